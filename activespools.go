@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActiveSpool is a spool mapped to a toolhead the current job prints with, and what it has
+// left as the job consumes it. Spoolman is only updated when the job finishes, so the
+// remaining weights here are estimates: the job's parsed requirement scaled by its progress.
+type ActiveSpool struct {
+	PrinterID         string  `json:"printer_id"`
+	PrinterName       string  `json:"printer_name"`
+	ToolheadID        int     `json:"toolhead_id"`
+	SpoolID           int     `json:"spool_id"`
+	JobID             int     `json:"job_id"`
+	JobName           string  `json:"job_name"`
+	Progress          float64 `json:"progress"`            // Job progress in percent
+	Required          float64 `json:"required"`            // Grams the whole job needs from this toolhead
+	Used              float64 `json:"used"`                // Grams used so far
+	RemainingAtStart  float64 `json:"remaining_at_start"`  // Grams left on the spool when the job started
+	Remaining         float64 `json:"remaining"`           // Grams left now
+	RemainingAfterJob float64 `json:"remaining_after_job"` // Grams left once the job finishes, negative if it runs out
+}
+
+// newActiveSpool estimates a spool's remaining weight at a job's progress, assuming filament
+// is consumed evenly over the job
+func newActiveSpool(printerID, printerName string, toolheadID, spoolID int, requirement *jobRequirement, remaining, required, progress float64) ActiveSpool {
+	progress = math.Max(0, math.Min(progress, 100))
+	used := required * progress / 100
+	return ActiveSpool{
+		PrinterID:         printerID,
+		PrinterName:       printerName,
+		ToolheadID:        toolheadID,
+		SpoolID:           spoolID,
+		JobID:             requirement.jobID,
+		JobName:           requirement.jobName,
+		Progress:          progress,
+		Required:          required,
+		Used:              used,
+		RemainingAtStart:  remaining,
+		Remaining:         remaining - used,
+		RemainingAfterJob: remaining - required,
+	}
+}
+
+// ActiveSpools returns the estimates for the spools of all running jobs, ordered by printer
+// and toolhead
+func (r *RunoutEstimator) ActiveSpools() []ActiveSpool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	active := []ActiveSpool{}
+	for _, printerActive := range r.active {
+		active = append(active, printerActive...)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].PrinterName != active[j].PrinterName {
+			return active[i].PrinterName < active[j].PrinterName
+		}
+		return active[i].ToolheadID < active[j].ToolheadID
+	})
+	return active
+}
+
+// getActiveSpoolsHandler returns the active spool estimates
+func (ws *WebServer) getActiveSpoolsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ActiveSpoolsResponse{ActiveSpools: ws.bridge.runout.ActiveSpools()})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GcodeAnalysis is what FilaBridge reads from a G-code file and, when checked against a
+// printer, whether the spools mapped to it can print the file
+type GcodeAnalysis struct {
+	File          string             `json:"file"`
+	Size          int                `json:"size"`                     // Bytes
+	Flavor        string             `json:"flavor"`                   // Flavor the file was parsed as
+	ParseError    string             `json:"parse_error,omitempty"`    // Why usage couldn't be read with the declared flavor
+	EstimatedTime int                `json:"estimated_time,omitempty"` // Slicer estimate in seconds
+	TotalWeight   float64            `json:"total_weight"`             // Grams across all toolheads
+	Toolheads     []AnalyzedToolhead `json:"toolheads"`
+	PrinterName   string             `json:"printer_name,omitempty"`
+	Compatible    *bool              `json:"compatible,omitempty"` // Set when checked against a printer
+	Problems      []string           `json:"problems,omitempty"`   // Problems with the file as a whole
+}
+
+// AnalyzedToolhead is one toolhead's part of a G-code analysis
+type AnalyzedToolhead struct {
+	ToolheadID       int      `json:"toolhead_id"`
+	Weight           float64  `json:"weight"`                       // Grams the file needs
+	Length           float64  `json:"length,omitempty"`             // mm, when the file only reports a length
+	WeightFromLength bool     `json:"weight_from_length,omitempty"` // Weight was converted from the length
+	Material         string   `json:"material,omitempty"`           // Sliced filament type
+	Color            string   `json:"color,omitempty"`              // Sliced color
+	SpoolID          int      `json:"spool_id,omitempty"`           // Spool mapped to the toolhead
+	Available        *float64 `json:"available,omitempty"`          // Grams on the spool not reserved for other jobs
+	Problems         []string `json:"problems,omitempty"`
+}
+
+// AnalyzeGcode parses a G-code file the way a finished print would be, with the flavor
+// given or, with a printer, the printer's flavor. With a printer, the result also reports
+// whether its current mappings can print the file.
+func (b *FilamentBridge) AnalyzeGcode(content []byte, filename, printer, flavor string) (*GcodeAnalysis, error) {
+	var config PrinterConfig
+	printerName := ""
+	if printer != "" {
+		_, printerConfig, exists := b.findPrinterConfig(printer)
+		if !exists {
+			return nil, newCodedError(ErrCodePrinterNotFound, "printer %s not found", printer)
+		}
+		config, printerName = printerConfig, resolvePrinterName(printerConfig)
+		if flavor == "" {
+			flavor = config.GcodeFlavor
+		}
+	}
+	if !validGcodeFlavor(flavor) {
+		return nil, newCodedError(ErrCodeInvalidRequest, "unknown G-code flavor: %s", flavor)
+	}
+
+	analysis := &GcodeAnalysis{
+		File:          filename,
+		Size:          len(content),
+		Flavor:        flavor,
+		EstimatedTime: ParseGcodePrintTime(content),
+		Toolheads:     []AnalyzedToolhead{},
+		PrinterName:   printerName,
+	}
+	if analysis.Flavor == GcodeFlavorAuto {
+		analysis.Flavor = detectGcodeFlavor(content)
+	}
+
+	usage, err := ParseGcodeUsage(content, flavor)
+	if err != nil {
+		analysis.ParseError = err.Error()
+	}
+	sliced := ParseGcodeFilaments(content)
+
+	toolheads := make(map[int]*AnalyzedToolhead)
+	toolhead := func(toolheadID int) *AnalyzedToolhead {
+		if _, exists := toolheads[toolheadID]; !exists {
+			toolheads[toolheadID] = &AnalyzedToolhead{ToolheadID: toolheadID}
+		}
+		return toolheads[toolheadID]
+	}
+	for toolheadID, weight := range usage.Weights {
+		toolhead(toolheadID).Weight = weight
+	}
+	for toolheadID, length := range usage.Lengths {
+		result := toolhead(toolheadID)
+		result.Length = length
+		result.WeightFromLength = true
+		// Converted with the mapped spool's filament when there is a printer, the defaults otherwise
+		if printerName != "" {
+			result.Weight = b.filamentLengthToWeight(printerName, toolheadID, length)
+		} else {
+			result.Weight = b.spoolLengthToWeight("", 0, length)
+		}
+	}
+	for toolheadID, material := range sliced.Types {
+		toolhead(toolheadID).Material = material
+	}
+	for toolheadID, color := range sliced.Colors {
+		toolhead(toolheadID).Color = color
+	}
+
+	weights := make(map[int]float64, len(toolheads))
+	for toolheadID, result := range toolheads {
+		if result.Weight > 0 {
+			weights[toolheadID] = result.Weight
+		}
+	}
+	if len(weights) == 0 && analysis.ParseError == "" {
+		analysis.Problems = append(analysis.Problems, "no filament usage found in the file")
+	}
+
+	if printerName != "" {
+		if analysis.ParseError != "" {
+			analysis.Problems = append(analysis.Problems, analysis.ParseError)
+		}
+		// The toolheads a print start check would look at, even when the file doesn't name them
+		for _, toolheadID := range jobToolheads(config, weights) {
+			toolhead(toolheadID)
+		}
+		if err := b.checkGcodeCompatibility(config, filename, sliced, toolheads); err != nil {
+			return nil, err
+		}
+	}
+
+	compatible := len(analysis.Problems) == 0
+	for _, result := range toolheads {
+		analysis.TotalWeight += result.Weight
+		if len(result.Problems) > 0 {
+			compatible = false
+		}
+		analysis.Toolheads = append(analysis.Toolheads, *result)
+	}
+	sort.Slice(analysis.Toolheads, func(i, j int) bool {
+		return analysis.Toolheads[i].ToolheadID < analysis.Toolheads[j].ToolheadID
+	})
+	if printerName != "" {
+		analysis.Compatible = &compatible
+	}
+	return analysis, nil
+}
+
+// checkGcodeCompatibility fills in the spool mapped to each toolhead of an analysis and what
+// stops it printing the file: a missing toolhead or spool, too little filament left after
+// other jobs' reservations, the wrong material or color, or a broken toolhead rule
+func (b *FilamentBridge) checkGcodeCompatibility(config PrinterConfig, filename string, sliced GcodeFilaments, toolheads map[int]*AnalyzedToolhead) error {
+	printerName := resolvePrinterName(config)
+	rules := b.activeToolheadRules(printerName)
+	mappings, err := b.GetToolheadMappings(printerName)
+	if err != nil {
+		return err
+	}
+	allSpools, err := b.spoolmanFor(printerName).GetAllSpools()
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+	spools := make(map[int]SpoolmanSpool, len(allSpools))
+	for _, spool := range allSpools {
+		spools[spool.ID] = spool
+	}
+	reserved := b.reservedWeights(printerName, filename)
+
+	for toolheadID, result := range toolheads {
+		if toolheadID >= config.SlotCount() {
+			result.Problems = append(result.Problems, fmt.Sprintf("toolhead %d isn't on %s, which has %d", toolheadID, printerName, config.SlotCount()))
+			continue
+		}
+		result.Problems = append(result.Problems, nozzleRuleProblems(toolheadID, rules[toolheadID], sliced)...)
+		mapping, mapped := mappings[toolheadID]
+		if !mapped || mapping.SpoolID == 0 {
+			result.Problems = append(result.Problems, fmt.Sprintf("toolhead %d has no spool mapped", toolheadID))
+			continue
+		}
+		result.SpoolID = mapping.SpoolID
+
+		spool, exists := spools[mapping.SpoolID]
+		if !exists {
+			result.Problems = append(result.Problems, fmt.Sprintf("toolhead %d is mapped to spool %d which is no longer in Spoolman", toolheadID, mapping.SpoolID))
+			continue
+		}
+		available := spool.RemainingWeight - reserved[mapping.SpoolID]
+		result.Available = &available
+		if result.Weight > available {
+			result.Problems = append(result.Problems, fmt.Sprintf("toolhead %d needs %.1fg but spool %d has only %.1fg available", toolheadID, result.Weight, mapping.SpoolID, available))
+		}
+		result.Problems = append(result.Problems, filamentMismatches(toolheadID, sliced, spool)...)
+		result.Problems = append(result.Problems, spoolRuleProblems(toolheadID, rules[toolheadID], spool)...)
+	}
+	return nil
+}
+
+// AnalyzePrinterFile downloads a file from a printer and analyzes it against the printer
+func (b *FilamentBridge) AnalyzePrinterFile(printer, filename, flavor string) (*GcodeAnalysis, error) {
+	printerID, config, exists := b.findPrinterConfig(printer)
+	if !exists {
+		return nil, newCodedError(ErrCodePrinterNotFound, "printer %s not found", printer)
+	}
+	if config.IPAddress == "" {
+		return nil, newCodedError(ErrCodeInvalidRequest, "printer %s has no PrusaLink address to read files from", printerID)
+	}
+
+	client := config.prusaLinkClient(b.GetConfigSnapshot())
+	content, err := client.GetGcodeFile(strings.TrimPrefix(filename, "/"))
+	if err != nil {
+		return nil, newCodedError(ErrCodePrinterError, "failed to download %s: %v", filename, err)
+	}
+	return b.AnalyzeGcode(content, filename, printerID, flavor)
+}
+
+// analyzeGcodeHandler analyzes a G-code file uploaded as the "file" form field, checked
+// against the printer in the printer_name field if there is one, or a file on a printer
+// given as JSON {"printer_name": ..., "path": ...}. Either can set the flavor to parse as.
+func (ws *WebServer) analyzeGcodeHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxGcodeAnalysisSize+1024*1024)
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		var req struct {
+			PrinterName string `json:"printer_name"`
+			Path        string `json:"path"`
+			Flavor      string `json:"flavor"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+			return
+		}
+		if req.PrinterName == "" || strings.TrimSpace(req.Path) == "" {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "printer_name and path are required, or upload a file")
+			return
+		}
+		analysis, err := ws.bridge.AnalyzePrinterFile(req.PrinterName, strings.TrimSpace(req.Path), req.Flavor)
+		if err != nil {
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+			return
+		}
+		c.JSON(http.StatusOK, analysis)
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Missing G-code file")
+		return
+	}
+	if header.Size > MaxGcodeAnalysisSize {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "G-code file is too large")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Failed to read G-code file")
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Failed to read G-code file")
+		return
+	}
+
+	analysis, err := ws.bridge.AnalyzeGcode(content, header.Filename, c.PostForm("printer_name"), c.PostForm("flavor"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, analysis)
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versions. Routes are served under /api/v1; the unversioned /api routes are deprecated
+// aliases kept for one release, except permanentAPIRoutes.
+const (
+	LegacyAPIPrefix = "/api"
+	APIV1Prefix     = "/api/v1"
+)
+
+// permanentAPIRoutes stay at their unversioned path for good and aren't marked deprecated:
+// their URLs are printed on NFC tags and QR codes or sent out in signed links
+var permanentAPIRoutes = map[string]bool{
+	"GET /api/nfc/assign":         true,
+	"GET /api/nfc/toolhead":       true,
+	"GET /api/nfc/session/status": true,
+	"DELETE /api/nfc/session":     true,
+	"GET /api/reminders/return":   true,
+}
+
+// v1Path returns the versioned path of an unversioned API path, e.g. /api/v1/status for
+// /api/status
+func v1Path(path string) string {
+	return APIV1Prefix + strings.TrimPrefix(path, LegacyAPIPrefix)
+}
+
+// apiRouteKey returns a request's route as the access rule maps key it, i.e. with the
+// unversioned path, so rules cover both the legacy and the versioned route
+func apiRouteKey(c *gin.Context) string {
+	path := c.FullPath()
+	if strings.HasPrefix(path, APIV1Prefix+"/") {
+		path = LegacyAPIPrefix + strings.TrimPrefix(path, APIV1Prefix)
+	}
+	return c.Request.Method + " " + path
+}
+
+// deprecatedAPI marks responses from the unversioned routes as deprecated and points to their
+// /api/v1 successor. Each route is logged the first time it's called so admins can find the
+// automations still using it.
+func (ws *WebServer) deprecatedAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := apiRouteKey(c)
+		if permanentAPIRoutes[route] {
+			c.Next()
+			return
+		}
+
+		successor := ws.path(v1Path(c.Request.URL.Path))
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		if _, logged := ws.deprecatedCalls.LoadOrStore(route, true); !logged {
+			webLog.Warn("Deprecated API route called; it will be removed in the next release", "route", route, "successor", v1Path(c.FullPath()), "client", c.ClientIP())
+		}
+		c.Next()
+	}
+}
+
+// Typed API responses. Fields only present in some responses are omitted when empty, so the
+// JSON matches what the unversioned routes have always returned.
+
+// MessageResponse is the response of routes that only report success
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// MappingResponse is the response of the toolhead mapping routes
+type MappingResponse struct {
+	Message  string   `json:"message"`
+	Warning  string   `json:"warning,omitempty"`  // Single mapping, e.g. a spool owned by another member
+	Warnings []string `json:"warnings,omitempty"` // Batch mappings
+}
+
+// SpoolsResponse lists spools
+type SpoolsResponse struct {
+	Spools []SpoolmanSpool `json:"spools"`
+}
+
+// ColorFamiliesResponse lists spools grouped by color family
+type ColorFamiliesResponse struct {
+	Families []ColorFamily `json:"families"`
+}
+
+// SpoolSuggestionsResponse lists suggested spools per toolhead for a file
+type SpoolSuggestionsResponse struct {
+	File      string                `json:"file"`
+	Toolheads []ToolheadSuggestions `json:"toolheads"`
+}
+
+// SpoolFieldsResponse lists a spool's editable extra fields
+type SpoolFieldsResponse struct {
+	SpoolID int          `json:"spool_id"`
+	Fields  []SpoolField `json:"fields"`
+}
+
+// SpoolTransferResponse reports a filament transfer
+type SpoolTransferResponse struct {
+	Message  string               `json:"message"`
+	Transfer *SpoolTransferResult `json:"transfer"`
+}
+
+// SpoolRefillResponse reports a spool refill
+type SpoolRefillResponse struct {
+	Message string             `json:"message"`
+	Refill  *SpoolRefillResult `json:"refill"`
+}
+
+// SpoolEventsResponse lists a spool's events
+type SpoolEventsResponse struct {
+	Events []SpoolEvent `json:"events"`
+}
+
+// PurgeResponse reports what a purge removed
+type PurgeResponse struct {
+	Purged *PurgeSummary `json:"purged"`
+}
+
+// MaterialDefaultsListResponse lists per-material defaults
+type MaterialDefaultsListResponse struct {
+	Materials []MaterialDefaults `json:"materials"`
+}
+
+// MaterialDefaultsSavedResponse returns a material's saved defaults
+type MaterialDefaultsSavedResponse struct {
+	Message  string            `json:"message"`
+	Defaults *MaterialDefaults `json:"defaults"`
+}
+
+// MappingMismatchesResponse lists mappings that disagree with Spoolman
+type MappingMismatchesResponse struct {
+	Mismatches []MappingMismatch `json:"mismatches"`
+}
+
+// PrinterResponse is a configured printer with its toolhead names. Unlike PrinterConfig every
+// setting is included, even when empty.
+type PrinterResponse struct {
+	Name               string         `json:"name"`
+	Model              string         `json:"model"`
+	IPAddress          string         `json:"ip_address"`
+	APIKey             string         `json:"api_key"`
+	Toolheads          int            `json:"toolheads"`
+	Slots              int            `json:"slots"`
+	ConnectPrinterUUID string         `json:"connect_printer_uuid"`
+	ConnectToken       string         `json:"connect_token"`
+	GcodeFlavor        string         `json:"gcode_flavor"`
+	PollInterval       int            `json:"poll_interval"` // Overrides of the global settings, 0 when the global value applies
+	ActivePollInterval int            `json:"active_poll_interval"`
+	PrusaLinkTimeout   int            `json:"prusalink_timeout"`
+	DownloadTimeout    int            `json:"prusalink_file_download_timeout"`
+	SpoolmanInstance   string         `json:"spoolman_instance"` // Empty for the main Spoolman instance
+	InsecureSkipVerify bool           `json:"insecure_skip_verify"`
+	Group              string         `json:"group"` // Printer group, empty when ungrouped
+	ToolheadNames      map[int]string `json:"toolhead_names,omitempty"`
+}
+
+// PrintersResponse lists the configured printers by ID
+type PrintersResponse struct {
+	Printers map[string]PrinterResponse `json:"printers"`
+}
+
+// PrinterAddedResponse returns the ID of a new printer
+type PrinterAddedResponse struct {
+	Message   string `json:"message"`
+	PrinterID string `json:"printer_id"`
+}
+
+// ToolheadNamesResponse lists a printer's toolhead names
+type ToolheadNamesResponse struct {
+	ToolheadNames map[int]string `json:"toolhead_names"`
+}
+
+// APIKeyRotationResponse reports a printer API key change
+type APIKeyRotationResponse struct {
+	Message  string `json:"message"`
+	Verified bool   `json:"verified"`
+}
+
+// PrinterEventsResponse lists a printer's events
+type PrinterEventsResponse struct {
+	Events []PrinterEvent `json:"events"`
+}
+
+// WebhookSecretResponse returns a new print event webhook secret
+type WebhookSecretResponse struct {
+	Message string `json:"message"`
+	Secret  string `json:"secret"`
+	Header  string `json:"header"`
+}
+
+// MaintenanceWindowsResponse lists a printer's maintenance windows
+type MaintenanceWindowsResponse struct {
+	Windows       []MaintenanceWindow `json:"windows"`
+	InMaintenance bool                `json:"in_maintenance"`
+}
+
+// DetectedPrinterResponse describes a printer found at an address
+type DetectedPrinterResponse struct {
+	Model    string `json:"model"`
+	Hostname string `json:"hostname"`
+	MMU      bool   `json:"mmu"`
+	Detected bool   `json:"detected"`
+	Warning  string `json:"warning,omitempty"`
+}
+
+// DiscoveredPrintersResponse lists printers found on the network
+type DiscoveredPrintersResponse struct {
+	Printers []DiscoveredPrinter `json:"printers"`
+}
+
+// PrintHistoryResponse lists prints
+type PrintHistoryResponse struct {
+	History []PrintHistory `json:"history"`
+}
+
+// SpoolHistoryResponse lists the prints that used a spool
+type SpoolHistoryResponse struct {
+	SpoolID int            `json:"spool_id"`
+	History []PrintHistory `json:"history"`
+}
+
+// HistoryEntryResponse returns a print history entry that was recorded or changed
+type HistoryEntryResponse struct {
+	Message string        `json:"message"`
+	Entry   *PrintHistory `json:"entry"`
+}
+
+// HistoryCorrectionsResponse lists the corrections made to a print
+type HistoryCorrectionsResponse struct {
+	HistoryID   int                 `json:"history_id"`
+	Corrections []HistoryCorrection `json:"corrections"`
+}
+
+// HistoryExportResponse is the JSON form of a history export
+type HistoryExportResponse struct {
+	History []HistoryExportRow `json:"history"`
+}
+
+// SimulatedPrintResponse reports a simulated print completion
+type SimulatedPrintResponse struct {
+	Message       string          `json:"message"`
+	Printer       string          `json:"printer"`
+	Job           string          `json:"job"`
+	FilamentUsage map[int]float64 `json:"filament_usage"`
+}
+
+// PrintErrorsResponse lists prints that couldn't be processed
+type PrintErrorsResponse struct {
+	Errors []PrintError `json:"errors"`
+}
+
+// PendingUpdatesResponse lists Spoolman updates waiting to be retried
+type PendingUpdatesResponse struct {
+	PendingUpdates []PendingUpdate `json:"pending_updates"`
+}
+
+// ActiveSpoolsResponse lists the estimated remaining weight of the spools running jobs use
+type ActiveSpoolsResponse struct {
+	ActiveSpools []ActiveSpool `json:"active_spools"`
+}
+
+// SpoolReservationsResponse lists spool reservations for queued jobs
+type SpoolReservationsResponse struct {
+	Reservations []SpoolReservation `json:"reservations"`
+}
+
+// SpoolReservationResponse reports a new spool reservation
+type SpoolReservationResponse struct {
+	Message     string            `json:"message"`
+	Reservation *SpoolReservation `json:"reservation"`
+}
+
+// FilamentRunoutsResponse lists the filament runouts waiting for a decision
+type FilamentRunoutsResponse struct {
+	Runouts []FilamentRunout `json:"runouts"`
+}
+
+// UnattributedUsageResponse lists usage of unmapped toolheads waiting to be assigned to a spool
+type UnattributedUsageResponse struct {
+	Usage []UnattributedUsage `json:"usage"`
+}
+
+// FilamentRunoutResponse reports a confirmed filament runout
+type FilamentRunoutResponse struct {
+	Message string          `json:"message"`
+	Runout  *FilamentRunout `json:"runout"`
+}
+
+// RunoutPredictionsResponse lists predicted runouts
+type RunoutPredictionsResponse struct {
+	Predictions []RunoutPrediction `json:"predictions"`
+}
+
+// LocationSummary is a storage location in a location list
+type LocationSummary struct {
+	Name        string               `json:"name"`
+	Type        string               `json:"type"`
+	IsVirtual   bool                 `json:"is_virtual"`
+	Environment *LocationEnvironment `json:"environment,omitempty"` // Drybox flag and latest sensor reading, if any
+}
+
+// LocationsResponse lists storage locations
+type LocationsResponse struct {
+	Locations   []LocationSummary `json:"locations"`
+	SpoolmanURL string            `json:"spoolman_url"`
+}
+
+// LocationResponse is a Spoolman location
+type LocationResponse struct {
+	Name     string `json:"name"`
+	ID       int    `json:"id"`
+	Comment  string `json:"comment"`
+	Archived bool   `json:"archived"`
+}
+
+// newLocationResponse converts a Spoolman location
+func newLocationResponse(location *SpoolmanLocation) LocationResponse {
+	return LocationResponse{Name: location.Name, ID: location.ID, Comment: location.Comment, Archived: location.Archived}
+}
+
+// LocationUpdatedResponse reports a renamed location
+type LocationUpdatedResponse struct {
+	Message  string            `json:"message"`
+	Location *LocationResponse `json:"location,omitempty"` // Absent when the location wasn't in Spoolman
+}
+
+// NFCURLsResponse lists the URLs to write to tags. Entries vary with their type: spool,
+// filament or location.
+type NFCURLsResponse struct {
+	URLs        []gin.H `json:"urls"`
+	SpoolmanURL string  `json:"spoolman_url"`
+}
+
+// NDEFResponse is an NFC tag's NDEF message in the encodings tag writers take
+type NDEFResponse struct {
+	URL        string     `json:"url"`
+	NDEFHex    string     `json:"ndef_hex"`
+	NDEFBase64 string     `json:"ndef_base64"`
+	TLVHex     string     `json:"tlv_hex"`
+	Size       int        `json:"size"`
+	WebNFC     NDEFWebNFC `json:"web_nfc"`
+}
+
+// NDEFWebNFC is the message as passed to the Web NFC API's write()
+type NDEFWebNFC struct {
+	Records []NDEFWebNFCRecord `json:"records"`
+}
+
+// NDEFWebNFCRecord is a Web NFC record
+type NDEFWebNFCRecord struct {
+	RecordType string `json:"recordType"`
+	Data       string `json:"data"`
+}
+
+// NFCSessionStatusResponse reports the NFC scan session; the session's fields are only
+// present while one is active
+type NFCSessionStatusResponse struct {
+	Active bool `json:"active"`
+	*NFCSessionDetails
+}
+
+// NFCSessionDetails describes an active NFC scan session
+type NFCSessionDetails struct {
+	SessionID         string    `json:"session_id"`
+	HasSpool          bool      `json:"has_spool"`
+	HasLocation       bool      `json:"has_location"`
+	SpoolID           int       `json:"spool_id"`
+	PrinterName       string    `json:"printer_name"`
+	ToolheadID        int       `json:"toolhead_id"`
+	LocationName      string    `json:"location_name"`
+	IsPrinterLocation bool      `json:"is_printer_location"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// ConfigOverridesResponse lists settings set by environment variables
+type ConfigOverridesResponse struct {
+	Overrides map[string]string `json:"overrides"`
+}
+
+// ConfigImportResponse counts what an import wrote
+type ConfigImportResponse struct {
+	Message  string `json:"message"`
+	Config   int    `json:"config"`
+	Printers int    `json:"printers"`
+	Mappings int    `json:"mappings"`
+}
+
+// AutoAssignPreviousSpoolResponse is where replaced spools are moved
+type AutoAssignPreviousSpoolResponse struct {
+	Enabled  bool   `json:"enabled"`
+	Location string `json:"location"`
+}
+
+// SpoolmanConnectionResponse reports a Spoolman connection check
+type SpoolmanConnectionResponse struct {
+	Message   string `json:"message"`
+	Connected bool   `json:"connected"`
+}
+
+// NotificationChannelsResponse lists notification channels
+type NotificationChannelsResponse struct {
+	Channels []NotificationChannel `json:"channels"`
+}
+
+// HeldNotificationsResponse lists notifications held for quiet hours by channel
+type HeldNotificationsResponse struct {
+	Held map[string][]Notification `json:"held"`
+}
+
+// OutgoingWebhooksResponse lists outgoing webhooks
+type OutgoingWebhooksResponse struct {
+	Webhooks []OutgoingWebhook `json:"webhooks"`
+}
+
+// ScalesResponse lists the configured smart scales
+type ScalesResponse struct {
+	Scales []ScaleConfig `json:"scales"`
+}
+
+// ScaleReadingsResponse lists measured spool weights
+type ScaleReadingsResponse struct {
+	Readings []ScaleReading `json:"readings"`
+}
+
+// EnvironmentSensorsResponse lists the configured humidity/temperature sensors
+type EnvironmentSensorsResponse struct {
+	Sensors []EnvironmentSensor `json:"sensors"`
+}
+
+// LocationEnvironmentResponse is a location's environment with its recent readings
+type LocationEnvironmentResponse struct {
+	Environment LocationEnvironment  `json:"environment"`
+	Readings    []EnvironmentReading `json:"readings"`
+}
+
+// SpoolTimelineResponse is everything that happened to a spool, newest first
+type SpoolTimelineResponse struct {
+	SpoolID  int                  `json:"spool_id"`
+	Timeline []SpoolTimelineEntry `json:"timeline"`
+}
+
+// SharedSpoolsResponse lists the spools allowed on several toolheads
+type SharedSpoolsResponse struct {
+	Spools []SharedSpool `json:"spools"`
+}
+
+// ToolheadRulesResponse lists a printer's toolhead rules
+type ToolheadRulesResponse struct {
+	Rules []ToolheadRule `json:"rules"`
+}
+
+// PrinterGroupsResponse is the farm view: the printer groups and the printers in none
+type PrinterGroupsResponse struct {
+	Groups    []PrinterGroup        `json:"groups"`
+	Ungrouped []PrinterGroupPrinter `json:"ungrouped"`
+}
+
+// SpoolExposuresResponse lists how long spools have been out of dry storage
+type SpoolExposuresResponse struct {
+	Spools []SpoolExposure `json:"spools"`
+}
+
+// SpoolmanInstancesResponse lists the additional Spoolman instances
+type SpoolmanInstancesResponse struct {
+	Instances []SpoolmanInstance `json:"instances"`
+}
+
+// ScheduledJobsResponse lists scheduled jobs
+type ScheduledJobsResponse struct {
+	Jobs []ScheduledJobStatus `json:"jobs"`
+}
+
+// RestoreResponse reports a database restore
+type RestoreResponse struct {
+	Message          string `json:"message"`
+	PreviousDatabase string `json:"previous_database"`
+}
+
+// FixturesResponse reports the loaded demo data
+type FixturesResponse struct {
+	Message  string          `json:"message,omitempty"`
+	Fixtures *FixtureSummary `json:"fixtures"`
+}
+
+// FixturesDeletedResponse reports deleted demo data
+type FixturesDeletedResponse struct {
+	Message string          `json:"message"`
+	Deleted *FixtureSummary `json:"deleted"`
+}
+
+// LogsResponse lists log entries
+type LogsResponse struct {
+	Logs []LogEntry `json:"logs"`
+}
+
+// AuthStatusResponse describes access control and the caller
+type AuthStatusResponse struct {
+	Enabled      bool   `json:"enabled"`
+	LoginEnabled bool   `json:"login_enabled"`
+	Role         string `json:"role"`
+	Member       string `json:"member,omitempty"`
+}
+
+// AdminTokenResponse returns a new admin token
+type AdminTokenResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+// APITokensResponse lists API tokens
+type APITokensResponse struct {
+	Tokens []APIToken `json:"tokens"`
+}
+
+// APITokenCreatedResponse returns a new API token; the token is only ever returned here
+type APITokenCreatedResponse struct {
+	APIToken *APIToken `json:"api_token"`
+	Token    string    `json:"token"`
+}
+
+// MembersResponse lists members
+type MembersResponse struct {
+	Members []Member `json:"members"`
+}
+
+// MemberCreatedResponse returns a new member and their token, which is only returned here
+type MemberCreatedResponse struct {
+	Member *Member `json:"member"`
+	Token  string  `json:"token"`
+}
+
+// VendorCreatedResponse reports a vendor added to Spoolman
+type VendorCreatedResponse struct {
+	Message string          `json:"message"`
+	Vendor  *SpoolmanVendor `json:"vendor"`
+}
+
+// FilamentCreatedResponse reports a filament type added to Spoolman
+type FilamentCreatedResponse struct {
+	Message  string            `json:"message"`
+	Filament *SpoolmanFilament `json:"filament"`
+}
+
+// FilamentBarcodesResponse lists the barcode mappings
+type FilamentBarcodesResponse struct {
+	Barcodes []FilamentBarcode `json:"barcodes"`
+}
+
+// FilamentBarcodeResponse returns a saved barcode mapping
+type FilamentBarcodeResponse struct {
+	Message string           `json:"message"`
+	Barcode *FilamentBarcode `json:"barcode"`
+}
+
+// SpoolCreatedResponse reports a spool added to Spoolman and the toolhead it was mapped to
+type SpoolCreatedResponse struct {
+	Message string          `json:"message"`
+	Result  *NewSpoolResult `json:"result"`
+}
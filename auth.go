@@ -0,0 +1,627 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles that can be given to API tokens besides admin
+const (
+	RoleViewer = "viewer" // Read-only access
+	RoleNFC    = "nfc"    // Only the NFC scan URLs, for tokens embedded in tags
+)
+
+// AuthSessionCookie is the cookie holding a UI login session
+const AuthSessionCookie = "filabridge_session"
+
+// publicRoutes can be called without credentials even when access control is on
+var publicRoutes = map[string]bool{
+	"GET /api/auth/status":  true,
+	"POST /api/auth/login":  true,
+	"POST /api/auth/logout": true,
+	// The signature on the link authorizes it
+	"GET /api/reminders/return": true,
+	// The printer's webhook secret authorizes it
+	"POST /api/webhooks/print-event": true,
+	// The API description holds no data
+	"GET " + OpenAPIPath:     true,
+	"GET " + OpenAPIDocsPath: true,
+}
+
+// scanRoutes are the GET routes opened from NFC tags and QR codes. They change state, so
+// when access control is on they need a token, which may be passed as ?token= and may be
+// scoped to the nfc role.
+var scanRoutes = map[string]bool{
+	"GET /api/nfc/assign":         true,
+	"POST /api/nfc/barcode":       true, // Checks for the admin role itself, to answer with a page
+	"GET /api/nfc/toolhead":       true,
+	"GET /api/nfc/session/status": true,
+	"DELETE /api/nfc/session":     true,
+}
+
+// adminReadRoutes are read-only routes that still require the admin role
+var adminReadRoutes = map[string]bool{
+	"GET /api/tokens": true,
+	// Backups include printer API keys and token hashes
+	"GET /api/backup": true,
+	// Configuration exports include printer API keys
+	"GET /api/config/export": true,
+	// Subnet scans probe other hosts on the network
+	"GET /api/discover_printers": true,
+	// Notification channels include bot tokens and webhook URLs
+	"GET /api/notifications/channels": true,
+	// Outgoing webhooks include their signing secrets
+	"GET /api/webhooks/outgoing": true,
+	// Spoolman instances include their basic auth passwords
+	"GET /api/spoolman/instances": true,
+	// Printers include their PrusaLink API keys and Prusa Connect tokens
+	"GET /api/printers": true,
+	// Configuration includes credentials that aren't hidden, such as Spoolman's
+	"GET /api/config": true,
+	// Tag URLs, label sheets and NDEF records embed the NFC scan token
+	"GET /api/nfc/urls":       true,
+	"GET /api/nfc/labels.pdf": true,
+	"GET /api/nfc/ndef":       true,
+	// Members are only managed by admins
+	"GET /api/members": true,
+	// Logs can include addresses, job names and request details
+	"GET /api/logs":        true,
+	"GET /api/logs/stream": true,
+}
+
+// APIToken is a named API token created by an admin
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// authCaller is who made a request, as resolved by the access control middleware
+type authCaller struct {
+	role     string
+	member   *Member
+	username string // UI login user, for session callers
+}
+
+// validTokenRole reports whether API tokens can be created with a role
+func validTokenRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleViewer, RoleNFC:
+		return true
+	}
+	return false
+}
+
+// CreateAPIToken adds a named token and returns it with the plain token, which is only shown once
+func (b *FilamentBridge) CreateAPIToken(name, role string) (*APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", newCodedError(ErrCodeInvalidRequest, "token name is required")
+	}
+	if !validTokenRole(role) {
+		return nil, "", newCodedError(ErrCodeInvalidRequest, "role must be %s, %s or %s", RoleAdmin, RoleViewer, RoleNFC)
+	}
+
+	token, err := generateAccessToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	createdAt := time.Now()
+	result, err := b.db.Exec(
+		"INSERT INTO api_tokens (name, token_hash, role, created_at) VALUES (?, ?, ?, ?)",
+		name, hashAccessToken(token), role, createdAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get API token ID: %w", err)
+	}
+	return &APIToken{ID: int(id), Name: name, Role: role, CreatedAt: createdAt}, token, nil
+}
+
+// GetAPITokens returns all named API tokens
+func (b *FilamentBridge) GetAPITokens() ([]APIToken, error) {
+	rows, err := b.db.Query("SELECT id, name, role, created_at, last_used_at FROM api_tokens ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var token APIToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&token.ID, &token.Name, &token.Role, &token.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		if lastUsed.Valid {
+			token.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes a named API token
+func (b *FilamentBridge) DeleteAPIToken(id int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec("DELETE FROM api_tokens WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return newCodedError(ErrCodeNotFound, "API token %d not found", id)
+	}
+	return nil
+}
+
+// authenticateAPIToken returns the role of a named token, or an empty string if it's unknown
+func (b *FilamentBridge) authenticateAPIToken(token string) (string, error) {
+	hash := hashAccessToken(token)
+	var role string
+	err := b.db.QueryRow("SELECT role FROM api_tokens WHERE token_hash = ?", hash).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate API token: %w", err)
+	}
+
+	if _, err := b.db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE token_hash = ?", time.Now(), hash); err != nil {
+		webLog.Warn("Failed to record API token use", "error", err)
+	}
+	return role, nil
+}
+
+// accessControlEnabled reports whether access control is on, and whether UI login is configured.
+// It is on once any admin credential exists: the admin token, an admin API token or a login.
+func (b *FilamentBridge) accessControlEnabled() (bool, bool) {
+	loginEnabled := false
+	if hash, err := b.GetConfigValue(ConfigKeyAuthPasswordHash); err == nil && hash != "" {
+		loginEnabled = true
+	}
+	if loginEnabled {
+		return true, true
+	}
+
+	if hash, err := b.GetConfigValue(ConfigKeyAdminTokenHash); err == nil && hash != "" {
+		return true, false
+	}
+
+	var adminTokens int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE role = ?", RoleAdmin).Scan(&adminTokens); err != nil {
+		webLog.Warn("Failed to count admin API tokens", "error", err)
+	}
+	return adminTokens > 0, false
+}
+
+// SetLoginCredentials sets the UI login; an empty password disables login. Existing sessions
+// are ended either way.
+func (b *FilamentBridge) SetLoginCredentials(username, password string) error {
+	username = strings.TrimSpace(username)
+	hash := ""
+	if password != "" {
+		if username == "" {
+			return newCodedError(ErrCodeInvalidRequest, "username is required")
+		}
+		if len(password) < MinLoginPasswordLength {
+			return newCodedError(ErrCodeInvalidRequest, "password must be at least %d characters", MinLoginPasswordLength)
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return newCodedError(ErrCodeInvalidRequest, "failed to hash password: %v", err)
+		}
+		hash = string(hashed)
+	} else {
+		username = ""
+	}
+
+	if err := b.SetConfigValue(ConfigKeyAuthUsername, username); err != nil {
+		return err
+	}
+	if err := b.SetConfigValue(ConfigKeyAuthPasswordHash, hash); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, err := b.db.Exec("DELETE FROM auth_sessions"); err != nil {
+		return fmt.Errorf("failed to end login sessions: %w", err)
+	}
+	return nil
+}
+
+// Login checks UI credentials and starts a session, returning its token
+func (b *FilamentBridge) Login(username, password string) (string, error) {
+	expectedUser, _ := b.GetConfigValue(ConfigKeyAuthUsername)
+	hash, _ := b.GetConfigValue(ConfigKeyAuthPasswordHash)
+	if hash == "" {
+		return "", newCodedError(ErrCodeInvalidRequest, "login is not enabled")
+	}
+
+	// Always compare the password so a wrong username takes as long as a wrong password
+	passwordErr := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if passwordErr != nil || !hmac.Equal([]byte(strings.TrimSpace(username)), []byte(expectedUser)) {
+		return "", newCodedError(ErrCodeUnauthorized, "invalid username or password")
+	}
+
+	token, err := generateAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	if _, err := b.db.Exec("DELETE FROM auth_sessions WHERE expires_at < ?", now); err != nil {
+		webLog.Warn("Failed to clean up expired login sessions", "error", err)
+	}
+	_, err = b.db.Exec(
+		"INSERT INTO auth_sessions (token_hash, username, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		hashAccessToken(token), expectedUser, now, now.Add(AuthSessionDuration),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create login session: %w", err)
+	}
+	return token, nil
+}
+
+// Logout ends a UI session
+func (b *FilamentBridge) Logout(token string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, err := b.db.Exec("DELETE FROM auth_sessions WHERE token_hash = ?", hashAccessToken(token)); err != nil {
+		return fmt.Errorf("failed to end login session: %w", err)
+	}
+	return nil
+}
+
+// authenticateSession returns the user of an unexpired session, or an empty string
+func (b *FilamentBridge) authenticateSession(token string) (string, error) {
+	var username string
+	err := b.db.QueryRow(
+		"SELECT username FROM auth_sessions WHERE token_hash = ? AND expires_at > ?",
+		hashAccessToken(token), time.Now(),
+	).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check login session: %w", err)
+	}
+	return username, nil
+}
+
+// nfcScanToken returns the nfc-scoped token embedded in generated tag URLs. It is derived
+// from a secret so it can be printed again at any time; rotating the secret revokes it.
+func (b *FilamentBridge) nfcScanToken() (string, error) {
+	secret, err := b.GetConfigValue(ConfigKeyNFCTokenSecret)
+	if err != nil || secret == "" {
+		if secret, err = b.RotateNFCScanToken(); err != nil {
+			return "", err
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("nfc-scan"))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// RotateNFCScanToken replaces the secret behind the NFC scan token, invalidating tags
+// written with the old one
+func (b *FilamentBridge) RotateNFCScanToken() (string, error) {
+	secret, err := generateAccessToken()
+	if err != nil {
+		return "", err
+	}
+	if err := b.SetConfigValue(ConfigKeyNFCTokenSecret, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// nfcScanURL adds the NFC scan token to a tag URL when access control is on
+func (ws *WebServer) nfcScanURL(url string) string {
+	return ws.bridge.nfcScanURL(url)
+}
+
+// nfcScanURL adds the NFC scan token to a scan URL when access control is on, for links
+// built outside a request such as those in notifications
+func (b *FilamentBridge) nfcScanURL(url string) string {
+	if enabled, _ := b.accessControlEnabled(); !enabled {
+		return url
+	}
+	token, err := b.nfcScanToken()
+	if err != nil {
+		webLog.Warn("Failed to get NFC scan token", "error", err)
+		return url
+	}
+	return url + "&token=" + token
+}
+
+// resolveCaller works out who is calling from the request's token or session cookie
+func (ws *WebServer) resolveCaller(c *gin.Context, allowQueryToken bool) authCaller {
+	token := requestToken(c)
+	if token == "" && allowQueryToken {
+		token = strings.TrimSpace(c.Query("token"))
+	}
+
+	if token != "" {
+		if adminTokenHash, err := ws.bridge.GetConfigValue(ConfigKeyAdminTokenHash); err == nil &&
+			adminTokenHash != "" && hmac.Equal([]byte(hashAccessToken(token)), []byte(adminTokenHash)) {
+			return authCaller{role: RoleAdmin}
+		}
+		if role, err := ws.bridge.authenticateAPIToken(token); err == nil && role != "" {
+			return authCaller{role: role}
+		}
+		if member, err := ws.bridge.AuthenticateMember(token); err == nil && member != nil {
+			return authCaller{role: RoleMember, member: member}
+		}
+		if allowQueryToken {
+			if scanToken, err := ws.bridge.nfcScanToken(); err == nil && hmac.Equal([]byte(token), []byte(scanToken)) {
+				return authCaller{role: RoleNFC}
+			}
+		}
+	}
+
+	if cookie, err := c.Cookie(AuthSessionCookie); err == nil && cookie != "" {
+		if username, err := ws.bridge.authenticateSession(cookie); err == nil && username != "" {
+			return authCaller{role: RoleAdmin, username: username}
+		}
+	}
+	return authCaller{}
+}
+
+// accessControl resolves the caller's role and restricts API requests by role. Until an admin
+// credential exists every caller is treated as admin, matching an open install. Read-only
+// requests are allowed without credentials unless UI login is configured, in which case the
+// whole API needs a session or token. NFC scan URLs accept tokens scoped to the nfc role.
+func (ws *WebServer) accessControl() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, loginEnabled := ws.bridge.accessControlEnabled()
+		if !enabled {
+			c.Set(contextKeyRole, RoleAdmin)
+			c.Next()
+			return
+		}
+
+		route := apiRouteKey(c)
+		caller := ws.resolveCaller(c, scanRoutes[route])
+		if caller.role != "" {
+			c.Set(contextKeyRole, caller.role)
+		}
+		if caller.member != nil {
+			c.Set(contextKeyMember, caller.member)
+		}
+
+		if publicRoutes[route] {
+			c.Next()
+			return
+		}
+
+		if scanRoutes[route] {
+			if caller.role == "" {
+				c.HTML(http.StatusUnauthorized, "nfc_error.html", gin.H{
+					"Error": "This tag needs an access token. Regenerate the tag from NFC Management.",
+				})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if caller.role == RoleNFC {
+			respondError(c, http.StatusForbidden, ErrCodeForbidden, "This token can only be used for NFC scans")
+			c.Abort()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if adminReadRoutes[route] && caller.role != RoleAdmin {
+				respondError(c, http.StatusForbidden, ErrCodeForbidden, "This action requires the admin role")
+				c.Abort()
+				return
+			}
+			if loginEnabled && caller.role == "" {
+				respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Login required")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		switch caller.role {
+		case RoleAdmin:
+			c.Next()
+		case RoleMember:
+			if memberRoutes[route] {
+				c.Next()
+				return
+			}
+			respondError(c, http.StatusForbidden, ErrCodeForbidden, "This action requires the admin role")
+			c.Abort()
+		case RoleViewer:
+			respondError(c, http.StatusForbidden, ErrCodeForbidden, "This token is read-only")
+			c.Abort()
+		default:
+			respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "A valid API token is required")
+			c.Abort()
+		}
+	}
+}
+
+// requireLogin redirects UI pages to the login page when login is configured and the
+// caller has no session or token
+func (ws *WebServer) requireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, loginEnabled := ws.bridge.accessControlEnabled(); !loginEnabled {
+			c.Next()
+			return
+		}
+		if caller := ws.resolveCaller(c, false); caller.role == "" || caller.role == RoleNFC {
+			c.Redirect(http.StatusFound, ws.path("/login"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// loginPageHandler shows the UI login form
+func (ws *WebServer) loginPageHandler(c *gin.Context) {
+	if _, loginEnabled := ws.bridge.accessControlEnabled(); !loginEnabled {
+		c.Redirect(http.StatusFound, ws.path("/"))
+		return
+	}
+	c.HTML(http.StatusOK, "login.html", gin.H{})
+}
+
+// authStatusHandler reports whether access control and login are on, and the caller's role
+func (ws *WebServer) authStatusHandler(c *gin.Context) {
+	enabled, loginEnabled := ws.bridge.accessControlEnabled()
+	response := AuthStatusResponse{
+		Enabled:      enabled,
+		LoginEnabled: loginEnabled,
+		Role:         c.GetString(contextKeyRole),
+	}
+	if member := callerMember(c); member != nil {
+		response.Member = member.Name
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// loginHandler checks UI credentials and sets the session cookie
+func (ws *WebServer) loginHandler(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	token, err := ws.bridge.Login(req.Username, req.Password)
+	if err != nil {
+		webLog.Warn("Failed login attempt", "username", req.Username, "client_ip", c.ClientIP())
+		respondErrorFrom(c, http.StatusUnauthorized, ErrCodeUnauthorized, err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(AuthSessionCookie, token, int(AuthSessionDuration/time.Second), ws.path("/"), "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, MessageResponse{Message: "Logged in"})
+}
+
+// logoutHandler ends the caller's UI session
+func (ws *WebServer) logoutHandler(c *gin.Context) {
+	if cookie, err := c.Cookie(AuthSessionCookie); err == nil && cookie != "" {
+		if err := ws.bridge.Logout(cookie); err != nil {
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+			return
+		}
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(AuthSessionCookie, "", -1, ws.path("/"), "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, MessageResponse{Message: "Logged out"})
+}
+
+// updateLoginCredentialsHandler sets or clears the UI login
+func (ws *WebServer) updateLoginCredentialsHandler(c *gin.Context) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.SetLoginCredentials(req.Username, req.Password); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if req.Password == "" {
+		c.JSON(http.StatusOK, MessageResponse{Message: "Login disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Login enabled; the dashboard and API now require a session or token"})
+}
+
+// getAPITokensHandler lists named API tokens (without the tokens themselves)
+func (ws *WebServer) getAPITokensHandler(c *gin.Context) {
+	tokens, err := ws.bridge.GetAPITokens()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, APITokensResponse{Tokens: tokens})
+}
+
+// createAPITokenHandler creates a named API token and returns it
+func (ws *WebServer) createAPITokenHandler(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON or missing 'name' or 'role' field")
+		return
+	}
+
+	apiToken, token, err := ws.bridge.CreateAPIToken(req.Name, req.Role)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusCreated, APITokenCreatedResponse{APIToken: apiToken, Token: token})
+}
+
+// deleteAPITokenHandler revokes a named API token
+func (ws *WebServer) deleteAPITokenHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid token ID")
+		return
+	}
+
+	if err := ws.bridge.DeleteAPIToken(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "API token deleted successfully"})
+}
+
+// rotateNFCScanTokenHandler revokes the NFC scan token embedded in existing tags
+func (ws *WebServer) rotateNFCScanTokenHandler(c *gin.Context) {
+	if _, err := ws.bridge.RotateNFCScanToken(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "NFC scan token rotated; rewrite your NFC tags and QR codes"})
+}
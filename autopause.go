@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Low filament check modes
+const (
+	LowFilamentCheckOff   = "off"
+	LowFilamentCheckWarn  = "warn"
+	LowFilamentCheckPause = "pause"
+)
+
+// checkSpoolsOnPrintStart pauses a print that just started when one of the toolheads it
+// uses has an empty spool (or no spool, if configured), and records a print error so the
+// problem shows up on the dashboard. With the low filament check enabled, spools that have
+// less left than the job's G-code requires, after filament reserved for other queued jobs,
+// are reported too, and optionally paused, as are spools whose material or color doesn't
+// match what the job was sliced for and spools or nozzle sizes that break a toolhead's rules.
+func (b *FilamentBridge) checkSpoolsOnPrintStart(config PrinterConfig, jobID int, filename, jobName string) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+	printerName := resolvePrinterName(config)
+	checkLowFilament := snapshot.LowFilamentCheck != LowFilamentCheckOff
+	checkMismatch := snapshot.FilamentMismatchCheck != FilamentMismatchCheckOff
+	rules := b.activeToolheadRules(printerName)
+	checkRules := len(rules) > 0
+	checkNozzles := false
+	for _, rule := range rules {
+		checkNozzles = checkNozzles || rule.NozzleDiameter > 0
+	}
+	if !snapshot.AutoPauseEmptySpool && !snapshot.AutoPauseUnmapped && !checkLowFilament && !snapshot.RunoutPredictionEnabled && !snapshot.ActiveSpoolEstimates && !checkMismatch && !checkRules {
+		return
+	}
+
+	mappings, err := b.GetToolheadMappings(printerName)
+	if err != nil {
+		monitorLog.Warn("Failed to get toolhead mappings for print start check", "printer", printerName, "error", err)
+		return
+	}
+
+	// Spools are only needed if a mapped spool could be empty, short, the wrong filament or
+	// not allowed on its toolhead
+	spools := make(map[int]SpoolmanSpool)
+	if (snapshot.AutoPauseEmptySpool || checkLowFilament || checkMismatch || checkRules) && len(mappings) > 0 {
+		allSpools, err := b.spoolmanFor(printerName).GetAllSpools()
+		if err != nil {
+			monitorLog.Warn("Failed to get spools for print start check", "printer", printerName, "error", err)
+			return
+		}
+		for _, spool := range allSpools {
+			spools[spool.ID] = spool
+		}
+	}
+
+	// Single-toolhead jobs are only downloaded when something in the file is needed, since
+	// there is no toolhead selection to make
+	needAmounts := checkLowFilament || snapshot.RunoutPredictionEnabled || snapshot.ActiveSpoolEstimates
+	var gcodeContent []byte
+	if config.SlotCount() > 1 || needAmounts || checkMismatch || checkNozzles {
+		gcodeContent = b.downloadJobGcode(config, snapshot, filename)
+	}
+	required := b.jobFilamentUsage(config, filename, gcodeContent)
+	var sliced GcodeFilaments
+	if (checkMismatch || checkNozzles) && gcodeContent != nil {
+		sliced = ParseGcodeFilaments(gcodeContent)
+	}
+	if (snapshot.RunoutPredictionEnabled || snapshot.ActiveSpoolEstimates) && required != nil {
+		b.runout.SetJobRequirement(printerName, jobID, jobName, required, snapshot.RunoutPredictionEnabled)
+	}
+
+	// Filament reserved for other queued jobs isn't available to this one
+	var reserved map[int]float64
+	if checkLowFilament {
+		reserved = b.reservedWeights(printerName, filename, jobName)
+	}
+
+	var pauseProblems, warnProblems, mismatchProblems, ruleProblems []string
+	for _, toolheadID := range jobToolheads(config, required) {
+		if problems := nozzleRuleProblems(toolheadID, rules[toolheadID], sliced); len(problems) > 0 {
+			if snapshot.ToolheadRuleCheck == ToolheadRuleCheckReject {
+				pauseProblems = append(pauseProblems, problems...)
+			} else {
+				ruleProblems = append(ruleProblems, problems...)
+			}
+		}
+
+		mapping, mapped := mappings[toolheadID]
+		if !mapped || mapping.SpoolID == 0 {
+			if snapshot.AutoPauseUnmapped {
+				pauseProblems = append(pauseProblems, fmt.Sprintf("toolhead %d has no spool mapped", toolheadID))
+			}
+			continue
+		}
+
+		spool, exists := spools[mapping.SpoolID]
+		weight := spool.RemainingWeight
+		if exists && checkMismatch {
+			if problems := filamentMismatches(toolheadID, sliced, spool); len(problems) > 0 {
+				if snapshot.FilamentMismatchCheck == FilamentMismatchCheckPause {
+					pauseProblems = append(pauseProblems, problems...)
+				} else {
+					mismatchProblems = append(mismatchProblems, problems...)
+				}
+			}
+		}
+		if exists && checkRules {
+			if problems := spoolRuleProblems(toolheadID, rules[toolheadID], spool); len(problems) > 0 {
+				if snapshot.ToolheadRuleCheck == ToolheadRuleCheckReject {
+					pauseProblems = append(pauseProblems, problems...)
+				} else {
+					ruleProblems = append(ruleProblems, problems...)
+				}
+			}
+		}
+
+		switch {
+		case !exists && snapshot.AutoPauseEmptySpool:
+			pauseProblems = append(pauseProblems, fmt.Sprintf("toolhead %d is mapped to spool %d which is no longer in Spoolman", toolheadID, mapping.SpoolID))
+		case !exists:
+			continue
+		case snapshot.AutoPauseEmptySpool && weight <= snapshot.AutoPauseMinWeight:
+			pauseProblems = append(pauseProblems, fmt.Sprintf("toolhead %d spool %d has only %.1fg remaining", toolheadID, mapping.SpoolID, weight))
+		case checkLowFilament && required[toolheadID] > weight-reserved[mapping.SpoolID]:
+			problem := fmt.Sprintf("toolhead %d needs %.1fg but spool %d has only %.1fg remaining", toolheadID, required[toolheadID], mapping.SpoolID, weight)
+			if reserved[mapping.SpoolID] > 0 {
+				problem += fmt.Sprintf(", %.1fg of it reserved for queued jobs", reserved[mapping.SpoolID])
+			}
+			if snapshot.LowFilamentCheck == LowFilamentCheckPause {
+				pauseProblems = append(pauseProblems, problem)
+			} else {
+				warnProblems = append(warnProblems, problem)
+			}
+		}
+	}
+
+	// Mismatches and broken rules are reported together, as one error for the job
+	var warnings []string
+	if len(mismatchProblems) > 0 {
+		reason := strings.Join(mismatchProblems, "; ")
+		monitorLog.Warn("Filament mismatch for print", "printer", printerName, "job", jobName, "reason", reason)
+		warnings = append(warnings, fmt.Sprintf("print may be using the wrong filament: %s", reason))
+	}
+	if len(ruleProblems) > 0 {
+		reason := strings.Join(ruleProblems, "; ")
+		monitorLog.Warn("Toolhead rules broken by print", "printer", printerName, "job", jobName, "reason", reason)
+		warnings = append(warnings, fmt.Sprintf("print breaks toolhead rules: %s", reason))
+	}
+	if len(warnings) > 0 {
+		b.addPrintError(printerName, filename, jobName, strings.Join(warnings, ". "))
+	}
+
+	if len(pauseProblems) == 0 {
+		if len(warnProblems) > 0 {
+			reason := strings.Join(warnProblems, "; ")
+			monitorLog.Warn("Low filament for print", "printer", printerName, "job", jobName, "reason", reason)
+			b.addPrintError(printerName, filename, jobName, fmt.Sprintf("print may run out of filament: %s", reason))
+		}
+		return
+	}
+
+	reason := strings.Join(append(pauseProblems, warnProblems...), "; ")
+
+	// Pausing is only possible through the printer's local PrusaLink API
+	if config.IPAddress == "" {
+		b.addPrintError(printerName, filename, jobName, fmt.Sprintf("print started with %s, but the printer has no local address to pause it", reason))
+		return
+	}
+
+	client := config.prusaLinkClient(snapshot)
+	if err := client.PauseJob(jobID); err != nil {
+		b.addPrintError(printerName, filename, jobName, fmt.Sprintf("print started with %s, and pausing it failed: %v", reason, err))
+		return
+	}
+
+	monitorLog.Warn("Paused print", "printer", printerName, "job", jobName, "reason", reason)
+	b.addPrintError(printerName, filename, jobName, fmt.Sprintf("print paused automatically: %s", reason))
+}
+
+// downloadJobGcode fetches the job's G-code for the print start check, or nil when the
+// printer has no local address or the download fails
+func (b *FilamentBridge) downloadJobGcode(config PrinterConfig, snapshot *Config, filename string) []byte {
+	if config.IPAddress == "" || filename == "" {
+		return nil
+	}
+
+	client := config.prusaLinkClient(snapshot)
+	gcodeContent, err := client.GetGcodeFile(filename)
+	if err != nil {
+		monitorLog.Warn("Failed to download G-code for print start check, checking all toolheads", "file", filename, "error", err)
+		return nil
+	}
+	return gcodeContent
+}
+
+// jobFilamentUsage returns the grams per toolhead the job's G-code requires, or nil when
+// there is no G-code or it can't be parsed
+func (b *FilamentBridge) jobFilamentUsage(config PrinterConfig, filename string, gcodeContent []byte) map[int]float64 {
+	if gcodeContent == nil {
+		return nil
+	}
+
+	usage, err := b.gcodeFilamentUsage(resolvePrinterName(config), config, gcodeContent)
+	if err != nil {
+		monitorLog.Warn("Failed to parse G-code for print start check, checking all toolheads", "file", filename, "error", err)
+		return nil
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+	return usage
+}
+
+// jobToolheads returns the toolheads a job will print with. On multi-toolhead printers the
+// parsed usage decides which toolheads are checked; without it, all are checked.
+func jobToolheads(config PrinterConfig, usage map[int]float64) []int {
+	toolheadCount := config.SlotCount()
+	if toolheadCount < 1 {
+		toolheadCount = 1
+	}
+
+	all := make([]int, toolheadCount)
+	for i := range all {
+		all[i] = i
+	}
+	if toolheadCount == 1 || len(usage) == 0 {
+		return all
+	}
+
+	used := make([]int, 0, len(usage))
+	for toolheadID := range usage {
+		used = append(used, toolheadID)
+	}
+	sort.Ints(used)
+	return used
+}
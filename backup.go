@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Tables a file has to have to be restored as a FilaBridge database
+var requiredBackupTables = []string{"configuration", "printer_configs", "toolhead_mappings"}
+
+// backupFileName names a backup by when it was taken, so backups sort oldest first
+func backupFileName(prefix string, at time.Time) string {
+	return prefix + at.Format("20060102-150405") + ".db"
+}
+
+// BackupDatabase writes a consistent copy of the database to path, which must not exist yet.
+// VACUUM INTO reads in a single transaction, so writes made meanwhile don't tear the copy.
+func (b *FilamentBridge) BackupDatabase(path string) error {
+	if _, err := b.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// RestoreDatabase replaces the database with a backup read from r, including its settings,
+// API tokens and login credentials. The current database is first backed up next to it, and
+// its path returned, so a wrong restore can be undone.
+func (b *FilamentBridge) RestoreDatabase(r io.Reader) (string, error) {
+	dir := filepath.Dir(b.databasePath())
+	upload, err := os.CreateTemp(dir, "restore-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create restore file: %w", err)
+	}
+	uploadPath := upload.Name()
+	defer os.Remove(uploadPath)
+
+	written, err := io.Copy(upload, io.LimitReader(r, MaxRestoreUploadSize+1))
+	upload.Close()
+	if err != nil {
+		return "", newCodedError(ErrCodeInvalidRequest, "failed to read backup: %v", err)
+	}
+	if written > MaxRestoreUploadSize {
+		return "", newCodedError(ErrCodeInvalidRequest, "backup is larger than %d MB", MaxRestoreUploadSize/(1024*1024))
+	}
+
+	source, err := sql.Open("sqlite3", uploadPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer source.Close()
+	if err := validateBackup(source); err != nil {
+		return "", err
+	}
+
+	preRestorePath := filepath.Join(dir, backupFileName(BackupFilePrefix+"pre-restore-", time.Now()))
+	if err := b.BackupDatabase(preRestorePath); err != nil {
+		return "", err
+	}
+
+	b.mutex.Lock()
+	err = copyDatabase(b.db, source)
+	b.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	// The backup may predate tables and columns this version uses
+	if err := b.initSchema(); err != nil {
+		return "", fmt.Errorf("failed to update restored database: %w", err)
+	}
+	if err := b.ReloadConfig(); err != nil {
+		return "", err
+	}
+
+	bridgeLog.Info("Restored database from backup", "bytes", written, "previous_database", preRestorePath)
+	return preRestorePath, nil
+}
+
+// validateBackup checks that a file is an intact SQLite database with FilaBridge's tables
+func validateBackup(db *sql.DB) error {
+	var integrity string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return newCodedError(ErrCodeInvalidRequest, "backup is not a SQLite database: %v", err)
+	}
+	if integrity != "ok" {
+		return newCodedError(ErrCodeInvalidRequest, "backup is damaged: %s", integrity)
+	}
+	for _, table := range requiredBackupTables {
+		var name string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if err == sql.ErrNoRows {
+			return newCodedError(ErrCodeInvalidRequest, "backup is not a FilaBridge database: it has no %s table", table)
+		}
+		if err != nil {
+			return newCodedError(ErrCodeInvalidRequest, "failed to read backup: %v", err)
+		}
+	}
+	return nil
+}
+
+// copyDatabase overwrites dest with source using SQLite's online backup, which swaps the
+// content in place so open connections to dest keep working
+func copyDatabase(dest, source *sql.DB) error {
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer destConn.Close()
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get backup connection: %w", err)
+	}
+	defer sourceConn.Close()
+
+	return destConn.Raw(func(destDriver interface{}) error {
+		return sourceConn.Raw(func(sourceDriver interface{}) error {
+			destSQLite, ok := destDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("database connection is not SQLite")
+			}
+			sourceSQLite, ok := sourceDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("backup connection is not SQLite")
+			}
+			backup, err := destSQLite.Backup("main", sourceSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start restore: %w", err)
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to restore database: %w", err)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish restore: %w", err)
+			}
+			return nil
+		})
+	})
+}
+
+// runScheduledBackup writes a backup to the backup directory and removes the oldest beyond
+// the retention count
+func (b *FilamentBridge) runScheduledBackup() error {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil || snapshot.BackupDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(snapshot.BackupDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(snapshot.BackupDir, backupFileName(BackupFilePrefix, time.Now()))
+	if err := b.BackupDatabase(path); err != nil {
+		return err
+	}
+	bridgeLog.Info("Backed up database", "path", path)
+
+	entries, err := os.ReadDir(snapshot.BackupDir)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, BackupFilePrefix) && strings.HasSuffix(name, ".db") &&
+			!strings.HasPrefix(name, BackupFilePrefix+"pre-restore-") {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > snapshot.BackupRetention {
+		if err := os.Remove(filepath.Join(snapshot.BackupDir, backups[0])); err != nil {
+			bridgeLog.Warn("Failed to remove old backup", "file", backups[0], "error", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backupHandler streams a snapshot of the database
+func (ws *WebServer) backupHandler(c *gin.Context) {
+	dir, err := os.MkdirTemp("", "filabridge-backup-")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to create backup directory: "+err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	name := backupFileName(BackupFilePrefix, time.Now())
+	path := filepath.Join(dir, name)
+	if err := ws.bridge.BackupDatabase(path); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// restoreHandler replaces the database with an uploaded backup, sent either as the "file"
+// field of a form or as the request body
+func (ws *WebServer) restoreHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxRestoreUploadSize+1024*1024)
+
+	var backup io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Missing backup file")
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Failed to read backup file")
+			return
+		}
+		defer file.Close()
+		backup = file
+	}
+
+	preRestorePath, err := ws.bridge.RestoreDatabase(backup)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, RestoreResponse{Message: "Database restored successfully", PreviousDatabase: preRestorePath})
+}
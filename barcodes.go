@@ -0,0 +1,522 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// barcodeLookupTimeout bounds a request to the online filament database
+const barcodeLookupTimeout = 10 * time.Second
+
+// Where a barcode lookup found the filament
+const (
+	BarcodeSourceLocal  = "local"  // FilaBridge's own barcode mappings
+	BarcodeSourceOnline = "online" // The database at barcode_lookup_url
+)
+
+// FilamentBarcode maps a manufacturer barcode (EAN/UPC) to the filament on spools that carry
+// it: a Spoolman filament, or the details to create one from
+type FilamentBarcode struct {
+	Barcode     string    `json:"barcode"`
+	FilamentID  int       `json:"filament_id,omitempty"` // Spoolman filament new spools with this barcode are created from
+	Vendor      string    `json:"vendor,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Material    string    `json:"material,omitempty"`
+	ColorHex    string    `json:"color_hex,omitempty"`
+	Weight      float64   `json:"weight,omitempty"` // Net grams of a full spool
+	SpoolWeight float64   `json:"spool_weight,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BarcodeLookup is what's known about a scanned barcode, to prefill a new spool
+type BarcodeLookup struct {
+	FilamentBarcode
+	Found  bool   `json:"found"`
+	Source string `json:"source,omitempty"` // local or online
+}
+
+// normalizeBarcode trims a scanned code and writes UPC-A codes as the EAN-13 they're part of,
+// so the same spool scans the same with either kind of scanner
+func normalizeBarcode(code string) string {
+	code = strings.TrimSpace(code)
+	if len(code) == 12 {
+		return "0" + code
+	}
+	if len(code) == 14 && code[0] == '0' {
+		return code[1:]
+	}
+	return code
+}
+
+// isProductBarcode reports whether a code is an EAN-8, UPC-A, EAN-13 or GTIN-14 with a valid
+// check digit, rather than a spool ID or another kind of code
+func isProductBarcode(code string) bool {
+	code = strings.TrimSpace(code)
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		digit := code[i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+		// Digits are weighted 3 and 1 alternately, starting with 3 next to the check digit
+		weight := 1
+		if (len(code)-2-i)%2 == 0 {
+			weight = 3
+		}
+		sum += int(digit-'0') * weight
+	}
+	check := code[len(code)-1]
+	return check >= '0' && check <= '9' && int(check-'0') == (10-sum%10)%10
+}
+
+// scanFilamentBarcode reads a filament_barcodes row
+func scanFilamentBarcode(row interface{ Scan(...interface{}) error }) (FilamentBarcode, error) {
+	var barcode FilamentBarcode
+	err := row.Scan(&barcode.Barcode, &barcode.FilamentID, &barcode.Vendor, &barcode.Name, &barcode.Material,
+		&barcode.ColorHex, &barcode.Weight, &barcode.SpoolWeight, &barcode.UpdatedAt)
+	return barcode, err
+}
+
+const filamentBarcodeColumns = "barcode, COALESCE(filament_id, 0), COALESCE(vendor, ''), COALESCE(name, ''), COALESCE(material, ''), COALESCE(color_hex, ''), COALESCE(weight, 0), COALESCE(spool_weight, 0), updated_at"
+
+// GetFilamentBarcodes returns the barcode mappings, sorted by barcode
+func (b *FilamentBridge) GetFilamentBarcodes() ([]FilamentBarcode, error) {
+	rows, err := b.db.Query("SELECT " + filamentBarcodeColumns + " FROM filament_barcodes ORDER BY barcode")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get barcodes: %w", err)
+	}
+	defer rows.Close()
+
+	barcodes := []FilamentBarcode{}
+	for rows.Next() {
+		barcode, err := scanFilamentBarcode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan barcode: %w", err)
+		}
+		barcodes = append(barcodes, barcode)
+	}
+	return barcodes, rows.Err()
+}
+
+// getFilamentBarcode returns the mapping of a barcode, or nil if it has none
+func (b *FilamentBridge) getFilamentBarcode(code string) (*FilamentBarcode, error) {
+	barcode, err := scanFilamentBarcode(b.db.QueryRow("SELECT "+filamentBarcodeColumns+" FROM filament_barcodes WHERE barcode = ?", code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get barcode %s: %w", code, err)
+	}
+	return &barcode, nil
+}
+
+// SetFilamentBarcode saves the mapping of a barcode, replacing any it had
+func (b *FilamentBridge) SetFilamentBarcode(barcode FilamentBarcode) (*FilamentBarcode, error) {
+	if !isProductBarcode(barcode.Barcode) {
+		return nil, newCodedError(ErrCodeInvalidRequest, "%q isn't an EAN or UPC barcode", barcode.Barcode)
+	}
+	barcode.Barcode = normalizeBarcode(barcode.Barcode)
+	barcode.Vendor = strings.TrimSpace(barcode.Vendor)
+	barcode.Name = strings.TrimSpace(barcode.Name)
+	barcode.Material = strings.TrimSpace(barcode.Material)
+	barcode.ColorHex = strings.TrimSpace(barcode.ColorHex)
+	if barcode.ColorHex != "" {
+		if _, _, _, ok := parseColorHex(barcode.ColorHex); !ok {
+			return nil, newCodedError(ErrCodeInvalidRequest, "color_hex %q isn't an RRGGBB color", barcode.ColorHex)
+		}
+		barcode.ColorHex = normalizeTagColor(barcode.ColorHex)
+	}
+	if barcode.FilamentID == 0 && barcode.Material == "" {
+		return nil, newCodedError(ErrCodeInvalidRequest, "give the barcode's filament_id or at least its material")
+	}
+	if barcode.Weight < 0 || barcode.SpoolWeight < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "weight and spool_weight can't be negative")
+	}
+	if barcode.FilamentID != 0 {
+		if filament, err := b.findFilament(barcode.FilamentID); err != nil {
+			return nil, err
+		} else if filament == nil {
+			return nil, newCodedError(ErrCodeNotFound, "filament %d not found", barcode.FilamentID)
+		}
+	}
+	barcode.UpdatedAt = time.Now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(`INSERT OR REPLACE INTO filament_barcodes (barcode, filament_id, vendor, name, material, color_hex, weight, spool_weight, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		barcode.Barcode, barcode.FilamentID, barcode.Vendor, barcode.Name, barcode.Material,
+		barcode.ColorHex, barcode.Weight, barcode.SpoolWeight, barcode.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save barcode: %w", err)
+	}
+	return &barcode, nil
+}
+
+// DeleteFilamentBarcode removes the mapping of a barcode
+func (b *FilamentBridge) DeleteFilamentBarcode(code string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec("DELETE FROM filament_barcodes WHERE barcode = ?", normalizeBarcode(code))
+	if err != nil {
+		return fmt.Errorf("failed to delete barcode: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodeNotFound, "barcode %s not found", code)
+	}
+	return nil
+}
+
+// rememberBarcode maps a barcode to the Spoolman filament a spool carrying it was created
+// from, keeping the details it already had
+func (b *FilamentBridge) rememberBarcode(code string, filamentID int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(`INSERT INTO filament_barcodes (barcode, filament_id, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(barcode) DO UPDATE SET filament_id = excluded.filament_id, updated_at = excluded.updated_at`,
+		normalizeBarcode(code), filamentID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to remember barcode: %w", err)
+	}
+	return nil
+}
+
+// findFilament returns a filament of the main Spoolman instance, or nil if it has none
+// with that ID
+func (b *FilamentBridge) findFilament(filamentID int) (*SpoolmanFilament, error) {
+	filaments, err := b.spoolman.GetAllFilaments()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get filaments: %v", err)
+	}
+	for i := range filaments {
+		if filaments[i].ID == filamentID {
+			return &filaments[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// LookupBarcode finds the filament of a scanned barcode: first in FilaBridge's own mappings,
+// then in the online database when one is configured. Details found online are matched to a
+// Spoolman filament with the same vendor, material and color, if there is one. Filaments are
+// those of the printer's Spoolman instance, the main one when printerName is empty; barcode
+// mappings name main instance filaments, so other instances match on the details alone.
+func (b *FilamentBridge) LookupBarcode(printerName, code string) (*BarcodeLookup, error) {
+	if !isProductBarcode(code) {
+		return nil, newCodedError(ErrCodeInvalidRequest, "%q isn't an EAN or UPC barcode", code)
+	}
+	code = normalizeBarcode(code)
+	lookup := &BarcodeLookup{FilamentBarcode: FilamentBarcode{Barcode: code}}
+
+	local, err := b.getFilamentBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+	if local != nil {
+		lookup.FilamentBarcode = *local
+		lookup.Found = true
+		lookup.Source = BarcodeSourceLocal
+	} else if snapshot := b.GetConfigSnapshot(); snapshot != nil && snapshot.BarcodeLookupURL != "" {
+		online, err := lookupBarcodeOnline(snapshot.BarcodeLookupURL, code)
+		if err != nil {
+			// Without the database the spool can still be added by hand
+			bridgeLog.Warn("Online barcode lookup failed", "barcode", code, "error", err)
+		} else if online != nil {
+			lookup.FilamentBarcode = *online
+			lookup.Found = true
+			lookup.Source = BarcodeSourceOnline
+		}
+	}
+	if !lookup.Found {
+		return lookup, nil
+	}
+
+	instance := b.spoolmanInstanceOf(printerName)
+	filaments, err := b.spoolmanInstance(instance).GetAllFilaments()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get filaments: %v", err)
+	}
+	if instance != "" {
+		lookup.FilamentID = 0
+	}
+	if lookup.FilamentID != 0 {
+		// A filament since deleted or archived in Spoolman is matched again like one found online
+		known := false
+		for _, filament := range filaments {
+			if filament.ID == lookup.FilamentID {
+				fillBarcodeFromFilament(&lookup.FilamentBarcode, filament)
+				known = true
+				break
+			}
+		}
+		if !known {
+			lookup.FilamentID = 0
+		}
+	}
+	if lookup.FilamentID == 0 {
+		if filament := matchBarcodeFilament(lookup.FilamentBarcode, filaments); filament != nil {
+			lookup.FilamentID = filament.ID
+			fillBarcodeFromFilament(&lookup.FilamentBarcode, *filament)
+		}
+	}
+	return lookup, nil
+}
+
+// fillBarcodeFromFilament fills in the details of a barcode that a Spoolman filament has
+func fillBarcodeFromFilament(barcode *FilamentBarcode, filament SpoolmanFilament) {
+	if barcode.Vendor == "" && filament.Vendor != nil {
+		barcode.Vendor = filament.Vendor.Name
+	}
+	barcode.Name = firstNonEmpty(barcode.Name, filament.Name)
+	barcode.Material = firstNonEmpty(barcode.Material, filament.Material)
+	barcode.ColorHex = firstNonEmpty(barcode.ColorHex, normalizeTagColor(filament.ColorHex))
+	if barcode.Weight == 0 {
+		barcode.Weight = filament.Weight
+	}
+	if barcode.SpoolWeight == 0 {
+		barcode.SpoolWeight = filament.SpoolWeight
+	}
+}
+
+// matchBarcodeFilament returns the filament with a barcode's vendor, material and color, and
+// its name when the barcode has one, or nil when none or several match
+func matchBarcodeFilament(barcode FilamentBarcode, filaments []SpoolmanFilament) *SpoolmanFilament {
+	if barcode.Vendor == "" || barcode.Material == "" || barcode.ColorHex == "" {
+		return nil
+	}
+	var match *SpoolmanFilament
+	for i := range filaments {
+		filament := &filaments[i]
+		if filament.Vendor == nil || !strings.EqualFold(filament.Vendor.Name, barcode.Vendor) ||
+			normalizeMaterial(filament.Material) != normalizeMaterial(barcode.Material) ||
+			normalizeTagColor(filament.ColorHex) != barcode.ColorHex ||
+			(barcode.Name != "" && !strings.EqualFold(filament.Name, barcode.Name)) {
+			continue
+		}
+		if match != nil {
+			return nil
+		}
+		match = filament
+	}
+	return match
+}
+
+// lookupBarcodeOnline asks the online filament database about a barcode. The URL has
+// {barcode} where the code goes and answers with a JSON object; common names for the vendor,
+// material, color and weight fields are understood. A 404 means the database doesn't know it.
+func lookupBarcodeOnline(urlTemplate, code string) (*FilamentBarcode, error) {
+	client := &http.Client{Timeout: barcodeLookupTimeout}
+	resp, err := client.Get(strings.ReplaceAll(urlTemplate, "{barcode}", neturl.QueryEscape(code)))
+	if err != nil {
+		return nil, fmt.Errorf("error looking up barcode: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("barcode lookup returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("error decoding barcode lookup: %w", err)
+	}
+	barcode := &FilamentBarcode{
+		Barcode:     code,
+		Vendor:      lookupString(fields, "vendor", "brand", "manufacturer"),
+		Name:        lookupString(fields, "name", "product_name", "title"),
+		Material:    lookupString(fields, "material", "type", "material_type"),
+		ColorHex:    normalizeTagColor(lookupString(fields, "color_hex", "color")),
+		Weight:      lookupNumber(fields, "weight", "net_weight"),
+		SpoolWeight: lookupNumber(fields, "spool_weight", "empty_spool_weight"),
+	}
+	if _, _, _, ok := parseColorHex(barcode.ColorHex); !ok {
+		barcode.ColorHex = ""
+	}
+	if barcode.Vendor == "" && barcode.Name == "" && barcode.Material == "" {
+		return nil, nil
+	}
+	return barcode, nil
+}
+
+// lookupString returns the first of several fields that is a string, or an object with a name
+func lookupString(fields map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := fields[key].(type) {
+		case string:
+			if value = strings.TrimSpace(value); value != "" {
+				return value
+			}
+		case map[string]interface{}:
+			if name, ok := value["name"].(string); ok && strings.TrimSpace(name) != "" {
+				return strings.TrimSpace(name)
+			}
+		}
+	}
+	return ""
+}
+
+// lookupNumber returns the first of several fields that is a positive number or numeric string
+func lookupNumber(fields map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		switch value := fields[key].(type) {
+		case float64:
+			if value > 0 {
+				return value
+			}
+		case string:
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && parsed > 0 {
+				return parsed
+			}
+		}
+	}
+	return 0
+}
+
+// getBarcodesHandler lists the barcode mappings
+func (ws *WebServer) getBarcodesHandler(c *gin.Context) {
+	barcodes, err := ws.bridge.GetFilamentBarcodes()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, FilamentBarcodesResponse{Barcodes: barcodes})
+}
+
+// lookupBarcodeHandler returns what's known about a barcode
+func (ws *WebServer) lookupBarcodeHandler(c *gin.Context) {
+	lookup, err := ws.bridge.LookupBarcode(c.Query("printer_name"), c.Param("code"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, lookup)
+}
+
+// setBarcodeHandler saves the mapping of a barcode
+func (ws *WebServer) setBarcodeHandler(c *gin.Context) {
+	var req FilamentBarcode
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	req.Barcode = c.Param("code")
+
+	barcode, err := ws.bridge.SetFilamentBarcode(req)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, FilamentBarcodeResponse{Message: "Barcode saved successfully", Barcode: barcode})
+}
+
+// deleteBarcodeHandler removes the mapping of a barcode
+func (ws *WebServer) deleteBarcodeHandler(c *gin.Context) {
+	if err := ws.bridge.DeleteFilamentBarcode(c.Param("code")); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Barcode deleted successfully"})
+}
+
+// nfcBarcodePage shows the form for adding a spool from a scanned manufacturer barcode,
+// prefilled from the lookup. The spool goes into the Spoolman instance of the printer the
+// scan session is at, if any, so it can be loaded there.
+func (ws *WebServer) nfcBarcodePage(c *gin.Context, code, printerName string) {
+	lookup, err := ws.bridge.LookupBarcode(printerName, code)
+	if err != nil {
+		c.HTML(httpStatusForCode(errorCode(err, ErrCodeInternal), http.StatusBadGateway), "nfc_error.html", gin.H{
+			"Error": err.Error(),
+		})
+		return
+	}
+	filaments, err := ws.bridge.spoolmanFor(printerName).GetAllFilaments()
+	if err != nil {
+		c.HTML(http.StatusBadGateway, "nfc_error.html", gin.H{
+			"Error": "Failed to get filaments: " + err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "nfc_barcode.html", gin.H{
+		"Lookup":      lookup,
+		"Filaments":   filaments,
+		"PrinterName": printerName,
+	})
+}
+
+// nfcBarcodeSpoolHandler adds the spool from the barcode form, creating its filament first if
+// it's new, then continues the scan session with it as if its tag had been scanned
+func (ws *WebServer) nfcBarcodeSpoolHandler(c *gin.Context) {
+	// Scan tokens only assign spools; adding them to Spoolman takes an admin
+	if c.GetString(contextKeyRole) != RoleAdmin {
+		c.HTML(http.StatusForbidden, "nfc_error.html", gin.H{
+			"Error": "Adding spools needs an admin login. Log in to FilaBridge on this device and scan the barcode again.",
+		})
+		return
+	}
+
+	barcode := c.PostForm("barcode")
+	printerName := c.PostForm("printer_name")
+	formNumber := func(key string) float64 {
+		value, _ := strconv.ParseFloat(strings.TrimSpace(c.PostForm(key)), 64)
+		return value
+	}
+
+	var filamentID int
+	if c.PostForm("filament_id") == "new" {
+		filament, err := ws.bridge.CreateFilament(NewFilament{
+			VendorName:  c.PostForm("vendor_name"),
+			Name:        c.PostForm("name"),
+			Material:    c.PostForm("material"),
+			ColorHex:    c.PostForm("color_hex"),
+			Weight:      formNumber("weight"),
+			SpoolWeight: formNumber("spool_weight"),
+			PrinterName: printerName,
+		})
+		if err != nil {
+			c.HTML(httpStatusForCode(errorCode(err, ErrCodeInternal), http.StatusInternalServerError), "nfc_error.html", gin.H{
+				"Error": "Failed to add filament: " + err.Error(),
+			})
+			return
+		}
+		filamentID = filament.ID
+	} else {
+		filamentID, _ = strconv.Atoi(c.PostForm("filament_id"))
+	}
+
+	spool := NewSpool{FilamentID: filamentID, LotNr: c.PostForm("lot_nr"), Barcode: barcode, PrinterName: printerName}
+	if weight := formNumber("initial_weight"); weight > 0 {
+		spool.InitialWeight = &weight
+	}
+	result, err := ws.bridge.CreateSpool(spool)
+	if err != nil {
+		c.HTML(httpStatusForCode(errorCode(err, ErrCodeInternal), http.StatusInternalServerError), "nfc_error.html", gin.H{
+			"Error": "Failed to add spool: " + err.Error(),
+		})
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("%s?spool=%d", ws.path("/api/v1/nfc/assign"), result.Spool.ID))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ToolheadAssignment is one toolhead's spool in a batch mapping; spool 0 unmaps it
+type ToolheadAssignment struct {
+	ToolheadID int `json:"toolhead_id"`
+	SpoolID    int `json:"spool_id"`
+}
+
+// SetToolheadMappings sets several toolheads of a printer in one transaction, e.g. after
+// loading a full MMU or XL changeout. Every assignment is checked before anything is written,
+// so either all of them apply or none do. Spools can move between the listed toolheads, but a
+// spool mapped anywhere else is a conflict.
+func (b *FilamentBridge) SetToolheadMappings(printer string, assignments []ToolheadAssignment, member string) error {
+	_, config, exists := b.findPrinterConfig(printer)
+	if !exists {
+		return newCodedError(ErrCodePrinterNotFound, "printer %s not found", printer)
+	}
+	printerName := resolvePrinterName(config)
+	if len(assignments) == 0 {
+		return newCodedError(ErrCodeInvalidRequest, "no toolhead mappings given")
+	}
+
+	var problems []string
+	toolheads := make(map[int]bool)
+	spoolToolheads := make(map[int]int)
+	for _, assignment := range assignments {
+		if assignment.ToolheadID < 0 || assignment.ToolheadID >= config.SlotCount() {
+			problems = append(problems, fmt.Sprintf("%s has no toolhead %d", printerName, assignment.ToolheadID))
+		} else if toolheads[assignment.ToolheadID] {
+			problems = append(problems, fmt.Sprintf("toolhead %d is listed more than once", assignment.ToolheadID))
+		}
+		toolheads[assignment.ToolheadID] = true
+
+		if assignment.SpoolID < 0 {
+			problems = append(problems, fmt.Sprintf("spool ID for toolhead %d can't be negative", assignment.ToolheadID))
+		} else if other, listed := spoolToolheads[assignment.SpoolID]; listed && assignment.SpoolID != 0 {
+			problems = append(problems, fmt.Sprintf("spool %d is listed for both toolhead %d and toolhead %d", assignment.SpoolID, other, assignment.ToolheadID))
+		} else {
+			spoolToolheads[assignment.SpoolID] = assignment.ToolheadID
+		}
+	}
+	if len(problems) > 0 {
+		return newCodedError(ErrCodeInvalidRequest, "%s", strings.Join(problems, "; "))
+	}
+
+	b.mutex.Lock()
+	previous, err := b.replaceToolheadMappings(printerName, assignments, toolheads, member)
+	b.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	bridgeLog.Info("Mapped toolheads in batch", "printer", printerName, "toolheads", len(assignments))
+
+	assigned := make(map[int]int, len(assignments))
+	for _, assignment := range assignments {
+		assigned[assignment.ToolheadID] = assignment.SpoolID
+		if assignment.SpoolID != 0 {
+			b.emitSpoolAssigned(printerName, assignment.ToolheadID, assignment.SpoolID, previous[assignment.ToolheadID])
+		}
+	}
+	b.logSpoolEvents(b.mappingEvents(printerName, assigned, previous, member)...)
+
+	// Spools that came off the printer altogether go to the default location, same as one at a time
+	for _, spoolID := range previous {
+		if _, remapped := spoolToolheads[spoolID]; !remapped {
+			b.parkPreviousSpool(printerName, spoolID)
+		}
+	}
+	return nil
+}
+
+// replaceToolheadMappings checks a batch for spools mapped elsewhere and writes it, returning
+// the spools previously on the batch's toolheads by toolhead. Callers hold b.mutex.
+func (b *FilamentBridge) replaceToolheadMappings(printerName string, assignments []ToolheadAssignment, toolheads map[int]bool, member string) (map[int]int, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin toolhead mapping: %w", err)
+	}
+	defer tx.Rollback()
+
+	instance := printerSpoolmanInstance(b.config, printerName)
+	shared, err := sharedSpoolIDs(tx, instance)
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[int]int)
+	var conflictIDs []int
+	conflicts := make(map[int]string)
+	rows, err := tx.Query("SELECT printer_name, toolhead_id, spool_id FROM toolhead_mappings WHERE spool_id != 0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing spool assignments: %w", err)
+	}
+	wanted := make(map[int]bool)
+	for _, assignment := range assignments {
+		wanted[assignment.SpoolID] = assignment.SpoolID != 0
+	}
+	for rows.Next() {
+		var mappedPrinter string
+		var toolheadID, spoolID int
+		if err := rows.Scan(&mappedPrinter, &toolheadID, &spoolID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan existing assignment: %w", err)
+		}
+		if mappedPrinter == printerName && toolheads[toolheadID] {
+			previous[toolheadID] = spoolID
+		} else if wanted[spoolID] && !shared[spoolID] && printerSpoolmanInstance(b.config, mappedPrinter) == instance {
+			conflicts[spoolID] = fmt.Sprintf("spool %d is already assigned to %s toolhead %d; share it to map it to both", spoolID, mappedPrinter, toolheadID)
+			conflictIDs = append(conflictIDs, spoolID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check existing spool assignments: %w", err)
+	}
+	if len(conflictIDs) > 0 {
+		sort.Ints(conflictIDs)
+		messages := make([]string, 0, len(conflictIDs))
+		for _, spoolID := range conflictIDs {
+			messages = append(messages, conflicts[spoolID])
+		}
+		return nil, newCodedError(ErrCodeSpoolAlreadyAssigned, "%s", strings.Join(messages, "; "))
+	}
+
+	now := time.Now()
+	for _, assignment := range assignments {
+		if _, err := tx.Exec(
+			"DELETE FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
+			printerName, assignment.ToolheadID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to unmap toolhead %d: %w", assignment.ToolheadID, err)
+		}
+		if assignment.SpoolID == 0 {
+			continue
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO toolhead_mappings (printer_name, toolhead_id, spool_id, mapped_at, mapped_by) VALUES (?, ?, ?, ?, ?)",
+			printerName, assignment.ToolheadID, assignment.SpoolID, now, member,
+		); err != nil {
+			return nil, fmt.Errorf("failed to set toolhead %d mapping: %w", assignment.ToolheadID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit toolhead mappings: %w", err)
+	}
+	return previous, nil
+}
+
+// mapToolheadsHandler sets several toolheads of a printer at once
+func (ws *WebServer) mapToolheadsHandler(c *gin.Context) {
+	var req struct {
+		PrinterName string               `json:"printer_name" binding:"required"`
+		Mappings    []ToolheadAssignment `json:"mappings" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	member := callerMember(c)
+	var memberName string
+	if member != nil {
+		memberName = member.Name
+	}
+	warnings, err := ws.bridge.checkToolheadRules(req.PrinterName, req.Mappings)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if err := ws.bridge.SetToolheadMappings(req.PrinterName, req.Mappings, memberName); err != nil {
+		// Spool conflicts carry ErrCodeSpoolAlreadyAssigned and map to 409
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	response := MappingResponse{Message: "Toolheads mapped successfully"}
+	for _, assignment := range req.Mappings {
+		if assignment.SpoolID == 0 {
+			continue
+		}
+		if warning := ws.bridge.ownerMismatchWarning(req.PrinterName, assignment.SpoolID, member); warning != "" {
+			webLog.Warn("Member mapped another member's spool", "member", member.Name, "printer", req.PrinterName, "toolhead_id", assignment.ToolheadID, "spool_id", assignment.SpoolID, "warning", warning)
+			warnings = append(warnings, warning)
+		}
+	}
+	if len(warnings) > 0 {
+		response.Warnings = warnings
+	}
+
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, response)
+}
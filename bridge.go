@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,15 +17,26 @@ import (
 
 // FilamentBridge manages the connection between PrusaLink and Spoolman
 type FilamentBridge struct {
-	config           *Config
-	spoolman         *SpoolmanClient
-	db               *sql.DB
-	wasPrinting      map[string]bool
-	currentJobFile   map[string]string     // Store current job filename per printer
-	processingPrints map[string]bool       // Track prints being processed
-	printErrors      map[string]PrintError // Store print processing errors
-	errorMutex       sync.RWMutex
-	mutex            sync.RWMutex
+	config            *Config
+	spoolman          *SpoolmanClient
+	spoolmanInstances map[string]*SpoolmanClient // Clients for additional Spoolman instances by name
+	db                *sql.DB
+	wasPrinting       map[string]bool
+	currentJobFile    map[string]string       // Store current job filename per printer
+	currentJobID      map[string]int          // PrusaLink job ID of the current job per printer
+	currentJobName    map[string]string       // Readable name of the current job per printer
+	jobTelemetry      map[string]jobTelemetry // Last job telemetry per printer, for usage estimates
+	processingPrints  map[string]bool         // Track prints being processed
+	offlineSince      map[string]time.Time    // When each unreachable printer was first seen offline
+	offlineNotified   map[string]bool         // Printers an offline notification was sent for
+	notifier          *Notifier
+	runout            *RunoutEstimator
+	scheduler         *Scheduler
+	homeAssistant     *HomeAssistantPublisher
+	clockDrift        map[string]time.Duration // Last measured printer clock drift per printer
+	pollTiming        map[string]printerPollTiming
+	printQueue        *PrintQueue // Processes finished prints on a bounded pool of workers
+	mutex             sync.RWMutex
 }
 
 // ToolheadMapping represents a mapping between a printer toolhead and a spool
@@ -34,28 +46,39 @@ type ToolheadMapping struct {
 	SpoolID     int       `json:"spool_id"`
 	MappedAt    time.Time `json:"mapped_at"`
 	DisplayName string    `json:"display_name,omitempty"` // Custom toolhead name or empty for default
+	Shared      bool      `json:"shared,omitempty"`       // The spool may be on other toolheads too
 }
 
 // PrintHistory represents a record of filament usage
 type PrintHistory struct {
-	ID            int       `json:"id"`
-	PrinterName   string    `json:"printer_name"`
-	ToolheadID    int       `json:"toolhead_id"`
-	SpoolID       int       `json:"spool_id"`
-	FilamentUsed  float64   `json:"filament_used"`
-	PrintStarted  time.Time `json:"print_started"`
-	PrintFinished time.Time `json:"print_finished"`
-	JobName       string    `json:"job_name"`
+	ID             int       `json:"id"`
+	PrinterName    string    `json:"printer_name"`
+	ToolheadID     int       `json:"toolhead_id"`
+	SpoolID        int       `json:"spool_id"`
+	FilamentUsed   float64   `json:"filament_used"`
+	PrintStarted   time.Time `json:"print_started"`
+	PrintFinished  time.Time `json:"print_finished"`
+	JobName        string    `json:"job_name"`         // Raw job file path as reported by the printer
+	JobDisplayName string    `json:"job_display_name"` // Decoded, human-readable job name
+	Notes          string    `json:"notes"`
+	Rating         int       `json:"rating"`           // Success rating 1-5, 0 when unrated
+	Member         string    `json:"member,omitempty"` // Member who mapped the spool used by this print
+	Reverted       bool      `json:"reverted"`         // Usage was taken back out of Spoolman
+	Source         string    `json:"source"`           // "print" for monitored prints, "estimated" when usage came from job telemetry, "manual" for logged usage
+	Waste          float64   `json:"waste"`            // Grams of filament_used that went to the wipe tower and purges
+	Cost           *float64  `json:"cost"`             // Cost of filament_used at the spool's Spoolman price; nil when the spool had none
 }
 
 // PrintError represents a failed print processing attempt
 type PrintError struct {
 	ID           string    `json:"id"`
 	PrinterName  string    `json:"printer_name"`
-	Filename     string    `json:"filename"`
+	Filename     string    `json:"filename"`     // Raw file path, used to retry or look up the job
+	DisplayName  string    `json:"display_name"` // Human-readable job name
 	Error        string    `json:"error"`
 	Timestamp    time.Time `json:"timestamp"`
 	Acknowledged bool      `json:"acknowledged"`
+	Retryable    bool      `json:"retryable"` // Whether POST /api/print-errors/:id/retry can process the job again
 }
 
 // PrinterStatus represents the current status of all printers
@@ -67,29 +90,56 @@ type PrinterStatus struct {
 
 // PrinterData represents data for a single printer
 type PrinterData struct {
-	Name  string `json:"name"`
-	State string `json:"state"`
+	Name              string  `json:"name"`
+	State             string  `json:"state"`
+	ClockDriftSeconds float64 `json:"clock_drift_seconds"` // Printer clock minus server time, 0 if unknown
+
+	// What the printer is doing, omitted when it has no job or doesn't report temperatures
+	Job          *PrinterJobProgress  `json:"job,omitempty"`
+	Temperatures *PrinterTemperatures `json:"temperatures,omitempty"`
+
+	// Monitor timing, so clients can show how old the data is. Times are omitted before the first poll.
+	PollIntervalSeconds  float64    `json:"poll_interval_seconds"` // Effective interval: the active one while printing
+	LastPollAt           *time.Time `json:"last_poll_at,omitempty"`
+	LastSuccessfulPollAt *time.Time `json:"last_successful_poll_at,omitempty"`
+	NextPollAt           *time.Time `json:"next_poll_at,omitempty"`
 }
 
 // NewFilamentBridge creates a new FilamentBridge instance
 func NewFilamentBridge(config *Config) (*FilamentBridge, error) {
 	bridge := &FilamentBridge{
 		config:           config,
-		spoolman:         NewSpoolmanClient(DefaultSpoolmanURL, SpoolmanTimeout, "", ""), // Default URL and timeout, will be updated
+		spoolman:         NewSpoolmanClient(DefaultSpoolmanURL, SpoolmanTimeout, "", "", false), // Default URL and timeout, will be updated
 		wasPrinting:      make(map[string]bool),
 		currentJobFile:   make(map[string]string),
+		currentJobID:     make(map[string]int),
+		currentJobName:   make(map[string]string),
+		jobTelemetry:     make(map[string]jobTelemetry),
 		processingPrints: make(map[string]bool),
-		printErrors:      make(map[string]PrintError),
-	}
+		offlineSince:     make(map[string]time.Time),
+		offlineNotified:  make(map[string]bool),
+		clockDrift:       make(map[string]time.Duration),
+		pollTiming:       make(map[string]printerPollTiming),
+		printQueue:       NewPrintQueue(),
+	}
+	bridge.notifier = NewNotifier(bridge)
+	bridge.runout = NewRunoutEstimator(bridge)
+	bridge.homeAssistant = NewHomeAssistantPublisher(bridge)
+	bridge.scheduler = NewScheduler(bridge)
+	bridge.registerScheduledJobs()
 
 	// Initialize database
 	if err := bridge.initDatabase(); err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
+	bridge.logConfigEnvOverrides()
 
 	// Update Spoolman URL and timeout if config is provided
 	if config != nil && config.SpoolmanURL != "" {
-		bridge.spoolman = NewSpoolmanClient(config.SpoolmanURL, config.SpoolmanTimeout, config.SpoolmanUsername, config.SpoolmanPassword)
+		bridge.spoolman = NewSpoolmanClient(config.SpoolmanURL, config.SpoolmanTimeout, config.SpoolmanUsername, config.SpoolmanPassword, config.SpoolmanInsecureSkipVerify)
+	}
+	if config != nil {
+		bridge.spoolmanInstances = newSpoolmanInstanceClients(config)
 	}
 
 	return bridge, nil
@@ -97,22 +147,55 @@ func NewFilamentBridge(config *Config) (*FilamentBridge, error) {
 
 // initDatabase initializes the SQLite database
 func (b *FilamentBridge) initDatabase() error {
+	db, err := sql.Open("sqlite3", b.databasePath())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	b.db = db
+	return b.initSchema()
+}
+
+// databasePath returns the SQLite database file in use
+func (b *FilamentBridge) databasePath() string {
 	dbFile := DefaultDBFileName
 	if b.config != nil && b.config.DBFile != "" {
 		dbFile = b.config.DBFile
 	}
 	// Check for environment variable (path only, append filename)
-	if envDBPath := os.Getenv("FILABRIDGE_DB_PATH"); envDBPath != "" {
+	if envDBPath := os.Getenv(DBPathEnv); envDBPath != "" {
 		dbFile = filepath.Join(envDBPath, DefaultDBFileName)
 	}
+	return dbFile
+}
 
-	db, err := sql.Open("sqlite3", dbFile)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
-	}
-
-	b.db = db
+// Tables keyed by spool ID that were first created without the Spoolman instance in their
+// key; migrateSchema rebuilds them from these statements
+const (
+	createSpoolAliasesTable = `CREATE TABLE IF NOT EXISTS spool_aliases (
+			spoolman_instance TEXT NOT NULL DEFAULT '',
+			spool_id INTEGER NOT NULL,
+			current_spool_id INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (spoolman_instance, spool_id)
+		)`
+	createSpoolExposureTable = `CREATE TABLE IF NOT EXISTS spool_exposure (
+			spoolman_instance TEXT NOT NULL DEFAULT '',
+			spool_id INTEGER NOT NULL,
+			location TEXT DEFAULT '',
+			dry BOOLEAN DEFAULT 0,
+			exposed_hours REAL NOT NULL DEFAULT 0,
+			dry_hours REAL NOT NULL DEFAULT 0,
+			dried_at TIMESTAMP,
+			alerted_at TIMESTAMP,
+			checked_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (spoolman_instance, spool_id)
+		)`
+)
 
+// initSchema creates missing tables and columns and fills in default configuration. It runs
+// at startup and again after a restore, which may bring back a database from an older version.
+func (b *FilamentBridge) initSchema() error {
 	// Create tables
 	createTables := []string{
 		`CREATE TABLE IF NOT EXISTS configuration (
@@ -158,12 +241,222 @@ func (b *FilamentBridge) initDatabase() error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			expires_at TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS history_corrections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			history_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			old_spool_id INTEGER,
+			new_spool_id INTEGER,
+			old_filament_used REAL,
+			new_filament_used REAL,
+			reason TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS material_defaults (
+			material TEXT PRIMARY KEY,
+			exposure_limit_hours REAL,
+			drying_temp REAL,
+			drying_time_hours REAL,
+			usage_correction_factor REAL,
+			low_stock_threshold REAL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS members (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
 		`CREATE TABLE IF NOT EXISTS toolhead_names (
 			printer_id TEXT,
 			toolhead_id INTEGER,
 			display_name TEXT NOT NULL,
 			PRIMARY KEY (printer_id, toolhead_id)
 		)`,
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			role TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS auth_sessions (
+			token_hash TEXT PRIMARY KEY,
+			username TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS spool_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			spool_id INTEGER NOT NULL,
+			event_type TEXT NOT NULL,
+			related_spool_id INTEGER DEFAULT 0,
+			weight REAL DEFAULT 0,
+			member TEXT DEFAULT '',
+			notes TEXT DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS printer_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			printer_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			member TEXT DEFAULT '',
+			notes TEXT DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		createSpoolAliasesTable,
+		`CREATE TABLE IF NOT EXISTS toolhead_rules (
+			printer_id TEXT NOT NULL,
+			toolhead_id INTEGER NOT NULL,
+			allowed_materials TEXT DEFAULT '',
+			nozzle_type TEXT DEFAULT '',
+			nozzle_diameter REAL DEFAULT 0,
+			PRIMARY KEY (printer_id, toolhead_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS filament_barcodes (
+			barcode TEXT PRIMARY KEY,
+			filament_id INTEGER DEFAULT 0,
+			vendor TEXT DEFAULT '',
+			name TEXT DEFAULT '',
+			material TEXT DEFAULT '',
+			color_hex TEXT DEFAULT '',
+			weight REAL DEFAULT 0,
+			spool_weight REAL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			printer_id TEXT NOT NULL,
+			days TEXT DEFAULT '',
+			start_time TEXT NOT NULL,
+			end_time TEXT NOT NULL,
+			note TEXT DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS printer_webhook_secrets (
+			printer_id TEXT PRIMARY KEY,
+			secret_hash TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS pending_updates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			printer_name TEXT NOT NULL,
+			toolhead_id INTEGER NOT NULL,
+			spool_id INTEGER NOT NULL,
+			filament_used REAL NOT NULL,
+			waste REAL DEFAULT 0,
+			job_name TEXT DEFAULT '',
+			job_display_name TEXT DEFAULT '',
+			source TEXT DEFAULT '',
+			member TEXT DEFAULT '',
+			attempts INTEGER DEFAULT 0,
+			last_error TEXT DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS print_errors (
+			id TEXT PRIMARY KEY,
+			printer_name TEXT NOT NULL,
+			filename TEXT DEFAULT '',
+			display_name TEXT DEFAULT '',
+			error TEXT NOT NULL,
+			retry TEXT DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			acknowledged INTEGER DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS filament_runouts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			printer_name TEXT NOT NULL,
+			toolhead_id INTEGER NOT NULL,
+			spool_id INTEGER NOT NULL,
+			job_name TEXT DEFAULT '',
+			reason TEXT DEFAULT '',
+			estimated_remaining REAL,
+			status TEXT NOT NULL,
+			detected_at TIMESTAMP NOT NULL,
+			resolved_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS unfinished_prints (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			printer_id TEXT NOT NULL,
+			filename TEXT NOT NULL,
+			display_name TEXT DEFAULT '',
+			job_id INTEGER DEFAULT 0,
+			telemetry TEXT DEFAULT '',
+			finished INTEGER DEFAULT 0,
+			saved_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS processed_prints (
+			job_key TEXT PRIMARY KEY,
+			printer_id TEXT NOT NULL,
+			job_id INTEGER DEFAULT 0,
+			filename TEXT NOT NULL,
+			finished_at TIMESTAMP NOT NULL,
+			processed_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS unattributed_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			printer_name TEXT NOT NULL,
+			toolhead_id INTEGER NOT NULL,
+			filament_used REAL NOT NULL,
+			filament_length REAL DEFAULT 0,
+			waste REAL DEFAULT 0,
+			job_name TEXT DEFAULT '',
+			job_display_name TEXT DEFAULT '',
+			source TEXT DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS scale_readings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			spool_id INTEGER NOT NULL,
+			printer_name TEXT DEFAULT '',
+			scale TEXT DEFAULT '',
+			measured_weight REAL NOT NULL,
+			expected_weight REAL NOT NULL,
+			difference REAL NOT NULL,
+			status TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS location_environments (
+			location TEXT PRIMARY KEY COLLATE NOCASE,
+			drybox BOOLEAN DEFAULT 0,
+			humidity REAL,
+			temperature REAL,
+			reading_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS environment_readings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			location TEXT NOT NULL,
+			humidity REAL,
+			temperature REAL,
+			recorded_at TIMESTAMP NOT NULL
+		)`,
+		createSpoolExposureTable,
+		`CREATE TABLE IF NOT EXISTS shared_spools (
+			spoolman_instance TEXT NOT NULL DEFAULT '',
+			spool_id INTEGER NOT NULL,
+			shared_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (spoolman_instance, spool_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS low_stock_alerts (
+			spoolman_instance TEXT NOT NULL DEFAULT '',
+			spool_id INTEGER NOT NULL,
+			notified_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (spoolman_instance, spool_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS low_stock_checks (
+			spoolman_instance TEXT PRIMARY KEY,
+			first_checked_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS spool_reservations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			spool_id INTEGER NOT NULL,
+			printer_name TEXT NOT NULL,
+			job_name TEXT NOT NULL,
+			grams REAL NOT NULL,
+			reserved_by TEXT DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
 	}
 
 	for _, query := range createTables {
@@ -172,6 +465,11 @@ func (b *FilamentBridge) initDatabase() error {
 		}
 	}
 
+	// Add columns introduced after the initial schema
+	if err := b.migrateSchema(); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
 	// Initialize default configuration
 	if err := b.initializeDefaultConfig(); err != nil {
 		return fmt.Errorf("failed to initialize default configuration: %w", err)
@@ -179,19 +477,154 @@ func (b *FilamentBridge) initDatabase() error {
 
 	// Migrate existing FilaBridge locations to Spoolman
 	if err := b.migrateLocationsToSpoolman(); err != nil {
-		log.Printf("Warning: Failed to migrate locations to Spoolman: %v", err)
+		bridgeLog.Warn("Failed to migrate locations to Spoolman", "error", err)
 		// Don't fail initialization if migration fails
 	}
 
 	// Create Spoolman locations for existing toolhead mappings
 	if err := b.migrateToolheadMappingsToSpoolman(); err != nil {
-		log.Printf("Warning: Failed to migrate toolhead mappings to Spoolman: %v", err)
+		bridgeLog.Warn("Failed to migrate toolhead mappings to Spoolman", "error", err)
 		// Don't fail initialization if migration fails
 	}
 
 	return nil
 }
 
+// migrateSchema adds columns that were introduced after a table was first created
+func (b *FilamentBridge) migrateSchema() error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"printer_configs", "connect_printer_uuid", "TEXT DEFAULT ''"},
+		{"printer_configs", "gcode_flavor", "TEXT DEFAULT ''"},
+		{"printer_configs", "connect_token", "TEXT DEFAULT ''"},
+		{"printer_configs", "slots", "INTEGER DEFAULT 0"},
+		{"printer_configs", "poll_interval", "INTEGER DEFAULT 0"},
+		{"printer_configs", "active_poll_interval", "INTEGER DEFAULT 0"},
+		{"printer_configs", "prusalink_timeout", "INTEGER DEFAULT 0"},
+		{"printer_configs", "prusalink_file_download_timeout", "INTEGER DEFAULT 0"},
+		{"printer_configs", "spoolman_instance", "TEXT DEFAULT ''"},
+		{"printer_configs", "insecure_skip_verify", "BOOLEAN DEFAULT 0"},
+		{"printer_configs", "printer_group", "TEXT DEFAULT ''"},
+		{"print_history", "notes", "TEXT DEFAULT ''"},
+		{"print_history", "rating", "INTEGER DEFAULT 0"},
+		{"print_history", "job_display_name", "TEXT DEFAULT ''"},
+		{"print_history", "member", "TEXT DEFAULT ''"},
+		{"print_history", "reverted", "INTEGER DEFAULT 0"},
+		{"print_history", "source", "TEXT DEFAULT 'print'"},
+		{"print_history", "waste", "REAL DEFAULT 0"},
+		{"print_history", "cost", "REAL"},
+		{"toolhead_mappings", "mapped_by", "TEXT DEFAULT ''"},
+		{"toolhead_mappings", "idle_reminded_at", "TIMESTAMP"},
+		{"unfinished_prints", "finished_at", "TIMESTAMP"},
+		{"spool_events", "location", "TEXT DEFAULT ''"},
+		{"spool_events", "spoolman_instance", "TEXT DEFAULT ''"},
+		{"nfc_sessions", "spoolman_instance", "TEXT DEFAULT ''"},
+	}
+
+	for _, col := range columns {
+		if err := b.addColumnIfMissing(col.table, col.column, col.definition); err != nil {
+			return err
+		}
+	}
+
+	// Spool IDs are only unique within a Spoolman instance
+	spoolKeyedTables := []struct {
+		table  string
+		create string
+	}{
+		{"spool_aliases", createSpoolAliasesTable},
+		{"spool_exposure", createSpoolExposureTable},
+	}
+	for _, spoolTable := range spoolKeyedTables {
+		if err := b.addInstanceToSpoolKey(spoolTable.table, spoolTable.create); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tableColumns returns the names of a table's columns
+func (b *FilamentBridge) tableColumns(table string) ([]string, error) {
+	rows, err := b.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan column info for %s: %w", table, err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// addInstanceToSpoolKey rebuilds a table keyed by spool ID alone with create, which keys it by
+// Spoolman instance and spool ID. Its rows are kept and belong to the main instance.
+func (b *FilamentBridge) addInstanceToSpoolKey(table, create string) error {
+	columns, err := b.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if column == "spoolman_instance" {
+			return nil
+		}
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rebuilding %s: %w", table, err)
+	}
+	defer tx.Rollback()
+
+	copied := strings.Join(columns, ", ")
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s_old", table, table),
+		create,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s_old", table, copied, copied, table),
+		fmt.Sprintf("DROP TABLE %s_old", table),
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to rebuild %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rebuilding %s: %w", table, err)
+	}
+	bridgeLog.Info("Migration: keyed table by Spoolman instance", "table", table)
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table unless it already exists
+func (b *FilamentBridge) addColumnIfMissing(table, column, definition string) error {
+	columns, err := b.tableColumns(table)
+	if err != nil {
+		return err
+	}
+	for _, name := range columns {
+		if name == column {
+			return nil
+		}
+	}
+
+	if _, err := b.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	bridgeLog.Info("Migration: added column", "table", table, "column", column)
+	return nil
+}
+
 // migrateLocationsToSpoolman migrates existing FilaBridge locations to Spoolman
 func (b *FilamentBridge) migrateLocationsToSpoolman() error {
 	// Check if fb_locations table exists by trying to query it
@@ -208,7 +641,7 @@ func (b *FilamentBridge) migrateLocationsToSpoolman() error {
 		var toolheadID sql.NullInt64
 
 		if err := rows.Scan(&name, &locationType, &printerName, &toolheadID); err != nil {
-			log.Printf("Warning: Failed to scan location row during migration: %v", err)
+			bridgeLog.Warn("Failed to scan location row during migration", "error", err)
 			continue
 		}
 
@@ -220,7 +653,7 @@ func (b *FilamentBridge) migrateLocationsToSpoolman() error {
 
 		// Skip if this is a virtual printer toolhead location (will be created on-demand)
 		if b.isVirtualPrinterToolheadLocation(locationName) {
-			log.Printf("Migration: Skipping virtual printer toolhead location '%s'", locationName)
+			bridgeLog.Info("Migration: skipping virtual printer toolhead location", "location", locationName)
 			continue
 		}
 
@@ -229,20 +662,20 @@ func (b *FilamentBridge) migrateLocationsToSpoolman() error {
 		// Locations must be created manually in Spoolman UI or are auto-created when referenced in spools.
 		existingLocation, err := b.spoolman.FindLocationByName(locationName)
 		if err != nil {
-			log.Printf("Warning: Failed to check if location '%s' exists in Spoolman: %v", locationName, err)
+			bridgeLog.Warn("Failed to check if location exists in Spoolman", "location", locationName, "error", err)
 			continue
 		}
 
 		if existingLocation == nil {
-			log.Printf("Migration: Location '%s' does not exist in Spoolman. It will be created when referenced in a spool, or can be created manually in Spoolman UI.", locationName)
+			bridgeLog.Info("Migration: location does not exist in Spoolman. It will be created when referenced in a spool, or can be created manually in Spoolman UI.", "location", locationName)
 		} else {
 			migratedCount++
-			log.Printf("Migration: Location '%s' already exists in Spoolman", locationName)
+			bridgeLog.Info("Migration: location already exists in Spoolman", "location", locationName)
 		}
 	}
 
 	if migratedCount > 0 {
-		log.Printf("Migration: Successfully migrated %d location(s) from FilaBridge to Spoolman", migratedCount)
+		bridgeLog.Info("Migration: migrated locations from FilaBridge to Spoolman", "count", migratedCount)
 	}
 
 	return nil
@@ -274,14 +707,14 @@ func (b *FilamentBridge) migrateToolheadMappingsToSpoolman() error {
 		}
 
 		if printerID == "" {
-			log.Printf("Migration: Could not find printer ID for printer name '%s', skipping", printerName)
+			bridgeLog.Info("Migration: could not find printer ID for printer name, skipping", "printer", printerName)
 			continue
 		}
 
 		// Get toolhead names for this printer
 		toolheadNames, err := b.GetAllToolheadNames(printerID)
 		if err != nil {
-			log.Printf("Warning: Failed to get toolhead names for printer %s: %v", printerID, err)
+			bridgeLog.Warn("Failed to get toolhead names", "printer_id", printerID, "error", err)
 			toolheadNames = make(map[int]string)
 		}
 
@@ -302,43 +735,107 @@ func (b *FilamentBridge) migrateToolheadMappingsToSpoolman() error {
 			// Locations will be auto-created when spools are assigned to toolheads.
 			existingLocation, err := b.spoolman.FindLocationByName(locationName)
 			if err != nil {
-				log.Printf("Warning: Failed to check if toolhead location '%s' exists in Spoolman: %v", locationName, err)
+				bridgeLog.Warn("Failed to check if toolhead location exists in Spoolman", "location", locationName, "error", err)
 				continue
 			}
 
 			if existingLocation == nil {
-				log.Printf("Migration: Toolhead location '%s' does not exist in Spoolman. It will be created when a spool is assigned to this toolhead.", locationName)
+				bridgeLog.Info("Migration: toolhead location does not exist in Spoolman. It will be created when a spool is assigned to this toolhead.", "location", locationName)
 			} else {
 				createdCount++
-				log.Printf("Migration: Toolhead location '%s' already exists in Spoolman", locationName)
+				bridgeLog.Info("Migration: toolhead location already exists in Spoolman", "location", locationName)
 			}
 		}
 	}
 
 	if createdCount > 0 {
-		log.Printf("Migration: Successfully created %d toolhead location(s) in Spoolman", createdCount)
+		bridgeLog.Info("Migration: created toolhead locations in Spoolman", "count", createdCount)
 	}
 
 	return nil
 }
 
-// initializeDefaultConfig sets up default configuration values
-func (b *FilamentBridge) initializeDefaultConfig() error {
-	defaultConfigs := map[string]string{
+// defaultConfigValues returns the configuration a fresh installation starts with
+func defaultConfigValues() map[string]string {
+	return map[string]string{
 		ConfigKeyPrinterIPs:                      "", // Comma-separated list of printer IP addresses
 		ConfigKeyAPIKey:                          "", // PrusaLink API key for authentication
 		ConfigKeySpoolmanURL:                     DefaultSpoolmanURL,
 		ConfigKeySpoolmanUsername:                "", // Spoolman basic auth username (optional)
 		ConfigKeySpoolmanPassword:                "", // Spoolman basic auth password (optional)
 		ConfigKeyPollInterval:                    fmt.Sprintf("%d", DefaultPollInterval),
+		ConfigKeyActivePollInterval:              fmt.Sprintf("%d", DefaultActivePollInterval),
 		ConfigKeyWebPort:                         DefaultWebPort,
 		ConfigKeyPrusaLinkTimeout:                fmt.Sprintf("%d", PrusaLinkTimeout),
 		ConfigKeyPrusaLinkFileDownloadTimeout:    fmt.Sprintf("%d", PrusaLinkFileDownloadTimeout),
 		ConfigKeySpoolmanTimeout:                 fmt.Sprintf("%d", SpoolmanTimeout),
 		ConfigKeyAutoAssignPreviousSpoolEnabled:  "false", // Enable auto-assignment of previous spool to default location
 		ConfigKeyAutoAssignPreviousSpoolLocation: "",      // Default location name for auto-assigned previous spools
+		ConfigKeyMonitorStartDelay:               fmt.Sprintf("%d", DefaultMonitorStartDelay),
+		ConfigKeyMonitorJitter:                   fmt.Sprintf("%d", DefaultMonitorJitter),
+		ConfigKeyPrusaLinkEventsEnabled:          "false", // Subscribe to PrusaLink push events where firmware supports it
+		ConfigKeySpoolmanEventsEnabled:           "true",  // Follow Spoolman's change websocket to refresh the dashboard
+		ConfigKeyLowStockThreshold:               fmt.Sprintf("%d", DefaultLowStockThreshold),
+		ConfigKeyPrusaConnectURL:                 DefaultPrusaConnectURL,
+		ConfigKeyAutoPauseEmptySpool:             "false", // Pause prints that start on an empty spool
+		ConfigKeyAutoPauseUnmapped:               "false", // Pause prints that start on a toolhead with no spool
+		ConfigKeyLowFilamentCheck:                "off",   // off, warn or pause when a spool has less left than a job needs
+		ConfigKeyRunoutPredictionEnabled:         "false", // Predict mid-print spool runouts from job progress
+		ConfigKeyActiveSpoolEstimates:            "true",  // Estimate mapped spools' remaining weight during prints
+		ConfigKeyRunoutDetectionEnabled:          "true",  // Record and notify when a print stops for attention
+		ConfigKeyAutoPauseMinWeight:              fmt.Sprintf("%d", DefaultAutoPauseMinWeight),
+		ConfigKeyEmptySpoolAutoArchive:           "false", // Archive and unmap spools once usage leaves them empty
+		ConfigKeyNotificationChannels:            "[]",    // JSON list of notification channels
+		ConfigKeyOutgoingWebhooks:                "[]",    // JSON list of lifecycle event webhooks
+		ConfigKeySpoolmanInstances:               "[]",    // JSON list of additional Spoolman instances printers can use
+		ConfigKeyAdminTokenHash:                  "",      // Access control is off until an admin token is issued
+		ConfigKeyIdleSpoolReminderDays:           fmt.Sprintf("%d", DefaultIdleSpoolReminderDays),
+		ConfigKeyIdleSpoolReturnLocation:         "", // Falls back to the auto-assign default location
+		ConfigKeyExternalURL:                     "", // Public URL used in links, e.g. https://filabridge.example.com
+		ConfigKeyClockDriftThreshold:             fmt.Sprintf("%d", DefaultClockDriftThreshold),
+		ConfigKeyScheduledJobs:                   "{}", // Per-job enabled/interval overrides keyed by job name
+		ConfigKeyMQTTBroker:                      "",   // Home Assistant MQTT publishing is off until a broker is set
+		ConfigKeyMQTTUsername:                    "",
+		ConfigKeyMQTTPassword:                    "",
+		ConfigKeyMQTTTopicPrefix:                 DefaultMQTTTopicPrefix,
+		ConfigKeyMQTTDiscoveryPrefix:             DefaultMQTTDiscoveryPrefix,
+		ConfigKeyEditableSpoolFields:             "", // Comma-separated Spoolman extra field keys, e.g. dried_on,owner
+		ConfigKeySpoolOwnerField:                 DefaultSpoolOwnerField,
+		ConfigKeyLogLevel:                        "info",
+		ConfigKeyLogFormat:                       LogFormatText,
+		ConfigKeyLogModuleLevels:                 "", // e.g. prusalink=debug,spoolman=warn
+		ConfigKeyLogBufferSize:                   fmt.Sprintf("%d", DefaultLogBufferSize),
+		ConfigKeyEstimatedFlowRate:               fmt.Sprintf("%d", DefaultEstimatedFlowRate),
+		ConfigKeyDefaultFilamentDensity:          fmt.Sprintf("%g", defaultFilamentDensity),
+		ConfigKeyDefaultFilamentDiameter:         fmt.Sprintf("%g", defaultFilamentDiameter),
+		ConfigKeyBasePath:                        "", // e.g. /filabridge when served behind a reverse proxy
+		ConfigKeyTLSCertFile:                     "",
+		ConfigKeyTLSKeyFile:                      "",
+		ConfigKeyTLSACMEDomains:                  "",
+		ConfigKeyTLSACMEEmail:                    "",
+		ConfigKeyNFCSessionTimeout:               fmt.Sprintf("%d", DefaultNFCSessionTimeout),
+		ConfigKeySpoolmanWriteDelay:              fmt.Sprintf("%d", DefaultSpoolmanWriteDelay),
+		ConfigKeyCombineNotifications:            "false",
+		ConfigKeyFilamentMismatchCheck:           "off", // off, warn or pause when a spool isn't the filament a job was sliced for
+		ConfigKeySpoolmanProxyEnabled:            "false",
+		ConfigKeyWasteSpoolField:                 "", // e.g. purge_waste to total each spool's purge waste in Spoolman
+		ConfigKeyBackupDir:                       "",
+		ConfigKeyBackupRetention:                 fmt.Sprintf("%d", DefaultBackupRetention),
+		ConfigKeyReadinessSpoolmanWindow:         fmt.Sprintf("%d", DefaultReadinessSpoolmanWindow),
+		ConfigKeyPrintProcessingWorkers:          fmt.Sprintf("%d", DefaultPrintProcessingWorkers),
+		ConfigKeySpoolmanInsecureSkipVerify:      "false",
+		ConfigKeySpoolLowStockField:              DefaultSpoolLowStockField,
+		ConfigKeyScales:                          "[]", // JSON list of smart scales publishing spool weights over MQTT
+		ConfigKeyScaleDiscrepancyThreshold:       fmt.Sprintf("%d", DefaultScaleDiscrepancyThreshold),
+		ConfigKeyEnvironmentSensors:              "[]", // JSON list of humidity/temperature sensors publishing over MQTT
+		ConfigKeyDryStorageHumidity:              fmt.Sprintf("%d", DefaultDryStorageHumidity),
+		ConfigKeyToolheadRuleCheck:               "warn", // off, warn or reject when a spool or job breaks a toolhead's rules
+		ConfigKeyBarcodeLookupURL:                "",     // Online filament database for barcodes with no local mapping; off when empty
 	}
+}
 
+// initializeDefaultConfig sets up default configuration values
+func (b *FilamentBridge) initializeDefaultConfig() error {
 	// Check if this is a fresh installation by checking if any config exists
 	var totalCount int
 	err := b.db.QueryRow("SELECT COUNT(*) FROM configuration").Scan(&totalCount)
@@ -348,7 +845,7 @@ func (b *FilamentBridge) initializeDefaultConfig() error {
 
 	// Only insert defaults if this is a fresh installation
 	if totalCount == 0 {
-		for key, value := range defaultConfigs {
+		for key, value := range defaultConfigValues() {
 			_, err := b.db.Exec(
 				"INSERT INTO configuration (key, value, description) VALUES (?, ?, ?)",
 				key, value, getConfigDescription(key),
@@ -371,12 +868,77 @@ func getConfigDescription(key string) string {
 		ConfigKeySpoolmanUsername:                "Spoolman basic auth username (optional, leave empty if not using basic auth)",
 		ConfigKeySpoolmanPassword:                "Spoolman basic auth password (optional, leave empty if not using basic auth)",
 		ConfigKeyPollInterval:                    "Polling interval in seconds",
+		ConfigKeyActivePollInterval:              "Polling interval in seconds while a printer is printing (capped at the idle poll interval)",
 		ConfigKeyWebPort:                         "Port for web interface",
 		ConfigKeyPrusaLinkTimeout:                "PrusaLink API timeout in seconds",
 		ConfigKeyPrusaLinkFileDownloadTimeout:    "PrusaLink file download timeout in seconds",
 		ConfigKeySpoolmanTimeout:                 "Spoolman API timeout in seconds",
 		ConfigKeyAutoAssignPreviousSpoolEnabled:  "Enable automatic assignment of previous spool to default location when assigning new spool to toolhead",
 		ConfigKeyAutoAssignPreviousSpoolLocation: "Default location name where previous spools will be automatically assigned (must exist as a location)",
+		ConfigKeyMonitorStartDelay:               "Delay in seconds before the first monitoring cycle after startup",
+		ConfigKeyMonitorJitter:                   "Maximum random delay in seconds added to each printer poll to stagger requests (kept below the poll interval)",
+		ConfigKeyPrusaLinkEventsEnabled:          "Subscribe to PrusaLink push events for faster print completion detection (falls back to polling when unsupported)",
+		ConfigKeySpoolmanEventsEnabled:           "Subscribe to Spoolman's change websocket so spool edits made in Spoolman show on the dashboard right away",
+		ConfigKeyLowStockThreshold:               "Remaining weight in grams below which a spool is reported as low stock",
+		ConfigKeyPrusaConnectURL:                 "Base URL of the Prusa Connect API (used for printers configured with a Connect token)",
+		ConfigKeyAutoPauseEmptySpool:             "Pause a print when it starts on a toolhead whose mapped spool is at or below the empty weight",
+		ConfigKeyAutoPauseUnmapped:               "Pause a print when it starts on a toolhead with no spool mapped",
+		ConfigKeyAutoPauseMinWeight:              "Remaining weight in grams at or below which a spool is considered empty, for auto-pause and empty spool notifications",
+		ConfigKeyEmptySpoolAutoArchive:           "Archive spools in Spoolman and unmap them from their toolhead once print usage leaves them empty",
+		ConfigKeyLowFilamentCheck:                "Check at print start whether mapped spools have enough filament for the job: off, warn or pause",
+		ConfigKeyRunoutPredictionEnabled:         "Predict from job progress whether a mapped spool will run out mid-print and alert with the estimated time",
+		ConfigKeyRunoutDetectionEnabled:          "Notify when a printer stops a print for attention, as it does when its filament sensor detects a runout, and offer to archive the empty spool",
+		ConfigKeyActiveSpoolEstimates:            "Show on the dashboard how much filament mapped spools will have left after the current job, counting down as it prints. Downloads each job's G-code when it starts",
+		ConfigKeyNotificationChannels:            "JSON list of notification channels (webhook, ntfy, discord, telegram, email) with optional per-channel quiet hours",
+		ConfigKeyOutgoingWebhooks:                "JSON list of webhooks receiving print_completed, spool_deducted, print_error, spool_assigned and spool_empty events, each with an optional secret, event filter and body template",
+		ConfigKeySpoolmanInstances:               "JSON list of additional Spoolman instances, each with a name, URL and optional basic auth; printers assigned to one track their spools there instead of in the main instance",
+		ConfigKeyAdminTokenHash:                  "SHA-256 hash of the admin API token (empty disables access control)",
+		ConfigKeyIdleSpoolReminderDays:           "Days a spool can stay mapped to a toolhead without being used before a reminder is sent (0 disables)",
+		ConfigKeyIdleSpoolReturnLocation:         "Location idle spools are moved to from reminder links (defaults to the auto-assign location)",
+		ConfigKeyExternalURL:                     "Public base URL of FilaBridge used in generated links, NFC tag URLs and QR codes, e.g. https://filabridge.example.com (including any base path)",
+		ConfigKeyActionLinkSecret:                "Secret used to sign one-tap action links",
+		ConfigKeyClockDriftThreshold:             "Seconds a printer clock may differ from the server before a warning is logged (0 disables)",
+		ConfigKeyScheduledJobs:                   "JSON object of background job settings (enabled, interval_seconds) keyed by job name",
+		ConfigKeyMQTTBroker:                      "MQTT broker for Home Assistant, e.g. tcp://homeassistant.local:1883 (empty disables)",
+		ConfigKeyMQTTUsername:                    "MQTT username (optional)",
+		ConfigKeyMQTTPassword:                    "MQTT password (optional)",
+		ConfigKeyMQTTTopicPrefix:                 "Topic prefix FilaBridge state is published under",
+		ConfigKeyMQTTDiscoveryPrefix:             "Home Assistant MQTT discovery prefix",
+		ConfigKeyEditableSpoolFields:             "Comma-separated keys of Spoolman spool extra fields that can be edited from FilaBridge",
+		ConfigKeySpoolOwnerField:                 "Spoolman spool extra field that holds the spool owner (a member name or free text)",
+		ConfigKeyLogLevel:                        "Minimum log level: debug, info, warn or error",
+		ConfigKeyLogFormat:                       "Log output format: text or json",
+		ConfigKeyLogModuleLevels:                 "Per-module log levels overriding log_level, e.g. prusalink=debug,spoolman=warn",
+		ConfigKeyLogBufferSize:                   "Number of recent log entries kept in memory for the web UI and /api/logs (0 disables)",
+		ConfigKeyAuthUsername:                    "Username for the UI login",
+		ConfigKeyAuthPasswordHash:                "bcrypt hash of the UI login password (empty disables login)",
+		ConfigKeyNFCTokenSecret:                  "Secret the NFC scan token embedded in tag URLs is derived from",
+		ConfigKeyBasePath:                        "Path prefix the web interface is served under behind a reverse proxy, e.g. /filabridge (restart required)",
+		ConfigKeyTLSCertFile:                     "Path to a PEM certificate for serving HTTPS directly (restart required)",
+		ConfigKeyTLSKeyFile:                      "Path to the PEM private key for tls_cert_file",
+		ConfigKeyTLSACMEDomains:                  "Comma-separated domains to obtain HTTPS certificates for with ACME (Let's Encrypt) when no certificate file is set; ports 80 or 443 must be reachable (restart required)",
+		ConfigKeyTLSACMEEmail:                    "Contact email given to the ACME certificate authority",
+		ConfigKeyEstimatedFlowRate:               "Average grams of filament per hour of printing (not the volumetric flow in mm³/s; about 15 for a 0.4 mm nozzle), used to estimate usage when a job's G-code can't be downloaded and the printer reports no filament usage (0 disables)",
+		ConfigKeyDefaultFilamentDensity:          "Filament density in g/cm³ used to convert usage reported as a length when the spool's filament in Spoolman has none",
+		ConfigKeyDefaultFilamentDiameter:         "Filament diameter in mm used to convert usage reported as a length when the spool's filament in Spoolman has none",
+		ConfigKeyNFCSessionTimeout:               "Minutes an NFC scan session waits for its next tag before it expires",
+		ConfigKeySpoolmanWriteDelay:              "Milliseconds to wait between Spoolman updates when a multi-tool print finishes, to spare small Spoolman instances (0 disables)",
+		ConfigKeyCombineNotifications:            "Send one print complete notification with per-toolhead usage and low stock warnings instead of a notification per spool",
+		ConfigKeyFilamentMismatchCheck:           "Check at print start whether mapped spools match the material and color a job was sliced for: off, warn or pause",
+		ConfigKeySpoolmanProxyEnabled:            "Serve Spoolman read-only under /spoolman and point Spoolman links there, for clients that can't reach Spoolman; Spoolman must run with SPOOLMAN_BASE_PATH set to FilaBridge's base path plus /spoolman",
+		ConfigKeyWasteSpoolField:                 "Spoolman spool extra field that totals the grams each spool lost to wipe towers and purges (empty disables)",
+		ConfigKeyBackupDir:                       "Directory the database is backed up to once a day (empty disables scheduled backups)",
+		ConfigKeyBackupRetention:                 "Number of scheduled backups to keep in the backup directory",
+		ConfigKeyReadinessSpoolmanWindow:         "Minutes since Spoolman last answered before the readiness check fails",
+		ConfigKeyPrintProcessingWorkers:          "Number of finished prints processed at once across all printers; a printer's prints are always processed one at a time (restart required)",
+		ConfigKeySpoolmanInsecureSkipVerify:      "Accept Spoolman's HTTPS certificate without verifying it, e.g. a self-signed one",
+		ConfigKeySpoolLowStockField:              "Spoolman spool extra field that holds a spool's own low stock threshold in grams, overriding its material's and the global one",
+		ConfigKeyScales:                          "JSON list of smart scales, each with a name, the MQTT topic it publishes weights on, and the spool or printer toolhead it weighs",
+		ConfigKeyScaleDiscrepancyThreshold:       "Grams a weighed spool may differ from Spoolman and still be corrected automatically; larger differences are flagged for review",
+		ConfigKeyEnvironmentSensors:              "JSON list of humidity/temperature sensors, each with the location it's in, the MQTT topic it publishes on and, for plain number payloads, the measure",
+		ConfigKeyDryStorageHumidity:              "Relative humidity (%) at or below which a location counts as dry storage for spool exposure tracking",
+		ConfigKeyToolheadRuleCheck:               "Check mappings and print starts against toolhead rules (allowed materials, nozzle type and size): off, warn or reject, which also pauses the print",
+		ConfigKeyBarcodeLookupURL:                "URL of an online filament database to look up spool barcodes without a local mapping, with {barcode} where the code goes; leave empty to only use local mappings",
 	}
 	if desc, exists := descriptions[key]; exists {
 		return desc
@@ -384,8 +946,13 @@ func getConfigDescription(key string) string {
 	return "Configuration value"
 }
 
-// GetConfigValue gets a configuration value from the database
+// GetConfigValue gets a configuration value, from the environment if it overrides the key and
+// otherwise from the database
 func (b *FilamentBridge) GetConfigValue(key string) (string, error) {
+	if value, ok := configEnvOverride(key); ok {
+		return value, nil
+	}
+
 	var value string
 	err := b.db.QueryRow("SELECT value FROM configuration WHERE key = ?", key).Scan(&value)
 	if err != nil {
@@ -394,8 +961,16 @@ func (b *FilamentBridge) GetConfigValue(key string) (string, error) {
 	return value, nil
 }
 
-// SetConfigValue sets a configuration value in the database
+// SetConfigValue sets a configuration value in the database. Keys overridden by the
+// environment are read-only; saving the value they already have is a no-op.
 func (b *FilamentBridge) SetConfigValue(key, value string) error {
+	if override, ok := configEnvOverride(key); ok {
+		if value == override {
+			return nil
+		}
+		return newCodedError(ErrCodeConflict, "%s is set by the %s environment variable and can't be changed here", key, configEnvName(key))
+	}
+
 	_, err := b.db.Exec(
 		"INSERT OR REPLACE INTO configuration (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
 		key, value,
@@ -406,8 +981,20 @@ func (b *FilamentBridge) SetConfigValue(key, value string) error {
 	return nil
 }
 
-// GetAllConfig gets all configuration values
+// GetAllConfig gets all configuration values, with environment overrides applied
 func (b *FilamentBridge) GetAllConfig() (map[string]string, error) {
+	config, err := b.getStoredConfig()
+	if err != nil {
+		return nil, err
+	}
+	for key := range configEnvOverrides(config) {
+		config[key], _ = configEnvOverride(key)
+	}
+	return config, nil
+}
+
+// getStoredConfig gets all configuration values saved in the database
+func (b *FilamentBridge) getStoredConfig() (map[string]string, error) {
 	rows, err := b.db.Query("SELECT key, value FROM configuration")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all config: %w", err)
@@ -468,7 +1055,7 @@ func (b *FilamentBridge) SetAutoAssignPreviousSpoolLocation(location string) err
 
 // GetAllPrinterConfigs gets all printer configurations
 func (b *FilamentBridge) GetAllPrinterConfigs() (map[string]PrinterConfig, error) {
-	rows, err := b.db.Query("SELECT printer_id, name, model, ip_address, api_key, toolheads FROM printer_configs")
+	rows, err := b.db.Query("SELECT printer_id, name, model, ip_address, api_key, toolheads, COALESCE(slots, 0), COALESCE(connect_printer_uuid, ''), COALESCE(connect_token, ''), COALESCE(gcode_flavor, ''), COALESCE(poll_interval, 0), COALESCE(active_poll_interval, 0), COALESCE(prusalink_timeout, 0), COALESCE(prusalink_file_download_timeout, 0), COALESCE(spoolman_instance, ''), COALESCE(insecure_skip_verify, 0), COALESCE(printer_group, '') FROM printer_configs")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get printer configs: %w", err)
 	}
@@ -476,17 +1063,30 @@ func (b *FilamentBridge) GetAllPrinterConfigs() (map[string]PrinterConfig, error
 
 	configs := make(map[string]PrinterConfig)
 	for rows.Next() {
-		var printerID, name, model, ipAddress, apiKey string
-		var toolheads int
-		if err := rows.Scan(&printerID, &name, &model, &ipAddress, &apiKey, &toolheads); err != nil {
+		var printerID, name, model, ipAddress, apiKey, connectUUID, connectToken, gcodeFlavor, spoolmanInstance, group string
+		var toolheads, slots, pollInterval, activePollInterval, prusaLinkTimeout, downloadTimeout int
+		var insecureSkipVerify bool
+		if err := rows.Scan(&printerID, &name, &model, &ipAddress, &apiKey, &toolheads, &slots, &connectUUID, &connectToken, &gcodeFlavor,
+			&pollInterval, &activePollInterval, &prusaLinkTimeout, &downloadTimeout, &spoolmanInstance, &insecureSkipVerify, &group); err != nil {
 			return nil, fmt.Errorf("failed to scan printer config row: %w", err)
 		}
 		configs[printerID] = PrinterConfig{
-			Name:      name,
-			Model:     model,
-			IPAddress: ipAddress,
-			APIKey:    apiKey,
-			Toolheads: toolheads,
+			Name:               name,
+			Model:              model,
+			IPAddress:          ipAddress,
+			APIKey:             apiKey,
+			Toolheads:          toolheads,
+			Slots:              slots,
+			ConnectPrinterUUID: connectUUID,
+			ConnectToken:       connectToken,
+			GcodeFlavor:        gcodeFlavor,
+			PollInterval:       pollInterval,
+			ActivePollInterval: activePollInterval,
+			PrusaLinkTimeout:   prusaLinkTimeout,
+			DownloadTimeout:    downloadTimeout,
+			SpoolmanInstance:   spoolmanInstance,
+			InsecureSkipVerify: insecureSkipVerify,
+			Group:              group,
 		}
 	}
 
@@ -499,9 +1099,11 @@ func (b *FilamentBridge) SavePrinterConfig(printerID string, config PrinterConfi
 	defer b.mutex.Unlock()
 
 	_, err := b.db.Exec(`
-		INSERT OR REPLACE INTO printer_configs (printer_id, name, model, ip_address, api_key, toolheads)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, printerID, config.Name, config.Model, config.IPAddress, config.APIKey, config.Toolheads)
+		INSERT OR REPLACE INTO printer_configs (printer_id, name, model, ip_address, api_key, toolheads, slots, connect_printer_uuid, connect_token, gcode_flavor,
+			poll_interval, active_poll_interval, prusalink_timeout, prusalink_file_download_timeout, spoolman_instance, insecure_skip_verify, printer_group)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, printerID, config.Name, config.Model, config.IPAddress, config.APIKey, config.Toolheads, config.Slots, config.ConnectPrinterUUID, config.ConnectToken, config.GcodeFlavor,
+		config.PollInterval, config.ActivePollInterval, config.PrusaLinkTimeout, config.DownloadTimeout, config.SpoolmanInstance, config.InsecureSkipVerify, strings.TrimSpace(config.Group))
 	if err != nil {
 		return fmt.Errorf("failed to save printer config: %w", err)
 	}
@@ -517,6 +1119,15 @@ func (b *FilamentBridge) DeletePrinterConfig(printerID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete printer config: %w", err)
 	}
+	if _, err := b.db.Exec("DELETE FROM maintenance_windows WHERE printer_id = ?", printerID); err != nil {
+		return fmt.Errorf("failed to delete maintenance windows: %w", err)
+	}
+	if _, err := b.db.Exec("DELETE FROM toolhead_rules WHERE printer_id = ?", printerID); err != nil {
+		return fmt.Errorf("failed to delete toolhead rules: %w", err)
+	}
+	if _, err := b.db.Exec("DELETE FROM printer_webhook_secrets WHERE printer_id = ?", printerID); err != nil {
+		return fmt.Errorf("failed to delete webhook secret: %w", err)
+	}
 	return nil
 }
 
@@ -547,7 +1158,7 @@ func (b *FilamentBridge) SetToolheadName(printerID string, toolheadID int, name
 	// Validate name is not empty
 	name = strings.TrimSpace(name)
 	if name == "" {
-		return fmt.Errorf("toolhead name cannot be empty")
+		return newCodedError(ErrCodeInvalidRequest, "toolhead name cannot be empty")
 	}
 
 	// Get printer config to find printer name (before acquiring lock)
@@ -558,7 +1169,7 @@ func (b *FilamentBridge) SetToolheadName(printerID string, toolheadID int, name
 
 	printerConfig, exists := printerConfigs[printerID]
 	if !exists {
-		return fmt.Errorf("printer %s not found", printerID)
+		return newCodedError(ErrCodePrinterNotFound, "printer %s not found", printerID)
 	}
 
 	printerName := printerConfig.Name
@@ -589,17 +1200,18 @@ func (b *FilamentBridge) SetToolheadName(printerID string, toolheadID int, name
 
 	// If location name changed, update Spoolman (outside of lock)
 	if oldLocationName != newLocationName {
-		// Get all spools from Spoolman
-		spools, err := b.spoolman.GetAllSpools()
+		// Get all spools from the printer's Spoolman
+		spoolman := b.spoolmanFor(printerName)
+		spools, err := spoolman.GetAllSpools()
 		if err != nil {
-			log.Printf("Warning: Failed to get spools from Spoolman to update location names: %v", err)
+			bridgeLog.Warn("Failed to get spools from Spoolman to update location names", "error", err)
 		} else {
 			// Find spools with the old location name and update them
 			updatedCount := 0
 			for _, spool := range spools {
 				if spool.Location == oldLocationName {
-					if err := b.spoolman.UpdateSpoolLocation(spool.ID, newLocationName); err != nil {
-						log.Printf("Warning: Failed to update spool %d location from '%s' to '%s': %v", spool.ID, oldLocationName, newLocationName, err)
+					if err := spoolman.UpdateSpoolLocation(spool.ID, newLocationName); err != nil {
+						bridgeLog.Warn("Failed to update spool location", "spool_id", spool.ID, "from", oldLocationName, "to", newLocationName, "error", err)
 					} else {
 						updatedCount++
 					}
@@ -607,17 +1219,17 @@ func (b *FilamentBridge) SetToolheadName(printerID string, toolheadID int, name
 			}
 
 			// Ensure the new location exists in Spoolman
-			if _, err := b.spoolman.GetOrCreateLocation(newLocationName); err != nil {
-				log.Printf("Warning: Failed to create/verify location '%s' in Spoolman: %v", newLocationName, err)
+			if _, err := spoolman.GetOrCreateLocation(newLocationName); err != nil {
+				bridgeLog.Warn("Failed to create or verify location in Spoolman", "location", newLocationName, "error", err)
 			}
 
 			if updatedCount > 0 {
-				log.Printf("Updated %d spool(s) location from '%s' to '%s'", updatedCount, oldLocationName, newLocationName)
+				bridgeLog.Info("Updated spool locations", "count", updatedCount, "from", oldLocationName, "to", newLocationName)
 			}
 		}
 	}
 
-	log.Printf("Set toolhead name for printer %s, toolhead %d: %s", printerID, toolheadID, name)
+	bridgeLog.Info("Set toolhead name", "printer_id", printerID, "toolhead_id", toolheadID, "name", name)
 	return nil
 }
 
@@ -661,12 +1273,72 @@ func (b *FilamentBridge) GetConfigSnapshot() *Config {
 	// Create a shallow copy of the config
 	configCopy := &Config{
 		SpoolmanURL:                  b.config.SpoolmanURL,
+		SpoolmanInsecureSkipVerify:   b.config.SpoolmanInsecureSkipVerify,
 		PollInterval:                 b.config.PollInterval,
+		ActivePollInterval:           b.config.ActivePollInterval,
 		DBFile:                       b.config.DBFile,
 		WebPort:                      b.config.WebPort,
 		PrusaLinkTimeout:             b.config.PrusaLinkTimeout,
 		PrusaLinkFileDownloadTimeout: b.config.PrusaLinkFileDownloadTimeout,
 		SpoolmanTimeout:              b.config.SpoolmanTimeout,
+		MonitorStartDelay:            b.config.MonitorStartDelay,
+		MonitorJitter:                b.config.MonitorJitter,
+		PrusaLinkEventsEnabled:       b.config.PrusaLinkEventsEnabled,
+		SpoolmanEventsEnabled:        b.config.SpoolmanEventsEnabled,
+		LowStockThreshold:            b.config.LowStockThreshold,
+		PrusaConnectURL:              b.config.PrusaConnectURL,
+		AutoPauseEmptySpool:          b.config.AutoPauseEmptySpool,
+		AutoPauseUnmapped:            b.config.AutoPauseUnmapped,
+		AutoPauseMinWeight:           b.config.AutoPauseMinWeight,
+		EmptySpoolAutoArchive:        b.config.EmptySpoolAutoArchive,
+		LowFilamentCheck:             b.config.LowFilamentCheck,
+		FilamentMismatchCheck:        b.config.FilamentMismatchCheck,
+		ToolheadRuleCheck:            b.config.ToolheadRuleCheck,
+		BarcodeLookupURL:             b.config.BarcodeLookupURL,
+		RunoutPredictionEnabled:      b.config.RunoutPredictionEnabled,
+		ActiveSpoolEstimates:         b.config.ActiveSpoolEstimates,
+		RunoutDetectionEnabled:       b.config.RunoutDetectionEnabled,
+		NotificationChannels:         append([]NotificationChannel(nil), b.config.NotificationChannels...),
+		OutgoingWebhooks:             append([]OutgoingWebhook(nil), b.config.OutgoingWebhooks...),
+		SpoolmanInstances:            append([]SpoolmanInstance(nil), b.config.SpoolmanInstances...),
+		IdleSpoolReminderDays:        b.config.IdleSpoolReminderDays,
+		IdleSpoolReturnLocation:      b.config.IdleSpoolReturnLocation,
+		ExternalURL:                  b.config.ExternalURL,
+		ClockDriftThreshold:          b.config.ClockDriftThreshold,
+		MQTTBroker:                   b.config.MQTTBroker,
+		MQTTUsername:                 b.config.MQTTUsername,
+		MQTTPassword:                 b.config.MQTTPassword,
+		MQTTTopicPrefix:              b.config.MQTTTopicPrefix,
+		MQTTDiscoveryPrefix:          b.config.MQTTDiscoveryPrefix,
+		EditableSpoolFields:          append([]string(nil), b.config.EditableSpoolFields...),
+		SpoolOwnerField:              b.config.SpoolOwnerField,
+		SpoolLowStockField:           b.config.SpoolLowStockField,
+		Scales:                       append([]ScaleConfig(nil), b.config.Scales...),
+		ScaleDiscrepancyThreshold:    b.config.ScaleDiscrepancyThreshold,
+		EnvironmentSensors:           append([]EnvironmentSensor(nil), b.config.EnvironmentSensors...),
+		DryStorageHumidity:           b.config.DryStorageHumidity,
+		LogLevel:                     b.config.LogLevel,
+		LogFormat:                    b.config.LogFormat,
+		LogModuleLevels:              b.config.LogModuleLevels,
+		LogBufferSize:                b.config.LogBufferSize,
+		EstimatedFlowRate:            b.config.EstimatedFlowRate,
+		DefaultFilamentDensity:       b.config.DefaultFilamentDensity,
+		DefaultFilamentDiameter:      b.config.DefaultFilamentDiameter,
+		BasePath:                     b.config.BasePath,
+		TLSCertFile:                  b.config.TLSCertFile,
+		TLSKeyFile:                   b.config.TLSKeyFile,
+		TLSACMEDomains:               b.config.TLSACMEDomains,
+		TLSACMEEmail:                 b.config.TLSACMEEmail,
+		NFCSessionTimeout:            b.config.NFCSessionTimeout,
+		SpoolmanWriteDelay:           b.config.SpoolmanWriteDelay,
+		CombineNotifications:         b.config.CombineNotifications,
+		SpoolmanProxyEnabled:         b.config.SpoolmanProxyEnabled,
+		WasteSpoolField:              b.config.WasteSpoolField,
+		BackupDir:                    b.config.BackupDir,
+		BackupRetention:              b.config.BackupRetention,
+		ReadinessSpoolmanWindow:      b.config.ReadinessSpoolmanWindow,
+		PrintProcessingWorkers:       b.config.PrintProcessingWorkers,
+		ScheduledJobs:                make(map[string]ScheduledJobSettings, len(b.config.ScheduledJobs)),
 		Printers:                     make(map[string]PrinterConfig),
 	}
 
@@ -674,6 +1346,9 @@ func (b *FilamentBridge) GetConfigSnapshot() *Config {
 	for id, printer := range b.config.Printers {
 		configCopy.Printers[id] = printer
 	}
+	for name, settings := range b.config.ScheduledJobs {
+		configCopy.ScheduledJobs[name] = settings
+	}
 
 	return configCopy
 }
@@ -686,12 +1361,15 @@ func (b *FilamentBridge) ReloadConfig() error {
 		return fmt.Errorf("failed to reload config: %w", err)
 	}
 
+	configureLogging(config)
+
 	// Only lock briefly to swap the config pointer and recreate SpoolmanClient
 	b.mutex.Lock()
 	b.config = config
 	if config.SpoolmanURL != "" {
-		b.spoolman = NewSpoolmanClient(config.SpoolmanURL, config.SpoolmanTimeout, config.SpoolmanUsername, config.SpoolmanPassword)
+		b.spoolman = NewSpoolmanClient(config.SpoolmanURL, config.SpoolmanTimeout, config.SpoolmanUsername, config.SpoolmanPassword, config.SpoolmanInsecureSkipVerify)
 	}
+	b.spoolmanInstances = newSpoolmanInstanceClients(config)
 	b.mutex.Unlock()
 
 	return nil
@@ -711,11 +1389,14 @@ func (b *FilamentBridge) IsFirstRun() (bool, error) {
 
 // UpdateConfig updates the bridge configuration
 func (b *FilamentBridge) UpdateConfig(config *Config) error {
+	configureLogging(config)
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
 	b.config = config
-	b.spoolman = NewSpoolmanClient(config.SpoolmanURL, config.SpoolmanTimeout, config.SpoolmanUsername, config.SpoolmanPassword)
+	b.spoolman = NewSpoolmanClient(config.SpoolmanURL, config.SpoolmanTimeout, config.SpoolmanUsername, config.SpoolmanPassword, config.SpoolmanInsecureSkipVerify)
+	b.spoolmanInstances = newSpoolmanInstanceClients(config)
 
 	return nil
 }
@@ -744,6 +1425,7 @@ func (b *FilamentBridge) GetToolheadMapping(printerName string, toolheadID int)
 // SetToolheadMapping maps a spool to a specific toolhead
 func (b *FilamentBridge) SetToolheadMapping(printerName string, toolheadID int, spoolID int) error {
 	b.mutex.Lock()
+	instance := printerSpoolmanInstance(b.config, printerName)
 
 	// Get the previous spool ID before replacing it (for auto-assignment feature)
 	var previousSpoolID int
@@ -757,6 +1439,13 @@ func (b *FilamentBridge) SetToolheadMapping(printerName string, toolheadID int,
 	}
 	// If no previous mapping exists, previousSpoolID will be 0
 
+	// Shared spools may be on any number of toolheads
+	shared, err := b.isSpoolShared(instance, spoolID)
+	if err != nil {
+		b.mutex.Unlock()
+		return err
+	}
+
 	// Check if this spool is already assigned to a different toolhead
 	rows, err := b.db.Query(
 		"SELECT printer_name, toolhead_id FROM toolhead_mappings WHERE spool_id = ? AND NOT (printer_name = ? AND toolhead_id = ?)",
@@ -768,16 +1457,20 @@ func (b *FilamentBridge) SetToolheadMapping(printerName string, toolheadID int,
 	}
 	defer rows.Close()
 
-	// If we find any rows, this spool is already assigned elsewhere
-	if rows.Next() {
+	// If we find any rows, this spool is already assigned elsewhere. The same ID on a printer
+	// using another Spoolman instance is a different spool.
+	for rows.Next() {
 		var existingPrinterName string
 		var existingToolheadID int
 		if err := rows.Scan(&existingPrinterName, &existingToolheadID); err != nil {
 			b.mutex.Unlock()
 			return fmt.Errorf("failed to scan existing assignment: %w", err)
 		}
+		if shared || printerSpoolmanInstance(b.config, existingPrinterName) != instance {
+			continue
+		}
 		b.mutex.Unlock()
-		return fmt.Errorf("spool %d is already assigned to %s toolhead %d", spoolID, existingPrinterName, existingToolheadID)
+		return newCodedError(ErrCodeSpoolAlreadyAssigned, "spool %d is already assigned to %s toolhead %d; share it to map it to both", spoolID, existingPrinterName, existingToolheadID)
 	}
 
 	_, err = b.db.Exec(
@@ -789,47 +1482,61 @@ func (b *FilamentBridge) SetToolheadMapping(printerName string, toolheadID int,
 		return fmt.Errorf("failed to set toolhead mapping: %w", err)
 	}
 
-	log.Printf("Mapped %s toolhead %d to spool %d", printerName, toolheadID, spoolID)
+	bridgeLog.Info("Mapped spool to toolhead", "printer", printerName, "toolhead_id", toolheadID, "spool_id", spoolID)
+
+	// Unlock before potentially calling AssignSpoolToLocation (which may need locks)
+	b.mutex.Unlock()
+
+	b.emitSpoolAssigned(printerName, toolheadID, spoolID, previousSpoolID)
+	b.logSpoolEvents(b.mappingEvents(printerName, map[int]int{toolheadID: spoolID}, map[int]int{toolheadID: previousSpoolID}, "")...)
+
+	if previousSpoolID > 0 && previousSpoolID != spoolID {
+		b.parkPreviousSpool(printerName, previousSpoolID)
+	}
+	return nil
+}
+
+// parkPreviousSpool moves a spool taken off a toolhead to the default location, when the
+// auto-assign feature is enabled. Failures are only logged so they never fail the mapping.
+func (b *FilamentBridge) parkPreviousSpool(printerName string, previousSpoolID int) {
+	// A shared spool still on another toolhead stays where it is
+	if toolheads, err := b.spoolToolheads(b.spoolmanInstanceOf(printerName), previousSpoolID); err != nil || len(toolheads) > 0 {
+		return
+	}
 
-	// Check if auto-assign feature is enabled and we have a previous spool to assign
+	// Check if auto-assign feature is enabled
 	enabled, err := b.GetAutoAssignPreviousSpoolEnabled()
 	if err != nil {
-		log.Printf("Warning: Failed to check auto-assign previous spool setting: %v", err)
-		b.mutex.Unlock()
-		return nil // Don't fail the assignment if we can't check the setting
+		bridgeLog.Warn("Failed to check auto-assign previous spool setting", "error", err)
+		return
+	}
+	if !enabled {
+		return
 	}
 
-	// Unlock before potentially calling AssignSpoolToLocation (which may need locks)
-	b.mutex.Unlock()
+	// Get the configured default location
+	locationName, err := b.GetAutoAssignPreviousSpoolLocation()
+	if err != nil {
+		bridgeLog.Warn("Failed to get auto-assign previous spool location setting", "error", err)
+		return
+	}
 
-	if enabled && previousSpoolID > 0 && previousSpoolID != spoolID {
-		// Get the configured default location
-		locationName, err := b.GetAutoAssignPreviousSpoolLocation()
-		if err != nil {
-			log.Printf("Warning: Failed to get auto-assign previous spool location setting: %v", err)
-			return nil // Don't fail the assignment
+	if locationName != "" {
+		// Verify the location exists in Spoolman
+		location, err := b.spoolmanFor(printerName).FindLocationByName(locationName)
+		if err != nil || location == nil {
+			bridgeLog.Warn("Auto-assign previous spool location does not exist, skipping auto-assignment", "location", locationName, "spool_id", previousSpoolID)
+			return
 		}
 
-		if locationName != "" {
-			// Verify the location exists in Spoolman
-			location, err := b.spoolman.FindLocationByName(locationName)
-			if err != nil || location == nil {
-				log.Printf("Warning: Auto-assign previous spool location '%s' does not exist, skipping auto-assignment of spool %d", locationName, previousSpoolID)
-				return nil // Don't fail the assignment
-			}
-
-			// Assign the previous spool to the default location
-			// Use isPrinterLocation = false since this is a storage location
-			if err := b.AssignSpoolToLocation(previousSpoolID, "", 0, locationName, false); err != nil {
-				log.Printf("Warning: Failed to auto-assign previous spool %d to location '%s': %v", previousSpoolID, locationName, err)
-				// Don't fail the original assignment if auto-assignment fails
-			} else {
-				log.Printf("Auto-assigned previous spool %d to location '%s'", previousSpoolID, locationName)
-			}
+		// Assign the previous spool to the default location
+		// Use isPrinterLocation = false since this is a storage location
+		if err := b.AssignSpoolToLocation(previousSpoolID, printerName, 0, locationName, false); err != nil {
+			bridgeLog.Warn("Failed to auto-assign previous spool to location", "spool_id", previousSpoolID, "location", locationName, "error", err)
+		} else {
+			bridgeLog.Info("Auto-assigned previous spool to location", "spool_id", previousSpoolID, "location", locationName)
 		}
 	}
-
-	return nil
 }
 
 // GetToolheadMappings gets all toolhead mappings for a printer
@@ -898,22 +1605,34 @@ func (b *FilamentBridge) GetAllToolheadMappings() (map[string]map[int]ToolheadMa
 // UnmapToolhead removes a spool mapping from a toolhead
 func (b *FilamentBridge) UnmapToolhead(printerName string, toolheadID int) error {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	var spoolID int
+	if err := b.db.QueryRow(
+		"SELECT spool_id FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
+		printerName, toolheadID,
+	).Scan(&spoolID); err != nil && err != sql.ErrNoRows {
+		b.mutex.Unlock()
+		return fmt.Errorf("failed to get spool mapping: %w", err)
+	}
 
 	_, err := b.db.Exec(
 		"DELETE FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
 		printerName, toolheadID,
 	)
+	b.mutex.Unlock()
 	if err != nil {
 		return fmt.Errorf("failed to unmap toolhead: %w", err)
 	}
 
-	log.Printf("Unmapped %s toolhead %d", printerName, toolheadID)
+	bridgeLog.Info("Unmapped toolhead", "printer", printerName, "toolhead_id", toolheadID)
+	b.logSpoolEvents(b.mappingEvents(printerName, map[int]int{toolheadID: 0}, map[int]int{toolheadID: spoolID}, "")...)
 	return nil
 }
 
 // LogPrintUsage logs filament usage for a print job
-func (b *FilamentBridge) LogPrintUsage(printerName string, toolheadID int, spoolID int, filamentUsed float64, jobName string) error {
+func (b *FilamentBridge) LogPrintUsage(printerName string, toolheadID int, spoolID int, filamentUsed, waste float64, jobName, jobDisplayName, source string) error {
+	// Priced before locking, since it asks Spoolman
+	cost := b.usageCost(printerName, spoolID, filamentUsed)
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
@@ -926,8 +1645,8 @@ func (b *FilamentBridge) LogPrintUsage(printerName string, toolheadID int, spool
 	}
 
 	_, err := b.db.Exec(
-		"INSERT INTO print_history (printer_name, toolhead_id, spool_id, filament_used, print_started, print_finished, job_name) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		printerName, toolheadID, spoolID, filamentUsed, printStarted, time.Now(), jobName,
+		"INSERT INTO print_history (printer_name, toolhead_id, spool_id, filament_used, print_started, print_finished, job_name, job_display_name, member, source, waste, cost) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		printerName, toolheadID, spoolID, filamentUsed, printStarted, time.Now(), jobName, displayFilename(jobName, jobDisplayName), b.toolheadMappedBy(printerName, toolheadID), source, waste, cost,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to log print usage: %w", err)
@@ -936,240 +1655,293 @@ func (b *FilamentBridge) LogPrintUsage(printerName string, toolheadID int, spool
 	return nil
 }
 
-// MonitorPrinters monitors all printers for print status changes
-func (b *FilamentBridge) MonitorPrinters() {
-	log.Printf("Monitoring printers at %s", time.Now().Format(time.RFC3339))
-
-	// Get a safe snapshot of the config to prevent iteration issues
-	configSnapshot := b.GetConfigSnapshot()
-	if configSnapshot == nil || len(configSnapshot.Printers) == 0 {
-		log.Printf("No printers configured - skipping monitoring")
-		return
-	}
-
-	// Monitor each printer using PrusaLink
-	for printerID, printerConfig := range configSnapshot.Printers {
-		if printerID == "no_printers" {
-			continue // Skip placeholder
-		}
-		go func(printerID string, config PrinterConfig) {
-			if err := b.monitorPrusaLink(printerID, config); err != nil {
-				log.Printf("Error monitoring printer %s (%s): %v", config.IPAddress, printerID, err)
-			}
-		}(printerID, printerConfig)
+// toolheadMappedBy returns the member who mapped a toolhead's spool, used to tag its prints,
+// or "" when nobody did
+func (b *FilamentBridge) toolheadMappedBy(printerName string, toolheadID int) string {
+	var member string
+	if err := b.db.QueryRow(
+		"SELECT COALESCE(mapped_by, '') FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
+		printerName, toolheadID,
+	).Scan(&member); err != nil && err != sql.ErrNoRows {
+		bridgeLog.Warn("Failed to look up mapping member", "printer", printerName, "toolhead_id", toolheadID, "error", err)
 	}
+	return member
 }
 
-// monitorPrusaLink monitors a single printer using PrusaLink API
-func (b *FilamentBridge) monitorPrusaLink(printerID string, config PrinterConfig) error {
-	log.Printf("Starting monitoring for printer %s (%s) at %s", printerID, config.IPAddress, config.Name)
-	client := NewPrusaLinkClient(config.IPAddress, config.APIKey, b.config.PrusaLinkTimeout, b.config.PrusaLinkFileDownloadTimeout)
-
-	status, err := client.GetStatus()
-	if err != nil {
-		log.Printf("Warning: Failed to get printer status from %s (%s): %v", config.IPAddress, printerID, err)
+// monitorPrusaLink monitors a single printer using PrusaLink API. A finished print is processed
+// before it returns; if ctx is cancelled meanwhile, the print is saved to be processed on the
+// next start.
+func (b *FilamentBridge) monitorPrusaLink(ctx context.Context, printerID string, config PrinterConfig) error {
+	monitorLog.Debug("Checking printer", "printer_id", printerID, "address", config.IPAddress, "printer", config.Name)
+
+	// Status and job come from PrusaLink or Prusa Connect depending on printer config
+	status, jobInfo, err := b.getPrinterStatusAndJob(config)
+	if status == nil {
+		monitorLog.Warn("Failed to get printer status", "printer_id", printerID, "address", config.IPAddress, "error", err)
+		b.markPrinterOffline(printerID)
+		b.recordPoll(printerID, false)
 		return nil // Don't fail the entire monitoring cycle for one printer
 	}
+	b.markPrinterOnline(printerID)
+	b.recordPoll(printerID, true)
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		b.recordClockDrift(printerID, config, status.ClockDrift, snapshot.ClockDriftThreshold)
+	}
 
-	jobInfo, err := client.GetJobInfo()
 	if err != nil {
-		log.Printf("Warning: Failed to get job info from %s (%s): %v", config.IPAddress, printerID, err)
+		monitorLog.Warn("Failed to get job info", "printer_id", printerID, "address", config.IPAddress, "error", err)
 		// Continue with status-only monitoring if job info fails
 		jobInfo = &PrusaLinkJob{}
 	}
 
 	currentState := status.Printer.State
 	jobName := "No active job"
-	currentJobFilename := ""
+	currentJobFilename := jobFilePath(jobInfo)
 	if jobInfo.File.Name != "" {
-		jobName = jobInfo.File.DisplayName // Use display name for better readability
-		// Use the download path directly from refs - it's already in the correct format
-		if jobInfo.File.Refs.Download != "" {
-			currentJobFilename = strings.TrimPrefix(jobInfo.File.Refs.Download, "/")
-		} else {
-			// Fallback: construct the path manually
-			storage := strings.TrimPrefix(jobInfo.File.Path, "/")
-			currentJobFilename = storage + "/" + jobInfo.File.Name
-		}
+		jobName = displayFilename(jobInfo.File.Name, jobInfo.File.DisplayName) // Use display name for better readability
 	}
 
 	// Check if print just finished - minimize lock scope
 	b.mutex.RLock()
 	wasPrinting := b.wasPrinting[printerID]
 	storedJobFile := b.currentJobFile[printerID]
+	storedJobID := b.currentJobID[printerID]
+	storedJobDisplay := b.currentJobName[printerID]
+	storedTelemetry := b.jobTelemetry[printerID]
 	b.mutex.RUnlock()
 
 	// Debug logging for all printers
-	log.Printf("Printer %s (%s): state=%s, wasPrinting=%v, job=%s, stored_file=%s",
-		config.IPAddress, printerID, currentState, wasPrinting, jobName, storedJobFile)
+	monitorLog.Debug("Printer polled", "printer_id", printerID, "address", config.IPAddress, "state", currentState,
+		"was_printing", wasPrinting, "job", jobName, "job_id", jobInfo.ID, "stored_file", storedJobFile, "stored_job_id", storedJobID)
+
+	b.runout.Update(printerID, config, currentState, jobInfo)
+	// Printers stop for attention when the filament sensor detects a runout
+	if currentState == StateAttention && wasPrinting {
+		runoutJob := storedJobDisplay
+		if runoutJob == "" {
+			runoutJob = jobName
+		}
+		b.detectFilamentRunout(printerID, config, status, runoutJob)
+	}
+
+	// A reprint queued right after a finished job can go straight back to PRINTING between
+	// polls, so a different job ID also means the previous print finished
+	jobReplaced := wasPrinting && currentState == StatePrinting &&
+		storedJobID != 0 && jobInfo.ID != 0 && jobInfo.ID != storedJobID
 
 	// Check if print just finished
-	if (currentState == StateIdle || currentState == StateFinished) && wasPrinting {
+	if ((currentState == StateIdle || currentState == StateFinished) && wasPrinting) || jobReplaced {
 		// Use stored filename (should be available since we stored it when printing started)
 		filenameToUse := storedJobFile
-		if filenameToUse == "" {
-			log.Printf("Warning: No stored filename for %s (%s), using current job filename: %s",
-				config.IPAddress, printerID, currentJobFilename)
+		displayNameToUse := storedJobDisplay
+		if filenameToUse == "" && !jobReplaced {
+			monitorLog.Warn("No stored filename, using current job filename",
+				"printer_id", printerID, "address", config.IPAddress, "job", currentJobFilename)
 			filenameToUse = currentJobFilename
+			displayNameToUse = jobName
 		}
 
-		log.Printf("🎉 Print finished detected for %s (%s): %s (state: %s, file: %s)",
-			config.IPAddress, printerID, jobName, currentState, filenameToUse)
+		monitorLog.Info("Print finished detected", "printer_id", printerID, "address", config.IPAddress,
+			"job", jobName, "state", currentState, "file", filenameToUse)
 
-		// Mark as processing to prevent filename from being cleared
+		// Mark as processing to prevent filename from being cleared. Polling and push
+		// events can both observe the same transition, so only the first one proceeds.
 		b.mutex.Lock()
-		b.wasPrinting[printerID] = false
+		if b.processingPrints[printerID] || !b.wasPrinting[printerID] || b.currentJobID[printerID] != storedJobID {
+			b.mutex.Unlock()
+			return nil
+		}
+		b.wasPrinting[printerID] = jobReplaced
 		b.processingPrints[printerID] = true
-		b.mutex.Unlock()
-
-		// Now process the print (this takes a long time)
-		err := b.handlePrusaLinkPrintFinished(config, filenameToUse)
-
-		// Clear processing flag and filename after completion
-		b.mutex.Lock()
-		b.processingPrints[printerID] = false
-		if err == nil {
-			b.currentJobFile[printerID] = ""
+		if jobReplaced {
+			// Start tracking the new job right away; the finished one is processed below
+			b.currentJobFile[printerID] = currentJobFilename
+			b.currentJobID[printerID] = jobInfo.ID
+			b.currentJobName[printerID] = jobName
+			b.jobTelemetry[printerID] = newJobTelemetry(jobInfo)
+			monitorLog.Info("Stored job filename", "printer_id", printerID, "address", config.IPAddress, "job", currentJobFilename, "job_id", jobInfo.ID)
 		}
 		b.mutex.Unlock()
 
-		if err != nil {
-			log.Printf("Error handling PrusaLink print finished: %v", err)
+		if jobReplaced {
+			go b.checkSpoolsOnPrintStart(config, jobInfo.ID, currentJobFilename, jobName)
 		}
+
+		// Queue the print for processing (this takes a long time) so polling carries on
+		b.queueTrackedPrint(ctx, config, unfinishedPrint{
+			PrinterID:   printerID,
+			Filename:    filenameToUse,
+			DisplayName: displayNameToUse,
+			JobID:       storedJobID,
+			Telemetry:   storedTelemetry,
+			FinishedAt:  time.Now(),
+		}, jobReplaced)
 	} else {
 		// Update state tracking - minimize lock scope
 		b.mutex.Lock()
 		defer b.mutex.Unlock()
 
-		// Store the current job filename when printing starts (only if not already stored)
-		if currentState == StatePrinting && currentJobFilename != "" && storedJobFile == "" {
-			b.currentJobFile[printerID] = currentJobFilename
-			log.Printf("📁 Stored job filename for %s (%s): %s", config.IPAddress, printerID, currentJobFilename)
+		// Store the current job when printing starts. A job ID different from the stored one
+		// is a new print even if the filename matches (e.g. a reprint of the same file).
+		if currentState == StatePrinting && currentJobFilename != "" {
+			newJobID := jobInfo.ID != 0 && jobInfo.ID != b.currentJobID[printerID]
+			if b.currentJobFile[printerID] == "" || newJobID {
+				b.currentJobFile[printerID] = currentJobFilename
+				b.currentJobID[printerID] = jobInfo.ID
+				b.currentJobName[printerID] = jobName
+				monitorLog.Info("Stored job filename", "printer_id", printerID, "address", config.IPAddress, "job", currentJobFilename, "job_id", jobInfo.ID)
+
+				// A new print has started - check its spools before it runs for hours
+				go b.checkSpoolsOnPrintStart(config, jobInfo.ID, currentJobFilename, jobName)
+			}
+		}
+
+		// Keep the latest telemetry of the stored job in case its G-code can't be downloaded later
+		if currentState == StatePrinting && (jobInfo.ID == 0 || jobInfo.ID == b.currentJobID[printerID]) {
+			b.jobTelemetry[printerID] = newJobTelemetry(jobInfo)
 		}
 
 		// Update wasPrinting flag for NEXT cycle
 		b.wasPrinting[printerID] = currentState == StatePrinting
 
-		// Clear stored filename when print finishes (but only if not currently processing)
+		// Clear stored job when print finishes (but only if not currently processing)
 		if (currentState == StateIdle || currentState == StateFinished) && !b.processingPrints[printerID] {
 			b.currentJobFile[printerID] = ""
+			b.currentJobID[printerID] = 0
+			b.currentJobName[printerID] = ""
+			delete(b.jobTelemetry, printerID)
 		}
 	}
 
 	return nil
 }
 
-// handlePrusaLinkPrintFinished handles when a print job finishes via PrusaLink
-func (b *FilamentBridge) handlePrusaLinkPrintFinished(config PrinterConfig, filename string) error {
-	log.Printf("Print finished via PrusaLink (%s): %s", config.IPAddress, filename)
+// finishTrackedPrint processes a finished print once the caller has set processingPrints for
+// its printer, then clears the flag and, unless a new job took over, the stored job. A print
+// interrupted by ctx is saved to be processed after the restart.
+func (b *FilamentBridge) finishTrackedPrint(ctx context.Context, config PrinterConfig, print unfinishedPrint, jobReplaced bool) {
+	printerID := print.PrinterID
+	err := b.handlePrusaLinkPrintFinished(ctx, config, print)
+	interrupted := err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err())
+	if interrupted {
+		print.Finished = true
+		b.saveUnfinishedPrint(print)
+	}
+
+	// Clear processing flag and filename after completion, unless a new job took over
+	b.mutex.Lock()
+	b.processingPrints[printerID] = false
+	if err == nil && !jobReplaced && b.currentJobID[printerID] == print.JobID {
+		b.currentJobFile[printerID] = ""
+		b.currentJobID[printerID] = 0
+		b.currentJobName[printerID] = ""
+		delete(b.jobTelemetry, printerID)
+	}
+	b.mutex.Unlock()
+
+	if err != nil && !interrupted {
+		monitorLog.Error("Error handling PrusaLink print finished", "printer_id", printerID, "error", err)
+	}
+}
+
+// handlePrusaLinkPrintFinished handles when a print job finishes via PrusaLink. If the G-code
+// can't be downloaded, usage is estimated from the job's telemetry instead. Cancelling ctx
+// stops the download and returns its error without recording anything; once Spoolman is being
+// updated, processing runs to the end so no toolhead is counted twice on a retry. A print
+// that was already processed, e.g. by another FilaBridge, is skipped.
+func (b *FilamentBridge) handlePrusaLinkPrintFinished(ctx context.Context, config PrinterConfig, print unfinishedPrint) error {
+	filename, displayName, telemetry := print.Filename, print.DisplayName, print.Telemetry
+	monitorLog.Info("Print finished via PrusaLink", "printer", config.Name, "address", config.IPAddress, "job", filename)
 
 	printerName := resolvePrinterName(config)
 
 	// Create PrusaLink client for this printer
-	prusaClient := NewPrusaLinkClient(config.IPAddress, config.APIKey, b.config.PrusaLinkTimeout, b.config.PrusaLinkFileDownloadTimeout)
+	prusaClient := config.prusaLinkClient(b.config)
+	_, downloadTimeout := config.prusaLinkTimeouts(b.config)
+
+	// Failures keep the telemetry, so a retry can still fall back to an estimate
+	retry := &printErrorRetry{Telemetry: telemetry, JobID: print.JobID, FinishedAt: print.FinishedAt}
+
+	// G-code files are only downloadable from the printer's local PrusaLink API
+	if config.IPAddress == "" {
+		errorMsg := "printer has no local address; G-code cannot be downloaded to compute filament usage"
+		b.addRetryablePrintError(printerName, filename, displayName, errorMsg, retry)
+		return fmt.Errorf("%s", errorMsg)
+	}
 
 	// Use the filename parameter (stored when print started)
 	if filename == "" {
 		errorMsg := "no filename available for print processing"
-		b.addPrintError(printerName, "unknown", errorMsg)
+		b.addPrintError(printerName, "unknown", displayName, errorMsg)
 		return fmt.Errorf("%s", errorMsg)
 	}
 
 	// Download and parse the G-code file (.gcode or .bgcode) for filament usage
-	log.Printf("Analyzing G-code file for filament usage: %s", filename)
+	monitorLog.Info("Analyzing G-code file for filament usage", "printer", config.Name, "job", filename)
 
 	// Download with retry logic
-	gcodeContent, err := prusaClient.GetGcodeFileWithRetry(filename, b.config.PrusaLinkFileDownloadTimeout)
+	gcodeContent, err := prusaClient.GetGcodeFileWithRetry(ctx, filename, downloadTimeout)
 	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
 		errorMsg := fmt.Sprintf("failed to download G-code file after retries: %v", err)
-		b.addPrintError(printerName, filename, errorMsg)
-		return fmt.Errorf("%s", errorMsg)
+		estimated, estimateErr := b.estimateFilamentUsage(printerName, config, telemetry)
+		if estimateErr != nil {
+			b.addRetryablePrintError(printerName, filename, displayName, fmt.Sprintf("%s; usage could not be estimated: %v", errorMsg, estimateErr), retry)
+			return fmt.Errorf("%s", errorMsg)
+		}
+
+		monitorLog.Warn("G-code download failed, recording usage estimated from job telemetry",
+			"printer", config.Name, "job", filename, "usage", estimated, "error", err)
+		if !b.claimPrint(print) {
+			return nil
+		}
+		return b.processFilamentUsage(printerName, estimated, nil, nil, filename, displayName, HistorySourceEstimated)
 	}
 
-	// Parse the downloaded file
-	filamentUsage, err := prusaClient.ParseGcodeFilamentUsage(gcodeContent)
+	// Parse the downloaded file with the strategy for the printer's G-code flavor
+	filamentUsage, err := b.gcodeFilamentUsage(printerName, config, gcodeContent)
 	if err != nil {
 		errorMsg := fmt.Sprintf("failed to parse G-code for filament usage: %v", err)
-		b.addPrintError(printerName, filename, errorMsg)
+		b.addRetryablePrintError(printerName, filename, displayName, errorMsg, retry)
 		return fmt.Errorf("%s", errorMsg)
 	}
 
 	// Check if we got any filament usage data
 	if len(filamentUsage) == 0 {
 		errorMsg := "no filament usage data found in G-code file"
-		b.addPrintError(printerName, filename, errorMsg)
+		b.addRetryablePrintError(printerName, filename, displayName, errorMsg, retry)
 		return fmt.Errorf("%s", errorMsg)
 	}
 
-	log.Printf("Successfully parsed G-code file for filament usage: %+v", filamentUsage)
+	waste := ParseGcodeWaste(gcodeContent, filamentUsage)
+	monitorLog.Info("Parsed G-code file for filament usage", "printer", config.Name, "job", filename, "usage", filamentUsage, "waste", waste)
 
 	// Process filament usage using helper function
-	if err := b.processFilamentUsage(printerName, filamentUsage, filename); err != nil {
-		log.Printf("Error processing filament usage: %v", err)
+	if !b.claimPrint(print) {
+		return nil
+	}
+	if err := b.processFilamentUsage(printerName, filamentUsage, nil, waste, filename, displayName, HistorySourcePrint); err != nil {
+		monitorLog.Error("Error processing filament usage", "printer", config.Name, "job", filename, "error", err)
 		return err
 	}
 
 	return nil
 }
 
-// GetPrintErrors returns all unacknowledged print errors
-func (b *FilamentBridge) GetPrintErrors() []PrintError {
-	b.errorMutex.RLock()
-	defer b.errorMutex.RUnlock()
-
-	var errors []PrintError
-	for _, err := range b.printErrors {
-		if !err.Acknowledged {
-			errors = append(errors, err)
-		}
-	}
-	return errors
-}
-
-// AcknowledgePrintError marks a print error as acknowledged
-func (b *FilamentBridge) AcknowledgePrintError(errorID string) error {
-	b.errorMutex.Lock()
-	defer b.errorMutex.Unlock()
-
-	if err, exists := b.printErrors[errorID]; exists {
-		err.Acknowledged = true
-		b.printErrors[errorID] = err
-		return nil
-	}
-	return fmt.Errorf("print error not found: %s", errorID)
-}
-
-// sanitizeErrorID replaces problematic characters in error IDs to make them URL-safe
+// sanitizeErrorID replaces problematic characters in error IDs to make them URL-safe.
+// Anything outside ASCII letters, digits, '-', '_' and '.' (slashes, spaces, UTF-8
+// and 8.3 '~' names) becomes an underscore.
 func sanitizeErrorID(s string) string {
-	// Replace forward slashes with underscores
-	s = strings.ReplaceAll(s, "/", "_")
-	// Replace spaces with underscores
-	s = strings.ReplaceAll(s, " ", "_")
-	// Replace backslashes with underscores
-	s = strings.ReplaceAll(s, "\\", "_")
-	return s
-}
-
-// addPrintError adds a new print error
-func (b *FilamentBridge) addPrintError(printerName, filename, errorMsg string) {
-	b.errorMutex.Lock()
-	defer b.errorMutex.Unlock()
-
-	// Sanitize printer name and filename to ensure URL-safe error IDs
-	sanitizedPrinterName := sanitizeErrorID(printerName)
-	sanitizedFilename := sanitizeErrorID(filename)
-	errorID := fmt.Sprintf("%s_%s_%d", sanitizedPrinterName, sanitizedFilename, time.Now().Unix())
-	b.printErrors[errorID] = PrintError{
-		ID:           errorID,
-		PrinterName:  printerName,
-		Filename:     filename,
-		Error:        errorMsg,
-		Timestamp:    time.Now(),
-		Acknowledged: false,
-	}
-
-	log.Printf("⚠️  Print processing failed for %s (%s): %s - Manual Spoolman update required",
-		printerName, filename, errorMsg)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
 }
 
 // GetStatus gets current status of all printers and mappings
@@ -1198,29 +1970,45 @@ func (b *FilamentBridge) GetStatus() (*PrinterStatus, error) {
 				continue // Skip placeholder
 			}
 
-			client := NewPrusaLinkClient(printerConfig.IPAddress, printerConfig.APIKey, b.config.PrusaLinkTimeout, b.config.PrusaLinkFileDownloadTimeout)
-
 			// Use the configured printer name, not the hostname from PrusaLink
 			printerName := printerConfig.Name
 
+			// Fixture printers have no device to ask
+			if isFixturePrinter(printerID) {
+				data := PrinterData{Name: printerName, State: StateIdle}
+				b.applyPollTiming(printerID, configSnapshot, &data)
+				status.Printers[printerID] = data
+				continue
+			}
+
 			// Get current status
-			printerStatus, err := client.GetStatus()
-			if err != nil {
+			printerStatus, job, err := b.getPrinterStatusAndJob(printerConfig)
+			if printerStatus == nil {
 				// Enhanced error logging to help diagnose connection issues
 				// This is especially useful for DNS resolution problems with hostnames
-				log.Printf("Warning: Failed to get printer status from %s (%s - %s): %v",
-					printerConfig.IPAddress, printerID, printerName, err)
-				status.Printers[printerID] = PrinterData{
+				monitorLog.Warn("Failed to get printer status",
+					"printer_id", printerID, "printer", printerName, "address", printerConfig.IPAddress, "error", err)
+				data := PrinterData{
 					Name:  printerName,
 					State: StateOffline,
 				}
+				b.applyPollTiming(printerID, configSnapshot, &data)
+				status.Printers[printerID] = data
+				b.markPrinterOffline(printerID)
 				continue
 			}
+			b.markPrinterOnline(printerID)
 
-			status.Printers[printerID] = PrinterData{
+			data := PrinterData{
 				Name:  printerName,
 				State: printerStatus.Printer.State,
 			}
+			if printerStatus.ClockDrift != nil {
+				data.ClockDriftSeconds = printerStatus.ClockDrift.Seconds()
+			}
+			applyJobProgress(&data, printerStatus, job)
+			b.applyPollTiming(printerID, configSnapshot, &data)
+			status.Printers[printerID] = data
 		}
 	} else {
 		// No printers configured
@@ -1239,20 +2027,24 @@ func (b *FilamentBridge) GetStatus() (*PrinterStatus, error) {
 		printerName := printerConfig.Name
 		mappings, err := b.GetToolheadMappings(printerName)
 		if err != nil {
-			log.Printf("Error getting toolhead mappings for %s: %v", printerName, err)
+			bridgeLog.Error("Error getting toolhead mappings", "printer", printerName, "error", err)
 			mappings = make(map[int]ToolheadMapping)
 		}
+		shared, err := sharedSpoolIDs(b.db, printerSpoolmanInstance(configSnapshot, printerName))
+		if err != nil {
+			bridgeLog.Warn("Failed to get shared spools", "printer", printerName, "error", err)
+		}
 
 		// Get toolhead names for this printer
 		toolheadNames, err := b.GetAllToolheadNames(printerID)
 		if err != nil {
-			log.Printf("Warning: Failed to get toolhead names for printer %s: %v", printerID, err)
+			bridgeLog.Warn("Failed to get toolhead names", "printer_id", printerID, "error", err)
 			toolheadNames = make(map[int]string)
 		}
 
 		// Create enhanced mappings for ALL toolheads (including unmapped ones)
 		enhancedMappings := make(map[int]ToolheadMapping)
-		for toolheadID := 0; toolheadID < printerConfig.Toolheads; toolheadID++ {
+		for toolheadID := 0; toolheadID < printerConfig.SlotCount(); toolheadID++ {
 			// Get display name (custom or default)
 			var displayName string
 			if name, exists := toolheadNames[toolheadID]; exists {
@@ -1264,6 +2056,7 @@ func (b *FilamentBridge) GetStatus() (*PrinterStatus, error) {
 			// If this toolhead has a mapping, use it and add display name
 			if mapping, exists := mappings[toolheadID]; exists {
 				mapping.DisplayName = displayName
+				mapping.Shared = shared[mapping.SpoolID]
 				enhancedMappings[toolheadID] = mapping
 			} else {
 				// Create empty mapping with just display name for unmapped toolheads
@@ -1281,50 +2074,203 @@ func (b *FilamentBridge) GetStatus() (*PrinterStatus, error) {
 	return status, nil
 }
 
-// processFilamentUsage processes filament usage updates for all toolheads
-func (b *FilamentBridge) processFilamentUsage(printerName string, filamentUsage map[int]float64, jobName string) error {
+// processFilamentUsage processes filament usage updates for all toolheads, recording them in
+// the print history with the given source. filamentLengths are mm for toolheads whose usage
+// was only reported as a length, and waste is the part of each toolhead's usage that went to
+// purging; both may be nil.
+func (b *FilamentBridge) processFilamentUsage(printerName string, filamentUsage, filamentLengths, waste map[int]float64, jobName, jobDisplayName, source string) error {
+	var writeDelay time.Duration
+	var combineNotifications bool
+	var wasteField string
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		writeDelay = snapshot.SpoolmanWriteDelay
+		combineNotifications = snapshot.CombineNotifications
+		wasteField = snapshot.WasteSpoolField
+	}
+
+	// Lengths are converted with the filament of the spool they're charged to
+	convertedLengths := make(map[int]float64)
+	if len(filamentLengths) > 0 {
+		weights := make(map[int]float64, len(filamentUsage)+len(filamentLengths))
+		for toolheadID, usedWeight := range filamentUsage {
+			weights[toolheadID] = usedWeight
+		}
+		for toolheadID, length := range filamentLengths {
+			if weights[toolheadID] > 0 || length <= 0 {
+				continue
+			}
+			weights[toolheadID] = b.filamentLengthToWeight(printerName, toolheadID, length)
+			convertedLengths[toolheadID] = length
+			monitorLog.Info("Converted filament length to weight", "printer", printerName,
+				"toolhead_id", toolheadID, "length_mm", length, "grams", weights[toolheadID])
+		}
+		filamentUsage = weights
+	}
+
+	toolheadIDs := make([]int, 0, len(filamentUsage))
+	for toolheadID := range filamentUsage {
+		toolheadIDs = append(toolheadIDs, toolheadID)
+	}
+	sort.Ints(toolheadIDs)
+
+	var usageLines []string
+	var lowStock []Notification
+	var toolheadUsage []map[string]interface{} // For the print_completed event
+	var totalUsed float64                      // After correction factors, as charged to the spools
+	spoolmanWrites := 0
+
 	// Update Spoolman with filament usage for each toolhead
-	for toolheadID, usedWeight := range filamentUsage {
+	for _, toolheadID := range toolheadIDs {
+		usedWeight := filamentUsage[toolheadID]
 		if usedWeight <= 0 {
 			continue
 		}
+		wasteWeight := waste[toolheadID]
 
 		// Get the mapped spool for this toolhead
 		spoolID, err := b.GetToolheadMapping(printerName, toolheadID)
 		if err != nil {
-			log.Printf("Error getting toolhead mapping for %s toolhead %d: %v",
-				printerName, toolheadID, err)
+			monitorLog.Error("Error getting toolhead mapping",
+				"printer", printerName, "toolhead_id", toolheadID, "error", err)
+			totalUsed += usedWeight
 			continue
 		}
 
+		// Without a spool the usage is kept until someone assigns it to the one that was loaded
 		if spoolID == 0 {
-			log.Printf("No spool mapped to %s toolhead %d, skipping filament usage update",
-				printerName, toolheadID)
+			totalUsed += usedWeight
+			if err := b.recordUnattributedUsage(UnattributedUsage{
+				PrinterName:    printerName,
+				ToolheadID:     toolheadID,
+				FilamentUsed:   usedWeight,
+				FilamentLength: convertedLengths[toolheadID],
+				Waste:          wasteWeight,
+				JobName:        jobName,
+				JobDisplayName: jobDisplayName,
+				Source:         source,
+			}); err != nil {
+				monitorLog.Error("Error recording usage of unmapped toolhead", "printer", printerName, "toolhead_id", toolheadID, "error", err)
+				continue
+			}
+			monitorLog.Warn("No spool mapped, kept usage for assigning to a spool later",
+				"printer", printerName, "toolhead_id", toolheadID, "grams", usedWeight)
+			usageLines = append(usageLines, fmt.Sprintf("Toolhead %d: %.1fg not charged, no spool mapped", toolheadID, usedWeight))
 			continue
 		}
 
-		// Update Spoolman
-		if err := b.spoolman.UpdateSpoolUsage(spoolID, usedWeight); err != nil {
-			log.Printf("Error updating spool %d usage: %v", spoolID, err)
+		// Apply the material's correction factor for slicers that misreport usage
+		if factor := b.usageCorrectionFactor(printerName, spoolID); factor != 1 {
+			monitorLog.Info("Applying usage correction factor",
+				"spool_id", spoolID, "factor", factor, "grams", usedWeight, "corrected_grams", usedWeight*factor)
+			usedWeight *= factor
+			wasteWeight *= factor
+		}
+		totalUsed += usedWeight
+
+		// Pace back-to-back writes so a multi-tool job doesn't flood Spoolman
+		if spoolmanWrites > 0 && writeDelay > 0 {
+			time.Sleep(writeDelay)
+		}
+		spoolmanWrites++
+
+		// Update Spoolman, queueing the usage for a retry when it's unavailable
+		if err := b.spoolmanFor(printerName).UpdateSpoolUsage(spoolID, usedWeight); err != nil {
+			update := PendingUpdate{
+				PrinterName:    printerName,
+				ToolheadID:     toolheadID,
+				SpoolID:        spoolID,
+				FilamentUsed:   usedWeight,
+				Waste:          wasteWeight,
+				JobName:        jobName,
+				JobDisplayName: jobDisplayName,
+				Source:         source,
+				Member:         b.toolheadMappedBy(printerName, toolheadID),
+			}
+			if queueErr := b.queueSpoolUpdate(update, err); queueErr != nil {
+				monitorLog.Error("Error updating spool usage", "printer", printerName, "job", jobName, "spool_id", spoolID, "error", err, "queue_error", queueErr)
+				continue
+			}
+			monitorLog.Warn("Spoolman update failed, queued for retry", "printer", printerName, "job", jobName,
+				"toolhead_id", toolheadID, "spool_id", spoolID, "grams", usedWeight, "error", err)
+			usageLines = append(usageLines, fmt.Sprintf("Toolhead %d: %.1fg from spool %d (queued, Spoolman unavailable)", toolheadID, usedWeight, spoolID))
+			toolheadUsage = append(toolheadUsage, map[string]interface{}{
+				"toolhead_id": toolheadID, "spool_id": spoolID, "grams": usedWeight, "waste": wasteWeight, "queued": true,
+			})
 			continue
 		}
 
 		// Log the usage in our database
-		if err := b.LogPrintUsage(printerName, toolheadID, spoolID, usedWeight, jobName); err != nil {
-			log.Printf("Error logging print usage: %v", err)
+		if err := b.LogPrintUsage(printerName, toolheadID, spoolID, usedWeight, wasteWeight, jobName, jobDisplayName, source); err != nil {
+			monitorLog.Error("Error logging print usage", "printer", printerName, "job", jobName, "spool_id", spoolID, "error", err)
 		}
 
-		log.Printf("Updated spool %d: used %.2fg filament on %s toolhead %d",
-			spoolID, usedWeight, printerName, toolheadID)
+		if wasteField != "" && wasteWeight > 0 {
+			if err := b.addSpoolWaste(printerName, spoolID, wasteField, wasteWeight); err != nil {
+				monitorLog.Warn("Error recording spool purge waste", "spool_id", spoolID, "field", wasteField, "error", err)
+			}
+		}
+
+		monitorLog.Info("Updated spool usage", "printer", printerName, "job", jobName,
+			"toolhead_id", toolheadID, "spool_id", spoolID, "grams", usedWeight, "waste_grams", wasteWeight)
+		b.emitSpoolDeducted(printerName, toolheadID, spoolID, usedWeight, jobName, jobDisplayName, source)
+		toolheadUsage = append(toolheadUsage, map[string]interface{}{
+			"toolhead_id": toolheadID, "spool_id": spoolID, "grams": usedWeight, "waste": wasteWeight, "queued": false,
+		})
+
+		usageLine := fmt.Sprintf("Toolhead %d: %.1fg from spool %d", toolheadID, usedWeight, spoolID)
+		if wasteWeight > 0 {
+			usageLine += fmt.Sprintf(" (%.1fg purged)", wasteWeight)
+		}
+		usageLines = append(usageLines, usageLine)
+		if !combineNotifications {
+			b.notifyIfSpoolLow(printerName, spoolID, usedWeight)
+		} else if notification := b.spoolLowNotification(printerName, spoolID, usedWeight); notification != nil {
+			lowStock = append(lowStock, *notification)
+		}
+		b.handleSpoolEmptied(printerName, toolheadID, spoolID, usedWeight)
 	}
 
 	// Summary log
 	if len(filamentUsage) > 0 {
-		log.Printf("✅ Print completion processing finished for %s: processed %d toolheads", printerName, len(filamentUsage))
+		monitorLog.Info("Print completion processing finished", "printer", printerName, "job", jobName, "toolheads", len(filamentUsage))
+
+		estimatedSuffix := ""
+		if source == HistorySourceEstimated {
+			estimatedSuffix = " (estimated)"
+		}
+		message := fmt.Sprintf("%s used %.1fg of filament%s", displayFilename(jobName, jobDisplayName), totalUsed, estimatedSuffix)
+		if combineNotifications {
+			// One message covering every toolhead, with low stock warnings folded in
+			if len(usageLines) > 1 {
+				message += "\n" + strings.Join(usageLines, "\n")
+			}
+			for _, notification := range lowStock {
+				message += "\n" + notification.Message
+			}
+		}
+		notification := Notification{
+			Event:   NotificationEventPrintComplete,
+			Title:   fmt.Sprintf("Print complete on %s", printerName),
+			Message: message,
+		}
+		if len(lowStock) > 0 {
+			// Channels subscribed only to low stock warnings still get them
+			notification.Includes = []string{NotificationEventSpoolLow}
+		}
+		b.notifier.Notify(notification)
+		b.emitLifecycleEvent(LifecycleEventPrintCompleted, map[string]interface{}{
+			"printer_name":     printerName,
+			"job_name":         jobName,
+			"job_display_name": displayFilename(jobName, jobDisplayName),
+			"source":           source,
+			"total_grams":      totalUsed,
+			"toolheads":        toolheadUsage,
+		})
 	} else {
-		log.Printf("⚠️  No filament usage data processed for %s", printerName)
+		monitorLog.Warn("No filament usage data processed", "printer", printerName, "job", jobName)
 	}
 
+	b.releaseJobReservations(printerName, jobName, jobDisplayName)
 	return nil
 }
 
@@ -1335,7 +2281,7 @@ func (b *FilamentBridge) isVirtualPrinterToolheadLocation(name string) bool {
 	printerConfigs, err := b.GetAllPrinterConfigs()
 	if err != nil {
 		// If we can't get printer configs, assume it's not a virtual location
-		log.Printf("Warning: Could not get printer configurations to check virtual location: %v", err)
+		bridgeLog.Warn("Could not get printer configurations to check virtual location", "error", err)
 		return false
 	}
 
@@ -1344,11 +2290,11 @@ func (b *FilamentBridge) isVirtualPrinterToolheadLocation(name string) bool {
 		// Get toolhead names for this printer
 		toolheadNames, err := b.GetAllToolheadNames(printerID)
 		if err != nil {
-			log.Printf("Warning: Could not get toolhead names for printer %s: %v", printerID, err)
+			bridgeLog.Warn("Could not get toolhead names", "printer_id", printerID, "error", err)
 			toolheadNames = make(map[int]string)
 		}
 
-		for toolheadID := 0; toolheadID < printerConfig.Toolheads; toolheadID++ {
+		for toolheadID := 0; toolheadID < printerConfig.SlotCount(); toolheadID++ {
 			// Check default pattern
 			expectedNameDefault := fmt.Sprintf("%s - Toolhead %d", printerConfig.Name, toolheadID)
 			if name == expectedNameDefault {
@@ -1368,8 +2314,9 @@ func (b *FilamentBridge) isVirtualPrinterToolheadLocation(name string) bool {
 	return false
 }
 
-// Close closes the database connection
+// Close disconnects from MQTT and closes the database connection
 func (b *FilamentBridge) Close() error {
+	b.homeAssistant.Close()
 	if b.db != nil {
 		return b.db.Close()
 	}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+)
+
+// recordClockDrift stores the latest clock drift measured for a printer and logs a warning
+// when it crosses the configured threshold. Drift skews print durations, so a printer whose
+// clock is badly off should be fixed (usually by enabling NTP on the printer).
+func (b *FilamentBridge) recordClockDrift(printerID string, config PrinterConfig, drift *time.Duration, threshold time.Duration) {
+	if drift == nil {
+		return // Prusa Connect and firmware without a Date header don't report a clock
+	}
+
+	b.mutex.Lock()
+	previous, measured := b.clockDrift[printerID]
+	b.clockDrift[printerID] = *drift
+	b.mutex.Unlock()
+
+	if threshold <= 0 {
+		return
+	}
+
+	wasDrifting := measured && absDuration(previous) > threshold
+	isDrifting := absDuration(*drift) > threshold
+	switch {
+	case isDrifting && !wasDrifting:
+		monitorLog.Warn("Printer clock is off compared to the server; print durations may be inaccurate",
+			"printer", config.Name, "printer_id", printerID, "drift", drift.Round(time.Second))
+	case wasDrifting && !isDrifting:
+		monitorLog.Info("Printer clock is back in sync with the server", "printer", config.Name, "printer_id", printerID, "threshold", threshold)
+	}
+}
+
+// GetPrinterClockDrift returns the last measured clock drift per printer (printer minus server time)
+func (b *FilamentBridge) GetPrinterClockDrift() map[string]time.Duration {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	drift := make(map[string]time.Duration, len(b.clockDrift))
+	for printerID, d := range b.clockDrift {
+		drift[printerID] = d
+	}
+	return drift
+}
+
+// absDuration returns the magnitude of a duration
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
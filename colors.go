@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coarse color families used to group spools
+const (
+	ColorFamilyRed     = "red"
+	ColorFamilyOrange  = "orange"
+	ColorFamilyYellow  = "yellow"
+	ColorFamilyGreen   = "green"
+	ColorFamilyCyan    = "cyan"
+	ColorFamilyBlue    = "blue"
+	ColorFamilyPurple  = "purple"
+	ColorFamilyPink    = "pink"
+	ColorFamilyBrown   = "brown"
+	ColorFamilyBlack   = "black"
+	ColorFamilyWhite   = "white"
+	ColorFamilyGray    = "gray"
+	ColorFamilyUnknown = "unknown"
+)
+
+// ColorFamily summarizes the spools that fall into one color family
+type ColorFamily struct {
+	Family          string  `json:"family"`
+	SpoolCount      int     `json:"spool_count"`
+	RemainingWeight float64 `json:"remaining_weight"`
+	SpoolIDs        []int   `json:"spool_ids"`
+}
+
+// parseColorHex parses a "#RRGGBB" or "RRGGBB" string into RGB components (0-1)
+func parseColorHex(hex string) (r, g, b float64, ok bool) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 8 {
+		hex = hex[:6] // Ignore alpha channel
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	r = float64((value>>16)&0xFF) / 255
+	g = float64((value>>8)&0xFF) / 255
+	b = float64(value&0xFF) / 255
+	return r, g, b, true
+}
+
+// colorHexBytes is parseColorHex with 0-255 components, for drawing
+func colorHexBytes(hex string) (r, g, b uint8, ok bool) {
+	red, green, blue, ok := parseColorHex(hex)
+	return uint8(math.Round(red * 255)), uint8(math.Round(green * 255)), uint8(math.Round(blue * 255)), ok
+}
+
+// colorFamilyForHex classifies a hex color into a coarse color family using HSV
+func colorFamilyForHex(hex string) string {
+	r, g, b, ok := parseColorHex(hex)
+	if !ok {
+		return ColorFamilyUnknown
+	}
+
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	delta := maxC - minC
+	value := maxC
+	saturation := 0.0
+	if maxC > 0 {
+		saturation = delta / maxC
+	}
+
+	// Achromatic colors first
+	if value < 0.15 {
+		return ColorFamilyBlack
+	}
+	if saturation < 0.15 {
+		switch {
+		case value > 0.85:
+			return ColorFamilyWhite
+		case value < 0.3:
+			return ColorFamilyBlack
+		default:
+			return ColorFamilyGray
+		}
+	}
+
+	var hue float64
+	switch maxC {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+
+	switch {
+	case hue < 15 || hue >= 345:
+		return ColorFamilyRed
+	case hue < 45:
+		// Dark oranges read as brown
+		if value < 0.6 {
+			return ColorFamilyBrown
+		}
+		return ColorFamilyOrange
+	case hue < 70:
+		return ColorFamilyYellow
+	case hue < 165:
+		return ColorFamilyGreen
+	case hue < 195:
+		return ColorFamilyCyan
+	case hue < 255:
+		return ColorFamilyBlue
+	case hue < 290:
+		return ColorFamilyPurple
+	default:
+		return ColorFamilyPink
+	}
+}
+
+// groupSpoolsByColorFamily groups spools into color families, sorted by remaining weight (descending)
+func groupSpoolsByColorFamily(spools []SpoolmanSpool) []ColorFamily {
+	families := make(map[string]*ColorFamily)
+
+	for _, spool := range spools {
+		colorHex := ""
+		if spool.Filament != nil {
+			colorHex = spool.Filament.ColorHex
+		}
+		name := colorFamilyForHex(colorHex)
+
+		family, exists := families[name]
+		if !exists {
+			family = &ColorFamily{Family: name, SpoolIDs: []int{}}
+			families[name] = family
+		}
+		family.SpoolCount++
+		family.RemainingWeight += spool.RemainingWeight
+		family.SpoolIDs = append(family.SpoolIDs, spool.ID)
+	}
+
+	result := make([]ColorFamily, 0, len(families))
+	for _, family := range families {
+		result = append(result, *family)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].RemainingWeight != result[j].RemainingWeight {
+			return result[i].RemainingWeight > result[j].RemainingWeight
+		}
+		return result[i].Family < result[j].Family
+	})
+
+	return result
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +16,75 @@ type PrinterConfig struct {
 	IPAddress string `json:"ip_address"`
 	APIKey    string `json:"api_key,omitempty"`
 	Toolheads int    `json:"toolheads"`
+	// Filament slots feeding the toolheads, e.g. 5 on an MK4 with an MMU3; 0 means one per
+	// toolhead. Spools are mapped, and G-code usage is counted, per slot.
+	Slots int `json:"slots,omitempty"`
+	// Prusa Connect cloud monitoring (used instead of local PrusaLink polling when both are set)
+	ConnectPrinterUUID string `json:"connect_printer_uuid,omitempty"`
+	ConnectToken       string `json:"connect_token,omitempty"`
+	// G-code flavor the printer's files are expected in, empty to detect it per file
+	GcodeFlavor string `json:"gcode_flavor,omitempty"`
+	// Overrides of the global polling and PrusaLink settings in seconds, 0 to use the global
+	// value, e.g. a longer download timeout for a printer reading from slow USB storage
+	PollInterval       int `json:"poll_interval,omitempty"`
+	ActivePollInterval int `json:"active_poll_interval,omitempty"`
+	PrusaLinkTimeout   int `json:"prusalink_timeout,omitempty"`
+	DownloadTimeout    int `json:"prusalink_file_download_timeout,omitempty"`
+	// Name of the Spoolman instance the printer's spools are in, empty for the main one
+	SpoolmanInstance string `json:"spoolman_instance,omitempty"`
+	// Accept the printer's HTTPS certificate without verifying it, e.g. a self-signed one on
+	// a reverse proxy in front of PrusaLink
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// Printer group the printer is listed under in the farm view, e.g. "PLA farm"
+	Group string `json:"group,omitempty"`
+}
+
+// SlotCount returns how many spools the printer can have mapped: one per MMU slot, or one
+// per toolhead without an MMU
+func (p PrinterConfig) SlotCount() int {
+	if p.Slots > 0 {
+		return p.Slots
+	}
+	return p.Toolheads
+}
+
+// pollInterval returns how long to wait between polls of the printer, using its own
+// intervals where set. The active interval is never slower than the idle one.
+func (p PrinterConfig) pollInterval(global *Config, printing bool) time.Duration {
+	interval := global.PollInterval
+	if p.PollInterval > 0 {
+		interval = time.Duration(p.PollInterval) * time.Second
+	}
+	if !printing {
+		return interval
+	}
+
+	active := global.ActivePollInterval
+	if p.ActivePollInterval > 0 {
+		active = time.Duration(p.ActivePollInterval) * time.Second
+	}
+	if active > 0 && active < interval {
+		return active
+	}
+	return interval
+}
+
+// prusaLinkTimeouts returns the request and file download timeouts for the printer in seconds
+func (p PrinterConfig) prusaLinkTimeouts(global *Config) (int, int) {
+	timeout, downloadTimeout := global.PrusaLinkTimeout, global.PrusaLinkFileDownloadTimeout
+	if p.PrusaLinkTimeout > 0 {
+		timeout = p.PrusaLinkTimeout
+	}
+	if p.DownloadTimeout > 0 {
+		downloadTimeout = p.DownloadTimeout
+	}
+	return timeout, downloadTimeout
+}
+
+// prusaLinkClient returns a PrusaLink client for the printer with its timeouts
+func (p PrinterConfig) prusaLinkClient(global *Config) *PrusaLinkClient {
+	timeout, downloadTimeout := p.prusaLinkTimeouts(global)
+	return NewPrusaLinkClient(p.IPAddress, p.APIKey, timeout, downloadTimeout, p.InsecureSkipVerify)
 }
 
 // FilamentSpool represents a filament spool from Spoolman
@@ -34,13 +104,73 @@ type Config struct {
 	SpoolmanURL                  string
 	SpoolmanUsername             string
 	SpoolmanPassword             string
+	SpoolmanInsecureSkipVerify   bool // Don't verify Spoolman's HTTPS certificate
 	PollInterval                 time.Duration
+	ActivePollInterval           time.Duration // Faster poll interval used while a printer is printing
 	LocationSyncInterval         time.Duration
 	DBFile                       string
 	WebPort                      string
 	PrusaLinkTimeout             int
 	PrusaLinkFileDownloadTimeout int
 	SpoolmanTimeout              int
+	ScheduledJobs                map[string]ScheduledJobSettings
+	MQTTBroker                   string
+	MQTTUsername                 string
+	MQTTPassword                 string
+	MQTTTopicPrefix              string
+	MQTTDiscoveryPrefix          string
+	EditableSpoolFields          []string
+	SpoolOwnerField              string
+	SpoolLowStockField           string // Spoolman extra field holding per-spool low stock thresholds
+	Scales                       []ScaleConfig
+	ScaleDiscrepancyThreshold    float64 // Grams a measured weight may differ from Spoolman and still be corrected automatically
+	EnvironmentSensors           []EnvironmentSensor
+	DryStorageHumidity           float64 // % relative humidity at or below which a location keeps spools dry
+	LogLevel                     string
+	LogFormat                    string
+	LogModuleLevels              string
+	LogBufferSize                int
+	EstimatedFlowRate            float64 // Grams per hour, for usage estimates from print time
+	DefaultFilamentDensity       float64 // g/cm³, for converting lengths when the spool's filament has none
+	DefaultFilamentDiameter      float64 // mm, for converting lengths when the spool's filament has none
+	BasePath                     string  // Normalized to "/prefix", or "" for the root
+	TLSCertFile                  string
+	TLSKeyFile                   string
+	TLSACMEDomains               []string // Domains to get certificates for with ACME when no certificate file is set
+	TLSACMEEmail                 string
+	NFCSessionTimeout            time.Duration            // How long an NFC scan session waits for its next tag
+	SpoolmanWriteDelay           time.Duration            // Pause between Spoolman writes for a finished print
+	CombineNotifications         bool                     // Fold per-spool notifications into the print complete notification
+	SpoolmanProxyEnabled         bool                     // Serve Spoolman read-only under /spoolman
+	WasteSpoolField              string                   // Spoolman extra field totaling each spool's purge waste, empty disables
+	BackupDir                    string                   // Directory for scheduled database backups, empty disables
+	BackupRetention              int                      // Scheduled backups kept, oldest removed first
+	ReadinessSpoolmanWindow      time.Duration            // How recently Spoolman must have answered for /readyz to pass
+	PrintProcessingWorkers       int                      // Finished prints processed at once
+	MonitorStartDelay            time.Duration            // Delay before the first monitoring cycle
+	MonitorJitter                time.Duration            // Maximum random delay added to each printer poll
+	PrusaLinkEventsEnabled       bool                     // Subscribe to PrusaLink push events where supported
+	SpoolmanEventsEnabled        bool                     // Follow Spoolman's change websocket
+	LowStockThreshold            float64                  // Remaining grams below which a spool is low stock
+	PrusaConnectURL              string                   // Base URL of the Prusa Connect API
+	AutoPauseEmptySpool          bool                     // Pause new prints whose mapped spool is (nearly) empty
+	AutoPauseUnmapped            bool                     // Pause new prints on toolheads with no mapped spool
+	AutoPauseMinWeight           float64                  // Remaining grams at or below which a spool counts as empty
+	EmptySpoolAutoArchive        bool                     // Archive and unmap spools once usage leaves them empty
+	LowFilamentCheck             string                   // off, warn or pause when a spool has less left than a job needs
+	FilamentMismatchCheck        string                   // off, warn or pause when a spool isn't the filament a job was sliced for
+	ToolheadRuleCheck            string                   // off, warn or reject when a spool or job breaks a toolhead's rules
+	BarcodeLookupURL             string                   // Online filament database URL with {barcode} in it; empty for local mappings only
+	RunoutPredictionEnabled      bool                     // Predict mid-print spool runouts from job progress
+	ActiveSpoolEstimates         bool                     // Estimate mapped spools' remaining weight during prints
+	RunoutDetectionEnabled       bool                     // Record and notify when a print stops for attention
+	NotificationChannels         []NotificationChannel    // Configured notification destinations
+	OutgoingWebhooks             []OutgoingWebhook        // Endpoints lifecycle events are posted to
+	SpoolmanInstances            []SpoolmanInstance       // Additional Spoolman instances printers can be assigned to
+	IdleSpoolReminderDays        int                      // Days a mapped spool may go unused before a reminder (0 disables)
+	IdleSpoolReturnLocation      string                   // Location idle spools are moved to by reminder links
+	ExternalURL                  string                   // Public base URL used in generated links
+	ClockDriftThreshold          time.Duration            // Printer clock drift beyond which a warning is logged (0 disables)
 	Printers                     map[string]PrinterConfig // Key is printer ID, value is printer config
 }
 
@@ -60,6 +190,17 @@ func LoadConfig(bridge *FilamentBridge) (*Config, error) {
 		}
 	}
 
+	// Parse active poll interval (never slower than the idle interval)
+	activePollInterval := DefaultActivePollInterval
+	if activeStr, exists := configValues[ConfigKeyActivePollInterval]; exists {
+		if parsed, err := strconv.Atoi(activeStr); err == nil && parsed > 0 {
+			activePollInterval = parsed
+		}
+	}
+	if activePollInterval > pollInterval {
+		activePollInterval = pollInterval
+	}
+
 	// Parse location sync interval
 	locationSyncInterval := DefaultLocationSyncInterval
 	if syncStr, exists := configValues[ConfigKeyLocationSyncInterval]; exists {
@@ -90,24 +231,265 @@ func LoadConfig(bridge *FilamentBridge) (*Config, error) {
 		}
 	}
 
+	// Parse monitor start delay and jitter
+	monitorStartDelay := DefaultMonitorStartDelay
+	if delayStr, exists := configValues[ConfigKeyMonitorStartDelay]; exists {
+		if parsed, err := strconv.Atoi(delayStr); err == nil && parsed >= 0 {
+			monitorStartDelay = parsed
+		}
+	}
+
+	monitorJitter := DefaultMonitorJitter
+	if jitterStr, exists := configValues[ConfigKeyMonitorJitter]; exists {
+		if parsed, err := strconv.Atoi(jitterStr); err == nil && parsed >= 0 {
+			monitorJitter = parsed
+		}
+	}
+	// Jitter must stay below the poll interval so cycles don't overlap
+	if monitorJitter >= pollInterval {
+		monitorJitter = pollInterval / 2
+	}
+
+	lowStockThreshold := float64(DefaultLowStockThreshold)
+	if thresholdStr, exists := configValues[ConfigKeyLowStockThreshold]; exists {
+		if parsed, err := strconv.ParseFloat(thresholdStr, 64); err == nil && parsed >= 0 {
+			lowStockThreshold = parsed
+		}
+	}
+
+	autoPauseMinWeight := float64(DefaultAutoPauseMinWeight)
+	if weightStr, exists := configValues[ConfigKeyAutoPauseMinWeight]; exists {
+		if parsed, err := strconv.ParseFloat(weightStr, 64); err == nil && parsed >= 0 {
+			autoPauseMinWeight = parsed
+		}
+	}
+
+	lowFilamentCheck := LowFilamentCheckOff
+	switch mode := configValues[ConfigKeyLowFilamentCheck]; mode {
+	case LowFilamentCheckWarn, LowFilamentCheckPause:
+		lowFilamentCheck = mode
+	}
+
+	filamentMismatchCheck := FilamentMismatchCheckOff
+	switch mode := configValues[ConfigKeyFilamentMismatchCheck]; mode {
+	case FilamentMismatchCheckWarn, FilamentMismatchCheckPause:
+		filamentMismatchCheck = mode
+	}
+
+	toolheadRuleCheck := ToolheadRuleCheckWarn
+	switch mode := configValues[ConfigKeyToolheadRuleCheck]; mode {
+	case ToolheadRuleCheckOff, ToolheadRuleCheckReject:
+		toolheadRuleCheck = mode
+	}
+
+	idleSpoolReminderDays := DefaultIdleSpoolReminderDays
+	if daysStr, exists := configValues[ConfigKeyIdleSpoolReminderDays]; exists {
+		if parsed, err := strconv.Atoi(daysStr); err == nil && parsed >= 0 {
+			idleSpoolReminderDays = parsed
+		}
+	}
+
+	backupRetention := DefaultBackupRetention
+	if retentionStr, exists := configValues[ConfigKeyBackupRetention]; exists {
+		if parsed, err := strconv.Atoi(retentionStr); err == nil && parsed > 0 {
+			backupRetention = parsed
+		}
+	}
+
+	readinessSpoolmanWindow := DefaultReadinessSpoolmanWindow
+	if windowStr, exists := configValues[ConfigKeyReadinessSpoolmanWindow]; exists {
+		if parsed, err := strconv.Atoi(windowStr); err == nil && parsed > 0 {
+			readinessSpoolmanWindow = parsed
+		}
+	}
+
+	printProcessingWorkers := DefaultPrintProcessingWorkers
+	if workersStr, exists := configValues[ConfigKeyPrintProcessingWorkers]; exists {
+		if parsed, err := strconv.Atoi(workersStr); err == nil && parsed > 0 {
+			printProcessingWorkers = parsed
+		}
+	}
+
+	clockDriftThreshold := DefaultClockDriftThreshold
+	if thresholdStr, exists := configValues[ConfigKeyClockDriftThreshold]; exists {
+		if parsed, err := strconv.Atoi(thresholdStr); err == nil && parsed >= 0 {
+			clockDriftThreshold = parsed
+		}
+	}
+
+	logBufferSize := DefaultLogBufferSize
+	if sizeStr, exists := configValues[ConfigKeyLogBufferSize]; exists {
+		if parsed, err := strconv.Atoi(sizeStr); err == nil && parsed >= 0 {
+			logBufferSize = min(parsed, MaxLogBufferSize)
+		}
+	}
+
+	estimatedFlowRate := float64(DefaultEstimatedFlowRate)
+	if rateStr, exists := configValues[ConfigKeyEstimatedFlowRate]; exists {
+		if parsed, err := strconv.ParseFloat(rateStr, 64); err == nil && parsed >= 0 {
+			estimatedFlowRate = parsed
+		}
+	}
+
+	filamentDensity := defaultFilamentDensity
+	if densityStr, exists := configValues[ConfigKeyDefaultFilamentDensity]; exists {
+		if parsed, err := strconv.ParseFloat(densityStr, 64); err == nil && parsed > 0 {
+			filamentDensity = parsed
+		}
+	}
+	filamentDiameter := defaultFilamentDiameter
+	if diameterStr, exists := configValues[ConfigKeyDefaultFilamentDiameter]; exists {
+		if parsed, err := strconv.ParseFloat(diameterStr, 64); err == nil && parsed > 0 {
+			filamentDiameter = parsed
+		}
+	}
+
+	nfcSessionTimeout := DefaultNFCSessionTimeout
+	if timeoutStr, exists := configValues[ConfigKeyNFCSessionTimeout]; exists {
+		if parsed, err := strconv.Atoi(timeoutStr); err == nil && parsed > 0 {
+			nfcSessionTimeout = parsed
+		}
+	}
+
+	spoolmanWriteDelay := DefaultSpoolmanWriteDelay
+	if delayStr, exists := configValues[ConfigKeySpoolmanWriteDelay]; exists {
+		if parsed, err := strconv.Atoi(delayStr); err == nil && parsed >= 0 {
+			spoolmanWriteDelay = parsed
+		}
+	}
+
+	notificationChannels, err := parseNotificationChannels(configValues[ConfigKeyNotificationChannels])
+	if err != nil {
+		bridgeLog.Warn("Ignoring notification channels", "error", err)
+		notificationChannels = []NotificationChannel{}
+	}
+
+	outgoingWebhooks, err := parseOutgoingWebhooks(configValues[ConfigKeyOutgoingWebhooks])
+	if err != nil {
+		bridgeLog.Warn("Ignoring outgoing webhooks", "error", err)
+		outgoingWebhooks = []OutgoingWebhook{}
+	}
+
+	spoolmanInstances, err := parseSpoolmanInstances(configValues[ConfigKeySpoolmanInstances])
+	if err != nil {
+		bridgeLog.Warn("Ignoring Spoolman instances", "error", err)
+		spoolmanInstances = []SpoolmanInstance{}
+	}
+
+	scales, err := parseScales(configValues[ConfigKeyScales])
+	if err != nil {
+		bridgeLog.Warn("Ignoring scales", "error", err)
+		scales = []ScaleConfig{}
+	}
+
+	scaleDiscrepancyThreshold := float64(DefaultScaleDiscrepancyThreshold)
+	if thresholdStr, exists := configValues[ConfigKeyScaleDiscrepancyThreshold]; exists {
+		if parsed, err := strconv.ParseFloat(thresholdStr, 64); err == nil && parsed >= 0 {
+			scaleDiscrepancyThreshold = parsed
+		}
+	}
+
+	environmentSensors, err := parseEnvironmentSensors(configValues[ConfigKeyEnvironmentSensors])
+	if err != nil {
+		bridgeLog.Warn("Ignoring environment sensors", "error", err)
+		environmentSensors = []EnvironmentSensor{}
+	}
+
+	dryStorageHumidity := float64(DefaultDryStorageHumidity)
+	if humidityStr, exists := configValues[ConfigKeyDryStorageHumidity]; exists {
+		if parsed, err := strconv.ParseFloat(humidityStr, 64); err == nil && parsed >= 0 && parsed <= 100 {
+			dryStorageHumidity = parsed
+		}
+	}
+
+	scheduledJobs, err := parseScheduledJobs(configValues[ConfigKeyScheduledJobs])
+	if err != nil {
+		bridgeLog.Warn("Ignoring scheduled job settings", "error", err)
+		scheduledJobs = make(map[string]ScheduledJobSettings)
+	}
+
 	config := &Config{
 		SpoolmanURL:                  configValues[ConfigKeySpoolmanURL],
 		SpoolmanUsername:             configValues[ConfigKeySpoolmanUsername],
 		SpoolmanPassword:             configValues[ConfigKeySpoolmanPassword],
+		SpoolmanInsecureSkipVerify:   configValues[ConfigKeySpoolmanInsecureSkipVerify] == "true",
 		PollInterval:                 time.Duration(pollInterval) * time.Second,
+		ActivePollInterval:           time.Duration(activePollInterval) * time.Second,
 		LocationSyncInterval:         time.Duration(locationSyncInterval) * time.Minute,
 		DBFile:                       getDBFilePath(),
 		WebPort:                      configValues[ConfigKeyWebPort],
 		PrusaLinkTimeout:             prusaLinkTimeout,
 		PrusaLinkFileDownloadTimeout: prusaLinkFileDownloadTimeout,
 		SpoolmanTimeout:              spoolmanTimeout,
+		MonitorStartDelay:            time.Duration(monitorStartDelay) * time.Second,
+		MonitorJitter:                time.Duration(monitorJitter) * time.Second,
+		PrusaLinkEventsEnabled:       configValues[ConfigKeyPrusaLinkEventsEnabled] == "true",
+		SpoolmanEventsEnabled:        configValues[ConfigKeySpoolmanEventsEnabled] != "false",
+		LowStockThreshold:            lowStockThreshold,
+		PrusaConnectURL:              DefaultPrusaConnectURL,
+		AutoPauseEmptySpool:          configValues[ConfigKeyAutoPauseEmptySpool] == "true",
+		AutoPauseUnmapped:            configValues[ConfigKeyAutoPauseUnmapped] == "true",
+		AutoPauseMinWeight:           autoPauseMinWeight,
+		EmptySpoolAutoArchive:        configValues[ConfigKeyEmptySpoolAutoArchive] == "true",
+		LowFilamentCheck:             lowFilamentCheck,
+		FilamentMismatchCheck:        filamentMismatchCheck,
+		ToolheadRuleCheck:            toolheadRuleCheck,
+		BarcodeLookupURL:             strings.TrimSpace(configValues[ConfigKeyBarcodeLookupURL]),
+		RunoutPredictionEnabled:      configValues[ConfigKeyRunoutPredictionEnabled] == "true",
+		ActiveSpoolEstimates:         configValues[ConfigKeyActiveSpoolEstimates] == "true",
+		RunoutDetectionEnabled:       configValues[ConfigKeyRunoutDetectionEnabled] == "true",
+		NotificationChannels:         notificationChannels,
+		OutgoingWebhooks:             outgoingWebhooks,
+		SpoolmanInstances:            spoolmanInstances,
+		IdleSpoolReminderDays:        idleSpoolReminderDays,
+		IdleSpoolReturnLocation:      configValues[ConfigKeyIdleSpoolReturnLocation],
+		ExternalURL:                  configValues[ConfigKeyExternalURL],
+		ClockDriftThreshold:          time.Duration(clockDriftThreshold) * time.Second,
+		ScheduledJobs:                scheduledJobs,
+		MQTTBroker:                   configValues[ConfigKeyMQTTBroker],
+		MQTTUsername:                 configValues[ConfigKeyMQTTUsername],
+		MQTTPassword:                 configValues[ConfigKeyMQTTPassword],
+		MQTTTopicPrefix:              configValues[ConfigKeyMQTTTopicPrefix],
+		MQTTDiscoveryPrefix:          configValues[ConfigKeyMQTTDiscoveryPrefix],
+		EditableSpoolFields:          parseEditableSpoolFields(configValues[ConfigKeyEditableSpoolFields]),
+		SpoolOwnerField:              configValues[ConfigKeySpoolOwnerField],
+		SpoolLowStockField:           strings.TrimSpace(configValues[ConfigKeySpoolLowStockField]),
+		Scales:                       scales,
+		ScaleDiscrepancyThreshold:    scaleDiscrepancyThreshold,
+		EnvironmentSensors:           environmentSensors,
+		DryStorageHumidity:           dryStorageHumidity,
+		LogLevel:                     configValues[ConfigKeyLogLevel],
+		LogFormat:                    configValues[ConfigKeyLogFormat],
+		LogModuleLevels:              configValues[ConfigKeyLogModuleLevels],
+		LogBufferSize:                logBufferSize,
+		EstimatedFlowRate:            estimatedFlowRate,
+		DefaultFilamentDensity:       filamentDensity,
+		DefaultFilamentDiameter:      filamentDiameter,
+		BasePath:                     normalizeBasePath(configValues[ConfigKeyBasePath]),
+		TLSCertFile:                  strings.TrimSpace(configValues[ConfigKeyTLSCertFile]),
+		TLSKeyFile:                   strings.TrimSpace(configValues[ConfigKeyTLSKeyFile]),
+		TLSACMEDomains:               splitDomains(configValues[ConfigKeyTLSACMEDomains]),
+		TLSACMEEmail:                 strings.TrimSpace(configValues[ConfigKeyTLSACMEEmail]),
+		NFCSessionTimeout:            time.Duration(nfcSessionTimeout) * time.Minute,
+		SpoolmanWriteDelay:           time.Duration(spoolmanWriteDelay) * time.Millisecond,
+		CombineNotifications:         configValues[ConfigKeyCombineNotifications] == "true",
+		SpoolmanProxyEnabled:         configValues[ConfigKeySpoolmanProxyEnabled] == "true",
+		WasteSpoolField:              strings.TrimSpace(configValues[ConfigKeyWasteSpoolField]),
+		BackupDir:                    strings.TrimSpace(configValues[ConfigKeyBackupDir]),
+		BackupRetention:              backupRetention,
+		ReadinessSpoolmanWindow:      time.Duration(readinessSpoolmanWindow) * time.Minute,
+		PrintProcessingWorkers:       printProcessingWorkers,
 		Printers:                     make(map[string]PrinterConfig),
 	}
 
+	if connectURL := configValues[ConfigKeyPrusaConnectURL]; connectURL != "" {
+		config.PrusaConnectURL = connectURL
+	}
+
 	// Load individual printer configurations from database
 	printerConfigs, err := bridge.GetAllPrinterConfigs()
 	if err != nil {
-		fmt.Printf("Error loading printer configs: %v\n", err)
+		bridgeLog.Error("Error loading printer configs", "error", err)
 		// Fallback to empty config
 		config.Printers["no_printers"] = PrinterConfig{
 			Name:      "No Printers Configured",
@@ -125,11 +507,22 @@ func LoadConfig(bridge *FilamentBridge) (*Config, error) {
 		// This prevents race conditions and timeouts during config loading
 		// Live printer status will be handled by the monitoring cycle
 		config.Printers[printerID] = PrinterConfig{
-			Name:      printerConfig.Name,
-			Model:     printerConfig.Model,
-			IPAddress: printerConfig.IPAddress,
-			APIKey:    printerConfig.APIKey,
-			Toolheads: printerConfig.Toolheads,
+			Name:               printerConfig.Name,
+			Model:              printerConfig.Model,
+			IPAddress:          printerConfig.IPAddress,
+			APIKey:             printerConfig.APIKey,
+			Toolheads:          printerConfig.Toolheads,
+			Slots:              printerConfig.Slots,
+			ConnectPrinterUUID: printerConfig.ConnectPrinterUUID,
+			ConnectToken:       printerConfig.ConnectToken,
+			GcodeFlavor:        printerConfig.GcodeFlavor,
+			PollInterval:       printerConfig.PollInterval,
+			ActivePollInterval: printerConfig.ActivePollInterval,
+			PrusaLinkTimeout:   printerConfig.PrusaLinkTimeout,
+			DownloadTimeout:    printerConfig.DownloadTimeout,
+			SpoolmanInstance:   printerConfig.SpoolmanInstance,
+			InsecureSkipVerify: printerConfig.InsecureSkipVerify,
+			Group:              printerConfig.Group,
 		}
 	}
 
@@ -157,7 +550,7 @@ func resolvePrinterName(config PrinterConfig) string {
 
 // getDBFilePath returns the database file path, checking environment variable first
 func getDBFilePath() string {
-	if dbPath := os.Getenv("FILABRIDGE_DB_PATH"); dbPath != "" {
+	if dbPath := os.Getenv(DBPathEnv); dbPath != "" {
 		return filepath.Join(dbPath, DefaultDBFileName)
 	}
 	return DefaultDBFileName
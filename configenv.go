@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configEnvName returns the environment variable that overrides a configuration key, e.g.
+// FILABRIDGE_SPOOLMAN_URL for spoolman_url
+func configEnvName(key string) string {
+	return ConfigEnvPrefix + strings.ToUpper(key)
+}
+
+// configEnvOverride returns the environment's value for a configuration key. Empty variables
+// don't count, since compose files often pass through variables that were never set.
+func configEnvOverride(key string) (string, bool) {
+	value := os.Getenv(configEnvName(key))
+	return value, value != ""
+}
+
+// configEnvOverrides returns the keys overridden by the environment, out of the defaults and
+// the stored keys, with the variable setting each
+func configEnvOverrides(stored map[string]string) map[string]string {
+	keys := defaultConfigValues()
+	for key := range stored {
+		keys[key] = ""
+	}
+
+	overrides := make(map[string]string)
+	for key := range keys {
+		if _, ok := configEnvOverride(key); ok {
+			overrides[key] = configEnvName(key)
+		}
+	}
+	return overrides
+}
+
+// ConfigEnvOverrides returns the configuration keys overridden by the environment
+func (b *FilamentBridge) ConfigEnvOverrides() (map[string]string, error) {
+	stored, err := b.getStoredConfig()
+	if err != nil {
+		return nil, err
+	}
+	return configEnvOverrides(stored), nil
+}
+
+// logConfigEnvOverrides reports the overridden keys at startup, and FILABRIDGE_ variables that
+// don't match any key, which are usually typos. Values aren't logged as some are credentials.
+func (b *FilamentBridge) logConfigEnvOverrides() {
+	overrides, err := b.ConfigEnvOverrides()
+	if err != nil {
+		bridgeLog.Warn("Failed to check environment configuration overrides", "error", err)
+		return
+	}
+
+	known := map[string]bool{DBPathEnv: true, ExecHooksEnv: true}
+	keys := make([]string, 0, len(overrides))
+	for key, name := range overrides {
+		keys = append(keys, key)
+		known[name] = true
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		bridgeLog.Info("Configuration overridden by environment", "keys", strings.Join(keys, ","))
+	}
+
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, ConfigEnvPrefix) && value != "" && !known[name] {
+			bridgeLog.Warn("Ignoring environment variable that matches no configuration key", "variable", name)
+		}
+	}
+}
+
+// getConfigOverridesHandler lists the configuration keys set by the environment, which the
+// config API returns but won't change
+func (ws *WebServer) getConfigOverridesHandler(c *gin.Context) {
+	overrides, err := ws.bridge.ConfigEnvOverrides()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	for _, key := range hiddenConfigKeys {
+		delete(overrides, key)
+	}
+	c.JSON(http.StatusOK, ConfigOverridesResponse{Overrides: overrides})
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigExport is the portable part of a FilaBridge install: settings, printers, toolhead
+// names and rules, and spool mappings. Unlike a database backup it leaves out history and credentials,
+// so it can be kept in git or carried to a new host.
+type ConfigExport struct {
+	Version       int                      `json:"version"`
+	ExportedAt    time.Time                `json:"exported_at"`
+	Config        map[string]string        `json:"config"`
+	Printers      map[string]PrinterConfig `json:"printers"` // By printer ID
+	ToolheadNames []ExportedToolheadName   `json:"toolhead_names"`
+	ToolheadRules []ExportedToolheadRule   `json:"toolhead_rules,omitempty"`
+	Mappings      []ExportedMapping        `json:"mappings"`
+	SharedSpools  []ExportedSharedSpool    `json:"shared_spools,omitempty"`
+}
+
+// ExportedToolheadName is a toolhead's custom name in a configuration export
+type ExportedToolheadName struct {
+	PrinterID  string `json:"printer_id"`
+	ToolheadID int    `json:"toolhead_id"`
+	Name       string `json:"name"`
+}
+
+// ExportedToolheadRule is a toolhead's rule in a configuration export
+type ExportedToolheadRule struct {
+	PrinterID string `json:"printer_id"`
+	ToolheadRule
+}
+
+// ExportedMapping is a spool mapped to a toolhead in a configuration export
+type ExportedMapping struct {
+	PrinterName string `json:"printer_name"`
+	ToolheadID  int    `json:"toolhead_id"`
+	SpoolID     int    `json:"spool_id"`
+	MappedBy    string `json:"mapped_by,omitempty"`
+}
+
+// ExportedSharedSpool is a spool allowed on several toolheads in a configuration export
+type ExportedSharedSpool struct {
+	SpoolmanInstance string `json:"spoolman_instance,omitempty"`
+	SpoolID          int    `json:"spool_id"`
+}
+
+// isCredentialConfigKey reports whether a key is one of FilaBridge's own credentials
+func isCredentialConfigKey(key string) bool {
+	for _, credential := range credentialConfigKeys {
+		if key == credential {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportConfig collects the configuration for export
+func (b *FilamentBridge) ExportConfig() (*ConfigExport, error) {
+	// Environment overrides belong to the deployment, not the configuration being exported
+	config, err := b.getStoredConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range credentialConfigKeys {
+		delete(config, key)
+	}
+
+	printers, err := b.GetAllPrinterConfigs()
+	if err != nil {
+		return nil, err
+	}
+	toolheadNames := []ExportedToolheadName{}
+	var toolheadRules []ExportedToolheadRule
+	for printerID := range printers {
+		names, err := b.GetAllToolheadNames(printerID)
+		if err != nil {
+			return nil, err
+		}
+		for toolheadID, name := range names {
+			toolheadNames = append(toolheadNames, ExportedToolheadName{PrinterID: printerID, ToolheadID: toolheadID, Name: name})
+		}
+		rules, err := b.GetToolheadRules(printerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			toolheadRules = append(toolheadRules, ExportedToolheadRule{PrinterID: printerID, ToolheadRule: rule})
+		}
+	}
+	sort.Slice(toolheadNames, func(i, j int) bool {
+		if toolheadNames[i].PrinterID != toolheadNames[j].PrinterID {
+			return toolheadNames[i].PrinterID < toolheadNames[j].PrinterID
+		}
+		return toolheadNames[i].ToolheadID < toolheadNames[j].ToolheadID
+	})
+	sort.Slice(toolheadRules, func(i, j int) bool {
+		if toolheadRules[i].PrinterID != toolheadRules[j].PrinterID {
+			return toolheadRules[i].PrinterID < toolheadRules[j].PrinterID
+		}
+		return toolheadRules[i].ToolheadID < toolheadRules[j].ToolheadID
+	})
+
+	rows, err := b.db.Query("SELECT printer_name, toolhead_id, spool_id, COALESCE(mapped_by, '') FROM toolhead_mappings WHERE spool_id != 0 ORDER BY printer_name, toolhead_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get toolhead mappings: %w", err)
+	}
+	defer rows.Close()
+	mappings := []ExportedMapping{}
+	for rows.Next() {
+		var mapping ExportedMapping
+		if err := rows.Scan(&mapping.PrinterName, &mapping.ToolheadID, &mapping.SpoolID, &mapping.MappedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan toolhead mapping: %w", err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get toolhead mappings: %w", err)
+	}
+
+	sharedRows, err := b.db.Query("SELECT spoolman_instance, spool_id FROM shared_spools ORDER BY spoolman_instance, spool_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared spools: %w", err)
+	}
+	defer sharedRows.Close()
+	var sharedSpools []ExportedSharedSpool
+	for sharedRows.Next() {
+		var shared ExportedSharedSpool
+		if err := sharedRows.Scan(&shared.SpoolmanInstance, &shared.SpoolID); err != nil {
+			return nil, fmt.Errorf("failed to scan shared spool: %w", err)
+		}
+		sharedSpools = append(sharedSpools, shared)
+	}
+	if err := sharedRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get shared spools: %w", err)
+	}
+
+	return &ConfigExport{
+		Version:       ConfigExportVersion,
+		ExportedAt:    time.Now(),
+		Config:        config,
+		Printers:      printers,
+		ToolheadNames: toolheadNames,
+		ToolheadRules: toolheadRules,
+		Mappings:      mappings,
+		SharedSpools:  sharedSpools,
+	}, nil
+}
+
+// validate checks an export before anything from it is written
+func (e *ConfigExport) validate() error {
+	if e.Version > ConfigExportVersion {
+		return newCodedError(ErrCodeInvalidRequest, "export version %d is newer than this FilaBridge supports (%d)", e.Version, ConfigExportVersion)
+	}
+
+	var problems []string
+	for printerID, printer := range e.Printers {
+		if strings.TrimSpace(printerID) == "" {
+			problems = append(problems, "printer ID can't be empty")
+		}
+		if strings.TrimSpace(printer.Name) == "" {
+			problems = append(problems, fmt.Sprintf("printer %s has no name", printerID))
+		}
+		if printer.Toolheads < 1 {
+			problems = append(problems, fmt.Sprintf("printer %s needs at least one toolhead", printerID))
+		}
+	}
+	for _, name := range e.ToolheadNames {
+		if name.PrinterID == "" || name.ToolheadID < 0 || strings.TrimSpace(name.Name) == "" {
+			problems = append(problems, fmt.Sprintf("invalid name for printer %s toolhead %d", name.PrinterID, name.ToolheadID))
+		}
+	}
+	for _, rule := range e.ToolheadRules {
+		if rule.PrinterID == "" || rule.ToolheadID < 0 || rule.NozzleDiameter < 0 {
+			problems = append(problems, fmt.Sprintf("invalid rule for printer %s toolhead %d", rule.PrinterID, rule.ToolheadID))
+		}
+	}
+	shared := make(map[int]bool)
+	for _, spool := range e.SharedSpools {
+		if spool.SpoolID <= 0 {
+			problems = append(problems, fmt.Sprintf("invalid shared spool %d", spool.SpoolID))
+		}
+		shared[spool.SpoolID] = true
+	}
+	spools := make(map[int]bool)
+	for _, mapping := range e.Mappings {
+		switch {
+		case mapping.PrinterName == "" || mapping.ToolheadID < 0 || mapping.SpoolID <= 0:
+			problems = append(problems, fmt.Sprintf("invalid mapping of spool %d to %s toolhead %d", mapping.SpoolID, mapping.PrinterName, mapping.ToolheadID))
+		case spools[mapping.SpoolID] && !shared[mapping.SpoolID]:
+			problems = append(problems, fmt.Sprintf("spool %d is mapped more than once", mapping.SpoolID))
+		}
+		spools[mapping.SpoolID] = true
+	}
+	if len(problems) > 0 {
+		return newCodedError(ErrCodeInvalidRequest, "%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ImportConfig writes an export over the current configuration in one transaction. Settings,
+// printers and toolhead names and rules in the export replace the current ones, and its mappings
+// replace any mapping of the same toolhead or spool; everything else is left as it is.
+// Printer API keys and Prusa Connect tokens are imported with their printers, as are the
+// secrets of integrations such as Spoolman instances. FilaBridge's own credentials (admin
+// token, login password, link and NFC token secrets) are never imported.
+func (b *FilamentBridge) ImportConfig(export *ConfigExport) error {
+	if err := export.validate(); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin configuration import: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, value := range export.Config {
+		if isCredentialConfigKey(key) {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO configuration (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)", key, value); err != nil {
+			return fmt.Errorf("failed to import config value for %s: %w", key, err)
+		}
+	}
+
+	for printerID, config := range export.Printers {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO printer_configs (printer_id, name, model, ip_address, api_key, toolheads, slots, connect_printer_uuid, connect_token, gcode_flavor,
+				poll_interval, active_poll_interval, prusalink_timeout, prusalink_file_download_timeout, spoolman_instance, insecure_skip_verify, printer_group)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, printerID, config.Name, config.Model, config.IPAddress, config.APIKey, config.Toolheads, config.Slots, config.ConnectPrinterUUID, config.ConnectToken, config.GcodeFlavor,
+			config.PollInterval, config.ActivePollInterval, config.PrusaLinkTimeout, config.DownloadTimeout, config.SpoolmanInstance, config.InsecureSkipVerify, strings.TrimSpace(config.Group)); err != nil {
+			return fmt.Errorf("failed to import printer %s: %w", printerID, err)
+		}
+	}
+
+	for _, name := range export.ToolheadNames {
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO toolhead_names (printer_id, toolhead_id, display_name) VALUES (?, ?, ?)",
+			name.PrinterID, name.ToolheadID, strings.TrimSpace(name.Name),
+		); err != nil {
+			return fmt.Errorf("failed to import name of %s toolhead %d: %w", name.PrinterID, name.ToolheadID, err)
+		}
+	}
+
+	for _, rule := range export.ToolheadRules {
+		if _, err := tx.Exec(
+			"INSERT OR REPLACE INTO toolhead_rules (printer_id, toolhead_id, allowed_materials, nozzle_type, nozzle_diameter) VALUES (?, ?, ?, ?, ?)",
+			rule.PrinterID, rule.ToolheadID, strings.Join(rule.AllowedMaterials, ","), strings.ToLower(strings.TrimSpace(rule.NozzleType)), rule.NozzleDiameter,
+		); err != nil {
+			return fmt.Errorf("failed to import rule of %s toolhead %d: %w", rule.PrinterID, rule.ToolheadID, err)
+		}
+	}
+
+	now := time.Now()
+	for _, spool := range export.SharedSpools {
+		if _, err := tx.Exec(
+			"INSERT OR IGNORE INTO shared_spools (spoolman_instance, spool_id, shared_at) VALUES (?, ?, ?)",
+			spool.SpoolmanInstance, spool.SpoolID, now,
+		); err != nil {
+			return fmt.Errorf("failed to import shared spool %d: %w", spool.SpoolID, err)
+		}
+	}
+
+	imported := make(map[int]bool)
+	for _, mapping := range export.Mappings {
+		// The spool's current mappings are replaced once, so a shared spool keeps all of its
+		// toolheads in the export
+		if !imported[mapping.SpoolID] {
+			if _, err := tx.Exec("DELETE FROM toolhead_mappings WHERE spool_id = ?", mapping.SpoolID); err != nil {
+				return fmt.Errorf("failed to import mapping of spool %d: %w", mapping.SpoolID, err)
+			}
+			imported[mapping.SpoolID] = true
+		}
+		if _, err := tx.Exec(
+			"DELETE FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
+			mapping.PrinterName, mapping.ToolheadID,
+		); err != nil {
+			return fmt.Errorf("failed to import mapping of spool %d: %w", mapping.SpoolID, err)
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO toolhead_mappings (printer_name, toolhead_id, spool_id, mapped_at, mapped_by) VALUES (?, ?, ?, ?, ?)",
+			mapping.PrinterName, mapping.ToolheadID, mapping.SpoolID, now, mapping.MappedBy,
+		); err != nil {
+			return fmt.Errorf("failed to import mapping of spool %d: %w", mapping.SpoolID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit configuration import: %w", err)
+	}
+	bridgeLog.Info("Imported configuration", "config_keys", len(export.Config), "printers", len(export.Printers), "mappings", len(export.Mappings))
+	return nil
+}
+
+// isYAMLRequest reports whether a request asks for, or sends, YAML rather than JSON
+func isYAMLRequest(c *gin.Context) bool {
+	switch strings.ToLower(c.Query("format")) {
+	case "yaml", "yml":
+		return true
+	case "json":
+		return false
+	}
+	contentType := c.ContentType()
+	return strings.HasSuffix(contentType, "/yaml") || strings.HasSuffix(contentType, "/x-yaml")
+}
+
+// exportConfigHandler downloads the configuration as JSON, or as YAML with ?format=yaml
+func (ws *WebServer) exportConfigHandler(c *gin.Context) {
+	export, err := ws.bridge.ExportConfig()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	name := ConfigExportFilePrefix + export.ExportedAt.Format("20060102-150405")
+	if isYAMLRequest(c) {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".yaml"))
+		c.YAML(http.StatusOK, export)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+	c.JSON(http.StatusOK, export)
+}
+
+// importConfigHandler applies an uploaded export. YAML is read when the content type says so
+// or with ?format=yaml.
+func (ws *WebServer) importConfigHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxConfigImportSize)
+
+	var export ConfigExport
+	bind := c.ShouldBindJSON
+	if isYAMLRequest(c) {
+		bind = c.ShouldBindYAML
+	}
+	if err := bind(&export); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid configuration export: "+err.Error())
+		return
+	}
+
+	// Serialize printer operations to prevent race conditions
+	ws.operationMutex.Lock()
+	defer ws.operationMutex.Unlock()
+
+	if err := ws.bridge.ImportConfig(&export); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if err := ws.reloadBridgeConfig(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, ConfigImportResponse{
+		Message:  "Configuration imported successfully",
+		Config:   len(export.Config),
+		Printers: len(export.Printers),
+		Mappings: len(export.Mappings),
+	})
+}
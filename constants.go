@@ -1,10 +1,13 @@
 package main
 
+import "time"
+
 // Printer states
 const (
 	StateIdle          = "IDLE"
 	StatePrinting      = "PRINTING"
 	StateFinished      = "FINISHED"
+	StateAttention     = "ATTENTION" // Waiting for the user, e.g. after a filament runout
 	StateOffline       = "offline"
 	StateNotConfigured = "not_configured"
 )
@@ -14,8 +17,22 @@ const (
 	DefaultSpoolmanURL          = "http://localhost:7912"
 	DefaultWebPort              = "5000"
 	DefaultPollInterval         = 30
+	DefaultActivePollInterval   = 10 // seconds, used while a printer is printing
 	DefaultLocationSyncInterval = 5 // minutes
 	DefaultDBFileName           = "filabridge.db"
+	DefaultMonitorStartDelay    = 0 // seconds
+	DefaultMonitorJitter        = 0 // seconds
+	DefaultLowStockThreshold    = 100 // grams
+	DefaultPrusaConnectURL      = "https://connect.prusa3d.com"
+	DefaultAutoPauseMinWeight   = 5 // grams
+	DefaultIdleSpoolReminderDays = 0 // disabled
+	DefaultClockDriftThreshold   = 120 // seconds
+	DefaultEstimatedFlowRate     = 15 // grams (not mm³) per hour of printing, averaged over travel and infill, for usage estimates
+	DefaultNFCSessionTimeout     = 5  // minutes
+	DefaultSpoolmanWriteDelay    = 0  // milliseconds between Spoolman writes for a finished print, 0 disables
+	DefaultBackupRetention       = 7  // scheduled backups kept in the backup directory
+	DefaultReadinessSpoolmanWindow = 5 // minutes since Spoolman last answered before /readyz fails
+	DefaultPrintProcessingWorkers  = 4 // finished prints processed at once across all printers
 )
 
 // Database configuration keys
@@ -33,6 +50,71 @@ const (
 	ConfigKeySpoolmanPassword             = "spoolman_password"
 	ConfigKeyAutoAssignPreviousSpoolEnabled = "auto_assign_previous_spool_enabled"
 	ConfigKeyAutoAssignPreviousSpoolLocation = "auto_assign_previous_spool_location"
+	ConfigKeyMonitorStartDelay               = "monitor_start_delay"
+	ConfigKeyMonitorJitter                   = "monitor_jitter"
+	ConfigKeyPrusaLinkEventsEnabled          = "prusalink_events_enabled"
+	ConfigKeySpoolmanEventsEnabled           = "spoolman_events_enabled"
+	ConfigKeyLowStockThreshold               = "low_stock_threshold"
+	ConfigKeyPrusaConnectURL                 = "prusa_connect_url"
+	ConfigKeyActivePollInterval              = "active_poll_interval"
+	ConfigKeyAutoPauseEmptySpool             = "auto_pause_empty_spool"
+	ConfigKeyAutoPauseUnmapped               = "auto_pause_unmapped"
+	ConfigKeyAutoPauseMinWeight              = "auto_pause_min_weight"
+	ConfigKeyEmptySpoolAutoArchive           = "empty_spool_auto_archive"
+	ConfigKeyLowFilamentCheck                = "low_filament_check"
+	ConfigKeyRunoutPredictionEnabled         = "runout_prediction_enabled"
+	ConfigKeyActiveSpoolEstimates            = "active_spool_estimates"
+	ConfigKeyRunoutDetectionEnabled          = "runout_detection_enabled"
+	ConfigKeyNotificationChannels            = "notification_channels"
+	ConfigKeyOutgoingWebhooks                = "outgoing_webhooks"
+	ConfigKeySpoolmanInstances               = "spoolman_instances"
+	ConfigKeyAdminTokenHash                  = "admin_token_hash"
+	ConfigKeyIdleSpoolReminderDays           = "idle_spool_reminder_days"
+	ConfigKeyIdleSpoolReturnLocation         = "idle_spool_return_location"
+	ConfigKeyExternalURL                     = "external_url"
+	ConfigKeyActionLinkSecret                = "action_link_secret"
+	ConfigKeyClockDriftThreshold             = "clock_drift_threshold"
+	ConfigKeyScheduledJobs                   = "scheduled_jobs"
+	ConfigKeyMQTTBroker                      = "mqtt_broker"
+	ConfigKeyMQTTUsername                    = "mqtt_username"
+	ConfigKeyMQTTPassword                    = "mqtt_password"
+	ConfigKeyMQTTTopicPrefix                 = "mqtt_topic_prefix"
+	ConfigKeyMQTTDiscoveryPrefix             = "mqtt_discovery_prefix"
+	ConfigKeyEditableSpoolFields             = "editable_spool_fields"
+	ConfigKeySpoolOwnerField                 = "spool_owner_field"
+	ConfigKeyLogLevel                        = "log_level"
+	ConfigKeyLogFormat                       = "log_format"
+	ConfigKeyLogModuleLevels                 = "log_module_levels"
+	ConfigKeyLogBufferSize                   = "log_buffer_size"
+	ConfigKeyAuthUsername                    = "auth_username"
+	ConfigKeyAuthPasswordHash                = "auth_password_hash"
+	ConfigKeyNFCTokenSecret                  = "nfc_token_secret"
+	ConfigKeyEstimatedFlowRate               = "estimated_flow_rate"
+	ConfigKeyDefaultFilamentDensity          = "default_filament_density"
+	ConfigKeyDefaultFilamentDiameter         = "default_filament_diameter"
+	ConfigKeyBasePath                        = "base_path"
+	ConfigKeyTLSCertFile                     = "tls_cert_file"
+	ConfigKeyTLSKeyFile                      = "tls_key_file"
+	ConfigKeyTLSACMEDomains                  = "tls_acme_domains"
+	ConfigKeyTLSACMEEmail                    = "tls_acme_email"
+	ConfigKeyNFCSessionTimeout               = "nfc_session_timeout"
+	ConfigKeySpoolmanWriteDelay              = "spoolman_write_delay"
+	ConfigKeyCombineNotifications            = "combine_completion_notifications"
+	ConfigKeyFilamentMismatchCheck           = "filament_mismatch_check"
+	ConfigKeySpoolmanProxyEnabled            = "spoolman_proxy_enabled"
+	ConfigKeyWasteSpoolField                 = "waste_spool_field"
+	ConfigKeyBackupDir                       = "backup_dir"
+	ConfigKeyBackupRetention                 = "backup_retention"
+	ConfigKeyReadinessSpoolmanWindow         = "readiness_spoolman_window"
+	ConfigKeyPrintProcessingWorkers          = "print_processing_workers"
+	ConfigKeySpoolmanInsecureSkipVerify      = "spoolman_insecure_skip_verify"
+	ConfigKeySpoolLowStockField              = "spool_low_stock_field"
+	ConfigKeyScales                          = "scales"
+	ConfigKeyScaleDiscrepancyThreshold       = "scale_discrepancy_threshold"
+	ConfigKeyEnvironmentSensors              = "environment_sensors"
+	ConfigKeyDryStorageHumidity              = "dry_storage_humidity"
+	ConfigKeyToolheadRuleCheck               = "toolhead_rule_check"
+	ConfigKeyBarcodeLookupURL                = "barcode_lookup_url"
 )
 
 // HTTP timeouts
@@ -42,6 +124,190 @@ const (
 	SpoolmanTimeout              = 10  // seconds
 )
 
+// Spoolman response cache settings
+const (
+	SpoolmanCacheTTL = 10 * time.Second // How long a fetched spool list is reused; ?refresh=true skips it
+)
+
+// Print history settings
+const (
+	MaxPrintRating           = 5   // Highest success rating for a print history entry
+	DefaultPrintHistoryLimit = 100 // Entries returned by history endpoints when no limit is given
+	FailedPrintRating        = 2   // Ratings at or below this count a print as failed in statistics
+	StatsTopFilamentLimit    = 10  // Filaments listed as most used in statistics
+)
+
+// Notification settings
+const (
+	NotificationTimeout             = 10 * time.Second // HTTP timeout for delivering a notification
+	NotificationDigestCheckInterval = time.Minute      // How often held notifications are checked for delivery
+	PrinterOfflineNotifyDelay       = 2 * time.Minute  // How long a printer must be unreachable before notifying
+	TelegramAPIURL                  = "https://api.telegram.org"
+	ExecHookTimeout                 = 30 * time.Second // How long an exec hook may run before it's killed
+	ExecHookOutputLimit             = 4096             // Bytes of a failed hook's output kept for the log
+	ExecHooksEnv                    = "FILABRIDGE_EXEC_HOOKS" // Must be "true" for exec hooks to run
+	OutgoingWebhookAttempts         = 3                // Tries per lifecycle event before giving up
+	OutgoingWebhookRetryDelay       = 5 * time.Second  // Wait after a failed try, growing with each attempt
+)
+
+// Idle spool reminder settings
+const (
+	IdleSpoolCheckInterval = time.Hour // How often mapped spools are checked for inactivity
+)
+
+// Mapping reconciliation settings
+const (
+	MappingReconcileInterval = 6 * time.Hour // How often mappings are compared with Spoolman locations
+)
+
+// Database backup settings
+const (
+	DatabaseBackupInterval = 24 * time.Hour    // How often scheduled backups are written
+	MaxRestoreUploadSize   = 512 * 1024 * 1024 // Largest database accepted by a restore
+	BackupFilePrefix       = "filabridge-"
+)
+
+// Configuration export settings
+const (
+	ConfigExportVersion    = 1               // Format version written to exports; newer ones are refused on import
+	MaxConfigImportSize    = 8 * 1024 * 1024 // Largest export accepted by an import
+	ConfigExportFilePrefix = "filabridge-config-"
+)
+
+// G-code analysis settings
+const (
+	MaxGcodeAnalysisSize = 256 * 1024 * 1024 // Largest file accepted by an analysis upload
+)
+
+// Health check settings
+const (
+	HealthCheckTimeout = 3 * time.Second // Longest a probe waits on the database or Spoolman
+)
+
+// Environment settings
+const (
+	ConfigEnvPrefix = "FILABRIDGE_"        // Followed by an upper-cased config key, e.g. FILABRIDGE_SPOOLMAN_URL
+	DBPathEnv       = "FILABRIDGE_DB_PATH" // Directory holding the database
+)
+
+// Spoolman update retry settings
+const (
+	PendingUpdateCheckInterval  = 30 * time.Second // How often queued updates are checked for due retries
+	PendingUpdateInitialBackoff = 30 * time.Second // Wait before the first retry, doubled after each failure
+	PendingUpdateMaxBackoff     = time.Hour        // Longest wait between retries
+	PendingUpdateMaxAttempts    = 24               // Attempts before an update is reported as a print error
+)
+
+// Scheduler settings
+const (
+	SchedulerTickInterval     = 5 * time.Second // How often the scheduler checks for due jobs
+	NFCSessionCleanupInterval = time.Minute     // How often expired NFC sessions are removed
+)
+
+// ACMECacheDir is where certificates obtained with ACME are stored, next to the database
+const ACMECacheDir = "autocert"
+
+// Authentication settings
+const (
+	AuthSessionDuration    = 30 * 24 * time.Hour // How long a UI login lasts
+	MinLoginPasswordLength = 8
+)
+
+// In-app log buffer settings
+const (
+	DefaultLogBufferSize  = 1000             // Log entries kept in memory for /api/logs
+	MaxLogBufferSize      = 50000            // Upper bound for log_buffer_size
+	LogStreamBuffer       = 256              // Entries queued per log stream client before entries are dropped
+	LogStreamPingInterval = 30 * time.Second // Keeps idle log stream websockets open
+)
+
+// Server-sent event stream settings
+const (
+	EventStreamBuffer            = 256              // Messages queued per /api/events client before it's dropped
+	EventStreamKeepAliveInterval = 30 * time.Second // Comment lines that keep idle streams open through proxies
+)
+
+// Spool owner settings
+const (
+	DefaultSpoolOwnerField = "owner" // Spoolman extra field key holding a spool's owner
+)
+
+// Smart scale settings
+const (
+	DefaultScaleDiscrepancyThreshold = 50               // grams
+	DefaultScaleReadingLimit         = 100              // Readings listed when no limit is given
+	ScaleReadingTolerance            = 1.0              // Grams of difference from Spoolman that aren't corrected
+	ScaleMinWeight                   = 5.0              // Grams below which a scale is taken to be empty
+	ScaleSettleTime                  = 10 * time.Second // A scale's weight must stop changing this long before it's recorded
+)
+
+// Low stock settings
+const (
+	DefaultSpoolLowStockField = "low_stock_threshold" // Spoolman extra field key holding a spool's own threshold
+	LowStockCheckInterval     = time.Hour             // How often all spools are checked against their thresholds
+)
+
+// Environment settings
+const (
+	DefaultDryStorageHumidity   = 30                  // % relative humidity at or below which a location keeps spools dry
+	EnvironmentReadingMaxAge    = 2 * time.Hour       // Older readings don't decide whether a location is dry
+	EnvironmentReadingRetention = 30 * 24 * time.Hour // How long humidity and temperature history is kept
+	SpoolExposureCheckInterval  = 10 * time.Minute    // How often spool exposure time is updated
+)
+
+// Home Assistant MQTT settings
+const (
+	DefaultMQTTTopicPrefix        = "filabridge"
+	DefaultMQTTDiscoveryPrefix    = "homeassistant"
+	HomeAssistantPublishInterval  = 30 * time.Second // How often state is published to MQTT
+	MQTTConnectTimeout            = 10 * time.Second
+	MQTTKeepAlive                 = 60 * time.Second
+	MQTTSubscriptionRetryInterval = 30 * time.Second // Wait before resubscribing to scales or sensors after a failure
+)
+
+// Printer monitor settings
+const (
+	MonitorReconcileInterval = 30 * time.Second // How often monitor goroutines are synced with printer configs
+	MonitorUpdateDebounce    = 2 * time.Second  // Minimum time between status broadcasts triggered by checks
+	ShutdownTimeout          = 30 * time.Second // How long shutdown waits for requests and print processing to finish
+)
+
+// Processed print settings
+const (
+	ProcessedPrintWindow    = time.Hour           // Finishes of the same job and file this close together are one print
+	ProcessedPrintRetention = 30 * 24 * time.Hour // How long processed prints are remembered
+)
+
+// Print processing queue settings
+const (
+	PrintQueueCapacity = 100 // Finished prints waiting for a worker before printer checks wait for room
+)
+
+// PrusaLink event subscription settings
+const (
+	PrusaLinkEventsPath            = "/api/v1/events" // Websocket event endpoint on firmware that supports push
+	PrusaLinkEventRetryInterval    = 5 * time.Minute  // Wait before retrying a failed or unsupported subscription
+	PrusaLinkEventMinCheckInterval = 2 * time.Second  // Minimum time between event-triggered status checks
+)
+
+// Spoolman event subscription settings
+const (
+	SpoolmanEventsPath         = "/api/v1/"       // Websocket of every entity change in Spoolman
+	SpoolmanEventRetryInterval = 30 * time.Second // Wait before reconnecting a failed or dropped subscription
+	SpoolmanEventDebounce      = time.Second      // Changes arriving together are broadcast once
+)
+
+// Printer discovery settings
+const (
+	SSDPAddress           = "239.255.255.250:1900"
+	SSDPSearchWait        = 3 * time.Second // How long SSDP answers are collected
+	MDNSAddress           = "224.0.0.251:5353"
+	MDNSBrowseWait        = 3 * time.Second // How long mDNS answers are collected
+	DiscoveryProbeTimeout = 2 * time.Second // Per-address timeout when checking for PrusaLink
+	DiscoveryScanWorkers  = 64              // Addresses probed at once
+	MaxDiscoveryScanHosts = 1024            // Largest subnet that can be scanned, a /22
+)
+
 // Printer model detection patterns
 const (
 	ModelCorePattern = "core"
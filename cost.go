@@ -0,0 +1,36 @@
+package main
+
+// spoolPricePerGram returns what a gram of a spool's filament cost, from the spool's own price
+// over its initial weight, or else its filament's price over the filament's weight. It returns
+// false when neither has a price.
+func spoolPricePerGram(spool SpoolmanSpool) (float64, bool) {
+	if spool.Price > 0 {
+		weight := spool.InitialWeight
+		if weight <= 0 && spool.Filament != nil {
+			weight = spool.Filament.Weight
+		}
+		if weight > 0 {
+			return spool.Price / weight, true
+		}
+	}
+	if spool.Filament != nil && spool.Filament.Price > 0 && spool.Filament.Weight > 0 {
+		return spool.Filament.Price / spool.Filament.Weight, true
+	}
+	return 0, false
+}
+
+// usageCost returns what grams of a spool's filament cost at the spool's price in the
+// printer's Spoolman, or nil when the spool has no price or can't be looked up
+func (b *FilamentBridge) usageCost(printerName string, spoolID int, grams float64) *float64 {
+	spool, err := b.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil {
+		bridgeLog.Warn("Failed to get spool price", "spool_id", spoolID, "error", err)
+		return nil
+	}
+	pricePerGram, priced := spoolPricePerGram(*spool)
+	if !priced {
+		return nil
+	}
+	cost := pricePerGram * grams
+	return &cost
+}
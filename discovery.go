@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Discovery sources, saying how a printer was found
+const (
+	DiscoverySourceMDNS = "mdns"
+	DiscoverySourceSSDP = "ssdp"
+	DiscoverySourceScan = "scan"
+)
+
+// mdnsServices are the mDNS service types PrusaLink announces itself under
+var mdnsServices = []string{"_octoprint._tcp.local.", "_http._tcp.local."}
+
+// DiscoveredPrinter is a PrusaLink printer found on the network
+type DiscoveredPrinter struct {
+	Address    string `json:"address"` // Usable as a printer's ip_address
+	Hostname   string `json:"hostname,omitempty"`
+	Model      string `json:"model"`
+	Source     string `json:"source"`
+	Configured bool   `json:"configured"` // Already added to FilaBridge
+}
+
+// prusaLinkVersion is the part of the /api/version response used to recognize PrusaLink
+type prusaLinkVersion struct {
+	Text     string `json:"text"`
+	Server   string `json:"server"`
+	Original string `json:"original"`
+	Hostname string `json:"hostname"`
+}
+
+// DiscoverPrinters looks for PrusaLink printers by browsing mDNS, with an SSDP search and,
+// when a subnet is given, by probing every address in it. Answers from other devices are
+// dropped, so only addresses that respond like PrusaLink are returned.
+func (b *FilamentBridge) DiscoverPrinters(subnet string) ([]DiscoveredPrinter, error) {
+	candidates := make(map[string]string) // Address to source
+	if subnet != "" {
+		hosts, err := subnetHosts(subnet)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			candidates[host] = DiscoverySourceScan
+		}
+	}
+
+	// Both searches wait for answers, so they run side by side
+	var (
+		ssdpAddresses []string
+		mdnsHosts     map[string]string
+		searches      sync.WaitGroup
+	)
+	searches.Add(2)
+	go func() {
+		defer searches.Done()
+		var err error
+		ssdpAddresses, err = ssdpSearch(SSDPSearchWait)
+		if err != nil {
+			// Multicast is often unavailable in containers; a subnet scan still works there
+			bridgeLog.Warn("SSDP search failed", "error", err)
+		}
+	}()
+	go func() {
+		defer searches.Done()
+		var err error
+		mdnsHosts, err = mdnsBrowse(MDNSBrowseWait)
+		if err != nil {
+			bridgeLog.Warn("mDNS browse failed", "error", err)
+		}
+	}()
+	searches.Wait()
+
+	for _, address := range ssdpAddresses {
+		candidates[address] = DiscoverySourceSSDP
+	}
+	for address := range mdnsHosts {
+		candidates[address] = DiscoverySourceMDNS
+	}
+
+	addresses := make(chan string)
+	var (
+		found = []DiscoveredPrinter{}
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+	)
+	client := &http.Client{Timeout: DiscoveryProbeTimeout}
+	for i := 0; i < DiscoveryScanWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range addresses {
+				printer, ok := probePrusaLink(client, address, mdnsHosts[address])
+				if !ok {
+					continue
+				}
+				printer.Source = candidates[address]
+				mutex.Lock()
+				found = append(found, printer)
+				mutex.Unlock()
+			}
+		}()
+	}
+	for address := range candidates {
+		addresses <- address
+	}
+	close(addresses)
+	wg.Wait()
+
+	configured := make(map[string]bool)
+	for _, config := range b.GetConfigSnapshot().Printers {
+		configured[strings.ToLower(printerHostname(config.IPAddress))] = true
+	}
+	for i := range found {
+		found[i].Configured = configured[strings.ToLower(found[i].Address)] ||
+			(found[i].Hostname != "" && configured[strings.ToLower(found[i].Hostname)])
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Address < found[j].Address })
+
+	bridgeLog.Info("Printer discovery finished", "subnet", subnet, "candidates", len(candidates), "found", len(found))
+	return found, nil
+}
+
+// subnetHosts lists the host addresses in an IPv4 subnet, without its network and broadcast
+// addresses
+func subnetHosts(subnet string) ([]string, error) {
+	_, network, err := net.ParseCIDR(strings.TrimSpace(subnet))
+	if err != nil || network.IP.To4() == nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "subnet must be an IPv4 CIDR such as 192.168.1.0/24")
+	}
+	ones, bits := network.Mask.Size()
+	size := 1 << (bits - ones)
+	if size > MaxDiscoveryScanHosts {
+		return nil, newCodedError(ErrCodeInvalidRequest, "subnet %s is too large to scan (at most %d addresses)", subnet, MaxDiscoveryScanHosts)
+	}
+
+	base := network.IP.To4()
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	first, last := 0, size-1
+	if size > 2 {
+		first, last = 1, size-2
+	}
+	hosts := make([]string, 0, last-first+1)
+	for offset := first; offset <= last; offset++ {
+		ip := start + uint32(offset)
+		hosts = append(hosts, net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)).String())
+	}
+	return hosts, nil
+}
+
+// ssdpSearch sends an SSDP M-SEARCH and returns the addresses of every device that answers
+// within wait: where the answer came from, and the host in its LOCATION header when that's
+// somewhere else, such as another port
+func ssdpSearch(wait time.Duration) ([]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	target, err := net.ResolveUDPAddr("udp4", SSDPAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve SSDP address: %w", err)
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + SSDPAddress + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		fmt.Sprintf("MX: %d\r\n", int(wait/time.Second)) +
+		"ST: ssdp:all\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), target); err != nil {
+		return nil, fmt.Errorf("failed to send SSDP search: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var addresses []string
+	buffer := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(wait))
+	for {
+		n, from, err := conn.ReadFrom(buffer)
+		if err != nil {
+			// The deadline ends the search
+			break
+		}
+		var found []string
+		if udpAddr, ok := from.(*net.UDPAddr); ok {
+			found = append(found, udpAddr.IP.String())
+		}
+		if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buffer[:n])), nil); err == nil {
+			if location, err := url.Parse(resp.Header.Get("Location")); err == nil && location.Host != "" {
+				found = append(found, location.Host)
+			}
+		}
+		for _, address := range found {
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// mdnsBrowse asks for the services PrusaLink announces and returns the addresses that answer
+// within wait, each with the hostname it announced. Queries come from an ephemeral port, so
+// responders answer straight back to it instead of to the whole network.
+func mdnsBrowse(wait time.Duration) (map[string]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mDNS socket: %w", err)
+	}
+	defer conn.Close()
+
+	target, err := net.ResolveUDPAddr("udp4", MDNSAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mDNS address: %w", err)
+	}
+	query := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	query.EnableCompression()
+	if err := query.StartQuestions(); err != nil {
+		return nil, fmt.Errorf("failed to build mDNS query: %w", err)
+	}
+	for _, service := range mdnsServices {
+		question := dnsmessage.Question{
+			Name:  dnsmessage.MustNewName(service),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}
+		if err := query.Question(question); err != nil {
+			return nil, fmt.Errorf("failed to build mDNS query: %w", err)
+		}
+	}
+	packet, err := query.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mDNS query: %w", err)
+	}
+	if _, err := conn.WriteTo(packet, target); err != nil {
+		return nil, fmt.Errorf("failed to send mDNS query: %w", err)
+	}
+
+	hosts := make(map[string]string)
+	buffer := make([]byte, 9000) // Largest mDNS packet
+	conn.SetReadDeadline(time.Now().Add(wait))
+	for {
+		n, from, err := conn.ReadFrom(buffer)
+		if err != nil {
+			// The deadline ends the browse
+			break
+		}
+		udpAddr, ok := from.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		for address, hostname := range parseMDNSResponse(buffer[:n], udpAddr.IP.String()) {
+			if hosts[address] == "" {
+				hosts[address] = hostname
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// parseMDNSResponse finds the hosts in an mDNS answer: each SRV target at its IPv4 address
+// and port, when the answer includes one, otherwise at the address it came from
+func parseMDNSResponse(packet []byte, from string) map[string]string {
+	var message dnsmessage.Message
+	if err := message.Unpack(packet); err != nil || !message.Header.Response {
+		return nil
+	}
+
+	// Responders put SRV and A records in either the answers or the additional records
+	targets := make(map[string]uint16) // Hostname to port
+	addresses := make(map[string]string)
+	for _, resource := range append(message.Answers, message.Additionals...) {
+		switch body := resource.Body.(type) {
+		case *dnsmessage.SRVResource:
+			targets[body.Target.String()] = body.Port
+		case *dnsmessage.AResource:
+			addresses[resource.Header.Name.String()] = net.IP(body.A[:]).String()
+		}
+	}
+
+	hosts := make(map[string]string)
+	for target, port := range targets {
+		ip := addresses[target]
+		if ip == "" {
+			ip = from
+		}
+		address := ip
+		if port != 0 && port != 80 {
+			address = net.JoinHostPort(ip, fmt.Sprint(port))
+		}
+		hosts[address] = strings.TrimSuffix(target, ".")
+	}
+	return hosts
+}
+
+// probePrusaLink checks whether an address answers like PrusaLink. Without an API key
+// PrusaLink answers /api/version with 401, so its authentication challenge counts as well.
+// hostname, when known from the address's mDNS announcement, is used if PrusaLink gives none.
+func probePrusaLink(client *http.Client, address, hostname string) (DiscoveredPrinter, bool) {
+	printer := DiscoveredPrinter{Address: address, Model: ModelUnknown}
+
+	resp, err := client.Get("http://" + address + "/api/version")
+	if err != nil {
+		return printer, false
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	server := strings.ToLower(resp.Header.Get("Server"))
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var version prusaLinkVersion
+		if err := json.Unmarshal(body, &version); err != nil {
+			return printer, false
+		}
+		signature := strings.ToLower(version.Text + " " + version.Original + " " + version.Server)
+		if !strings.Contains(signature, "prusalink") && !strings.Contains(signature, "prusa-link") &&
+			!strings.Contains(server, "prusa") {
+			return printer, false
+		}
+		printer.Hostname = version.Hostname
+	case http.StatusUnauthorized:
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if !strings.Contains(challenge, `realm="Printer API"`) && !strings.Contains(server, "prusa") {
+			return printer, false
+		}
+	default:
+		return printer, false
+	}
+
+	if printer.Hostname == "" {
+		printer.Hostname = hostname
+	}
+	if printer.Hostname == "" {
+		printer.Hostname = reverseLookup(address)
+	}
+	if printer.Hostname != "" {
+		printer.Model = detectPrinterModel(printer.Hostname)
+	}
+	return printer, true
+}
+
+// reverseLookup returns the DNS name of an address, or "" when it has none
+func reverseLookup(address string) string {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DiscoveryProbeTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, host)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// discoverPrintersHandler finds PrusaLink printers to add, optionally scanning ?subnet=
+func (ws *WebServer) discoverPrintersHandler(c *gin.Context) {
+	printers, err := ws.bridge.DiscoverPrinters(c.Query("subnet"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, DiscoveredPrintersResponse{Printers: printers})
+}
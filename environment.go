@@ -0,0 +1,703 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Environment sensor measures, for sensors publishing a single value per topic
+const (
+	EnvironmentMeasureHumidity    = "humidity"
+	EnvironmentMeasureTemperature = "temperature"
+)
+
+// SpoolEventDried is the spool event of a spool marked as dried
+const SpoolEventDried = "dried"
+
+// EnvironmentSensor is a humidity or temperature sensor in a storage location publishing
+// over MQTT, e.g. an ESPHome sensor in a drybox
+type EnvironmentSensor struct {
+	Location string `json:"location"`
+	Topic    string `json:"topic"`
+	Measure  string `json:"measure,omitempty"` // humidity or temperature for a plain number; empty for JSON {"humidity": ..., "temperature": ...}
+}
+
+// LocationEnvironment is the environment of a storage location: whether it's a drybox and the
+// latest humidity and temperature its sensors reported
+type LocationEnvironment struct {
+	Location    string     `json:"location"`
+	Drybox      bool       `json:"drybox"`                // Sealed dry storage, counted as dry when there's no recent humidity reading
+	Humidity    *float64   `json:"humidity,omitempty"`    // Relative humidity in %
+	Temperature *float64   `json:"temperature,omitempty"` // °C
+	ReadingAt   *time.Time `json:"reading_at,omitempty"`
+	Dry         bool       `json:"dry"` // Whether spools stored here are kept dry now
+}
+
+// EnvironmentReading is one humidity and temperature report from a location
+type EnvironmentReading struct {
+	Humidity    *float64  `json:"humidity,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// SpoolExposure is how long a spool has spent in dry storage and exposed to room air since
+// it was last dried, or since FilaBridge started tracking it
+type SpoolExposure struct {
+	SpoolmanInstance   string     `json:"spoolman_instance,omitempty"` // Empty for the main instance
+	SpoolID            int        `json:"spool_id"`
+	Material           string     `json:"material,omitempty"`
+	Location           string     `json:"location"`
+	Dry                bool       `json:"dry"` // Whether its location kept it dry at the last check
+	ExposedHours       float64    `json:"exposed_hours"`
+	DryHours           float64    `json:"dry_hours"`
+	ExposureLimitHours *float64   `json:"exposure_limit_hours,omitempty"` // From the material defaults
+	DriedAt            *time.Time `json:"dried_at,omitempty"`
+	AlertedAt          *time.Time `json:"alerted_at,omitempty"` // When it was reported over its exposure limit
+	CheckedAt          time.Time  `json:"checked_at"`
+}
+
+// parseEnvironmentSensors parses and validates the JSON sensor list from the configuration table
+func parseEnvironmentSensors(value string) ([]EnvironmentSensor, error) {
+	sensors := []EnvironmentSensor{}
+	if strings.TrimSpace(value) == "" {
+		return sensors, nil
+	}
+	if err := json.Unmarshal([]byte(value), &sensors); err != nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "invalid environment sensors: %v", err)
+	}
+
+	topics := make(map[string]bool)
+	for i := range sensors {
+		sensor := &sensors[i]
+		sensor.Location = strings.TrimSpace(sensor.Location)
+		sensor.Topic = strings.TrimSpace(sensor.Topic)
+		switch {
+		case sensor.Location == "":
+			return nil, newCodedError(ErrCodeInvalidRequest, "environment sensor %d needs a location", i+1)
+		case sensor.Topic == "" || strings.ContainsAny(sensor.Topic, "+#"):
+			return nil, newCodedError(ErrCodeInvalidRequest, "environment sensor of %s needs a topic without wildcards", sensor.Location)
+		case topics[sensor.Topic]:
+			return nil, newCodedError(ErrCodeInvalidRequest, "environment sensors share the topic %s", sensor.Topic)
+		}
+		switch sensor.Measure {
+		case "", EnvironmentMeasureHumidity, EnvironmentMeasureTemperature:
+		default:
+			return nil, newCodedError(ErrCodeInvalidRequest, "unknown environment sensor measure: %s", sensor.Measure)
+		}
+		topics[sensor.Topic] = true
+	}
+	return sensors, nil
+}
+
+// RecordEnvironmentReading stores a humidity and/or temperature reading of a location
+func (b *FilamentBridge) RecordEnvironmentReading(location string, humidity, temperature *float64) error {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return newCodedError(ErrCodeInvalidRequest, "location is required")
+	}
+	if humidity == nil && temperature == nil {
+		return newCodedError(ErrCodeInvalidRequest, "humidity or temperature is required")
+	}
+	if humidity != nil && (*humidity < 0 || *humidity > 100 || math.IsNaN(*humidity)) {
+		return newCodedError(ErrCodeInvalidRequest, "humidity must be between 0 and 100%%")
+	}
+	if temperature != nil && (math.IsNaN(*temperature) || math.IsInf(*temperature, 0)) {
+		return newCodedError(ErrCodeInvalidRequest, "invalid temperature")
+	}
+
+	now := time.Now()
+
+	// Sensors publishing humidity and temperature separately each update only their own value
+	_, err := b.db.Exec(`
+		INSERT INTO location_environments (location, humidity, temperature, reading_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(location) DO UPDATE SET
+			humidity = COALESCE(excluded.humidity, humidity),
+			temperature = COALESCE(excluded.temperature, temperature),
+			reading_at = excluded.reading_at
+	`, location, nullFloat(humidity), nullFloat(temperature), now)
+	if err != nil {
+		return fmt.Errorf("failed to record environment reading: %w", err)
+	}
+	if _, err := b.db.Exec("INSERT INTO environment_readings (location, humidity, temperature, recorded_at) VALUES (?, ?, ?, ?)",
+		location, nullFloat(humidity), nullFloat(temperature), now); err != nil {
+		return fmt.Errorf("failed to record environment reading: %w", err)
+	}
+	return nil
+}
+
+// pruneEnvironmentReadings removes readings older than EnvironmentReadingRetention
+func (b *FilamentBridge) pruneEnvironmentReadings() error {
+	if _, err := b.db.Exec("DELETE FROM environment_readings WHERE recorded_at < ?", time.Now().Add(-EnvironmentReadingRetention)); err != nil {
+		return fmt.Errorf("failed to prune environment readings: %w", err)
+	}
+	return nil
+}
+
+// SetLocationDrybox marks a location as a drybox or not
+func (b *FilamentBridge) SetLocationDrybox(location string, drybox bool) error {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return newCodedError(ErrCodeInvalidRequest, "location is required")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	_, err := b.db.Exec(`INSERT INTO location_environments (location, drybox) VALUES (?, ?)
+		ON CONFLICT(location) DO UPDATE SET drybox = excluded.drybox`, location, drybox)
+	if err != nil {
+		return fmt.Errorf("failed to save location environment: %w", err)
+	}
+	return nil
+}
+
+// GetLocationEnvironments returns the environment of every location that has one, keyed by
+// lower-case location name. A location is dry when its latest humidity reading, if recent, is
+// at most dry_storage_humidity; without one it's dry when it's a drybox.
+func (b *FilamentBridge) GetLocationEnvironments(now time.Time) (map[string]LocationEnvironment, error) {
+	rows, err := b.db.Query("SELECT location, COALESCE(drybox, 0), humidity, temperature, reading_at FROM location_environments")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location environments: %w", err)
+	}
+	defer rows.Close()
+
+	dryHumidity := float64(DefaultDryStorageHumidity)
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		dryHumidity = snapshot.DryStorageHumidity
+	}
+
+	environments := make(map[string]LocationEnvironment)
+	for rows.Next() {
+		var environment LocationEnvironment
+		var humidity, temperature sql.NullFloat64
+		var readingAt sql.NullTime
+		if err := rows.Scan(&environment.Location, &environment.Drybox, &humidity, &temperature, &readingAt); err != nil {
+			return nil, fmt.Errorf("failed to scan location environment: %w", err)
+		}
+		environment.Humidity = floatPtr(humidity)
+		environment.Temperature = floatPtr(temperature)
+		if readingAt.Valid {
+			environment.ReadingAt = &readingAt.Time
+		}
+
+		environment.Dry = environment.Drybox
+		if environment.Humidity != nil && readingAt.Valid && now.Sub(readingAt.Time) <= EnvironmentReadingMaxAge {
+			environment.Dry = *environment.Humidity <= dryHumidity
+		}
+		environments[strings.ToLower(environment.Location)] = environment
+	}
+	return environments, rows.Err()
+}
+
+// getLocationEnvironment returns a location's environment, or an empty one if it has none
+func (b *FilamentBridge) getLocationEnvironment(location string) (LocationEnvironment, error) {
+	environments, err := b.GetLocationEnvironments(time.Now())
+	if err != nil {
+		return LocationEnvironment{}, err
+	}
+	if environment, exists := environments[strings.ToLower(strings.TrimSpace(location))]; exists {
+		return environment, nil
+	}
+	return LocationEnvironment{Location: location}, nil
+}
+
+// GetEnvironmentReadings returns a location's readings since a time, oldest first
+func (b *FilamentBridge) GetEnvironmentReadings(location string, since time.Time) ([]EnvironmentReading, error) {
+	rows, err := b.db.Query(
+		"SELECT humidity, temperature, recorded_at FROM environment_readings WHERE LOWER(location) = LOWER(?) AND recorded_at >= ? ORDER BY recorded_at",
+		strings.TrimSpace(location), since,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []EnvironmentReading{}
+	for rows.Next() {
+		var reading EnvironmentReading
+		var humidity, temperature sql.NullFloat64
+		if err := rows.Scan(&humidity, &temperature, &reading.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan environment reading: %w", err)
+		}
+		reading.Humidity = floatPtr(humidity)
+		reading.Temperature = floatPtr(temperature)
+		readings = append(readings, reading)
+	}
+	return readings, rows.Err()
+}
+
+// TrackSpoolExposure adds the time since the last check to each spool's dry or exposed time,
+// by whether its location kept it dry, and notifies about spools exposed for longer than
+// their material's exposure limit. Spools that were never used are taken to still be sealed.
+// It runs as a scheduled job, over the spools of every Spoolman instance.
+func (b *FilamentBridge) TrackSpoolExposure(now time.Time) error {
+	environments, err := b.GetLocationEnvironments(now)
+	if err != nil {
+		return err
+	}
+	materialDefaults, err := b.GetAllMaterialDefaults()
+	if err != nil {
+		bridgeLog.Warn("Failed to get material defaults for exposure tracking", "error", err)
+	}
+
+	var firstErr error
+	for _, instance := range b.spoolmanInstanceNames() {
+		if err := b.trackInstanceExposure(instance, environments, materialDefaults, now); err != nil {
+			bridgeLog.Warn("Spool exposure tracking failed", "spoolman_instance", instance, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// trackInstanceExposure tracks the exposure of the spools in one Spoolman instance
+func (b *FilamentBridge) trackInstanceExposure(instance string, environments map[string]LocationEnvironment,
+	materialDefaults map[string]MaterialDefaults, now time.Time) error {
+	spools, err := b.spoolmanInstance(instance).GetAllSpools()
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+	exposures, err := b.spoolExposures(instance)
+	if err != nil {
+		return err
+	}
+
+	for _, spool := range spools {
+		exposure, tracked := exposures[spool.ID]
+		if spool.Archived || (!tracked && spool.FirstUsed == "") {
+			continue
+		}
+
+		if tracked {
+			elapsed := now.Sub(exposure.CheckedAt).Hours()
+			if elapsed > 0 && exposure.Dry {
+				exposure.DryHours += elapsed
+			} else if elapsed > 0 {
+				exposure.ExposedHours += elapsed
+			}
+		} else {
+			exposure = SpoolExposure{SpoolmanInstance: instance, SpoolID: spool.ID}
+		}
+		exposure.Location = spool.Location
+		exposure.Dry = environments[strings.ToLower(strings.TrimSpace(spool.Location))].Dry
+		exposure.CheckedAt = now
+
+		if md, exists := materialDefaults[materialKey(spool.Material)]; exists && md.ExposureLimitHours != nil &&
+			*md.ExposureLimitHours > 0 && exposure.ExposedHours > *md.ExposureLimitHours && exposure.AlertedAt == nil {
+			b.notifySpoolExposed(spool, exposure, md)
+			exposure.AlertedAt = &now
+		}
+
+		if err := b.saveSpoolExposure(exposure); err != nil {
+			bridgeLog.Warn("Failed to save spool exposure", "spoolman_instance", instance, "spool_id", spool.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// notifySpoolExposed notifies that a spool has been out of dry storage longer than its
+// material's exposure limit
+func (b *FilamentBridge) notifySpoolExposed(spool SpoolmanSpool, exposure SpoolExposure, md MaterialDefaults) {
+	name := fmt.Sprintf("Spool %d", spool.ID)
+	if spool.Name != "" {
+		name = fmt.Sprintf("Spool %d (%s)", spool.ID, spool.Name)
+	}
+	message := fmt.Sprintf("%s has been out of dry storage for %.0f hours, over the %.0f hour limit for %s. Consider drying it",
+		name, exposure.ExposedHours, *md.ExposureLimitHours, spool.Material)
+	if md.DryingTemp != nil && md.DryingTimeHours != nil {
+		message += fmt.Sprintf(" at %.0f°C for %.0f hours", *md.DryingTemp, *md.DryingTimeHours)
+	}
+	message += "."
+
+	bridgeLog.Info("Spool over its exposure limit", "spool_id", spool.ID, "material", spool.Material, "exposed_hours", exposure.ExposedHours)
+	b.notifier.Notify(Notification{
+		Event:   NotificationEventSpoolExposed,
+		Title:   fmt.Sprintf("%s needs drying", name),
+		Message: message,
+	})
+}
+
+const spoolExposureColumns = "COALESCE(spoolman_instance, ''), spool_id, COALESCE(location, ''), COALESCE(dry, 0), exposed_hours, dry_hours, dried_at, alerted_at, checked_at"
+
+// scanSpoolExposure reads a spool's exposure from a query row
+func scanSpoolExposure(scanner interface{ Scan(...interface{}) error }) (SpoolExposure, error) {
+	var exposure SpoolExposure
+	var driedAt, alertedAt sql.NullTime
+	if err := scanner.Scan(&exposure.SpoolmanInstance, &exposure.SpoolID, &exposure.Location, &exposure.Dry, &exposure.ExposedHours, &exposure.DryHours,
+		&driedAt, &alertedAt, &exposure.CheckedAt); err != nil {
+		return exposure, err
+	}
+	if driedAt.Valid {
+		exposure.DriedAt = &driedAt.Time
+	}
+	if alertedAt.Valid {
+		exposure.AlertedAt = &alertedAt.Time
+	}
+	return exposure, nil
+}
+
+// spoolExposures returns the exposure of every tracked spool in a Spoolman instance by spool ID
+func (b *FilamentBridge) spoolExposures(instance string) (map[int]SpoolExposure, error) {
+	rows, err := b.db.Query("SELECT "+spoolExposureColumns+" FROM spool_exposure WHERE spoolman_instance = ?", instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spool exposure: %w", err)
+	}
+	defer rows.Close()
+
+	exposures := make(map[int]SpoolExposure)
+	for rows.Next() {
+		exposure, err := scanSpoolExposure(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan spool exposure: %w", err)
+		}
+		exposures[exposure.SpoolID] = exposure
+	}
+	return exposures, rows.Err()
+}
+
+// saveSpoolExposure stores a spool's exposure
+func (b *FilamentBridge) saveSpoolExposure(exposure SpoolExposure) error {
+	var driedAt, alertedAt interface{}
+	if exposure.DriedAt != nil {
+		driedAt = *exposure.DriedAt
+	}
+	if exposure.AlertedAt != nil {
+		alertedAt = *exposure.AlertedAt
+	}
+	_, err := b.db.Exec(
+		`INSERT OR REPLACE INTO spool_exposure (spoolman_instance, spool_id, location, dry, exposed_hours, dry_hours, dried_at, alerted_at, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		exposure.SpoolmanInstance, exposure.SpoolID, exposure.Location, exposure.Dry, exposure.ExposedHours, exposure.DryHours, driedAt, alertedAt, exposure.CheckedAt,
+	)
+	return err
+}
+
+// GetSpoolExposures returns the exposure of tracked spools, most exposed first, with their
+// material and its exposure limit, across every Spoolman instance. With spoolID set only that
+// spool is returned, from the printer's instance or the main one when printerName is empty.
+func (b *FilamentBridge) GetSpoolExposures(printerName string, spoolID int) ([]SpoolExposure, error) {
+	instances := b.spoolmanInstanceNames()
+	if spoolID > 0 {
+		instances = []string{b.spoolmanInstanceOf(printerName)}
+	}
+	materialDefaults, err := b.GetAllMaterialDefaults()
+	if err != nil {
+		bridgeLog.Warn("Failed to get material defaults for exposure", "error", err)
+	}
+
+	result := []SpoolExposure{}
+	for _, instance := range instances {
+		exposures, err := b.spoolExposures(instance)
+		if err != nil {
+			return nil, err
+		}
+		if len(exposures) == 0 {
+			continue
+		}
+		spools, err := b.spoolmanInstance(instance).GetAllSpools()
+		if err != nil {
+			spoolmanLog.Warn("Failed to get spools for exposure", "spoolman_instance", instance, "error", err)
+		}
+		materials := make(map[int]string, len(spools))
+		for _, spool := range spools {
+			materials[spool.ID] = spool.Material
+		}
+
+		for _, exposure := range exposures {
+			if spoolID > 0 && exposure.SpoolID != spoolID {
+				continue
+			}
+			exposure.ExposedHours = math.Round(exposure.ExposedHours*10) / 10
+			exposure.DryHours = math.Round(exposure.DryHours*10) / 10
+			exposure.Material = materials[exposure.SpoolID]
+			if md, exists := materialDefaults[materialKey(exposure.Material)]; exists {
+				exposure.ExposureLimitHours = md.ExposureLimitHours
+			}
+			result = append(result, exposure)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ExposedHours > result[j].ExposedHours })
+	return result, nil
+}
+
+// MarkSpoolDried restarts a spool's exposure after it was dried. The spool is in the
+// printer's Spoolman instance, the main one when printerName is empty.
+func (b *FilamentBridge) MarkSpoolDried(printerName string, spoolID int) error {
+	instance := b.spoolmanInstanceOf(printerName)
+	spool, err := b.spoolmanInstance(instance).GetSpool(spoolID)
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", spoolID, err)
+	}
+	environments, err := b.GetLocationEnvironments(time.Now())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	exposure := SpoolExposure{
+		SpoolmanInstance: instance,
+		SpoolID:          spoolID,
+		Location:         spool.Location,
+		Dry:              environments[strings.ToLower(strings.TrimSpace(spool.Location))].Dry,
+		DriedAt:          &now,
+		CheckedAt:        now,
+	}
+	if err := b.saveSpoolExposure(exposure); err != nil {
+		return fmt.Errorf("failed to save spool exposure: %w", err)
+	}
+	bridgeLog.Info("Spool marked as dried", "spoolman_instance", instance, "spool_id", spoolID)
+	b.logSpoolEvents(SpoolEvent{SpoolmanInstance: instance, SpoolID: spoolID, EventType: SpoolEventDried, CreatedAt: now})
+	return nil
+}
+
+// EnvironmentListener receives humidity and temperature readings from the configured sensors
+// over MQTT, using the broker set for Home Assistant
+type EnvironmentListener struct {
+	bridge *FilamentBridge
+}
+
+// NewEnvironmentListener creates a listener recording readings of the configured sensors
+func NewEnvironmentListener(bridge *FilamentBridge) *EnvironmentListener {
+	return &EnvironmentListener{bridge: bridge}
+}
+
+// Run keeps a subscription to the sensor topics open until ctx is cancelled
+func (l *EnvironmentListener) Run(ctx context.Context) {
+	subscription := mqttSubscription{
+		bridge:       l.bridge,
+		name:         "environment sensors",
+		clientSuffix: "-environment",
+		topics: func(snapshot *Config) []string {
+			topics := make([]string, 0, len(snapshot.EnvironmentSensors))
+			for _, sensor := range snapshot.EnvironmentSensors {
+				topics = append(topics, sensor.Topic)
+			}
+			return topics
+		},
+		receive: func(topic string, payload []byte) {
+			// Recording writes to the database, which mustn't hold up reading from the broker
+			go l.receive(topic, payload)
+		},
+	}
+	subscription.run(ctx)
+}
+
+// receive records a reading published on a sensor topic
+func (l *EnvironmentListener) receive(topic string, payload []byte) {
+	snapshot := l.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+	for _, sensor := range snapshot.EnvironmentSensors {
+		if sensor.Topic != topic {
+			continue
+		}
+		humidity, temperature, err := parseEnvironmentPayload(sensor.Measure, payload)
+		if err == nil {
+			err = l.bridge.RecordEnvironmentReading(sensor.Location, humidity, temperature)
+		}
+		if err != nil {
+			mqttLog.Debug("Ignoring environment reading", "location", sensor.Location, "payload", truncateEventPayload(payload), "error", err)
+		}
+		return
+	}
+}
+
+// parseEnvironmentPayload reads a sensor payload: a plain number of the sensor's measure, or
+// JSON {"humidity": ..., "temperature": ...}
+func parseEnvironmentPayload(measure string, payload []byte) (*float64, *float64, error) {
+	text := strings.TrimSpace(string(payload))
+	if measure != "" {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		if measure == EnvironmentMeasureHumidity {
+			return &value, nil, nil
+		}
+		return nil, &value, nil
+	}
+
+	var reading struct {
+		Humidity    *float64 `json:"humidity"`
+		Temperature *float64 `json:"temperature"`
+	}
+	if err := json.Unmarshal([]byte(text), &reading); err != nil {
+		return nil, nil, err
+	}
+	return reading.Humidity, reading.Temperature, nil
+}
+
+// annotateLocationEnvironments fills in the environment of locations that have one
+func (b *FilamentBridge) annotateLocationEnvironments(locations []LocationSummary) {
+	environments, err := b.GetLocationEnvironments(time.Now())
+	if err != nil {
+		bridgeLog.Warn("Failed to get location environments", "error", err)
+		return
+	}
+	for i := range locations {
+		if environment, exists := environments[strings.ToLower(locations[i].Name)]; exists {
+			locations[i].Environment = &environment
+		}
+	}
+}
+
+// getLocationEnvironmentHandler returns a location's environment and its readings of the last
+// day, or of the number of hours given by the hours query parameter
+func (ws *WebServer) getLocationEnvironmentHandler(c *gin.Context) {
+	hours := 24
+	if value := c.Query("hours"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid hours")
+			return
+		}
+		hours = parsed
+	}
+
+	environment, err := ws.bridge.getLocationEnvironment(c.Param("name"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	readings, err := ws.bridge.GetEnvironmentReadings(c.Param("name"), time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, LocationEnvironmentResponse{Environment: environment, Readings: readings})
+}
+
+// recordEnvironmentReadingHandler records a sensor reading of a location, given as JSON
+// {"humidity": ..., "temperature": ...}
+func (ws *WebServer) recordEnvironmentReadingHandler(c *gin.Context) {
+	var req struct {
+		Humidity    *float64 `json:"humidity"`
+		Temperature *float64 `json:"temperature"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if err := ws.bridge.RecordEnvironmentReading(c.Param("name"), req.Humidity, req.Temperature); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Environment reading recorded"})
+}
+
+// setLocationDryboxHandler marks a location as a drybox or not, given as JSON {"drybox": true}
+func (ws *WebServer) setLocationDryboxHandler(c *gin.Context) {
+	var req struct {
+		Drybox bool `json:"drybox"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if err := ws.bridge.SetLocationDrybox(c.Param("name"), req.Drybox); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Location environment updated successfully"})
+}
+
+// getSpoolExposuresHandler lists how long tracked spools have been exposed
+func (ws *WebServer) getSpoolExposuresHandler(c *gin.Context) {
+	exposures, err := ws.bridge.GetSpoolExposures("", 0)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolExposuresResponse{Spools: exposures})
+}
+
+// getSpoolExposureHandler returns how long a spool has been exposed
+func (ws *WebServer) getSpoolExposureHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+	exposures, err := ws.bridge.GetSpoolExposures(c.Query("printer_name"), spoolID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if len(exposures) == 0 {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Spool exposure isn't tracked yet")
+		return
+	}
+	c.JSON(http.StatusOK, exposures[0])
+}
+
+// markSpoolDriedHandler restarts a spool's exposure after drying
+func (ws *WebServer) markSpoolDriedHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+	if err := ws.bridge.MarkSpoolDried(c.Query("printer_name"), spoolID); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spool marked as dried"})
+}
+
+// getEnvironmentSensorsHandler returns the configured environment sensors
+func (ws *WebServer) getEnvironmentSensorsHandler(c *gin.Context) {
+	snapshot := ws.bridge.GetConfigSnapshot()
+	sensors := []EnvironmentSensor{}
+	if snapshot != nil {
+		sensors = snapshot.EnvironmentSensors
+	}
+	c.JSON(http.StatusOK, EnvironmentSensorsResponse{Sensors: sensors})
+}
+
+// updateEnvironmentSensorsHandler replaces the sensor list; the listener resubscribes to the
+// new topics
+func (ws *WebServer) updateEnvironmentSensorsHandler(c *gin.Context) {
+	var req struct {
+		Sensors []EnvironmentSensor `json:"sensors"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	value, err := json.Marshal(req.Sensors)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	// Validate before saving so a bad entry doesn't drop every sensor
+	if _, err := parseEnvironmentSensors(string(value)); err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+		return
+	}
+
+	if err := ws.bridge.SetConfigValue(ConfigKeyEnvironmentSensors, string(value)); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	if err := ws.bridge.ReloadConfig(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Environment sensors updated successfully"})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// API error codes returned alongside error messages so clients can react programmatically
+const (
+	ErrCodeInvalidRequest       = "invalid_request"
+	ErrCodeNotFound             = "not_found"
+	ErrCodeConflict             = "conflict"
+	ErrCodeSpoolAlreadyAssigned = "spool_already_assigned"
+	ErrCodeIncompatibleSpool    = "incompatible_spool"
+	ErrCodePrinterNotFound      = "printer_not_found"
+	ErrCodePrintErrorNotFound   = "print_error_not_found"
+	ErrCodeLocationNotFound     = "location_not_found"
+	ErrCodeSpoolmanError        = "spoolman_error"
+	ErrCodePrinterError         = "printer_error"
+	ErrCodeUnauthorized         = "unauthorized"
+	ErrCodeForbidden            = "forbidden"
+	ErrCodeInternal             = "internal_error"
+)
+
+// CodedError is an error that carries a machine-readable code for API responses
+type CodedError struct {
+	Code    string
+	Message string
+}
+
+// Error returns the human-readable error message
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+// newCodedError creates a CodedError with a formatted message
+func newCodedError(code, format string, args ...interface{}) *CodedError {
+	return &CodedError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// errorCode returns the code carried by err, or fallback if err has no code
+func errorCode(err error, fallback string) string {
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return fallback
+}
+
+// httpStatusForCode returns the HTTP status that best matches an error code,
+// or fallback if the code has no specific status
+func httpStatusForCode(code string, fallback int) int {
+	switch code {
+	case ErrCodeInvalidRequest:
+		return http.StatusBadRequest
+	case ErrCodeNotFound, ErrCodePrinterNotFound, ErrCodePrintErrorNotFound, ErrCodeLocationNotFound:
+		return http.StatusNotFound
+	case ErrCodeConflict, ErrCodeSpoolAlreadyAssigned, ErrCodeIncompatibleSpool:
+		return http.StatusConflict
+	case ErrCodeUnauthorized:
+		return http.StatusUnauthorized
+	case ErrCodeForbidden:
+		return http.StatusForbidden
+	}
+	return fallback
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Used to convert a reported filament length to grams when neither the spool's filament nor
+// the configuration say
+const (
+	defaultFilamentDensity  = 1.24 // g/cm³, PLA
+	defaultFilamentDiameter = 1.75 // mm
+)
+
+// jobTelemetry is what PrusaLink reported about a job while it printed. It's kept until the
+// print is processed so usage can still be estimated if the G-code can't be downloaded.
+type jobTelemetry struct {
+	TimePrinting int                      // Seconds
+	Filament     map[int]reportedFilament // By toolhead, when the printer reports it
+}
+
+// reportedFilament is a toolhead's filament usage as reported in the job info
+type reportedFilament struct {
+	Length float64 // mm
+	Weight float64 // grams
+}
+
+// newJobTelemetry takes the usage-related fields from a job
+func newJobTelemetry(job *PrusaLinkJob) jobTelemetry {
+	telemetry := jobTelemetry{TimePrinting: job.TimePrinting}
+	for _, filament := range job.Filament {
+		if filament.Length <= 0 && filament.Weight <= 0 {
+			continue
+		}
+		if telemetry.Filament == nil {
+			telemetry.Filament = make(map[int]reportedFilament)
+		}
+		telemetry.Filament[filament.ToolheadID] = reportedFilament{Length: filament.Length, Weight: filament.Weight}
+	}
+	return telemetry
+}
+
+// estimateFilamentUsage estimates a finished job's usage per toolhead from its telemetry. The
+// job's reported filament fields are used when present, otherwise the print time at the
+// configured average flow rate, which needs a single mapped toolhead to charge it to.
+func (b *FilamentBridge) estimateFilamentUsage(printerName string, config PrinterConfig, telemetry jobTelemetry) (map[int]float64, error) {
+	if len(telemetry.Filament) > 0 {
+		usage := make(map[int]float64)
+		for toolheadID, filament := range telemetry.Filament {
+			if filament.Weight > 0 {
+				usage[toolheadID] = filament.Weight
+				continue
+			}
+			usage[toolheadID] = b.filamentLengthToWeight(printerName, toolheadID, filament.Length)
+		}
+		return usage, nil
+	}
+
+	flowRate := b.GetConfigSnapshot().EstimatedFlowRate
+	if flowRate <= 0 {
+		return nil, fmt.Errorf("the job reported no filament usage and time-based estimates are disabled")
+	}
+	if telemetry.TimePrinting <= 0 {
+		return nil, fmt.Errorf("the job reported no filament usage or print time")
+	}
+
+	mappings, err := b.GetToolheadMappings(printerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get toolhead mappings: %w", err)
+	}
+	mapped := -1
+	for toolheadID, mapping := range mappings {
+		if toolheadID >= config.SlotCount() || mapping.SpoolID == 0 {
+			continue
+		}
+		if mapped >= 0 {
+			return nil, fmt.Errorf("the job reported no per-toolhead usage and several toolheads have spools mapped")
+		}
+		mapped = toolheadID
+	}
+	if mapped < 0 {
+		return nil, fmt.Errorf("no spool is mapped to charge the estimate to")
+	}
+
+	hours := float64(telemetry.TimePrinting) / 3600
+	return map[int]float64{mapped: hours * flowRate}, nil
+}
+
+// filamentLengthToWeight converts a length of filament in mm to grams using the density and
+// diameter of the spool mapped to the toolhead
+func (b *FilamentBridge) filamentLengthToWeight(printerName string, toolheadID int, length float64) float64 {
+	spoolID, err := b.GetToolheadMapping(printerName, toolheadID)
+	if err != nil {
+		monitorLog.Warn("Failed to get toolhead mapping for length conversion, using default density",
+			"printer", printerName, "toolhead_id", toolheadID, "error", err)
+		spoolID = 0
+	}
+	return b.spoolLengthToWeight(printerName, spoolID, length)
+}
+
+// spoolLengthToWeight converts a length of filament in mm to grams using the density and
+// diameter of the filament of a spool on a printer. Where Spoolman doesn't have them, or for
+// spool 0, the configured defaults apply.
+func (b *FilamentBridge) spoolLengthToWeight(printerName string, spoolID int, length float64) float64 {
+	density, diameter := defaultFilamentDensity, defaultFilamentDiameter
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		if snapshot.DefaultFilamentDensity > 0 {
+			density = snapshot.DefaultFilamentDensity
+		}
+		if snapshot.DefaultFilamentDiameter > 0 {
+			diameter = snapshot.DefaultFilamentDiameter
+		}
+	}
+	if spoolID != 0 {
+		if spool, err := b.spoolmanFor(printerName).GetSpool(spoolID); err != nil {
+			monitorLog.Warn("Failed to get spool for length conversion, using default density",
+				"spool_id", spoolID, "error", err)
+		} else if spool.Filament != nil {
+			if spool.Filament.Density > 0 {
+				density = spool.Filament.Density
+			}
+			if spool.Filament.Diameter > 0 {
+				diameter = spool.Filament.Diameter
+			}
+		}
+	}
+
+	radius := diameter / 2
+	volume := math.Pi * radius * radius * length // mm³
+	return volume / 1000 * density
+}
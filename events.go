@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// prusaLinkEventTransport subscribes to the PrusaLink event websocket on firmware that
+// supports it and wakes the printer's monitor loop whenever an event arrives. Printers
+// whose firmware doesn't expose the endpoint are left to regular polling.
+type prusaLinkEventTransport struct {
+	bridge *FilamentBridge
+}
+
+// Name returns the transport identifier
+func (t *prusaLinkEventTransport) Name() string {
+	return "prusalink-events"
+}
+
+// Run maintains the event subscription for a single printer, reconnecting with backoff
+func (t *prusaLinkEventTransport) Run(printerID string, config PrinterConfig, wake func(), stop <-chan struct{}) {
+	snapshot := t.bridge.GetConfigSnapshot()
+	if snapshot == nil || !snapshot.PrusaLinkEventsEnabled || config.IPAddress == "" || config.usesPrusaConnect() {
+		return
+	}
+
+	// ws:// for http:// and wss:// for https://
+	url := "ws" + strings.TrimPrefix(printerBaseURL(config.IPAddress), "http") + PrusaLinkEventsPath
+	header := http.Header{}
+	if config.APIKey != "" {
+		header.Set("X-Api-Key", config.APIKey)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second, TLSClientConfig: insecureTLSConfig(config.InsecureSkipVerify)}
+	unsupportedLogged := false
+
+	for {
+		conn, resp, err := dialer.Dial(url, header)
+		if err != nil {
+			// A 404 means the firmware has no event endpoint - polling keeps working
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				if !unsupportedLogged {
+					monitorLog.Info("PrusaLink events not supported, using polling only", "printer_id", printerID, "address", config.IPAddress)
+					unsupportedLogged = true
+				}
+			} else {
+				monitorLog.Warn("Failed to subscribe to PrusaLink events", "printer_id", printerID, "address", config.IPAddress, "error", err)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(PrusaLinkEventRetryInterval):
+				continue
+			}
+		}
+
+		monitorLog.Info("Subscribed to PrusaLink events", "printer_id", printerID, "address", config.IPAddress)
+		unsupportedLogged = false
+		t.readEvents(printerID, config, conn, wake, stop)
+		conn.Close()
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// readEvents reads events until the connection drops or the transport is stopped
+func (t *prusaLinkEventTransport) readEvents(printerID string, config PrinterConfig, conn *websocket.Conn, wake func(), stop <-chan struct{}) {
+	// Close the connection when stopped so the blocking read returns
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var lastWake time.Time
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if !strings.Contains(err.Error(), "use of closed network connection") {
+				monitorLog.Info("PrusaLink event stream closed", "printer_id", printerID, "address", config.IPAddress, "error", err)
+			}
+			return
+		}
+
+		// Bursts of events (telemetry, temperature) only need one check
+		if time.Since(lastWake) < PrusaLinkEventMinCheckInterval {
+			continue
+		}
+		lastWake = time.Now()
+
+		monitorLog.Debug("PrusaLink event", "printer_id", printerID, "address", config.IPAddress, "payload", truncateEventPayload(message))
+		wake()
+	}
+}
+
+// truncateEventPayload shortens an event payload for logging
+func truncateEventPayload(message []byte) string {
+	payload := string(message)
+	if len(payload) > 200 {
+		payload = payload[:200] + "..."
+	}
+	return payload
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execHooksEnabled reports whether exec hooks may run. They run arbitrary programs on the
+// host, so whoever deploys FilaBridge has to allow them; an admin token alone isn't enough.
+func execHooksEnabled() bool {
+	return strings.EqualFold(os.Getenv(ExecHooksEnv), "true")
+}
+
+// execHookEnv passes the notification to a hook as FILABRIDGE_* environment variables, for
+// scripts that would rather not parse the JSON on stdin
+func execHookEnv(notification Notification) []string {
+	return append(os.Environ(),
+		"FILABRIDGE_EVENT="+notification.Event,
+		"FILABRIDGE_TITLE="+notification.Title,
+		"FILABRIDGE_MESSAGE="+notification.Message,
+		"FILABRIDGE_CRITICAL="+strconv.FormatBool(notification.Critical),
+		"FILABRIDGE_URL="+notification.URL,
+		"FILABRIDGE_TIMESTAMP="+notification.Timestamp.Format(time.RFC3339),
+	)
+}
+
+// runExecHook runs a channel's command with the notification as JSON on stdin and in the
+// environment. The command is started directly, not through a shell; use e.g.
+// "/bin/sh" with args ["-c", "..."] for shell syntax.
+func runExecHook(channel NotificationChannel, notification Notification) error {
+	if !execHooksEnabled() {
+		return fmt.Errorf("exec hooks are disabled; set %s=true to allow them", ExecHooksEnv)
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ExecHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, channel.Command, channel.Args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = execHookEnv(notification)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("exec hook timed out after %s", ExecHookTimeout)
+		}
+		text := strings.TrimSpace(output.String())
+		if len(text) > ExecHookOutputLimit {
+			text = text[:ExecHookOutputLimit]
+		}
+		if text == "" {
+			return fmt.Errorf("exec hook failed: %w", err)
+		}
+		return fmt.Errorf("exec hook failed: %w: %s", err, text)
+	}
+
+	notificationsLog.Debug("Ran exec hook", "channel", channel.Name, "event", notification.Event, "command", channel.Command)
+	return nil
+}
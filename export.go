@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HistoryExportRow is one print history entry with the spool details a spreadsheet needs
+type HistoryExportRow struct {
+	ID            int       `json:"id"`
+	PrintStarted  time.Time `json:"print_started"`
+	PrintFinished time.Time `json:"print_finished"`
+	PrinterName   string    `json:"printer_name"`
+	ToolheadID    int       `json:"toolhead_id"`
+	Job           string    `json:"job"`
+	SpoolID       int       `json:"spool_id"`
+	SpoolName     string    `json:"spool_name"`
+	Vendor        string    `json:"vendor"`
+	Material      string    `json:"material"`
+	ColorHex      string    `json:"color_hex"`
+	FilamentUsed  float64   `json:"filament_used"`
+	Waste         float64   `json:"waste"`
+	Cost          *float64  `json:"cost"` // In Spoolman's currency; nil when the spool has no price
+	Source        string    `json:"source"`
+	Member        string    `json:"member"`
+	Rating        int       `json:"rating"`
+	Notes         string    `json:"notes"`
+	Reverted      bool      `json:"reverted"`
+}
+
+// historyExportColumns is the CSV header, in HistoryExportRow order
+var historyExportColumns = []string{
+	"id", "print_started", "print_finished", "printer_name", "toolhead_id", "job", "spool_id", "spool_name",
+	"vendor", "material", "color_hex", "filament_used_g", "waste_g", "cost", "source", "member", "rating", "notes", "reverted",
+}
+
+// ExportPrintHistory returns the history entries that finished between from and to, oldest
+// first, with spool names, materials and costs looked up in Spoolman. A zero from or to leaves
+// that end open.
+func (b *FilamentBridge) ExportPrintHistory(from, to time.Time, printerName string) ([]HistoryExportRow, error) {
+	history, err := b.GetPrintHistory(PrintHistoryFilter{PrinterName: printerName})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []PrintHistory
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if (!from.IsZero() && entry.PrintFinished.Before(from)) || (!to.IsZero() && !entry.PrintFinished.Before(to)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	spools := b.historySpools(entries)
+
+	rows := make([]HistoryExportRow, 0, len(entries))
+	for _, entry := range entries {
+		row := HistoryExportRow{
+			ID:            entry.ID,
+			PrintStarted:  entry.PrintStarted,
+			PrintFinished: entry.PrintFinished,
+			PrinterName:   entry.PrinterName,
+			ToolheadID:    entry.ToolheadID,
+			Job:           entry.JobDisplayName,
+			SpoolID:       entry.SpoolID,
+			FilamentUsed:  entry.FilamentUsed,
+			Waste:         entry.Waste,
+			Cost:          entry.Cost,
+			Source:        entry.Source,
+			Member:        entry.Member,
+			Rating:        entry.Rating,
+			Notes:         entry.Notes,
+			Reverted:      entry.Reverted,
+		}
+		if spool, known := spools.spoolOf(entry); known {
+			row.SpoolName = spool.Name
+			row.Vendor = spool.Brand
+			row.Material = spoolMaterial(spool)
+			if spool.Filament != nil {
+				row.ColorHex = spool.Filament.ColorHex
+				if row.Vendor == "" && spool.Filament.Vendor != nil {
+					row.Vendor = spool.Filament.Vendor.Name
+				}
+				if row.SpoolName == "" {
+					row.SpoolName = spool.Filament.Name
+				}
+			}
+			// Entries from before costs were recorded are priced at the spool's current price
+			if row.Cost == nil {
+				if pricePerGram, priced := spoolPricePerGram(spool); priced {
+					cost := pricePerGram * entry.FilamentUsed
+					row.Cost = &cost
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseExportTime parses a from/to query value, either an RFC 3339 time or a local date. A
+// date given as to covers that whole day.
+func parseExportTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return parsed, nil
+}
+
+// exportHistoryHandler downloads print history as CSV or JSON, optionally limited to
+// ?from= and ?to= and one ?printer_name=
+func (ws *WebServer) exportHistoryHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "format must be csv or json")
+		return
+	}
+	from, err := parseExportTime(c.Query("from"), false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "from must be a date (YYYY-MM-DD) or RFC 3339 time")
+		return
+	}
+	to, err := parseExportTime(c.Query("to"), true)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "to must be a date (YYYY-MM-DD) or RFC 3339 time")
+		return
+	}
+
+	rows, err := ws.bridge.ExportPrintHistory(from, to, c.Query("printer_name"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	filename := fmt.Sprintf("filabridge-history-%s.%s", time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "json" {
+		c.JSON(http.StatusOK, HistoryExportResponse{History: rows})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(historyExportColumns)
+	for _, row := range rows {
+		cost := ""
+		if row.Cost != nil {
+			cost = strconv.FormatFloat(*row.Cost, 'f', 2, 64)
+		}
+		writer.Write([]string{
+			strconv.Itoa(row.ID),
+			row.PrintStarted.Format(time.RFC3339),
+			row.PrintFinished.Format(time.RFC3339),
+			row.PrinterName,
+			strconv.Itoa(row.ToolheadID),
+			row.Job,
+			strconv.Itoa(row.SpoolID),
+			row.SpoolName,
+			row.Vendor,
+			row.Material,
+			row.ColorHex,
+			strconv.FormatFloat(row.FilamentUsed, 'f', 2, 64),
+			strconv.FormatFloat(row.Waste, 'f', 2, 64),
+			cost,
+			row.Source,
+			row.Member,
+			strconv.Itoa(row.Rating),
+			row.Notes,
+			strconv.FormatBool(row.Reverted),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		webLog.Warn("Failed to write history export", "error", err)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Filament runout statuses
+const (
+	FilamentRunoutPending   = "pending"
+	FilamentRunoutConfirmed = "confirmed" // The spool was archived as empty
+	FilamentRunoutDismissed = "dismissed"
+)
+
+// SpoolEventRunout is logged when a spool is archived after a filament runout
+const SpoolEventRunout = "runout"
+
+// FilamentRunout is a spool that may have run out: its printer stopped a print for attention,
+// which is how the filament sensor reports a runout. A runout records every spool the job
+// was printing with, since the printer doesn't say which toolhead ran out.
+type FilamentRunout struct {
+	ID                 int        `json:"id"`
+	PrinterName        string     `json:"printer_name"`
+	ToolheadID         int        `json:"toolhead_id"`
+	SpoolID            int        `json:"spool_id"`
+	JobName            string     `json:"job_name"`
+	Reason             string     `json:"reason"`                        // What the printer reported, if anything
+	EstimatedRemaining *float64   `json:"estimated_remaining,omitempty"` // Grams the spool should still have, when the job's requirement is known
+	Status             string     `json:"status"`
+	DetectedAt         time.Time  `json:"detected_at"`
+	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+}
+
+// detectFilamentRunout records a runout when a printing printer asks for attention, and
+// notifies about it. The printer was printing on the previous poll, so this runs once per stop.
+func (b *FilamentBridge) detectFilamentRunout(printerID string, config PrinterConfig, status *PrusaLinkStatus, jobName string) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil || !snapshot.RunoutDetectionEnabled {
+		return
+	}
+
+	printerName := resolvePrinterName(config)
+	mappings, err := b.GetToolheadMappings(printerName)
+	if err != nil {
+		monitorLog.Warn("Failed to get toolhead mappings for filament runout", "printer", printerName, "error", err)
+		return
+	}
+
+	// The active spool estimates know which toolheads the job uses; without them every
+	// mapped toolhead is a candidate
+	estimates := make(map[int]ActiveSpool)
+	for _, active := range b.runout.ActiveSpools() {
+		if active.PrinterID == printerID {
+			estimates[active.ToolheadID] = active
+		}
+	}
+	toolheads := make([]int, 0, len(mappings))
+	for toolheadID, mapping := range mappings {
+		if _, used := estimates[toolheadID]; (len(estimates) == 0 || used) && mapping.SpoolID != 0 {
+			toolheads = append(toolheads, toolheadID)
+		}
+	}
+	sort.Ints(toolheads)
+
+	reason := strings.TrimSpace(status.Printer.StatusPrinter.Message)
+	now := time.Now()
+	var runouts []FilamentRunout
+	for _, toolheadID := range toolheads {
+		runout := FilamentRunout{
+			PrinterName: printerName,
+			ToolheadID:  toolheadID,
+			SpoolID:     mappings[toolheadID].SpoolID,
+			JobName:     jobName,
+			Reason:      reason,
+			Status:      FilamentRunoutPending,
+			DetectedAt:  now,
+		}
+		if active, known := estimates[toolheadID]; known {
+			remaining := active.Remaining
+			runout.EstimatedRemaining = &remaining
+		}
+		runouts = append(runouts, runout)
+	}
+	if err := b.recordFilamentRunouts(runouts); err != nil {
+		monitorLog.Error("Failed to record filament runout", "printer", printerName, "error", err)
+	}
+
+	monitorLog.Warn("Printer stopped for attention during a print, possibly a filament runout", "printer", printerName, "job", jobName, "reason", reason, "spools", len(runouts))
+	message := fmt.Sprintf("%s stopped printing %s and needs attention", printerName, jobName)
+	if reason != "" {
+		message += ": " + reason
+	}
+	var spools []string
+	for _, runout := range runouts {
+		spool := fmt.Sprintf("spool %d on toolhead %d", runout.SpoolID, runout.ToolheadID)
+		if runout.EstimatedRemaining != nil {
+			spool += fmt.Sprintf(" (~%.0fg left by estimate)", *runout.EstimatedRemaining)
+		}
+		spools = append(spools, spool)
+	}
+	if len(spools) > 0 {
+		message += fmt.Sprintf(". If the filament ran out, mark the empty spool on the dashboard: %s", strings.Join(spools, ", "))
+	}
+	b.notifier.Notify(Notification{
+		Event:    NotificationEventFilamentRunout,
+		Title:    fmt.Sprintf("Filament runout on %s?", printerName),
+		Message:  message,
+		Critical: true,
+	})
+}
+
+// recordFilamentRunouts stores newly detected runouts
+func (b *FilamentBridge) recordFilamentRunouts(runouts []FilamentRunout) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, runout := range runouts {
+		if _, err := b.db.Exec(
+			`INSERT INTO filament_runouts (printer_name, toolhead_id, spool_id, job_name, reason, estimated_remaining, status, detected_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			runout.PrinterName, runout.ToolheadID, runout.SpoolID, runout.JobName, runout.Reason, runout.EstimatedRemaining, runout.Status, runout.DetectedAt,
+		); err != nil {
+			return fmt.Errorf("failed to record filament runout of spool %d: %w", runout.SpoolID, err)
+		}
+	}
+	return nil
+}
+
+// scanFilamentRunout reads a runout from a query row
+func scanFilamentRunout(scanner interface{ Scan(...interface{}) error }) (FilamentRunout, error) {
+	var runout FilamentRunout
+	var estimated sql.NullFloat64
+	var resolvedAt sql.NullTime
+	err := scanner.Scan(&runout.ID, &runout.PrinterName, &runout.ToolheadID, &runout.SpoolID, &runout.JobName, &runout.Reason,
+		&estimated, &runout.Status, &runout.DetectedAt, &resolvedAt)
+	if estimated.Valid {
+		runout.EstimatedRemaining = &estimated.Float64
+	}
+	if resolvedAt.Valid {
+		runout.ResolvedAt = &resolvedAt.Time
+	}
+	return runout, err
+}
+
+// filamentRunoutColumns are the columns scanFilamentRunout reads
+const filamentRunoutColumns = "id, printer_name, toolhead_id, spool_id, job_name, reason, estimated_remaining, status, detected_at, resolved_at"
+
+// GetPendingFilamentRunouts returns the runouts nobody has confirmed or dismissed, oldest first
+func (b *FilamentBridge) GetPendingFilamentRunouts() ([]FilamentRunout, error) {
+	rows, err := b.db.Query("SELECT "+filamentRunoutColumns+" FROM filament_runouts WHERE status = ? ORDER BY detected_at, toolhead_id", FilamentRunoutPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filament runouts: %w", err)
+	}
+	defer rows.Close()
+
+	runouts := []FilamentRunout{}
+	for rows.Next() {
+		runout, err := scanFilamentRunout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan filament runout: %w", err)
+		}
+		runouts = append(runouts, runout)
+	}
+	return runouts, rows.Err()
+}
+
+// getFilamentRunout returns a runout by ID
+func (b *FilamentBridge) getFilamentRunout(id int) (*FilamentRunout, error) {
+	runout, err := scanFilamentRunout(b.db.QueryRow("SELECT "+filamentRunoutColumns+" FROM filament_runouts WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, newCodedError(ErrCodeNotFound, "filament runout %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filament runout: %w", err)
+	}
+	return &runout, nil
+}
+
+// resolveFilamentRunout sets a pending runout's final status, in storage and on runout. Confirming a spool empty
+// dismisses the other spools recorded for the same stop, since only one ran out.
+func (b *FilamentBridge) resolveFilamentRunout(runout *FilamentRunout, status string) error {
+	ids := []int{runout.ID}
+	if status == FilamentRunoutConfirmed {
+		pending, err := b.GetPendingFilamentRunouts()
+		if err != nil {
+			return err
+		}
+		for _, other := range pending {
+			if other.ID != runout.ID && other.PrinterName == runout.PrinterName && other.DetectedAt.Equal(runout.DetectedAt) {
+				ids = append(ids, other.ID)
+			}
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		idStatus := status
+		if id != runout.ID {
+			idStatus = FilamentRunoutDismissed
+		}
+		if _, err := b.db.Exec("UPDATE filament_runouts SET status = ?, resolved_at = ? WHERE id = ?", idStatus, now, id); err != nil {
+			return fmt.Errorf("failed to resolve filament runout %d: %w", id, err)
+		}
+	}
+	runout.Status = status
+	runout.ResolvedAt = &now
+	return nil
+}
+
+// ConfirmFilamentRunout marks a runout's spool as empty: it is archived in Spoolman and
+// unmapped from its toolhead, if it's still mapped there
+func (b *FilamentBridge) ConfirmFilamentRunout(id int, member string) (*FilamentRunout, error) {
+	runout, err := b.getFilamentRunout(id)
+	if err != nil {
+		return nil, err
+	}
+	if runout.Status != FilamentRunoutPending {
+		return nil, newCodedError(ErrCodeConflict, "filament runout %d is already %s", id, runout.Status)
+	}
+
+	if err := b.spoolmanFor(runout.PrinterName).UpdateSpool(runout.SpoolID, map[string]interface{}{"archived": true}); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to archive spool %d: %v", runout.SpoolID, err)
+	}
+
+	mappings, err := b.GetToolheadMappings(runout.PrinterName)
+	if err != nil {
+		return nil, err
+	}
+	if mappings[runout.ToolheadID].SpoolID == runout.SpoolID {
+		if err := b.UnmapToolhead(runout.PrinterName, runout.ToolheadID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.resolveFilamentRunout(runout, FilamentRunoutConfirmed); err != nil {
+		return nil, err
+	}
+	event := SpoolEvent{SpoolmanInstance: b.spoolmanInstanceOf(runout.PrinterName), SpoolID: runout.SpoolID,
+		EventType: SpoolEventRunout, Member: member, Notes: runout.Reason, CreatedAt: time.Now()}
+	if err := b.recordSpoolEvents([]SpoolEvent{event}); err != nil {
+		bridgeLog.Error("Failed to record spool runout", "spool_id", runout.SpoolID, "error", err)
+	}
+
+	bridgeLog.Info("Archived spool after filament runout", "spool_id", runout.SpoolID, "printer", runout.PrinterName, "toolhead_id", runout.ToolheadID)
+	return runout, nil
+}
+
+// DismissFilamentRunout records that a runout's spool isn't empty
+func (b *FilamentBridge) DismissFilamentRunout(id int) error {
+	runout, err := b.getFilamentRunout(id)
+	if err != nil {
+		return err
+	}
+	if runout.Status != FilamentRunoutPending {
+		return newCodedError(ErrCodeConflict, "filament runout %d is already %s", id, runout.Status)
+	}
+	return b.resolveFilamentRunout(runout, FilamentRunoutDismissed)
+}
+
+// getFilamentRunoutsHandler lists the pending filament runouts
+func (ws *WebServer) getFilamentRunoutsHandler(c *gin.Context) {
+	runouts, err := ws.bridge.GetPendingFilamentRunouts()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, FilamentRunoutsResponse{Runouts: runouts})
+}
+
+// confirmFilamentRunoutHandler archives the spool of a runout as empty
+func (ws *WebServer) confirmFilamentRunoutHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid runout ID")
+		return
+	}
+
+	member := ""
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+	runout, err := ws.bridge.ConfirmFilamentRunout(id, member)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, FilamentRunoutResponse{Message: fmt.Sprintf("Spool %d archived as empty", runout.SpoolID), Runout: runout})
+}
+
+// dismissFilamentRunoutHandler records that a runout's spool isn't empty
+func (ws *WebServer) dismissFilamentRunoutHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid runout ID")
+		return
+	}
+	if err := ws.bridge.DismissFilamentRunout(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Runout dismissed"})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// decodeFilename turns a raw file name or path from a printer into readable text: it
+// undoes URL escaping, reinterprets non-UTF-8 bytes as Latin-1, and drops control characters
+func decodeFilename(raw string) string {
+	decoded := raw
+	if unescaped, err := url.PathUnescape(raw); err == nil {
+		decoded = unescaped
+	}
+
+	if !utf8.ValidString(decoded) {
+		// Older firmware and USB sticks hand back single-byte names
+		runes := make([]rune, 0, len(decoded))
+		for i := 0; i < len(decoded); i++ {
+			runes = append(runes, rune(decoded[i]))
+		}
+		decoded = string(runes)
+	}
+
+	decoded = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, decoded)
+
+	return strings.TrimSpace(decoded)
+}
+
+// displayFilename returns the human-readable form of a job file. The printer's long
+// display name is preferred since raw paths on FAT storage are 8.3 mangled
+// (e.g. "SPIROG~1.BGC"); otherwise the decoded base name of the raw path is used.
+func displayFilename(raw, displayName string) string {
+	if name := decodeFilename(displayName); name != "" {
+		return name
+	}
+	if raw == "" {
+		return ""
+	}
+	return decodeFilename(path.Base(strings.TrimPrefix(raw, "/")))
+}
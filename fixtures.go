@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Test fixtures are synthetic spools, printers and history rows for evaluating the dashboard,
+// broadcasts and statistics at realistic scale. Everything generated is marked so it can be
+// told apart from real data and removed in bulk.
+const (
+	FixturePrinterIDPrefix = "fixture_"           // Printer ID prefix of fixture printers
+	FixtureMarker          = "filabridge-fixture" // Spoolman comment on fixture spools and filaments
+	FixtureAddress         = "fixture.invalid"    // Address of fixture printers; they are never polled
+	MaxFixtureSpools       = 1000
+	MaxFixturePrinters     = 200
+	MaxFixtureHistory      = 100000
+)
+
+// FixtureRequest is the number of each kind of fixture to generate
+type FixtureRequest struct {
+	Spools           int    `json:"spools"`
+	Printers         int    `json:"printers"`
+	History          int    `json:"history"`
+	SpoolmanInstance string `json:"spoolman_instance"` // Instance of the spools and the printers; the main one when empty
+}
+
+// FixtureSummary counts existing fixture data
+type FixtureSummary struct {
+	Spools   int `json:"spools"`
+	Printers int `json:"printers"`
+	History  int `json:"history"`
+}
+
+// isFixturePrinter reports whether a printer ID belongs to a fixture printer
+func isFixturePrinter(printerID string) bool {
+	return strings.HasPrefix(printerID, FixturePrinterIDPrefix)
+}
+
+// fixtureMaterials are cycled through for fixture spools
+var fixtureMaterials = []string{"PLA", "PETG", "ASA", "TPU", "PA-CF"}
+
+// GenerateFixtures creates fixture spools in Spoolman and fixture printers and history rows
+// locally. Fixture spools are mapped round-robin onto the new printers' toolheads, which use
+// the Spoolman instance the spools were created in.
+func (b *FilamentBridge) GenerateFixtures(req FixtureRequest) (*FixtureSummary, error) {
+	if req.Spools < 0 || req.Printers < 0 || req.History < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "fixture counts must not be negative")
+	}
+	if req.Spools > MaxFixtureSpools || req.Printers > MaxFixturePrinters || req.History > MaxFixtureHistory {
+		return nil, newCodedError(ErrCodeInvalidRequest, "at most %d spools, %d printers and %d history rows can be generated at once",
+			MaxFixtureSpools, MaxFixturePrinters, MaxFixtureHistory)
+	}
+	if err := b.validateSpoolmanInstance(req.SpoolmanInstance); err != nil {
+		return nil, err
+	}
+
+	spoolIDs, err := b.generateFixtureSpools(req.SpoolmanInstance, req.Spools)
+	if err != nil {
+		return nil, err
+	}
+
+	printers, err := b.generateFixturePrinters(req.SpoolmanInstance, req.Printers, spoolIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.generateFixtureHistory(req.History, printers, spoolIDs); err != nil {
+		return nil, err
+	}
+
+	if req.Printers > 0 {
+		if err := b.ReloadConfig(); err != nil {
+			return nil, fmt.Errorf("failed to reload configuration: %w", err)
+		}
+	}
+
+	bridgeLog.Info("Generated fixtures", "spools", req.Spools, "printers", req.Printers, "history", req.History,
+		"spoolman_instance", req.SpoolmanInstance)
+	return b.GetFixtureSummary()
+}
+
+// generateFixtureSpools creates fixture spools in a Spoolman instance, one filament per material
+func (b *FilamentBridge) generateFixtureSpools(instance string, count int) ([]int, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	spoolman := b.spoolmanInstance(instance)
+
+	filamentIDs := make([]int, 0, len(fixtureMaterials))
+	for _, material := range fixtureMaterials {
+		filament, err := spoolman.CreateFilament(map[string]interface{}{
+			"name":     "Fixture " + material,
+			"material": material,
+			"density":  1.24,
+			"diameter": 1.75,
+			"weight":   1000,
+			"comment":  FixtureMarker,
+		})
+		if err != nil {
+			return nil, newCodedError(ErrCodeSpoolmanError, "failed to create fixture filament: %v", err)
+		}
+		filamentIDs = append(filamentIDs, filament.ID)
+	}
+
+	spoolIDs := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		spool, err := spoolman.CreateSpool(map[string]interface{}{
+			"filament_id":    filamentIDs[i%len(filamentIDs)],
+			"initial_weight": 1000,
+			"used_weight":    rand.Float64() * 1000,
+			"comment":        FixtureMarker,
+		})
+		if err != nil {
+			return spoolIDs, newCodedError(ErrCodeSpoolmanError, "failed to create fixture spool %d of %d: %v", i+1, count, err)
+		}
+		spoolIDs = append(spoolIDs, spool.ID)
+	}
+	return spoolIDs, nil
+}
+
+// generateFixturePrinters creates fixture printers on a Spoolman instance and maps fixture
+// spools onto their toolheads. It returns the new printers' configs.
+func (b *FilamentBridge) generateFixturePrinters(instance string, count int, spoolIDs []int) ([]PrinterConfig, error) {
+	var existing int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM printer_configs WHERE printer_id LIKE ?", FixturePrinterIDPrefix+"%").Scan(&existing); err != nil {
+		return nil, fmt.Errorf("failed to count fixture printers: %w", err)
+	}
+
+	printers := make([]PrinterConfig, 0, count)
+	nextSpool := 0
+	for i := existing + 1; i <= existing+count; i++ {
+		config := PrinterConfig{
+			Name:             fmt.Sprintf("Fixture Printer %d", i),
+			Model:            ModelMK4,
+			IPAddress:        FixtureAddress,
+			Toolheads:        1,
+			SpoolmanInstance: instance,
+		}
+		if i%4 == 0 {
+			config.Model = ModelXL
+			config.Toolheads = 5
+		}
+
+		if err := b.SavePrinterConfig(fmt.Sprintf("%s%d", FixturePrinterIDPrefix, i), config); err != nil {
+			return nil, err
+		}
+		printers = append(printers, config)
+
+		for toolheadID := 0; toolheadID < config.SlotCount() && nextSpool < len(spoolIDs); toolheadID++ {
+			b.mutex.Lock()
+			_, err := b.db.Exec(
+				"INSERT OR REPLACE INTO toolhead_mappings (printer_name, toolhead_id, spool_id, mapped_at) VALUES (?, ?, ?, ?)",
+				config.Name, toolheadID, spoolIDs[nextSpool], time.Now(),
+			)
+			b.mutex.Unlock()
+			if err != nil {
+				return nil, fmt.Errorf("failed to map fixture spool: %w", err)
+			}
+			nextSpool++
+		}
+	}
+	return printers, nil
+}
+
+// generateFixtureHistory inserts fixture history rows spread over the last year
+func (b *FilamentBridge) generateFixtureHistory(count int, printers []PrinterConfig, spoolIDs []int) error {
+	if count == 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO print_history (printer_name, toolhead_id, spool_id, filament_used, print_started, print_finished, job_name, job_display_name, rating, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare fixture history insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for i := 0; i < count; i++ {
+		printerName := "Fixture Printer"
+		toolheadID := 0
+		if len(printers) > 0 {
+			printer := printers[rand.Intn(len(printers))]
+			printerName = printer.Name
+			toolheadID = rand.Intn(printer.SlotCount())
+		}
+		spoolID := 0
+		if len(spoolIDs) > 0 {
+			spoolID = spoolIDs[rand.Intn(len(spoolIDs))]
+		}
+
+		started := now.Add(-time.Duration(rand.Int63n(int64(365 * 24 * time.Hour))))
+		finished := started.Add(30*time.Minute + time.Duration(rand.Int63n(int64(10*time.Hour))))
+		jobName := fmt.Sprintf("usb/FIXTUR~%d.BGC", i+1)
+		displayName := fmt.Sprintf("fixture_job_%d.bgcode", i+1)
+
+		if _, err := stmt.Exec(printerName, toolheadID, spoolID, 5+rand.Float64()*295, started, finished,
+			jobName, displayName, rand.Intn(MaxPrintRating+1), HistorySourceFixture); err != nil {
+			return fmt.Errorf("failed to insert fixture history: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit fixture history: %w", err)
+	}
+	return nil
+}
+
+// GetFixtureSummary counts the fixture data that currently exists
+func (b *FilamentBridge) GetFixtureSummary() (*FixtureSummary, error) {
+	summary := &FixtureSummary{}
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM printer_configs WHERE printer_id LIKE ?", FixturePrinterIDPrefix+"%").Scan(&summary.Printers); err != nil {
+		return nil, fmt.Errorf("failed to count fixture printers: %w", err)
+	}
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM print_history WHERE source = ?", HistorySourceFixture).Scan(&summary.History); err != nil {
+		return nil, fmt.Errorf("failed to count fixture history: %w", err)
+	}
+
+	// Local fixtures are still counted when Spoolman is unavailable
+	for _, instance := range b.spoolmanInstanceNames() {
+		spools, err := b.spoolmanInstance(instance).GetAllSpools()
+		if err != nil {
+			bridgeLog.Warn("Failed to get spools to count fixtures", "spoolman_instance", instance, "error", err)
+		}
+		for _, spool := range spools {
+			if spool.Comment == FixtureMarker {
+				summary.Spools++
+			}
+		}
+	}
+	return summary, nil
+}
+
+// DeleteFixtures removes all fixture data from every Spoolman instance and the local database.
+// Local data is removed even if Spoolman is unavailable; fixture spools can be deleted again later.
+func (b *FilamentBridge) DeleteFixtures() (*FixtureSummary, error) {
+	deleted := &FixtureSummary{}
+
+	for _, instance := range b.spoolmanInstanceNames() {
+		deleted.Spools += b.deleteFixtureSpools(instance)
+	}
+
+	b.mutex.Lock()
+	err := b.deleteFixtureRows(deleted)
+	b.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.ReloadConfig(); err != nil {
+		return nil, fmt.Errorf("failed to reload configuration: %w", err)
+	}
+
+	bridgeLog.Info("Deleted fixtures", "spools", deleted.Spools, "printers", deleted.Printers, "history", deleted.History)
+	return deleted, nil
+}
+
+// deleteFixtureSpools removes fixture spools and filaments from a Spoolman instance, returning
+// how many spools were deleted
+func (b *FilamentBridge) deleteFixtureSpools(instance string) int {
+	spoolman := b.spoolmanInstance(instance)
+	var deleted int
+
+	spools, err := spoolman.GetAllSpools()
+	if err != nil {
+		bridgeLog.Warn("Failed to get spools to delete fixtures", "spoolman_instance", instance, "error", err)
+	}
+	for _, spool := range spools {
+		if spool.Comment != FixtureMarker {
+			continue
+		}
+		if err := spoolman.DeleteSpool(spool.ID); err != nil {
+			bridgeLog.Warn("Failed to delete fixture spool", "spoolman_instance", instance, "spool_id", spool.ID, "error", err)
+			continue
+		}
+		deleted++
+	}
+
+	filaments, err := spoolman.GetAllFilaments()
+	if err != nil {
+		bridgeLog.Warn("Failed to get filaments to delete fixtures", "spoolman_instance", instance, "error", err)
+	}
+	for _, filament := range filaments {
+		if filament.Comment != FixtureMarker {
+			continue
+		}
+		if err := spoolman.DeleteFilament(filament.ID); err != nil {
+			bridgeLog.Warn("Failed to delete fixture filament", "spoolman_instance", instance, "filament_id", filament.ID, "error", err)
+		}
+	}
+	return deleted
+}
+
+// deleteFixtureRows removes fixture printers, their mappings and fixture history in one
+// transaction. The caller must hold b.mutex.
+func (b *FilamentBridge) deleteFixtureRows(deleted *FixtureSummary) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fixturePrinters := "SELECT name FROM printer_configs WHERE printer_id LIKE ?"
+	pattern := FixturePrinterIDPrefix + "%"
+	statements := []struct {
+		query string
+		args  []interface{}
+		count *int
+	}{
+		{"DELETE FROM history_corrections WHERE history_id IN (SELECT id FROM print_history WHERE source = ?)", []interface{}{HistorySourceFixture}, nil},
+		{"DELETE FROM print_history WHERE source = ?", []interface{}{HistorySourceFixture}, &deleted.History},
+		{"DELETE FROM toolhead_mappings WHERE printer_name IN (" + fixturePrinters + ")", []interface{}{pattern}, nil},
+		{"DELETE FROM toolhead_names WHERE printer_id LIKE ?", []interface{}{pattern}, nil},
+		{"DELETE FROM toolhead_rules WHERE printer_id LIKE ?", []interface{}{pattern}, nil},
+		{"DELETE FROM printer_configs WHERE printer_id LIKE ?", []interface{}{pattern}, &deleted.Printers},
+	}
+	for _, statement := range statements {
+		result, err := tx.Exec(statement.query, statement.args...)
+		if err != nil {
+			return fmt.Errorf("failed to delete fixtures: %w", err)
+		}
+		if statement.count != nil {
+			if affected, err := result.RowsAffected(); err == nil {
+				*statement.count = int(affected)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit fixture deletion: %w", err)
+	}
+	return nil
+}
+
+// getFixturesHandler returns counts of existing fixture data
+func (ws *WebServer) getFixturesHandler(c *gin.Context) {
+	summary, err := ws.bridge.GetFixtureSummary()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, FixturesResponse{Fixtures: summary})
+}
+
+// createFixturesHandler generates fixture data for load testing
+func (ws *WebServer) createFixturesHandler(c *gin.Context) {
+	var req FixtureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	summary, err := ws.bridge.GenerateFixtures(req)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusCreated, FixturesResponse{Message: "Fixtures generated successfully", Fixtures: summary})
+}
+
+// deleteFixturesHandler removes all fixture data
+func (ws *WebServer) deleteFixturesHandler(c *gin.Context) {
+	deleted, err := ws.bridge.DeleteFixtures()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, FixturesDeletedResponse{Message: "Fixtures deleted successfully", Deleted: deleted})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// G-code flavors a printer can be set to expect, so files are parsed with the right
+// strategy and a mismatch is reported clearly instead of as missing usage data
+const (
+	GcodeFlavorAuto        = ""            // Detect from the file
+	GcodeFlavorBgcode      = "bgcode"      // Prusa binary G-code
+	GcodeFlavorPrusaSlicer = "prusaslicer" // PrusaSlicer ASCII G-code
+	GcodeFlavorCura        = "cura"        // Cura ASCII G-code, which only reports filament length
+	GcodeFlavorOrcaSlicer  = "orcaslicer"  // OrcaSlicer and Bambu Studio ASCII G-code
+	GcodeFlavorKlipper     = "klipper"     // ASCII G-code sliced for Klipper with PrusaSlicer-style comments
+)
+
+// gcodeExtractor reads the filament usage of one G-code flavor
+type gcodeExtractor struct {
+	name    string                    // Description for error messages
+	marker  string                    // What usage is read from, for error messages
+	detect  func(content []byte) bool // Whether a file is of this flavor, nil when it can't be told apart
+	extract func(content []byte, usage *GcodeUsage)
+}
+
+// gcodeExtractors reads each flavor. Detection tries the flavors in gcodeDetectionOrder
+// and falls back to PrusaSlicer, so supporting another slicer means adding its extractor
+// here and, if its files can be recognized, its flavor to the order.
+var gcodeExtractors = map[string]gcodeExtractor{
+	GcodeFlavorBgcode: {
+		name:    "Prusa binary G-code (.bgcode)",
+		marker:  `"filament used [g]", "filament used [mm]" or "total filament used [g]" metadata`,
+		detect:  func(content []byte) bool { return bytes.HasPrefix(content, bgcodeMagic) },
+		extract: parseWeightsOrLengths,
+	},
+	GcodeFlavorPrusaSlicer: {
+		name:    "PrusaSlicer ASCII G-code",
+		marker:  `"filament used [g]", "filament used [mm]" or "total filament used [g]" metadata`,
+		extract: parseWeightsOrLengths,
+	},
+	GcodeFlavorCura: {
+		name:   "Cura G-code",
+		marker: `";Filament used:" header`,
+		detect: func(content []byte) bool {
+			return bytes.Contains(content, []byte(";Generated with Cura")) || curaFilamentRegex.Match(content)
+		},
+		extract: parseCuraUsage,
+	},
+	GcodeFlavorOrcaSlicer: {
+		name:   "OrcaSlicer G-code",
+		marker: `"; total filament weight [g]" header or "; filament used [g]" comment`,
+		detect: func(content []byte) bool {
+			return bytes.Contains(content, []byte("generated by OrcaSlicer")) || bytes.Contains(content, []byte("BambuStudio"))
+		},
+		extract: parseOrcaSlicerUsage,
+	},
+	GcodeFlavorKlipper: {
+		name:    "Klipper G-code",
+		marker:  `"; filament used [g]" or "; filament used [mm]" comment`,
+		extract: parseWeightsOrLengths,
+	},
+}
+
+// gcodeDetectionOrder is the order flavors are tried in when detecting a file's flavor
+var gcodeDetectionOrder = []string{GcodeFlavorBgcode, GcodeFlavorOrcaSlicer, GcodeFlavorCura}
+
+// bgcodeMagic starts every binary G-code file
+var bgcodeMagic = []byte("GCDE")
+
+// The per-toolhead markers mustn't match inside "total filament used", which ASCII footers
+// also have, so they only follow a non-word character other than a space
+var (
+	// "filament used [g]=1.23,4.56" in bgcode metadata, "; filament used [g] = 1.23, 4.56" in ASCII
+	filamentWeightRegex = regexp.MustCompile(`(?:^|[^\w ]);?\s*filament used \[g\]\s*=\s*([0-9.,\s]+)`)
+	// "filament used [mm]=1234.5,678.9" in bgcode metadata, "; filament used [mm] = 1234.5, 678.9" in ASCII
+	filamentLengthRegex = regexp.MustCompile(`(?:^|[^\w ]);?\s*filament used \[mm\]\s*=\s*([0-9.,\s]+)`)
+	// "; total filament used [g] = 5.79", the only weight some single-extruder ASCII files have
+	totalFilamentWeightRegex = regexp.MustCompile(`total filament used \[g\]\s*=\s*([0-9.]+)`)
+	// Cura header: ";Filament used: 1.23456m, 0.5m"
+	curaFilamentRegex = regexp.MustCompile(`;Filament used:\s*([0-9.,m\s]+)`)
+	// OrcaSlicer header: "; total filament weight [g] : 3.70,1.20", one amount per filament
+	orcaFilamentWeightRegex = regexp.MustCompile(`; total filament weight \[g\]\s*:\s*([0-9.,\s]+)`)
+	// OrcaSlicer header: "; total filament length [mm] : 1234.56,500.00"
+	orcaFilamentLengthRegex = regexp.MustCompile(`; total filament length \[mm\]\s*:\s*([0-9.,\s]+)`)
+	// "; estimated printing time (normal mode) = 1d 2h 3m 4s", without the spaces in bgcode
+	// metadata, or OrcaSlicer's "; model printing time: 1h 2m; total estimated time: 1h 5m"
+	printTimeRegex = regexp.MustCompile(`(?:estimated printing time \(normal mode\)\s*=|total estimated time:)\s*((?:\d+[dhms]\s*)+)`)
+	// Cura header: ";TIME:3723", in seconds
+	curaPrintTimeRegex = regexp.MustCompile(`;TIME:(\d+)`)
+	// One "2h" part of a PrusaSlicer duration
+	durationPartRegex = regexp.MustCompile(`(\d+)([dhms])`)
+)
+
+// GcodeUsage is the filament usage found in a G-code file, by toolhead
+type GcodeUsage struct {
+	Weights map[int]float64 // Grams
+	Lengths map[int]float64 // mm, for toolheads the file only reports a length for
+}
+
+// validGcodeFlavor reports whether a flavor is known; empty means auto-detect
+func validGcodeFlavor(flavor string) bool {
+	_, known := gcodeExtractors[flavor]
+	return flavor == GcodeFlavorAuto || known
+}
+
+// detectGcodeFlavor guesses the flavor of a file from its content
+func detectGcodeFlavor(content []byte) string {
+	for _, flavor := range gcodeDetectionOrder {
+		if gcodeExtractors[flavor].detect(content) {
+			return flavor
+		}
+	}
+	return GcodeFlavorPrusaSlicer
+}
+
+// ParseGcodeUsage extracts filament usage from a G-code file using the strategy for the
+// expected flavor. With auto-detection an empty result is returned when nothing is found;
+// with a declared flavor, a file that doesn't match it is an error.
+func ParseGcodeUsage(content []byte, flavor string) (GcodeUsage, error) {
+	usage := GcodeUsage{Weights: make(map[int]float64), Lengths: make(map[int]float64)}
+	declared := flavor != GcodeFlavorAuto
+	if !declared {
+		flavor = detectGcodeFlavor(content)
+	}
+
+	binary := bytes.HasPrefix(content, bgcodeMagic)
+	if declared && binary != (flavor == GcodeFlavorBgcode) {
+		actual := "plain-text G-code"
+		if binary {
+			actual = gcodeExtractors[GcodeFlavorBgcode].name
+		}
+		return usage, fmt.Errorf("printer expects %s but the file is %s; check the printer's G-code flavor", gcodeExtractors[flavor].name, actual)
+	}
+
+	extractor := gcodeExtractors[flavor]
+	extractor.extract(content, &usage)
+
+	if declared && len(usage.Weights) == 0 && len(usage.Lengths) == 0 {
+		return usage, fmt.Errorf("no %s found; the file doesn't look like %s", extractor.marker, extractor.name)
+	}
+	return usage, nil
+}
+
+// gcodeFilamentUsage parses a printer's G-code file into grams per toolhead, converting
+// lengths with the density and diameter of the mapped spools
+func (b *FilamentBridge) gcodeFilamentUsage(printerName string, config PrinterConfig, content []byte) (map[int]float64, error) {
+	usage, err := ParseGcodeUsage(content, config.GcodeFlavor)
+	if err != nil {
+		return nil, err
+	}
+
+	filamentUsage := usage.Weights
+	for toolheadID, length := range usage.Lengths {
+		if _, exists := filamentUsage[toolheadID]; !exists {
+			filamentUsage[toolheadID] = b.filamentLengthToWeight(printerName, toolheadID, length)
+		}
+	}
+	return filamentUsage, nil
+}
+
+// parseWeightsOrLengths reads the "filament used [g]" amounts, and "filament used [mm]" for
+// toolheads without a weight. PrusaSlicer writes zero grams when the filament profile has no
+// density, which leaves only the lengths. Single-extruder files without a per-toolhead weight,
+// like some footers written for MK3 and SD card prints, may still have a "total filament
+// used [g]", which is used for the first toolhead rather than converting its length.
+func parseWeightsOrLengths(content []byte, usage *GcodeUsage) {
+	if match := filamentWeightRegex.FindSubmatch(content); match != nil {
+		usage.Weights = parseGcodeValues(string(match[1]))
+	}
+	if match := filamentLengthRegex.FindSubmatch(content); match != nil {
+		for toolheadID, length := range parseGcodeValues(string(match[1])) {
+			if _, weighed := usage.Weights[toolheadID]; !weighed {
+				usage.Lengths[toolheadID] = length
+			}
+		}
+	}
+	_, firstToolhead := usage.Lengths[0]
+	if len(usage.Weights) > 0 || len(usage.Lengths) > 1 || (len(usage.Lengths) == 1 && !firstToolhead) {
+		return
+	}
+	if match := totalFilamentWeightRegex.FindSubmatch(content); match != nil {
+		if total, err := strconv.ParseFloat(string(match[1]), 64); err == nil && total > 0 {
+			usage.Weights[0] = total
+			delete(usage.Lengths, 0)
+		}
+	}
+}
+
+// ParseGcodePrintTime returns the slicer's estimated print time in seconds, or 0 when the
+// file doesn't say
+func ParseGcodePrintTime(content []byte) int {
+	if match := curaPrintTimeRegex.FindSubmatch(content); match != nil {
+		seconds, _ := strconv.Atoi(string(match[1]))
+		return seconds
+	}
+
+	match := printTimeRegex.FindSubmatch(content)
+	if match == nil {
+		return 0
+	}
+	unitSeconds := map[string]int{"d": 86400, "h": 3600, "m": 60, "s": 1}
+	seconds := 0
+	for _, part := range durationPartRegex.FindAllStringSubmatch(string(match[1]), -1) {
+		value, _ := strconv.Atoi(part[1])
+		seconds += value * unitSeconds[part[2]]
+	}
+	return seconds
+}
+
+// parseCuraUsage reads Cura's per-extruder lengths, which it reports in meters
+func parseCuraUsage(content []byte, usage *GcodeUsage) {
+	if match := curaFilamentRegex.FindSubmatch(content); match != nil {
+		for toolheadID, meters := range parseGcodeValues(strings.ReplaceAll(string(match[1]), "m", "")) {
+			usage.Lengths[toolheadID] = meters * 1000
+		}
+	}
+}
+
+// parseOrcaSlicerUsage reads the per-filament weights and lengths from OrcaSlicer's header
+// block, falling back to the PrusaSlicer-style footer it also writes
+func parseOrcaSlicerUsage(content []byte, usage *GcodeUsage) {
+	if match := orcaFilamentWeightRegex.FindSubmatch(content); match != nil {
+		usage.Weights = parseGcodeValues(string(match[1]))
+	}
+	if match := orcaFilamentLengthRegex.FindSubmatch(content); match != nil {
+		for toolheadID, length := range parseGcodeValues(string(match[1])) {
+			if _, weighed := usage.Weights[toolheadID]; !weighed {
+				usage.Lengths[toolheadID] = length
+			}
+		}
+	}
+	if len(usage.Weights) == 0 && len(usage.Lengths) == 0 {
+		parseWeightsOrLengths(content, usage)
+	}
+}
+
+// parseGcodeValues parses a comma-separated list of per-toolhead amounts, skipping zeros
+func parseGcodeValues(list string) map[int]float64 {
+	values := make(map[int]float64)
+	for i, value := range strings.Split(list, ",") {
+		if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && parsed > 0 {
+			values[i] = parsed
+		}
+	}
+	return values
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Paths of the probe endpoints, which answer at the root even when a base path is configured
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+)
+
+// spoolmanContact is the Spoolman client's transport; it records when Spoolman last answered
+// so readiness checks don't need a request of their own while the bridge is busy
+type spoolmanContact struct {
+	next http.RoundTripper
+	last atomic.Int64 // Unix nanoseconds, 0 until the first answer
+}
+
+// RoundTrip sends a request, counting any answer other than a server or authentication error
+// as contact
+func (t *spoolmanContact) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode < 500 && resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		t.last.Store(time.Now().UnixNano())
+	}
+	return resp, err
+}
+
+// LastContact returns when Spoolman last answered, or the zero time if it hasn't yet
+func (c *SpoolmanClient) LastContact() time.Time {
+	last := c.contact.last.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// healthzHandler reports whether the process is alive and its database answers. Liveness
+// probes restart the container when it fails, so it checks nothing outside FilaBridge.
+func (ws *WebServer) healthzHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), HealthCheckTimeout)
+	defer cancel()
+
+	var one int
+	if err := ws.bridge.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unhealthy",
+			"checks": gin.H{"database": err.Error()},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"checks": gin.H{"database": "ok"},
+	})
+}
+
+// readyzHandler reports whether the bridge can do its job: configuration is loaded and
+// Spoolman answered within the readiness window. When it hasn't, e.g. because nothing has
+// needed it lately, Spoolman is asked directly before failing.
+func (ws *WebServer) readyzHandler(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+
+	config := ws.bridge.GetConfigSnapshot()
+	window := time.Duration(DefaultReadinessSpoolmanWindow) * time.Minute
+	if config == nil {
+		checks["config"] = "not loaded"
+		ready = false
+	} else {
+		checks["config"] = "ok"
+		window = config.ReadinessSpoolmanWindow
+	}
+
+	client := ws.bridge.spoolman
+	lastContact := client.LastContact()
+	if time.Since(lastContact) <= window {
+		checks["spoolman"] = "ok"
+	} else {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), HealthCheckTimeout)
+		defer cancel()
+		if err := client.ping(ctx); err != nil {
+			checks["spoolman"] = err.Error()
+			ready = false
+		} else {
+			checks["spoolman"] = "ok"
+			lastContact = client.LastContact()
+		}
+	}
+
+	response := gin.H{"status": "ready", "checks": checks}
+	if !lastContact.IsZero() {
+		response["spoolman_last_contact"] = lastContact
+	}
+	if !ready {
+		response["status"] = "not_ready"
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
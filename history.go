@@ -0,0 +1,353 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// printHistoryColumns is the column list used when reading print history rows
+const printHistoryColumns = "id, printer_name, toolhead_id, spool_id, filament_used, print_started, print_finished, job_name, COALESCE(NULLIF(job_display_name, ''), job_name, ''), COALESCE(notes, ''), COALESCE(rating, 0), COALESCE(member, ''), COALESCE(reverted, 0), COALESCE(source, 'print'), COALESCE(waste, 0), cost"
+
+// PrintHistoryFilter narrows print history queries
+type PrintHistoryFilter struct {
+	SpoolID     int    // 0 means any spool
+	PrinterName string // Empty means any printer
+	Limit       int    // 0 means no limit
+}
+
+// scanPrintHistory scans rows selected with printHistoryColumns
+func scanPrintHistory(rows *sql.Rows) ([]PrintHistory, error) {
+	history := []PrintHistory{}
+	for rows.Next() {
+		var entry PrintHistory
+		var jobName sql.NullString
+		var cost sql.NullFloat64
+		if err := rows.Scan(&entry.ID, &entry.PrinterName, &entry.ToolheadID, &entry.SpoolID, &entry.FilamentUsed,
+			&entry.PrintStarted, &entry.PrintFinished, &jobName, &entry.JobDisplayName, &entry.Notes, &entry.Rating, &entry.Member, &entry.Reverted, &entry.Source, &entry.Waste, &cost); err != nil {
+			return nil, fmt.Errorf("failed to scan print history row: %w", err)
+		}
+		entry.JobName = jobName.String
+		if cost.Valid {
+			entry.Cost = &cost.Float64
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// GetPrintHistory returns print history entries, newest first
+func (b *FilamentBridge) GetPrintHistory(filter PrintHistoryFilter) ([]PrintHistory, error) {
+	query := "SELECT " + printHistoryColumns + " FROM print_history"
+	var conditions []string
+	var args []interface{}
+
+	if filter.SpoolID > 0 {
+		conditions = append(conditions, "spool_id = ?")
+		args = append(args, filter.SpoolID)
+	}
+	if filter.PrinterName != "" {
+		conditions = append(conditions, "printer_name = ?")
+		args = append(args, filter.PrinterName)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY print_finished DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get print history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPrintHistory(rows)
+}
+
+// GetPrintHistoryEntry returns a single print history entry
+func (b *FilamentBridge) GetPrintHistoryEntry(id int) (*PrintHistory, error) {
+	rows, err := b.db.Query("SELECT "+printHistoryColumns+" FROM print_history WHERE id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get print history entry: %w", err)
+	}
+	defer rows.Close()
+
+	history, err := scanPrintHistory(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, newCodedError(ErrCodeNotFound, "print history entry %d not found", id)
+	}
+	return &history[0], nil
+}
+
+// UpdatePrintHistoryNotes sets the notes and success rating of a print history entry.
+// Rating is 1-5, or 0 to clear it.
+func (b *FilamentBridge) UpdatePrintHistoryNotes(id int, notes string, rating int) (*PrintHistory, error) {
+	if rating < 0 || rating > MaxPrintRating {
+		return nil, newCodedError(ErrCodeInvalidRequest, "rating must be between 0 and %d", MaxPrintRating)
+	}
+
+	b.mutex.Lock()
+	result, err := b.db.Exec(
+		"UPDATE print_history SET notes = ?, rating = ? WHERE id = ?",
+		strings.TrimSpace(notes), rating, id,
+	)
+	b.mutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update print history entry: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, newCodedError(ErrCodeNotFound, "print history entry %d not found", id)
+	}
+
+	return b.GetPrintHistoryEntry(id)
+}
+
+// History correction actions
+const (
+	HistoryCorrectionRevert   = "revert"
+	HistoryCorrectionAdjust   = "adjust"
+	HistoryCorrectionReassign = "reassign"
+)
+
+// HistoryCorrection is an audit record of a change made to a print history entry
+type HistoryCorrection struct {
+	ID              int       `json:"id"`
+	HistoryID       int       `json:"history_id"`
+	Action          string    `json:"action"`
+	OldSpoolID      int       `json:"old_spool_id"`
+	NewSpoolID      int       `json:"new_spool_id"`
+	OldFilamentUsed float64   `json:"old_filament_used"`
+	NewFilamentUsed float64   `json:"new_filament_used"`
+	Reason          string    `json:"reason"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// HistoryAdjustment describes a correction to a print history entry; nil fields are unchanged
+type HistoryAdjustment struct {
+	SpoolID      *int     `json:"spool_id"`
+	FilamentUsed *float64 `json:"filament_used"`
+	Reason       string   `json:"reason"`
+}
+
+// RevertPrintHistory takes a recorded print's usage back out of Spoolman, e.g. when it
+// was counted twice, and marks the history entry as reverted
+func (b *FilamentBridge) RevertPrintHistory(id int, reason string) (*PrintHistory, error) {
+	entry, err := b.GetPrintHistoryEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Reverted {
+		return nil, newCodedError(ErrCodeConflict, "print history entry %d is already reverted", id)
+	}
+
+	if err := b.claimHistoryCorrection(*entry, HistoryCorrectionRevert, entry.SpoolID, 0, 0, entry.Cost); err != nil {
+		return nil, err
+	}
+	if err := b.spoolmanFor(entry.PrinterName).AdjustSpoolUsedWeight(entry.SpoolID, -entry.FilamentUsed); err != nil {
+		b.releaseHistoryCorrection(*entry)
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to revert usage on spool %d: %v", entry.SpoolID, err)
+	}
+
+	b.recordHistoryCorrection(*entry, HistoryCorrectionRevert, entry.SpoolID, 0, reason)
+	b.correctSpoolWaste(*entry, entry.SpoolID, 0)
+
+	bridgeLog.Info("Reverted print history entry", "entry_id", id, "spool_id", entry.SpoolID, "filament_used", entry.FilamentUsed)
+	return b.GetPrintHistoryEntry(id)
+}
+
+// AdjustPrintHistory corrects the spool or amount of a recorded print, moving the usage
+// in Spoolman accordingly
+func (b *FilamentBridge) AdjustPrintHistory(id int, adjustment HistoryAdjustment) (*PrintHistory, error) {
+	entry, err := b.GetPrintHistoryEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Reverted {
+		return nil, newCodedError(ErrCodeConflict, "print history entry %d is reverted and can't be adjusted", id)
+	}
+
+	newSpoolID := entry.SpoolID
+	if adjustment.SpoolID != nil {
+		newSpoolID = *adjustment.SpoolID
+	}
+	newFilamentUsed := entry.FilamentUsed
+	if adjustment.FilamentUsed != nil {
+		newFilamentUsed = *adjustment.FilamentUsed
+	}
+	if newSpoolID <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_id must be positive")
+	}
+	if newFilamentUsed < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "filament_used must not be negative")
+	}
+	if newSpoolID == entry.SpoolID && newFilamentUsed == entry.FilamentUsed {
+		return nil, newCodedError(ErrCodeInvalidRequest, "adjustment doesn't change the entry")
+	}
+
+	// The purge waste is part of the usage, so it's scaled with it
+	var newWaste float64
+	if entry.FilamentUsed > 0 {
+		newWaste = entry.Waste * newFilamentUsed / entry.FilamentUsed
+	}
+	if err := b.claimHistoryCorrection(*entry, HistoryCorrectionAdjust, newSpoolID, newFilamentUsed, newWaste, b.usageCost(entry.PrinterName, newSpoolID, newFilamentUsed)); err != nil {
+		return nil, err
+	}
+
+	if newSpoolID == entry.SpoolID {
+		delta := newFilamentUsed - entry.FilamentUsed
+		if err := b.spoolmanFor(entry.PrinterName).AdjustSpoolUsedWeight(entry.SpoolID, delta); err != nil {
+			b.releaseHistoryCorrection(*entry)
+			return nil, newCodedError(ErrCodeSpoolmanError, "failed to adjust usage on spool %d: %v", entry.SpoolID, err)
+		}
+	} else if err := b.moveHistoryUsage(*entry, newSpoolID, newFilamentUsed); err != nil {
+		b.releaseHistoryCorrection(*entry)
+		return nil, err
+	}
+
+	b.recordHistoryCorrection(*entry, HistoryCorrectionAdjust, newSpoolID, newFilamentUsed, adjustment.Reason)
+	b.correctSpoolWaste(*entry, newSpoolID, newWaste)
+
+	bridgeLog.Info("Adjusted print history entry", "entry_id", id,
+		"old_spool_id", entry.SpoolID, "old_filament_used", entry.FilamentUsed, "spool_id", newSpoolID, "filament_used", newFilamentUsed)
+	return b.GetPrintHistoryEntry(id)
+}
+
+// ReassignPrintHistory moves a recorded print to the spool it was really printed with, when
+// the wrong spool was mapped at the time: the usage is given back to the recorded spool and
+// charged to the new one
+func (b *FilamentBridge) ReassignPrintHistory(id, spoolID int, reason string) (*PrintHistory, error) {
+	entry, err := b.GetPrintHistoryEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Reverted {
+		return nil, newCodedError(ErrCodeConflict, "print history entry %d is reverted and can't be reassigned", id)
+	}
+	if spoolID <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_id must be positive")
+	}
+	if spoolID == entry.SpoolID {
+		return nil, newCodedError(ErrCodeInvalidRequest, "print history entry %d is already recorded on spool %d", id, spoolID)
+	}
+
+	// Check the spool before touching the recorded one, so a mistyped ID changes nothing
+	if _, err := b.spoolmanFor(entry.PrinterName).GetSpool(spoolID); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", spoolID, err)
+	}
+	if err := b.claimHistoryCorrection(*entry, HistoryCorrectionReassign, spoolID, entry.FilamentUsed, entry.Waste, b.usageCost(entry.PrinterName, spoolID, entry.FilamentUsed)); err != nil {
+		return nil, err
+	}
+	if err := b.moveHistoryUsage(*entry, spoolID, entry.FilamentUsed); err != nil {
+		b.releaseHistoryCorrection(*entry)
+		return nil, err
+	}
+
+	b.recordHistoryCorrection(*entry, HistoryCorrectionReassign, spoolID, entry.FilamentUsed, reason)
+	b.correctSpoolWaste(*entry, spoolID, entry.Waste)
+
+	bridgeLog.Info("Reassigned print history entry", "entry_id", id, "old_spool_id", entry.SpoolID, "spool_id", spoolID, "filament_used", entry.FilamentUsed)
+	return b.GetPrintHistoryEntry(id)
+}
+
+// moveHistoryUsage takes a history entry's usage off its spool in Spoolman, then charges
+// filamentUsed to another spool
+func (b *FilamentBridge) moveHistoryUsage(entry PrintHistory, spoolID int, filamentUsed float64) error {
+	if err := b.spoolmanFor(entry.PrinterName).AdjustSpoolUsedWeight(entry.SpoolID, -entry.FilamentUsed); err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to remove usage from spool %d: %v", entry.SpoolID, err)
+	}
+	if err := b.spoolmanFor(entry.PrinterName).AdjustSpoolUsedWeight(spoolID, filamentUsed); err != nil {
+		// Put the original usage back so Spoolman isn't left half-corrected
+		if rollbackErr := b.spoolmanFor(entry.PrinterName).AdjustSpoolUsedWeight(entry.SpoolID, entry.FilamentUsed); rollbackErr != nil {
+			bridgeLog.Error("Failed to restore spool usage after failed correction", "spool_id", entry.SpoolID, "error", rollbackErr)
+		}
+		return newCodedError(ErrCodeSpoolmanError, "failed to add usage to spool %d: %v", spoolID, err)
+	}
+	return nil
+}
+
+// claimHistoryCorrection writes a correction to its history entry before Spoolman is touched.
+// The update only applies while the entry is as it was read, so of two corrections racing on
+// the same entry one is refused instead of both changing Spoolman.
+func (b *FilamentBridge) claimHistoryCorrection(entry PrintHistory, action string, newSpoolID int, newFilamentUsed, newWaste float64, newCost *float64) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var result sql.Result
+	var err error
+	if action == HistoryCorrectionRevert {
+		result, err = b.db.Exec("UPDATE print_history SET reverted = 1 WHERE id = ? AND COALESCE(reverted, 0) = 0 AND spool_id = ? AND filament_used = ?",
+			entry.ID, entry.SpoolID, entry.FilamentUsed)
+	} else {
+		result, err = b.db.Exec("UPDATE print_history SET spool_id = ?, filament_used = ?, waste = ?, cost = ? WHERE id = ? AND COALESCE(reverted, 0) = 0 AND spool_id = ? AND filament_used = ?",
+			newSpoolID, newFilamentUsed, newWaste, newCost, entry.ID, entry.SpoolID, entry.FilamentUsed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update print history entry: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodeConflict, "print history entry %d was changed by another correction, reload it and try again", entry.ID)
+	}
+	return nil
+}
+
+// releaseHistoryCorrection puts a claimed history entry back as it was, when the correction
+// couldn't be made in Spoolman
+func (b *FilamentBridge) releaseHistoryCorrection(entry PrintHistory) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, err := b.db.Exec("UPDATE print_history SET reverted = 0, spool_id = ?, filament_used = ?, waste = ?, cost = ? WHERE id = ?",
+		entry.SpoolID, entry.FilamentUsed, entry.Waste, entry.Cost, entry.ID); err != nil {
+		bridgeLog.Error("Failed to restore print history entry after failed correction", "entry_id", entry.ID, "error", err)
+	}
+}
+
+// recordHistoryCorrection writes the audit record of a correction made to a history entry.
+// The correction is already in the entry and in Spoolman, so a failure here is only logged.
+func (b *FilamentBridge) recordHistoryCorrection(entry PrintHistory, action string, newSpoolID int, newFilamentUsed float64, reason string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(
+		`INSERT INTO history_corrections (history_id, action, old_spool_id, new_spool_id, old_filament_used, new_filament_used, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, action, entry.SpoolID, newSpoolID, entry.FilamentUsed, newFilamentUsed, strings.TrimSpace(reason), time.Now(),
+	)
+	if err != nil {
+		bridgeLog.Error("Failed to record history correction", "entry_id", entry.ID, "action", action, "error", err)
+	}
+}
+
+// GetHistoryCorrections returns the audit trail of corrections for a history entry, oldest first
+func (b *FilamentBridge) GetHistoryCorrections(historyID int) ([]HistoryCorrection, error) {
+	rows, err := b.db.Query(
+		`SELECT id, history_id, action, COALESCE(old_spool_id, 0), COALESCE(new_spool_id, 0),
+			COALESCE(old_filament_used, 0), COALESCE(new_filament_used, 0), COALESCE(reason, ''), created_at
+		FROM history_corrections WHERE history_id = ? ORDER BY id`,
+		historyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history corrections: %w", err)
+	}
+	defer rows.Close()
+
+	corrections := []HistoryCorrection{}
+	for rows.Next() {
+		var correction HistoryCorrection
+		if err := rows.Scan(&correction.ID, &correction.HistoryID, &correction.Action, &correction.OldSpoolID, &correction.NewSpoolID,
+			&correction.OldFilamentUsed, &correction.NewFilamentUsed, &correction.Reason, &correction.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history correction: %w", err)
+		}
+		corrections = append(corrections, correction)
+	}
+	return corrections, rows.Err()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Home Assistant availability payloads
+const (
+	HomeAssistantOnline  = "online"
+	HomeAssistantOffline = "offline"
+)
+
+// mqttSettings are the broker settings a connection was made with
+type mqttSettings struct {
+	broker          string
+	username        string
+	password        string
+	topicPrefix     string
+	discoveryPrefix string
+}
+
+// HomeAssistantPublisher publishes printer and spool state to MQTT with Home Assistant discovery
+type HomeAssistantPublisher struct {
+	bridge    *FilamentBridge
+	client    *MQTTClient
+	settings  mqttSettings
+	published map[string]string // Discovery config payloads published on the current broker, by topic
+	mutex     sync.Mutex
+}
+
+// homeAssistantEntity is a sensor announced through a discovery config topic
+type homeAssistantEntity struct {
+	topic  string
+	config map[string]interface{}
+}
+
+// homeAssistantToolhead is the state of one toolhead in a printer's state payload
+type homeAssistantToolhead struct {
+	Name            string   `json:"name"`
+	SpoolID         int      `json:"spool_id"`
+	SpoolName       string   `json:"spool_name"`
+	Brand           string   `json:"brand,omitempty"`
+	Material        string   `json:"material,omitempty"`
+	Color           string   `json:"color,omitempty"`
+	RemainingWeight *float64 `json:"remaining_weight"`
+}
+
+// homeAssistantPrinterState is the JSON state payload published per printer
+type homeAssistantPrinterState struct {
+	State       string                           `json:"state"`
+	PrintErrors int                              `json:"print_errors"`
+	Errors      []PrintError                     `json:"errors"`
+	Toolheads   map[string]homeAssistantToolhead `json:"toolheads"`
+}
+
+// NewHomeAssistantPublisher creates a publisher; nothing is sent until an MQTT broker is configured
+func NewHomeAssistantPublisher(bridge *FilamentBridge) *HomeAssistantPublisher {
+	return &HomeAssistantPublisher{
+		bridge:    bridge,
+		published: make(map[string]string),
+	}
+}
+
+// Publish sends the current state of every printer, announcing new entities and removing
+// ones that no longer exist. It runs as a scheduled job.
+func (h *HomeAssistantPublisher) Publish() error {
+	snapshot := h.bridge.GetConfigSnapshot()
+	if snapshot == nil || snapshot.MQTTBroker == "" {
+		h.Close()
+		return nil
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err := h.ensureConnected(snapshot); err != nil {
+		return err
+	}
+
+	status, err := h.bridge.GetStatus()
+	if err != nil {
+		return fmt.Errorf("failed to get printer status: %w", err)
+	}
+
+	// Spools by ID, fetched once for each Spoolman instance a printer uses
+	instanceSpools := make(map[string]map[int]SpoolmanSpool)
+	spoolsFor := func(printerName string) map[int]SpoolmanSpool {
+		instance := h.bridge.spoolmanInstanceOf(printerName)
+		if spools, fetched := instanceSpools[instance]; fetched {
+			return spools
+		}
+		spools := make(map[int]SpoolmanSpool)
+		if allSpools, err := h.bridge.spoolmanFor(printerName).GetAllSpools(); err != nil {
+			mqttLog.Warn("Failed to get spools for Home Assistant", "instance", instance, "error", err)
+		} else {
+			for _, spool := range allSpools {
+				spools[spool.ID] = spool
+			}
+		}
+		instanceSpools[instance] = spools
+		return spools
+	}
+
+	errorsByPrinter := make(map[string][]PrintError)
+	for _, printError := range h.bridge.GetPrintErrors() {
+		errorsByPrinter[printError.PrinterName] = append(errorsByPrinter[printError.PrinterName], printError)
+	}
+
+	current := make(map[string]bool)
+	for printerID, printerConfig := range snapshot.Printers {
+		printerData, exists := status.Printers[printerID]
+		if !exists {
+			continue
+		}
+
+		state := homeAssistantPrinterState{
+			State:     printerData.State,
+			Errors:    errorsByPrinter[printerConfig.Name],
+			Toolheads: make(map[string]homeAssistantToolhead),
+		}
+		if state.Errors == nil {
+			state.Errors = []PrintError{}
+		}
+		state.PrintErrors = len(state.Errors)
+
+		spools := spoolsFor(resolvePrinterName(printerConfig))
+		toolheadIDs := make([]int, 0, len(status.ToolheadMappings[printerID]))
+		for toolheadID, mapping := range status.ToolheadMappings[printerID] {
+			toolheadIDs = append(toolheadIDs, toolheadID)
+			state.Toolheads[fmt.Sprintf("%d", toolheadID)] = homeAssistantToolheadState(mapping, spools)
+		}
+		sort.Ints(toolheadIDs)
+
+		payload, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("failed to encode state of %s: %w", printerConfig.Name, err)
+		}
+		if err := h.client.Publish(h.stateTopic(printerID), payload, true); err != nil {
+			return fmt.Errorf("failed to publish state of %s: %w", printerConfig.Name, err)
+		}
+
+		for _, entity := range h.printerEntities(printerID, printerConfig, status.ToolheadMappings[printerID], toolheadIDs) {
+			current[entity.topic] = true
+			config, err := json.Marshal(entity.config)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s: %w", entity.topic, err)
+			}
+			if h.published[entity.topic] == string(config) {
+				continue
+			}
+			if err := h.client.Publish(entity.topic, config, true); err != nil {
+				return fmt.Errorf("failed to publish %s: %w", entity.topic, err)
+			}
+			h.published[entity.topic] = string(config)
+		}
+	}
+
+	// An empty retained config removes an entity from Home Assistant
+	for topic := range h.published {
+		if current[topic] {
+			continue
+		}
+		if err := h.client.Publish(topic, nil, true); err != nil {
+			return fmt.Errorf("failed to remove Home Assistant entity: %w", err)
+		}
+		delete(h.published, topic)
+	}
+
+	return nil
+}
+
+// ensureConnected connects to the broker, reconnecting when the connection dropped or the
+// broker settings changed. Discovery configs are republished on every new connection.
+func (h *HomeAssistantPublisher) ensureConnected(snapshot *Config) error {
+	settings := mqttSettings{
+		broker:          snapshot.MQTTBroker,
+		username:        snapshot.MQTTUsername,
+		password:        snapshot.MQTTPassword,
+		topicPrefix:     strings.Trim(snapshot.MQTTTopicPrefix, "/"),
+		discoveryPrefix: strings.Trim(snapshot.MQTTDiscoveryPrefix, "/"),
+	}
+	if settings.topicPrefix == "" {
+		settings.topicPrefix = DefaultMQTTTopicPrefix
+	}
+	if settings.discoveryPrefix == "" {
+		settings.discoveryPrefix = DefaultMQTTDiscoveryPrefix
+	}
+
+	if h.client != nil && !h.client.Closed() && h.settings == settings {
+		return nil
+	}
+	h.disconnect()
+
+	availabilityTopic := settings.topicPrefix + "/status"
+	client, err := NewMQTTClient(settings.broker, settings.topicPrefix, settings.username, settings.password,
+		&MQTTWill{Topic: availabilityTopic, Payload: HomeAssistantOffline, Retain: true})
+	if err != nil {
+		return err
+	}
+	if err := client.Publish(availabilityTopic, []byte(HomeAssistantOnline), true); err != nil {
+		client.Close()
+		return fmt.Errorf("failed to publish availability: %w", err)
+	}
+
+	mqttLog.Info("Connected to MQTT broker for Home Assistant", "broker", settings.broker)
+	h.client = client
+	h.settings = settings
+	h.published = make(map[string]string)
+	return nil
+}
+
+// Close marks FilaBridge offline and disconnects from the broker
+func (h *HomeAssistantPublisher) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.disconnect()
+}
+
+// disconnect closes the current connection, if any. The caller must hold the mutex.
+func (h *HomeAssistantPublisher) disconnect() {
+	if h.client == nil {
+		return
+	}
+	if !h.client.Closed() {
+		h.client.Publish(h.settings.topicPrefix+"/status", []byte(HomeAssistantOffline), true)
+		h.client.Close()
+	}
+	h.client = nil
+}
+
+// stateTopic returns the topic a printer's state payload is published on
+func (h *HomeAssistantPublisher) stateTopic(printerID string) string {
+	return fmt.Sprintf("%s/printer/%s/state", h.settings.topicPrefix, printerID)
+}
+
+// homeAssistantToolheadState describes the spool mapped to a toolhead
+func homeAssistantToolheadState(mapping ToolheadMapping, spools map[int]SpoolmanSpool) homeAssistantToolhead {
+	toolhead := homeAssistantToolhead{
+		Name:      mapping.DisplayName,
+		SpoolID:   mapping.SpoolID,
+		SpoolName: "None",
+	}
+	if mapping.SpoolID == 0 {
+		return toolhead
+	}
+
+	toolhead.SpoolName = fmt.Sprintf("Spool %d", mapping.SpoolID)
+	if spool, exists := spools[mapping.SpoolID]; exists {
+		if spool.Name != "" {
+			toolhead.SpoolName = spool.Name
+		}
+		toolhead.Brand = spool.Brand
+		toolhead.Material = spool.Material
+		if spool.Filament != nil && spool.Filament.ColorHex != "" {
+			toolhead.Color = "#" + strings.TrimPrefix(spool.Filament.ColorHex, "#")
+		}
+		remaining := spool.RemainingWeight
+		toolhead.RemainingWeight = &remaining
+	}
+	return toolhead
+}
+
+// printerEntities returns the discovery configs of a printer's sensors: its state, its open
+// print errors, and the spool and remaining weight on each toolhead
+func (h *HomeAssistantPublisher) printerEntities(printerID string, printerConfig PrinterConfig, mappings map[int]ToolheadMapping, toolheadIDs []int) []homeAssistantEntity {
+	device := map[string]interface{}{
+		"identifiers":  []string{"filabridge_" + printerID},
+		"name":         printerConfig.Name,
+		"manufacturer": "Prusa Research",
+	}
+	if printerConfig.Model != "" {
+		device["model"] = printerConfig.Model
+	}
+
+	entity := func(object, name, valueTemplate string, extra map[string]interface{}) homeAssistantEntity {
+		uniqueID := fmt.Sprintf("filabridge_%s_%s", printerID, object)
+		config := map[string]interface{}{
+			"name":               name,
+			"unique_id":          uniqueID,
+			"state_topic":        h.stateTopic(printerID),
+			"value_template":     valueTemplate,
+			"availability_topic": h.settings.topicPrefix + "/status",
+			"device":             device,
+		}
+		for key, value := range extra {
+			config[key] = value
+		}
+		return homeAssistantEntity{
+			topic:  fmt.Sprintf("%s/sensor/%s/config", h.settings.discoveryPrefix, uniqueID),
+			config: config,
+		}
+	}
+
+	entities := []homeAssistantEntity{
+		entity("state", "State", "{{ value_json.state }}", map[string]interface{}{
+			"icon": "mdi:printer-3d",
+		}),
+		entity("print_errors", "Print errors", "{{ value_json.print_errors }}", map[string]interface{}{
+			"icon":                     "mdi:alert-circle",
+			"state_class":              "measurement",
+			"json_attributes_topic":    h.stateTopic(printerID),
+			"json_attributes_template": "{{ {'errors': value_json.errors} | tojson }}",
+		}),
+	}
+
+	for _, toolheadID := range toolheadIDs {
+		toolhead := fmt.Sprintf("value_json.toolheads['%d']", toolheadID)
+		name := mappings[toolheadID].DisplayName
+		entities = append(entities,
+			entity(fmt.Sprintf("toolhead_%d_spool", toolheadID), name+" spool", "{{ "+toolhead+".spool_name }}", map[string]interface{}{
+				"icon":                     "mdi:printer-3d-nozzle",
+				"json_attributes_topic":    h.stateTopic(printerID),
+				"json_attributes_template": "{{ " + toolhead + " | tojson }}",
+			}),
+			entity(fmt.Sprintf("toolhead_%d_remaining", toolheadID), name+" remaining", "{{ "+toolhead+".remaining_weight }}", map[string]interface{}{
+				"device_class":        "weight",
+				"state_class":         "measurement",
+				"unit_of_measurement": "g",
+			}),
+		)
+	}
+	return entities
+}
@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+
+// PrinterJobProgress is the job a printer is running or has paused
+type PrinterJobProgress struct {
+	ID                   int     `json:"id"`
+	File                 string  `json:"file"`         // Raw file name as reported by the printer
+	DisplayName          string  `json:"display_name"` // Decoded, human-readable job name
+	Progress             float64 `json:"progress"`     // Percent
+	TimeRemainingSeconds int     `json:"time_remaining_seconds"`
+	TimePrintingSeconds  int     `json:"time_printing_seconds"`
+}
+
+// PrinterTemperatures are a printer's current and target temperatures in °C. The nozzle is
+// the active one on printers with several toolheads.
+type PrinterTemperatures struct {
+	Nozzle       float64 `json:"nozzle"`
+	NozzleTarget float64 `json:"nozzle_target"`
+	Bed          float64 `json:"bed"`
+	BedTarget    float64 `json:"bed_target"`
+}
+
+// applyJobProgress fills in a printer's current job and temperatures from its last status.
+// Prusa Connect doesn't report temperatures, so they're left out for its printers.
+func applyJobProgress(data *PrinterData, status *PrusaLinkStatus, job *PrusaLinkJob) {
+	if job != nil && job.ID != 0 {
+		data.Job = &PrinterJobProgress{
+			ID:                   job.ID,
+			File:                 job.File.Name,
+			DisplayName:          displayFilename(job.File.Name, job.File.DisplayName),
+			Progress:             job.Progress,
+			TimeRemainingSeconds: max(job.TimeRemaining, 0),
+			TimePrintingSeconds:  max(job.TimePrinting, 0),
+		}
+	}
+
+	// Newer firmware reports flat fields, older the per-tool temperature object
+	printer := status.Printer
+	temperatures := PrinterTemperatures{
+		Nozzle:       firstNonZero(printer.TempNozzle, printer.Temperature.Tool0.Actual),
+		NozzleTarget: firstNonZero(printer.TargetNozzle, printer.Temperature.Tool0.Target),
+		Bed:          firstNonZero(printer.TempBed, printer.Temperature.Bed.Actual),
+		BedTarget:    firstNonZero(printer.TargetBed, printer.Temperature.Bed.Target),
+	}
+	if temperatures != (PrinterTemperatures{}) {
+		data.Temperatures = &temperatures
+	}
+}
+
+// firstNonZero returns the first value that isn't zero
+func firstNonZero(values ...float64) float64 {
+	for _, value := range values {
+		if value != 0 {
+			return value
+		}
+	}
+	return 0
+}
+
+// formatDuration formats seconds for the dashboard, e.g. "1h 05m" or "42m"
+func formatDuration(seconds int) string {
+	minutes := seconds / 60
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Printer audit event types
+const (
+	PrinterEventAPIKeyRotated        = "api_key_rotated"
+	PrinterEventWebhookSecretRotated = "webhook_secret_rotated"
+	PrinterEventWebhookSecretRemoved = "webhook_secret_removed"
+	PrinterEventControlAction        = "control_action" // A job or heaters controlled from FilaBridge
+)
+
+// PrinterEvent is an entry in a printer's audit log
+type PrinterEvent struct {
+	ID        int       `json:"id"`
+	PrinterID string    `json:"printer_id"`
+	EventType string    `json:"event_type"`
+	Member    string    `json:"member,omitempty"` // Member who made the change, if any
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// recordPrinterEvent writes an entry to a printer's audit log
+func (b *FilamentBridge) recordPrinterEvent(event PrinterEvent) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(
+		"INSERT INTO printer_events (printer_id, event_type, member, notes, created_at) VALUES (?, ?, ?, ?, ?)",
+		event.PrinterID, event.EventType, event.Member, event.Notes, event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record printer event: %w", err)
+	}
+	return nil
+}
+
+// GetPrinterEvents returns a printer's audit log, oldest first
+func (b *FilamentBridge) GetPrinterEvents(printerID string) ([]PrinterEvent, error) {
+	rows, err := b.db.Query(
+		"SELECT id, printer_id, event_type, COALESCE(member, ''), COALESCE(notes, ''), created_at FROM printer_events WHERE printer_id = ? ORDER BY id",
+		printerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get printer events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []PrinterEvent{}
+	for rows.Next() {
+		var event PrinterEvent
+		if err := rows.Scan(&event.ID, &event.PrinterID, &event.EventType, &event.Member, &event.Notes, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan printer event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// maskAPIKey shows only the last characters of a key, for logs and the audit trail
+func maskAPIKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// verifyPrinterAPIKey checks that the printer accepts an API key
+func (b *FilamentBridge) verifyPrinterAPIKey(config PrinterConfig, apiKey string) error {
+	snapshot := b.GetConfigSnapshot()
+	timeout, downloadTimeout := config.prusaLinkTimeouts(snapshot)
+	client := NewPrusaLinkClient(config.IPAddress, apiKey, timeout, downloadTimeout, config.InsecureSkipVerify)
+	if _, err := client.GetStatus(); err != nil {
+		return newCodedError(ErrCodePrinterError, "the printer rejected the new API key: %v", err)
+	}
+	return nil
+}
+
+// rotatePrinterKeyHandler replaces a printer's PrusaLink API key once the printer has
+// accepted it, so monitoring switches straight from the old key to a working new one. With
+// verify_only the key is only checked, for setting it on the printer first.
+func (ws *WebServer) rotatePrinterKeyHandler(c *gin.Context) {
+	// Serialize printer operations to prevent race conditions
+	ws.operationMutex.Lock()
+	defer ws.operationMutex.Unlock()
+
+	printerID := c.Param("id")
+
+	var req struct {
+		APIKey     string `json:"api_key"`
+		VerifyOnly bool   `json:"verify_only"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	newKey := strings.TrimSpace(req.APIKey)
+	if newKey == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "api_key is required")
+		return
+	}
+
+	// Read the stored config rather than the snapshot so the swap starts from current data
+	printerConfigs, err := ws.bridge.GetAllPrinterConfigs()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	config, exists := printerConfigs[printerID]
+	if !exists {
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
+		return
+	}
+	if config.IPAddress == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Only printers with a PrusaLink address have an API key")
+		return
+	}
+
+	if err := ws.bridge.verifyPrinterAPIKey(config, newKey); err != nil {
+		respondErrorFrom(c, http.StatusBadGateway, ErrCodePrinterError, err)
+		return
+	}
+	if req.VerifyOnly {
+		c.JSON(http.StatusOK, APIKeyRotationResponse{Message: "The printer accepted the API key", Verified: true})
+		return
+	}
+	if newKey == config.APIKey {
+		c.JSON(http.StatusOK, APIKeyRotationResponse{Message: "The printer already uses this API key", Verified: true})
+		return
+	}
+
+	oldKey := config.APIKey
+	config.APIKey = newKey
+	if err := ws.bridge.SavePrinterConfig(printerID, config); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if err := ws.reloadBridgeConfig(); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reload configuration")
+		return
+	}
+
+	var member string
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+	event := PrinterEvent{
+		PrinterID: printerID,
+		EventType: PrinterEventAPIKeyRotated,
+		Member:    member,
+		Notes:     fmt.Sprintf("API key changed from %s to %s", maskAPIKey(oldKey), maskAPIKey(newKey)),
+		CreatedAt: time.Now(),
+	}
+	if err := ws.bridge.recordPrinterEvent(event); err != nil {
+		// The key has already been swapped, so report success and keep the failure in the log
+		webLog.Error("Failed to record API key rotation", "printer_id", printerID, "error", err)
+	}
+
+	webLog.Info("Rotated printer API key", "printer_id", printerID, "printer", config.Name, "api_key", maskAPIKey(newKey))
+	c.JSON(http.StatusOK, APIKeyRotationResponse{Message: "API key rotated successfully", Verified: true})
+}
+
+// getPrinterEventsHandler returns a printer's audit log
+func (ws *WebServer) getPrinterEventsHandler(c *gin.Context) {
+	events, err := ws.bridge.GetPrinterEvents(c.Param("id"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, PrinterEventsResponse{Events: events})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+// Page sizes in mm
+const (
+	pageA4Width      = 210.0
+	pageA4Height     = 297.0
+	pageLetterWidth  = 215.9
+	pageLetterHeight = 279.4
+)
+
+// Defaults for custom label sizes
+const (
+	customLabelMargin = 10.0 // mm around the grid
+	customLabelGap    = 2.0  // mm between labels
+)
+
+// DefaultLabelTemplate is used when no template or label size is requested
+const DefaultLabelTemplate = "avery-l7160"
+
+// labelLayout is a grid of labels on a sheet, in mm
+type labelLayout struct {
+	PageWidth, PageHeight   float64
+	LabelWidth, LabelHeight float64
+	Columns, Rows           int
+	MarginLeft, MarginTop   float64 // Offset of the first label from the page corner
+	PitchX, PitchY          float64 // Distance between the corners of neighbouring labels
+}
+
+// labelTemplates are common label sheets by manufacturer product code
+var labelTemplates = map[string]labelLayout{
+	// A4, 21 per sheet, 63.5 x 38.1mm
+	"avery-l7160": {pageA4Width, pageA4Height, 63.5, 38.1, 3, 7, 7.21, 15.15, 66.04, 38.1},
+	// A4, 14 per sheet, 99.1 x 38.1mm
+	"avery-l7163": {pageA4Width, pageA4Height, 99.1, 38.1, 2, 7, 4.65, 15.15, 101.6, 38.1},
+	// A4, 65 per sheet, 38.1 x 21.2mm
+	"avery-l7651": {pageA4Width, pageA4Height, 38.1, 21.2, 5, 13, 4.75, 10.7, 40.6, 21.2},
+	// Letter, 30 per sheet, 2.625 x 1in
+	"avery-5160": {pageLetterWidth, pageLetterHeight, 66.675, 25.4, 3, 10, 4.7625, 12.7, 69.85, 25.4},
+	// Letter, 10 per sheet, 4 x 2in
+	"avery-5163": {pageLetterWidth, pageLetterHeight, 101.6, 50.8, 2, 5, 3.96875, 12.7, 104.775, 50.8},
+}
+
+// customLabelLayout fits as many labels of a size as possible on a page, centred
+func customLabelLayout(page string, width, height float64) (labelLayout, error) {
+	layout := labelLayout{PageWidth: pageA4Width, PageHeight: pageA4Height, LabelWidth: width, LabelHeight: height}
+	switch strings.ToLower(page) {
+	case "", "a4":
+	case "letter":
+		layout.PageWidth, layout.PageHeight = pageLetterWidth, pageLetterHeight
+	default:
+		return layout, newCodedError(ErrCodeInvalidRequest, "unknown page size %q, use a4 or letter", page)
+	}
+
+	layout.PitchX, layout.PitchY = width+customLabelGap, height+customLabelGap
+	layout.Columns = int((layout.PageWidth - 2*customLabelMargin + customLabelGap) / layout.PitchX)
+	layout.Rows = int((layout.PageHeight - 2*customLabelMargin + customLabelGap) / layout.PitchY)
+	if width < 15 || height < 10 || layout.Columns < 1 || layout.Rows < 1 {
+		return layout, newCodedError(ErrCodeInvalidRequest, "labels of %.1f x %.1fmm don't fit on the page", width, height)
+	}
+
+	layout.MarginLeft = (layout.PageWidth - (float64(layout.Columns)*layout.PitchX - customLabelGap)) / 2
+	layout.MarginTop = (layout.PageHeight - (float64(layout.Rows)*layout.PitchY - customLabelGap)) / 2
+	return layout, nil
+}
+
+// labelContent is what's printed on one label
+type labelContent struct {
+	URL      string // Encoded in the QR code
+	Title    string
+	Subtitle string
+	Detail   string
+	ColorHex string // Swatch color, if any
+}
+
+// renderLabelSheets lays labels out on as many sheets as needed, leaving the first skip
+// positions empty so partly used sheets can be reused
+func renderLabelSheets(layout labelLayout, labels []labelContent, skip int, outline bool) ([]byte, error) {
+	doc := newPDFDocument(layout.PageWidth, layout.PageHeight)
+	perPage := layout.Columns * layout.Rows
+
+	for i, label := range labels {
+		position := (i + skip) % perPage
+		if i == 0 || position == 0 {
+			doc.addPage()
+		}
+		x := layout.MarginLeft + float64(position%layout.Columns)*layout.PitchX
+		y := layout.MarginTop + float64(position/layout.Columns)*layout.PitchY
+		if outline {
+			doc.strokeRect(x, y, layout.LabelWidth, layout.LabelHeight)
+		}
+		if err := drawLabel(doc, x, y, layout.LabelWidth, layout.LabelHeight, label); err != nil {
+			return nil, err
+		}
+	}
+	return doc.bytes()
+}
+
+// drawLabel draws a QR code on the left of a label and its text on the right
+func drawLabel(doc *pdfDocument, x, y, width, height float64, label labelContent) error {
+	padding := math.Min(2.5, height*0.08)
+	qrSize := math.Min(height-2*padding, width/2)
+
+	qr, err := qrcode.New(label.URL, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	qr.DisableBorder = true
+	bitmap := qr.Bitmap()
+	module := qrSize / float64(len(bitmap))
+	qrX, qrY := x+padding, y+(height-qrSize)/2
+
+	// Dark modules are drawn as runs per row to keep the page small
+	doc.setFillColor(0, 0, 0)
+	for row, cells := range bitmap {
+		for col := 0; col < len(cells); {
+			if !cells[col] {
+				col++
+				continue
+			}
+			start := col
+			for col < len(cells) && cells[col] {
+				col++
+			}
+			doc.rect(qrX+float64(start)*module, qrY+float64(row)*module, float64(col-start)*module, module)
+		}
+	}
+	doc.fill()
+
+	textX := qrX + qrSize + padding
+	textWidth := x + width - padding - textX
+	if textWidth < 10 {
+		return nil // Only room for the QR code
+	}
+
+	// Four lines: title, subtitle, detail and the color swatch
+	lineHeight := (height - 2*padding) / 4
+	size := math.Min(11, lineHeight*mmToPt*0.8)
+	baseline := y + padding + lineHeight*0.8
+	for i, line := range []string{label.Title, label.Subtitle, label.Detail} {
+		if line == "" {
+			continue
+		}
+		font, lineSize := pdfFontRegular, size*0.85
+		if i == 0 {
+			font, lineSize = pdfFontBold, size
+		}
+		doc.text(textX, baseline+float64(i)*lineHeight, font, lineSize, pdfFitText(line, lineSize, textWidth))
+	}
+
+	if r, g, b, ok := colorHexBytes(label.ColorHex); ok {
+		swatchY := y + padding + 3*lineHeight + lineHeight*0.15
+		swatchWidth := math.Min(textWidth, 15)
+		doc.setFillColor(r, g, b)
+		doc.rect(textX, swatchY, swatchWidth, lineHeight*0.7)
+		doc.fill()
+		doc.strokeRect(textX, swatchY, swatchWidth, lineHeight*0.7)
+		doc.setFillColor(0, 0, 0)
+	}
+	return nil
+}
+
+// nfcLabelsHandler returns a printable PDF sheet of NFC/QR labels for spools or locations.
+// The layout comes from a label template or a custom label_width and label_height in mm.
+func (ws *WebServer) nfcLabelsHandler(c *gin.Context) {
+	var layout labelLayout
+	if c.Query("label_width") != "" || c.Query("label_height") != "" {
+		width, widthErr := strconv.ParseFloat(c.Query("label_width"), 64)
+		height, heightErr := strconv.ParseFloat(c.Query("label_height"), 64)
+		if widthErr != nil || heightErr != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "label_width and label_height must both be numbers in mm")
+			return
+		}
+		custom, err := customLabelLayout(c.Query("page"), width, height)
+		if err != nil {
+			respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+			return
+		}
+		layout = custom
+	} else {
+		name := strings.ToLower(c.DefaultQuery("template", DefaultLabelTemplate))
+		template, exists := labelTemplates[name]
+		if !exists {
+			names := make([]string, 0, len(labelTemplates))
+			for templateName := range labelTemplates {
+				names = append(names, templateName)
+			}
+			sort.Strings(names)
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+				fmt.Sprintf("Unknown label template %q, available: %s", name, strings.Join(names, ", ")))
+			return
+		}
+		layout = template
+	}
+
+	skip, err := strconv.Atoi(c.DefaultQuery("skip", "0"))
+	if err != nil || skip < 0 || skip >= layout.Columns*layout.Rows {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+			fmt.Sprintf("skip must be between 0 and %d", layout.Columns*layout.Rows-1))
+		return
+	}
+
+	labelType := c.DefaultQuery("type", "spool")
+	var labels []labelContent
+	switch labelType {
+	case "spool":
+		labels, err = ws.spoolLabels(c, c.Query("ids"))
+	case "location":
+		labels, err = ws.locationLabels(c)
+	default:
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "type must be spool or location")
+		return
+	}
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
+		return
+	}
+	if len(labels) == 0 {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "No labels to print")
+		return
+	}
+
+	pdf, err := renderLabelSheets(layout, labels, skip, c.Query("outline") == "true")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="filabridge-%s-labels.pdf"`, labelType))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// spoolLabels builds labels for the given comma-separated spool IDs, or all active spools, in
+// the Spoolman instance of the printer_name query parameter
+func (ws *WebServer) spoolLabels(c *gin.Context, ids string) ([]labelContent, error) {
+	spools, err := ws.bridge.spoolmanFor(c.Query("printer_name")).GetAllSpools()
+	if err != nil {
+		return nil, err
+	}
+
+	var wanted map[int]bool
+	if ids = strings.TrimSpace(ids); ids != "" {
+		wanted = make(map[int]bool)
+		for _, value := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, newCodedError(ErrCodeInvalidRequest, "invalid spool ID %q", value)
+			}
+			wanted[id] = true
+		}
+	}
+
+	sort.Slice(spools, func(i, j int) bool { return spools[i].ID < spools[j].ID })
+
+	var labels []labelContent
+	for _, spool := range spools {
+		if wanted != nil && !wanted[spool.ID] {
+			continue
+		}
+		title := spool.Name
+		if title == "" {
+			title = fmt.Sprintf("Spool %d", spool.ID)
+		}
+		var colorHex string
+		if spool.Filament != nil {
+			colorHex = spool.Filament.ColorHex
+		}
+		labels = append(labels, labelContent{
+			URL:      ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?spool=%d", ws.publicBaseURL(c), spool.ID)),
+			Title:    title,
+			Subtitle: strings.TrimSpace(spool.Brand + " " + spool.Material),
+			Detail:   fmt.Sprintf("Spool #%d", spool.ID),
+			ColorHex: colorHex,
+		})
+	}
+	return labels, nil
+}
+
+// locationLabels builds labels for all active locations in the Spoolman instance of the
+// printer_name query parameter
+func (ws *WebServer) locationLabels(c *gin.Context) ([]labelContent, error) {
+	locations, err := ws.bridge.spoolmanFor(c.Query("printer_name")).GetLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+
+	var labels []labelContent
+	for _, location := range locations {
+		if location.Archived || strings.TrimSpace(location.Name) == "" {
+			continue
+		}
+		labels = append(labels, labelContent{
+			URL:      ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?location=%s", ws.publicBaseURL(c), neturl.QueryEscape(location.Name))),
+			Title:    location.Name,
+			Subtitle: "Location",
+			Detail:   location.Comment,
+		})
+	}
+	return labels, nil
+}
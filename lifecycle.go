@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lifecycle events sent to outgoing webhooks
+const (
+	LifecycleEventPrintCompleted = "print_completed"
+	LifecycleEventSpoolDeducted  = "spool_deducted"
+	LifecycleEventPrintError     = "print_error"
+	LifecycleEventSpoolAssigned  = "spool_assigned"
+	LifecycleEventSpoolEmpty     = "spool_empty"
+)
+
+// lifecycleEvents lists the events a webhook can filter on
+var lifecycleEvents = map[string]bool{
+	LifecycleEventPrintCompleted: true,
+	LifecycleEventSpoolDeducted:  true,
+	LifecycleEventPrintError:     true,
+	LifecycleEventSpoolAssigned:  true,
+	LifecycleEventSpoolEmpty:     true,
+}
+
+// Headers sent with every outgoing webhook request
+const (
+	LifecycleEventHeader     = "X-FilaBridge-Event"
+	LifecycleSignatureHeader = "X-FilaBridge-Signature" // "sha256=" and the hex HMAC of the body, when the webhook has a secret
+)
+
+// OutgoingWebhook is an endpoint lifecycle events are posted to as JSON, e.g. an n8n or
+// Node-RED flow. Unlike notification channels it gets the event's data rather than a message.
+type OutgoingWebhook struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret,omitempty"`   // Signs each body so the receiver can check where it came from
+	Events   []string `json:"events,omitempty"`   // Empty means all events
+	Template string   `json:"template,omitempty"` // Go template producing the JSON body; empty sends the event as is
+	Enabled  bool     `json:"enabled"`
+}
+
+// LifecycleEvent is a single event delivered to outgoing webhooks. Templates see its fields,
+// e.g. {{.Event}} and {{.Data.spool_id}}.
+type LifecycleEvent struct {
+	Event     string                 `json:"event"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// lifecycleTemplateFuncs are available in webhook templates; json quotes a value so strings
+// from job names can't break the body
+var lifecycleTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// parseOutgoingWebhooks parses and validates the JSON webhook list from the configuration table
+func parseOutgoingWebhooks(value string) ([]OutgoingWebhook, error) {
+	if strings.TrimSpace(value) == "" {
+		return []OutgoingWebhook{}, nil
+	}
+
+	var webhooks []OutgoingWebhook
+	if err := json.Unmarshal([]byte(value), &webhooks); err != nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "invalid outgoing webhooks: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, webhook := range webhooks {
+		if webhook.Name == "" {
+			return nil, newCodedError(ErrCodeInvalidRequest, "outgoing webhook name is required")
+		}
+		if names[webhook.Name] {
+			return nil, newCodedError(ErrCodeInvalidRequest, "duplicate outgoing webhook name: %s", webhook.Name)
+		}
+		names[webhook.Name] = true
+
+		if !strings.HasPrefix(webhook.URL, "http://") && !strings.HasPrefix(webhook.URL, "https://") {
+			return nil, newCodedError(ErrCodeInvalidRequest, "outgoing webhook %s requires an http or https URL", webhook.Name)
+		}
+		for _, event := range webhook.Events {
+			if !lifecycleEvents[event] {
+				return nil, newCodedError(ErrCodeInvalidRequest, "unknown event for outgoing webhook %s: %s", webhook.Name, event)
+			}
+		}
+		if _, err := webhook.template(); err != nil {
+			return nil, newCodedError(ErrCodeInvalidRequest, "invalid template for outgoing webhook %s: %v", webhook.Name, err)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// template parses the webhook's body template, or returns nil when it has none
+func (w OutgoingWebhook) template() (*template.Template, error) {
+	if strings.TrimSpace(w.Template) == "" {
+		return nil, nil
+	}
+	return template.New(w.Name).Funcs(lifecycleTemplateFuncs).Option("missingkey=zero").Parse(w.Template)
+}
+
+// subscribes reports whether the webhook wants the given event
+func (w OutgoingWebhook) subscribes(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// body renders the request body for an event
+func (w OutgoingWebhook) body(event LifecycleEvent) ([]byte, error) {
+	tmpl, err := w.template()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if tmpl == nil {
+		return json.Marshal(event)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("template did not produce valid JSON")
+	}
+	return body.Bytes(), nil
+}
+
+// signLifecycleBody returns the signature header value for a body
+func signLifecycleBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// emitLifecycleEvent posts an event to every enabled webhook subscribed to it. Delivery runs
+// in the background and never holds up the print processing that raised the event.
+func (b *FilamentBridge) emitLifecycleEvent(event string, data map[string]interface{}) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+
+	lifecycleEvent := LifecycleEvent{Event: event, Timestamp: time.Now(), Data: data}
+	for _, webhook := range snapshot.OutgoingWebhooks {
+		if !webhook.Enabled || !webhook.subscribes(event) {
+			continue
+		}
+		go b.deliverLifecycleEvent(webhook, lifecycleEvent)
+	}
+}
+
+// deliverLifecycleEvent posts an event to a webhook, retrying failed attempts a few times
+func (b *FilamentBridge) deliverLifecycleEvent(webhook OutgoingWebhook, event LifecycleEvent) {
+	body, err := webhook.body(event)
+	if err != nil {
+		notificationsLog.Warn("Failed to build outgoing webhook body", "webhook", webhook.Name, "event", event.Event, "error", err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := b.postLifecycleEvent(webhook, event.Event, body)
+		if err == nil {
+			notificationsLog.Debug("Sent outgoing webhook", "webhook", webhook.Name, "event", event.Event)
+			return
+		}
+		if attempt >= OutgoingWebhookAttempts {
+			notificationsLog.Warn("Failed to send outgoing webhook", "webhook", webhook.Name, "event", event.Event, "attempts", attempt, "error", err)
+			return
+		}
+		time.Sleep(time.Duration(attempt) * OutgoingWebhookRetryDelay)
+	}
+}
+
+// postLifecycleEvent sends one attempt of a webhook request
+func (b *FilamentBridge) postLifecycleEvent(webhook OutgoingWebhook, event string, body []byte) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(LifecycleEventHeader, event)
+	if webhook.Secret != "" {
+		req.Header.Set(LifecycleSignatureHeader, signLifecycleBody(webhook.Secret, body))
+	}
+
+	resp, err := b.notifier.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getOutgoingWebhooksHandler returns the configured outgoing webhooks
+func (ws *WebServer) getOutgoingWebhooksHandler(c *gin.Context) {
+	snapshot := ws.bridge.GetConfigSnapshot()
+	webhooks := []OutgoingWebhook{}
+	if snapshot != nil {
+		webhooks = snapshot.OutgoingWebhooks
+	}
+	c.JSON(http.StatusOK, OutgoingWebhooksResponse{Webhooks: webhooks})
+}
+
+// updateOutgoingWebhooksHandler replaces the outgoing webhook list
+func (ws *WebServer) updateOutgoingWebhooksHandler(c *gin.Context) {
+	var req struct {
+		Webhooks []OutgoingWebhook `json:"webhooks"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	value, err := json.Marshal(req.Webhooks)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	// Validate before saving so a bad webhook doesn't disable all of them
+	if _, err := parseOutgoingWebhooks(string(value)); err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+		return
+	}
+
+	if err := ws.bridge.SetConfigValue(ConfigKeyOutgoingWebhooks, string(value)); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	if err := ws.bridge.ReloadConfig(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Outgoing webhooks updated successfully"})
+}
+
+// emitSpoolDeducted sends the spool_deducted event for usage written to Spoolman
+func (b *FilamentBridge) emitSpoolDeducted(printerName string, toolheadID, spoolID int, grams float64, jobName, jobDisplayName, source string) {
+	b.emitLifecycleEvent(LifecycleEventSpoolDeducted, map[string]interface{}{
+		"printer_name":     printerName,
+		"toolhead_id":      toolheadID,
+		"spool_id":         spoolID,
+		"grams":            grams,
+		"job_name":         jobName,
+		"job_display_name": displayFilename(jobName, jobDisplayName),
+		"source":           source,
+	})
+}
+
+// emitSpoolAssigned sends the spool_assigned event for a spool mapped to a toolhead
+func (b *FilamentBridge) emitSpoolAssigned(printerName string, toolheadID, spoolID, previousSpoolID int) {
+	b.emitLifecycleEvent(LifecycleEventSpoolAssigned, map[string]interface{}{
+		"printer_name":      printerName,
+		"toolhead_id":       toolheadID,
+		"spool_id":          spoolID,
+		"previous_spool_id": previousSpoolID,
+	})
+}
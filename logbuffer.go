@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// LogEntry is a log record kept in the in-app log buffer
+type LogEntry struct {
+	ID      int64                  `json:"id"` // Increases with every entry, used to page with after_id
+	Time    time.Time              `json:"time"`
+	Level   slog.Level             `json:"level"`
+	Module  string                 `json:"module,omitempty"`
+	Message string                 `json:"message"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// LogFilter selects entries from the log buffer
+type LogFilter struct {
+	Level   slog.Level // Minimum level
+	Module  string     // Empty for all modules
+	AfterID int64      // Only entries newer than this ID
+	Limit   int        // Most recent entries to return, 0 for all
+}
+
+// matches reports whether an entry passes the filter
+func (f LogFilter) matches(entry LogEntry) bool {
+	return entry.Level >= f.Level && entry.ID > f.AfterID &&
+		(f.Module == "" || strings.EqualFold(entry.Module, f.Module))
+}
+
+// LogBuffer keeps the most recent log entries in memory and fans new ones out to subscribers
+type LogBuffer struct {
+	entries     []LogEntry // Ring buffer; next is the slot the next entry goes in
+	next        int
+	full        bool
+	lastID      int64
+	subscribers map[chan LogEntry]struct{}
+	mutex       sync.Mutex
+}
+
+// logBuffer holds the log entries shown in the web UI
+var logBuffer = NewLogBuffer(DefaultLogBufferSize)
+
+// NewLogBuffer creates a buffer holding up to size entries
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{
+		entries:     make([]LogEntry, size),
+		subscribers: make(map[chan LogEntry]struct{}),
+	}
+}
+
+// Add stores an entry, dropping the oldest one when the buffer is full
+func (l *LogBuffer) Add(entry LogEntry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastID++
+	entry.ID = l.lastID
+
+	if len(l.entries) > 0 {
+		l.entries[l.next] = entry
+		l.next = (l.next + 1) % len(l.entries)
+		if l.next == 0 {
+			l.full = true
+		}
+	}
+
+	for subscriber := range l.subscribers {
+		select {
+		case subscriber <- entry:
+		default: // Slow subscribers miss entries rather than block logging
+		}
+	}
+}
+
+// Resize changes how many entries are kept, keeping the most recent ones
+func (l *LogBuffer) Resize(size int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if size == len(l.entries) {
+		return
+	}
+	current := l.ordered()
+	if len(current) > size {
+		current = current[len(current)-size:]
+	}
+
+	l.entries = make([]LogEntry, size)
+	copy(l.entries, current)
+	l.next = 0
+	l.full = false
+	if size > 0 {
+		l.next = len(current) % size
+		l.full = len(current) == size
+	}
+}
+
+// Entries returns the buffered entries matching the filter, oldest first
+func (l *LogBuffer) Entries(filter LogFilter) []LogEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entries := []LogEntry{}
+	for _, entry := range l.ordered() {
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries
+}
+
+// Subscribe returns a channel receiving every new entry and a function that ends the subscription
+func (l *LogBuffer) Subscribe() (<-chan LogEntry, func()) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	subscriber := make(chan LogEntry, LogStreamBuffer)
+	l.subscribers[subscriber] = struct{}{}
+	return subscriber, func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		delete(l.subscribers, subscriber)
+	}
+}
+
+// ordered returns the buffered entries oldest first. The caller must hold the mutex.
+func (l *LogBuffer) ordered() []LogEntry {
+	if !l.full {
+		return append([]LogEntry(nil), l.entries[:l.next]...)
+	}
+	return append(append([]LogEntry(nil), l.entries[l.next:]...), l.entries[:l.next]...)
+}
+
+// logAttrValue converts an attribute value to something that encodes readably as JSON
+func logAttrValue(value slog.Value) interface{} {
+	value = value.Resolve()
+	switch value.Kind() {
+	case slog.KindDuration:
+		return value.Duration().String()
+	case slog.KindGroup:
+		group := make(map[string]interface{})
+		for _, attr := range value.Group() {
+			group[attr.Key] = logAttrValue(attr.Value)
+		}
+		return group
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return v.Error()
+		case fmt.Stringer:
+			return v.String()
+		}
+	}
+	return value.Any()
+}
+
+// parseLogFilter reads the level, module, after_id and limit query parameters
+func parseLogFilter(c *gin.Context) (LogFilter, error) {
+	filter := LogFilter{Level: slog.LevelDebug, Module: strings.TrimSpace(c.Query("module"))}
+
+	if value := c.Query("level"); value != "" {
+		level, err := parseLogLevel(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Level = level
+	}
+	if value := c.Query("after_id"); value != "" {
+		afterID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || afterID < 0 {
+			return filter, fmt.Errorf("invalid after_id")
+		}
+		filter.AfterID = afterID
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return filter, fmt.Errorf("invalid limit")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// getLogsHandler returns recent log entries, optionally filtered by level and module
+func (ws *WebServer) getLogsHandler(c *gin.Context) {
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, LogsResponse{Logs: logBuffer.Entries(filter)})
+}
+
+// logStreamHandler streams log entries over a websocket: first the buffered entries matching
+// the filter, then new entries as they are logged
+func (ws *WebServer) logStreamHandler(c *gin.Context) {
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: ws.websocketOriginAllowed}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		webLog.Warn("Log stream upgrade error", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Subscribe before reading the backlog so no entry falls between the two
+	entries, unsubscribe := logBuffer.Subscribe()
+	defer unsubscribe()
+
+	for _, entry := range logBuffer.Entries(filter) {
+		if err := conn.WriteJSON(entry); err != nil {
+			return
+		}
+		filter.AfterID = entry.ID
+	}
+	filter.Limit = 0
+
+	// Detect the client going away; incoming messages are ignored
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(LogStreamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case entry := <-entries:
+			if !filter.matches(entry) {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(entry); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Log output formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log modules; each can be given its own level with log_module_levels
+const (
+	LogModuleBridge        = "bridge"        // Mappings, configuration and migrations
+	LogModuleMonitor       = "monitor"       // Printer polling and print processing
+	LogModulePrusaLink     = "prusalink"     // PrusaLink and Prusa Connect clients
+	LogModuleSpoolman      = "spoolman"      // Spoolman client
+	LogModuleWeb           = "web"           // API handlers and websocket
+	LogModuleNFC           = "nfc"           // NFC tag flows
+	LogModuleNotifications = "notifications" // Notifications and reminders
+	LogModuleScheduler     = "scheduler"     // Scheduled background jobs
+	LogModuleMQTT          = "mqtt"          // Home Assistant MQTT publishing
+)
+
+// Loggers per module. Attributes use the names printer_id, printer, job, spool_id and
+// toolhead_id so one print or spool can be followed across modules.
+var (
+	bridgeLog        = newModuleLogger(LogModuleBridge)
+	monitorLog       = newModuleLogger(LogModuleMonitor)
+	prusaLinkLog     = newModuleLogger(LogModulePrusaLink)
+	spoolmanLog      = newModuleLogger(LogModuleSpoolman)
+	webLog           = newModuleLogger(LogModuleWeb)
+	nfcLog           = newModuleLogger(LogModuleNFC)
+	notificationsLog = newModuleLogger(LogModuleNotifications)
+	schedulerLog     = newModuleLogger(LogModuleScheduler)
+	mqttLog          = newModuleLogger(LogModuleMQTT)
+)
+
+// logSettings is the active logging configuration, swapped atomically on config reload
+type logSettings struct {
+	handler slog.Handler
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+var currentLogSettings atomic.Pointer[logSettings]
+
+func init() {
+	currentLogSettings.Store(&logSettings{
+		handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		level:   slog.LevelInfo,
+	})
+	// Route the standard library logger (and anything still using it) through slog
+	slog.SetDefault(slog.New(&moduleHandler{}))
+}
+
+// newModuleLogger returns a logger tagged with a module, filtered by that module's level
+func newModuleLogger(module string) *slog.Logger {
+	return slog.New(&moduleHandler{}).With("module", module)
+}
+
+// configureLogging applies the log level, format and per-module levels from the config.
+// Invalid values are reported and fall back to the defaults.
+func configureLogging(config *Config) {
+	format := strings.ToLower(strings.TrimSpace(config.LogFormat))
+	options := &slog.HandlerOptions{Level: slog.LevelDebug} // Filtering happens in moduleHandler
+	var handler slog.Handler
+	switch format {
+	case LogFormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	case "", LogFormatText:
+		handler = slog.NewTextHandler(os.Stderr, options)
+	default:
+		handler = slog.NewTextHandler(os.Stderr, options)
+		defer bridgeLog.Warn("Unknown log format, using text", "log_format", config.LogFormat)
+	}
+
+	level, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		defer bridgeLog.Warn("Invalid log level, using info", "error", err)
+	}
+	modules, err := parseLogModuleLevels(config.LogModuleLevels)
+	if err != nil {
+		defer bridgeLog.Warn("Ignoring invalid module log levels", "error", err)
+	}
+
+	currentLogSettings.Store(&logSettings{handler: handler, level: level, modules: modules})
+	logBuffer.Resize(config.LogBufferSize)
+}
+
+// parseLogLevel parses debug, info, warn or error; empty means info
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if strings.TrimSpace(value) == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q", value)
+	}
+	return level, nil
+}
+
+// parseLogModuleLevels parses a comma-separated list such as "prusalink=debug,spoolman=warn"
+func parseLogModuleLevels(value string) (map[string]slog.Level, error) {
+	modules := make(map[string]slog.Level)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		module, levelText, found := strings.Cut(entry, "=")
+		if !found {
+			return modules, fmt.Errorf("expected module=level, got %q", entry)
+		}
+		level, err := parseLogLevel(levelText)
+		if err != nil {
+			return modules, err
+		}
+		modules[strings.ToLower(strings.TrimSpace(module))] = level
+	}
+	return modules, nil
+}
+
+// moduleHandler filters records by their module's level and writes them to the handler
+// of the current settings, so loggers created at startup follow config reloads. Records
+// are also kept in the in-app log buffer.
+type moduleHandler struct {
+	module string
+	apply  []func(slog.Handler) slog.Handler // WithAttrs/WithGroup calls, replayed on the current handler
+	attrs  []slog.Attr                       // Attributes added with WithAttrs, keys prefixed with their groups
+	group  string                            // Current group prefix, e.g. "request."
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	settings := currentLogSettings.Load()
+	minimum := settings.level
+	if moduleLevel, exists := settings.modules[h.module]; exists {
+		minimum = moduleLevel
+	}
+	return level >= minimum
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := currentLogSettings.Load().handler
+	for _, apply := range h.apply {
+		handler = apply(handler)
+	}
+
+	entry := LogEntry{Time: record.Time, Level: record.Level, Module: h.module, Message: record.Message}
+	if len(h.attrs) > 0 || record.NumAttrs() > 0 {
+		entry.Attrs = make(map[string]interface{}, len(h.attrs)+record.NumAttrs())
+		for _, attr := range h.attrs {
+			entry.Attrs[attr.Key] = logAttrValue(attr.Value)
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			entry.Attrs[h.group+attr.Key] = logAttrValue(attr.Value)
+			return true
+		})
+	}
+	logBuffer.Add(entry)
+
+	return handler.Handle(ctx, record)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := &moduleHandler{
+		module: h.module,
+		apply: append(h.apply[:len(h.apply):len(h.apply)], func(handler slog.Handler) slog.Handler {
+			return handler.WithAttrs(attrs)
+		}),
+		attrs: h.attrs[:len(h.attrs):len(h.attrs)],
+		group: h.group,
+	}
+	for _, attr := range attrs {
+		if attr.Key == "module" && h.group == "" {
+			child.module = attr.Value.String()
+			continue
+		}
+		child.attrs = append(child.attrs, slog.Attr{Key: h.group + attr.Key, Value: attr.Value})
+	}
+	return child
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return &moduleHandler{
+		module: h.module,
+		apply: append(h.apply[:len(h.apply):len(h.apply)], func(handler slog.Handler) slog.Handler {
+			return handler.WithGroup(name)
+		}),
+		attrs: h.attrs,
+		group: h.group + name + ".",
+	}
+}
+
+// fatal logs an error and exits; used for startup failures
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
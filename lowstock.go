@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spoolLowStockField returns the Spoolman extra field key that holds per-spool low stock thresholds
+func (b *FilamentBridge) spoolLowStockField() string {
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil && snapshot.SpoolLowStockField != "" {
+		return snapshot.SpoolLowStockField
+	}
+	return DefaultSpoolLowStockField
+}
+
+// spoolLowStockThreshold returns the threshold set in a spool's extra field, if it has one
+func spoolLowStockThreshold(spool SpoolmanSpool, field string) (float64, bool) {
+	encoded, ok := spool.Extra[field].(string)
+	if !ok {
+		return 0, false
+	}
+	threshold, err := strconv.ParseFloat(strings.Trim(encoded, `"`), 64)
+	if err != nil || threshold < 0 {
+		return 0, false
+	}
+	return threshold, true
+}
+
+// lowStockThresholdOf returns the low stock threshold of a spool: its own if set, otherwise
+// its material's, otherwise the global one
+func lowStockThresholdOf(spool SpoolmanSpool, field string, defaults map[string]MaterialDefaults, global float64) float64 {
+	if threshold, ok := spoolLowStockThreshold(spool, field); ok {
+		return threshold
+	}
+	material := spool.Material
+	if spool.Filament != nil && spool.Filament.Material != "" {
+		material = spool.Filament.Material
+	}
+	return lowStockThresholdFor(material, defaults, global)
+}
+
+// SetSpoolLowStockThreshold stores a spool's own low stock threshold in the printer's
+// Spoolman instance, the main one when printerName is empty; nil clears it so the material's
+// or global threshold applies again. The extra field is defined in Spoolman on first use.
+func (b *FilamentBridge) SetSpoolLowStockThreshold(printerName string, spoolID int, threshold *float64) error {
+	field := b.spoolLowStockField()
+	if threshold == nil {
+		return b.updateSpoolExtra(printerName, spoolID, map[string]interface{}{field: nil})
+	}
+	if *threshold < 0 {
+		return newCodedError(ErrCodeInvalidRequest, "threshold can't be negative")
+	}
+
+	if err := b.ensureSpoolField(printerName, field, "Low stock threshold (g)", "float"); err != nil {
+		return err
+	}
+	return b.updateSpoolExtra(printerName, spoolID, map[string]interface{}{
+		field: strconv.FormatFloat(*threshold, 'f', -1, 64),
+	})
+}
+
+// recordLowStockAlert notes that a spool of a Spoolman instance was reported low, so the
+// sweep doesn't report it again until it's back above its threshold
+func (b *FilamentBridge) recordLowStockAlert(instance string, spoolID int, now time.Time) {
+	_, err := b.db.Exec(
+		"INSERT OR REPLACE INTO low_stock_alerts (spoolman_instance, spool_id, notified_at) VALUES (?, ?, ?)",
+		instance, spoolID, now,
+	)
+	if err != nil {
+		notificationsLog.Warn("Failed to record low stock alert", "spool_id", spoolID, "error", err)
+	}
+}
+
+// lowStockAlerted returns the spools of a Spoolman instance that were reported low
+func (b *FilamentBridge) lowStockAlerted(instance string) (map[int]bool, error) {
+	rows, err := b.db.Query("SELECT spool_id FROM low_stock_alerts WHERE spoolman_instance = ?", instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get low stock alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerted := make(map[int]bool)
+	for rows.Next() {
+		var spoolID int
+		if err := rows.Scan(&spoolID); err != nil {
+			return nil, fmt.Errorf("failed to scan low stock alert: %w", err)
+		}
+		alerted[spoolID] = true
+	}
+	return alerted, rows.Err()
+}
+
+// CheckLowStockSpools reports every spool, in each Spoolman instance, that is below its low
+// stock threshold and hasn't been reported since it last was above it. This catches spools
+// that got low outside FilaBridge or whose threshold was raised, which the check after each
+// usage update doesn't see. The first check of an instance only records the spools that are
+// already low, so adding an instance doesn't report its whole stock at once.
+func (b *FilamentBridge) CheckLowStockSpools(now time.Time) error {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return nil
+	}
+
+	materialDefaults, err := b.GetAllMaterialDefaults()
+	if err != nil {
+		notificationsLog.Warn("Failed to get material defaults for low stock check", "error", err)
+	}
+	field := b.spoolLowStockField()
+
+	var firstErr error
+	for _, instance := range b.spoolmanInstanceNames() {
+		if err := b.checkLowStockInstance(instance, snapshot, materialDefaults, field, now); err != nil {
+			notificationsLog.Warn("Low stock check failed", "spoolman_instance", instance, "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// checkLowStockInstance runs the low stock check for the spools of one Spoolman instance
+func (b *FilamentBridge) checkLowStockInstance(instance string, snapshot *Config, materialDefaults map[string]MaterialDefaults, field string, now time.Time) error {
+	spools, err := b.spoolmanInstance(instance).GetAllSpools()
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+	alerted, err := b.lowStockAlerted(instance)
+	if err != nil {
+		return err
+	}
+
+	// INSERT OR IGNORE only changes a row when the instance hasn't been checked before
+	result, err := b.db.Exec("INSERT OR IGNORE INTO low_stock_checks (spoolman_instance, first_checked_at) VALUES (?, ?)", instance, now)
+	if err != nil {
+		return fmt.Errorf("failed to record low stock check: %w", err)
+	}
+	seeding, _ := result.RowsAffected()
+
+	for _, spool := range spools {
+		threshold := lowStockThresholdOf(spool, field, materialDefaults, snapshot.LowStockThreshold)
+		low := !spool.Archived && spool.RemainingWeight < threshold
+
+		if !low {
+			// Refilled, threshold lowered or archived: report it again next time it's low
+			if alerted[spool.ID] {
+				if _, err := b.db.Exec("DELETE FROM low_stock_alerts WHERE spoolman_instance = ? AND spool_id = ?", instance, spool.ID); err != nil {
+					notificationsLog.Warn("Failed to clear low stock alert", "spool_id", spool.ID, "error", err)
+				}
+			}
+			continue
+		}
+		if alerted[spool.ID] {
+			continue
+		}
+
+		if seeding == 0 {
+			notificationsLog.Info("Spool below low stock threshold", "spool_id", spool.ID, "spoolman_instance", instance,
+				"remaining", spool.RemainingWeight, "threshold", threshold)
+			b.notifier.Notify(spoolLowStockNotification(spool, threshold))
+		}
+		b.recordLowStockAlert(instance, spool.ID, now)
+	}
+	if seeding > 0 {
+		notificationsLog.Info("First low stock check, recorded spools that are already low without notifying", "spoolman_instance", instance)
+	}
+	return nil
+}
+
+// spoolLowStockNotification is the notification that a spool is below its low stock threshold
+func spoolLowStockNotification(spool SpoolmanSpool, threshold float64) Notification {
+	name := fmt.Sprintf("Spool %d", spool.ID)
+	if spool.Filament != nil && spool.Filament.Name != "" {
+		name = fmt.Sprintf("Spool %d (%s)", spool.ID, spool.Filament.Name)
+	}
+	return Notification{
+		Event:   NotificationEventSpoolLow,
+		Title:   fmt.Sprintf("%s is running low", name),
+		Message: fmt.Sprintf("%s has %.1fg remaining, below the %.0fg low stock threshold", name, spool.RemainingWeight, threshold),
+	}
+}
+
+// setSpoolLowStockThresholdHandler sets or clears a spool's own low stock threshold
+func (ws *WebServer) setSpoolLowStockThresholdHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var req struct {
+		Threshold   *float64 `json:"threshold"`    // Grams, null to clear
+		PrinterName string   `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.SetSpoolLowStockThreshold(req.PrinterName, spoolID, req.Threshold); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spool low stock threshold updated successfully"})
+}
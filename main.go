@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"net/http"
 	"os/signal"
 	"syscall"
 	"time"
@@ -23,19 +25,19 @@ func main() {
 	// Create bridge instance first (with default config)
 	bridge, err := NewFilamentBridge(nil)
 	if err != nil {
-		log.Fatalf("Failed to create bridge: %v", err)
+		fatal("Failed to create bridge", "error", err)
 	}
 	defer bridge.Close()
 
 	// Load configuration from database
 	config, err := LoadConfig(bridge)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", "error", err)
 	}
 
 	// Update bridge with loaded config
 	if err := bridge.UpdateConfig(config); err != nil {
-		log.Fatalf("Failed to update bridge config: %v", err)
+		fatal("Failed to update bridge config", "error", err)
 	}
 
 
@@ -44,114 +46,133 @@ func main() {
 		*port = config.WebPort
 	}
 
-	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Handle graceful shutdown: SIGINT and SIGTERM cancel ctx, which stops monitoring and jobs
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// Start NFC session cleanup background task
-	go func() {
-		ticker := time.NewTicker(1 * time.Minute) // Clean up every minute
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				if err := bridge.cleanupExpiredSessions(); err != nil {
-					log.Printf("Error cleaning up NFC sessions: %v", err)
-				}
-			case <-sigChan:
-				return
-			}
-		}
-	}()
+	// Start background jobs (NFC session cleanup, notification digests, idle spool reminders)
+	go bridge.scheduler.Run(ctx)
 
 	if *webOnly {
 		// Run only web interface
-		fmt.Println("Starting web interface only...")
+		slog.Info("Starting web interface only")
 		webServer := NewWebServer(bridge)
-		go func() {
-			if err := webServer.Start(*port); err != nil {
-				log.Fatalf("Web server error: %v", err)
-			}
-		}()
+		go serveWeb(webServer, *port)
+
+		// Show changes made in Spoolman's own UI on the dashboard right away
+		go NewSpoolmanEventSubscriber(bridge, webServer.BroadcastStatus).Run(ctx)
 
 		// Wait for shutdown signal
-		<-sigChan
-		fmt.Println("Shutting down web server...")
+		<-ctx.Done()
+		slog.Info("Shutting down web server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		shutdownWebServer(shutdownCtx, webServer)
 
 	} else if *bridgeOnly {
 		// Run only bridge service
-		fmt.Println("Starting bridge service only...")
-		fmt.Printf("Monitoring printers: %v\n", getPrinterNames(config))
-		fmt.Printf("Spoolman URL: %s\n", config.SpoolmanURL)
-		fmt.Printf("Poll interval: %v\n", config.PollInterval)
-
-		// Start monitoring in a goroutine
-		go func() {
-			ticker := time.NewTicker(config.PollInterval)
-			defer ticker.Stop()
-
-			// Run initial check
-			bridge.MonitorPrinters()
-
-			// Continue monitoring
-			for {
-				select {
-				case <-ticker.C:
-					bridge.MonitorPrinters()
-				case <-sigChan:
-					return
-				}
-			}
-		}()
+		slog.Info("Starting bridge service only")
+		slog.Info("Bridge configuration", "printers", getPrinterNames(config), "spoolman_url", config.SpoolmanURL, "poll_interval", config.PollInterval.String())
+
+		// Start per-printer monitoring (adaptive polling plus push events where supported)
+		monitorDone := startMonitor(ctx, NewPrinterMonitor(bridge, nil))
+
+		// Check spool weights published by smart scales against Spoolman
+		go NewScaleListener(bridge, nil).Run(ctx)
+
+		// Record humidity and temperature published by location sensors
+		go NewEnvironmentListener(bridge).Run(ctx)
 
 		// Wait for shutdown signal
-		<-sigChan
-		fmt.Println("Shutting down bridge service...")
+		<-ctx.Done()
+		slog.Info("Shutting down bridge service")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		waitForMonitor(shutdownCtx, bridge, monitorDone)
 
 	} else {
 		// Run both bridge service and web interface
-		fmt.Println("Starting both bridge service and web interface...")
-		fmt.Printf("Monitoring printers: %v\n", getPrinterNames(config))
-		fmt.Printf("Spoolman URL: %s\n", config.SpoolmanURL)
-		fmt.Printf("Poll interval: %v\n", config.PollInterval)
-		fmt.Printf("Web interface: http://%s:%s\n", *host, *port)
+		slog.Info("Starting both bridge service and web interface")
+		slog.Info("Bridge configuration", "printers", getPrinterNames(config), "spoolman_url", config.SpoolmanURL, "poll_interval", config.PollInterval.String())
+		slog.Info("Web interface", "url", fmt.Sprintf("%s://%s:%s%s/", webScheme(config), *host, *port, config.BasePath))
 
 		// Create web server first so we can pass it to monitoring
 		webServer := NewWebServer(bridge)
 
-		// Start bridge monitoring in a goroutine
-		go func() {
-			ticker := time.NewTicker(config.PollInterval)
-			defer ticker.Stop()
-
-			// Run initial check
-			bridge.MonitorPrinters()
-			// Broadcast initial status
-			webServer.BroadcastStatus()
-
-			// Continue monitoring
-			for {
-				select {
-				case <-ticker.C:
-					bridge.MonitorPrinters()
-					// Broadcast status after each monitoring cycle
-					webServer.BroadcastStatus()
-				case <-sigChan:
-					return
-				}
-			}
-		}()
+		// Start per-printer monitoring (adaptive polling plus push events where supported),
+		// broadcasting status to dashboard clients after printer checks
+		monitor := NewPrinterMonitor(bridge, webServer.BroadcastStatus)
+		webServer.SetMonitor(monitor)
+		monitorDone := startMonitor(ctx, monitor)
+
+		// Show changes made in Spoolman's own UI on the dashboard right away
+		go NewSpoolmanEventSubscriber(bridge, webServer.BroadcastStatus).Run(ctx)
+
+		// Check spool weights published by smart scales against Spoolman
+		go NewScaleListener(bridge, webServer.BroadcastStatus).Run(ctx)
+
+		// Record humidity and temperature published by location sensors
+		go NewEnvironmentListener(bridge).Run(ctx)
 
 		// Start web server in a goroutine
-		go func() {
-			if err := webServer.Start(*port); err != nil {
-				log.Fatalf("Web server error: %v", err)
-			}
-		}()
+		go serveWeb(webServer, *port)
+
+		// Wait for shutdown signal, then give requests and print processing the same deadline
+		<-ctx.Done()
+		slog.Info("Shutting down services")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		shutdownWebServer(shutdownCtx, webServer)
+		waitForMonitor(shutdownCtx, bridge, monitorDone)
+	}
+}
 
-		// Wait for shutdown signal
-		<-sigChan
-		fmt.Println("Shutting down services...")
+// serveWeb runs the web server until it's shut down
+func serveWeb(webServer *WebServer, port string) {
+	if err := webServer.Start(port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("Web server error", "error", err)
+	}
+}
+
+// shutdownWebServer lets requests in progress finish until shutdownCtx expires
+func shutdownWebServer(shutdownCtx context.Context, webServer *WebServer) {
+	if err := webServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Web server did not shut down cleanly", "error", err)
+	}
+}
+
+// startMonitor runs the printer monitor until ctx is cancelled; the returned channel is
+// closed once it has stopped
+func startMonitor(ctx context.Context, monitor *PrinterMonitor) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		monitor.Run(ctx)
+		close(done)
+	}()
+	return done
+}
+
+// waitForMonitor waits for prints being processed until shutdownCtx expires, then saves the
+// jobs still printing so they're recorded after the restart
+func waitForMonitor(shutdownCtx context.Context, bridge *FilamentBridge, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		monitorLog.Warn("Timed out waiting for print processing to finish", "timeout", ShutdownTimeout.String())
+	}
+	bridge.SaveTrackedPrints()
+}
+
+// waitMonitorStartDelay sleeps for the configured delay before the first monitoring cycle,
+// or until ctx is cancelled
+func waitMonitorStartDelay(ctx context.Context, config *Config) {
+	if config.MonitorStartDelay <= 0 {
+		return
+	}
+	monitorLog.Info("Delaying first monitoring cycle", "delay", config.MonitorStartDelay.String())
+	select {
+	case <-ctx.Done():
+	case <-time.After(config.MonitorStartDelay):
 	}
 }
 
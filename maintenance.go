@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceWindow is a recurring weekly window (server local time) during which a
+// printer's offline alerts and error notifications are suppressed
+type MaintenanceWindow struct {
+	Days  []string `json:"days,omitempty"` // Weekdays such as "mon" or "saturday"; empty means every day
+	Start string   `json:"start"`          // "HH:MM"
+	End   string   `json:"end"`            // "HH:MM", may be earlier than Start to span midnight
+	Note  string   `json:"note,omitempty"` // e.g. "Weekly cleaning"
+}
+
+// weekdayNames maps accepted day names to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// validate checks the window's times and day names
+func (w MaintenanceWindow) validate() error {
+	start, err := parseClockTime(w.Start)
+	if err != nil {
+		return newCodedError(ErrCodeInvalidRequest, "invalid maintenance window start: %v", err)
+	}
+	end, err := parseClockTime(w.End)
+	if err != nil {
+		return newCodedError(ErrCodeInvalidRequest, "invalid maintenance window end: %v", err)
+	}
+	if start == end {
+		return newCodedError(ErrCodeInvalidRequest, "maintenance window start and end must differ")
+	}
+	for _, day := range w.Days {
+		if _, known := weekdayNames[strings.ToLower(strings.TrimSpace(day))]; !known {
+			return newCodedError(ErrCodeInvalidRequest, "unknown maintenance window day %q", day)
+		}
+	}
+	return nil
+}
+
+// onDay reports whether the window recurs on a weekday
+func (w MaintenanceWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekday, known := weekdayNames[strings.ToLower(strings.TrimSpace(name))]; known && weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// active reports whether the window contains the given time. A window spanning midnight
+// belongs to the day it starts on.
+func (w MaintenanceWindow) active(now time.Time) bool {
+	start, err := parseClockTime(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClockTime(w.End)
+	if err != nil || start == end {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return w.onDay(now.Weekday()) && minute >= start && minute < end
+	}
+	if minute >= start {
+		return w.onDay(now.Weekday())
+	}
+	return minute < end && w.onDay((now.Weekday()+6)%7)
+}
+
+// GetMaintenanceWindows returns a printer's maintenance windows
+func (b *FilamentBridge) GetMaintenanceWindows(printerID string) ([]MaintenanceWindow, error) {
+	rows, err := b.db.Query(
+		"SELECT COALESCE(days, ''), start_time, end_time, COALESCE(note, '') FROM maintenance_windows WHERE printer_id = ? ORDER BY id",
+		printerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var window MaintenanceWindow
+		var days string
+		if err := rows.Scan(&days, &window.Start, &window.End, &window.Note); err != nil {
+			return nil, fmt.Errorf("failed to scan maintenance window: %w", err)
+		}
+		if days != "" {
+			window.Days = strings.Split(days, ",")
+		}
+		windows = append(windows, window)
+	}
+	return windows, rows.Err()
+}
+
+// SetMaintenanceWindows replaces a printer's maintenance windows
+func (b *FilamentBridge) SetMaintenanceWindows(printerID string, windows []MaintenanceWindow) error {
+	for _, window := range windows {
+		if err := window.validate(); err != nil {
+			return err
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin maintenance window update: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM maintenance_windows WHERE printer_id = ?", printerID); err != nil {
+		return fmt.Errorf("failed to clear maintenance windows: %w", err)
+	}
+	for _, window := range windows {
+		days := make([]string, 0, len(window.Days))
+		for _, day := range window.Days {
+			days = append(days, strings.ToLower(strings.TrimSpace(day)))
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO maintenance_windows (printer_id, days, start_time, end_time, note) VALUES (?, ?, ?, ?, ?)",
+			printerID, strings.Join(days, ","), strings.TrimSpace(window.Start), strings.TrimSpace(window.End), strings.TrimSpace(window.Note),
+		); err != nil {
+			return fmt.Errorf("failed to save maintenance window: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit maintenance windows: %w", err)
+	}
+	return nil
+}
+
+// inMaintenance reports whether a printer is inside one of its maintenance windows
+func (b *FilamentBridge) inMaintenance(printerID string, now time.Time) bool {
+	windows, err := b.GetMaintenanceWindows(printerID)
+	if err != nil {
+		bridgeLog.Warn("Failed to check maintenance windows", "printer_id", printerID, "error", err)
+		return false
+	}
+	for _, window := range windows {
+		if window.active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// printerNameInMaintenance is inMaintenance for callers that only know the printer's name
+func (b *FilamentBridge) printerNameInMaintenance(printerName string, now time.Time) bool {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return false
+	}
+	for printerID, config := range snapshot.Printers {
+		if resolvePrinterName(config) == printerName {
+			return b.inMaintenance(printerID, now)
+		}
+	}
+	return false
+}
+
+// getMaintenanceWindowsHandler returns a printer's maintenance windows
+func (ws *WebServer) getMaintenanceWindowsHandler(c *gin.Context) {
+	printerID := c.Param("id")
+	if _, exists := ws.bridge.GetConfigSnapshot().Printers[printerID]; !exists {
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
+		return
+	}
+
+	windows, err := ws.bridge.GetMaintenanceWindows(printerID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MaintenanceWindowsResponse{
+		Windows:       windows,
+		InMaintenance: ws.bridge.inMaintenance(printerID, time.Now()),
+	})
+}
+
+// updateMaintenanceWindowsHandler replaces a printer's maintenance windows
+func (ws *WebServer) updateMaintenanceWindowsHandler(c *gin.Context) {
+	printerID := c.Param("id")
+	if _, exists := ws.bridge.GetConfigSnapshot().Printers[printerID]; !exists {
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
+		return
+	}
+
+	var req struct {
+		Windows []MaintenanceWindow `json:"windows"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.SetMaintenanceWindows(printerID, req.Windows); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Maintenance windows updated successfully"})
+}
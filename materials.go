@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaterialDefaults holds per-material settings keyed by the Spoolman material string
+// (e.g. "PLA", "PETG"). They apply to every spool of that material; nil fields are unset.
+type MaterialDefaults struct {
+	Material              string    `json:"material"`
+	ExposureLimitHours    *float64  `json:"exposure_limit_hours,omitempty"`    // Hours out of dry storage before drying is advised
+	DryingTemp            *float64  `json:"drying_temp,omitempty"`             // Drying temperature in °C
+	DryingTimeHours       *float64  `json:"drying_time_hours,omitempty"`       // Drying duration in hours
+	UsageCorrectionFactor *float64  `json:"usage_correction_factor,omitempty"` // Multiplier applied to slicer-reported usage
+	LowStockThreshold     *float64  `json:"low_stock_threshold,omitempty"`     // Overrides the global low stock threshold (grams)
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// materialKey normalizes a material string for matching
+func materialKey(material string) string {
+	return strings.ToUpper(strings.TrimSpace(material))
+}
+
+// nullFloat converts an optional value for storage
+func nullFloat(value *float64) sql.NullFloat64 {
+	if value == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *value, Valid: true}
+}
+
+// floatPtr converts a stored optional value back
+func floatPtr(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	v := value.Float64
+	return &v
+}
+
+// GetAllMaterialDefaults returns all material defaults keyed by normalized material
+func (b *FilamentBridge) GetAllMaterialDefaults() (map[string]MaterialDefaults, error) {
+	rows, err := b.db.Query(`SELECT material, exposure_limit_hours, drying_temp, drying_time_hours,
+		usage_correction_factor, low_stock_threshold, updated_at FROM material_defaults ORDER BY material`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get material defaults: %w", err)
+	}
+	defer rows.Close()
+
+	defaults := make(map[string]MaterialDefaults)
+	for rows.Next() {
+		var md MaterialDefaults
+		var exposure, dryingTemp, dryingTime, factor, threshold sql.NullFloat64
+		if err := rows.Scan(&md.Material, &exposure, &dryingTemp, &dryingTime, &factor, &threshold, &md.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan material defaults: %w", err)
+		}
+		md.ExposureLimitHours = floatPtr(exposure)
+		md.DryingTemp = floatPtr(dryingTemp)
+		md.DryingTimeHours = floatPtr(dryingTime)
+		md.UsageCorrectionFactor = floatPtr(factor)
+		md.LowStockThreshold = floatPtr(threshold)
+		defaults[materialKey(md.Material)] = md
+	}
+	return defaults, rows.Err()
+}
+
+// GetMaterialDefaults returns the defaults for a material, or nil if none are configured
+func (b *FilamentBridge) GetMaterialDefaults(material string) (*MaterialDefaults, error) {
+	defaults, err := b.GetAllMaterialDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if md, exists := defaults[materialKey(material)]; exists {
+		return &md, nil
+	}
+	return nil, nil
+}
+
+// SetMaterialDefaults creates or replaces the defaults for a material
+func (b *FilamentBridge) SetMaterialDefaults(md MaterialDefaults) error {
+	md.Material = strings.TrimSpace(md.Material)
+	if md.Material == "" {
+		return newCodedError(ErrCodeInvalidRequest, "material is required")
+	}
+	if md.UsageCorrectionFactor != nil && *md.UsageCorrectionFactor <= 0 {
+		return newCodedError(ErrCodeInvalidRequest, "usage_correction_factor must be positive")
+	}
+	for name, value := range map[string]*float64{
+		"exposure_limit_hours": md.ExposureLimitHours,
+		"drying_temp":          md.DryingTemp,
+		"drying_time_hours":    md.DryingTimeHours,
+		"low_stock_threshold":  md.LowStockThreshold,
+	} {
+		if value != nil && *value < 0 {
+			return newCodedError(ErrCodeInvalidRequest, "%s must not be negative", name)
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(
+		`INSERT OR REPLACE INTO material_defaults (material, exposure_limit_hours, drying_temp, drying_time_hours,
+			usage_correction_factor, low_stock_threshold, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		materialKey(md.Material), nullFloat(md.ExposureLimitHours), nullFloat(md.DryingTemp), nullFloat(md.DryingTimeHours),
+		nullFloat(md.UsageCorrectionFactor), nullFloat(md.LowStockThreshold), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save material defaults: %w", err)
+	}
+	return nil
+}
+
+// DeleteMaterialDefaults removes the defaults for a material
+func (b *FilamentBridge) DeleteMaterialDefaults(material string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec("DELETE FROM material_defaults WHERE material = ?", materialKey(material))
+	if err != nil {
+		return fmt.Errorf("failed to delete material defaults: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return newCodedError(ErrCodeNotFound, "no defaults configured for material %s", material)
+	}
+	return nil
+}
+
+// usageCorrectionFactor returns the usage multiplier for the material of a spool on a
+// printer (1 if unset)
+func (b *FilamentBridge) usageCorrectionFactor(printerName string, spoolID int) float64 {
+	spool, err := b.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil || spool.Filament == nil {
+		return 1
+	}
+
+	md, err := b.GetMaterialDefaults(spool.Filament.Material)
+	if err != nil {
+		bridgeLog.Warn("Failed to get material defaults", "spool_id", spoolID, "error", err)
+		return 1
+	}
+	if md == nil || md.UsageCorrectionFactor == nil {
+		return 1
+	}
+	return *md.UsageCorrectionFactor
+}
+
+// lowStockThresholdFor returns the low stock threshold for a material, falling back to the global one
+func lowStockThresholdFor(material string, defaults map[string]MaterialDefaults, global float64) float64 {
+	if md, exists := defaults[materialKey(material)]; exists && md.LowStockThreshold != nil {
+		return *md.LowStockThreshold
+	}
+	return global
+}
+
+// getMaterialDefaultsHandler returns all per-material defaults
+func (ws *WebServer) getMaterialDefaultsHandler(c *gin.Context) {
+	defaults, err := ws.bridge.GetAllMaterialDefaults()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	list := make([]MaterialDefaults, 0, len(defaults))
+	for _, md := range defaults {
+		list = append(list, md)
+	}
+	c.JSON(http.StatusOK, MaterialDefaultsListResponse{Materials: list})
+}
+
+// updateMaterialDefaultsHandler creates or replaces the defaults for the material in the URL
+func (ws *WebServer) updateMaterialDefaultsHandler(c *gin.Context) {
+	var md MaterialDefaults
+	if err := c.ShouldBindJSON(&md); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	md.Material = c.Param("material")
+
+	if err := ws.bridge.SetMaterialDefaults(md); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	saved, err := ws.bridge.GetMaterialDefaults(md.Material)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MaterialDefaultsSavedResponse{Message: "Material defaults saved successfully", Defaults: saved})
+}
+
+// deleteMaterialDefaultsHandler removes the defaults for a material
+func (ws *WebServer) deleteMaterialDefaultsHandler(c *gin.Context) {
+	if err := ws.bridge.DeleteMaterialDefaults(c.Param("material")); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Material defaults deleted successfully"})
+}
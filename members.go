@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API roles
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Gin context keys set by the access control middleware
+const (
+	contextKeyRole   = "role"
+	contextKeyMember = "member"
+)
+
+// memberRoutes lists the mutating API routes members may call. Everything else that
+// changes state (inventory, configuration, printers) requires the admin role.
+var memberRoutes = map[string]bool{
+	"POST /api/map_toolhead":                 true,
+	"POST /api/map_toolheads":                true,
+	"PUT /api/printer-groups/:name/mappings": true,
+	"POST /api/printers/:id/actions/:action": true, // Stopping a print still takes an admin
+	"PUT /api/history/:id":                   true,
+	"POST /api/usage":                        true,
+	"POST /api/reservations":                 true,
+	"POST /api/analyze":                      true,
+}
+
+// Member is a makerspace member allowed to map spools and annotate their own prints
+type Member struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// generateAccessToken returns a random token for API authentication
+func generateAccessToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashAccessToken hashes a token for storage; plain tokens are never stored
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateMember adds a member and returns it with its token, which is only shown once
+func (b *FilamentBridge) CreateMember(name string) (*Member, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", newCodedError(ErrCodeInvalidRequest, "member name is required")
+	}
+
+	token, err := generateAccessToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var existing int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM members WHERE name = ?", name).Scan(&existing); err != nil {
+		return nil, "", fmt.Errorf("failed to check existing members: %w", err)
+	}
+	if existing > 0 {
+		return nil, "", newCodedError(ErrCodeConflict, "member %s already exists", name)
+	}
+
+	createdAt := time.Now()
+	result, err := b.db.Exec(
+		"INSERT INTO members (name, token_hash, created_at) VALUES (?, ?, ?)",
+		name, hashAccessToken(token), createdAt,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create member: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get member ID: %w", err)
+	}
+
+	return &Member{ID: int(id), Name: name, CreatedAt: createdAt}, token, nil
+}
+
+// GetMembers returns all members
+func (b *FilamentBridge) GetMembers() ([]Member, error) {
+	rows, err := b.db.Query("SELECT id, name, created_at FROM members ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(&member.ID, &member.Name, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// DeleteMember removes a member, revoking its token
+func (b *FilamentBridge) DeleteMember(id int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec("DELETE FROM members WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete member: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return newCodedError(ErrCodeNotFound, "member %d not found", id)
+	}
+	return nil
+}
+
+// AuthenticateMember returns the member owning a token, or nil if the token is unknown
+func (b *FilamentBridge) AuthenticateMember(token string) (*Member, error) {
+	var member Member
+	err := b.db.QueryRow(
+		"SELECT id, name, created_at FROM members WHERE token_hash = ?",
+		hashAccessToken(token),
+	).Scan(&member.ID, &member.Name, &member.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate member: %w", err)
+	}
+	return &member, nil
+}
+
+// RotateAdminToken generates a new admin token, enabling access control if it was off
+func (b *FilamentBridge) RotateAdminToken() (string, error) {
+	token, err := generateAccessToken()
+	if err != nil {
+		return "", err
+	}
+	if err := b.SetConfigValue(ConfigKeyAdminTokenHash, hashAccessToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// setToolheadMappedBy records which member mapped a toolhead's spool
+func (b *FilamentBridge) setToolheadMappedBy(printerName string, toolheadID int, memberName string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(
+		"UPDATE toolhead_mappings SET mapped_by = ? WHERE printer_name = ? AND toolhead_id = ?",
+		memberName, printerName, toolheadID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record mapping member: %w", err)
+	}
+	return nil
+}
+
+// requestToken extracts an API token from the Authorization or X-Api-Token header
+func requestToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return strings.TrimSpace(c.GetHeader("X-Api-Token"))
+}
+
+// callerMember returns the authenticated member, or nil for admins
+func callerMember(c *gin.Context) *Member {
+	if value, exists := c.Get(contextKeyMember); exists {
+		if member, ok := value.(*Member); ok {
+			return member
+		}
+	}
+	return nil
+}
+
+// getMembersHandler returns all members
+func (ws *WebServer) getMembersHandler(c *gin.Context) {
+	members, err := ws.bridge.GetMembers()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MembersResponse{Members: members})
+}
+
+// createMemberHandler adds a member and returns its token
+func (ws *WebServer) createMemberHandler(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON or missing 'name' field")
+		return
+	}
+
+	member, token, err := ws.bridge.CreateMember(req.Name)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, MemberCreatedResponse{Member: member, Token: token})
+}
+
+// deleteMemberHandler removes a member
+func (ws *WebServer) deleteMemberHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid member ID")
+		return
+	}
+
+	if err := ws.bridge.DeleteMember(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Member deleted successfully"})
+}
+
+// rotateAdminTokenHandler issues a new admin token
+func (ws *WebServer) rotateAdminTokenHandler(c *gin.Context) {
+	token, err := ws.bridge.RotateAdminToken()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, AdminTokenResponse{
+		Message: "Admin token updated; mutating API requests now require a token",
+		Token:   token,
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// markPrinterOnline records that a printer responded to a status request
+func (b *FilamentBridge) markPrinterOnline(printerID string) {
+	b.mutex.Lock()
+	delete(b.offlineSince, printerID)
+	wasNotified := b.offlineNotified[printerID]
+	delete(b.offlineNotified, printerID)
+	b.mutex.Unlock()
+
+	if wasNotified {
+		name := b.printerDisplayName(printerID)
+		b.notifier.Notify(Notification{
+			Event:   NotificationEventPrinterOffline,
+			Title:   fmt.Sprintf("Printer %s is back online", name),
+			Message: fmt.Sprintf("%s is responding again", name),
+		})
+	}
+}
+
+// markPrinterOffline records that a printer failed to respond, keeping the first failure time.
+// A notification is sent once the printer has been unreachable for PrinterOfflineNotifyDelay.
+func (b *FilamentBridge) markPrinterOffline(printerID string) {
+	// Offline alerts wait out a maintenance window, so a printer still offline once the
+	// window ends is reported then
+	inMaintenance := b.inMaintenance(printerID, time.Now())
+
+	b.mutex.Lock()
+	since, exists := b.offlineSince[printerID]
+	if !exists {
+		since = time.Now()
+		b.offlineSince[printerID] = since
+	}
+	notify := !b.offlineNotified[printerID] && !inMaintenance && time.Since(since) >= PrinterOfflineNotifyDelay
+	if notify {
+		b.offlineNotified[printerID] = true
+	}
+	b.mutex.Unlock()
+
+	if notify {
+		name := b.printerDisplayName(printerID)
+		b.notifier.Notify(Notification{
+			Event:    NotificationEventPrinterOffline,
+			Title:    fmt.Sprintf("Printer %s is offline", name),
+			Message:  fmt.Sprintf("%s hasn't responded since %s", name, since.Format("15:04")),
+			Critical: true,
+		})
+	}
+}
+
+// GetPrinterOfflineDurations returns how long each currently offline printer has been unreachable
+func (b *FilamentBridge) GetPrinterOfflineDurations() map[string]time.Duration {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	durations := make(map[string]time.Duration, len(b.offlineSince))
+	for printerID, since := range b.offlineSince {
+		durations[printerID] = time.Since(since)
+	}
+	return durations
+}
+
+// metricsWriter builds a Prometheus text exposition payload
+type metricsWriter struct {
+	builder strings.Builder
+}
+
+// header writes the HELP and TYPE lines for a metric family
+func (m *metricsWriter) header(name, help, metricType string) {
+	fmt.Fprintf(&m.builder, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes a single sample with optional labels given as key/value pairs
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.builder.WriteString(name)
+	if len(labels) > 0 {
+		m.builder.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.builder.WriteString(",")
+			}
+			fmt.Fprintf(&m.builder, "%s=\"%s\"", labels[i], escapeMetricLabel(labels[i+1]))
+		}
+		m.builder.WriteString("}")
+	}
+	fmt.Fprintf(&m.builder, " %g\n", value)
+}
+
+// escapeMetricLabel escapes a label value per the Prometheus text format
+func escapeMetricLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+// metricsHandler exposes alert-friendly Prometheus metrics for low stock and offline printers
+func (ws *WebServer) metricsHandler(c *gin.Context) {
+	m := &metricsWriter{}
+	configSnapshot := ws.bridge.GetConfigSnapshot()
+
+	threshold := float64(DefaultLowStockThreshold)
+	if configSnapshot != nil {
+		threshold = configSnapshot.LowStockThreshold
+	}
+
+	// Spool stock series - labels are limited to spool_id so series stay stable across renames.
+	// Spools of additional Spoolman instances also carry spoolman_instance, since IDs repeat.
+	instances := ws.bridge.spoolmanInstanceNames()
+	spoolsByInstance := make(map[string][]SpoolmanSpool, len(instances))
+	spoolmanUp := make(map[string]float64, len(instances))
+	for _, instance := range instances {
+		spools, err := ws.bridge.spoolmanInstance(instance).GetAllSpools()
+		if err != nil {
+			webLog.Warn("Failed to get spools for metrics", "spoolman_instance", instance, "error", err)
+			continue
+		}
+		spoolsByInstance[instance] = spools
+		spoolmanUp[instance] = 1
+	}
+	instanceLabels := func(instance string, labels ...string) []string {
+		if instance != "" {
+			labels = append(labels, "spoolman_instance", instance)
+		}
+		return labels
+	}
+
+	m.header("filabridge_spoolman_up", "Whether the last Spoolman request for metrics succeeded.", "gauge")
+	for _, instance := range instances {
+		m.sample("filabridge_spoolman_up", spoolmanUp[instance], instanceLabels(instance)...)
+	}
+
+	m.header("filabridge_low_stock_threshold_grams", "Remaining weight below which a spool is considered low stock.", "gauge")
+	m.sample("filabridge_low_stock_threshold_grams", threshold)
+
+	m.header("filabridge_spool_remaining_weight_grams", "Remaining filament weight per spool.", "gauge")
+	for _, instance := range instances {
+		for _, spool := range spoolsByInstance[instance] {
+			m.sample("filabridge_spool_remaining_weight_grams", spool.RemainingWeight, instanceLabels(instance, "spool_id", fmt.Sprintf("%d", spool.ID))...)
+		}
+	}
+
+	// Spools and materials can override the global threshold
+	lowStockField := ws.bridge.spoolLowStockField()
+	materialDefaults, err := ws.bridge.GetAllMaterialDefaults()
+	if err != nil {
+		webLog.Warn("Failed to get material defaults for metrics", "error", err)
+	}
+
+	m.header("filabridge_spool_below_threshold", "1 if the spool's remaining weight is below its low stock threshold.", "gauge")
+	for _, instance := range instances {
+		for _, spool := range spoolsByInstance[instance] {
+			below := 0.0
+			if spool.RemainingWeight < lowStockThresholdOf(spool, lowStockField, materialDefaults, threshold) {
+				below = 1
+			}
+			m.sample("filabridge_spool_below_threshold", below, instanceLabels(instance, "spool_id", fmt.Sprintf("%d", spool.ID))...)
+		}
+	}
+
+	// Printer availability series
+	offline := ws.bridge.GetPrinterOfflineDurations()
+	var printerIDs []string
+	if configSnapshot != nil {
+		for printerID := range configSnapshot.Printers {
+			if printerID != "no_printers" {
+				printerIDs = append(printerIDs, printerID)
+			}
+		}
+	}
+	sort.Strings(printerIDs)
+
+	m.header("filabridge_printer_up", "1 if the printer responded to the last status request.", "gauge")
+	for _, printerID := range printerIDs {
+		up := 1.0
+		if _, isOffline := offline[printerID]; isOffline {
+			up = 0
+		}
+		m.sample("filabridge_printer_up", up, "printer_id", printerID)
+	}
+
+	m.header("filabridge_printer_offline_duration_seconds", "Seconds since the printer stopped responding (0 while online).", "gauge")
+	for _, printerID := range printerIDs {
+		m.sample("filabridge_printer_offline_duration_seconds", offline[printerID].Seconds(), "printer_id", printerID)
+	}
+
+	clockDrift := ws.bridge.GetPrinterClockDrift()
+	m.header("filabridge_printer_clock_drift_seconds", "Printer clock minus server time at the last poll.", "gauge")
+	for _, printerID := range printerIDs {
+		if drift, measured := clockDrift[printerID]; measured {
+			m.sample("filabridge_printer_clock_drift_seconds", drift.Seconds(), "printer_id", printerID)
+		}
+	}
+
+	// Finished print processing
+	queue := ws.bridge.printQueue.Stats()
+	m.header("filabridge_print_queue_workers", "Workers processing finished prints.", "gauge")
+	m.sample("filabridge_print_queue_workers", float64(queue.Workers))
+
+	m.header("filabridge_print_queue_active", "Finished prints being processed.", "gauge")
+	m.sample("filabridge_print_queue_active", float64(queue.Active))
+
+	m.header("filabridge_print_queue_depth", "Finished prints waiting to be processed.", "gauge")
+	m.sample("filabridge_print_queue_depth", float64(queue.Depth))
+
+	m.header("filabridge_print_queue_printer_depth", "Finished prints waiting to be processed per printer.", "gauge")
+	for _, printerID := range printerIDs {
+		m.sample("filabridge_print_queue_printer_depth", float64(queue.PrinterDepth[printerID]), "printer_id", printerID)
+	}
+
+	m.header("filabridge_print_queue_oldest_wait_seconds", "How long the oldest waiting finished print has been queued.", "gauge")
+	m.sample("filabridge_print_queue_oldest_wait_seconds", queue.OldestWaiting.Seconds())
+
+	m.header("filabridge_prints_processed_total", "Finished prints processed since start.", "counter")
+	m.sample("filabridge_prints_processed_total", float64(queue.Processed))
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(m.builder.String()))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filament mismatch check modes, the same as the low filament check modes
+const (
+	FilamentMismatchCheckOff   = LowFilamentCheckOff
+	FilamentMismatchCheckWarn  = LowFilamentCheckWarn
+	FilamentMismatchCheckPause = LowFilamentCheckPause
+)
+
+// mismatchColorDistance is how far apart, in RGB space (0 to √3), the sliced and loaded
+// colors have to be before they're reported. Shades of the same color stay well below it.
+const mismatchColorDistance = 0.6
+
+// Keys can follow binary block headers in bgcode, so they're matched after any non-word
+// character rather than only at the start of a line
+var (
+	// "; filament_type = PLA;PETG" in ASCII, "filament_type=PLA;PETG" in bgcode metadata
+	filamentTypeRegex = regexp.MustCompile(`(?:^|\W)filament_type\s*=\s*([^\r\n]*)`)
+	// "; extruder_colour = "#FF8000";""", empty when the extruder takes the filament's color
+	extruderColourRegex = regexp.MustCompile(`(?:^|\W)extruder_colour\s*=\s*([^\r\n]*)`)
+	// "; filament_colour = #FF8000;#FFFFFF"
+	filamentColourRegex = regexp.MustCompile(`(?:^|\W)filament_colour\s*=\s*([^\r\n]*)`)
+	// "; nozzle_diameter = 0.4,0.6"
+	nozzleDiameterRegex = regexp.MustCompile(`(?:^|\W)nozzle_diameter\s*=\s*([^\r\n]*)`)
+)
+
+// GcodeFilaments is the filament and nozzle a job was sliced for, by toolhead. Toolheads
+// without a value are missing from the maps.
+type GcodeFilaments struct {
+	Types           map[int]string
+	Colors          map[int]string  // "#RRGGBB"
+	NozzleDiameters map[int]float64 // mm
+}
+
+// ParseGcodeFilaments extracts the sliced filament types, colors and nozzle sizes from
+// PrusaSlicer-style metadata. Cura files don't carry them, so nothing is found there.
+func ParseGcodeFilaments(content []byte) GcodeFilaments {
+	filaments := GcodeFilaments{Types: make(map[int]string), Colors: make(map[int]string), NozzleDiameters: make(map[int]float64)}
+
+	if match := filamentTypeRegex.FindSubmatch(content); match != nil {
+		for toolheadID, value := range parseGcodeList(string(match[1])) {
+			filaments.Types[toolheadID] = value
+		}
+	}
+
+	// An extruder color overrides the filament's, so filament colors only fill the gaps
+	for _, regex := range []*regexp.Regexp{extruderColourRegex, filamentColourRegex} {
+		match := regex.FindSubmatch(content)
+		if match == nil {
+			continue
+		}
+		for toolheadID, value := range parseGcodeList(string(match[1])) {
+			if _, exists := filaments.Colors[toolheadID]; !exists {
+				if _, _, _, ok := parseColorHex(value); ok {
+					filaments.Colors[toolheadID] = value
+				}
+			}
+		}
+	}
+
+	// Nozzle sizes are comma-separated, one per extruder
+	if match := nozzleDiameterRegex.FindSubmatch(content); match != nil {
+		for toolheadID, value := range strings.Split(strings.TrimSpace(string(match[1])), ",") {
+			if diameter, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && diameter > 0 {
+				filaments.NozzleDiameters[toolheadID] = diameter
+			}
+		}
+	}
+	return filaments
+}
+
+// parseGcodeList splits a semicolon-separated slicer setting into per-toolhead values,
+// dropping quotes and empty entries
+func parseGcodeList(list string) map[int]string {
+	values := make(map[int]string)
+	for i, value := range strings.Split(strings.TrimSpace(list), ";") {
+		if value = strings.Trim(strings.TrimSpace(value), `"`); value != "" {
+			values[i] = value
+		}
+	}
+	return values
+}
+
+// normalizeMaterial reduces a material name for comparison, so "PET-G" and "petg" match
+func normalizeMaterial(material string) string {
+	return strings.NewReplacer("-", "", " ", "", "_", "").Replace(strings.ToUpper(strings.TrimSpace(material)))
+}
+
+// colorDistance returns how far apart two hex colors are in RGB space
+func colorDistance(a, b string) (float64, bool) {
+	ar, ag, ab, okA := parseColorHex(a)
+	br, bg, bb, okB := parseColorHex(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return math.Sqrt((ar-br)*(ar-br) + (ag-bg)*(ag-bg) + (ab-bb)*(ab-bb)), true
+}
+
+// filamentMismatches lists how the spool loaded on a toolhead differs from the filament the
+// job was sliced for. Values missing on either side aren't compared.
+func filamentMismatches(toolheadID int, sliced GcodeFilaments, spool SpoolmanSpool) []string {
+	if spool.Filament == nil {
+		return nil
+	}
+
+	var problems []string
+	if slicedType, exists := sliced.Types[toolheadID]; exists && spool.Filament.Material != "" &&
+		normalizeMaterial(slicedType) != normalizeMaterial(spool.Filament.Material) {
+		problems = append(problems, fmt.Sprintf("toolhead %d was sliced for %s but spool %d is %s",
+			toolheadID, slicedType, spool.ID, spool.Filament.Material))
+	}
+
+	if slicedColor, exists := sliced.Colors[toolheadID]; exists {
+		if distance, ok := colorDistance(slicedColor, spool.Filament.ColorHex); ok && distance > mismatchColorDistance {
+			problems = append(problems, fmt.Sprintf("toolhead %d was sliced for color %s but spool %d is #%s (%s)",
+				toolheadID, strings.ToUpper(slicedColor), spool.ID,
+				strings.ToUpper(strings.TrimPrefix(spool.Filament.ColorHex, "#")), colorFamilyForHex(spool.Filament.ColorHex)))
+		}
+	}
+	return problems
+}
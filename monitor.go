@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// MonitorTransport delivers out-of-band wake-ups for a printer's monitor loop, such as
+// push events from the printer. Polling is always active underneath as the fallback.
+type MonitorTransport interface {
+	// Name returns a short identifier used in logs
+	Name() string
+	// Run blocks until stop is closed, calling wake whenever the printer should be checked now
+	Run(printerID string, config PrinterConfig, wake func(), stop <-chan struct{})
+}
+
+// PrinterMonitor runs one monitoring goroutine per configured printer. Each goroutine polls
+// quickly while its printer is printing, slowly while idle, and immediately when a
+// transport reports an event.
+type PrinterMonitor struct {
+	bridge     *FilamentBridge
+	transports []MonitorTransport
+	onUpdate   func() // Called (debounced) after printer checks, e.g. to broadcast status
+	updates    chan struct{}
+	loops      map[string]*printerMonitorLoop
+	eventsOn   bool            // PrusaLink events setting the running loops were started with
+	running    sync.WaitGroup  // Printer goroutines, resumed and pushed prints, waited for at shutdown
+	ctx        context.Context // Context Run was started with, for prints pushed by webhook
+	mutex      sync.Mutex
+}
+
+// printerMonitorLoop holds the control channels of a single printer's monitor goroutine
+type printerMonitorLoop struct {
+	config PrinterConfig
+	wakeCh chan struct{}
+	stop   chan struct{}
+}
+
+// NewPrinterMonitor creates a monitor for all printers configured on the bridge
+func NewPrinterMonitor(bridge *FilamentBridge, onUpdate func()) *PrinterMonitor {
+	return &PrinterMonitor{
+		bridge:     bridge,
+		transports: []MonitorTransport{&prusaLinkEventTransport{bridge: bridge}},
+		onUpdate:   onUpdate,
+		updates:    make(chan struct{}, 1),
+		loops:      make(map[string]*printerMonitorLoop),
+	}
+}
+
+// Run starts monitoring and keeps printer goroutines in sync with the configuration until ctx
+// is cancelled. It then stops every printer and returns once prints being processed are done.
+func (m *PrinterMonitor) Run(ctx context.Context) {
+	if snapshot := m.bridge.GetConfigSnapshot(); snapshot != nil {
+		waitMonitorStartDelay(ctx, snapshot)
+	}
+	if ctx.Err() != nil {
+		return // Saved prints stay saved for the next start
+	}
+	m.mutex.Lock()
+	m.ctx = ctx
+	m.mutex.Unlock()
+
+	workers := DefaultPrintProcessingWorkers
+	if snapshot := m.bridge.GetConfigSnapshot(); snapshot != nil && snapshot.PrintProcessingWorkers > 0 {
+		workers = snapshot.PrintProcessingWorkers
+	}
+	m.bridge.printQueue.Start(workers, m.notifyUpdate)
+
+	// Prints left over from the last shutdown
+	finished, err := m.bridge.restoreUnfinishedPrints()
+	if err != nil {
+		monitorLog.Error("Failed to restore unfinished prints", "error", err)
+	}
+	for _, print := range finished {
+		m.running.Add(1)
+		go func(print unfinishedPrint) {
+			defer m.running.Done()
+			m.bridge.printQueue.Enqueue(print.PrinterID, func() {
+				m.bridge.resumeFinishedPrint(ctx, print)
+			})
+		}(print)
+	}
+
+	go m.runUpdateNotifier()
+
+	ticker := time.NewTicker(MonitorReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		if ctx.Err() == nil {
+			m.reconcile(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			m.stopAll()
+			// Stopping the queue first lets printer checks waiting for room finish
+			m.bridge.printQueue.Stop()
+			m.running.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopAll stops every printer goroutine
+func (m *PrinterMonitor) stopAll() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for printerID, loop := range m.loops {
+		close(loop.stop)
+		delete(m.loops, printerID)
+	}
+}
+
+// Wake requests an immediate check of a printer, e.g. from a push integration
+func (m *PrinterMonitor) Wake(printerID string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	loop, exists := m.loops[printerID]
+	if !exists {
+		return false
+	}
+	loop.wake()
+	return true
+}
+
+// wake signals the loop without blocking; pending wake-ups coalesce
+func (l *printerMonitorLoop) wake() {
+	select {
+	case l.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// reconcile starts, restarts, and stops printer goroutines to match the current configuration
+func (m *PrinterMonitor) reconcile(ctx context.Context) {
+	snapshot := m.bridge.GetConfigSnapshot()
+
+	wanted := make(map[string]PrinterConfig)
+	eventsOn := false
+	if snapshot != nil {
+		eventsOn = snapshot.PrusaLinkEventsEnabled
+		for printerID, printerConfig := range snapshot.Printers {
+			if printerID == "no_printers" || isFixturePrinter(printerID) {
+				continue // Skip placeholder and fixture printers, which have no real device
+			}
+			wanted[printerID] = printerConfig
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Transports read global settings at start, so restart everything when they change
+	restartAll := eventsOn != m.eventsOn
+	m.eventsOn = eventsOn
+
+	for printerID, loop := range m.loops {
+		config, stillWanted := wanted[printerID]
+		if !stillWanted || restartAll || config != loop.config {
+			close(loop.stop)
+			delete(m.loops, printerID)
+		}
+	}
+
+	for printerID, config := range wanted {
+		if _, running := m.loops[printerID]; running {
+			continue
+		}
+		loop := &printerMonitorLoop{
+			config: config,
+			wakeCh: make(chan struct{}, 1),
+			stop:   make(chan struct{}),
+		}
+		m.loops[printerID] = loop
+		m.running.Add(1)
+		go m.runPrinter(ctx, printerID, loop)
+	}
+}
+
+// runPrinter is the monitoring goroutine for a single printer
+func (m *PrinterMonitor) runPrinter(ctx context.Context, printerID string, loop *printerMonitorLoop) {
+	defer m.running.Done()
+	monitorLog.Info("Starting monitor", "printer", loop.config.Name, "printer_id", printerID)
+
+	for _, transport := range m.transports {
+		go transport.Run(printerID, loop.config, loop.wake, loop.stop)
+	}
+
+	// Stagger the first check so printers don't all poll at once
+	delay := m.jitter(m.bridge.GetConfigSnapshot(), 0)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	m.bridge.recordNextPoll(printerID, time.Now().Add(delay))
+
+	for {
+		select {
+		case <-loop.stop:
+			monitorLog.Info("Stopped monitor", "printer", loop.config.Name, "printer_id", printerID)
+			return
+		case <-loop.wakeCh:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+		}
+
+		if err := m.bridge.monitorPrusaLink(ctx, printerID, loop.config); err != nil {
+			monitorLog.Error("Error monitoring printer", "printer_id", printerID, "address", loop.config.IPAddress, "error", err)
+		}
+		interval := m.nextInterval(printerID)
+		timer.Reset(interval)
+		m.bridge.recordNextPoll(printerID, time.Now().Add(interval))
+		m.notifyUpdate()
+	}
+}
+
+// nextInterval returns how long to wait before the next poll of a printer
+func (m *PrinterMonitor) nextInterval(printerID string) time.Duration {
+	snapshot := m.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		return time.Duration(DefaultPollInterval) * time.Second
+	}
+
+	interval := snapshot.Printers[printerID].pollInterval(snapshot, m.bridge.isPrinting(printerID))
+	return interval + m.jitter(snapshot, interval)
+}
+
+// jitter returns a random delay up to the configured jitter, capped at half the interval
+func (m *PrinterMonitor) jitter(snapshot *Config, interval time.Duration) time.Duration {
+	if snapshot == nil || snapshot.MonitorJitter <= 0 {
+		return 0
+	}
+	maxJitter := snapshot.MonitorJitter
+	if interval > 0 && maxJitter > interval/2 {
+		maxJitter = interval / 2
+	}
+	if maxJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(maxJitter)))
+}
+
+// notifyUpdate schedules a debounced update notification
+func (m *PrinterMonitor) notifyUpdate() {
+	select {
+	case m.updates <- struct{}{}:
+	default:
+	}
+}
+
+// runUpdateNotifier calls onUpdate at most once per MonitorUpdateDebounce
+func (m *PrinterMonitor) runUpdateNotifier() {
+	for range m.updates {
+		if m.onUpdate != nil {
+			m.onUpdate()
+		}
+		time.Sleep(MonitorUpdateDebounce)
+	}
+}
+
+// isPrinting reports whether the printer was printing at its last check
+func (b *FilamentBridge) isPrinting(printerID string) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.wasPrinting[printerID]
+}
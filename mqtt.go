@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types (upper nibble of the fixed header)
+const (
+	mqttPacketConnect    = 0x10
+	mqttPacketConnAck    = 0x20
+	mqttPacketPublish    = 0x30
+	mqttPacketSubscribe  = 0x82 // With the reserved flags SUBSCRIBE requires
+	mqttPacketPingReq    = 0xC0
+	mqttPacketDisconnect = 0xE0
+)
+
+// MQTTClient is a minimal MQTT 3.1.1 client. Messages are sent and subscribed to with QoS 0,
+// which is all Home Assistant discovery and state updates, and scale readings, need.
+type MQTTClient struct {
+	conn      net.Conn
+	mutex     sync.Mutex
+	done      chan struct{}
+	closed    bool
+	onMessage func(topic string, payload []byte) // Receives messages of subscribed topics
+}
+
+// MQTTWill is the message the broker publishes on the client's behalf if the connection drops
+type MQTTWill struct {
+	Topic   string
+	Payload string
+	Retain  bool
+}
+
+// NewMQTTClient connects to a broker given as host:port, tcp://host:port or ssl://host:port
+func NewMQTTClient(broker, clientID, username, password string, will *MQTTWill) (*MQTTClient, error) {
+	address, useTLS, err := parseMQTTBroker(broker)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: MQTTConnectTimeout}
+	var conn net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker %s: %w", address, err)
+	}
+
+	client := &MQTTClient{conn: conn, done: make(chan struct{})}
+	if err := client.connect(clientID, username, password, will); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go client.keepAlive()
+	go client.readIncoming()
+	return client, nil
+}
+
+// parseMQTTBroker returns the dial address of a broker and whether it uses TLS
+func parseMQTTBroker(broker string) (string, bool, error) {
+	broker = strings.TrimSpace(broker)
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	parsed, err := url.Parse(broker)
+	if err != nil || parsed.Hostname() == "" {
+		return "", false, fmt.Errorf("invalid MQTT broker address: %s", broker)
+	}
+
+	var useTLS bool
+	defaultPort := "1883"
+	switch parsed.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		defaultPort = "8883"
+	default:
+		return "", false, fmt.Errorf("unsupported MQTT broker scheme: %s", parsed.Scheme)
+	}
+
+	port := parsed.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), useTLS, nil
+}
+
+// connect sends CONNECT and waits for the broker's CONNACK
+func (c *MQTTClient) connect(clientID, username, password string, will *MQTTWill) error {
+	var flags byte = 0x02 // Clean session
+	var payload []byte
+	payload = appendMQTTString(payload, clientID)
+	if will != nil {
+		flags |= 0x04
+		if will.Retain {
+			flags |= 0x20
+		}
+		payload = appendMQTTString(payload, will.Topic)
+		payload = appendMQTTString(payload, will.Payload)
+	}
+	if username != "" {
+		flags |= 0x80
+		payload = appendMQTTString(payload, username)
+		if password != "" {
+			flags |= 0x40
+			payload = appendMQTTString(payload, password)
+		}
+	}
+
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	body = append(body, 0x04, flags) // Protocol level 4 (3.1.1)
+	body = binary.BigEndian.AppendUint16(body, uint16(MQTTKeepAlive/time.Second))
+	body = append(body, payload...)
+
+	c.conn.SetDeadline(time.Now().Add(MQTTConnectTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.writePacket(mqttPacketConnect, body); err != nil {
+		return fmt.Errorf("failed to send MQTT connect: %w", err)
+	}
+
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, ack); err != nil {
+		return fmt.Errorf("failed to read MQTT connect acknowledgement: %w", err)
+	}
+	if ack[0] != mqttPacketConnAck {
+		return fmt.Errorf("unexpected MQTT packet 0x%02x while connecting", ack[0])
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("MQTT broker refused connection: %s", mqttConnectError(ack[3]))
+	}
+	return nil
+}
+
+// mqttConnectError describes a CONNACK return code
+func mqttConnectError(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad username or password"
+	case 5:
+		return "not authorized"
+	default:
+		return fmt.Sprintf("return code %d", code)
+	}
+}
+
+// Publish sends a QoS 0 message
+func (c *MQTTClient) Publish(topic string, payload []byte, retain bool) error {
+	header := byte(mqttPacketPublish)
+	if retain {
+		header |= 0x01
+	}
+	body := appendMQTTString(nil, topic)
+	body = append(body, payload...)
+	return c.writePacket(header, body)
+}
+
+// Subscribe asks the broker for messages published on topics, at QoS 0, and passes them to
+// handler. handler runs on the goroutine reading from the broker, so it must not block.
+func (c *MQTTClient) Subscribe(topics []string, handler func(topic string, payload []byte)) error {
+	c.mutex.Lock()
+	c.onMessage = handler
+	c.mutex.Unlock()
+
+	body := binary.BigEndian.AppendUint16(nil, 1) // Packet identifier; the SUBACK isn't waited for
+	for _, topic := range topics {
+		body = appendMQTTString(body, topic)
+		body = append(body, 0) // Requested QoS
+	}
+	return c.writePacket(mqttPacketSubscribe, body)
+}
+
+// Close sends DISCONNECT and closes the connection. The broker discards the will on a clean disconnect.
+func (c *MQTTClient) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.mutex.Unlock()
+
+	c.writePacket(mqttPacketDisconnect, nil)
+	return c.shutdown()
+}
+
+// shutdown closes the connection once and stops the background goroutines
+func (c *MQTTClient) shutdown() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	return c.conn.Close()
+}
+
+// Closed reports whether the connection has been closed or lost
+func (c *MQTTClient) Closed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// writePacket writes a control packet with its fixed header
+func (c *MQTTClient) writePacket(header byte, body []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("MQTT connection is closed")
+	}
+
+	packet := []byte{header}
+	packet = appendMQTTLength(packet, len(body))
+	packet = append(packet, body...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(MQTTConnectTimeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		c.closed = true
+		close(c.done)
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// keepAlive pings the broker so it doesn't drop an otherwise idle connection
+func (c *MQTTClient) keepAlive() {
+	ticker := time.NewTicker(MQTTKeepAlive / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.writePacket(mqttPacketPingReq, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readIncoming reads packets from the broker, passing messages of subscribed topics on and
+// dropping the rest (ping responses and acknowledgements), and closes the client when the
+// broker goes away
+func (c *MQTTClient) readIncoming() {
+	reader := bufio.NewReader(c.conn)
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			break
+		}
+		length, err := readMQTTLength(reader)
+		if err != nil {
+			break
+		}
+		if header&0xF0 != mqttPacketPublish {
+			if _, err := reader.Discard(length); err != nil {
+				break
+			}
+			continue
+		}
+
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(reader, packet); err != nil {
+			break
+		}
+		if len(packet) < 2 || int(binary.BigEndian.Uint16(packet))+2 > len(packet) {
+			continue
+		}
+		topicLength := int(binary.BigEndian.Uint16(packet))
+		topic, payload := string(packet[2:2+topicLength]), packet[2+topicLength:]
+
+		c.mutex.Lock()
+		handler := c.onMessage
+		c.mutex.Unlock()
+		if handler != nil {
+			handler(topic, payload)
+		}
+	}
+	c.shutdown()
+}
+
+// appendMQTTString appends a length-prefixed UTF-8 string
+func appendMQTTString(buf []byte, value string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(value)))
+	return append(buf, value...)
+}
+
+// appendMQTTLength appends a variable-length remaining length
+func appendMQTTLength(buf []byte, length int) []byte {
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		buf = append(buf, digit)
+		if length == 0 {
+			return buf
+		}
+	}
+}
+
+// readMQTTLength reads a variable-length remaining length
+func readMQTTLength(reader io.ByteReader) (int, error) {
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, fmt.Errorf("malformed MQTT remaining length")
+}
+
+// mqttSubscription keeps topics taken from the configuration subscribed on the broker set
+// for Home Assistant, with its own connection
+type mqttSubscription struct {
+	bridge       *FilamentBridge
+	name         string                 // What is subscribed to, for logs
+	clientSuffix string                 // Appended to the topic prefix to make the client ID
+	topics       func(*Config) []string // Topics to subscribe to; none means not connecting
+	receive      func(string, []byte)   // Handles a message; must not block
+}
+
+// mqttSubscriptionSettings are what a subscription was made with; a change resubscribes
+type mqttSubscriptionSettings struct {
+	broker   string
+	username string
+	password string
+	topics   string
+}
+
+// settings returns the broker settings and topics of a configuration
+func (s mqttSubscription) settings(snapshot *Config) mqttSubscriptionSettings {
+	return mqttSubscriptionSettings{
+		broker:   snapshot.MQTTBroker,
+		username: snapshot.MQTTUsername,
+		password: snapshot.MQTTPassword,
+		topics:   strings.Join(s.topics(snapshot), "\n"),
+	}
+}
+
+// run keeps the subscription open until ctx is cancelled, resubscribing after failures and
+// whenever the broker or topics change
+func (s mqttSubscription) run(ctx context.Context) {
+	for {
+		snapshot := s.bridge.GetConfigSnapshot()
+		if snapshot != nil && snapshot.MQTTBroker != "" && len(s.topics(snapshot)) > 0 {
+			settings := s.settings(snapshot)
+			prefix := strings.Trim(snapshot.MQTTTopicPrefix, "/")
+			if prefix == "" {
+				prefix = DefaultMQTTTopicPrefix
+			}
+
+			client, err := NewMQTTClient(settings.broker, prefix+s.clientSuffix, settings.username, settings.password, nil)
+			if err == nil {
+				err = client.Subscribe(strings.Split(settings.topics, "\n"), s.receive)
+			}
+			if err != nil {
+				mqttLog.Warn("Failed to subscribe over MQTT", "subscription", s.name, "broker", settings.broker, "error", err)
+			} else {
+				mqttLog.Info("Subscribed over MQTT", "subscription", s.name, "broker", settings.broker, "topics", len(s.topics(snapshot)))
+				s.wait(ctx, client, settings)
+			}
+			if client != nil {
+				client.Close()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(MQTTSubscriptionRetryInterval):
+		}
+	}
+}
+
+// wait returns when ctx is cancelled, the connection drops or the settings change
+func (s mqttSubscription) wait(ctx context.Context, client *MQTTClient, settings mqttSubscriptionSettings) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot := s.bridge.GetConfigSnapshot()
+			if client.Closed() || snapshot == nil || s.settings(snapshot) != settings {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NDEF record header flags and type name formats
+const (
+	ndefFlagMessageBegin = 0x80
+	ndefFlagMessageEnd   = 0x40
+	ndefFlagShortRecord  = 0x10
+	ndefTNFWellKnown     = 0x01
+)
+
+// ndefURIPrefixes are the URI identifier codes an NDEF URI record can abbreviate. Longer
+// prefixes come first so the best match wins.
+var ndefURIPrefixes = []struct {
+	code   byte
+	prefix string
+}{
+	{0x02, "https://www."},
+	{0x01, "http://www."},
+	{0x04, "https://"},
+	{0x03, "http://"},
+}
+
+// encodeNDEFURIMessage encodes a URL as an NDEF message holding a single URI record
+func encodeNDEFURIMessage(url string) []byte {
+	payload := []byte{0x00} // No abbreviation
+	for _, p := range ndefURIPrefixes {
+		if strings.HasPrefix(url, p.prefix) {
+			payload = []byte{p.code}
+			url = strings.TrimPrefix(url, p.prefix)
+			break
+		}
+	}
+	payload = append(payload, url...)
+
+	header := byte(ndefFlagMessageBegin | ndefFlagMessageEnd | ndefTNFWellKnown)
+	record := []byte{header, 1} // Type length: "U"
+	if len(payload) <= 0xff {
+		record[0] |= ndefFlagShortRecord
+		record = append(record, byte(len(payload)))
+	} else {
+		record = binary.BigEndian.AppendUint32(record, uint32(len(payload)))
+	}
+	record = append(record, 'U')
+	return append(record, payload...)
+}
+
+// wrapNDEFType2TLV wraps an NDEF message in the TLV block written to NFC Forum Type 2 tags
+// such as NTAG213/215/216, for tools that write raw tag memory
+func wrapNDEFType2TLV(message []byte) []byte {
+	tlv := []byte{0x03} // NDEF message TLV
+	if len(message) < 0xff {
+		tlv = append(tlv, byte(len(message)))
+	} else {
+		tlv = append(tlv, 0xff)
+		tlv = binary.BigEndian.AppendUint16(tlv, uint16(len(message)))
+	}
+	tlv = append(tlv, message...)
+	return append(tlv, 0xfe) // Terminator TLV
+}
+
+// nfcNDEFHandler returns ready-to-write NDEF data for a spool or location tag: the raw
+// message, a Type 2 tag TLV block and a Web NFC message that can be passed straight to
+// NDEFReader.write(). Use format=bin to download the raw message instead.
+func (ws *WebServer) nfcNDEFHandler(c *gin.Context) {
+	var url, name string
+	switch {
+	case c.Query("spool") != "":
+		spoolID, err := strconv.Atoi(c.Query("spool"))
+		if err != nil || spoolID <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+			return
+		}
+		url = ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?spool=%d", ws.publicBaseURL(c), spoolID))
+		name = fmt.Sprintf("spool-%d", spoolID)
+	case strings.TrimSpace(c.Query("location")) != "":
+		location := strings.TrimSpace(c.Query("location"))
+		url = ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?location=%s", ws.publicBaseURL(c), neturl.QueryEscape(location)))
+		name = "location-" + sanitizeErrorID(location)
+	default:
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "spool or location parameter is required")
+		return
+	}
+
+	message := encodeNDEFURIMessage(url)
+	if c.Query("format") == "bin" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ndef"`, name))
+		c.Data(http.StatusOK, "application/octet-stream", message)
+		return
+	}
+
+	c.JSON(http.StatusOK, NDEFResponse{
+		URL:        url,
+		NDEFHex:    strings.ToUpper(hex.EncodeToString(message)),
+		NDEFBase64: base64.StdEncoding.EncodeToString(message),
+		TLVHex:     strings.ToUpper(hex.EncodeToString(wrapNDEFType2TLV(message))),
+		Size:       len(message),
+		WebNFC: NDEFWebNFC{
+			Records: []NDEFWebNFCRecord{{RecordType: "url", Data: url}},
+		},
+	})
+}
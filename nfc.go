@@ -1,19 +1,33 @@
 package main
 
 import (
-	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"log"
-	"net"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// NFCSessionCookie holds the scanning device's NFC session ID
+const NFCSessionCookie = "filabridge_nfc_session"
+
+// NFCSessionCookieMaxAge keeps a device on the same session ID between scanning sessions
+const NFCSessionCookieMaxAge = 30 * 24 * time.Hour
+
+// NFC session events broadcast over the websocket
+const (
+	NFCSessionUpdated   = "updated"   // A tag was scanned and the session waits for the next one
+	NFCSessionCompleted = "completed" // Spool and location were both scanned and assigned
+	NFCSessionCancelled = "cancelled"
+	NFCSessionFailed    = "failed" // The assignment failed
+)
+
 // NFCSession represents an active NFC scanning session
 type NFCSession struct {
 	SessionID         string    `json:"session_id"`
 	SpoolID           int       `json:"spool_id"`
+	SpoolmanInstance  string    `json:"spoolman_instance,omitempty"` // Instance the spool was scanned in; empty for the main one
 	PrinterName       string    `json:"printer_name"`
 	ToolheadID        int       `json:"toolhead_id"`
 	LocationName      string    `json:"location_name"`
@@ -55,7 +69,7 @@ func (b *FilamentBridge) parseLocationParam(location string) (printerName string
 							}
 						}
 						// Also check default names
-						for tid := 0; tid < printerConfig.Toolheads; tid++ {
+						for tid := 0; tid < printerConfig.SlotCount(); tid++ {
 							defaultName := fmt.Sprintf("Toolhead %d", tid)
 							if defaultName == toolheadPart {
 								return printerName, tid, location, true, nil
@@ -78,7 +92,7 @@ func (b *FilamentBridge) parseLocationParam(location string) (printerName string
 						for _, printerConfig := range printerConfigs {
 							if printerConfig.Name == printerName {
 								// Verify the numeric ID is within valid range
-								if toolheadID >= 0 && toolheadID < printerConfig.Toolheads {
+								if toolheadID >= 0 && toolheadID < printerConfig.SlotCount() {
 									return printerName, toolheadID, location, true, nil
 								}
 								// If numeric ID is out of range, don't return it - treat as regular location
@@ -98,34 +112,36 @@ func (b *FilamentBridge) parseLocationParam(location string) (printerName string
 	return "", 0, location, false, nil
 }
 
-// generateSessionID creates a unique session ID based on client IP only
-// This ensures all scans from the same device use the same session
-func generateSessionID(clientIP string) string {
-	hash := md5.Sum([]byte(clientIP))
-	return fmt.Sprintf("%x", hash)[:16] // Use first 16 characters of MD5
+// newNFCSessionID creates a random session ID. It's handed to the scanning device in a
+// cookie, so phones sharing an IP address (e.g. behind NAT) get separate sessions.
+func newNFCSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate NFC session ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
 }
 
-// getClientIP extracts the real client IP from the request
-func getClientIP(remoteAddr string) string {
-	host, _, err := net.SplitHostPort(remoteAddr)
-	if err != nil {
-		// If SplitHostPort fails, assume the whole string is the IP
-		return remoteAddr
+// nfcSessionTimeout returns how long a session waits for its next scan. The caller must
+// hold b.mutex.
+func (b *FilamentBridge) nfcSessionTimeout() time.Duration {
+	if b.config != nil && b.config.NFCSessionTimeout > 0 {
+		return b.config.NFCSessionTimeout
 	}
-	return host
+	return time.Duration(DefaultNFCSessionTimeout) * time.Minute
 }
 
 // createOrUpdateSession creates a new session or updates an existing one
-func (b *FilamentBridge) createOrUpdateSession(sessionID string, spoolID int, printerName string, toolheadID int, locationName string, isPrinterLocation bool) (*NFCSession, error) {
+func (b *FilamentBridge) createOrUpdateSession(sessionID string, spoolID int, spoolInstance, printerName string, toolheadID int, locationName string, isPrinterLocation bool) (*NFCSession, error) {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
 	// Check if session already exists
 	var existingSession NFCSession
 	err := b.db.QueryRow(
-		"SELECT session_id, spool_id, printer_name, toolhead_id, location_name, is_printer_location, created_at, expires_at FROM nfc_sessions WHERE session_id = ?",
+		"SELECT session_id, spool_id, COALESCE(spoolman_instance, ''), printer_name, toolhead_id, location_name, is_printer_location, created_at, expires_at FROM nfc_sessions WHERE session_id = ?",
 		sessionID,
-	).Scan(&existingSession.SessionID, &existingSession.SpoolID, &existingSession.PrinterName,
+	).Scan(&existingSession.SessionID, &existingSession.SpoolID, &existingSession.SpoolmanInstance, &existingSession.PrinterName,
 		&existingSession.ToolheadID, &existingSession.LocationName, &existingSession.IsPrinterLocation, &existingSession.CreatedAt, &existingSession.ExpiresAt)
 
 	if err == nil {
@@ -133,7 +149,13 @@ func (b *FilamentBridge) createOrUpdateSession(sessionID string, spoolID int, pr
 		now := time.Now()
 		if now.After(existingSession.ExpiresAt) {
 			// Session expired, create new one
-			return b.createNewSession(sessionID, spoolID, printerName, toolheadID, locationName, isPrinterLocation)
+			return b.createNewSession(sessionID, spoolID, spoolInstance, printerName, toolheadID, locationName, isPrinterLocation)
+		}
+
+		// Each scan gives the session a fresh timeout for the next tag
+		existingSession.ExpiresAt = now.Add(b.nfcSessionTimeout())
+		if _, err := b.db.Exec("UPDATE nfc_sessions SET expires_at = ? WHERE session_id = ?", existingSession.ExpiresAt, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to extend NFC session: %w", err)
 		}
 
 		// Update existing session - only update fields that are actually being set
@@ -142,12 +164,13 @@ func (b *FilamentBridge) createOrUpdateSession(sessionID string, spoolID int, pr
 		// Update spool data only if a new spool is being scanned
 		if spoolID > 0 {
 			existingSession.SpoolID = spoolID
+			existingSession.SpoolmanInstance = spoolInstance
 			existingSession.HasSpool = true
 
-			// Update only spool_id in database, preserve other fields
+			// Update only the spool in database, preserve other fields
 			_, err = b.db.Exec(
-				"UPDATE nfc_sessions SET spool_id = ? WHERE session_id = ?",
-				spoolID, sessionID,
+				"UPDATE nfc_sessions SET spool_id = ?, spoolman_instance = ? WHERE session_id = ?",
+				spoolID, spoolInstance, sessionID,
 			)
 			if err != nil {
 				return nil, fmt.Errorf("failed to update spool in NFC session: %w", err)
@@ -181,17 +204,18 @@ func (b *FilamentBridge) createOrUpdateSession(sessionID string, spoolID int, pr
 	}
 
 	// Create new session
-	return b.createNewSession(sessionID, spoolID, printerName, toolheadID, locationName, isPrinterLocation)
+	return b.createNewSession(sessionID, spoolID, spoolInstance, printerName, toolheadID, locationName, isPrinterLocation)
 }
 
 // createNewSession creates a new NFC session
-func (b *FilamentBridge) createNewSession(sessionID string, spoolID int, printerName string, toolheadID int, locationName string, isPrinterLocation bool) (*NFCSession, error) {
+func (b *FilamentBridge) createNewSession(sessionID string, spoolID int, spoolInstance, printerName string, toolheadID int, locationName string, isPrinterLocation bool) (*NFCSession, error) {
 	now := time.Now()
-	expiresAt := now.Add(5 * time.Minute) // 5 minute expiration
+	expiresAt := now.Add(b.nfcSessionTimeout())
 
 	session := &NFCSession{
 		SessionID:         sessionID,
 		SpoolID:           spoolID,
+		SpoolmanInstance:  spoolInstance,
 		PrinterName:       printerName,
 		ToolheadID:        toolheadID,
 		LocationName:      locationName,
@@ -203,8 +227,8 @@ func (b *FilamentBridge) createNewSession(sessionID string, spoolID int, printer
 	}
 
 	_, err := b.db.Exec(
-		"INSERT INTO nfc_sessions (session_id, spool_id, printer_name, toolhead_id, location_name, is_printer_location, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		session.SessionID, session.SpoolID, session.PrinterName, session.ToolheadID, session.LocationName, session.IsPrinterLocation, session.CreatedAt, session.ExpiresAt,
+		"INSERT INTO nfc_sessions (session_id, spool_id, spoolman_instance, printer_name, toolhead_id, location_name, is_printer_location, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		session.SessionID, session.SpoolID, session.SpoolmanInstance, session.PrinterName, session.ToolheadID, session.LocationName, session.IsPrinterLocation, session.CreatedAt, session.ExpiresAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create NFC session: %w", err)
@@ -217,9 +241,9 @@ func (b *FilamentBridge) createNewSession(sessionID string, spoolID int, printer
 func (b *FilamentBridge) getSession(sessionID string) (*NFCSession, error) {
 	var session NFCSession
 	err := b.db.QueryRow(
-		"SELECT session_id, spool_id, printer_name, toolhead_id, location_name, is_printer_location, created_at, expires_at FROM nfc_sessions WHERE session_id = ?",
+		"SELECT session_id, spool_id, COALESCE(spoolman_instance, ''), printer_name, toolhead_id, location_name, is_printer_location, created_at, expires_at FROM nfc_sessions WHERE session_id = ?",
 		sessionID,
-	).Scan(&session.SessionID, &session.SpoolID, &session.PrinterName,
+	).Scan(&session.SessionID, &session.SpoolID, &session.SpoolmanInstance, &session.PrinterName,
 		&session.ToolheadID, &session.LocationName, &session.IsPrinterLocation, &session.CreatedAt, &session.ExpiresAt)
 
 	if err != nil {
@@ -256,14 +280,17 @@ func (b *FilamentBridge) cleanupExpiredSessions() error {
 	now := time.Now()
 	_, err := b.db.Exec("DELETE FROM nfc_sessions WHERE expires_at < ?", now)
 	if err != nil {
-		log.Printf("Error cleaning up expired NFC sessions: %v", err)
+		nfcLog.Error("Error cleaning up expired NFC sessions", "error", err)
 		return err
 	}
 	return nil
 }
 
-// AssignSpoolToLocation assigns a spool to a location and updates Spoolman
+// AssignSpoolToLocation assigns a spool to a location and updates Spoolman. The spool is in
+// the printer's Spoolman instance; for a storage location the printer is optional and only
+// picks the instance, the main one when it's empty.
 func (b *FilamentBridge) AssignSpoolToLocation(spoolID int, printerName string, toolheadID int, locationName string, isPrinterLocation bool) error {
+	spoolman := b.spoolmanFor(printerName)
 	if isPrinterLocation {
 		// This is a printer toolhead location
 		// Update FilaBridge toolhead mapping
@@ -298,43 +325,46 @@ func (b *FilamentBridge) AssignSpoolToLocation(spoolID int, printerName string,
 		// Note: Spoolman API doesn't support creating locations via POST.
 		// The location will be auto-created when we update the spool's location field.
 		
-		if err := b.spoolman.UpdateSpoolLocation(spoolID, locationName); err != nil {
+		if err := spoolman.UpdateSpoolLocation(spoolID, locationName); err != nil {
 			// If Spoolman update fails, we should still log it but not fail the entire operation
 			// since the FilaBridge mapping is more critical
-			log.Printf("Warning: Failed to update Spoolman location for spool %d: %v", spoolID, err)
+			nfcLog.Warn("Failed to update Spoolman location", "spool_id", spoolID, "error", err)
 		}
 
-		log.Printf("Successfully assigned spool %d to %s toolhead %d (%s)", spoolID, printerName, toolheadID, displayName)
+		nfcLog.Info("Assigned spool to toolhead", "spool_id", spoolID, "printer", printerName, "toolhead_id", toolheadID, "toolhead", displayName)
 	} else {
 		// This is a non-printer location (drybox, storage, etc.)
 		// First, check if this spool is currently assigned to any toolhead and clear it
-		if err := b.clearSpoolFromAllToolheads(spoolID); err != nil {
-			log.Printf("Warning: Failed to clear spool %d from toolheads: %v", spoolID, err)
+		if err := b.clearSpoolFromAllToolheads(b.spoolmanInstanceOf(printerName), spoolID); err != nil {
+			nfcLog.Warn("Failed to clear spool from toolheads", "spool_id", spoolID, "error", err)
 		}
 
 		// Use the location name directly with Spoolman
 		if locationName == "" {
-			return fmt.Errorf("location name cannot be empty")
+			return newCodedError(ErrCodeInvalidRequest, "location name cannot be empty")
 		}
 
 		// Ensure the location exists in Spoolman
-		if _, err := b.spoolman.GetOrCreateLocation(locationName); err != nil {
-			log.Printf("Warning: Failed to create/verify location '%s' in Spoolman: %v", locationName, err)
+		if _, err := spoolman.GetOrCreateLocation(locationName); err != nil {
+			nfcLog.Warn("Failed to create or verify location in Spoolman", "location", locationName, "error", err)
 		}
 
 		// Update Spoolman location
-		if err := b.spoolman.UpdateSpoolLocation(spoolID, locationName); err != nil {
+		if err := spoolman.UpdateSpoolLocation(spoolID, locationName); err != nil {
 			return fmt.Errorf("failed to update Spoolman location for spool %d: %w", spoolID, err)
 		}
 
-		log.Printf("Successfully assigned spool %d to location '%s'", spoolID, locationName)
+		nfcLog.Info("Assigned spool to location", "spool_id", spoolID, "location", locationName)
+		b.logSpoolEvents(SpoolEvent{SpoolmanInstance: b.spoolmanInstanceOf(printerName), SpoolID: spoolID,
+			EventType: SpoolEventMoved, Location: locationName, CreatedAt: time.Now()})
 	}
 
 	return nil
 }
 
-// clearSpoolFromAllToolheads removes a spool from all toolhead mappings
-func (b *FilamentBridge) clearSpoolFromAllToolheads(spoolID int) error {
+// clearSpoolFromAllToolheads removes a spool from the toolhead mappings of all printers using
+// its Spoolman instance
+func (b *FilamentBridge) clearSpoolFromAllToolheads(instance string, spoolID int) error {
 	// Get all current toolhead mappings
 	allMappings, err := b.GetAllToolheadMappings()
 	if err != nil {
@@ -344,12 +374,12 @@ func (b *FilamentBridge) clearSpoolFromAllToolheads(spoolID int) error {
 	// Find and clear any mappings for this spool
 	for printerName, printerMappings := range allMappings {
 		for toolheadID, mapping := range printerMappings {
-			if mapping.SpoolID == spoolID {
+			if mapping.SpoolID == spoolID && b.spoolmanInstanceOf(printerName) == instance {
 				// Clear this toolhead mapping
 				if err := b.UnmapToolhead(printerName, toolheadID); err != nil {
-					log.Printf("Warning: Failed to unmap spool %d from %s toolhead %d: %v", spoolID, printerName, toolheadID, err)
+					nfcLog.Warn("Failed to unmap spool", "spool_id", spoolID, "printer", printerName, "toolhead_id", toolheadID, "error", err)
 				} else {
-					log.Printf("Cleared spool %d from %s toolhead %d", spoolID, printerName, toolheadID)
+					nfcLog.Info("Cleared spool from toolhead", "spool_id", spoolID, "printer", printerName, "toolhead_id", toolheadID)
 				}
 			}
 		}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification event types
+const (
+	NotificationEventPrintComplete    = "print_complete"
+	NotificationEventProcessingFailed = "processing_failed"
+	NotificationEventSpoolIdle        = "spool_idle"
+	NotificationEventRunoutPredicted  = "runout_predicted"
+	NotificationEventFilamentRunout   = "filament_runout"
+	NotificationEventSpoolLow         = "spool_low"
+	NotificationEventSpoolEmpty       = "spool_empty"
+	NotificationEventPrinterOffline   = "printer_offline"
+	NotificationEventScaleDiscrepancy = "scale_discrepancy"
+	NotificationEventSpoolExposed     = "spool_exposed"
+	NotificationEventDigest           = "digest"
+)
+
+// Notification channel types
+const (
+	NotificationChannelWebhook  = "webhook"
+	NotificationChannelNtfy     = "ntfy"
+	NotificationChannelDiscord  = "discord"
+	NotificationChannelTelegram = "telegram"
+	NotificationChannelEmail    = "email"
+	NotificationChannelExec     = "exec"
+)
+
+// Notification is a single message sent to notification channels
+type Notification struct {
+	Event     string    `json:"event"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Critical  bool      `json:"critical"`      // Critical notifications may bypass quiet hours
+	URL       string    `json:"url,omitempty"` // Optional action link
+	Timestamp time.Time `json:"timestamp"`
+	Includes  []string  `json:"includes,omitempty"` // Events folded into this one, e.g. spool_low in a combined print complete
+}
+
+// events returns the notification's event and the events folded into it
+func (n Notification) events() []string {
+	return append([]string{n.Event}, n.Includes...)
+}
+
+// QuietHours is a daily time window (server local time) during which a channel's
+// notifications are held and delivered afterwards as a single digest
+type QuietHours struct {
+	Start          string   `json:"start"`           // "HH:MM"
+	End            string   `json:"end"`             // "HH:MM", may be earlier than Start to span midnight
+	ExemptCritical bool     `json:"exempt_critical"` // Deliver critical notifications immediately
+	ExemptEvents   []string `json:"exempt_events,omitempty"`
+}
+
+// NotificationChannel is a configured notification destination. Which fields are used
+// depends on the type: webhook, ntfy and discord post to URL; telegram uses Token and
+// ChatID; email sends through SMTPServer to To; exec runs Command with Args.
+type NotificationChannel struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	URL        string      `json:"url,omitempty"`
+	Token      string      `json:"token,omitempty"`       // ntfy access token or Telegram bot token
+	ChatID     string      `json:"chat_id,omitempty"`     // Telegram chat ID
+	SMTPServer string      `json:"smtp_server,omitempty"` // "host:port"
+	Username   string      `json:"username,omitempty"`    // SMTP username, empty for no authentication
+	Password   string      `json:"password,omitempty"`
+	From       string      `json:"from,omitempty"`
+	To         []string    `json:"to,omitempty"`
+	Command    string      `json:"command,omitempty"` // Program an exec hook runs, without a shell
+	Args       []string    `json:"args,omitempty"`
+	Events     []string    `json:"events,omitempty"` // Empty means all events
+	Enabled    bool        `json:"enabled"`
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+}
+
+// Notifier delivers notifications to the configured channels, holding them during quiet hours
+type Notifier struct {
+	bridge     *FilamentBridge
+	httpClient *http.Client
+	held       map[string][]Notification // Held notifications per channel name
+	mutex      sync.Mutex
+}
+
+// NewNotifier creates a notifier for the bridge's configured channels
+func NewNotifier(bridge *FilamentBridge) *Notifier {
+	return &Notifier{
+		bridge:     bridge,
+		httpClient: &http.Client{Timeout: NotificationTimeout},
+		held:       make(map[string][]Notification),
+	}
+}
+
+// parseNotificationChannels parses and validates the JSON channel list from the configuration table
+func parseNotificationChannels(value string) ([]NotificationChannel, error) {
+	if strings.TrimSpace(value) == "" {
+		return []NotificationChannel{}, nil
+	}
+
+	var channels []NotificationChannel
+	if err := json.Unmarshal([]byte(value), &channels); err != nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "invalid notification channels: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, channel := range channels {
+		if channel.Name == "" {
+			return nil, newCodedError(ErrCodeInvalidRequest, "notification channel name is required")
+		}
+		if names[channel.Name] {
+			return nil, newCodedError(ErrCodeInvalidRequest, "duplicate notification channel name: %s", channel.Name)
+		}
+		names[channel.Name] = true
+
+		if err := channel.validate(); err != nil {
+			return nil, err
+		}
+		if channel.QuietHours != nil {
+			if _, err := parseClockTime(channel.QuietHours.Start); err != nil {
+				return nil, newCodedError(ErrCodeInvalidRequest, "invalid quiet hours start for %s: %v", channel.Name, err)
+			}
+			if _, err := parseClockTime(channel.QuietHours.End); err != nil {
+				return nil, newCodedError(ErrCodeInvalidRequest, "invalid quiet hours end for %s: %v", channel.Name, err)
+			}
+		}
+	}
+
+	return channels, nil
+}
+
+// validate checks that a channel has the settings its type needs
+func (ch NotificationChannel) validate() error {
+	switch ch.Type {
+	case NotificationChannelWebhook, NotificationChannelNtfy, NotificationChannelDiscord:
+		if ch.URL == "" {
+			return newCodedError(ErrCodeInvalidRequest, "notification channel %s requires a URL", ch.Name)
+		}
+	case NotificationChannelTelegram:
+		if ch.Token == "" || ch.ChatID == "" {
+			return newCodedError(ErrCodeInvalidRequest, "notification channel %s requires a bot token and chat ID", ch.Name)
+		}
+	case NotificationChannelEmail:
+		if _, _, err := net.SplitHostPort(ch.SMTPServer); err != nil {
+			return newCodedError(ErrCodeInvalidRequest, "notification channel %s requires an SMTP server as host:port", ch.Name)
+		}
+		if ch.From == "" || len(ch.To) == 0 {
+			return newCodedError(ErrCodeInvalidRequest, "notification channel %s requires from and to addresses", ch.Name)
+		}
+	case NotificationChannelExec:
+		if strings.TrimSpace(ch.Command) == "" {
+			return newCodedError(ErrCodeInvalidRequest, "notification channel %s requires a command", ch.Name)
+		}
+	default:
+		return newCodedError(ErrCodeInvalidRequest, "unsupported notification channel type for %s: %s", ch.Name, ch.Type)
+	}
+	return nil
+}
+
+// parseClockTime parses "HH:MM" into minutes since midnight
+func parseClockTime(value string) (int, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// active reports whether the quiet hours window contains the given time
+func (q *QuietHours) active(now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	start, err := parseClockTime(q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClockTime(q.End)
+	if err != nil || start == end {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// Window spans midnight, e.g. 22:00-07:00
+	return minute >= start || minute < end
+}
+
+// exempts reports whether a notification bypasses the quiet hours window
+func (q *QuietHours) exempts(n Notification) bool {
+	if q.ExemptCritical && n.Critical {
+		return true
+	}
+	for _, event := range q.ExemptEvents {
+		for _, included := range n.events() {
+			if event == included {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subscribes reports whether the channel wants the given event
+func (ch NotificationChannel) subscribes(event string) bool {
+	if len(ch.Events) == 0 {
+		return true
+	}
+	for _, e := range ch.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// wants reports whether the channel subscribes to the notification's event or one folded into it
+func (ch NotificationChannel) wants(n Notification) bool {
+	for _, event := range n.events() {
+		if ch.subscribes(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify sends a notification to every subscribed channel, holding it on channels in quiet hours
+func (n *Notifier) Notify(notification Notification) {
+	if notification.Timestamp.IsZero() {
+		notification.Timestamp = time.Now()
+	}
+
+	snapshot := n.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, channel := range snapshot.NotificationChannels {
+		if !channel.Enabled || !channel.wants(notification) {
+			continue
+		}
+
+		if channel.QuietHours.active(now) && !channel.QuietHours.exempts(notification) {
+			n.mutex.Lock()
+			n.held[channel.Name] = append(n.held[channel.Name], notification)
+			n.mutex.Unlock()
+			notificationsLog.Info("Holding notification until quiet hours end", "event", notification.Event, "channel", channel.Name)
+			continue
+		}
+
+		go n.deliver(channel, notification)
+	}
+}
+
+// Held returns the notifications currently held per channel
+func (n *Notifier) Held() map[string][]Notification {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	held := make(map[string][]Notification, len(n.held))
+	for name, notifications := range n.held {
+		held[name] = append([]Notification(nil), notifications...)
+	}
+	return held
+}
+
+// flushDigests sends a digest for every channel whose quiet hours are over. It runs as
+// a scheduled job so held notifications go out soon after quiet hours end.
+func (n *Notifier) flushDigests(now time.Time) {
+	snapshot := n.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+
+	channels := make(map[string]NotificationChannel, len(snapshot.NotificationChannels))
+	for _, channel := range snapshot.NotificationChannels {
+		channels[channel.Name] = channel
+	}
+
+	n.mutex.Lock()
+	digests := make(map[string][]Notification)
+	for name, notifications := range n.held {
+		channel, exists := channels[name]
+		if !exists || !channel.Enabled {
+			delete(n.held, name) // Channel removed or disabled while holding
+			continue
+		}
+		if channel.QuietHours.active(now) {
+			continue
+		}
+		digests[name] = notifications
+		delete(n.held, name)
+	}
+	n.mutex.Unlock()
+
+	for name, notifications := range digests {
+		n.deliver(channels[name], buildDigest(notifications))
+	}
+}
+
+// buildDigest combines held notifications into a single notification
+func buildDigest(notifications []Notification) Notification {
+	lines := make([]string, 0, len(notifications))
+	critical := false
+	for _, notification := range notifications {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", notification.Timestamp.Format("15:04"), notification.Title, notification.Message))
+		critical = critical || notification.Critical
+	}
+
+	return Notification{
+		Event:     NotificationEventDigest,
+		Title:     fmt.Sprintf("%d notifications held during quiet hours", len(notifications)),
+		Message:   strings.Join(lines, "\n"),
+		Critical:  critical,
+		Timestamp: time.Now(),
+	}
+}
+
+// deliver sends a notification to a single channel
+func (n *Notifier) deliver(channel NotificationChannel, notification Notification) {
+	var err error
+	switch channel.Type {
+	case NotificationChannelWebhook:
+		err = n.sendWebhook(channel.URL, notification)
+	case NotificationChannelNtfy:
+		err = n.sendNtfy(channel, notification)
+	case NotificationChannelDiscord:
+		err = n.sendDiscord(channel.URL, notification)
+	case NotificationChannelTelegram:
+		err = n.sendTelegram(channel, notification)
+	case NotificationChannelEmail:
+		err = sendEmail(channel, notification)
+	case NotificationChannelExec:
+		err = runExecHook(channel, notification)
+	default:
+		err = fmt.Errorf("unsupported channel type: %s", channel.Type)
+	}
+
+	if err != nil {
+		notificationsLog.Warn("Failed to send notification", "event", notification.Event, "channel", channel.Name, "error", err)
+	}
+}
+
+// sendWebhook posts the notification as JSON to a webhook URL
+func (n *Notifier) sendWebhook(url string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	return n.postJSON(url, body)
+}
+
+// postJSON posts a JSON body and checks for a successful response
+func (n *Notifier) postJSON(url string, body []byte) error {
+	resp, err := n.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendNtfy publishes the notification to an ntfy topic URL
+func (n *Notifier) sendNtfy(channel NotificationChannel, notification Notification) error {
+	req, err := http.NewRequest("POST", channel.URL, strings.NewReader(notification.Message))
+	if err != nil {
+		return fmt.Errorf("failed to create ntfy request: %w", err)
+	}
+	req.Header.Set("Title", notification.Title)
+	req.Header.Set("Tags", notification.Event)
+	if notification.Critical {
+		req.Header.Set("Priority", "high")
+	}
+	if notification.URL != "" {
+		req.Header.Set("Click", notification.URL)
+	}
+	if channel.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+channel.Token)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to ntfy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ntfy returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendDiscord posts the notification to a Discord webhook
+func (n *Notifier) sendDiscord(url string, notification Notification) error {
+	body, err := json.Marshal(map[string]string{"content": notificationText(notification, "**")})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	return n.postJSON(url, body)
+}
+
+// sendTelegram sends the notification as a Telegram bot message
+func (n *Notifier) sendTelegram(channel NotificationChannel, notification Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": channel.ChatID,
+		"text":    notificationText(notification, ""),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	return n.postJSON(fmt.Sprintf("%s/bot%s/sendMessage", TelegramAPIURL, channel.Token), body)
+}
+
+// sendEmail sends the notification as a plain-text email
+func sendEmail(channel NotificationChannel, notification Notification) error {
+	host, _, _ := net.SplitHostPort(channel.SMTPServer)
+	var auth smtp.Auth
+	if channel.Username != "" {
+		auth = smtp.PlainAuth("", channel.Username, channel.Password, host)
+	}
+
+	// Strip line breaks from the subject so it can't inject headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace("[FilaBridge] " + notification.Title)
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		channel.From, strings.Join(channel.To, ", "), subject, notificationText(notification, ""))
+
+	if err := smtp.SendMail(channel.SMTPServer, auth, channel.From, channel.To, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// notificationText formats a notification as a title line, message and optional link.
+// The title is wrapped in emphasis markers for channels that render markdown.
+func notificationText(notification Notification, emphasis string) string {
+	text := emphasis + notification.Title + emphasis + "\n" + notification.Message
+	if notification.URL != "" {
+		text += "\n" + notification.URL
+	}
+	return text
+}
+
+// printerDisplayName returns the configured name of a printer, falling back to its ID
+func (b *FilamentBridge) printerDisplayName(printerID string) string {
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		if config, exists := snapshot.Printers[printerID]; exists && config.Name != "" {
+			return config.Name
+		}
+	}
+	return printerID
+}
+
+// notifyIfSpoolLow sends a notification when a usage update takes a spool below its low
+// stock threshold. Spools that were already low aren't notified again.
+func (b *FilamentBridge) notifyIfSpoolLow(printerName string, spoolID int, usedWeight float64) {
+	if notification := b.spoolLowNotification(printerName, spoolID, usedWeight); notification != nil {
+		b.notifier.Notify(*notification)
+	}
+}
+
+// spoolLowNotification returns the low stock notification for a usage update, or nil when
+// the spool didn't just drop below its threshold. A spool it returns one for is recorded as
+// reported, so the periodic low stock check doesn't report it again.
+func (b *FilamentBridge) spoolLowNotification(printerName string, spoolID int, usedWeight float64) *Notification {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return nil
+	}
+
+	spool, err := b.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil {
+		notificationsLog.Warn("Failed to get spool for low stock check", "spool_id", spoolID, "error", err)
+		return nil
+	}
+
+	materialDefaults, err := b.GetAllMaterialDefaults()
+	if err != nil {
+		notificationsLog.Warn("Failed to get material defaults for low stock check", "error", err)
+	}
+	threshold := lowStockThresholdOf(*spool, b.spoolLowStockField(), materialDefaults, snapshot.LowStockThreshold)
+
+	if spool.RemainingWeight >= threshold || spool.RemainingWeight+usedWeight < threshold {
+		return nil
+	}
+
+	b.recordLowStockAlert(b.spoolmanInstanceOf(printerName), spoolID, time.Now())
+	notification := spoolLowStockNotification(*spool, threshold)
+	return &notification
+}
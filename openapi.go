@@ -0,0 +1,508 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Paths of the API description and its browser
+const (
+	OpenAPIPath     = "/api/openapi.json"
+	OpenAPIDocsPath = "/api/docs"
+)
+
+// apiObject describes a JSON object by example: each value's type becomes the property's
+// schema, e.g. apiObject{"spools": []SpoolmanSpool{}}
+type apiObject map[string]interface{}
+
+// apiOperation documents one API route. The document is built from the router, so routes
+// missing here are still listed, with a summary made from their handler's name.
+type apiOperation struct {
+	Tag         string
+	Summary     string
+	Query       map[string]string // Query parameters and what they do
+	Request     interface{}       // Example of the JSON body, nil when there is none
+	Response    interface{}       // Example of the JSON response, nil for a plain message
+	Status      int               // Success status, 200 when unset
+	ContentType string            // Response content type when it isn't JSON
+}
+
+// messageResponse is what most mutating routes answer with
+var messageResponse = MessageResponse{}
+
+// spoolInstanceQuery picks the Spoolman instance of routes that take a spool ID
+var spoolInstanceQuery = map[string]string{"printer_name": "Printer whose Spoolman instance the spool is in; the main instance when omitted"}
+
+// apiOperations documents the API routes, keyed like publicRoutes, i.e. by their unversioned
+// path. The document lists their /api/v1 paths.
+var apiOperations = map[string]apiOperation{
+	// Status
+	"GET /api/status":             {Tag: "Status", Summary: "Printers, spools and mappings as shown on the dashboard", Response: PrinterStatus{}},
+	"GET /api/events":             {Tag: "Status", Summary: "Stream dashboard updates as server-sent events", ContentType: "text/event-stream"},
+	"GET /api/report":             {Tag: "Status", Summary: "Usage report", Query: map[string]string{"days": "Days covered by the report", "format": "pdf (default) or csv"}, ContentType: "application/pdf"},
+	"GET /api/stats":              {Tag: "Status", Summary: "Filament usage statistics", Query: map[string]string{"days": "Only count the last days, 0 for all time"}, Response: UsageStats{}},
+	"GET /api/logs":               {Tag: "Status", Summary: "Recent log entries", Query: map[string]string{"level": "Lowest level returned", "module": "Only entries from this module", "after_id": "Only entries after this ID"}, Response: LogsResponse{}},
+	"GET /api/logs/stream":        {Tag: "Status", Summary: "Stream log entries over a websocket"},
+	"GET /api/runout-predictions": {Tag: "Status", Summary: "Predicted mid-print spool runouts", Response: RunoutPredictionsResponse{}},
+	"GET /api/active-spools":      {Tag: "Status", Summary: "Estimated remaining weight of the spools running jobs use", Response: ActiveSpoolsResponse{}},
+
+	// Spools
+	"GET /api/spools":                          {Tag: "Spools", Summary: "All Spoolman spools", Query: map[string]string{"printer_name": "List the spools of this printer's Spoolman instance instead of the main one", "owner": "Only spools of this owner"}, Response: []SpoolmanSpool{}},
+	"GET /api/spools/color-families":           {Tag: "Spools", Summary: "Spools grouped by color family", Query: map[string]string{"material": "Only spools of this material", "family": "Only this color family"}, Response: ColorFamiliesResponse{}},
+	"POST /api/spools":                         {Tag: "Spools", Summary: "Add a received spool to Spoolman, optionally mapping it to a toolhead", Request: NewSpool{}, Response: SpoolCreatedResponse{}, Status: http.StatusCreated},
+	"GET /api/filaments":                       {Tag: "Spools", Summary: "All Spoolman filaments", Query: map[string]string{"printer_name": "List the filaments of this printer's Spoolman instance instead of the main one"}, Response: []SpoolmanFilament{}},
+	"POST /api/filaments":                      {Tag: "Spools", Summary: "Add a filament type to Spoolman, adding its vendor if needed", Request: NewFilament{}, Response: FilamentCreatedResponse{}, Status: http.StatusCreated},
+	"GET /api/vendors":                         {Tag: "Spools", Summary: "All Spoolman vendors", Query: map[string]string{"printer_name": "List the vendors of this printer's Spoolman instance instead of the main one"}, Response: []SpoolmanVendor{}},
+	"POST /api/vendors":                        {Tag: "Spools", Summary: "Add a vendor to Spoolman", Request: NewVendor{}, Response: VendorCreatedResponse{}, Status: http.StatusCreated},
+	"GET /api/barcodes":                        {Tag: "Spools", Summary: "Manufacturer barcodes mapped to filaments", Response: FilamentBarcodesResponse{}},
+	"GET /api/barcodes/:code":                  {Tag: "Spools", Summary: "Look up the filament of an EAN/UPC barcode, locally and then in the online database", Query: map[string]string{"printer_name": "Printer whose Spoolman instance the filament is matched in; the main instance when omitted"}, Response: BarcodeLookup{}},
+	"PUT /api/barcodes/:code":                  {Tag: "Spools", Summary: "Map a barcode to a Spoolman filament or to filament details", Request: FilamentBarcode{}, Response: FilamentBarcodeResponse{}},
+	"DELETE /api/barcodes/:code":               {Tag: "Spools", Summary: "Remove a barcode's mapping"},
+	"GET /api/available_spools":                {Tag: "Spools", Summary: "Spools that can be mapped to a toolhead", Query: map[string]string{"printer_name": "Printer the spool is for", "toolhead_id": "Toolhead the spool is for", "owner": "Only spools of this owner"}, Response: SpoolsResponse{}},
+	"GET /api/suggest_spools":                  {Tag: "Spools", Summary: "Suggest spools for a sliced file", Query: map[string]string{"printer": "Printer name", "file": "G-code file on the printer", "limit": "Suggestions per toolhead"}, Response: SpoolSuggestionsResponse{}},
+	"POST /api/analyze":                        {Tag: "Spools", Summary: "Analyze a G-code file on a printer, or one uploaded as the file form field with optional printer_name and flavor fields", Request: apiObject{"printer_name": "", "path": "", "flavor": ""}, Response: GcodeAnalysis{}},
+	"GET /api/spools/:id/history":              {Tag: "Spools", Summary: "Prints that used a spool", Response: SpoolHistoryResponse{}},
+	"GET /api/spools/:id/fields":               {Tag: "Spools", Summary: "A spool's editable extra fields", Query: spoolInstanceQuery, Response: SpoolFieldsResponse{}},
+	"PUT /api/spools/:id/fields":               {Tag: "Spools", Summary: "Update a spool's extra fields", Query: spoolInstanceQuery, Request: map[string]interface{}{}},
+	"PUT /api/spools/:id/owner":                {Tag: "Spools", Summary: "Set a spool's owner", Request: apiObject{"owner": "", "printer_name": ""}},
+	"PUT /api/spools/:id/low_stock_threshold":  {Tag: "Spools", Summary: "Set or clear (null) a spool's own low stock threshold in grams", Request: apiObject{"threshold": 0, "printer_name": ""}},
+	"POST /api/spools/:id/measured_weight":     {Tag: "Spools", Summary: "Compare a weighed spool with Spoolman, correcting a small drift", Query: map[string]string{"printer_name": "Use this printer's Spoolman instance instead of the main one"}, Request: apiObject{"weight": 0, "gross": false}, Response: ScaleReading{}},
+	"GET /api/scale-readings":                  {Tag: "Spools", Summary: "Measured spool weights, newest first", Query: map[string]string{"spool_id": "Only readings of this spool", "status": "Only readings with this status, e.g. flagged", "limit": "Maximum number of readings"}, Response: ScaleReadingsResponse{}},
+	"POST /api/scale-readings/:id/apply":       {Tag: "Spools", Summary: "Set a spool to a flagged reading's weight"},
+	"POST /api/scale-readings/:id/dismiss":     {Tag: "Spools", Summary: "Dismiss a flagged reading"},
+	"GET /api/spool-exposure":                  {Tag: "Spools", Summary: "How long tracked spools have been out of dry storage, most exposed first", Response: SpoolExposuresResponse{}},
+	"GET /api/spools/:id/exposure":             {Tag: "Spools", Summary: "How long a spool has been out of dry storage", Query: spoolInstanceQuery, Response: SpoolExposure{}},
+	"PUT /api/spools/:id/shared":               {Tag: "Spools", Summary: "Allow or stop allowing a spool on several toolheads at once", Request: apiObject{"shared": true, "printer_name": ""}},
+	"GET /api/shared-spools":                   {Tag: "Spools", Summary: "Spools allowed on several toolheads, with the toolheads they're on and each printer's usage", Response: SharedSpoolsResponse{}},
+	"GET /api/spools/:id/timeline":             {Tag: "Spools", Summary: "Everything that happened to a spool, newest first: assignments, moves, prints, corrections, weighings and NFC scans", Query: spoolInstanceQuery, Response: SpoolTimelineResponse{}},
+	"POST /api/spools/:id/dried":               {Tag: "Spools", Summary: "Restart a spool's exposure time after drying it", Query: spoolInstanceQuery},
+	"POST /api/spools/:id/transfer":            {Tag: "Spools", Summary: "Move filament from one spool to another", Request: SpoolTransfer{}, Response: SpoolTransferResponse{}},
+	"POST /api/spools/:id/refill":              {Tag: "Spools", Summary: "Replace an empty spool with a new one of the same filament", Request: SpoolRefill{}, Response: SpoolRefillResponse{}},
+	"GET /api/spools/:id/events":               {Tag: "Spools", Summary: "A spool's transfers, refills and moves", Query: spoolInstanceQuery, Response: SpoolEventsResponse{}},
+	"POST /api/spools/:id/purge":               {Tag: "Spools", Summary: "Delete FilaBridge's records of a spool", Request: spoolPurgeRequest{}, Response: PurgeResponse{}},
+	"POST /api/usage":                          {Tag: "Spools", Summary: "Record filament used outside a tracked print", Request: ManualUsage{}, Response: HistoryEntryResponse{}, Status: http.StatusCreated},
+	"GET /api/materials/defaults":              {Tag: "Spools", Summary: "Per-material defaults", Response: MaterialDefaultsListResponse{}},
+	"PUT /api/materials/defaults/:material":    {Tag: "Spools", Summary: "Save a material's defaults", Request: MaterialDefaults{}, Response: MaterialDefaultsSavedResponse{}},
+	"DELETE /api/materials/defaults/:material": {Tag: "Spools", Summary: "Delete a material's defaults"},
+	"GET /api/reservations":                    {Tag: "Spools", Summary: "Filament reserved on spools for queued jobs", Query: map[string]string{"printer_name": "Only reservations for this printer", "spool_id": "Only reservations of this spool"}, Response: SpoolReservationsResponse{}},
+	"POST /api/reservations":                   {Tag: "Spools", Summary: "Reserve filament on a spool for a queued job", Request: apiObject{"spool_id": 0, "printer_name": "", "job_name": "", "grams": 0.0}, Response: SpoolReservationResponse{}, Status: http.StatusCreated},
+	"DELETE /api/reservations/:id":             {Tag: "Spools", Summary: "Release a spool reservation"},
+
+	// Mappings
+	"POST /api/map_toolhead":      {Tag: "Mappings", Summary: "Map a spool to a toolhead, or unmap it with spool_id 0", Request: apiObject{"printer_name": "", "toolhead_id": 0, "spool_id": 0}, Response: MappingResponse{}},
+	"POST /api/map_toolheads":     {Tag: "Mappings", Summary: "Map several toolheads of a printer at once", Request: apiObject{"printer_name": "", "mappings": []ToolheadAssignment{}}, Response: MappingResponse{}},
+	"GET /api/reconcile":          {Tag: "Mappings", Summary: "Mappings that disagree with Spoolman's locations", Response: MappingMismatchesResponse{}},
+	"POST /api/reconcile/resolve": {Tag: "Mappings", Summary: "Resolve a mapping mismatch", Request: apiObject{"spool_id": 0, "use": "", "spoolman_instance": ""}},
+
+	"PUT /api/printer-groups/:name/mappings":    {Tag: "Mappings", Summary: "Map toolheads across a printer group's printers", Request: apiObject{"mappings": []GroupToolheadAssignment{}}, Response: MappingResponse{}},
+	"DELETE /api/printer-groups/:name/mappings": {Tag: "Mappings", Summary: "Unmap every toolhead in a printer group"},
+
+	// Printers
+	"GET /api/printers":                            {Tag: "Printers", Summary: "Configured printers", Response: PrintersResponse{}},
+	"POST /api/printers":                           {Tag: "Printers", Summary: "Add a printer", Request: PrinterConfig{}, Response: PrinterAddedResponse{}},
+	"PUT /api/printers/:id":                        {Tag: "Printers", Summary: "Update a printer", Request: PrinterConfig{}},
+	"DELETE /api/printers/:id":                     {Tag: "Printers", Summary: "Delete a printer"},
+	"GET /api/printers/:id/toolheads":              {Tag: "Printers", Summary: "A printer's toolhead names", Response: ToolheadNamesResponse{}},
+	"PUT /api/printers/:id/toolheads/:toolhead_id": {Tag: "Printers", Summary: "Rename a toolhead", Request: apiObject{"name": ""}},
+	"GET /api/printers/:id/toolhead-rules":         {Tag: "Printers", Summary: "A printer's toolhead rules: allowed materials and nozzle type and size", Response: ToolheadRulesResponse{}},
+	"PUT /api/printers/:id/toolhead-rules":         {Tag: "Printers", Summary: "Replace a printer's toolhead rules", Request: apiObject{"rules": []ToolheadRule{}}},
+	"POST /api/printers/:id/rotate-key":            {Tag: "Printers", Summary: "Change or verify a printer's PrusaLink API key", Request: apiObject{"api_key": "", "verify_only": false}, Response: APIKeyRotationResponse{}},
+	"POST /api/printers/:id/purge":                 {Tag: "Printers", Summary: "Delete FilaBridge's records of a printer", Request: purgeRequest{}, Response: PurgeResponse{}},
+	"POST /api/printers/:id/actions/:action":       {Tag: "Printers", Summary: "Pause, resume or stop a printer's job, or turn off its heaters (cooldown)", Response: MessageResponse{}},
+	"GET /api/printers/:id/events":                 {Tag: "Printers", Summary: "A printer's configuration and control events", Response: PrinterEventsResponse{}},
+	"POST /api/printers/:id/webhook-secret":        {Tag: "Printers", Summary: "Create or replace a printer's print event webhook secret", Response: WebhookSecretResponse{}},
+	"DELETE /api/printers/:id/webhook-secret":      {Tag: "Printers", Summary: "Remove a printer's print event webhook secret"},
+	"GET /api/printers/:id/maintenance-windows":    {Tag: "Printers", Summary: "A printer's maintenance windows", Response: MaintenanceWindowsResponse{}},
+	"PUT /api/printers/:id/maintenance-windows":    {Tag: "Printers", Summary: "Replace a printer's maintenance windows", Request: apiObject{"windows": []MaintenanceWindow{}}},
+	"GET /api/printer-groups":                      {Tag: "Printers", Summary: "Farm view: printer groups with their printers' state and toolheads, and the printers in no group", Response: PrinterGroupsResponse{}},
+	"GET /api/printer-groups/:name":                {Tag: "Printers", Summary: "A printer group's printers, state and toolheads", Response: PrinterGroup{}},
+	"GET /api/printer-groups/:name/stats":          {Tag: "Printers", Summary: "Filament usage statistics of a printer group's printers", Query: map[string]string{"days": "Only count the last days, 0 for all time"}, Response: UsageStats{}},
+	"POST /api/detect_printer":                     {Tag: "Printers", Summary: "Identify a PrusaLink printer", Request: apiObject{"ip_address": "", "api_key": "", "insecure_skip_verify": false}, Response: DetectedPrinterResponse{}},
+	"GET /api/discover_printers":                   {Tag: "Printers", Summary: "Scan a subnet for PrusaLink printers", Query: map[string]string{"subnet": "CIDR to scan, e.g. 192.168.1.0/24"}, Response: DiscoveredPrintersResponse{}},
+	"POST /api/webhooks/print-event":               {Tag: "Printers", Summary: "Report a print starting, finishing or being cancelled", Query: map[string]string{"secret": "Webhook secret, when it can't be sent in the " + WebhookSecretHeader + " header"}, Request: PrintEvent{}},
+
+	// History
+	"GET /api/history":                 {Tag: "History", Summary: "Print history", Query: map[string]string{"printer_name": "Only prints on this printer", "limit": "Most entries returned"}, Response: PrintHistoryResponse{}},
+	"GET /api/history/export":          {Tag: "History", Summary: "Export print history", Query: map[string]string{"format": "csv (default) or json", "from": "Earliest date", "to": "Latest date", "printer_name": "Only prints on this printer"}, ContentType: "text/csv"},
+	"PUT /api/history/:id":             {Tag: "History", Summary: "Annotate a print", Request: apiObject{"notes": "", "rating": 0}, Response: HistoryEntryResponse{}},
+	"PATCH /api/history/:id":           {Tag: "History", Summary: "Correct a print's recorded usage", Request: HistoryAdjustment{}, Response: HistoryEntryResponse{}},
+	"POST /api/history/:id/revert":     {Tag: "History", Summary: "Give a print's filament back to its spools", Request: apiObject{"reason": ""}, Response: HistoryEntryResponse{}},
+	"POST /api/history/:id/reassign":   {Tag: "History", Summary: "Move a print's usage to the spool it was really printed with", Request: apiObject{"spool_id": 0, "reason": ""}, Response: HistoryEntryResponse{}},
+	"GET /api/history/:id/corrections": {Tag: "History", Summary: "Corrections made to a print", Response: HistoryCorrectionsResponse{}},
+	"POST /api/test/print_complete":    {Tag: "History", Summary: "Simulate a finished print", Request: apiObject{"printer_name": "", "job_name": "", "filament_usage": map[string]float64{}, "filament_lengths": map[string]float64{}}, Response: SimulatedPrintResponse{}},
+
+	// Print errors
+	"GET /api/print-errors":                  {Tag: "Print errors", Summary: "Prints that couldn't be processed", Response: PrintErrorsResponse{}},
+	"POST /api/print-errors/:id/acknowledge": {Tag: "Print errors", Summary: "Acknowledge a print error"},
+	"POST /api/print-errors/:id/retry":       {Tag: "Print errors", Summary: "Process a failed print again"},
+	"POST /api/print-errors/:id/resolve":     {Tag: "Print errors", Summary: "Mark a print error as resolved"},
+	"GET /api/pending_updates":               {Tag: "Print errors", Summary: "Spoolman updates waiting to be retried", Response: PendingUpdatesResponse{}},
+	"DELETE /api/pending_updates/:id":        {Tag: "Print errors", Summary: "Discard a pending Spoolman update"},
+	"GET /api/filament-runouts":              {Tag: "Print errors", Summary: "Prints stopped for attention, with the spools that may have run out", Response: FilamentRunoutsResponse{}},
+	"POST /api/filament-runouts/:id/confirm": {Tag: "Print errors", Summary: "Archive a runout's spool as empty and unmap it", Response: FilamentRunoutResponse{}},
+	"POST /api/filament-runouts/:id/dismiss": {Tag: "Print errors", Summary: "Record that a runout's spool isn't empty"},
+
+	"GET /api/unattributed-usage":             {Tag: "Print errors", Summary: "Usage of toolheads that had no spool mapped", Response: UnattributedUsageResponse{}},
+	"POST /api/unattributed-usage/:id/assign": {Tag: "Print errors", Summary: "Charge unattributed usage to the spool that was loaded", Request: apiObject{"spool_id": 0}},
+	"DELETE /api/unattributed-usage/:id":      {Tag: "Print errors", Summary: "Discard unattributed usage"},
+
+	// Locations
+	"GET /api/locations":              {Tag: "Locations", Summary: "Spoolman locations and printer toolheads", Response: LocationsResponse{}},
+	"GET /api/locations/:name/status": {Tag: "Locations", Summary: "A Spoolman location", Response: LocationResponse{}},
+	"POST /api/locations":             {Tag: "Locations", Summary: "Create a location", Request: apiObject{"name": "", "type": "", "printer_name": "", "toolhead_id": 0}, Response: LocationResponse{}, Status: http.StatusCreated},
+	"PUT /api/locations/:name":        {Tag: "Locations", Summary: "Rename a location", Request: apiObject{"name": ""}, Response: LocationUpdatedResponse{}},
+	"DELETE /api/locations/:name":     {Tag: "Locations", Summary: "Archive a location"},
+
+	"GET /api/locations/:name/environment":  {Tag: "Locations", Summary: "A location's drybox flag, latest humidity and temperature, and recent readings", Query: map[string]string{"hours": "Hours of readings returned, 24 by default"}, Response: LocationEnvironmentResponse{}},
+	"POST /api/locations/:name/environment": {Tag: "Locations", Summary: "Record a humidity and/or temperature reading of a location", Request: apiObject{"humidity": 0, "temperature": 0}},
+	"PUT /api/locations/:name/environment":  {Tag: "Locations", Summary: "Mark a location as a drybox or not", Request: apiObject{"drybox": false}},
+
+	// NFC
+	"GET /api/nfc/assign":         {Tag: "NFC", Summary: "Scan target that assigns a spool to a location", Query: map[string]string{"spool": "Spool ID", "code": "Short code instead of a spool ID, or a manufacturer barcode to add a spool from", "location": "Location name", "token": "API token, for tags when access control is on"}, ContentType: "text/html"},
+	"POST /api/nfc/barcode":       {Tag: "NFC", Summary: "Add a spool from the form shown for a scanned barcode, then continue the scan session with it", ContentType: "text/html"},
+	"GET /api/nfc/toolhead":       {Tag: "NFC", Summary: "Scan target for a toolhead", Query: map[string]string{"location": "Toolhead location name", "file": "Sliced file to suggest spools for", "token": "API token, for tags when access control is on"}, ContentType: "text/html"},
+	"GET /api/nfc/urls":           {Tag: "NFC", Summary: "URLs to write to NFC tags", Response: NFCURLsResponse{}},
+	"GET /api/nfc/labels.pdf":     {Tag: "NFC", Summary: "Printable label sheets with QR codes", Query: map[string]string{"type": "spool (default) or location", "ids": "Comma-separated spool IDs", "template": "Label sheet template", "page": "Page size for a custom layout", "label_width": "Custom label width in mm", "label_height": "Custom label height in mm", "skip": "Labels to skip on the first sheet", "outline": "true to outline each label", "printer_name": "Printer whose Spoolman instance the spools or locations come from; the main instance when omitted"}, ContentType: "application/pdf"},
+	"GET /api/nfc/ndef":           {Tag: "NFC", Summary: "NDEF message for a spool or location tag", Query: map[string]string{"spool": "Spool ID", "location": "Location name", "format": "bin for the raw message"}, Response: NDEFResponse{}},
+	"GET /api/nfc/session/status": {Tag: "NFC", Summary: "The current NFC scan session", Response: NFCSessionStatusResponse{}},
+	"DELETE /api/nfc/session":     {Tag: "NFC", Summary: "Cancel the NFC scan session"},
+	"POST /api/nfc/token/rotate":  {Tag: "NFC", Summary: "Invalidate the token embedded in NFC tags and QR codes"},
+
+	// Configuration
+	"GET /api/config":                            {Tag: "Configuration", Summary: "All settings, except credentials", Response: map[string]string{}},
+	"POST /api/config":                           {Tag: "Configuration", Summary: "Update settings", Request: map[string]string{}},
+	"GET /api/config/export":                     {Tag: "Configuration", Summary: "Export settings, printers and mappings, including printer API keys and integration secrets", Query: map[string]string{"format": "json (default) or yaml"}, Response: ConfigExport{}},
+	"GET /api/config/overrides":                  {Tag: "Configuration", Summary: "Settings set by environment variables", Response: ConfigOverridesResponse{}},
+	"POST /api/config/import":                    {Tag: "Configuration", Summary: "Import an export; printer API keys and integration secrets are imported, FilaBridge's own credentials aren't", Query: map[string]string{"format": "yaml to read a YAML body"}, Request: ConfigExport{}, Response: ConfigImportResponse{}},
+	"GET /api/config/auto-assign-previous-spool": {Tag: "Configuration", Summary: "Where replaced spools are moved", Response: AutoAssignPreviousSpoolResponse{}},
+	"PUT /api/config/auto-assign-previous-spool": {Tag: "Configuration", Summary: "Set where replaced spools are moved", Request: apiObject{"enabled": false, "location": ""}},
+	"GET /api/spoolman/test":                     {Tag: "Configuration", Summary: "Check the connection to Spoolman", Response: SpoolmanConnectionResponse{}},
+	"GET /api/spoolman/debug":                    {Tag: "Configuration", Summary: "Raw Spoolman data for troubleshooting", Response: map[string]interface{}{}},
+	"GET /api/notifications/channels":            {Tag: "Configuration", Summary: "Notification channels", Response: NotificationChannelsResponse{}},
+	"PUT /api/notifications/channels":            {Tag: "Configuration", Summary: "Replace the notification channels", Request: apiObject{"channels": []NotificationChannel{}}},
+	"GET /api/notifications/held":                {Tag: "Configuration", Summary: "Notifications held until quiet hours end", Response: HeldNotificationsResponse{}},
+	"GET /api/webhooks/outgoing":                 {Tag: "Configuration", Summary: "Outgoing lifecycle event webhooks", Response: OutgoingWebhooksResponse{}},
+	"PUT /api/webhooks/outgoing":                 {Tag: "Configuration", Summary: "Replace the outgoing webhooks", Request: apiObject{"webhooks": []OutgoingWebhook{}}},
+	"GET /api/spoolman/instances":                {Tag: "Configuration", Summary: "Additional Spoolman instances printers can be assigned to", Response: SpoolmanInstancesResponse{}},
+	"PUT /api/spoolman/instances":                {Tag: "Configuration", Summary: "Replace the additional Spoolman instances", Request: apiObject{"instances": []SpoolmanInstance{}}},
+	"GET /api/scales":                            {Tag: "Configuration", Summary: "Smart scales publishing spool weights over MQTT", Response: ScalesResponse{}},
+	"PUT /api/scales":                            {Tag: "Configuration", Summary: "Replace the smart scales", Request: apiObject{"scales": []ScaleConfig{}}},
+	"GET /api/environment-sensors":               {Tag: "Configuration", Summary: "Humidity and temperature sensors publishing over MQTT", Response: EnvironmentSensorsResponse{}},
+	"PUT /api/environment-sensors":               {Tag: "Configuration", Summary: "Replace the environment sensors", Request: apiObject{"sensors": []EnvironmentSensor{}}},
+	"GET /api/reminders/return":                  {Tag: "Configuration", Summary: "Signed link that returns an idle spool to storage", Query: map[string]string{"spool": "Spool ID", "sig": "Link signature"}, ContentType: "text/html"},
+	"GET /api/scheduler/jobs":                    {Tag: "Configuration", Summary: "Scheduled background jobs", Response: ScheduledJobsResponse{}},
+	"PUT /api/scheduler/jobs/:name":              {Tag: "Configuration", Summary: "Enable, disable or reschedule a job", Request: ScheduledJobSettings{}},
+	"POST /api/scheduler/jobs/:name/run":         {Tag: "Configuration", Summary: "Run a scheduled job now", Status: http.StatusAccepted},
+	"GET /api/backup":                            {Tag: "Configuration", Summary: "Download a database backup", ContentType: "application/octet-stream"},
+	"POST /api/restore":                          {Tag: "Configuration", Summary: "Restore a database backup uploaded as the file form field", Response: RestoreResponse{}},
+	"GET /api/fixtures":                          {Tag: "Configuration", Summary: "Demo data currently loaded", Response: FixturesResponse{}},
+	"POST /api/fixtures":                         {Tag: "Configuration", Summary: "Generate demo data", Request: FixtureRequest{}, Response: FixturesResponse{}, Status: http.StatusCreated},
+	"DELETE /api/fixtures":                       {Tag: "Configuration", Summary: "Delete demo data", Response: FixturesDeletedResponse{}},
+
+	// Access
+	"GET /api/auth/status":      {Tag: "Access", Summary: "Whether access control is on, and the caller's role", Response: AuthStatusResponse{}},
+	"POST /api/auth/login":      {Tag: "Access", Summary: "Log in to the web interface", Request: apiObject{"username": "", "password": ""}},
+	"POST /api/auth/logout":     {Tag: "Access", Summary: "Log out of the web interface"},
+	"PUT /api/auth/credentials": {Tag: "Access", Summary: "Set the web interface login, or turn it off with an empty password", Request: apiObject{"username": "", "password": ""}},
+	"POST /api/admin-token":     {Tag: "Access", Summary: "Issue a new admin token, turning access control on", Response: AdminTokenResponse{}},
+	"GET /api/tokens":           {Tag: "Access", Summary: "Named API tokens", Response: APITokensResponse{}},
+	"POST /api/tokens":          {Tag: "Access", Summary: "Create an API token; the token is only returned once", Request: apiObject{"name": "", "role": ""}, Response: APITokenCreatedResponse{}, Status: http.StatusCreated},
+	"DELETE /api/tokens/:id":    {Tag: "Access", Summary: "Revoke an API token"},
+	"GET /api/members":          {Tag: "Access", Summary: "Makerspace members", Response: MembersResponse{}},
+	"POST /api/members":         {Tag: "Access", Summary: "Add a member and issue their token", Request: apiObject{"name": ""}, Response: MemberCreatedResponse{}, Status: http.StatusCreated},
+	"DELETE /api/members/:id":   {Tag: "Access", Summary: "Remove a member"},
+	"GET " + OpenAPIPath:        {Tag: "Access", Summary: "This API description", Response: map[string]interface{}{}},
+	"GET " + OpenAPIDocsPath:    {Tag: "Access", Summary: "Browse this API description", ContentType: "text/html"},
+}
+
+// apiTags orders the operation groups in the document
+var apiTags = []string{"Status", "Spools", "Mappings", "Printers", "History", "Print errors", "Locations", "NFC", "Configuration", "Access"}
+
+// routeParamPattern matches gin path parameters, e.g. :id
+var routeParamPattern = regexp.MustCompile(`[:*](\w+)`)
+
+// openAPISchemas builds JSON schemas from Go types, collecting named structs as components
+type openAPISchemas struct {
+	components map[string]interface{}
+}
+
+// schemaFor returns the schema of a value's type
+func (s *openAPISchemas) schemaFor(value interface{}) map[string]interface{} {
+	if object, ok := value.(apiObject); ok {
+		properties := make(map[string]interface{}, len(object))
+		for name, example := range object {
+			properties[name] = s.schemaFor(example)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return s.schemaOf(reflect.TypeOf(value))
+}
+
+// schemaOf returns the schema of a type; named structs are referenced, not inlined
+func (s *openAPISchemas) schemaOf(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.schemaOf(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return schema
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		if _, exists := s.components[t.Name()]; !exists {
+			s.components[t.Name()] = map[string]interface{}{} // Placeholder for types that refer to themselves
+			s.components[t.Name()] = s.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema lists a struct's JSON fields as properties
+func (s *openAPISchemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if embedded := field.Type; field.Anonymous && name == "" {
+			// Embedded structs' fields are encoded as the outer struct's own
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, property := range s.structSchema(embedded)["properties"].(map[string]interface{}) {
+					properties[name] = property
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schemaOf(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") && !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// handlerOperationID turns a handler name such as main.(*WebServer).getConfigHandler-fm into
+// getConfig
+func handlerOperationID(handler string) string {
+	name := handler[strings.LastIndex(handler, ".")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	return strings.TrimSuffix(name, "Handler")
+}
+
+// operationSummary makes a summary from an operation ID for undocumented routes, e.g.
+// getConfig becomes "Get config"
+func operationSummary(operationID string) string {
+	var words []string
+	start := 0
+	for i, r := range operationID {
+		if i > 0 && unicode.IsUpper(r) {
+			words = append(words, strings.ToLower(operationID[start:i]))
+			start = i
+		}
+	}
+	words = append(words, strings.ToLower(operationID[start:]))
+	summary := strings.Join(words, " ")
+	if summary == "" {
+		return summary
+	}
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// buildOpenAPI describes the API routes registered on the router
+func (ws *WebServer) buildOpenAPI(serverURL string) map[string]interface{} {
+	schemas := &openAPISchemas{components: map[string]interface{}{}}
+	schemas.components["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{"type": "string"},
+			"code":  map[string]interface{}{"type": "string"},
+		},
+	}
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+			},
+		}
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range ws.router.Routes() {
+		if !strings.HasPrefix(route.Path, APIV1Prefix+"/") {
+			continue
+		}
+		key := route.Method + " " + LegacyAPIPrefix + strings.TrimPrefix(route.Path, APIV1Prefix)
+		doc, documented := apiOperations[key]
+		operationID := handlerOperationID(route.Handler)
+		if !documented {
+			doc.Tag = operationSummary(strings.Split(strings.TrimPrefix(route.Path, APIV1Prefix+"/"), "/")[0])
+			doc.Summary = operationSummary(operationID)
+		}
+
+		operation := map[string]interface{}{
+			"operationId": operationID,
+			"summary":     doc.Summary,
+			"tags":        []string{doc.Tag},
+		}
+
+		var parameters []interface{}
+		for _, match := range routeParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		queryNames := make([]string, 0, len(doc.Query))
+		for name := range doc.Query {
+			queryNames = append(queryNames, name)
+		}
+		sort.Strings(queryNames)
+		for _, name := range queryNames {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "query", "description": doc.Query[name], "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if doc.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.schemaFor(doc.Request)},
+				},
+			}
+		}
+
+		success := map[string]interface{}{"description": "Success"}
+		switch {
+		case doc.ContentType != "":
+			success["content"] = map[string]interface{}{doc.ContentType: map[string]interface{}{}}
+		case doc.Response != nil:
+			success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.schemaFor(doc.Response)}}
+		default:
+			success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.schemaFor(messageResponse)}}
+		}
+		status := doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default":            errorResponse("Error"),
+		}
+
+		switch {
+		case publicRoutes[key]:
+			operation["security"] = []interface{}{}
+		case scanRoutes[key]:
+			responses["401"] = errorResponse("Access control is on and no valid token was sent")
+		default:
+			responses["401"] = errorResponse("Access control is on and no valid credentials were sent")
+			responses["403"] = errorResponse("The caller's role doesn't allow this")
+		}
+		operation["responses"] = responses
+
+		path := routeParamPattern.ReplaceAllString(route.Path, "{$1}")
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	tags := make([]interface{}, 0, len(apiTags))
+	for _, tag := range apiTags {
+		tags = append(tags, map[string]interface{}{"name": tag})
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title": "FilaBridge API",
+			"description": "Tracks filament used by Prusa printers in Spoolman. Access control is off until an admin token is issued; " +
+				"after that, send a token as a bearer token or in the X-Api-Token header. Errors share one shape, with a stable code. " +
+				"The same routes are served without /v1 for one more release; those responses carry a Deprecation header.",
+			"version": "1",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": serverURL}},
+		"tags":    tags,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerToken":    map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"apiTokenHeader": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-Api-Token"},
+				"sessionCookie":  map[string]interface{}{"type": "apiKey", "in": "cookie", "name": AuthSessionCookie},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearerToken": []string{}},
+			map[string]interface{}{"apiTokenHeader": []string{}},
+			map[string]interface{}{"sessionCookie": []string{}},
+		},
+	}
+}
+
+// openAPIHandler serves the API description, for code generators and tools like Postman
+func (ws *WebServer) openAPIHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ws.buildOpenAPI(ws.requestBaseURL(c)))
+}
+
+// openAPIDocsHandler serves a Swagger UI page for the API description
+func (ws *WebServer) openAPIDocsHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "api_docs.html", gin.H{"SpecURL": ws.path(v1Path(OpenAPIPath))})
+}
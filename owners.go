@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Special values of the owner filter on spool listings
+const (
+	OwnerFilterNone = "none" // Spools without an owner
+	OwnerFilterMe   = "me"   // Spools owned by the calling member
+)
+
+// spoolOwnerField returns the Spoolman extra field key that holds spool owners
+func (b *FilamentBridge) spoolOwnerField() string {
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil && snapshot.SpoolOwnerField != "" {
+		return snapshot.SpoolOwnerField
+	}
+	return DefaultSpoolOwnerField
+}
+
+// spoolOwner returns the owner stored in a spool's extra fields, or an empty string
+func spoolOwner(spool SpoolmanSpool, field string) string {
+	encoded, ok := spool.Extra[field].(string)
+	if !ok {
+		return ""
+	}
+	var owner string
+	if err := json.Unmarshal([]byte(encoded), &owner); err != nil {
+		return strings.TrimSpace(encoded)
+	}
+	return strings.TrimSpace(owner)
+}
+
+// annotateSpoolOwners fills in the owner of each spool and links owners that name a member
+func (b *FilamentBridge) annotateSpoolOwners(spools []SpoolmanSpool) {
+	field := b.spoolOwnerField()
+
+	memberIDs := make(map[string]int)
+	if members, err := b.GetMembers(); err != nil {
+		spoolmanLog.Warn("Failed to get members for spool owners", "error", err)
+	} else {
+		for _, member := range members {
+			memberIDs[strings.ToLower(member.Name)] = member.ID
+		}
+	}
+
+	for i := range spools {
+		spools[i].Owner = spoolOwner(spools[i], field)
+		spools[i].OwnerMemberID = memberIDs[strings.ToLower(spools[i].Owner)]
+	}
+}
+
+// filterSpoolsByOwner keeps spools owned by owner (case-insensitive), or unowned spools for "none"
+func filterSpoolsByOwner(spools []SpoolmanSpool, owner string) []SpoolmanSpool {
+	filtered := make([]SpoolmanSpool, 0, len(spools))
+	for _, spool := range spools {
+		if strings.EqualFold(owner, OwnerFilterNone) {
+			if spool.Owner == "" {
+				filtered = append(filtered, spool)
+			}
+		} else if strings.EqualFold(spool.Owner, owner) {
+			filtered = append(filtered, spool)
+		}
+	}
+	return filtered
+}
+
+// SetSpoolOwner stores the owner of a spool in the printer's Spoolman instance, the main one
+// when printerName is empty; an empty owner clears it. The owner extra field is defined in
+// Spoolman on first use.
+func (b *FilamentBridge) SetSpoolOwner(printerName string, spoolID int, owner string) error {
+	field := b.spoolOwnerField()
+	owner = strings.TrimSpace(owner)
+
+	if owner == "" {
+		return b.updateSpoolExtra(printerName, spoolID, map[string]interface{}{field: nil})
+	}
+
+	if err := b.ensureSpoolField(printerName, field, "Owner", "text"); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(owner)
+	if err != nil {
+		return fmt.Errorf("failed to encode owner: %w", err)
+	}
+	return b.updateSpoolExtra(printerName, spoolID, map[string]interface{}{field: string(encoded)})
+}
+
+// ensureSpoolField creates a spool extra field in the printer's Spoolman instance if it isn't
+// defined there yet
+func (b *FilamentBridge) ensureSpoolField(printerName, field, name, fieldType string) error {
+	spoolman := b.spoolmanFor(printerName)
+	fields, err := spoolman.GetSpoolFields()
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spool fields: %v", err)
+	}
+	for _, definition := range fields {
+		if definition.Key == field {
+			return nil
+		}
+	}
+
+	if err := spoolman.CreateSpoolField(field, name, fieldType); err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to create spool field %s: %v", field, err)
+	}
+	spoolmanLog.Info("Created spool field in Spoolman", "field", field, "name", name, "spoolman_instance", b.spoolmanInstanceOf(printerName))
+	return nil
+}
+
+// ownerMismatchWarning returns a warning when a member maps a spool owned by someone else to
+// a printer, or an empty string. Admins and open installs are not warned since the caller is
+// unknown.
+func (b *FilamentBridge) ownerMismatchWarning(printerName string, spoolID int, member *Member) string {
+	if member == nil {
+		return ""
+	}
+
+	spool, err := b.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil {
+		spoolmanLog.Warn("Failed to get spool to check its owner", "spool_id", spoolID, "error", err)
+		return ""
+	}
+
+	owner := spoolOwner(*spool, b.spoolOwnerField())
+	if owner == "" || strings.EqualFold(owner, member.Name) {
+		return ""
+	}
+	return fmt.Sprintf("Spool %d belongs to %s", spoolID, owner)
+}
+
+// setSpoolOwnerHandler sets or clears the owner of a spool
+func (ws *WebServer) setSpoolOwnerHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var req struct {
+		Owner       string `json:"owner"`
+		PrinterName string `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.SetSpoolOwner(req.PrinterName, spoolID, req.Owner); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spool owner updated successfully"})
+}
+
+// ownerFilter resolves the owner query parameter, mapping "me" to the calling member.
+// It returns false and responds with an error if "me" is used without a member token.
+func ownerFilter(c *gin.Context) (string, bool) {
+	owner := strings.TrimSpace(c.Query("owner"))
+	if !strings.EqualFold(owner, OwnerFilterMe) {
+		return owner, true
+	}
+	member := callerMember(c)
+	if member == nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "owner=me requires a member token")
+		return "", false
+	}
+	return member.Name, true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+)
+
+// mmToPt converts millimetres to PDF points
+const mmToPt = 72 / 25.4
+
+// Standard fonts every PDF reader provides, so nothing has to be embedded
+const (
+	pdfFontRegular = "F1" // Helvetica
+	pdfFontBold    = "F2" // Helvetica-Bold
+)
+
+// pdfDocument builds a simple PDF of vector drawing and standard-font text. Coordinates are
+// in millimetres from the top-left corner of the page.
+type pdfDocument struct {
+	width, height float64 // Page size in mm
+	pages         []*bytes.Buffer
+}
+
+// newPDFDocument starts a document with pages of the given size in mm
+func newPDFDocument(width, height float64) *pdfDocument {
+	return &pdfDocument{width: width, height: height}
+}
+
+// addPage starts a new page; drawing calls go to the last page added
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// page returns the content stream of the current page
+func (d *pdfDocument) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// setFillColor sets the fill color for following rectangles and text
+func (d *pdfDocument) setFillColor(r, g, b uint8) {
+	fmt.Fprintf(d.page(), "%.3f %.3f %.3f rg\n", float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+// rect adds a rectangle with its top-left corner at x, y; call fill to paint the rectangles added
+func (d *pdfDocument) rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "%.2f %.2f %.2f %.2f re\n", x*mmToPt, (d.height-y-h)*mmToPt, w*mmToPt, h*mmToPt)
+}
+
+// fill paints the rectangles added since the last fill
+func (d *pdfDocument) fill() {
+	d.page().WriteString("f\n")
+}
+
+// strokeRect outlines a rectangle with a thin grey line
+func (d *pdfDocument) strokeRect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "q 0.6 G 0.3 w %.2f %.2f %.2f %.2f re S Q\n", x*mmToPt, (d.height-y-h)*mmToPt, w*mmToPt, h*mmToPt)
+}
+
+// text writes a line of text with its baseline at x, y. size is in points.
+func (d *pdfDocument) text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x*mmToPt, (d.height-y)*mmToPt, pdfEscape(s))
+}
+
+// bytes renders the document
+func (d *pdfDocument) bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then takes two objects
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			d.width*mmToPt, d.height*mmToPt, pdfFontRegular, pdfFontBold, 6+i*2))
+
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		if _, err := writer.Write(content.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to compress page: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress page: %w", err)
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
+
+// pdfEscape encodes text for a PDF string in WinAnsiEncoding, replacing characters the
+// standard fonts can't show
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth approximates the width in mm of Helvetica text at a size in points
+func pdfTextWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.55 / mmToPt
+}
+
+// pdfFitText shortens text with an ellipsis to fit a width in mm
+func pdfFitText(s string, size, width float64) string {
+	if pdfTextWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
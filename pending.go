@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PendingUpdate is print usage that couldn't be written to Spoolman, waiting to be retried
+type PendingUpdate struct {
+	ID             int       `json:"id"`
+	PrinterName    string    `json:"printer_name"`
+	ToolheadID     int       `json:"toolhead_id"`
+	SpoolID        int       `json:"spool_id"`
+	FilamentUsed   float64   `json:"filament_used"`
+	Waste          float64   `json:"waste"`
+	JobName        string    `json:"job_name"`
+	JobDisplayName string    `json:"job_display_name"`
+	Source         string    `json:"source"`
+	Member         string    `json:"member"`
+	Attempts       int       `json:"attempts"`
+	LastError      string    `json:"last_error"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	CreatedAt      time.Time `json:"created_at"` // When the print finished
+}
+
+const pendingUpdateColumns = "id, printer_name, toolhead_id, spool_id, filament_used, COALESCE(waste, 0), COALESCE(job_name, ''), " +
+	"COALESCE(job_display_name, ''), COALESCE(source, ''), COALESCE(member, ''), attempts, COALESCE(last_error, ''), next_attempt_at, created_at"
+
+// pendingUpdateBackoff is how long to wait after a number of failed attempts, doubling up to
+// PendingUpdateMaxBackoff
+func pendingUpdateBackoff(attempts int) time.Duration {
+	backoff := PendingUpdateInitialBackoff
+	for i := 1; i < attempts && backoff < PendingUpdateMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > PendingUpdateMaxBackoff {
+		backoff = PendingUpdateMaxBackoff
+	}
+	return backoff
+}
+
+// queueSpoolUpdate stores usage Spoolman rejected or couldn't be reached for, so the retry job
+// applies it later instead of it being lost
+func (b *FilamentBridge) queueSpoolUpdate(update PendingUpdate, cause error) error {
+	now := time.Now()
+	_, err := b.db.Exec(
+		"INSERT INTO pending_updates (printer_name, toolhead_id, spool_id, filament_used, waste, job_name, job_display_name, source, member, attempts, last_error, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?)",
+		update.PrinterName, update.ToolheadID, update.SpoolID, update.FilamentUsed, update.Waste, update.JobName, update.JobDisplayName,
+		update.Source, update.Member, cause.Error(), now.Add(pendingUpdateBackoff(1)), now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to queue spool update: %w", err)
+	}
+	return nil
+}
+
+// GetPendingUpdates returns the queued Spoolman updates, oldest first
+func (b *FilamentBridge) GetPendingUpdates() ([]PendingUpdate, error) {
+	return b.queryPendingUpdates("SELECT " + pendingUpdateColumns + " FROM pending_updates ORDER BY id")
+}
+
+func (b *FilamentBridge) queryPendingUpdates(query string, args ...interface{}) ([]PendingUpdate, error) {
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending updates: %w", err)
+	}
+	defer rows.Close()
+
+	updates := []PendingUpdate{}
+	for rows.Next() {
+		var update PendingUpdate
+		if err := rows.Scan(&update.ID, &update.PrinterName, &update.ToolheadID, &update.SpoolID, &update.FilamentUsed, &update.Waste,
+			&update.JobName, &update.JobDisplayName, &update.Source, &update.Member, &update.Attempts, &update.LastError,
+			&update.NextAttemptAt, &update.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending update: %w", err)
+		}
+		updates = append(updates, update)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get pending updates: %w", err)
+	}
+	return updates, nil
+}
+
+// DeletePendingUpdate discards a queued update without applying it
+func (b *FilamentBridge) DeletePendingUpdate(id int) error {
+	result, err := b.db.Exec("DELETE FROM pending_updates WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete pending update: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodeNotFound, "pending update %d not found", id)
+	}
+	return nil
+}
+
+// retryPendingUpdates is the scheduled retry, applying every queued update that is due. An
+// update that keeps failing is given up on after PendingUpdateMaxAttempts and reported as a
+// print error, which can be retried once Spoolman is back or resolved by hand.
+func (b *FilamentBridge) retryPendingUpdates() error {
+	updates, err := b.queryPendingUpdates("SELECT "+pendingUpdateColumns+" FROM pending_updates WHERE next_attempt_at <= ? ORDER BY id", time.Now())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, update := range updates {
+		if err := b.applyPendingUpdate(update); err != nil {
+			failed++
+			b.pendingUpdateFailed(update, err)
+			continue
+		}
+		if _, err := b.db.Exec("DELETE FROM pending_updates WHERE id = ?", update.ID); err != nil {
+			// Spoolman has the usage now; leaving the row would apply it twice
+			bridgeLog.Error("Failed to remove applied pending update", "id", update.ID, "error", err)
+		}
+		bridgeLog.Info("Applied queued spool usage", "printer", update.PrinterName, "job", update.JobName,
+			"spool_id", update.SpoolID, "grams", update.FilamentUsed, "attempts", update.Attempts+1)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queued spool updates failed", failed, len(updates))
+	}
+	return nil
+}
+
+// applyPendingUpdate writes an update's usage to Spoolman and records it the way a print that
+// went through at once would have been. Only the Spoolman error is returned; once the usage is
+// applied, the rest is logged so the update isn't applied again.
+func (b *FilamentBridge) applyPendingUpdate(update PendingUpdate) error {
+	if err := b.spoolmanFor(update.PrinterName).UpdateSpoolUsage(update.SpoolID, update.FilamentUsed); err != nil {
+		return err
+	}
+
+	if err := b.logPendingUpdate(update); err != nil {
+		bridgeLog.Error("Error logging print usage", "printer", update.PrinterName, "job", update.JobName, "spool_id", update.SpoolID, "error", err)
+	}
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil && snapshot.WasteSpoolField != "" && update.Waste > 0 {
+		if err := b.addSpoolWaste(update.PrinterName, update.SpoolID, snapshot.WasteSpoolField, update.Waste); err != nil {
+			bridgeLog.Warn("Error recording spool purge waste", "spool_id", update.SpoolID, "field", snapshot.WasteSpoolField, "error", err)
+		}
+	}
+	b.notifyIfSpoolLow(update.PrinterName, update.SpoolID, update.FilamentUsed)
+	b.handleSpoolEmptied(update.PrinterName, update.ToolheadID, update.SpoolID, update.FilamentUsed)
+	b.emitSpoolDeducted(update.PrinterName, update.ToolheadID, update.SpoolID, update.FilamentUsed, update.JobName, update.JobDisplayName, update.Source)
+	return nil
+}
+
+// pendingUpdateFailed schedules the next attempt of an update, or gives up on it
+func (b *FilamentBridge) pendingUpdateFailed(update PendingUpdate, cause error) {
+	attempts := update.Attempts + 1
+	if attempts >= PendingUpdateMaxAttempts {
+		if err := b.DeletePendingUpdate(update.ID); err != nil {
+			bridgeLog.Error("Failed to remove abandoned pending update", "id", update.ID, "error", err)
+		}
+		bridgeLog.Error("Giving up on queued spool usage", "printer", update.PrinterName, "job", update.JobName,
+			"spool_id", update.SpoolID, "grams", update.FilamentUsed, "attempts", attempts, "error", cause)
+		b.addRetryablePrintError(update.PrinterName, update.JobName, update.JobDisplayName, fmt.Sprintf(
+			"Failed to update spool %d with %.1fg after %d attempts; retry or update it manually in Spoolman: %v",
+			update.SpoolID, update.FilamentUsed, attempts, cause), &printErrorRetry{Update: &update})
+		return
+	}
+
+	next := time.Now().Add(pendingUpdateBackoff(attempts))
+	if _, err := b.db.Exec(
+		"UPDATE pending_updates SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		attempts, cause.Error(), next, update.ID,
+	); err != nil {
+		bridgeLog.Error("Failed to reschedule pending update", "id", update.ID, "error", err)
+		return
+	}
+	bridgeLog.Warn("Queued spool usage still failing", "spool_id", update.SpoolID, "attempts", attempts, "next_attempt_at", next, "error", cause)
+}
+
+// logPendingUpdate records an applied update in the print history, dated when the print
+// finished rather than when Spoolman came back
+func (b *FilamentBridge) logPendingUpdate(update PendingUpdate) error {
+	cost := b.usageCost(update.PrinterName, update.SpoolID, update.FilamentUsed)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	_, err := b.db.Exec(
+		"INSERT INTO print_history (printer_name, toolhead_id, spool_id, filament_used, print_started, print_finished, job_name, job_display_name, member, source, waste, cost) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		update.PrinterName, update.ToolheadID, update.SpoolID, update.FilamentUsed, update.CreatedAt, update.CreatedAt,
+		update.JobName, displayFilename(update.JobName, update.JobDisplayName), update.Member, update.Source, update.Waste, cost,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to log print usage: %w", err)
+	}
+	return nil
+}
+
+// getPendingUpdatesHandler lists usage waiting to be written to Spoolman
+func (ws *WebServer) getPendingUpdatesHandler(c *gin.Context) {
+	updates, err := ws.bridge.GetPendingUpdates()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, PendingUpdatesResponse{PendingUpdates: updates})
+}
+
+// deletePendingUpdateHandler discards a queued update, for usage entered in Spoolman by hand
+func (ws *WebServer) deletePendingUpdateHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid pending update ID")
+		return
+	}
+
+	if err := ws.bridge.DeletePendingUpdate(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Pending update discarded"})
+}
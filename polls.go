@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+)
+
+// printerPollTiming is when a printer's monitor last checked it and when it checks next
+type printerPollTiming struct {
+	LastPoll           time.Time
+	LastSuccessfulPoll time.Time
+	NextPoll           time.Time
+}
+
+// recordPoll stores the result of a monitor check of a printer
+func (b *FilamentBridge) recordPoll(printerID string, success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	timing := b.pollTiming[printerID]
+	timing.LastPoll = time.Now()
+	if success {
+		timing.LastSuccessfulPoll = timing.LastPoll
+	}
+	b.pollTiming[printerID] = timing
+}
+
+// recordNextPoll stores when a printer's monitor will check it next
+func (b *FilamentBridge) recordNextPoll(printerID string, next time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	timing := b.pollTiming[printerID]
+	timing.NextPoll = next
+	b.pollTiming[printerID] = timing
+}
+
+// applyPollTiming fills in the poll fields of a printer's status
+func (b *FilamentBridge) applyPollTiming(printerID string, snapshot *Config, data *PrinterData) {
+	b.mutex.RLock()
+	timing, exists := b.pollTiming[printerID]
+	printing := b.wasPrinting[printerID]
+	b.mutex.RUnlock()
+
+	data.PollIntervalSeconds = snapshot.Printers[printerID].pollInterval(snapshot, printing).Seconds()
+
+	if !exists {
+		return
+	}
+	data.LastPollAt = optionalTime(timing.LastPoll)
+	data.LastSuccessfulPollAt = optionalTime(timing.LastSuccessfulPoll)
+	data.NextPollAt = optionalTime(timing.NextPoll)
+}
+
+// optionalTime returns nil for the zero time so it's omitted from JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
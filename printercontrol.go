@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Printer control actions
+const (
+	PrinterActionPause    = "pause"
+	PrinterActionResume   = "resume"
+	PrinterActionStop     = "stop"
+	PrinterActionCoolDown = "cooldown" // Turn off the nozzle and bed heaters
+)
+
+// printerActionLabels describes each action for messages and the printer's audit log
+var printerActionLabels = map[string]string{
+	PrinterActionPause:    "Paused",
+	PrinterActionResume:   "Resumed",
+	PrinterActionStop:     "Stopped",
+	PrinterActionCoolDown: "Turned off the heaters",
+}
+
+// ControlPrinter runs a control action on a printer through its local PrusaLink API. The
+// printer is looked up by ID or name. Job actions apply to the printer's current job and
+// fail with a conflict when there is none.
+func (b *FilamentBridge) ControlPrinter(printer, action, member string) (string, error) {
+	label, known := printerActionLabels[action]
+	if !known {
+		return "", newCodedError(ErrCodeInvalidRequest, "unknown action %q (use pause, resume, stop or cooldown)", action)
+	}
+
+	printerID, config, exists := b.findPrinterConfig(printer)
+	if !exists {
+		return "", newCodedError(ErrCodePrinterNotFound, "printer %s not found", printer)
+	}
+	printerName := resolvePrinterName(config)
+	if config.IPAddress == "" {
+		return "", newCodedError(ErrCodeInvalidRequest, "%s has no local PrusaLink address to control it", printerName)
+	}
+	client := config.prusaLinkClient(b.GetConfigSnapshot())
+
+	var notes string
+	if action == PrinterActionCoolDown {
+		if err := client.CoolDown(config.Toolheads); err != nil {
+			return "", newCodedError(ErrCodePrinterError, "failed to turn off the heaters on %s: %v", printerName, err)
+		}
+		notes = label
+	} else {
+		job, err := client.GetJobInfo()
+		if err != nil {
+			return "", newCodedError(ErrCodePrinterError, "failed to get the current job on %s: %v", printerName, err)
+		}
+		if job.ID == 0 {
+			return "", newCodedError(ErrCodeConflict, "%s has no job to %s", printerName, action)
+		}
+
+		switch action {
+		case PrinterActionPause:
+			err = client.PauseJob(job.ID)
+		case PrinterActionResume:
+			err = client.ResumeJob(job.ID)
+		case PrinterActionStop:
+			err = client.StopJob(job.ID)
+		}
+		if err != nil {
+			return "", newCodedError(ErrCodePrinterError, "failed to %s the job on %s: %v", action, printerName, err)
+		}
+		notes = fmt.Sprintf("%s job %s", label, firstNonEmpty(job.File.DisplayName, job.File.Name, fmt.Sprintf("#%d", job.ID)))
+	}
+
+	event := PrinterEvent{
+		PrinterID: printerID,
+		EventType: PrinterEventControlAction,
+		Member:    member,
+		Notes:     notes,
+		CreatedAt: time.Now(),
+	}
+	if err := b.recordPrinterEvent(event); err != nil {
+		// The printer has already acted, so only log the failure
+		bridgeLog.Error("Failed to record printer action", "printer", printerName, "action", action, "error", err)
+	}
+
+	bridgeLog.Info("Printer action", "printer", printerName, "action", action, "member", member)
+	return fmt.Sprintf("%s on %s", notes, printerName), nil
+}
+
+// controlPrinterHandler pauses, resumes or stops a printer's job or turns off its heaters, so
+// a runout can be handled from the dashboard. Members may do everything but stop a print.
+func (ws *WebServer) controlPrinterHandler(c *gin.Context) {
+	action := strings.ToLower(c.Param("action"))
+	if action == PrinterActionStop && c.GetString(contextKeyRole) == RoleMember {
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, "Stopping a print requires the admin role")
+		return
+	}
+
+	var member string
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+	message, err := ws.bridge.ControlPrinter(c.Param("id"), action, member)
+	if err != nil {
+		respondErrorFrom(c, http.StatusBadGateway, ErrCodePrinterError, err)
+		return
+	}
+
+	// The printer's new state shows up with the next poll; refresh what's known now
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: message})
+}
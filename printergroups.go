@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrinterGroupPrinter is one printer of a group in the farm view
+type PrinterGroupPrinter struct {
+	PrinterID string            `json:"printer_id"`
+	Name      string            `json:"name"`
+	Model     string            `json:"model"`
+	State     string            `json:"state"`
+	Toolheads []ToolheadMapping `json:"toolheads"` // Every toolhead in order, spool 0 when unmapped
+}
+
+// PrinterGroup is a set of printers run together, e.g. a "PLA farm" or "PETG wall", with
+// totals across its printers
+type PrinterGroup struct {
+	Name            string                `json:"name"`
+	Printers        []PrinterGroupPrinter `json:"printers"`
+	States          map[string]int        `json:"states"`           // Printers per state
+	Toolheads       int                   `json:"toolheads"`        // Toolheads or MMU slots across the group
+	MappedToolheads int                   `json:"mapped_toolheads"` // Of those, how many have a spool
+}
+
+// GroupToolheadAssignment is one toolhead's spool in a group mapping; spool 0 unmaps it
+type GroupToolheadAssignment struct {
+	PrinterName string `json:"printer_name"`
+	ToolheadID  int    `json:"toolhead_id"`
+	SpoolID     int    `json:"spool_id"`
+}
+
+// printerGroupIDs returns the printer IDs of each group, keyed by lowercase group name so
+// "PLA farm" and "pla farm" are one group, along with each group's name as first spelled in
+// printer name order. Ungrouped printers are left out.
+func printerGroupIDs(config *Config) (map[string][]string, map[string]string) {
+	printerIDs := make([]string, 0, len(config.Printers))
+	for printerID := range config.Printers {
+		if printerID != "no_printers" {
+			printerIDs = append(printerIDs, printerID)
+		}
+	}
+	sort.Slice(printerIDs, func(i, j int) bool {
+		return resolvePrinterName(config.Printers[printerIDs[i]]) < resolvePrinterName(config.Printers[printerIDs[j]])
+	})
+
+	groups := make(map[string][]string)
+	names := make(map[string]string)
+	for _, printerID := range printerIDs {
+		group := strings.TrimSpace(config.Printers[printerID].Group)
+		if group == "" {
+			continue
+		}
+		key := strings.ToLower(group)
+		if _, exists := names[key]; !exists {
+			names[key] = group
+		}
+		groups[key] = append(groups[key], printerID)
+	}
+	return groups, names
+}
+
+// groupPrinterIDs returns the name and printer IDs of one group
+func (b *FilamentBridge) groupPrinterIDs(group string) (string, []string, error) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return "", nil, newCodedError(ErrCodeNotFound, "printer group %s not found", group)
+	}
+	groups, names := printerGroupIDs(snapshot)
+	key := strings.ToLower(strings.TrimSpace(group))
+	if len(groups[key]) == 0 {
+		return "", nil, newCodedError(ErrCodeNotFound, "printer group %s not found", group)
+	}
+	return names[key], groups[key], nil
+}
+
+// buildPrinterGroup puts together the farm view of a group from the current status
+func buildPrinterGroup(name string, printerIDs []string, config *Config, status *PrinterStatus) PrinterGroup {
+	group := PrinterGroup{Name: name, Printers: []PrinterGroupPrinter{}, States: make(map[string]int)}
+	for _, printerID := range printerIDs {
+		printerConfig := config.Printers[printerID]
+		printer := PrinterGroupPrinter{
+			PrinterID: printerID,
+			Name:      resolvePrinterName(printerConfig),
+			Model:     printerConfig.Model,
+			State:     status.Printers[printerID].State,
+			Toolheads: []ToolheadMapping{},
+		}
+		for toolheadID := 0; toolheadID < printerConfig.SlotCount(); toolheadID++ {
+			mapping, exists := status.ToolheadMappings[printerID][toolheadID]
+			if !exists {
+				mapping = ToolheadMapping{PrinterName: resolvePrinterName(printerConfig), ToolheadID: toolheadID}
+			}
+			printer.Toolheads = append(printer.Toolheads, mapping)
+			group.Toolheads++
+			if mapping.SpoolID != 0 {
+				group.MappedToolheads++
+			}
+		}
+		group.States[printer.State]++
+		group.Printers = append(group.Printers, printer)
+	}
+	return group
+}
+
+// GetPrinterGroups returns every printer group with its printers' state and toolheads, sorted
+// by name, followed by the printers that aren't in a group
+func (b *FilamentBridge) GetPrinterGroups() ([]PrinterGroup, []PrinterGroupPrinter, error) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return []PrinterGroup{}, []PrinterGroupPrinter{}, nil
+	}
+	status, err := b.GetStatus()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groupIDs, names := printerGroupIDs(snapshot)
+	groups := make([]PrinterGroup, 0, len(groupIDs))
+	grouped := make(map[string]bool)
+	for key, printerIDs := range groupIDs {
+		groups = append(groups, buildPrinterGroup(names[key], printerIDs, snapshot, status))
+		for _, printerID := range printerIDs {
+			grouped[printerID] = true
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name) })
+
+	var ungroupedIDs []string
+	for printerID := range snapshot.Printers {
+		if printerID != "no_printers" && !grouped[printerID] {
+			ungroupedIDs = append(ungroupedIDs, printerID)
+		}
+	}
+	sort.Slice(ungroupedIDs, func(i, j int) bool {
+		return resolvePrinterName(snapshot.Printers[ungroupedIDs[i]]) < resolvePrinterName(snapshot.Printers[ungroupedIDs[j]])
+	})
+	ungrouped := buildPrinterGroup("", ungroupedIDs, snapshot, status).Printers
+	return groups, ungrouped, nil
+}
+
+// GetPrinterGroup returns the farm view of one group
+func (b *FilamentBridge) GetPrinterGroup(group string) (*PrinterGroup, error) {
+	name, printerIDs, err := b.groupPrinterIDs(group)
+	if err != nil {
+		return nil, err
+	}
+	status, err := b.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	view := buildPrinterGroup(name, printerIDs, b.GetConfigSnapshot(), status)
+	return &view, nil
+}
+
+// SetPrinterGroupMappings maps toolheads across the printers of a group, e.g. when loading the
+// same filament into a whole farm. Every printer and toolhead is checked first; each
+// printer's toolheads are then set in one batch, in the order the printers are first listed,
+// so list the printer a spool moves off before the one it moves to. If a printer fails, the
+// printers before it keep their new mappings and are named in the error.
+func (b *FilamentBridge) SetPrinterGroupMappings(group string, assignments []GroupToolheadAssignment, member string) error {
+	_, printerIDs, err := b.groupPrinterIDs(group)
+	if err != nil {
+		return err
+	}
+	if len(assignments) == 0 {
+		return newCodedError(ErrCodeInvalidRequest, "no toolhead mappings given")
+	}
+
+	snapshot := b.GetConfigSnapshot()
+	inGroup := make(map[string]PrinterConfig)
+	for _, printerID := range printerIDs {
+		printerConfig := snapshot.Printers[printerID]
+		inGroup[strings.ToLower(resolvePrinterName(printerConfig))] = printerConfig
+	}
+
+	var order []string
+	byPrinter := make(map[string][]ToolheadAssignment)
+	var problems []string
+	for _, assignment := range assignments {
+		printerConfig, exists := inGroup[strings.ToLower(assignment.PrinterName)]
+		if !exists {
+			problems = append(problems, fmt.Sprintf("printer %s isn't in the group", assignment.PrinterName))
+			continue
+		}
+		printerName := resolvePrinterName(printerConfig)
+		if assignment.ToolheadID < 0 || assignment.ToolheadID >= printerConfig.SlotCount() {
+			problems = append(problems, fmt.Sprintf("%s has no toolhead %d", printerName, assignment.ToolheadID))
+			continue
+		}
+		if _, listed := byPrinter[printerName]; !listed {
+			order = append(order, printerName)
+		}
+		byPrinter[printerName] = append(byPrinter[printerName], ToolheadAssignment{ToolheadID: assignment.ToolheadID, SpoolID: assignment.SpoolID})
+	}
+	if len(problems) > 0 {
+		return newCodedError(ErrCodeInvalidRequest, "%s", strings.Join(problems, "; "))
+	}
+
+	return b.applyGroupMappings(order, byPrinter, member)
+}
+
+// ClearPrinterGroupMappings unmaps every toolhead in a group, e.g. at the end of a run; the
+// spools go to the default location as when unmapped one at a time
+func (b *FilamentBridge) ClearPrinterGroupMappings(group string, member string) error {
+	_, printerIDs, err := b.groupPrinterIDs(group)
+	if err != nil {
+		return err
+	}
+
+	snapshot := b.GetConfigSnapshot()
+	var order []string
+	byPrinter := make(map[string][]ToolheadAssignment)
+	for _, printerID := range printerIDs {
+		printerConfig := snapshot.Printers[printerID]
+		assignments := make([]ToolheadAssignment, printerConfig.SlotCount())
+		for toolheadID := range assignments {
+			assignments[toolheadID].ToolheadID = toolheadID
+		}
+		printerName := resolvePrinterName(printerConfig)
+		order = append(order, printerName)
+		byPrinter[printerName] = assignments
+	}
+	return b.applyGroupMappings(order, byPrinter, member)
+}
+
+// applyGroupMappings sets each printer's toolheads in order, stopping at the first failure
+func (b *FilamentBridge) applyGroupMappings(order []string, byPrinter map[string][]ToolheadAssignment, member string) error {
+	var applied []string
+	for _, printerName := range order {
+		if err := b.SetToolheadMappings(printerName, byPrinter[printerName], member); err != nil {
+			if len(applied) == 0 {
+				return err
+			}
+			return newCodedError(errorCode(err, ErrCodeInternal), "%s: %v (already mapped: %s)", printerName, err, strings.Join(applied, ", "))
+		}
+		applied = append(applied, printerName)
+	}
+	return nil
+}
+
+// GetPrinterGroupStats aggregates the print history of a group's printers, like
+// ComputeUsageStats does for every printer. Usage is matched by printer name, so prints from
+// before a printer was renamed aren't counted.
+func (b *FilamentBridge) GetPrinterGroupStats(group string, days int, now time.Time) (*UsageStats, error) {
+	_, printerIDs, err := b.groupPrinterIDs(group)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := b.GetConfigSnapshot()
+	printers := make(map[string]bool, len(printerIDs))
+	for _, printerID := range printerIDs {
+		printers[resolvePrinterName(snapshot.Printers[printerID])] = true
+	}
+	return b.computeUsageStats(days, now, printers)
+}
+
+// getPrinterGroupsHandler returns the farm view: every group with its printers, then the
+// printers without one
+func (ws *WebServer) getPrinterGroupsHandler(c *gin.Context) {
+	groups, ungrouped, err := ws.bridge.GetPrinterGroups()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, PrinterGroupsResponse{Groups: groups, Ungrouped: ungrouped})
+}
+
+// getPrinterGroupHandler returns the farm view of one group
+func (ws *WebServer) getPrinterGroupHandler(c *gin.Context) {
+	group, err := ws.bridge.GetPrinterGroup(c.Param("name"))
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// setPrinterGroupMappingsHandler maps toolheads across a group's printers
+func (ws *WebServer) setPrinterGroupMappingsHandler(c *gin.Context) {
+	var req struct {
+		Mappings []GroupToolheadAssignment `json:"mappings" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	member := callerMember(c)
+	var memberName string
+	if member != nil {
+		memberName = member.Name
+	}
+	// Every printer's toolhead rules are checked before any printer is mapped
+	var warnings []string
+	for _, assignment := range req.Mappings {
+		problems, err := ws.bridge.checkToolheadRules(assignment.PrinterName, []ToolheadAssignment{{ToolheadID: assignment.ToolheadID, SpoolID: assignment.SpoolID}})
+		if err != nil {
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+			return
+		}
+		warnings = append(warnings, problems...)
+	}
+	if err := ws.bridge.SetPrinterGroupMappings(c.Param("name"), req.Mappings, memberName); err != nil {
+		ws.BroadcastStatus()
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	response := MappingResponse{Message: "Group toolheads mapped successfully", Warnings: warnings}
+	for _, assignment := range req.Mappings {
+		if assignment.SpoolID == 0 {
+			continue
+		}
+		if warning := ws.bridge.ownerMismatchWarning(assignment.PrinterName, assignment.SpoolID, member); warning != "" {
+			webLog.Warn("Member mapped another member's spool", "member", member.Name, "printer", assignment.PrinterName, "toolhead_id", assignment.ToolheadID, "spool_id", assignment.SpoolID, "warning", warning)
+			response.Warnings = append(response.Warnings, warning)
+		}
+	}
+
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, response)
+}
+
+// clearPrinterGroupMappingsHandler unmaps every toolhead in a group
+func (ws *WebServer) clearPrinterGroupMappingsHandler(c *gin.Context) {
+	var memberName string
+	if member := callerMember(c); member != nil {
+		memberName = member.Name
+	}
+	if err := ws.bridge.ClearPrinterGroupMappings(c.Param("name"), memberName); err != nil {
+		ws.BroadcastStatus()
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Group toolheads unmapped successfully"})
+}
+
+// getPrinterGroupStatsHandler returns a group's usage statistics over ?days=, or all history
+// when it's 0 or omitted
+func (ws *WebServer) getPrinterGroupStatsHandler(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "0"))
+	if err != nil || days < 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "days must be 0 or a positive number")
+		return
+	}
+
+	stats, err := ws.bridge.GetPrinterGroupStats(c.Param("name"), days, time.Now())
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
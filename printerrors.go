@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// printErrorRetry is what a print error keeps, beyond the job itself, to process it again
+type printErrorRetry struct {
+	Telemetry  jobTelemetry   `json:"telemetry"`
+	JobID      int            `json:"job_id,omitempty"`
+	FinishedAt time.Time      `json:"finished_at,omitempty"` // Keeps the print's processed key the same on a retry
+	Update     *PendingUpdate `json:"update,omitempty"`      // Usage already known, only the Spoolman update failed
+}
+
+// GetPrintErrors returns all unacknowledged print errors, oldest first
+func (b *FilamentBridge) GetPrintErrors() []PrintError {
+	rows, err := b.db.Query("SELECT id, printer_name, COALESCE(filename, ''), COALESCE(display_name, ''), error, created_at, COALESCE(retry, '') != '' FROM print_errors WHERE acknowledged = 0 ORDER BY created_at")
+	if err != nil {
+		bridgeLog.Error("Failed to get print errors", "error", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var printErrors []PrintError
+	for rows.Next() {
+		var printError PrintError
+		if err := rows.Scan(&printError.ID, &printError.PrinterName, &printError.Filename, &printError.DisplayName,
+			&printError.Error, &printError.Timestamp, &printError.Retryable); err != nil {
+			bridgeLog.Error("Failed to scan print error", "error", err)
+			return nil
+		}
+		printErrors = append(printErrors, printError)
+	}
+	if err := rows.Err(); err != nil {
+		bridgeLog.Error("Failed to get print errors", "error", err)
+	}
+	return printErrors
+}
+
+// getPrintError returns a stored print error with its retry data, which is nil when it can't
+// be retried
+func (b *FilamentBridge) getPrintError(errorID string) (*PrintError, *printErrorRetry, error) {
+	var printError PrintError
+	var retryData string
+	err := b.db.QueryRow(
+		"SELECT id, printer_name, COALESCE(filename, ''), COALESCE(display_name, ''), error, created_at, acknowledged, COALESCE(retry, '') FROM print_errors WHERE id = ?",
+		errorID,
+	).Scan(&printError.ID, &printError.PrinterName, &printError.Filename, &printError.DisplayName,
+		&printError.Error, &printError.Timestamp, &printError.Acknowledged, &retryData)
+	if err == sql.ErrNoRows {
+		return nil, nil, newCodedError(ErrCodePrintErrorNotFound, "print error not found: %s", errorID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get print error: %w", err)
+	}
+	if retryData == "" {
+		return &printError, nil, nil
+	}
+
+	var retry printErrorRetry
+	if err := json.Unmarshal([]byte(retryData), &retry); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode print error retry data: %w", err)
+	}
+	printError.Retryable = true
+	return &printError, &retry, nil
+}
+
+// AcknowledgePrintError marks a print error as acknowledged
+func (b *FilamentBridge) AcknowledgePrintError(errorID string) error {
+	result, err := b.db.Exec("UPDATE print_errors SET acknowledged = 1 WHERE id = ?", errorID)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge print error: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodePrintErrorNotFound, "print error not found: %s", errorID)
+	}
+	return nil
+}
+
+// ResolvePrintError removes a print error whose usage was dealt with, e.g. by updating
+// Spoolman by hand
+func (b *FilamentBridge) ResolvePrintError(errorID string) error {
+	result, err := b.db.Exec("DELETE FROM print_errors WHERE id = ?", errorID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve print error: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodePrintErrorNotFound, "print error not found: %s", errorID)
+	}
+	return nil
+}
+
+// RetryPrintError processes a failed job again, downloading and parsing its G-code and
+// updating Spoolman, or only applying its usage when that was already known. The error is
+// resolved when the retry gets past it; a retry that fails for another reason is stored as a
+// new error.
+func (b *FilamentBridge) RetryPrintError(ctx context.Context, errorID string) error {
+	printError, retry, err := b.getPrintError(errorID)
+	if err != nil {
+		return err
+	}
+	if retry == nil {
+		return newCodedError(ErrCodeConflict, "print error %s can't be retried", errorID)
+	}
+
+	if retry.Update != nil {
+		if err := b.applyPendingUpdate(*retry.Update); err != nil {
+			return newCodedError(ErrCodeSpoolmanError, "failed to update spool %d: %v", retry.Update.SpoolID, err)
+		}
+		bridgeLog.Info("Applied spool usage of retried print error", "id", errorID, "spool_id", retry.Update.SpoolID, "grams", retry.Update.FilamentUsed)
+		return b.ResolvePrintError(errorID)
+	}
+
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return newCodedError(ErrCodeInternal, "configuration not loaded")
+	}
+	var printerID string
+	var config PrinterConfig
+	for id, printerConfig := range snapshot.Printers {
+		if resolvePrinterName(printerConfig) == printError.PrinterName {
+			printerID, config = id, printerConfig
+			break
+		}
+	}
+	if printerID == "" {
+		return newCodedError(ErrCodePrinterNotFound, "printer %s no longer exists", printError.PrinterName)
+	}
+
+	b.mutex.Lock()
+	if b.processingPrints[printerID] {
+		b.mutex.Unlock()
+		return newCodedError(ErrCodeConflict, "%s is already processing a print, try again when it's done", printError.PrinterName)
+	}
+	b.processingPrints[printerID] = true
+	b.mutex.Unlock()
+
+	bridgeLog.Info("Retrying failed print", "id", errorID, "printer", printError.PrinterName, "job", printError.Filename)
+	err = b.handlePrusaLinkPrintFinished(ctx, config, unfinishedPrint{
+		PrinterID:   printerID,
+		Filename:    printError.Filename,
+		DisplayName: printError.DisplayName,
+		JobID:       retry.JobID,
+		Telemetry:   retry.Telemetry,
+		FinishedAt:  retry.FinishedAt,
+	})
+
+	b.mutex.Lock()
+	b.processingPrints[printerID] = false
+	b.mutex.Unlock()
+
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return err
+	}
+	// The job was processed, or failed again and was stored as a new error
+	if resolveErr := b.ResolvePrintError(errorID); resolveErr != nil {
+		bridgeLog.Warn("Failed to resolve retried print error", "id", errorID, "error", resolveErr)
+	}
+	if err != nil {
+		return newCodedError(ErrCodePrinterError, "retry failed: %v", err)
+	}
+	return nil
+}
+
+// addPrintError adds a new print error
+func (b *FilamentBridge) addPrintError(printerName, filename, displayName, errorMsg string) {
+	b.addRetryablePrintError(printerName, filename, displayName, errorMsg, nil)
+}
+
+// addRetryablePrintError adds a new print error, keeping what's needed to retry it unless
+// retry is nil
+func (b *FilamentBridge) addRetryablePrintError(printerName, filename, displayName, errorMsg string, retry *printErrorRetry) {
+	// Sanitize printer name and filename to ensure URL-safe error IDs. The random suffix keeps
+	// several errors for the same job within a second, such as a retry failing again, apart.
+	sanitizedPrinterName := sanitizeErrorID(printerName)
+	sanitizedFilename := sanitizeErrorID(filename)
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		monitorLog.Warn("Failed to generate print error ID suffix", "printer", printerName, "error", err)
+	}
+	errorID := fmt.Sprintf("%s_%s_%d_%s", sanitizedPrinterName, sanitizedFilename, time.Now().Unix(), hex.EncodeToString(suffix))
+
+	var retryData string
+	if retry != nil {
+		data, err := json.Marshal(retry)
+		if err != nil {
+			monitorLog.Warn("Failed to encode print error retry data", "printer", printerName, "error", err)
+		} else {
+			retryData = string(data)
+		}
+	}
+	if _, err := b.db.Exec(
+		"INSERT INTO print_errors (id, printer_name, filename, display_name, error, retry, created_at, acknowledged) VALUES (?, ?, ?, ?, ?, ?, ?, 0)",
+		errorID, printerName, filename, displayFilename(filename, displayName), errorMsg, retryData, time.Now(),
+	); err != nil {
+		monitorLog.Error("Failed to store print error", "printer", printerName, "error", err)
+	}
+
+	monitorLog.Warn("Print processing failed, manual Spoolman update required",
+		"printer", printerName, "job", displayFilename(filename, displayName), "error", errorMsg)
+	b.emitLifecycleEvent(LifecycleEventPrintError, map[string]interface{}{
+		"id":               errorID,
+		"printer_name":     printerName,
+		"job_name":         filename,
+		"job_display_name": displayFilename(filename, displayName),
+		"error":            errorMsg,
+		"retryable":        retry != nil,
+	})
+
+	if b.printerNameInMaintenance(printerName, time.Now()) {
+		monitorLog.Info("Printer is in a maintenance window, not sending failure notification", "printer", printerName)
+		return
+	}
+	b.notifier.Notify(Notification{
+		Event:    NotificationEventProcessingFailed,
+		Title:    fmt.Sprintf("Print processing failed on %s", printerName),
+		Message:  fmt.Sprintf("%s: %s", displayFilename(filename, displayName), errorMsg),
+		Critical: true,
+	})
+}
+
+// retryPrintErrorHandler processes the job of a print error again
+func (ws *WebServer) retryPrintErrorHandler(c *gin.Context) {
+	if err := ws.bridge.RetryPrintError(c.Request.Context(), c.Param("id")); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		ws.BroadcastStatus()
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Print processed successfully"})
+}
+
+// resolvePrintErrorHandler removes a print error that was dealt with by hand
+func (ws *WebServer) resolvePrintErrorHandler(c *gin.Context) {
+	if err := ws.bridge.ResolvePrintError(c.Param("id")); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Error resolved"})
+}
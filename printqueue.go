@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PrintQueue processes finished prints on a bounded pool of workers, so a fleet finishing at
+// once doesn't flood Spoolman and a slow G-code download doesn't hold up its printer's
+// monitoring. A printer's prints are processed one at a time, in the order they finished.
+type PrintQueue struct {
+	ready     []printQueueJob            // Jobs whose printer has nothing else running, oldest first
+	waiting   map[string][]printQueueJob // Later jobs of printers with a job ready or running
+	busy      map[string]bool            // Printers with a job ready or running
+	queued    int                        // Jobs in ready and waiting
+	active    int                        // Jobs being processed
+	processed int64                      // Jobs processed since start
+	workers   int
+	running   bool
+	onDone    func() // Called after each processed print, e.g. to broadcast status
+	done      sync.WaitGroup
+	cond      *sync.Cond
+	mutex     sync.Mutex
+}
+
+// printQueueJob is one finished print waiting to be processed
+type printQueueJob struct {
+	printerID string
+	queuedAt  time.Time
+	process   func()
+}
+
+// PrintQueueStats reports the queue's current load, for metrics
+type PrintQueueStats struct {
+	Workers       int
+	Active        int
+	Depth         int
+	Processed     int64
+	OldestWaiting time.Duration // How long the oldest queued job has waited
+	PrinterDepth  map[string]int
+}
+
+// NewPrintQueue creates a queue; prints are processed inline until it's started
+func NewPrintQueue() *PrintQueue {
+	q := &PrintQueue{
+		waiting: make(map[string][]printQueueJob),
+		busy:    make(map[string]bool),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+// Start runs workers that process queued prints until Stop is called, calling onDone after
+// each one
+func (q *PrintQueue) Start(workers int, onDone func()) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.running {
+		return
+	}
+	q.running = true
+	q.workers = workers
+	q.onDone = onDone
+	for i := 0; i < workers; i++ {
+		q.done.Add(1)
+		go q.work()
+	}
+	monitorLog.Info("Started print processing workers", "workers", workers)
+}
+
+// Stop stops accepting prints and waits for the workers to process the ones already queued.
+// Prints are processed with the context they were queued with, so once monitoring has been
+// cancelled they're saved for the next start rather than processed.
+func (q *PrintQueue) Stop() {
+	q.mutex.Lock()
+	q.running = false
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+	q.done.Wait()
+}
+
+// Enqueue queues a finished print of a printer and returns once it's queued. While the queue
+// is full it waits for room, holding up only the caller, and when the queue isn't running
+// the print is processed before Enqueue returns.
+func (q *PrintQueue) Enqueue(printerID string, process func()) {
+	q.mutex.Lock()
+	for q.running && q.queued >= PrintQueueCapacity {
+		q.cond.Wait()
+	}
+	if !q.running {
+		q.mutex.Unlock()
+		process()
+		return
+	}
+
+	job := printQueueJob{printerID: printerID, queuedAt: time.Now(), process: process}
+	q.queued++
+	if q.busy[printerID] {
+		q.waiting[printerID] = append(q.waiting[printerID], job)
+	} else {
+		q.busy[printerID] = true
+		q.ready = append(q.ready, job)
+		q.cond.Broadcast()
+	}
+	depth := q.queued
+	q.mutex.Unlock()
+
+	monitorLog.Debug("Queued finished print", "printer_id", printerID, "queue_depth", depth)
+}
+
+// work is a worker goroutine. Workers keep going after Stop until nothing is queued.
+func (q *PrintQueue) work() {
+	defer q.done.Done()
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for {
+		for len(q.ready) == 0 {
+			if !q.running && q.queued == 0 {
+				return
+			}
+			q.cond.Wait()
+		}
+
+		job := q.ready[0]
+		q.ready = q.ready[1:]
+		q.queued--
+		q.active++
+		q.cond.Broadcast() // Room for a waiting Enqueue
+		q.mutex.Unlock()
+
+		job.process()
+		if q.onDone != nil {
+			q.onDone()
+		}
+
+		q.mutex.Lock()
+		q.active--
+		q.processed++
+		// The printer's next print, if any, may run now
+		if next := q.waiting[job.printerID]; len(next) > 0 {
+			q.ready = append(q.ready, next[0])
+			if len(next) == 1 {
+				delete(q.waiting, job.printerID)
+			} else {
+				q.waiting[job.printerID] = next[1:]
+			}
+		} else {
+			delete(q.busy, job.printerID)
+		}
+		q.cond.Broadcast()
+	}
+}
+
+// Stats returns the queue's current load
+func (q *PrintQueue) Stats() PrintQueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	stats := PrintQueueStats{
+		Workers:      q.workers,
+		Active:       q.active,
+		Depth:        q.queued,
+		Processed:    q.processed,
+		PrinterDepth: make(map[string]int),
+	}
+	now := time.Now()
+	track := func(job printQueueJob) {
+		stats.PrinterDepth[job.printerID]++
+		if wait := now.Sub(job.queuedAt); wait > stats.OldestWaiting {
+			stats.OldestWaiting = wait
+		}
+	}
+	for _, job := range q.ready {
+		track(job)
+	}
+	for _, jobs := range q.waiting {
+		for _, job := range jobs {
+			track(job)
+		}
+	}
+	return stats
+}
+
+// queueTrackedPrint queues a finished print for finishTrackedPrint. The caller has set
+// processingPrints for its printer, which stays set until the print is processed.
+func (b *FilamentBridge) queueTrackedPrint(ctx context.Context, config PrinterConfig, print unfinishedPrint, jobReplaced bool) {
+	b.printQueue.Enqueue(print.PrinterID, func() {
+		b.finishTrackedPrint(ctx, config, print, jobReplaced)
+	})
+}
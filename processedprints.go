@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// processedPrintKey identifies one finished print: its printer, the printer's job ID, its
+// file and when it was seen to finish. The finish time is kept with the print through
+// restarts and retries, so the same finish always has the same key.
+func processedPrintKey(print unfinishedPrint) string {
+	return fmt.Sprintf("%s:%d:%s:%d", print.PrinterID, print.JobID, print.Filename, print.FinishedAt.Unix())
+}
+
+// claimProcessedPrint records a finished print before its usage is charged to Spoolman and
+// reports whether it's the first time. A print is already processed when its key was
+// recorded, or when the printer reported the same job ID and file within
+// ProcessedPrintWindow, which is how a second FilaBridge sharing the database sees it. The
+// check and the insert are one statement, so two processes can't both claim a print.
+func (b *FilamentBridge) claimProcessedPrint(print unfinishedPrint) (bool, error) {
+	if print.FinishedAt.IsZero() {
+		print.FinishedAt = time.Now()
+	}
+	key := processedPrintKey(print)
+
+	if _, err := b.db.Exec("DELETE FROM processed_prints WHERE finished_at < ?", time.Now().Add(-ProcessedPrintRetention)); err != nil {
+		monitorLog.Warn("Failed to prune processed prints", "error", err)
+	}
+
+	result, err := b.db.Exec(`
+		INSERT INTO processed_prints (job_key, printer_id, job_id, filename, finished_at, processed_at)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM processed_prints WHERE job_key = ?
+				OR (job_id != 0 AND printer_id = ? AND job_id = ? AND filename = ? AND finished_at BETWEEN ? AND ?)
+		)
+	`, key, print.PrinterID, print.JobID, print.Filename, print.FinishedAt, time.Now(),
+		key, print.PrinterID, print.JobID, print.Filename,
+		print.FinishedAt.Add(-ProcessedPrintWindow), print.FinishedAt.Add(ProcessedPrintWindow))
+	if err != nil {
+		return false, fmt.Errorf("failed to record processed print: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record processed print: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// claimPrint claims a finished print for processing, logging when it was already processed.
+// If the claim can't be recorded the print is processed anyway, since losing its usage is
+// worse than the small chance of counting it twice.
+func (b *FilamentBridge) claimPrint(print unfinishedPrint) bool {
+	claimed, err := b.claimProcessedPrint(print)
+	if err != nil {
+		monitorLog.Warn("Failed to check whether print was already processed, processing it", "printer_id", print.PrinterID, "job", print.Filename, "error", err)
+		return true
+	}
+	if !claimed {
+		monitorLog.Warn("Print was already processed, not updating Spoolman again", "printer_id", print.PrinterID,
+			"job", print.Filename, "job_id", print.JobID, "finished_at", print.FinishedAt)
+	}
+	return claimed
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PrusaConnectClient handles communication with the Prusa Connect cloud API
+type PrusaConnectClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// PrusaConnectPrinter represents the printer response from Prusa Connect
+type PrusaConnectPrinter struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	PrinterState string `json:"printer_state"`
+	JobInfo      *struct {
+		ID            int     `json:"id"`
+		DisplayName   string  `json:"display_name"`
+		Path          string  `json:"path"`
+		Progress      float64 `json:"progress"`
+		TimeRemaining int     `json:"time_remaining"`
+		TimePrinting  int     `json:"time_printing"`
+	} `json:"job_info,omitempty"`
+}
+
+// NewPrusaConnectClient creates a new Prusa Connect client
+func NewPrusaConnectClient(baseURL, token string, timeout int) *PrusaConnectClient {
+	return &PrusaConnectClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+	}
+}
+
+// GetPrinter retrieves the current state and job of a printer registered in Prusa Connect
+func (c *PrusaConnectClient) GetPrinter(printerUUID string) (*PrusaConnectPrinter, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/app/printers/%s", c.baseURL, printerUUID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prusa Connect request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get printer from Prusa Connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Prusa Connect API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var printer PrusaConnectPrinter
+	if err := json.NewDecoder(resp.Body).Decode(&printer); err != nil {
+		return nil, fmt.Errorf("failed to decode Prusa Connect printer response: %w", err)
+	}
+
+	return &printer, nil
+}
+
+// GetStatusAndJob retrieves the printer state and job in the same shape as the PrusaLink
+// client so the monitoring state machine can treat both sources identically
+func (c *PrusaConnectClient) GetStatusAndJob(printerUUID string) (*PrusaLinkStatus, *PrusaLinkJob, error) {
+	printer, err := c.GetPrinter(printerUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status := &PrusaLinkStatus{}
+	status.Printer.State = printer.PrinterState
+
+	job := &PrusaLinkJob{}
+	if printer.JobInfo != nil {
+		job.ID = printer.JobInfo.ID
+		job.State = printer.PrinterState
+		job.Progress = printer.JobInfo.Progress
+		job.TimeRemaining = printer.JobInfo.TimeRemaining
+		job.TimePrinting = printer.JobInfo.TimePrinting
+
+		// Connect reports the full path (e.g. "/usb/SHAPE~1.BGC"); split it the way PrusaLink does
+		path := strings.TrimPrefix(printer.JobInfo.Path, "/")
+		job.File.DisplayName = printer.JobInfo.DisplayName
+		job.File.Refs.Download = path
+		if idx := strings.LastIndex(path, "/"); idx >= 0 {
+			job.File.Path = "/" + path[:idx]
+			job.File.Name = path[idx+1:]
+		} else {
+			job.File.Name = path
+		}
+		if job.File.DisplayName == "" {
+			job.File.DisplayName = job.File.Name
+		}
+	}
+
+	return status, job, nil
+}
+
+// usesPrusaConnect reports whether a printer is monitored through Prusa Connect
+func (p PrinterConfig) usesPrusaConnect() bool {
+	return p.ConnectPrinterUUID != "" && p.ConnectToken != ""
+}
+
+// getPrinterStatusAndJob fetches the printer state and job from the printer's configured source
+func (b *FilamentBridge) getPrinterStatusAndJob(config PrinterConfig) (*PrusaLinkStatus, *PrusaLinkJob, error) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return nil, nil, fmt.Errorf("configuration not loaded")
+	}
+
+	if config.usesPrusaConnect() {
+		timeout, _ := config.prusaLinkTimeouts(snapshot)
+		client := NewPrusaConnectClient(snapshot.PrusaConnectURL, config.ConnectToken, timeout)
+		return client.GetStatusAndJob(config.ConnectPrinterUUID)
+	}
+
+	client := config.prusaLinkClient(snapshot)
+	status, err := client.GetStatus()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	job, err := client.GetJobInfo()
+	if err != nil {
+		return status, nil, err
+	}
+
+	return status, job, nil
+}
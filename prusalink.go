@@ -1,14 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
-	"regexp"
-	"strconv"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -23,8 +24,13 @@ type PrusaLinkClient struct {
 // PrusaLinkStatus represents the status response from PrusaLink
 type PrusaLinkStatus struct {
 	Printer struct {
-		State       string `json:"state"`
-		Temperature struct {
+		State string `json:"state"`
+		// Temperatures of the active nozzle and the bed, as reported by newer firmware
+		TempNozzle   float64 `json:"temp_nozzle"`
+		TargetNozzle float64 `json:"target_nozzle"`
+		TempBed      float64 `json:"temp_bed"`
+		TargetBed    float64 `json:"target_bed"`
+		Temperature  struct {
 			Bed struct {
 				Actual float64 `json:"actual"`
 				Target float64 `json:"target"`
@@ -55,7 +61,13 @@ type PrusaLinkStatus struct {
 			PrintTimeLeft int     `json:"print_time_left"`
 			Progress      float64 `json:"progress"`
 		} `json:"telemetry"`
+		// Printer-side problem report, e.g. the filament sensor's runout message while in ATTENTION
+		StatusPrinter struct {
+			Ok      bool   `json:"ok"`
+			Message string `json:"message"`
+		} `json:"status_printer"`
 	} `json:"printer"`
+	ClockDrift *time.Duration `json:"-"` // Printer clock minus server time, nil if the printer sent no Date header
 }
 
 // PrusaLinkJob represents the job response from PrusaLink
@@ -91,8 +103,37 @@ type PrusaLinkInfo struct {
 	MinExtrusionTemp int     `json:"min_extrusion_temp"`
 }
 
-// NewPrusaLinkClient creates a new PrusaLink client
-func NewPrusaLinkClient(ipAddress, apiKey string, timeout, fileDownloadTimeout int) *PrusaLinkClient {
+// printerBaseURL returns the base URL of a printer's PrusaLink. The address is a hostname or
+// IP address with an optional port, reached over plain HTTP, or a full http:// or https:// URL,
+// e.g. for PrusaLink behind a reverse proxy.
+func printerBaseURL(address string) string {
+	if strings.Contains(address, "://") {
+		return strings.TrimSuffix(address, "/")
+	}
+	return "http://" + address
+}
+
+// printerHostname returns the hostname or IP address in a printer address, without its
+// scheme, port or path
+func printerHostname(address string) string {
+	if parsed, err := url.Parse(printerBaseURL(address)); err == nil && parsed.Hostname() != "" {
+		return parsed.Hostname()
+	}
+	return address
+}
+
+// insecureTLSConfig returns a TLS configuration that accepts any certificate when skipVerify
+// is set, e.g. a self-signed one, or nil for the default verification
+func insecureTLSConfig(skipVerify bool) *tls.Config {
+	if !skipVerify {
+		return nil
+	}
+	return &tls.Config{InsecureSkipVerify: true}
+}
+
+// NewPrusaLinkClient creates a new PrusaLink client for a printer address as accepted by
+// printerBaseURL, optionally without verifying its HTTPS certificate
+func NewPrusaLinkClient(address, apiKey string, timeout, fileDownloadTimeout int, insecureSkipVerify bool) *PrusaLinkClient {
 	// Create a custom dialer with timeout for DNS resolution
 	// This ensures hostnames (especially .local domains) have adequate time to resolve
 	dialer := &net.Dialer{
@@ -107,10 +148,11 @@ func NewPrusaLinkClient(ipAddress, apiKey string, timeout, fileDownloadTimeout i
 		IdleConnTimeout:       30 * time.Second,
 		ResponseHeaderTimeout: 10 * time.Second, // Timeout for receiving response headers
 		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       insecureTLSConfig(insecureSkipVerify),
 	}
 
 	return &PrusaLinkClient{
-		baseURL: fmt.Sprintf("http://%s", ipAddress),
+		baseURL: printerBaseURL(address),
 		apiKey:  apiKey,
 		httpClient: &http.Client{
 			Timeout:   time.Duration(timeout) * time.Second,
@@ -136,11 +178,13 @@ func (c *PrusaLinkClient) GetStatus() (*PrusaLinkStatus, error) {
 	// Add API key authentication
 	c.addAPIKey(req)
 
+	requestStart := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get status from PrusaLink: %w", err)
 	}
 	defer resp.Body.Close()
+	roundTrip := time.Since(requestStart)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -152,6 +196,12 @@ func (c *PrusaLinkClient) GetStatus() (*PrusaLinkStatus, error) {
 		return nil, fmt.Errorf("failed to decode status response: %w", err)
 	}
 
+	// The Date header is the printer's clock; compare it with the middle of the round trip
+	if printerTime, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		drift := printerTime.Sub(requestStart.Add(roundTrip / 2))
+		status.ClockDrift = &drift
+	}
+
 	return &status, nil
 }
 
@@ -189,9 +239,73 @@ func (c *PrusaLinkClient) GetJobInfo() (*PrusaLinkJob, error) {
 	return &job, nil
 }
 
+// PauseJob pauses the running print job
+func (c *PrusaLinkClient) PauseJob(jobID int) error {
+	return c.sendCommand("PUT", fmt.Sprintf("/api/v1/job/%d/pause", jobID), nil, "pause job")
+}
+
+// ResumeJob resumes a paused print job
+func (c *PrusaLinkClient) ResumeJob(jobID int) error {
+	return c.sendCommand("PUT", fmt.Sprintf("/api/v1/job/%d/resume", jobID), nil, "resume job")
+}
+
+// StopJob stops the print job
+func (c *PrusaLinkClient) StopJob(jobID int) error {
+	return c.sendCommand("DELETE", fmt.Sprintf("/api/v1/job/%d", jobID), nil, "stop job")
+}
+
+// CoolDown turns off the nozzle heaters and the bed. PrusaLink's v1 API has no temperature
+// control, so this uses the older printer endpoints, which not every firmware still serves.
+func (c *PrusaLinkClient) CoolDown(tools int) error {
+	targets := make(map[string]int, tools)
+	for tool := 0; tool < tools; tool++ {
+		targets[fmt.Sprintf("tool%d", tool)] = 0
+	}
+	if err := c.sendCommand("POST", "/api/printer/tool", map[string]interface{}{"command": "target", "targets": targets}, "turn off nozzle heaters"); err != nil {
+		return err
+	}
+	return c.sendCommand("POST", "/api/printer/bed", map[string]interface{}{"command": "target", "target": 0}, "turn off bed heater")
+}
+
+// sendCommand sends a command that answers with no content, with an optional JSON body
+func (c *PrusaLinkClient) sendCommand(method, path string, payload interface{}, action string) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s request: %w", action, err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Add API key authentication
+	c.addAPIKey(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s on PrusaLink: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PrusaLink API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
 // GetPrinterInfo retrieves the printer information
 func (c *PrusaLinkClient) GetPrinterInfo() (*PrusaLinkInfo, error) {
-	log.Printf("🔍 [PrusaLink] Getting printer info from %s", c.baseURL)
+	prusaLinkLog.Debug("Getting printer info", "address", c.baseURL)
 
 	req, err := http.NewRequest("GET", c.baseURL+"/api/v1/info", nil)
 	if err != nil {
@@ -203,34 +317,34 @@ func (c *PrusaLinkClient) GetPrinterInfo() (*PrusaLinkInfo, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		log.Printf("❌ [PrusaLink] API call failed for %s: %v", c.baseURL, err)
+		prusaLinkLog.Warn("Printer info request failed", "address", c.baseURL, "error", err)
 		return nil, fmt.Errorf("failed to get printer info from PrusaLink: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("❌ [PrusaLink] API error for %s: %d - %s", c.baseURL, resp.StatusCode, string(body))
+		prusaLinkLog.Warn("Printer info API error", "address", c.baseURL, "status", resp.StatusCode, "body", string(body))
 		return nil, fmt.Errorf("PrusaLink API error: %d - %s", resp.StatusCode, string(body))
 	}
 
 	// Read the raw response body for logging
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("❌ [PrusaLink] Failed to read response body from %s: %v", c.baseURL, err)
+		prusaLinkLog.Warn("Failed to read printer info response", "address", c.baseURL, "error", err)
 		return nil, fmt.Errorf("failed to read printer info response: %w", err)
 	}
 
-	log.Printf("📥 [PrusaLink] Raw API response from %s: %s", c.baseURL, string(body))
+	prusaLinkLog.Debug("Printer info response", "address", c.baseURL, "body", string(body))
 
 	var info PrusaLinkInfo
 	if err := json.Unmarshal(body, &info); err != nil {
-		log.Printf("❌ [PrusaLink] JSON unmarshal failed for %s: %v", c.baseURL, err)
+		prusaLinkLog.Warn("Failed to decode printer info response", "address", c.baseURL, "error", err)
 		return nil, fmt.Errorf("failed to decode printer info response: %w", err)
 	}
 
-	log.Printf("✅ [PrusaLink] Parsed printer info from %s: hostname='%s', serial='%s', nozzle_diameter=%.2f, mmu=%v",
-		c.baseURL, info.Hostname, info.Serial, info.NozzleDiameter, info.MMU)
+	prusaLinkLog.Debug("Parsed printer info", "address", c.baseURL,
+		"hostname", info.Hostname, "serial", info.Serial, "nozzle_diameter", info.NozzleDiameter, "mmu", info.MMU)
 
 	return &info, nil
 }
@@ -266,15 +380,16 @@ func (c *PrusaLinkClient) GetGcodeFile(filename string) ([]byte, error) {
 	return body, nil
 }
 
-// GetGcodeFileWithRetry downloads the G-code file with retry logic and exponential backoff
-func (c *PrusaLinkClient) GetGcodeFileWithRetry(filename string, fileDownloadTimeout int) ([]byte, error) {
+// GetGcodeFileWithRetry downloads the G-code file with retry logic and exponential backoff.
+// Cancelling ctx aborts the download and any wait between attempts.
+func (c *PrusaLinkClient) GetGcodeFileWithRetry(ctx context.Context, filename string, fileDownloadTimeout int) ([]byte, error) {
 	const maxRetries = 3
 	backoffDelays := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second}
 
 	var lastErr error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		log.Printf("Downloading G-code file attempt %d/%d: %s", attempt+1, maxRetries, filename)
+		prusaLinkLog.Debug("Downloading G-code file", "file", filename, "attempt", attempt+1, "max_attempts", maxRetries)
 
 		// Create a new client with extended timeout for file downloads
 		// Use the same DNS timeout configuration for consistency
@@ -296,15 +411,15 @@ func (c *PrusaLinkClient) GetGcodeFileWithRetry(filename string, fileDownloadTim
 		}
 
 		// Add diagnostic logging to verify timeout values
-		log.Printf("File download client configured with %v timeout", fileClient.Timeout)
+		prusaLinkLog.Debug("File download client configured", "timeout", fileClient.Timeout)
 
 		// Use the correct PrusaLink API format: /{filename}
-		req, err := http.NewRequest("GET", c.baseURL+"/"+filename, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/"+filename, nil)
 		if err != nil {
 			lastErr = fmt.Errorf("failed to create G-code request: %w", err)
-			log.Printf("Attempt %d failed: %v", attempt+1, lastErr)
-			if attempt < maxRetries-1 {
-				time.Sleep(backoffDelays[attempt])
+			prusaLinkLog.Warn("G-code download attempt failed", "file", filename, "attempt", attempt+1, "error", lastErr)
+			if err := waitRetry(ctx, attempt, maxRetries, backoffDelays); err != nil {
+				return nil, err
 			}
 			continue
 		}
@@ -314,10 +429,13 @@ func (c *PrusaLinkClient) GetGcodeFileWithRetry(filename string, fileDownloadTim
 
 		resp, err := fileClient.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("G-code download interrupted: %w", ctx.Err())
+			}
 			lastErr = fmt.Errorf("failed to get G-code file from PrusaLink: %w", err)
-			log.Printf("Attempt %d failed: %v", attempt+1, lastErr)
-			if attempt < maxRetries-1 {
-				time.Sleep(backoffDelays[attempt])
+			prusaLinkLog.Warn("G-code download attempt failed", "file", filename, "attempt", attempt+1, "error", lastErr)
+			if err := waitRetry(ctx, attempt, maxRetries, backoffDelays); err != nil {
+				return nil, err
 			}
 			continue
 		}
@@ -326,9 +444,9 @@ func (c *PrusaLinkClient) GetGcodeFileWithRetry(filename string, fileDownloadTim
 			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 			lastErr = fmt.Errorf("PrusaLink API error: %d - %s", resp.StatusCode, string(body))
-			log.Printf("Attempt %d failed: %v", attempt+1, lastErr)
-			if attempt < maxRetries-1 {
-				time.Sleep(backoffDelays[attempt])
+			prusaLinkLog.Warn("G-code download attempt failed", "file", filename, "attempt", attempt+1, "error", lastErr)
+			if err := waitRetry(ctx, attempt, maxRetries, backoffDelays); err != nil {
+				return nil, err
 			}
 			continue
 		}
@@ -336,57 +454,38 @@ func (c *PrusaLinkClient) GetGcodeFileWithRetry(filename string, fileDownloadTim
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("G-code download interrupted: %w", ctx.Err())
+			}
 			lastErr = fmt.Errorf("failed to read G-code file: %w", err)
-			log.Printf("Attempt %d failed: %v", attempt+1, lastErr)
-			if attempt < maxRetries-1 {
-				time.Sleep(backoffDelays[attempt])
+			prusaLinkLog.Warn("G-code download attempt failed", "file", filename, "attempt", attempt+1, "error", lastErr)
+			if err := waitRetry(ctx, attempt, maxRetries, backoffDelays); err != nil {
+				return nil, err
 			}
 			continue
 		}
 
 		// Success!
-		log.Printf("Successfully downloaded G-code file on attempt %d: %s (%d bytes)",
-			attempt+1, filename, len(body))
+		prusaLinkLog.Info("Downloaded G-code file",
+			"file", filename, "attempt", attempt+1, "bytes", len(body))
 		return body, nil
 	}
 
 	return nil, fmt.Errorf("failed to download G-code file after %d attempts: %w", maxRetries, lastErr)
 }
 
-// ParseGcodeFilamentUsage extracts filament usage from .gcode or .bgcode content
-func (c *PrusaLinkClient) ParseGcodeFilamentUsage(gcodeContent []byte) (map[int]float64, error) {
-	content := string(gcodeContent)
-	filamentUsage := make(map[int]float64)
-
-	// Parse both .gcode and .bgcode formats
-	// Look for "filament used [g]=" pattern which gives exact weights per toolhead
-	// Pattern handles:
-	//   - .bgcode format: "filament used [g]=1.23,4.56"
-	//   - .gcode format: "; filament used [g] = 1.23, 4.56" (with semicolon and spaces)
-	gcodeRegex := regexp.MustCompile(`;?\s*filament used \[g\]\s*=\s*([0-9.,\s]+)`)
-	gcodeMatch := gcodeRegex.FindStringSubmatch(content)
-
-	if len(gcodeMatch) >= 2 {
-		// Parse the comma-separated values for each toolhead
-		weightsStr := gcodeMatch[1]
-		weights := strings.Split(weightsStr, ",")
-
-		for i, weightStr := range weights {
-			weightStr = strings.TrimSpace(weightStr)
-			if weight, err := strconv.ParseFloat(weightStr, 64); err == nil && weight > 0 {
-				filamentUsage[i] = weight
-			}
-		}
-
-		if len(filamentUsage) > 0 {
-			return filamentUsage, nil
-		}
+// waitRetry waits out the backoff after a failed download attempt, unless it was the last one.
+// It returns an error when ctx is cancelled first.
+func waitRetry(ctx context.Context, attempt, maxRetries int, backoffDelays []time.Duration) error {
+	if attempt >= maxRetries-1 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("G-code download interrupted: %w", ctx.Err())
+	case <-time.After(backoffDelays[attempt]):
+		return nil
 	}
-
-	// If no filament usage data found, return empty usage
-	// Both .gcode and .bgcode files should contain this metadata when generated by slicers
-
-	return filamentUsage, nil
 }
 
 // TestConnection tests the connection to PrusaLink
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PurgeSummary counts the records a purge removed, or would remove in a dry run
+type PurgeSummary struct {
+	DryRun             bool `json:"dry_run"`
+	PrintHistory       int  `json:"print_history"`
+	HistoryCorrections int  `json:"history_corrections"`
+	ToolheadMappings   int  `json:"toolhead_mappings"`
+	NFCSessions        int  `json:"nfc_sessions"`
+	PrintErrors        int  `json:"print_errors"`
+	PrinterEvents      int  `json:"printer_events"`
+	SpoolEvents        int  `json:"spool_events"`
+	SpoolAliases       int  `json:"spool_aliases"`
+	FilamentRunouts    int  `json:"filament_runouts"`
+	SpoolReservations  int  `json:"spool_reservations"`
+	ProcessedPrints    int  `json:"processed_prints"`
+	UnattributedUsage  int  `json:"unattributed_usage"`
+	LowStockAlerts     int  `json:"low_stock_alerts"`
+	ScaleReadings      int  `json:"scale_readings"`
+	SpoolExposure      int  `json:"spool_exposure"`
+	SharedSpools       int  `json:"shared_spools"`
+}
+
+// purgeStep counts and deletes one kind of record. The where clause and its arguments are
+// shared by both statements.
+type purgeStep struct {
+	count *int
+	table string
+	where string
+	args  []interface{}
+}
+
+// runPurge counts every step's records and, unless it's a dry run, deletes them, all in one
+// transaction so a purge never stops halfway
+func (b *FilamentBridge) runPurge(steps []purgeStep, dryRun bool) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin purge: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, step := range steps {
+		if err := tx.QueryRow("SELECT COUNT(*) FROM "+step.table+" WHERE "+step.where, step.args...).Scan(step.count); err != nil {
+			return fmt.Errorf("failed to count %s: %w", step.table, err)
+		}
+		if dryRun || *step.count == 0 {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM "+step.table+" WHERE "+step.where, step.args...); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", step.table, err)
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return nil
+}
+
+// PurgePrinterData removes a printer's history, mappings, NFC sessions, audit log and print
+// errors. Its configuration is kept. Spoolman isn't changed, so usage already charged to
+// spools stays charged.
+func (b *FilamentBridge) PurgePrinterData(printerID string, dryRun bool) (*PurgeSummary, error) {
+	snapshot := b.GetConfigSnapshot()
+	config, exists := snapshot.Printers[printerID]
+	if !exists {
+		return nil, newCodedError(ErrCodePrinterNotFound, "printer %s not found", printerID)
+	}
+	printerName := resolvePrinterName(config)
+
+	summary := &PurgeSummary{DryRun: dryRun}
+	// Corrections go first, while the history rows they belong to still exist
+	steps := []purgeStep{
+		{&summary.HistoryCorrections, "history_corrections", "history_id IN (SELECT id FROM print_history WHERE printer_name = ?)", []interface{}{printerName}},
+		{&summary.PrintHistory, "print_history", "printer_name = ?", []interface{}{printerName}},
+		{&summary.ToolheadMappings, "toolhead_mappings", "printer_name = ?", []interface{}{printerName}},
+		{&summary.NFCSessions, "nfc_sessions", "printer_name = ?", []interface{}{printerName}},
+		{&summary.PrinterEvents, "printer_events", "printer_id = ?", []interface{}{printerID}},
+		{&summary.PrintErrors, "print_errors", "printer_name = ?", []interface{}{printerName}},
+		{&summary.FilamentRunouts, "filament_runouts", "printer_name = ?", []interface{}{printerName}},
+		{&summary.SpoolReservations, "spool_reservations", "printer_name = ?", []interface{}{printerName}},
+		{&summary.ProcessedPrints, "processed_prints", "printer_id = ?", []interface{}{printerID}},
+		{&summary.UnattributedUsage, "unattributed_usage", "printer_name = ?", []interface{}{printerName}},
+		{&summary.ScaleReadings, "scale_readings", "printer_name = ?", []interface{}{printerName}},
+	}
+	if err := b.runPurge(steps, dryRun); err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		bridgeLog.Info("Purged printer data", "printer_id", printerID, "printer", printerName,
+			"history", summary.PrintHistory, "mappings", summary.ToolheadMappings, "errors", summary.PrintErrors)
+	}
+	return summary, nil
+}
+
+// PurgeSpoolData removes a spool's history, mappings, NFC sessions, event log and refill
+// links. The spool itself stays in Spoolman. The spool is in the printer's Spoolman instance,
+// the main one when printerName is empty, and the records of the same spool ID in other
+// instances are kept.
+func (b *FilamentBridge) PurgeSpoolData(printerName string, spoolID int, dryRun bool) (*PurgeSummary, error) {
+	snapshot := b.GetConfigSnapshot()
+	instance := printerSpoolmanInstance(snapshot, printerName)
+	printers, printerArgs := instancePrinterFilter(snapshot, instance)
+	onPrinters := func(where string, args ...interface{}) (string, []interface{}) {
+		return where + " AND " + printers, append(args, printerArgs...)
+	}
+	inInstance := func(where string, args ...interface{}) (string, []interface{}) {
+		return "COALESCE(spoolman_instance, '') = ? AND " + where, append([]interface{}{instance}, args...)
+	}
+
+	// Records of printers are kept by printer, the rest by the instance they belong to
+	byPrinter, byPrinterArgs := onPrinters("spool_id = ?", spoolID)
+	byInstance, byInstanceArgs := inInstance("spool_id = ?", spoolID)
+	aliases, aliasArgs := inInstance("(spool_id = ? OR current_spool_id = ?)", spoolID, spoolID)
+
+	summary := &PurgeSummary{DryRun: dryRun}
+	steps := []purgeStep{
+		{&summary.HistoryCorrections, "history_corrections", "history_id IN (SELECT id FROM print_history WHERE " + byPrinter + ")", byPrinterArgs},
+		{&summary.PrintHistory, "print_history", byPrinter, byPrinterArgs},
+		{&summary.ToolheadMappings, "toolhead_mappings", byPrinter, byPrinterArgs},
+		{&summary.NFCSessions, "nfc_sessions", byPrinter, byPrinterArgs},
+		{&summary.SpoolEvents, "spool_events", byInstance, byInstanceArgs},
+		{&summary.SpoolAliases, "spool_aliases", aliases, aliasArgs},
+		{&summary.FilamentRunouts, "filament_runouts", byPrinter, byPrinterArgs},
+		{&summary.SpoolReservations, "spool_reservations", byPrinter, byPrinterArgs},
+		{&summary.LowStockAlerts, "low_stock_alerts", byInstance, byInstanceArgs},
+		{&summary.ScaleReadings, "scale_readings", byPrinter, byPrinterArgs},
+		{&summary.SpoolExposure, "spool_exposure", byInstance, byInstanceArgs},
+		{&summary.SharedSpools, "shared_spools", byInstance, byInstanceArgs},
+	}
+
+	if err := b.runPurge(steps, dryRun); err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		bridgeLog.Info("Purged spool data", "spoolman_instance", instance, "spool_id", spoolID,
+			"history", summary.PrintHistory, "mappings", summary.ToolheadMappings, "events", summary.SpoolEvents)
+	}
+	return summary, nil
+}
+
+// instancePrinterFilter returns a where clause matching records of the printers using a
+// Spoolman instance. Records without a printer, or of a printer that was removed, belong to
+// the main instance.
+func instancePrinterFilter(snapshot *Config, instance string) (string, []interface{}) {
+	var names []interface{}
+	if snapshot != nil {
+		for _, printer := range snapshot.Printers {
+			printerInstance := printerSpoolmanInstance(snapshot, resolvePrinterName(printer))
+			if (instance == "" && printerInstance != "") || (instance != "" && printerInstance == instance) {
+				names = append(names, resolvePrinterName(printer))
+			}
+		}
+	}
+
+	switch {
+	case instance == "" && len(names) == 0:
+		return "1 = 1", nil
+	case len(names) == 0:
+		return "0 = 1", nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	if instance == "" {
+		return "COALESCE(printer_name, '') NOT IN (" + placeholders + ")", names
+	}
+	return "printer_name IN (" + placeholders + ")", names
+}
+
+// purgeRequest selects a dry-run preview instead of deleting
+type purgeRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// spoolPurgeRequest selects the spool's Spoolman instance and a dry-run preview
+type spoolPurgeRequest struct {
+	DryRun      bool   `json:"dry_run"`
+	PrinterName string `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+}
+
+// purgePrinterDataHandler removes all FilaBridge data recorded for a printer
+func (ws *WebServer) purgePrinterDataHandler(c *gin.Context) {
+	var req purgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	summary, err := ws.bridge.PurgePrinterData(c.Param("id"), req.DryRun)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if !req.DryRun {
+		ws.BroadcastStatus()
+	}
+	c.JSON(http.StatusOK, PurgeResponse{Purged: summary})
+}
+
+// purgeSpoolDataHandler removes all FilaBridge data recorded for a spool
+func (ws *WebServer) purgeSpoolDataHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var req spoolPurgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	summary, err := ws.bridge.PurgeSpoolData(req.PrinterName, spoolID, req.DryRun)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if !req.DryRun {
+		ws.BroadcastStatus()
+	}
+	c.JSON(http.StatusOK, PurgeResponse{Purged: summary})
+}
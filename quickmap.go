@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuickMapSpoolLimit is how many spools the toolhead quick-map page lists
+const QuickMapSpoolLimit = 8
+
+// SuggestToolheadSpools ranks the spools that could be loaded on one toolhead. With a job
+// file, the job's material and color for that toolhead come first and spools of other
+// materials are left out unless none match; without one, every free spool is listed, emptiest
+// first.
+func (b *FilamentBridge) SuggestToolheadSpools(printer string, toolheadID int, filename string, limit int) (*ToolheadSuggestions, error) {
+	_, config, exists := b.findPrinterConfig(printer)
+	if !exists {
+		return nil, newCodedError(ErrCodePrinterNotFound, "printer %s not found", printer)
+	}
+	if toolheadID < 0 || toolheadID >= config.SlotCount() {
+		return nil, newCodedError(ErrCodeInvalidRequest, "printer %s has no toolhead %d", printer, toolheadID)
+	}
+
+	if filename != "" {
+		toolheads, err := b.SuggestSpools(printer, filename, limit)
+		if err != nil {
+			// The list without the job is still enough to map a spool
+			bridgeLog.Warn("Failed to rank spools for job", "printer", printer, "file", filename, "error", err)
+		}
+		for i := range toolheads {
+			if toolheads[i].ToolheadID != toolheadID {
+				continue
+			}
+			matching := toolheads[i].Suggestions[:0]
+			for _, suggestion := range toolheads[i].Suggestions {
+				if suggestion.MaterialMatch {
+					matching = append(matching, suggestion)
+				}
+			}
+			if len(matching) > 0 {
+				toolheads[i].Suggestions = matching
+			}
+			return &toolheads[i], nil
+		}
+	}
+
+	spools, err := b.spoolmanFor(resolvePrinterName(config)).GetAllSpools()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+	mappedTo, mappedHere, err := b.spoolPlacements(resolvePrinterName(config))
+	if err != nil {
+		return nil, err
+	}
+	result := &ToolheadSuggestions{ToolheadID: toolheadID}
+	rankSpools(result, spools, mappedTo, mappedHere[toolheadID], limit)
+	return result, nil
+}
+
+// currentJobFilePath returns the path of the file a printer has a job for, or "" when it has
+// none or can't be reached
+func (b *FilamentBridge) currentJobFilePath(config PrinterConfig) string {
+	_, job, err := b.getPrinterStatusAndJob(config)
+	if err != nil || job == nil {
+		return ""
+	}
+	return jobFilePath(job)
+}
+
+// jobFilePath returns the download path of a job's file on the printer
+func jobFilePath(job *PrusaLinkJob) string {
+	if job.File.Name == "" {
+		return ""
+	}
+	// The download path from refs is already in the right format
+	if job.File.Refs.Download != "" {
+		return strings.TrimPrefix(job.File.Refs.Download, "/")
+	}
+	storage := strings.TrimPrefix(job.File.Path, "/")
+	return storage + "/" + job.File.Name
+}
+
+// toolheadQuickMapURL is the scan URL for a toolhead's quick-map page
+func (ws *WebServer) toolheadQuickMapURL(c *gin.Context, location string) string {
+	return ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/toolhead?location=%s", ws.publicBaseURL(c), neturl.QueryEscape(location)))
+}
+
+// nfcToolheadHandler serves the page opened from a toolhead's tag: the spools suggested for
+// it, each completing the mapping with one tap, instead of scanning a spool tag next
+func (ws *WebServer) nfcToolheadHandler(c *gin.Context) {
+	location := c.Query("location")
+	if location == "" {
+		c.HTML(http.StatusBadRequest, "nfc_error.html", gin.H{"Error": "location parameter is required"})
+		return
+	}
+	printerName, toolheadID, _, isPrinterLocation, err := ws.bridge.parseLocationParam(location)
+	if err != nil || !isPrinterLocation {
+		c.HTML(http.StatusBadRequest, "nfc_error.html", gin.H{
+			"Error": fmt.Sprintf("'%s' is not a printer toolhead", location),
+		})
+		return
+	}
+	_, config, exists := ws.bridge.findPrinterConfig(printerName)
+	if !exists {
+		c.HTML(http.StatusNotFound, "nfc_error.html", gin.H{"Error": "Printer " + printerName + " not found"})
+		return
+	}
+
+	// The job waiting on the printer says which material to load; ?file= picks another one
+	filename := strings.TrimSpace(c.Query("file"))
+	if filename == "" {
+		filename = ws.bridge.currentJobFilePath(config)
+	}
+
+	suggestions, err := ws.bridge.SuggestToolheadSpools(printerName, toolheadID, filename, QuickMapSpoolLimit)
+	if err != nil {
+		c.HTML(httpStatusForCode(errorCode(err, ErrCodeInternal), http.StatusBadGateway), "nfc_error.html", gin.H{
+			"Error": err.Error(),
+		})
+		return
+	}
+
+	baseURL := ws.publicBaseURL(c)
+	spools := make([]gin.H, 0, len(suggestions.Suggestions))
+	for _, suggestion := range suggestions.Suggestions {
+		spool := suggestion.Spool
+		colorHex := ""
+		if spool.Filament != nil && spool.Filament.ColorHex != "" {
+			colorHex = "#" + strings.TrimPrefix(spool.Filament.ColorHex, "#")
+		}
+		spools = append(spools, gin.H{
+			"ID":              spool.ID,
+			"Name":            spool.getSpoolDisplayName(),
+			"ColorHex":        colorHex,
+			"RemainingWeight": spool.RemainingWeight,
+			"MaterialMatch":   suggestion.MaterialMatch,
+			"EnoughFilament":  suggestion.EnoughFilament,
+			"CurrentlyMapped": suggestion.CurrentlyMapped,
+			"URL": ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?spool=%d&location=%s",
+				baseURL, spool.ID, neturl.QueryEscape(location))),
+		})
+	}
+
+	c.HTML(http.StatusOK, "nfc_toolhead.html", gin.H{
+		"Location":       location,
+		"Job":            displayFilename(filename, ""),
+		"Material":       suggestions.Material,
+		"RequiredWeight": suggestions.RequiredWeight,
+		"Spools":         spools,
+		// Falls back to the two-scan session for a spool that isn't listed
+		"ScanURL": ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?location=%s", baseURL, neturl.QueryEscape(location))),
+	})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of disagreement between toolhead mappings and Spoolman locations
+const (
+	MismatchLocatedElsewhere = "located_elsewhere" // Mapped here, but Spoolman has it somewhere else
+	MismatchUnmapped         = "unmapped"          // Spoolman has it on a toolhead it isn't mapped to
+	MismatchSpoolMissing     = "spool_missing"     // Mapped, but archived or deleted in Spoolman
+)
+
+// Sides a mismatch can be resolved from
+const (
+	ReconcileUseFilaBridge = "filabridge" // Update Spoolman's location from the mapping
+	ReconcileUseSpoolman   = "spoolman"   // Update the mapping from Spoolman's location
+)
+
+// MappingMismatch is a spool FilaBridge and Spoolman disagree about
+type MappingMismatch struct {
+	SpoolmanInstance   string `json:"spoolman_instance,omitempty"` // Instance of the spool; empty for the main one
+	SpoolID            int    `json:"spool_id"`
+	SpoolName          string `json:"spool_name,omitempty"`
+	Kind               string `json:"kind"`
+	FilaBridgeLocation string `json:"filabridge_location"` // Toolhead it's mapped to; "" when unmapped
+	SpoolmanLocation   string `json:"spoolman_location"`
+	Message            string `json:"message"`
+}
+
+// toolheadRef is one toolhead of a printer, by printer name
+type toolheadRef struct {
+	printerName string
+	toolheadID  int
+}
+
+// toolheadLocations returns the Spoolman location name of every toolhead of the printers
+// using a Spoolman instance, the "Printer - Toolhead" form NFC assignments write
+func (b *FilamentBridge) toolheadLocations(instance string) (map[toolheadRef]string, error) {
+	printerConfigs, err := b.GetAllPrinterConfigs()
+	if err != nil {
+		return nil, err
+	}
+	locations := make(map[toolheadRef]string)
+	for printerID, config := range printerConfigs {
+		if b.spoolmanInstanceOf(resolvePrinterName(config)) != instance {
+			continue
+		}
+		for toolheadID := 0; toolheadID < config.SlotCount(); toolheadID++ {
+			displayName, err := b.GetToolheadName(printerID, toolheadID)
+			if err != nil {
+				displayName = fmt.Sprintf("Toolhead %d", toolheadID)
+			}
+			ref := toolheadRef{printerName: resolvePrinterName(config), toolheadID: toolheadID}
+			locations[ref] = fmt.Sprintf("%s - %s", ref.printerName, displayName)
+		}
+	}
+	return locations, nil
+}
+
+// sameLocation compares location names the way Spoolman users type them
+func sameLocation(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// FindMappingMismatches compares toolhead mappings with the locations Spoolman has for the
+// same spools. A spool mapped to a toolhead should be at that toolhead's location, and a spool
+// Spoolman places on a toolhead should be mapped to it. Each printer's mappings are compared
+// with the Spoolman instance it uses.
+func (b *FilamentBridge) FindMappingMismatches() ([]MappingMismatch, error) {
+	allMappings, err := b.GetAllToolheadMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := []MappingMismatch{}
+	for _, instance := range b.spoolmanInstanceNames() {
+		instanceMismatches, err := b.findInstanceMismatches(instance, allMappings)
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, instanceMismatches...)
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].SpoolmanInstance != mismatches[j].SpoolmanInstance {
+			return mismatches[i].SpoolmanInstance < mismatches[j].SpoolmanInstance
+		}
+		return mismatches[i].SpoolID < mismatches[j].SpoolID
+	})
+	return mismatches, nil
+}
+
+// findInstanceMismatches compares the mappings of the printers using a Spoolman instance
+// with that instance's spools
+func (b *FilamentBridge) findInstanceMismatches(instance string, allMappings map[string]map[int]ToolheadMapping) ([]MappingMismatch, error) {
+	locations, err := b.toolheadLocations(instance)
+	if err != nil {
+		return nil, err
+	}
+	spools, err := b.spoolmanInstance(instance).GetAllSpools()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+
+	spoolsByID := make(map[int]SpoolmanSpool, len(spools))
+	for _, spool := range spools {
+		spoolsByID[spool.ID] = spool
+	}
+
+	mismatches := []MappingMismatch{}
+	mappedAt := make(map[int][]string) // Spool ID to the locations of its toolheads; several for a shared spool
+	for printerName, mappings := range allMappings {
+		for toolheadID, mapping := range mappings {
+			if mapping.SpoolID == 0 {
+				continue
+			}
+			location, exists := locations[toolheadRef{printerName: printerName, toolheadID: toolheadID}]
+			if !exists {
+				// A toolhead of a removed printer or another instance's printer; nothing here to compare with
+				continue
+			}
+			mappedAt[mapping.SpoolID] = append(mappedAt[mapping.SpoolID], location)
+		}
+	}
+
+	for spoolID, mapped := range mappedAt {
+		sort.Strings(mapped)
+		location := strings.Join(mapped, ", ")
+
+		spool, exists := spoolsByID[spoolID]
+		if !exists || spool.Archived {
+			mismatches = append(mismatches, MappingMismatch{
+				SpoolmanInstance:   instance,
+				SpoolID:            spoolID,
+				Kind:               MismatchSpoolMissing,
+				FilaBridgeLocation: location,
+				Message:            fmt.Sprintf("Spool %d is mapped to %s but is archived or missing in Spoolman", spoolID, location),
+			})
+			continue
+		}
+
+		// Spoolman holds one location, so a shared spool matches when it's at any of its toolheads
+		matched := false
+		for _, toolheadLocation := range mapped {
+			matched = matched || sameLocation(spool.Location, toolheadLocation)
+		}
+		if !matched {
+			message := fmt.Sprintf("Spool %d is mapped to %s but Spoolman has it at %s", spool.ID, location, spool.Location)
+			if spool.Location == "" {
+				message = fmt.Sprintf("Spool %d is mapped to %s but has no location in Spoolman", spool.ID, location)
+			}
+			mismatches = append(mismatches, MappingMismatch{
+				SpoolmanInstance:   instance,
+				SpoolID:            spool.ID,
+				SpoolName:          spool.getSpoolDisplayName(),
+				Kind:               MismatchLocatedElsewhere,
+				FilaBridgeLocation: location,
+				SpoolmanLocation:   spool.Location,
+				Message:            message,
+			})
+		}
+	}
+
+	for _, spool := range spools {
+		if spool.Archived || spool.Location == "" || len(mappedAt[spool.ID]) > 0 {
+			continue
+		}
+		for _, location := range locations {
+			if sameLocation(spool.Location, location) {
+				mismatches = append(mismatches, MappingMismatch{
+					SpoolmanInstance: instance,
+					SpoolID:          spool.ID,
+					SpoolName:        spool.getSpoolDisplayName(),
+					Kind:             MismatchUnmapped,
+					SpoolmanLocation: spool.Location,
+					Message:          fmt.Sprintf("Spoolman has spool %d at %s but it isn't mapped there", spool.ID, spool.Location),
+				})
+				break
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// ResolveMappingMismatch makes one side agree with the other for a spool. From FilaBridge,
+// Spoolman's location becomes the spool's toolhead, or is cleared when it isn't mapped; from
+// Spoolman, the spool is mapped to the toolhead Spoolman has it on, or unmapped when that's
+// not a toolhead. Spoolman has one location, so a shared spool ends up at the first of its
+// toolheads from FilaBridge and on only one toolhead from Spoolman. The spool is in the given
+// Spoolman instance, and only the toolheads of printers using it are considered.
+func (b *FilamentBridge) ResolveMappingMismatch(instance string, spoolID int, use string) error {
+	if use != ReconcileUseFilaBridge && use != ReconcileUseSpoolman {
+		return newCodedError(ErrCodeInvalidRequest, "use must be %q or %q", ReconcileUseFilaBridge, ReconcileUseSpoolman)
+	}
+	if err := b.validateSpoolmanInstance(instance); err != nil {
+		return err
+	}
+	spoolman := b.spoolmanInstance(instance)
+	locations, err := b.toolheadLocations(instance)
+	if err != nil {
+		return err
+	}
+
+	if use == ReconcileUseFilaBridge {
+		allMappings, err := b.GetAllToolheadMappings()
+		if err != nil {
+			return err
+		}
+		target := ""
+		for printerName, mappings := range allMappings {
+			for toolheadID, mapping := range mappings {
+				location, exists := locations[toolheadRef{printerName: printerName, toolheadID: toolheadID}]
+				if exists && mapping.SpoolID == spoolID && (target == "" || location < target) {
+					target = location
+				}
+			}
+		}
+		if err := spoolman.UpdateSpoolLocation(spoolID, target); err != nil {
+			return newCodedError(ErrCodeSpoolmanError, "failed to update Spoolman location for spool %d: %v", spoolID, err)
+		}
+		bridgeLog.Info("Resolved mapping mismatch from FilaBridge", "spoolman_instance", instance, "spool_id", spoolID, "location", target)
+		return nil
+	}
+
+	// Archived and deleted spools are both left out of the list, and can't be on a toolhead
+	spools, err := spoolman.GetAllSpools()
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+	var spoolmanLocation string
+	for _, spool := range spools {
+		if spool.ID == spoolID && !spool.Archived {
+			spoolmanLocation = spool.Location
+		}
+	}
+
+	if err := b.clearSpoolFromAllToolheads(instance, spoolID); err != nil {
+		return fmt.Errorf("failed to unmap spool %d: %w", spoolID, err)
+	}
+	for ref, location := range locations {
+		if spoolmanLocation != "" && sameLocation(spoolmanLocation, location) {
+			if err := b.SetToolheadMapping(ref.printerName, ref.toolheadID, spoolID); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	bridgeLog.Info("Resolved mapping mismatch from Spoolman", "spoolman_instance", instance, "spool_id", spoolID, "location", spoolmanLocation)
+	return nil
+}
+
+// checkMappingMismatches is the scheduled reconciliation, logging what it finds
+func (b *FilamentBridge) checkMappingMismatches() error {
+	mismatches, err := b.FindMappingMismatches()
+	if err != nil {
+		return err
+	}
+	for _, mismatch := range mismatches {
+		bridgeLog.Warn("Toolhead mapping disagrees with Spoolman", "spoolman_instance", mismatch.SpoolmanInstance,
+			"spool_id", mismatch.SpoolID, "kind", mismatch.Kind,
+			"filabridge_location", mismatch.FilaBridgeLocation, "spoolman_location", mismatch.SpoolmanLocation)
+	}
+	return nil
+}
+
+// getMappingMismatchesHandler lists spools whose mapping and Spoolman location disagree
+func (ws *WebServer) getMappingMismatchesHandler(c *gin.Context) {
+	mismatches, err := ws.bridge.FindMappingMismatches()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MappingMismatchesResponse{Mismatches: mismatches})
+}
+
+// resolveMappingMismatchHandler resolves a spool's mismatch from the side given as use
+func (ws *WebServer) resolveMappingMismatchHandler(c *gin.Context) {
+	var req struct {
+		SpoolID          int    `json:"spool_id" binding:"required"`
+		Use              string `json:"use" binding:"required"`
+		SpoolmanInstance string `json:"spoolman_instance"` // The mismatch's instance; the main one when empty
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "spool_id and use are required")
+		return
+	}
+
+	if err := ws.bridge.ResolveMappingMismatch(req.SpoolmanInstance, req.SpoolID, req.Use); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Mismatch resolved"})
+}
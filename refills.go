@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpoolEventRefill is logged on both records when a reusable spool is refilled
+const SpoolEventRefill = "refill"
+
+// SpoolRefill loads a refill onto the reusable spool of a depleted spool record
+type SpoolRefill struct {
+	FilamentID  int      `json:"filament_id"`  // Filament of the refill; the depleted spool's when omitted
+	Weight      *float64 `json:"weight"`       // Net grams of the refill; the filament's weight when omitted
+	SpoolWeight *float64 `json:"spool_weight"` // Empty weight of the reusable spool; the depleted spool's when omitted
+	Notes       string   `json:"notes"`
+	PrinterName string   `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+}
+
+// SpoolRefillResult describes a completed refill
+type SpoolRefillResult struct {
+	OldSpoolID  int     `json:"old_spool_id"`
+	NewSpoolID  int     `json:"new_spool_id"`
+	Weight      float64 `json:"weight"`
+	SpoolWeight float64 `json:"spool_weight"`
+	Remapped    int     `json:"remapped"`          // Toolhead mappings moved to the new spool
+	Warning     string  `json:"warning,omitempty"` // e.g. the depleted spool still had filament left
+}
+
+// RefillSpool creates a Spoolman spool for a refill loaded onto a reusable spool and archives
+// the depleted record. The reusable spool keeps its tags: codes for the old spool ID resolve
+// to the new one from then on, and toolhead mappings move over with it. Both records are in
+// the Spoolman instance of the refill's printer.
+func (b *FilamentBridge) RefillSpool(oldSpoolID int, refill SpoolRefill, member string) (*SpoolRefillResult, error) {
+	instance := b.spoolmanInstanceOf(refill.PrinterName)
+	spoolman := b.spoolmanInstance(instance)
+	old, err := spoolman.GetSpool(oldSpoolID)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", oldSpoolID, err)
+	}
+
+	filamentID := refill.FilamentID
+	var filament *SpoolmanFilament
+	if filamentID == 0 && old.Filament != nil {
+		filamentID = old.Filament.ID
+		filament = old.Filament
+	} else if filamentID != 0 {
+		filaments, err := spoolman.GetAllFilaments()
+		if err != nil {
+			return nil, newCodedError(ErrCodeSpoolmanError, "failed to get filaments: %v", err)
+		}
+		for i := range filaments {
+			if filaments[i].ID == filamentID {
+				filament = &filaments[i]
+				break
+			}
+		}
+		if filament == nil {
+			return nil, newCodedError(ErrCodeNotFound, "filament %d not found", filamentID)
+		}
+	}
+	if filament == nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool %d has no filament; give the refill's filament_id", oldSpoolID)
+	}
+
+	weight := filament.Weight
+	if refill.Weight != nil {
+		weight = *refill.Weight
+	}
+	if weight <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "weight of the refill is required; filament %d has none set", filamentID)
+	}
+
+	// The reusable spool stays, so its empty weight carries over
+	spoolWeight := old.SpoolWeight
+	if spoolWeight == 0 {
+		spoolWeight = filament.SpoolWeight
+	}
+	if refill.SpoolWeight != nil {
+		spoolWeight = *refill.SpoolWeight
+	}
+	if spoolWeight < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_weight can't be negative")
+	}
+
+	notes := strings.TrimSpace(refill.Notes)
+	data := map[string]interface{}{
+		"filament_id":    filamentID,
+		"initial_weight": weight,
+		"spool_weight":   spoolWeight,
+		"comment":        fmt.Sprintf("Refill of spool %d", oldSpoolID),
+	}
+	if old.Location != "" {
+		data["location"] = old.Location
+	}
+	if ownerField := b.spoolOwnerField(); old.Extra[ownerField] != nil {
+		data["extra"] = map[string]interface{}{ownerField: old.Extra[ownerField]}
+	}
+	created, err := spoolman.CreateSpool(data)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to create refill spool: %v", err)
+	}
+
+	if !old.Archived {
+		if err := spoolman.UpdateSpool(oldSpoolID, map[string]interface{}{"archived": true}); err != nil {
+			// Take the new spool back out so Spoolman isn't left with both records active
+			if rollbackErr := spoolman.DeleteSpool(created.ID); rollbackErr != nil {
+				bridgeLog.Error("Failed to remove refill spool after failed archive", "spool_id", created.ID, "error", rollbackErr)
+			}
+			return nil, newCodedError(ErrCodeSpoolmanError, "failed to archive spool %d: %v", oldSpoolID, err)
+		}
+	}
+
+	result := &SpoolRefillResult{OldSpoolID: oldSpoolID, NewSpoolID: created.ID, Weight: weight, SpoolWeight: spoolWeight}
+	if old.RemainingWeight >= 1 {
+		result.Warning = fmt.Sprintf("Spool %d still had %.0fg left when it was archived", oldSpoolID, old.RemainingWeight)
+	}
+
+	remapped, err := b.moveSpoolRecord(instance, oldSpoolID, created.ID)
+	if err != nil {
+		// Spoolman has already been updated, so report success and keep the failure in the log
+		bridgeLog.Error("Failed to move tags and mappings to refill spool", "spool_id", oldSpoolID, "new_spool_id", created.ID, "error", err)
+	}
+	result.Remapped = remapped
+
+	now := time.Now()
+	events := []SpoolEvent{
+		{SpoolmanInstance: instance, SpoolID: oldSpoolID, EventType: SpoolEventRefill, RelatedSpoolID: created.ID,
+			Weight: weight, Member: member, Notes: notes, CreatedAt: now},
+		{SpoolmanInstance: instance, SpoolID: created.ID, EventType: SpoolEventRefill, RelatedSpoolID: oldSpoolID,
+			Weight: weight, Member: member, Notes: notes, CreatedAt: now},
+	}
+	if err := b.recordSpoolEvents(events); err != nil {
+		bridgeLog.Error("Failed to record spool refill", "spool_id", oldSpoolID, "new_spool_id", created.ID, "error", err)
+	}
+
+	bridgeLog.Info("Refilled spool", "spool_id", oldSpoolID, "new_spool_id", created.ID,
+		"grams", weight, "spool_weight", spoolWeight, "remapped", remapped)
+	return result, nil
+}
+
+// moveSpoolRecord points tags and toolhead mappings for a spool record at the one replacing
+// it, returning how many mappings moved. Older records already pointing at the old one are
+// moved too, so a spool refilled many times is always a single lookup away. Only aliases and
+// mappings in the records' Spoolman instance move, since other instances reuse the IDs.
+func (b *FilamentBridge) moveSpoolRecord(instance string, oldSpoolID, newSpoolID int) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin spool record move: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE spool_aliases SET current_spool_id = ? WHERE spoolman_instance = ? AND current_spool_id = ?",
+		newSpoolID, instance, oldSpoolID,
+	); err != nil {
+		return 0, fmt.Errorf("failed to update spool aliases: %w", err)
+	}
+	if _, err := tx.Exec(
+		"INSERT OR REPLACE INTO spool_aliases (spoolman_instance, spool_id, current_spool_id, created_at) VALUES (?, ?, ?, ?)",
+		instance, oldSpoolID, newSpoolID, time.Now(),
+	); err != nil {
+		return 0, fmt.Errorf("failed to add spool alias: %w", err)
+	}
+
+	rows, err := tx.Query("SELECT DISTINCT printer_name FROM toolhead_mappings WHERE spool_id = ?", oldSpoolID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get toolhead mappings: %w", err)
+	}
+	var printers []string
+	for rows.Next() {
+		var printerName string
+		if err := rows.Scan(&printerName); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan toolhead mapping: %w", err)
+		}
+		if printerSpoolmanInstance(b.config, printerName) == instance {
+			printers = append(printers, printerName)
+		}
+	}
+	rows.Close()
+
+	var remapped int64
+	for _, printerName := range printers {
+		moved, err := tx.Exec("UPDATE toolhead_mappings SET spool_id = ? WHERE printer_name = ? AND spool_id = ?",
+			newSpoolID, printerName, oldSpoolID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to move toolhead mappings: %w", err)
+		}
+		count, _ := moved.RowsAffected()
+		remapped += count
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit spool record move: %w", err)
+	}
+	return int(remapped), nil
+}
+
+// currentSpoolID follows a refilled spool's ID in a Spoolman instance to the record that
+// replaced it, so tags and labels written for the old record keep working
+func (b *FilamentBridge) currentSpoolID(instance string, spoolID int) int {
+	var current int
+	err := b.db.QueryRow("SELECT current_spool_id FROM spool_aliases WHERE spoolman_instance = ? AND spool_id = ?",
+		instance, spoolID).Scan(&current)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			bridgeLog.Warn("Failed to look up spool alias", "spool_id", spoolID, "error", err)
+		}
+		return spoolID
+	}
+	return current
+}
+
+// refillSpoolHandler loads a refill onto the reusable spool of a depleted spool record
+func (ws *WebServer) refillSpoolHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var req SpoolRefill
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	var member string
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+
+	result, err := ws.bridge.RefillSpool(spoolID, req, member)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, SpoolRefillResponse{Message: "Spool refilled successfully", Refill: result})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckIdleSpools sends a reminder for every mapped spool that no print has used for the
+// configured number of days. Each spool is reminded at most once per reminder period.
+func (b *FilamentBridge) CheckIdleSpools(now time.Time) error {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil || snapshot.IdleSpoolReminderDays <= 0 {
+		return nil
+	}
+	idlePeriod := time.Duration(snapshot.IdleSpoolReminderDays) * 24 * time.Hour
+
+	allMappings, err := b.GetAllToolheadMappings()
+	if err != nil {
+		return fmt.Errorf("failed to get toolhead mappings: %w", err)
+	}
+
+	for printerName, mappings := range allMappings {
+		for toolheadID, mapping := range mappings {
+			idleSince, err := b.spoolIdleSince(mapping)
+			if err != nil {
+				notificationsLog.Warn("Failed to get last use of spool", "spool_id", mapping.SpoolID, "error", err)
+				continue
+			}
+			if now.Sub(idleSince) < idlePeriod {
+				continue
+			}
+
+			remindedAt, err := b.idleReminderSentAt(printerName, toolheadID)
+			if err != nil {
+				notificationsLog.Warn("Failed to get idle reminder state", "spool_id", mapping.SpoolID, "error", err)
+				continue
+			}
+			if remindedAt.After(idleSince) && now.Sub(remindedAt) < idlePeriod {
+				continue
+			}
+
+			b.sendIdleSpoolReminder(snapshot, mapping, now.Sub(idleSince))
+
+			b.mutex.Lock()
+			_, err = b.db.Exec(
+				"UPDATE toolhead_mappings SET idle_reminded_at = ? WHERE printer_name = ? AND toolhead_id = ?",
+				now, printerName, toolheadID,
+			)
+			b.mutex.Unlock()
+			if err != nil {
+				notificationsLog.Warn("Failed to record idle reminder", "spool_id", mapping.SpoolID, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// spoolIdleSince returns when a mapped spool was last used, or when it was mapped if it hasn't been
+func (b *FilamentBridge) spoolIdleSince(mapping ToolheadMapping) (time.Time, error) {
+	var lastUsed time.Time
+	err := b.db.QueryRow(
+		"SELECT print_finished FROM print_history WHERE spool_id = ? AND COALESCE(reverted, 0) = 0 ORDER BY print_finished DESC LIMIT 1",
+		mapping.SpoolID,
+	).Scan(&lastUsed)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+
+	if lastUsed.After(mapping.MappedAt) {
+		return lastUsed, nil
+	}
+	return mapping.MappedAt, nil
+}
+
+// idleReminderSentAt returns when the last idle reminder for a toolhead was sent (zero if never)
+func (b *FilamentBridge) idleReminderSentAt(printerName string, toolheadID int) (time.Time, error) {
+	var remindedAt sql.NullTime
+	err := b.db.QueryRow(
+		"SELECT idle_reminded_at FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
+		printerName, toolheadID,
+	).Scan(&remindedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+	return remindedAt.Time, nil
+}
+
+// sendIdleSpoolReminder notifies that a spool should go back to storage
+func (b *FilamentBridge) sendIdleSpoolReminder(snapshot *Config, mapping ToolheadMapping, idleFor time.Duration) {
+	days := int(idleFor.Hours() / 24)
+	message := fmt.Sprintf("Spool %d on %s toolhead %d hasn't been used for %d days. Consider returning it to storage.",
+		mapping.SpoolID, mapping.PrinterName, mapping.ToolheadID, days)
+
+	actionURL := ""
+	if location := b.idleSpoolReturnLocation(snapshot); location != "" && snapshot.ExternalURL != "" {
+		actionURL = b.returnSpoolActionURL(snapshot.ExternalURL, mapping)
+		message += fmt.Sprintf(" Move it to %s: %s", location, actionURL)
+	}
+
+	notificationsLog.Info("Idle spool reminder", "spool_id", mapping.SpoolID, "printer", mapping.PrinterName, "toolhead_id", mapping.ToolheadID, "idle_days", days)
+	b.notifier.Notify(Notification{
+		Event:   NotificationEventSpoolIdle,
+		Title:   fmt.Sprintf("Spool %d idle on %s", mapping.SpoolID, mapping.PrinterName),
+		Message: message,
+		URL:     actionURL,
+	})
+}
+
+// idleSpoolReturnLocation returns where idle spools are moved by the reminder action link,
+// falling back to the auto-assign default location
+func (b *FilamentBridge) idleSpoolReturnLocation(snapshot *Config) string {
+	if snapshot.IdleSpoolReturnLocation != "" {
+		return snapshot.IdleSpoolReturnLocation
+	}
+	location, err := b.GetAutoAssignPreviousSpoolLocation()
+	if err != nil {
+		return ""
+	}
+	return location
+}
+
+// actionLinkSecret returns the key used to sign action links, creating it on first use
+func (b *FilamentBridge) actionLinkSecret() (string, error) {
+	if secret, err := b.GetConfigValue(ConfigKeyActionLinkSecret); err == nil && secret != "" {
+		return secret, nil
+	}
+
+	secret, err := generateAccessToken()
+	if err != nil {
+		return "", err
+	}
+	if err := b.SetConfigValue(ConfigKeyActionLinkSecret, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// returnSpoolSignature signs a return action so links can't be forged and expire on remap
+func (b *FilamentBridge) returnSpoolSignature(mapping ToolheadMapping) (string, error) {
+	secret, err := b.actionLinkSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "return:%d:%s:%d:%d", mapping.SpoolID, mapping.PrinterName, mapping.ToolheadID, mapping.MappedAt.Unix())
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// returnSpoolActionURL builds the one-tap link that moves a spool back to storage
+func (b *FilamentBridge) returnSpoolActionURL(externalURL string, mapping ToolheadMapping) string {
+	signature, err := b.returnSpoolSignature(mapping)
+	if err != nil {
+		notificationsLog.Warn("Failed to sign return link", "spool_id", mapping.SpoolID, "error", err)
+		return ""
+	}
+
+	query := url.Values{}
+	query.Set("spool", strconv.Itoa(mapping.SpoolID))
+	query.Set("sig", signature)
+	return strings.TrimSuffix(externalURL, "/") + "/api/reminders/return?" + query.Encode()
+}
+
+// findSpoolMapping returns the toolhead mapping holding a spool, if any
+func (b *FilamentBridge) findSpoolMapping(spoolID int) (*ToolheadMapping, error) {
+	allMappings, err := b.GetAllToolheadMappings()
+	if err != nil {
+		return nil, err
+	}
+	for _, mappings := range allMappings {
+		for _, mapping := range mappings {
+			if mapping.SpoolID == spoolID {
+				return &mapping, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// returnSpoolHandler handles the reminder action link, moving the spool to the return location.
+// It is a GET so it works when tapped from a notification; the signature authorizes it.
+func (ws *WebServer) returnSpoolHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Query("spool"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "nfc_error.html", gin.H{"Error": "Invalid spool ID"})
+		return
+	}
+
+	mapping, err := ws.bridge.findSpoolMapping(spoolID)
+	if err != nil || mapping == nil {
+		c.HTML(http.StatusNotFound, "nfc_error.html", gin.H{"Error": "This spool is no longer mapped to a toolhead"})
+		return
+	}
+
+	expected, err := ws.bridge.returnSpoolSignature(*mapping)
+	if err != nil || !hmac.Equal([]byte(expected), []byte(c.Query("sig"))) {
+		c.HTML(http.StatusForbidden, "nfc_error.html", gin.H{"Error": "This link is invalid or has expired"})
+		return
+	}
+
+	snapshot := ws.bridge.GetConfigSnapshot()
+	location := ""
+	if snapshot != nil {
+		location = ws.bridge.idleSpoolReturnLocation(snapshot)
+	}
+	if location == "" {
+		c.HTML(http.StatusBadRequest, "nfc_error.html", gin.H{"Error": "No return location is configured"})
+		return
+	}
+
+	if err := ws.bridge.AssignSpoolToLocation(spoolID, mapping.PrinterName, 0, location, false); err != nil {
+		c.HTML(http.StatusInternalServerError, "nfc_error.html", gin.H{"Error": fmt.Sprintf("Failed to move spool: %v", err)})
+		return
+	}
+
+	c.HTML(http.StatusOK, "nfc_success.html", gin.H{
+		"SpoolID":           spoolID,
+		"IsPrinterLocation": false,
+		"LocationName":      location,
+	})
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultReportDays is the usage period covered by a status report
+const DefaultReportDays = 7
+
+// Styles of report lines
+const (
+	reportTitle = iota
+	reportHeading
+	reportText
+	reportMuted
+)
+
+// reportLine is one line of a status report, so the same content can be rendered to PDF or PNG
+type reportLine struct {
+	Style    int
+	Text     string
+	Indent   int    // Nesting level
+	ColorHex string // Drawn as a swatch before the text, if set
+}
+
+// buildStatusReport collects printer, inventory and recent usage status into report lines
+func (b *FilamentBridge) buildStatusReport(days int, now time.Time) ([]reportLine, error) {
+	status, err := b.GetStatus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	lines := []reportLine{
+		{Style: reportTitle, Text: "FilaBridge status report"},
+		{Style: reportMuted, Text: "Generated " + now.Format("Monday 2 January 2006, 15:04")},
+	}
+
+	// Spools of every Spoolman instance; the inventory covers those that could be reached
+	snapshot := b.GetConfigSnapshot()
+	var spools []SpoolmanSpool
+	spoolsByID := make(map[instanceSpool]SpoolmanSpool)
+	var spoolErrs []string
+	instances := b.spoolmanInstanceNames()
+	for _, instance := range instances {
+		instanceSpools, err := b.spoolmanInstance(instance).GetAllSpools()
+		if err != nil {
+			name := "Spoolman"
+			if instance != "" {
+				name = fmt.Sprintf("Spoolman instance %s", instance)
+			}
+			spoolErrs = append(spoolErrs, fmt.Sprintf("%s unavailable: %v", name, err))
+			continue
+		}
+		for _, spool := range instanceSpools {
+			spoolsByID[instanceSpool{instance, spool.ID}] = spool
+		}
+		spools = append(spools, instanceSpools...)
+	}
+
+	// Printers and what's loaded in them
+	var printerIDs []string
+	for printerID := range status.Printers {
+		if printerID != "no_printers" {
+			printerIDs = append(printerIDs, printerID)
+		}
+	}
+	sort.Slice(printerIDs, func(i, j int) bool {
+		return status.Printers[printerIDs[i]].Name < status.Printers[printerIDs[j]].Name
+	})
+
+	offline := b.GetPrinterOfflineDurations()
+	lines = append(lines, reportLine{Style: reportHeading, Text: fmt.Sprintf("Printers (%d)", len(printerIDs))})
+	if len(printerIDs) == 0 {
+		lines = append(lines, reportLine{Style: reportMuted, Text: "No printers configured"})
+	}
+	for _, printerID := range printerIDs {
+		printer := status.Printers[printerID]
+		state := printer.State
+		if duration, isOffline := offline[printerID]; isOffline && duration >= time.Minute {
+			state = fmt.Sprintf("%s for %s", state, duration.Round(time.Minute))
+		}
+		lines = append(lines, reportLine{Style: reportText, Text: fmt.Sprintf("%s - %s", printer.Name, state)})
+
+		mappings := status.ToolheadMappings[printerID]
+		toolheadIDs := make([]int, 0, len(mappings))
+		for toolheadID := range mappings {
+			toolheadIDs = append(toolheadIDs, toolheadID)
+		}
+		sort.Ints(toolheadIDs)
+		for _, toolheadID := range toolheadIDs {
+			mapping := mappings[toolheadID]
+			line := reportLine{Style: reportText, Indent: 1}
+			spool, known := spoolsByID[instanceSpool{printerSpoolmanInstance(snapshot, printer.Name), mapping.SpoolID}]
+			switch {
+			case mapping.SpoolID == 0:
+				line.Style = reportMuted
+				line.Text = fmt.Sprintf("%s: no spool", mapping.DisplayName)
+			case !known:
+				line.Text = fmt.Sprintf("%s: spool #%d", mapping.DisplayName, mapping.SpoolID)
+			default:
+				line.Text = fmt.Sprintf("%s: #%d %s, %.0fg left", mapping.DisplayName, spool.ID, reportSpoolName(spool), spool.RemainingWeight)
+				if spool.Filament != nil {
+					line.ColorHex = spool.Filament.ColorHex
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// Inventory
+	lines = append(lines, reportLine{Style: reportHeading, Text: "Inventory"})
+	for _, spoolErr := range spoolErrs {
+		lines = append(lines, reportLine{Style: reportMuted, Text: spoolErr})
+	}
+	if len(spoolErrs) < len(instances) {
+		threshold := float64(DefaultLowStockThreshold)
+		if snapshot != nil {
+			threshold = snapshot.LowStockThreshold
+		}
+		materialDefaults, err := b.GetAllMaterialDefaults()
+		if err != nil {
+			webLog.Warn("Failed to get material defaults for report", "error", err)
+		}
+
+		lowStockField := b.spoolLowStockField()
+
+		var remaining float64
+		var low []SpoolmanSpool
+		for _, spool := range spools {
+			remaining += spool.RemainingWeight
+			if spool.RemainingWeight < lowStockThresholdOf(spool, lowStockField, materialDefaults, threshold) {
+				low = append(low, spool)
+			}
+		}
+		sort.Slice(low, func(i, j int) bool { return low[i].RemainingWeight < low[j].RemainingWeight })
+
+		lines = append(lines, reportLine{Style: reportText, Text: fmt.Sprintf("%d active spools, %.2f kg remaining", len(spools), remaining/1000)})
+		if len(low) == 0 {
+			lines = append(lines, reportLine{Style: reportMuted, Text: "No spools are low on filament"})
+		} else {
+			lines = append(lines, reportLine{Style: reportText, Text: fmt.Sprintf("%d low on filament:", len(low))})
+		}
+		for _, spool := range low {
+			line := reportLine{Style: reportText, Indent: 1, Text: fmt.Sprintf("#%d %s, %.0fg left", spool.ID, reportSpoolName(spool), spool.RemainingWeight)}
+			if spool.Filament != nil {
+				line.ColorHex = spool.Filament.ColorHex
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// Usage over the period
+	history, err := b.GetPrintHistory(PrintHistoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+	since := now.AddDate(0, 0, -days)
+	var total, waste float64
+	var entries int
+	byPrinter := make(map[string]float64)
+	byMaterial := make(map[string]float64)
+	for _, entry := range history {
+		if entry.Reverted || entry.PrintFinished.Before(since) {
+			continue
+		}
+		entries++
+		total += entry.FilamentUsed
+		waste += entry.Waste
+		printer := entry.PrinterName
+		if printer == "" {
+			printer = "No printer" // Manually logged usage
+		}
+		byPrinter[printer] += entry.FilamentUsed
+		material := "Unknown"
+		if spool, known := spoolsByID[instanceSpool{printerSpoolmanInstance(snapshot, entry.PrinterName), entry.SpoolID}]; known && spool.Material != "" {
+			material = spool.Material
+		}
+		byMaterial[material] += entry.FilamentUsed
+	}
+
+	lines = append(lines, reportLine{Style: reportHeading, Text: fmt.Sprintf("Usage, last %d days", days)})
+	usageText := fmt.Sprintf("%.0fg used across %d usage records", total, entries)
+	if waste > 0 {
+		usageText += fmt.Sprintf(", %.0fg of it purged", waste)
+	}
+	lines = append(lines, reportLine{Style: reportText, Text: usageText})
+	for _, group := range []struct {
+		label  string
+		totals map[string]float64
+	}{{"By printer", byPrinter}, {"By material", byMaterial}} {
+		if len(group.totals) == 0 {
+			continue
+		}
+		lines = append(lines, reportLine{Style: reportText, Text: group.label + ":"})
+		names := make([]string, 0, len(group.totals))
+		for name := range group.totals {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return group.totals[names[i]] > group.totals[names[j]] })
+		for _, name := range names {
+			lines = append(lines, reportLine{Style: reportText, Indent: 1, Text: fmt.Sprintf("%s: %.0fg", name, group.totals[name])})
+		}
+	}
+
+	return lines, nil
+}
+
+// reportSpoolName describes a spool as "Name (Brand Material)"
+func reportSpoolName(spool SpoolmanSpool) string {
+	name := spool.Name
+	if name == "" {
+		name = "Unnamed spool"
+	}
+	if details := strings.TrimSpace(spool.Brand + " " + spool.Material); details != "" {
+		name += " (" + details + ")"
+	}
+	return name
+}
+
+// renderReportPDF lays report lines out on A4 pages
+func renderReportPDF(lines []reportLine) ([]byte, error) {
+	const margin, indent = 15.0, 6.0
+	sizes := map[int]float64{reportTitle: 18, reportHeading: 13, reportText: 10, reportMuted: 9}
+
+	doc := newPDFDocument(pageA4Width, pageA4Height)
+	doc.addPage()
+	y := margin
+	for _, line := range lines {
+		size := sizes[line.Style]
+		height := size * 1.5 / mmToPt
+		if line.Style == reportHeading {
+			y += height / 2 // Space above sections
+		}
+		if y+height > pageA4Height-margin {
+			doc.addPage()
+			y = margin
+		}
+		y += height
+
+		x := margin + float64(line.Indent)*indent
+		if r, g, b, ok := colorHexBytes(line.ColorHex); ok {
+			swatch := size * 0.75 / mmToPt
+			doc.setFillColor(r, g, b)
+			doc.rect(x, y-swatch, swatch, swatch)
+			doc.fill()
+			doc.strokeRect(x, y-swatch, swatch, swatch)
+			x += swatch + 1.5
+		}
+
+		font := pdfFontRegular
+		switch line.Style {
+		case reportTitle, reportHeading:
+			font = pdfFontBold
+			doc.setFillColor(0, 0, 0)
+		case reportMuted:
+			doc.setFillColor(110, 110, 110)
+		default:
+			doc.setFillColor(0, 0, 0)
+		}
+		doc.text(x, y, font, size, pdfFitText(line.Text, size, pageA4Width-margin-x))
+	}
+	return doc.bytes()
+}
+
+// renderReportPNG draws report lines onto a single image
+func renderReportPNG(lines []reportLine) ([]byte, error) {
+	const width, margin, indent = 960, 32, 36
+	scales := map[int]int{reportTitle: 4, reportHeading: 3, reportText: 2, reportMuted: 2}
+
+	lineHeight := func(line reportLine) int {
+		height := glyphHeight*scales[line.Style] + 10
+		if line.Style == reportHeading {
+			height += 16 // Space above sections
+		}
+		return height
+	}
+	height := 2 * margin
+	for _, line := range lines {
+		height += lineHeight(line)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	y := margin
+	for _, line := range lines {
+		scale := scales[line.Style]
+		y += lineHeight(line)
+		top := y - glyphHeight*scale - 10
+		x := margin + line.Indent*indent
+
+		if r, g, b, ok := colorHexBytes(line.ColorHex); ok {
+			size := glyphHeight * scale
+			draw.Draw(img, image.Rect(x, top, x+size, top+size), image.NewUniform(color.Gray{Y: 150}), image.Point{}, draw.Src)
+			draw.Draw(img, image.Rect(x+1, top+1, x+size-1, top+size-1), image.NewUniform(color.RGBA{r, g, b, 255}), image.Point{}, draw.Src)
+			x += size + 10
+		}
+
+		var textColor color.Color = color.Black
+		if line.Style == reportMuted {
+			textColor = color.Gray{Y: 110}
+		}
+		maxChars := int(math.Max(0, float64(width-margin-x)/float64(glyphAdvance*scale)))
+		text := line.Text
+		if runes := []rune(text); len(runes) > maxChars && maxChars > 3 {
+			text = string(runes[:maxChars-3]) + "..."
+		}
+		drawBitmapText(img, x, top, scale, text, textColor)
+		if line.Style == reportTitle || line.Style == reportHeading {
+			drawBitmapText(img, x+1, top, scale, text, textColor) // Bold
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// reportHandler renders the current printer and inventory status as a PDF or PNG report
+func (ws *WebServer) reportHandler(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(DefaultReportDays)))
+	if err != nil || days < 1 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "days must be a positive number")
+		return
+	}
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "png" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "format must be pdf or png")
+		return
+	}
+
+	now := time.Now()
+	lines, err := ws.bridge.buildStatusReport(days, now)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	var report []byte
+	contentType := "application/pdf"
+	if format == "png" {
+		contentType = "image/png"
+		report, err = renderReportPNG(lines)
+	} else {
+		report, err = renderReportPDF(lines)
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="filabridge-report-%s.%s"`, now.Format("2006-01-02"), format))
+	c.Data(http.StatusOK, contentType, report)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpoolReservation holds filament on a spool for a job queued on a printer, so a nearly empty
+// spool isn't promised to two jobs. It's released when the job finishes on that printer.
+type SpoolReservation struct {
+	ID          int       `json:"id"`
+	SpoolID     int       `json:"spool_id"`
+	PrinterName string    `json:"printer_name"`
+	JobName     string    `json:"job_name"` // File or display name of the queued job
+	Grams       float64   `json:"grams"`
+	ReservedBy  string    `json:"reserved_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// matchesJob reports whether a reservation is for a job, by any of the names it's known by
+func (r SpoolReservation) matchesJob(jobNames ...string) bool {
+	for _, name := range jobNames {
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(r.JobName, name) || strings.EqualFold(r.JobName, path.Base(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSpoolReservations returns reservations, oldest first, optionally only those of a printer
+// or a spool
+func (b *FilamentBridge) GetSpoolReservations(printerName string, spoolID int) ([]SpoolReservation, error) {
+	query := "SELECT id, spool_id, printer_name, job_name, grams, COALESCE(reserved_by, ''), created_at FROM spool_reservations WHERE 1 = 1"
+	var args []interface{}
+	if printerName != "" {
+		query += " AND printer_name = ?"
+		args = append(args, printerName)
+	}
+	if spoolID > 0 {
+		query += " AND spool_id = ?"
+		args = append(args, spoolID)
+	}
+	rows, err := b.db.Query(query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spool reservations: %w", err)
+	}
+	defer rows.Close()
+
+	reservations := []SpoolReservation{}
+	for rows.Next() {
+		var reservation SpoolReservation
+		if err := rows.Scan(&reservation.ID, &reservation.SpoolID, &reservation.PrinterName, &reservation.JobName,
+			&reservation.Grams, &reservation.ReservedBy, &reservation.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan spool reservation: %w", err)
+		}
+		reservations = append(reservations, reservation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get spool reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+// reservedWeights returns the grams reserved on each spool in a printer's Spoolman instance,
+// leaving out the reservations of the printer's job named by excludeJobs, if any
+func (b *FilamentBridge) reservedWeights(printerName string, excludeJobs ...string) map[int]float64 {
+	reserved := make(map[int]float64)
+	reservations, err := b.GetSpoolReservations("", 0)
+	if err != nil {
+		bridgeLog.Warn("Failed to get spool reservations", "error", err)
+		return reserved
+	}
+
+	snapshot := b.GetConfigSnapshot()
+	instance := printerSpoolmanInstance(snapshot, printerName)
+	for _, reservation := range reservations {
+		// The same spool ID in another Spoolman instance is another spool
+		if printerSpoolmanInstance(snapshot, reservation.PrinterName) != instance {
+			continue
+		}
+		if reservation.PrinterName == printerName && reservation.matchesJob(excludeJobs...) {
+			continue
+		}
+		reserved[reservation.SpoolID] += reservation.Grams
+	}
+	return reserved
+}
+
+// annotateSpoolReservations fills in the grams reserved on each spool of a printer's Spoolman
+// instance, except for the printer's job named by excludeJobs
+func (b *FilamentBridge) annotateSpoolReservations(printerName string, spools []SpoolmanSpool, excludeJobs ...string) {
+	reserved := b.reservedWeights(printerName, excludeJobs...)
+	for i := range spools {
+		spools[i].ReservedWeight = reserved[spools[i].ID]
+	}
+}
+
+// ReserveSpool reserves filament on a spool for a queued job. The spool must have enough
+// left that isn't already reserved for other jobs.
+func (b *FilamentBridge) ReserveSpool(reservation SpoolReservation) (*SpoolReservation, error) {
+	reservation.JobName = strings.TrimSpace(reservation.JobName)
+	switch {
+	case reservation.SpoolID <= 0:
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_id is required")
+	case reservation.JobName == "":
+		return nil, newCodedError(ErrCodeInvalidRequest, "job_name is required")
+	case reservation.Grams <= 0:
+		return nil, newCodedError(ErrCodeInvalidRequest, "grams must be greater than zero")
+	}
+
+	_, config, exists := b.findPrinterConfig(reservation.PrinterName)
+	if !exists {
+		return nil, newCodedError(ErrCodePrinterNotFound, "printer %s not found", reservation.PrinterName)
+	}
+	reservation.PrinterName = resolvePrinterName(config)
+
+	spool, err := b.spoolmanFor(reservation.PrinterName).GetSpool(reservation.SpoolID)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", reservation.SpoolID, err)
+	}
+	if spool.Archived {
+		return nil, newCodedError(ErrCodeConflict, "spool %d is archived", reservation.SpoolID)
+	}
+	unreserved := spool.RemainingWeight - b.reservedWeights(reservation.PrinterName)[reservation.SpoolID]
+	if reservation.Grams > unreserved {
+		return nil, newCodedError(ErrCodeConflict, "spool %d has only %.1fg that isn't reserved for other jobs, %.1fg needed",
+			reservation.SpoolID, unreserved, reservation.Grams)
+	}
+
+	reservation.CreatedAt = time.Now()
+	result, err := b.db.Exec(
+		"INSERT INTO spool_reservations (spool_id, printer_name, job_name, grams, reserved_by, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		reservation.SpoolID, reservation.PrinterName, reservation.JobName, reservation.Grams, reservation.ReservedBy, reservation.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve spool: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve spool: %w", err)
+	}
+	reservation.ID = int(id)
+
+	bridgeLog.Info("Reserved spool for queued job", "spool_id", reservation.SpoolID, "printer", reservation.PrinterName,
+		"job", reservation.JobName, "grams", reservation.Grams)
+	return &reservation, nil
+}
+
+// DeleteSpoolReservation releases a reservation before its job has run
+func (b *FilamentBridge) DeleteSpoolReservation(id int) error {
+	result, err := b.db.Exec("DELETE FROM spool_reservations WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete spool reservation: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodeNotFound, "spool reservation not found: %d", id)
+	}
+	return nil
+}
+
+// releaseJobReservations removes the reservations of a job that finished on a printer, since
+// its usage has now been charged to the spools
+func (b *FilamentBridge) releaseJobReservations(printerName string, jobNames ...string) {
+	reservations, err := b.GetSpoolReservations(printerName, 0)
+	if err != nil {
+		bridgeLog.Warn("Failed to get spool reservations to release", "printer", printerName, "error", err)
+		return
+	}
+	for _, reservation := range reservations {
+		if !reservation.matchesJob(jobNames...) {
+			continue
+		}
+		if err := b.DeleteSpoolReservation(reservation.ID); err != nil {
+			bridgeLog.Warn("Failed to release spool reservation", "id", reservation.ID, "error", err)
+			continue
+		}
+		bridgeLog.Info("Released spool reservation of finished job", "spool_id", reservation.SpoolID,
+			"printer", printerName, "job", reservation.JobName, "grams", reservation.Grams)
+	}
+}
+
+// getSpoolReservationsHandler lists reservations, optionally filtered by printer_name or spool_id
+func (ws *WebServer) getSpoolReservationsHandler(c *gin.Context) {
+	spoolID := 0
+	if value := c.Query("spool_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid spool_id")
+			return
+		}
+		spoolID = id
+	}
+
+	reservations, err := ws.bridge.GetSpoolReservations(c.Query("printer_name"), spoolID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolReservationsResponse{Reservations: reservations})
+}
+
+// createSpoolReservationHandler reserves filament on a spool for a queued job
+func (ws *WebServer) createSpoolReservationHandler(c *gin.Context) {
+	var req struct {
+		SpoolID     int     `json:"spool_id"`
+		PrinterName string  `json:"printer_name"`
+		JobName     string  `json:"job_name"`
+		Grams       float64 `json:"grams"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	reservation := SpoolReservation{SpoolID: req.SpoolID, PrinterName: req.PrinterName, JobName: req.JobName, Grams: req.Grams}
+	if caller := callerMember(c); caller != nil {
+		reservation.ReservedBy = caller.Name
+	}
+
+	// Serialize so two requests can't both reserve the last of a spool
+	ws.operationMutex.Lock()
+	defer ws.operationMutex.Unlock()
+
+	created, err := ws.bridge.ReserveSpool(reservation)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusCreated, SpoolReservationResponse{Message: fmt.Sprintf("Reserved %.1fg of spool %d", created.Grams, created.SpoolID), Reservation: created})
+}
+
+// deleteSpoolReservationHandler releases a reservation
+func (ws *WebServer) deleteSpoolReservationHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid reservation ID")
+		return
+	}
+	if err := ws.bridge.DeleteSpoolReservation(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spool reservation deleted successfully"})
+}
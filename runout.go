@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RunoutPrediction is an estimate that a mapped spool will run out before the current job finishes
+type RunoutPrediction struct {
+	PrinterName      string     `json:"printer_name"`
+	ToolheadID       int        `json:"toolhead_id"`
+	SpoolID          int        `json:"spool_id"`
+	JobID            int        `json:"job_id"`
+	JobName          string     `json:"job_name"`
+	Required         float64    `json:"required"`        // Grams the whole job needs from this toolhead
+	Remaining        float64    `json:"remaining"`       // Grams left on the spool when the job started
+	RunoutProgress   float64    `json:"runout_progress"` // Job progress (percent) at which the spool runs out
+	TimeToEmpty      int        `json:"time_to_empty"`   // Estimated seconds until the spool runs out, -1 if unknown
+	EstimatedEmptyAt *time.Time `json:"estimated_empty_at,omitempty"`
+}
+
+// jobRequirement is the parsed filament requirement of a printer's current job
+type jobRequirement struct {
+	jobID   int
+	jobName string
+	usage   map[int]float64     // Grams per toolhead from the job's G-code
+	predict bool                // Whether runouts are predicted, not only active spools estimated
+	spools  map[int]runoutSpool // Spool state per spool ID, fetched once per job
+	alerted map[int]bool        // Toolheads already notified for this job
+}
+
+// runoutSpool is the spool state a prediction is based on
+type runoutSpool struct {
+	remaining float64
+	factor    float64 // Usage correction factor for the spool's material
+}
+
+// RunoutEstimator predicts mid-print spool runouts from the current job's requirement and
+// progress, and estimates what the active spools have left
+type RunoutEstimator struct {
+	bridge       *FilamentBridge
+	requirements map[string]*jobRequirement    // Current job requirement per printer name
+	predictions  map[string][]RunoutPrediction // Active predictions per printer name
+	active       map[string][]ActiveSpool      // Active spool estimates per printer name
+	mutex        sync.Mutex
+}
+
+// NewRunoutEstimator creates a runout estimator for the bridge's printers
+func NewRunoutEstimator(bridge *FilamentBridge) *RunoutEstimator {
+	return &RunoutEstimator{
+		bridge:       bridge,
+		requirements: make(map[string]*jobRequirement),
+		predictions:  make(map[string][]RunoutPrediction),
+		active:       make(map[string][]ActiveSpool),
+	}
+}
+
+// SetJobRequirement records the per-toolhead requirement of a job that just started. Runouts
+// are only predicted with predict set; active spools are always estimated.
+func (r *RunoutEstimator) SetJobRequirement(printerName string, jobID int, jobName string, usage map[int]float64, predict bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requirements[printerName] = &jobRequirement{
+		jobID:   jobID,
+		jobName: jobName,
+		usage:   usage,
+		predict: predict,
+		spools:  make(map[int]runoutSpool),
+		alerted: make(map[int]bool),
+	}
+	delete(r.predictions, printerName)
+	delete(r.active, printerName)
+}
+
+// Update re-estimates runouts and active spools for a printer from its latest job progress.
+// It is called on every monitor poll; estimates are dropped once the job is no longer the one
+// they are for.
+func (r *RunoutEstimator) Update(printerID string, config PrinterConfig, state string, job *PrusaLinkJob) {
+	printerName := resolvePrinterName(config)
+
+	r.mutex.Lock()
+	requirement := r.requirements[printerName]
+	if state == StateIdle || state == StateFinished || job.ID == 0 || (requirement != nil && requirement.jobID != job.ID) {
+		delete(r.requirements, printerName)
+		delete(r.predictions, printerName)
+		delete(r.active, printerName)
+		r.mutex.Unlock()
+		return
+	}
+	r.mutex.Unlock()
+
+	// Paused jobs keep their last estimates
+	if requirement == nil || state != StatePrinting {
+		return
+	}
+
+	mappings, err := r.bridge.GetToolheadMappings(printerName)
+	if err != nil {
+		monitorLog.Warn("Failed to get toolhead mappings for runout prediction", "printer", printerName, "error", err)
+		return
+	}
+
+	toolheads := make([]int, 0, len(requirement.usage))
+	for toolheadID := range requirement.usage {
+		toolheads = append(toolheads, toolheadID)
+	}
+	sort.Ints(toolheads)
+
+	progress := job.Progress / 100
+	var predictions, alerts []RunoutPrediction
+	var active []ActiveSpool
+	for _, toolheadID := range toolheads {
+		mapping, mapped := mappings[toolheadID]
+		if !mapped || mapping.SpoolID == 0 {
+			continue
+		}
+
+		spool, err := r.spoolState(requirement, printerName, mapping.SpoolID)
+		if err != nil {
+			monitorLog.Warn("Failed to get spool for runout prediction", "spool_id", mapping.SpoolID, "error", err)
+			continue
+		}
+
+		required := requirement.usage[toolheadID] * spool.factor
+		active = append(active, newActiveSpool(printerID, printerName, toolheadID, mapping.SpoolID, requirement, spool.remaining, required, job.Progress))
+		if !requirement.predict || required <= spool.remaining || required <= 0 {
+			continue
+		}
+
+		runoutFraction := 0.0
+		if spool.remaining > 0 {
+			runoutFraction = spool.remaining / required
+		}
+
+		prediction := RunoutPrediction{
+			PrinterName:    printerName,
+			ToolheadID:     toolheadID,
+			SpoolID:        mapping.SpoolID,
+			JobID:          requirement.jobID,
+			JobName:        requirement.jobName,
+			Required:       required,
+			Remaining:      spool.remaining,
+			RunoutProgress: runoutFraction * 100,
+			TimeToEmpty:    estimateTimeToEmpty(runoutFraction, progress, job),
+		}
+		if prediction.TimeToEmpty >= 0 {
+			emptyAt := time.Now().Add(time.Duration(prediction.TimeToEmpty) * time.Second)
+			prediction.EstimatedEmptyAt = &emptyAt
+		}
+		predictions = append(predictions, prediction)
+
+		r.mutex.Lock()
+		if !requirement.alerted[toolheadID] {
+			requirement.alerted[toolheadID] = true
+			alerts = append(alerts, prediction)
+		}
+		r.mutex.Unlock()
+	}
+
+	r.mutex.Lock()
+	if r.requirements[printerName] == requirement {
+		r.predictions[printerName] = predictions
+		r.active[printerName] = active
+	}
+	r.mutex.Unlock()
+
+	for _, prediction := range alerts {
+		r.notify(prediction)
+	}
+}
+
+// spoolState returns the remaining weight and correction factor of a spool, caching it for the job.
+// Spoolman is only updated when a job finishes, so the remaining weight doesn't change mid-print.
+func (r *RunoutEstimator) spoolState(requirement *jobRequirement, printerName string, spoolID int) (runoutSpool, error) {
+	r.mutex.Lock()
+	spool, cached := requirement.spools[spoolID]
+	r.mutex.Unlock()
+	if cached {
+		return spool, nil
+	}
+
+	spoolmanSpool, err := r.bridge.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil {
+		return runoutSpool{}, err
+	}
+	spool = runoutSpool{
+		remaining: spoolmanSpool.RemainingWeight,
+		factor:    r.bridge.usageCorrectionFactor(printerName, spoolID),
+	}
+
+	r.mutex.Lock()
+	requirement.spools[spoolID] = spool
+	r.mutex.Unlock()
+	return spool, nil
+}
+
+// estimateTimeToEmpty returns the seconds until the job reaches the runout fraction, assuming
+// filament is consumed evenly over the job. It returns -1 when the printer reports no timing.
+func estimateTimeToEmpty(runoutFraction, progress float64, job *PrusaLinkJob) int {
+	if progress >= runoutFraction {
+		return 0
+	}
+	if job.TimeRemaining > 0 && progress < 1 {
+		return int(float64(job.TimeRemaining) * (runoutFraction - progress) / (1 - progress))
+	}
+	if job.TimePrinting > 0 && progress > 0 {
+		return int(float64(job.TimePrinting) * (runoutFraction - progress) / progress)
+	}
+	return -1
+}
+
+// notify sends a runout alert for a new prediction
+func (r *RunoutEstimator) notify(prediction RunoutPrediction) {
+	message := fmt.Sprintf("Spool %d on toolhead %d has %.1fg left but %s needs %.1fg; it will run out at about %.0f%% progress",
+		prediction.SpoolID, prediction.ToolheadID, prediction.Remaining, prediction.JobName, prediction.Required, prediction.RunoutProgress)
+	if prediction.TimeToEmpty > 0 {
+		message += fmt.Sprintf(", in about %s", (time.Duration(prediction.TimeToEmpty) * time.Second).Round(time.Minute))
+	}
+
+	monitorLog.Warn("Runout predicted", "printer", prediction.PrinterName, "job", prediction.JobName, "spool_id", prediction.SpoolID, "toolhead_id", prediction.ToolheadID, "runout_progress", prediction.RunoutProgress)
+	r.bridge.notifier.Notify(Notification{
+		Event:    NotificationEventRunoutPredicted,
+		Title:    fmt.Sprintf("Spool will run out during print on %s", prediction.PrinterName),
+		Message:  message,
+		Critical: true,
+	})
+}
+
+// Predictions returns all active runout predictions ordered by printer and toolhead
+func (r *RunoutEstimator) Predictions() []RunoutPrediction {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	predictions := []RunoutPrediction{}
+	for _, printerPredictions := range r.predictions {
+		predictions = append(predictions, printerPredictions...)
+	}
+	sort.Slice(predictions, func(i, j int) bool {
+		if predictions[i].PrinterName != predictions[j].PrinterName {
+			return predictions[i].PrinterName < predictions[j].PrinterName
+		}
+		return predictions[i].ToolheadID < predictions[j].ToolheadID
+	})
+	return predictions
+}
+
+// getRunoutPredictionsHandler returns the active runout predictions
+func (ws *WebServer) getRunoutPredictionsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, RunoutPredictionsResponse{Predictions: ws.bridge.runout.Predictions()})
+}
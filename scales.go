@@ -0,0 +1,613 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scale reading outcomes
+const (
+	ScaleReadingMatched   = "matched"   // Within ScaleReadingTolerance of Spoolman
+	ScaleReadingCorrected = "corrected" // Spoolman was set to the measured weight
+	ScaleReadingFlagged   = "flagged"   // Off by more than the discrepancy threshold, waiting for review
+	ScaleReadingApplied   = "applied"   // Flagged, then applied to Spoolman
+	ScaleReadingDismissed = "dismissed" // Flagged, then dismissed
+)
+
+// ScaleConfig is a smart scale publishing spool weights over MQTT, e.g. an ESPHome load cell
+// under a spool holder. Its spool is the one in the payload, SpoolID, or the spool mapped to
+// the printer toolhead it sits under, in that order.
+type ScaleConfig struct {
+	Name        string  `json:"name"`
+	Topic       string  `json:"topic"` // Topic the weight is published on, as a number or JSON {"weight": ..., "spool_id": ...}
+	SpoolID     int     `json:"spool_id,omitempty"`
+	PrinterName string  `json:"printer_name,omitempty"`
+	ToolheadID  int     `json:"toolhead_id,omitempty"`
+	Gross       bool    `json:"gross,omitempty"` // The weight includes the empty spool, which is subtracted
+	Tare        float64 `json:"tare,omitempty"`  // Grams of anything else on the scale, e.g. a holder
+}
+
+// ScaleReading is a measured spool weight compared with the remaining weight in Spoolman
+type ScaleReading struct {
+	ID             int       `json:"id"`
+	SpoolID        int       `json:"spool_id"`
+	PrinterName    string    `json:"printer_name,omitempty"` // Printer whose Spoolman instance the spool is in
+	Scale          string    `json:"scale,omitempty"`        // Scale it came from; empty for readings posted to the API
+	MeasuredWeight float64   `json:"measured_weight"`        // Grams of filament
+	ExpectedWeight float64   `json:"expected_weight"`        // Grams Spoolman had remaining
+	Difference     float64   `json:"difference"`             // Expected minus measured
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// parseScales parses and validates the JSON scale list from the configuration table
+func parseScales(value string) ([]ScaleConfig, error) {
+	scales := []ScaleConfig{}
+	if strings.TrimSpace(value) == "" {
+		return scales, nil
+	}
+	if err := json.Unmarshal([]byte(value), &scales); err != nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "invalid scales: %v", err)
+	}
+
+	names := make(map[string]bool)
+	topics := make(map[string]bool)
+	for i := range scales {
+		scale := &scales[i]
+		scale.Name = strings.TrimSpace(scale.Name)
+		scale.Topic = strings.TrimSpace(scale.Topic)
+		switch {
+		case scale.Name == "":
+			return nil, newCodedError(ErrCodeInvalidRequest, "scale %d needs a name", i+1)
+		case names[scale.Name]:
+			return nil, newCodedError(ErrCodeInvalidRequest, "duplicate scale name: %s", scale.Name)
+		case scale.Topic == "" || strings.ContainsAny(scale.Topic, "+#"):
+			return nil, newCodedError(ErrCodeInvalidRequest, "scale %s needs a topic without wildcards", scale.Name)
+		case topics[scale.Topic]:
+			return nil, newCodedError(ErrCodeInvalidRequest, "scales share the topic %s", scale.Topic)
+		case scale.Tare < 0:
+			return nil, newCodedError(ErrCodeInvalidRequest, "scale %s has a negative tare", scale.Name)
+		}
+		names[scale.Name] = true
+		topics[scale.Topic] = true
+	}
+	return scales, nil
+}
+
+// emptySpoolWeight returns the weight of a spool without filament, from the spool or its filament
+func emptySpoolWeight(spool SpoolmanSpool) float64 {
+	if spool.SpoolWeight > 0 {
+		return spool.SpoolWeight
+	}
+	if spool.Filament != nil {
+		return spool.Filament.SpoolWeight
+	}
+	return 0
+}
+
+// RecordMeasuredWeight compares a measured spool weight with the remaining weight in the
+// Spoolman instance of printerName (the main one when empty). A small drift is corrected in
+// Spoolman right away; one over the discrepancy threshold is flagged for review instead,
+// since the wrong spool may be on the scale. With gross set the weight includes the empty
+// spool, whose weight is taken from Spoolman.
+func (b *FilamentBridge) RecordMeasuredWeight(spoolID int, printerName string, weight float64, gross bool, scale string) (*ScaleReading, error) {
+	if spoolID <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_id is required")
+	}
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return nil, newCodedError(ErrCodeInvalidRequest, "weight must be zero or more grams")
+	}
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return nil, newCodedError(ErrCodeInternal, "configuration not loaded")
+	}
+
+	client := b.spoolmanFor(printerName)
+	spool, err := client.GetSpool(spoolID)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", spoolID, err)
+	}
+
+	measured := weight
+	if gross {
+		empty := emptySpoolWeight(*spool)
+		if empty <= 0 {
+			return nil, newCodedError(ErrCodeInvalidRequest, "spool %d has no empty spool weight in Spoolman to subtract", spoolID)
+		}
+		if weight < empty {
+			return nil, newCodedError(ErrCodeInvalidRequest, "%.1fg is less than spool %d's empty weight of %.1fg", weight, spoolID, empty)
+		}
+		measured = weight - empty
+	}
+
+	reading := ScaleReading{
+		SpoolID:        spoolID,
+		PrinterName:    printerName,
+		Scale:          scale,
+		MeasuredWeight: math.Round(measured*10) / 10,
+		ExpectedWeight: math.Round(spool.RemainingWeight*10) / 10,
+		Difference:     math.Round((spool.RemainingWeight-measured)*10) / 10,
+		CreatedAt:      time.Now(),
+	}
+
+	switch difference := math.Abs(reading.Difference); {
+	case difference < ScaleReadingTolerance:
+		reading.Status = ScaleReadingMatched
+	case difference <= snapshot.ScaleDiscrepancyThreshold:
+		if err := client.AdjustSpoolUsedWeight(spoolID, spool.RemainingWeight-measured); err != nil {
+			return nil, newCodedError(ErrCodeSpoolmanError, "failed to correct spool %d: %v", spoolID, err)
+		}
+		reading.Status = ScaleReadingCorrected
+	default:
+		reading.Status = ScaleReadingFlagged
+	}
+
+	result, err := b.db.Exec(
+		`INSERT INTO scale_readings (spool_id, printer_name, scale, measured_weight, expected_weight, difference, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		reading.SpoolID, reading.PrinterName, reading.Scale, reading.MeasuredWeight, reading.ExpectedWeight, reading.Difference,
+		reading.Status, reading.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record scale reading: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		reading.ID = int(id)
+	}
+
+	bridgeLog.Info("Recorded measured spool weight", "spool_id", spoolID, "scale", scale, "measured", reading.MeasuredWeight,
+		"expected", reading.ExpectedWeight, "status", reading.Status)
+	if reading.Status == ScaleReadingFlagged {
+		b.notifier.Notify(Notification{
+			Event: NotificationEventScaleDiscrepancy,
+			Title: fmt.Sprintf("Spool %d weighs %.0fg off", spoolID, math.Abs(reading.Difference)),
+			Message: fmt.Sprintf("Spool %d weighed %.1fg of filament but Spoolman has %.1fg remaining. Apply the reading if the right spool was weighed.",
+				spoolID, reading.MeasuredWeight, reading.ExpectedWeight),
+		})
+	}
+	return &reading, nil
+}
+
+const scaleReadingColumns = "id, spool_id, COALESCE(printer_name, ''), COALESCE(scale, ''), measured_weight, expected_weight, difference, status, created_at"
+
+// scanScaleReading reads a scale reading from a query row
+func scanScaleReading(scanner interface{ Scan(...interface{}) error }) (ScaleReading, error) {
+	var reading ScaleReading
+	err := scanner.Scan(&reading.ID, &reading.SpoolID, &reading.PrinterName, &reading.Scale, &reading.MeasuredWeight,
+		&reading.ExpectedWeight, &reading.Difference, &reading.Status, &reading.CreatedAt)
+	return reading, err
+}
+
+// GetScaleReadings returns readings, newest first, optionally only those of a spool or with a status
+func (b *FilamentBridge) GetScaleReadings(spoolID int, status string, limit int) ([]ScaleReading, error) {
+	query := "SELECT " + scaleReadingColumns + " FROM scale_readings WHERE 1 = 1"
+	var args []interface{}
+	if spoolID > 0 {
+		query += " AND spool_id = ?"
+		args = append(args, spoolID)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scale readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []ScaleReading{}
+	for rows.Next() {
+		reading, err := scanScaleReading(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scale reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+	return readings, rows.Err()
+}
+
+// flaggedScaleReading returns a reading by ID that is still waiting for review
+func (b *FilamentBridge) flaggedScaleReading(id int) (*ScaleReading, error) {
+	reading, err := scanScaleReading(b.db.QueryRow("SELECT "+scaleReadingColumns+" FROM scale_readings WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, newCodedError(ErrCodeNotFound, "scale reading %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scale reading: %w", err)
+	}
+	if reading.Status != ScaleReadingFlagged {
+		return nil, newCodedError(ErrCodeConflict, "scale reading %d is %s, not flagged", id, reading.Status)
+	}
+	return &reading, nil
+}
+
+// ApplyScaleReading sets a spool's remaining weight in Spoolman to a flagged reading's
+// measured weight. Usage recorded since the reading is kept.
+func (b *FilamentBridge) ApplyScaleReading(id int) (*ScaleReading, error) {
+	reading, err := b.flaggedScaleReading(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.spoolmanFor(reading.PrinterName).AdjustSpoolUsedWeight(reading.SpoolID, reading.Difference); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to correct spool %d: %v", reading.SpoolID, err)
+	}
+	if err := b.setScaleReadingStatus(id, ScaleReadingApplied); err != nil {
+		return nil, err
+	}
+	reading.Status = ScaleReadingApplied
+	return reading, nil
+}
+
+// DismissScaleReading marks a flagged reading as reviewed without changing Spoolman
+func (b *FilamentBridge) DismissScaleReading(id int) error {
+	if _, err := b.flaggedScaleReading(id); err != nil {
+		return err
+	}
+	return b.setScaleReadingStatus(id, ScaleReadingDismissed)
+}
+
+// setScaleReadingStatus records the outcome of a reading's review
+func (b *FilamentBridge) setScaleReadingStatus(id int, status string) error {
+	if _, err := b.db.Exec("UPDATE scale_readings SET status = ? WHERE id = ?", status, id); err != nil {
+		return fmt.Errorf("failed to update scale reading: %w", err)
+	}
+	return nil
+}
+
+// ScaleListener receives weights from the configured scales over MQTT, using the broker set
+// for Home Assistant, and records them once each scale has settled
+type ScaleListener struct {
+	bridge   *FilamentBridge
+	onChange func() // Called after a reading changed Spoolman or was flagged; may be nil
+	mutex    sync.Mutex
+	pending  map[string]*time.Timer  // Per scale, fires once its weight has been steady for ScaleSettleTime
+	settling map[string]scaleReading // Per scale, the reading the pending timer was started for
+	last     map[string]float64      // Per scale, the last weight recorded
+}
+
+// scaleReading is a weight published by a scale, with the spool it names if any
+type scaleReading struct {
+	weight  float64
+	spoolID int
+}
+
+// NewScaleListener creates a listener calling onChange after readings
+func NewScaleListener(bridge *FilamentBridge, onChange func()) *ScaleListener {
+	return &ScaleListener{
+		bridge:   bridge,
+		onChange: onChange,
+		pending:  make(map[string]*time.Timer),
+		settling: make(map[string]scaleReading),
+		last:     make(map[string]float64),
+	}
+}
+
+// Run keeps a subscription to the scale topics open until ctx is cancelled
+func (l *ScaleListener) Run(ctx context.Context) {
+	subscription := mqttSubscription{
+		bridge:       l.bridge,
+		name:         "scales",
+		clientSuffix: "-scales",
+		topics: func(snapshot *Config) []string {
+			topics := make([]string, 0, len(snapshot.Scales))
+			for _, scale := range snapshot.Scales {
+				topics = append(topics, scale.Topic)
+			}
+			return topics
+		},
+		receive: l.receive,
+	}
+	subscription.run(ctx)
+}
+
+// receive handles a message on a scale topic. A scale's weight changes while a spool is put
+// on it, so a weight is only recorded after it has stayed within ScaleReadingTolerance for
+// ScaleSettleTime. Scales that keep publishing the same weight don't delay it.
+func (l *ScaleListener) receive(topic string, payload []byte) {
+	snapshot := l.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+	var scale *ScaleConfig
+	for i := range snapshot.Scales {
+		if snapshot.Scales[i].Topic == topic {
+			scale = &snapshot.Scales[i]
+		}
+	}
+	if scale == nil {
+		return
+	}
+
+	weight, spoolID, err := parseScalePayload(payload)
+	if err != nil {
+		mqttLog.Debug("Ignoring unreadable scale reading", "scale", scale.Name, "payload", truncateEventPayload(payload), "error", err)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if timer, exists := l.pending[scale.Name]; exists {
+		settling := l.settling[scale.Name]
+		if math.Abs(weight-settling.weight) < ScaleReadingTolerance && spoolID == settling.spoolID {
+			return
+		}
+		timer.Stop()
+	}
+	config := *scale
+	l.settling[scale.Name] = scaleReading{weight: weight, spoolID: spoolID}
+	l.pending[scale.Name] = time.AfterFunc(ScaleSettleTime, func() {
+		l.settled(config, weight, spoolID)
+	})
+}
+
+// parseScalePayload reads a weight published as a number, e.g. an ESPHome sensor state, or
+// as JSON {"weight": ..., "spool_id": ...}
+func parseScalePayload(payload []byte) (float64, int, error) {
+	text := strings.TrimSpace(string(payload))
+	if weight, err := strconv.ParseFloat(text, 64); err == nil {
+		return weight, 0, nil
+	}
+	var reading struct {
+		Weight  *float64 `json:"weight"`
+		SpoolID int      `json:"spool_id"`
+	}
+	if err := json.Unmarshal([]byte(text), &reading); err != nil {
+		return 0, 0, err
+	}
+	if reading.Weight == nil {
+		return 0, 0, fmt.Errorf("no weight in reading")
+	}
+	return *reading.Weight, reading.SpoolID, nil
+}
+
+// settled records a scale's weight once it has stopped changing
+func (l *ScaleListener) settled(scale ScaleConfig, weight float64, spoolID int) {
+	l.mutex.Lock()
+	delete(l.pending, scale.Name)
+	delete(l.settling, scale.Name)
+	last, recorded := l.last[scale.Name]
+	l.mutex.Unlock()
+
+	weight -= scale.Tare
+	// Nothing on the scale, or the same weight published again
+	if weight < ScaleMinWeight || (recorded && math.Abs(weight-last) < ScaleReadingTolerance) {
+		return
+	}
+
+	printerName := ""
+	if scale.PrinterName != "" {
+		printerID, config, exists := l.bridge.findPrinterConfig(scale.PrinterName)
+		if !exists {
+			mqttLog.Warn("Scale's printer not found", "scale", scale.Name, "printer", scale.PrinterName)
+			return
+		}
+		printerName = resolvePrinterName(config)
+		// The spool is charged once the print has been processed, so weights taken before
+		// that would count it twice
+		if l.bridge.usageOutstanding(printerID, printerName) {
+			mqttLog.Debug("Ignoring scale reading while the printer's usage is outstanding", "scale", scale.Name, "printer", printerName)
+			return
+		}
+	}
+	if spoolID == 0 {
+		spoolID = scale.SpoolID
+	}
+	if spoolID == 0 && printerName != "" {
+		mappings, err := l.bridge.GetToolheadMappings(printerName)
+		if err != nil {
+			mqttLog.Warn("Failed to get toolhead mappings for scale", "scale", scale.Name, "error", err)
+			return
+		}
+		spoolID = mappings[scale.ToolheadID].SpoolID
+	}
+	if spoolID == 0 {
+		mqttLog.Debug("Ignoring scale reading without a spool", "scale", scale.Name)
+		return
+	}
+	if l.bridge.spoolUpdatePending(spoolID) {
+		mqttLog.Debug("Ignoring scale reading while usage of the spool waits to be retried", "scale", scale.Name, "spool_id", spoolID)
+		return
+	}
+
+	reading, err := l.bridge.RecordMeasuredWeight(spoolID, printerName, weight, scale.Gross, scale.Name)
+	if err != nil {
+		mqttLog.Warn("Failed to record scale reading", "scale", scale.Name, "spool_id", spoolID, "error", err)
+		return
+	}
+
+	l.mutex.Lock()
+	l.last[scale.Name] = weight
+	l.mutex.Unlock()
+	if reading.Status != ScaleReadingMatched && l.onChange != nil {
+		l.onChange()
+	}
+}
+
+// getScalesHandler returns the configured scales
+func (ws *WebServer) getScalesHandler(c *gin.Context) {
+	snapshot := ws.bridge.GetConfigSnapshot()
+	scales := []ScaleConfig{}
+	if snapshot != nil {
+		scales = snapshot.Scales
+	}
+	c.JSON(http.StatusOK, ScalesResponse{Scales: scales})
+}
+
+// updateScalesHandler replaces the scale list; the listener resubscribes to the new topics
+func (ws *WebServer) updateScalesHandler(c *gin.Context) {
+	var req struct {
+		Scales []ScaleConfig `json:"scales"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	value, err := json.Marshal(req.Scales)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	// Validate before saving so a bad entry doesn't drop every scale
+	if _, err := parseScales(string(value)); err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+		return
+	}
+
+	if err := ws.bridge.SetConfigValue(ConfigKeyScales, string(value)); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	if err := ws.bridge.ReloadConfig(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Scales updated successfully"})
+}
+
+// recordMeasuredWeightHandler compares a weighed spool with Spoolman, correcting a small drift.
+// The body is {"weight": grams, "gross": true when the weight includes the empty spool}; a
+// printer_name query parameter picks that printer's Spoolman instance.
+func (ws *WebServer) recordMeasuredWeightHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+	var req struct {
+		Weight *float64 `json:"weight"`
+		Gross  bool     `json:"gross"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Weight == nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "weight is required")
+		return
+	}
+
+	printerName := ""
+	if printer := c.Query("printer_name"); printer != "" {
+		_, config, exists := ws.bridge.findPrinterConfig(printer)
+		if !exists {
+			respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, fmt.Sprintf("printer %s not found", printer))
+			return
+		}
+		printerName = resolvePrinterName(config)
+	}
+
+	reading, err := ws.bridge.RecordMeasuredWeight(spoolID, printerName, *req.Weight, req.Gross, "")
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	if reading.Status != ScaleReadingMatched {
+		ws.BroadcastStatus()
+	}
+	c.JSON(http.StatusOK, reading)
+}
+
+// getScaleReadingsHandler lists readings, optionally filtered by spool_id or status
+func (ws *WebServer) getScaleReadingsHandler(c *gin.Context) {
+	spoolID := 0
+	if value := c.Query("spool_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid spool_id")
+			return
+		}
+		spoolID = id
+	}
+	limit := DefaultScaleReadingLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	readings, err := ws.bridge.GetScaleReadings(spoolID, c.Query("status"), limit)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, ScaleReadingsResponse{Readings: readings})
+}
+
+// applyScaleReadingHandler applies a flagged reading to Spoolman
+func (ws *WebServer) applyScaleReadingHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid scale reading ID")
+		return
+	}
+
+	// Serialize so a reading can't be applied twice
+	ws.operationMutex.Lock()
+	reading, err := ws.bridge.ApplyScaleReading(id)
+	ws.operationMutex.Unlock()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: fmt.Sprintf("Spool %d set to %.1fg", reading.SpoolID, reading.MeasuredWeight)})
+}
+
+// dismissScaleReadingHandler dismisses a flagged reading
+func (ws *WebServer) dismissScaleReadingHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid scale reading ID")
+		return
+	}
+	if err := ws.bridge.DismissScaleReading(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Scale reading dismissed"})
+}
+
+// usageOutstanding reports whether a printer has usage that isn't in Spoolman yet: a print
+// in progress, one being processed, or updates waiting to be retried
+func (b *FilamentBridge) usageOutstanding(printerID, printerName string) bool {
+	b.mutex.RLock()
+	busy := b.wasPrinting[printerID] || b.processingPrints[printerID]
+	b.mutex.RUnlock()
+	if busy {
+		return true
+	}
+
+	var count int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM pending_updates WHERE printer_name = ?", printerName).Scan(&count); err != nil {
+		bridgeLog.Warn("Failed to check pending updates", "printer", printerName, "error", err)
+		return true
+	}
+	return count > 0
+}
+
+// spoolUpdatePending reports whether usage of a spool waits to be retried
+func (b *FilamentBridge) spoolUpdatePending(spoolID int) bool {
+	var count int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM pending_updates WHERE spool_id = ?", spoolID).Scan(&count); err != nil {
+		bridgeLog.Warn("Failed to check pending updates", "spool_id", spoolID, "error", err)
+		return true
+	}
+	return count > 0
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scheduled job names
+const (
+	JobNFCSessionCleanup   = "nfc_session_cleanup"
+	JobNotificationDigests = "notification_digests"
+	JobIdleSpoolReminders  = "idle_spool_reminders"
+	JobHomeAssistantMQTT   = "home_assistant_mqtt"
+	JobMappingReconcile    = "mapping_reconciliation"
+	JobDatabaseBackup      = "database_backup"
+	JobSpoolmanRetry       = "spoolman_update_retry"
+	JobLowStockCheck       = "low_stock_check"
+	JobSpoolExposure       = "spool_exposure"
+)
+
+// ScheduledJobSettings overrides a job's defaults; stored as JSON keyed by job name. When
+// updating a job, fields left out keep their current value.
+type ScheduledJobSettings struct {
+	Enabled         *bool `json:"enabled,omitempty"`
+	IntervalSeconds *int  `json:"interval_seconds,omitempty"` // 0 restores the default interval
+}
+
+// ScheduledJobStatus is a job's configuration and last-run state
+type ScheduledJobStatus struct {
+	Name                string     `json:"name"`
+	Description         string     `json:"description"`
+	Enabled             bool       `json:"enabled"`
+	IntervalSeconds     int        `json:"interval_seconds"`
+	Running             bool       `json:"running"`
+	RunCount            int        `json:"run_count"`
+	LastRun             *time.Time `json:"last_run,omitempty"`
+	LastDurationSeconds float64    `json:"last_duration_seconds"`
+	LastError           string     `json:"last_error,omitempty"`
+	NextRun             *time.Time `json:"next_run,omitempty"`
+}
+
+// scheduledJob is a periodic background task registered with the scheduler
+type scheduledJob struct {
+	name            string
+	description     string
+	defaultInterval time.Duration
+	run             func() error
+	status          ScheduledJobStatus
+	nextRun         time.Time
+}
+
+// Scheduler runs registered background jobs at their configured intervals
+type Scheduler struct {
+	bridge *FilamentBridge
+	jobs   map[string]*scheduledJob
+	mutex  sync.Mutex
+}
+
+// NewScheduler creates an empty scheduler for the bridge
+func NewScheduler(bridge *FilamentBridge) *Scheduler {
+	return &Scheduler{
+		bridge: bridge,
+		jobs:   make(map[string]*scheduledJob),
+	}
+}
+
+// Register adds a job that runs every defaultInterval unless configured otherwise
+func (s *Scheduler) Register(name, description string, defaultInterval time.Duration, run func() error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.jobs[name] = &scheduledJob{
+		name:            name,
+		description:     description,
+		defaultInterval: defaultInterval,
+		run:             run,
+		status:          ScheduledJobStatus{Name: name, Description: description},
+	}
+}
+
+// registerScheduledJobs registers the bridge's built-in background jobs
+func (b *FilamentBridge) registerScheduledJobs() {
+	b.scheduler.Register(JobNFCSessionCleanup, "Remove expired NFC sessions and old environment readings", NFCSessionCleanupInterval,
+		func() error {
+			if err := b.cleanupExpiredSessions(); err != nil {
+				return err
+			}
+			return b.pruneEnvironmentReadings()
+		})
+	b.scheduler.Register(JobNotificationDigests, "Deliver notifications held during quiet hours as digests", NotificationDigestCheckInterval,
+		func() error {
+			b.notifier.flushDigests(time.Now())
+			return nil
+		})
+	b.scheduler.Register(JobIdleSpoolReminders, "Remind about spools left on toolheads without being used", IdleSpoolCheckInterval,
+		func() error {
+			return b.CheckIdleSpools(time.Now())
+		})
+	b.scheduler.Register(JobHomeAssistantMQTT, "Publish printer and spool state to Home Assistant over MQTT", HomeAssistantPublishInterval,
+		b.homeAssistant.Publish)
+	b.scheduler.Register(JobMappingReconcile, "Compare toolhead mappings with spool locations in Spoolman", MappingReconcileInterval,
+		b.checkMappingMismatches)
+	b.scheduler.Register(JobDatabaseBackup, "Back up the database to the backup directory", DatabaseBackupInterval,
+		b.runScheduledBackup)
+	b.scheduler.Register(JobSpoolmanRetry, "Retry spool usage updates that failed while Spoolman was unavailable", PendingUpdateCheckInterval,
+		b.retryPendingUpdates)
+	b.scheduler.Register(JobLowStockCheck, "Notify about spools below their low stock threshold", LowStockCheckInterval,
+		func() error {
+			return b.CheckLowStockSpools(time.Now())
+		})
+	b.scheduler.Register(JobSpoolExposure, "Track how long spools are out of dry storage and notify when they need drying", SpoolExposureCheckInterval,
+		func() error {
+			return b.TrackSpoolExposure(time.Now())
+		})
+}
+
+// settings returns the effective enabled flag and interval of a job
+func (s *Scheduler) settings(job *scheduledJob, snapshot *Config) (bool, time.Duration) {
+	if snapshot == nil {
+		return true, job.defaultInterval
+	}
+	override, exists := snapshot.ScheduledJobs[job.name]
+	if !exists {
+		return true, job.defaultInterval
+	}
+	interval := job.defaultInterval
+	if override.IntervalSeconds != nil && *override.IntervalSeconds > 0 {
+		interval = time.Duration(*override.IntervalSeconds) * time.Second
+	}
+	return override.Enabled == nil || *override.Enabled, interval
+}
+
+// Run checks for due jobs until ctx is cancelled. Each job first runs one interval after startup.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(SchedulerTickInterval)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		snapshot := s.bridge.GetConfigSnapshot()
+
+		s.mutex.Lock()
+		for _, job := range s.jobs {
+			enabled, interval := s.settings(job, snapshot)
+			if job.nextRun.IsZero() {
+				job.nextRun = now.Add(interval)
+			}
+			if !enabled || job.status.Running || now.Before(job.nextRun) {
+				continue
+			}
+			job.status.Running = true
+			job.nextRun = now.Add(interval)
+			go s.execute(job)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// execute runs a job and records its outcome
+func (s *Scheduler) execute(job *scheduledJob) {
+	started := time.Now()
+	err := job.run()
+	duration := time.Since(started)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job.status.Running = false
+	job.status.RunCount++
+	job.status.LastRun = &started
+	job.status.LastDurationSeconds = duration.Seconds()
+	job.status.LastError = ""
+	if err != nil {
+		job.status.LastError = err.Error()
+		schedulerLog.Error("Error running scheduled job", "job_name", job.name, "error", err)
+	}
+}
+
+// RunNow starts a job immediately, outside its schedule
+func (s *Scheduler) RunNow(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, exists := s.jobs[name]
+	if !exists {
+		return newCodedError(ErrCodeNotFound, "scheduled job %s not found", name)
+	}
+	if job.status.Running {
+		return newCodedError(ErrCodeConflict, "scheduled job %s is already running", name)
+	}
+	job.status.Running = true
+	go s.execute(job)
+	return nil
+}
+
+// Statuses returns the state of every registered job, ordered by name
+func (s *Scheduler) Statuses() []ScheduledJobStatus {
+	snapshot := s.bridge.GetConfigSnapshot()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	statuses := make([]ScheduledJobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		enabled, interval := s.settings(job, snapshot)
+		status := job.status
+		status.Enabled = enabled
+		status.IntervalSeconds = int(interval.Seconds())
+		if enabled && !job.nextRun.IsZero() {
+			nextRun := job.nextRun
+			status.NextRun = &nextRun
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// parseScheduledJobs parses the JSON job settings from the configuration table
+func parseScheduledJobs(value string) (map[string]ScheduledJobSettings, error) {
+	jobs := make(map[string]ScheduledJobSettings)
+	if strings.TrimSpace(value) == "" {
+		return jobs, nil
+	}
+	if err := json.Unmarshal([]byte(value), &jobs); err != nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "invalid scheduled job settings: %v", err)
+	}
+	return jobs, nil
+}
+
+// UpdateScheduledJob saves the settings of a registered job
+func (b *FilamentBridge) UpdateScheduledJob(name string, settings ScheduledJobSettings) error {
+	b.scheduler.mutex.Lock()
+	_, exists := b.scheduler.jobs[name]
+	b.scheduler.mutex.Unlock()
+	if !exists {
+		return newCodedError(ErrCodeNotFound, "scheduled job %s not found", name)
+	}
+	if seconds := settings.IntervalSeconds; seconds != nil && (*seconds < 0 || (*seconds > 0 && time.Duration(*seconds)*time.Second < SchedulerTickInterval)) {
+		return newCodedError(ErrCodeInvalidRequest, "interval_seconds must be 0 (default) or at least %d", int(SchedulerTickInterval.Seconds()))
+	}
+
+	jobs := make(map[string]ScheduledJobSettings)
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		for jobName, jobSettings := range snapshot.ScheduledJobs {
+			jobs[jobName] = jobSettings
+		}
+	}
+	merged := jobs[name]
+	if settings.Enabled != nil {
+		merged.Enabled = settings.Enabled
+	}
+	if settings.IntervalSeconds != nil {
+		merged.IntervalSeconds = settings.IntervalSeconds
+	}
+	jobs[name] = merged
+
+	value, err := json.Marshal(jobs)
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduled job settings: %w", err)
+	}
+	if err := b.SetConfigValue(ConfigKeyScheduledJobs, string(value)); err != nil {
+		return err
+	}
+	return b.ReloadConfig()
+}
+
+// getScheduledJobsHandler returns all scheduled jobs with their last-run status
+func (ws *WebServer) getScheduledJobsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, ScheduledJobsResponse{Jobs: ws.bridge.scheduler.Statuses()})
+}
+
+// updateScheduledJobHandler enables, disables or changes the interval of a job
+func (ws *WebServer) updateScheduledJobHandler(c *gin.Context) {
+	var settings ScheduledJobSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.UpdateScheduledJob(c.Param("name"), settings); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Scheduled job updated successfully"})
+}
+
+// runScheduledJobHandler runs a job immediately
+func (ws *WebServer) runScheduledJobHandler(c *gin.Context) {
+	if err := ws.bridge.scheduler.RunNow(c.Param("name")); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, MessageResponse{Message: "Scheduled job started"})
+}
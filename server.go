@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// normalizeBasePath cleans a base_path setting into "/prefix" form, or "" for the root
+func normalizeBasePath(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), "/")
+	if value == "" {
+		return ""
+	}
+	return "/" + value
+}
+
+// splitDomains parses a comma-separated list of domain names
+func splitDomains(value string) []string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// webScheme returns the scheme the web server is served with
+func webScheme(config *Config) string {
+	if (config.TLSCertFile != "" && config.TLSKeyFile != "") || len(config.TLSACMEDomains) > 0 {
+		return "https"
+	}
+	return "http"
+}
+
+// path prefixes an absolute path with the base path, for redirects and cookies
+func (ws *WebServer) path(p string) string {
+	return ws.basePath + p
+}
+
+// requestBaseURL returns the URL FilaBridge was reached at for this request, including the
+// base path. Behind a reverse proxy the scheme comes from X-Forwarded-Proto.
+func (ws *WebServer) requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	return scheme + "://" + c.Request.Host + ws.basePath
+}
+
+// publicBaseURL returns the base URL for links that leave the browser, such as NFC tag and
+// QR code URLs: the configured external URL, or the URL of this request when none is set
+func (ws *WebServer) publicBaseURL(c *gin.Context) string {
+	if snapshot := ws.bridge.GetConfigSnapshot(); snapshot != nil && strings.TrimSpace(snapshot.ExternalURL) != "" {
+		return strings.TrimSuffix(strings.TrimSpace(snapshot.ExternalURL), "/")
+	}
+	return ws.requestBaseURL(c)
+}
+
+// handler returns the router, served under the base path when one is configured. The
+// prefix is removed before routing so routes and access rules stay root-relative.
+func (ws *WebServer) handler() http.Handler {
+	if ws.basePath == "" {
+		return ws.router
+	}
+
+	stripped := http.StripPrefix(ws.basePath, ws.router)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, ws.basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		case r.URL.Path == HealthzPath || r.URL.Path == ReadyzPath:
+			// Probes are set up per container and shouldn't have to know the base path
+			ws.router.ServeHTTP(w, r)
+		case r.URL.Path == "/" || r.URL.Path == ws.basePath:
+			http.Redirect(w, r, ws.basePath+"/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// Start starts the web server, over HTTPS when a certificate or ACME domains are configured.
+// It returns http.ErrServerClosed after Shutdown.
+func (ws *WebServer) Start(port string) error {
+	config := ws.bridge.GetConfigSnapshot()
+	server := ws.server
+	server.Addr = ":" + port
+	server.Handler = ws.handler()
+
+	switch {
+	case config.TLSCertFile != "" && config.TLSKeyFile != "":
+		webLog.Info("Serving HTTPS with configured certificate", "cert_file", config.TLSCertFile)
+		return server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+
+	case len(config.TLSACMEDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.TLSACMEDomains...),
+			Cache:      autocert.DirCache(filepath.Join(filepath.Dir(getDBFilePath()), ACMECacheDir)),
+			Email:      config.TLSACMEEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+
+		// HTTP-01 challenges arrive on port 80; other requests there are redirected to HTTPS.
+		// TLS-ALPN-01 challenges are answered on the HTTPS port itself.
+		go func() {
+			if err := http.ListenAndServe(":80", manager.HTTPHandler(nil)); err != nil {
+				webLog.Warn("ACME HTTP challenge listener stopped; only TLS-ALPN challenges will work", "error", err)
+			}
+		}()
+
+		webLog.Info("Serving HTTPS with ACME certificates", "domains", config.TLSACMEDomains)
+		return server.ListenAndServeTLS("", "")
+
+	default:
+		return server.ListenAndServe()
+	}
+}
+
+// SetMonitor hands the server the printer monitor, which print event webhooks feed into
+func (ws *WebServer) SetMonitor(monitor *PrinterMonitor) {
+	ws.monitor = monitor
+}
+
+// Shutdown stops accepting connections and waits for requests in progress until ctx is done
+func (ws *WebServer) Shutdown(ctx context.Context) error {
+	return ws.server.Shutdown(ctx)
+}
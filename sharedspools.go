@@ -0,0 +1,232 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SharedSpool is a spool allowed on several toolheads at once, e.g. one spool feeding two
+// printers through a bowden splitter, or swapped between printers that are rarely busy
+// together. Each print's usage is still charged under the printer and toolhead that printed
+// it, so the history shows which printer used what.
+type SharedSpool struct {
+	SpoolID          int                `json:"spool_id"`
+	SpoolmanInstance string             `json:"spoolman_instance,omitempty"`
+	Toolheads        []ToolheadMapping  `json:"toolheads"`        // Toolheads it's mapped to now
+	UsageByPrinter   map[string]float64 `json:"usage_by_printer"` // Grams charged per printer, from the print history
+	SharedAt         time.Time          `json:"shared_at"`
+}
+
+// rowsQuerier is a database or transaction to read from
+type rowsQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// sharedSpoolIDs returns the shared spools of a Spoolman instance
+func sharedSpoolIDs(db rowsQuerier, instance string) (map[int]bool, error) {
+	rows, err := db.Query("SELECT spool_id FROM shared_spools WHERE spoolman_instance = ?", instance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared spools: %w", err)
+	}
+	defer rows.Close()
+
+	shared := make(map[int]bool)
+	for rows.Next() {
+		var spoolID int
+		if err := rows.Scan(&spoolID); err != nil {
+			return nil, fmt.Errorf("failed to scan shared spool: %w", err)
+		}
+		shared[spoolID] = true
+	}
+	return shared, rows.Err()
+}
+
+// isSpoolShared reports whether a spool of a Spoolman instance may be on several toolheads
+func (b *FilamentBridge) isSpoolShared(instance string, spoolID int) (bool, error) {
+	var count int
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM shared_spools WHERE spoolman_instance = ? AND spool_id = ?", instance, spoolID).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check shared spool: %w", err)
+	}
+	return count > 0, nil
+}
+
+// spoolToolheads returns the toolheads a spool is mapped to, across the printers using its
+// Spoolman instance
+func (b *FilamentBridge) spoolToolheads(instance string, spoolID int) ([]ToolheadMapping, error) {
+	return b.spoolToolheadsIn(b.GetConfigSnapshot(), instance, spoolID)
+}
+
+// spoolToolheadsIn is spoolToolheads with the printers' instances taken from config, so it
+// can be called while holding b.mutex
+func (b *FilamentBridge) spoolToolheadsIn(config *Config, instance string, spoolID int) ([]ToolheadMapping, error) {
+	allMappings, err := b.GetAllToolheadMappings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get toolhead mappings: %w", err)
+	}
+	toolheads := []ToolheadMapping{}
+	for printerName, mappings := range allMappings {
+		if printerSpoolmanInstance(config, printerName) != instance {
+			continue
+		}
+		for _, mapping := range mappings {
+			if mapping.SpoolID == spoolID {
+				toolheads = append(toolheads, mapping)
+			}
+		}
+	}
+	sort.Slice(toolheads, func(i, j int) bool {
+		if toolheads[i].PrinterName != toolheads[j].PrinterName {
+			return toolheads[i].PrinterName < toolheads[j].PrinterName
+		}
+		return toolheads[i].ToolheadID < toolheads[j].ToolheadID
+	})
+	return toolheads, nil
+}
+
+// SetSpoolShared allows or stops allowing a spool on several toolheads. The spool is in the
+// Spoolman instance of printerName, the main one when it's empty. A spool still mapped to
+// more than one toolhead can't stop being shared.
+func (b *FilamentBridge) SetSpoolShared(printerName string, spoolID int, shared bool) error {
+	if printerName != "" {
+		_, config, exists := b.findPrinterConfig(printerName)
+		if !exists {
+			return newCodedError(ErrCodePrinterNotFound, "printer %s not found", printerName)
+		}
+		printerName = resolvePrinterName(config)
+	}
+	instance := b.spoolmanInstanceOf(printerName)
+	if _, err := b.spoolmanFor(printerName).GetSpool(spoolID); err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", spoolID, err)
+	}
+
+	if shared {
+		b.mutex.Lock()
+		_, err := b.db.Exec("INSERT OR IGNORE INTO shared_spools (spoolman_instance, spool_id, shared_at) VALUES (?, ?, ?)", instance, spoolID, time.Now())
+		b.mutex.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to share spool: %w", err)
+		}
+		bridgeLog.Info("Spool shared between toolheads", "spool_id", spoolID, "spoolman_instance", instance)
+		return nil
+	}
+
+	// Mappings are checked against shared spools while holding the mutex, so checking here
+	// too keeps a second toolhead from being mapped in between
+	b.mutex.Lock()
+	toolheads, err := b.spoolToolheadsIn(b.config, instance, spoolID)
+	if err != nil {
+		b.mutex.Unlock()
+		return err
+	}
+	if len(toolheads) > 1 {
+		b.mutex.Unlock()
+		return newCodedError(ErrCodeConflict, "spool %d is mapped to %d toolheads; unmap all but one before it stops being shared", spoolID, len(toolheads))
+	}
+	_, err = b.db.Exec("DELETE FROM shared_spools WHERE spoolman_instance = ? AND spool_id = ?", instance, spoolID)
+	b.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to stop sharing spool: %w", err)
+	}
+	bridgeLog.Info("Spool no longer shared", "spool_id", spoolID, "spoolman_instance", instance)
+	return nil
+}
+
+// GetSharedSpools returns the shared spools with the toolheads they're on and how much each
+// printer has used of them
+func (b *FilamentBridge) GetSharedSpools() ([]SharedSpool, error) {
+	rows, err := b.db.Query("SELECT spoolman_instance, spool_id, shared_at FROM shared_spools ORDER BY spoolman_instance, spool_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared spools: %w", err)
+	}
+	spools := []SharedSpool{}
+	for rows.Next() {
+		var spool SharedSpool
+		if err := rows.Scan(&spool.SpoolmanInstance, &spool.SpoolID, &spool.SharedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan shared spool: %w", err)
+		}
+		spools = append(spools, spool)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get shared spools: %w", err)
+	}
+
+	for i := range spools {
+		spool := &spools[i]
+		if spool.Toolheads, err = b.spoolToolheads(spool.SpoolmanInstance, spool.SpoolID); err != nil {
+			return nil, err
+		}
+		if spool.UsageByPrinter, err = b.sharedSpoolUsage(spool.SpoolmanInstance, spool.SpoolID); err != nil {
+			return nil, err
+		}
+	}
+	return spools, nil
+}
+
+// sharedSpoolUsage returns the grams of a spool each printer using its Spoolman instance has
+// been charged, leaving out reverted entries
+func (b *FilamentBridge) sharedSpoolUsage(instance string, spoolID int) (map[string]float64, error) {
+	rows, err := b.db.Query(
+		"SELECT printer_name, SUM(filament_used) FROM print_history WHERE spool_id = ? AND COALESCE(reverted, 0) = 0 GROUP BY printer_name",
+		spoolID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared spool usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]float64)
+	for rows.Next() {
+		var printerName string
+		var grams float64
+		if err := rows.Scan(&printerName, &grams); err != nil {
+			return nil, fmt.Errorf("failed to scan shared spool usage: %w", err)
+		}
+		if b.spoolmanInstanceOf(printerName) == instance {
+			usage[printerName] = grams
+		}
+	}
+	return usage, rows.Err()
+}
+
+// getSharedSpoolsHandler lists the shared spools
+func (ws *WebServer) getSharedSpoolsHandler(c *gin.Context) {
+	spools, err := ws.bridge.GetSharedSpools()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SharedSpoolsResponse{Spools: spools})
+}
+
+// setSpoolSharedHandler allows or stops allowing a spool on several toolheads
+func (ws *WebServer) setSpoolSharedHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var req struct {
+		Shared      bool   `json:"shared"`
+		PrinterName string `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.SetSpoolShared(req.PrinterName, spoolID, req.Shared); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spool sharing updated successfully"})
+}
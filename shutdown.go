@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// unfinishedPrint is a job whose usage wasn't recorded when FilaBridge stopped: either one that
+// was still printing, or one that had finished but whose processing was interrupted
+type unfinishedPrint struct {
+	PrinterID   string
+	Filename    string
+	DisplayName string
+	JobID       int
+	Telemetry   jobTelemetry
+	Finished    bool
+	FinishedAt  time.Time // When the print was seen to finish, zero while it's printing
+}
+
+// saveUnfinishedPrint stores a print to be picked up again on the next start
+func (b *FilamentBridge) saveUnfinishedPrint(print unfinishedPrint) {
+	telemetry, err := json.Marshal(print.Telemetry)
+	if err != nil {
+		monitorLog.Warn("Failed to encode job telemetry", "printer_id", print.PrinterID, "error", err)
+		telemetry = nil
+	}
+	var finishedAt *time.Time
+	if !print.FinishedAt.IsZero() {
+		finishedAt = &print.FinishedAt
+	}
+	if _, err := b.db.Exec(
+		"INSERT INTO unfinished_prints (printer_id, filename, display_name, job_id, telemetry, finished, finished_at, saved_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		print.PrinterID, print.Filename, print.DisplayName, print.JobID, string(telemetry), print.Finished, finishedAt, time.Now(),
+	); err != nil {
+		monitorLog.Error("Failed to save unfinished print, its usage will not be recorded",
+			"printer_id", print.PrinterID, "job", print.Filename, "error", err)
+		return
+	}
+	monitorLog.Info("Saved unfinished print for the next start", "printer_id", print.PrinterID, "job", print.Filename, "finished", print.Finished)
+}
+
+// SaveTrackedPrints saves the jobs printers are in the middle of, so a print that finishes
+// while FilaBridge is down is still recorded once it's back. It's called at shutdown, after
+// monitoring has stopped.
+func (b *FilamentBridge) SaveTrackedPrints() {
+	b.mutex.RLock()
+	var prints []unfinishedPrint
+	for printerID, printing := range b.wasPrinting {
+		if !printing || b.currentJobFile[printerID] == "" || b.processingPrints[printerID] {
+			continue
+		}
+		prints = append(prints, unfinishedPrint{
+			PrinterID:   printerID,
+			Filename:    b.currentJobFile[printerID],
+			DisplayName: b.currentJobName[printerID],
+			JobID:       b.currentJobID[printerID],
+			Telemetry:   b.jobTelemetry[printerID],
+		})
+	}
+	b.mutex.RUnlock()
+
+	for _, print := range prints {
+		b.saveUnfinishedPrint(print)
+	}
+}
+
+// restoreUnfinishedPrints takes the prints saved at the last shutdown. Jobs that were still
+// printing are tracked again, so the next poll records them once they're done; finished ones
+// are returned to be processed.
+func (b *FilamentBridge) restoreUnfinishedPrints() ([]unfinishedPrint, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin restoring unfinished prints: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT printer_id, filename, COALESCE(display_name, ''), COALESCE(job_id, 0), COALESCE(telemetry, ''), COALESCE(finished, 0), finished_at FROM unfinished_prints ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unfinished prints: %w", err)
+	}
+	var prints []unfinishedPrint
+	for rows.Next() {
+		var print unfinishedPrint
+		var telemetry string
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&print.PrinterID, &print.Filename, &print.DisplayName, &print.JobID, &telemetry, &print.Finished, &finishedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan unfinished print: %w", err)
+		}
+		print.FinishedAt = finishedAt.Time
+		if telemetry != "" {
+			if err := json.Unmarshal([]byte(telemetry), &print.Telemetry); err != nil {
+				monitorLog.Warn("Failed to decode saved job telemetry", "printer_id", print.PrinterID, "error", err)
+			}
+		}
+		prints = append(prints, print)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get unfinished prints: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM unfinished_prints"); err != nil {
+		return nil, fmt.Errorf("failed to clear unfinished prints: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restored unfinished prints: %w", err)
+	}
+
+	var finished []unfinishedPrint
+	b.mutex.Lock()
+	for _, print := range prints {
+		if print.Finished {
+			finished = append(finished, print)
+			continue
+		}
+		b.wasPrinting[print.PrinterID] = true
+		b.currentJobFile[print.PrinterID] = print.Filename
+		b.currentJobID[print.PrinterID] = print.JobID
+		b.currentJobName[print.PrinterID] = print.DisplayName
+		b.jobTelemetry[print.PrinterID] = print.Telemetry
+		monitorLog.Info("Resumed tracking print from before restart", "printer_id", print.PrinterID, "job", print.Filename, "job_id", print.JobID)
+	}
+	b.mutex.Unlock()
+	return finished, nil
+}
+
+// resumeFinishedPrint processes a print that finished but wasn't processed before shutdown
+func (b *FilamentBridge) resumeFinishedPrint(ctx context.Context, print unfinishedPrint) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+	config, exists := snapshot.Printers[print.PrinterID]
+	if !exists {
+		monitorLog.Warn("Printer of an unfinished print was removed, not recording its usage", "printer_id", print.PrinterID, "job", print.Filename)
+		return
+	}
+
+	b.mutex.Lock()
+	if b.processingPrints[print.PrinterID] {
+		b.mutex.Unlock()
+		b.saveUnfinishedPrint(print)
+		return
+	}
+	b.processingPrints[print.PrinterID] = true
+	b.mutex.Unlock()
+
+	monitorLog.Info("Processing print that finished before restart", "printer_id", print.PrinterID, "job", print.Filename)
+	err := b.handlePrusaLinkPrintFinished(ctx, config, print)
+
+	b.mutex.Lock()
+	b.processingPrints[print.PrinterID] = false
+	b.mutex.Unlock()
+
+	switch {
+	case err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		b.saveUnfinishedPrint(print)
+	case err != nil:
+		monitorLog.Error("Error handling print that finished before restart", "printer_id", print.PrinterID, "error", err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// spoolmanQRPrefix starts the QR codes Spoolman's web UI prints on spool labels, e.g. "web+spoolman:s-12"
+const spoolmanQRPrefix = "web+spoolman:s-"
+
+// spoolmanSpoolPathRegex matches a spool page URL in Spoolman, e.g. http://spoolman:7912/spool/show/12
+var spoolmanSpoolPathRegex = regexp.MustCompile(`/spool/show/(\d+)/?$`)
+
+// tagPayload is the subset of an OpenSpool JSON tag used to find the spool it's on. OpenSpool
+// doesn't store a Spoolman ID, so spool_id or spoolman_id are only there when the tag was
+// written with one.
+type tagPayload struct {
+	SpoolID    json.Number `json:"spool_id"`
+	SpoolmanID json.Number `json:"spoolman_id"`
+	Type       string      `json:"type"`
+	Brand      string      `json:"brand"`
+	ColorHex   string      `json:"color_hex"`
+}
+
+// resolveSpoolCode works out the Spoolman spool ID from a scanned spool code: a plain ID, a
+// Spoolman label QR code or spool URL, or an OpenSpool JSON payload. Codes for a refilled
+// spool resolve to the record of its refill. The spool is looked up in the printer's Spoolman
+// instance, the main one when printerName is empty.
+func (b *FilamentBridge) resolveSpoolCode(printerName, code string) (int, error) {
+	instance := b.spoolmanInstanceOf(printerName)
+	id, err := b.parseSpoolCode(instance, code)
+	if err != nil {
+		return 0, err
+	}
+	return b.currentSpoolID(instance, id), nil
+}
+
+// parseSpoolCode reads the spool ID a code names
+func (b *FilamentBridge) parseSpoolCode(instance, code string) (int, error) {
+	code = strings.TrimSpace(code)
+
+	if id, err := strconv.Atoi(code); err == nil {
+		return id, nil
+	}
+
+	if strings.HasPrefix(strings.ToLower(code), spoolmanQRPrefix) {
+		id, err := strconv.Atoi(code[len(spoolmanQRPrefix):])
+		if err != nil {
+			return 0, newCodedError(ErrCodeInvalidRequest, "invalid Spoolman QR code %q", code)
+		}
+		return id, nil
+	}
+
+	if parsed, err := url.Parse(code); err == nil && parsed.Host != "" {
+		if match := spoolmanSpoolPathRegex.FindStringSubmatch(parsed.Path); match != nil {
+			return strconv.Atoi(match[1])
+		}
+	}
+
+	if strings.HasPrefix(code, "{") {
+		var payload tagPayload
+		if err := json.Unmarshal([]byte(code), &payload); err != nil {
+			return 0, newCodedError(ErrCodeInvalidRequest, "invalid tag payload: %v", err)
+		}
+		return b.resolveTagPayload(instance, payload)
+	}
+
+	return 0, newCodedError(ErrCodeInvalidRequest, "unrecognized spool code %q", code)
+}
+
+// resolveTagPayload finds the spool an OpenSpool payload describes: by the ID
+// written on the tag, otherwise the one active spool with the same material, brand and color
+func (b *FilamentBridge) resolveTagPayload(instance string, payload tagPayload) (int, error) {
+	for _, id := range []json.Number{payload.SpoolmanID, payload.SpoolID} {
+		if parsed, err := strconv.Atoi(id.String()); err == nil && parsed > 0 {
+			return parsed, nil
+		}
+	}
+
+	material := strings.TrimSpace(payload.Type)
+	brand := strings.TrimSpace(payload.Brand)
+	color := normalizeTagColor(payload.ColorHex)
+	if material == "" {
+		return 0, newCodedError(ErrCodeInvalidRequest, "tag payload has no spool ID or material")
+	}
+
+	spools, err := b.spoolmanInstance(instance).GetAllSpools()
+	if err != nil {
+		return 0, newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+
+	var matches []int
+	for _, spool := range spools {
+		if spool.Archived || !strings.EqualFold(spool.Material, material) {
+			continue
+		}
+		if brand != "" && !strings.EqualFold(spool.Brand, brand) {
+			continue
+		}
+		if color != "" && (spool.Filament == nil || normalizeTagColor(spool.Filament.ColorHex) != color) {
+			continue
+		}
+		matches = append(matches, spool.ID)
+	}
+
+	description := strings.TrimSpace(fmt.Sprintf("%s %s %s", brand, material, color))
+	switch len(matches) {
+	case 0:
+		return 0, newCodedError(ErrCodeNotFound, "no active spool matches the tag (%s)", description)
+	case 1:
+		return matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, id := range matches {
+			ids[i] = strconv.Itoa(id)
+		}
+		return 0, newCodedError(ErrCodeConflict, "spools %s all match the tag (%s); write the spool ID to the tag to tell them apart",
+			strings.Join(ids, ", "), description)
+	}
+}
+
+// normalizeTagColor reduces a color to lowercase RRGGBB, dropping any # and alpha channel
+func normalizeTagColor(color string) string {
+	color = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if len(color) > 6 {
+		color = color[:6]
+	}
+	return color
+}
+
+// firstNonEmpty returns the first value that isn't blank
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewVendor is a vendor to add to Spoolman
+type NewVendor struct {
+	Name             string   `json:"name"`
+	EmptySpoolWeight *float64 `json:"empty_spool_weight"` // Grams of the vendor's empty spools, if known
+	Comment          string   `json:"comment"`
+	PrinterName      string   `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+}
+
+// NewFilament is a filament type to add to Spoolman
+type NewFilament struct {
+	Name                 string   `json:"name"`
+	VendorID             int      `json:"vendor_id"`
+	VendorName           string   `json:"vendor_name"` // Used when vendor_id is omitted; the vendor is added if Spoolman has none by this name
+	Material             string   `json:"material"`
+	ColorHex             string   `json:"color_hex"`
+	Density              float64  `json:"density"`  // g/cm³; default_filament_density when omitted
+	Diameter             float64  `json:"diameter"` // mm; 1.75 when omitted
+	Weight               float64  `json:"weight"`   // Net grams of a full spool
+	SpoolWeight          float64  `json:"spool_weight"`
+	Price                *float64 `json:"price"`
+	SettingsExtruderTemp int      `json:"settings_extruder_temp"`
+	SettingsBedTemp      int      `json:"settings_bed_temp"`
+	Comment              string   `json:"comment"`
+	PrinterName          string   `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+}
+
+// NewSpool is a received spool to add to Spoolman, optionally mapped straight to a toolhead
+type NewSpool struct {
+	FilamentID    int      `json:"filament_id"`
+	InitialWeight *float64 `json:"initial_weight"` // Net grams; the filament's weight when omitted
+	SpoolWeight   *float64 `json:"spool_weight"`   // Empty spool grams; the filament's, then the vendor's, when omitted
+	LotNr         string   `json:"lot_nr"`
+	Price         *float64 `json:"price"` // The filament's price applies when omitted
+	Location      string   `json:"location"`
+	Comment       string   `json:"comment"`
+	PrinterName   string   `json:"printer_name"` // Picks the Spoolman instance; the main one when empty
+	ToolheadID    *int     `json:"toolhead_id"`  // Maps the new spool to this toolhead of printer_name
+	Barcode       string   `json:"barcode"`      // Manufacturer barcode on the spool, remembered for its filament
+}
+
+// NewSpoolResult describes a spool added through FilaBridge
+type NewSpoolResult struct {
+	Spool       *SpoolmanSpool `json:"spool"`
+	PrinterName string         `json:"printer_name,omitempty"`
+	ToolheadID  *int           `json:"toolhead_id,omitempty"` // Toolhead the spool was mapped to, if any
+	Warning     string         `json:"warning,omitempty"`
+}
+
+// checkSpoolmanPrinter checks that a printer picking a Spoolman instance exists. An empty
+// name picks the main instance.
+func (b *FilamentBridge) checkSpoolmanPrinter(printerName string) error {
+	if printerName == "" {
+		return nil
+	}
+	if _, _, exists := b.findPrinterConfig(printerName); !exists {
+		return newCodedError(ErrCodePrinterNotFound, "printer %s not found", printerName)
+	}
+	return nil
+}
+
+// findVendorByName returns the vendor of a Spoolman instance with a name (case-insensitive),
+// or nil if there is none
+func (b *FilamentBridge) findVendorByName(printerName, name string) (*SpoolmanVendor, error) {
+	vendors, err := b.spoolmanFor(printerName).GetAllVendors()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get vendors: %v", err)
+	}
+	for i := range vendors {
+		if strings.EqualFold(strings.TrimSpace(vendors[i].Name), name) {
+			return &vendors[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateVendor adds a vendor to Spoolman. A vendor whose name is already taken is refused,
+// so the same brand doesn't end up split over two records.
+func (b *FilamentBridge) CreateVendor(vendor NewVendor) (*SpoolmanVendor, error) {
+	name := strings.TrimSpace(vendor.Name)
+	if name == "" {
+		return nil, newCodedError(ErrCodeInvalidRequest, "vendor name is required")
+	}
+	if vendor.EmptySpoolWeight != nil && *vendor.EmptySpoolWeight < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "empty_spool_weight can't be negative")
+	}
+	if err := b.checkSpoolmanPrinter(vendor.PrinterName); err != nil {
+		return nil, err
+	}
+
+	existing, err := b.findVendorByName(vendor.PrinterName, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newCodedError(ErrCodeConflict, "vendor %q already exists with ID %d", existing.Name, existing.ID)
+	}
+
+	data := map[string]interface{}{"name": name}
+	if vendor.EmptySpoolWeight != nil {
+		data["empty_spool_weight"] = *vendor.EmptySpoolWeight
+	}
+	if comment := strings.TrimSpace(vendor.Comment); comment != "" {
+		data["comment"] = comment
+	}
+	created, err := b.spoolmanFor(vendor.PrinterName).CreateVendor(data)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to create vendor: %v", err)
+	}
+
+	bridgeLog.Info("Created vendor in Spoolman", "vendor_id", created.ID, "name", created.Name,
+		"spoolman_instance", b.spoolmanInstanceOf(vendor.PrinterName))
+	return created, nil
+}
+
+// CreateFilament adds a filament type to Spoolman. A vendor given by name is reused if
+// Spoolman already has it and added otherwise.
+func (b *FilamentBridge) CreateFilament(filament NewFilament) (*SpoolmanFilament, error) {
+	material := strings.TrimSpace(filament.Material)
+	var problems []string
+	if material == "" {
+		problems = append(problems, "material is required")
+	}
+	colorHex := strings.TrimPrefix(strings.TrimSpace(filament.ColorHex), "#")
+	if colorHex != "" {
+		if _, _, _, ok := parseColorHex(colorHex); !ok {
+			problems = append(problems, fmt.Sprintf("color_hex %q isn't an RRGGBB or RRGGBBAA color", filament.ColorHex))
+		}
+	}
+	if filament.Density < 0 || filament.Diameter < 0 || filament.Weight < 0 || filament.SpoolWeight < 0 {
+		problems = append(problems, "density, diameter, weight and spool_weight can't be negative")
+	}
+	if filament.Price != nil && *filament.Price < 0 {
+		problems = append(problems, "price can't be negative")
+	}
+	if len(problems) > 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "%s", strings.Join(problems, "; "))
+	}
+	if err := b.checkSpoolmanPrinter(filament.PrinterName); err != nil {
+		return nil, err
+	}
+
+	vendorID := filament.VendorID
+	if vendorName := strings.TrimSpace(filament.VendorName); vendorID == 0 && vendorName != "" {
+		vendor, err := b.findVendorByName(filament.PrinterName, vendorName)
+		if err != nil {
+			return nil, err
+		}
+		if vendor == nil {
+			if vendor, err = b.CreateVendor(NewVendor{Name: vendorName, PrinterName: filament.PrinterName}); err != nil {
+				return nil, err
+			}
+		}
+		vendorID = vendor.ID
+	}
+
+	density := filament.Density
+	if density == 0 {
+		density = defaultFilamentDensity
+		if snapshot := b.GetConfigSnapshot(); snapshot != nil && snapshot.DefaultFilamentDensity > 0 {
+			density = snapshot.DefaultFilamentDensity
+		}
+	}
+	diameter := filament.Diameter
+	if diameter == 0 {
+		diameter = defaultFilamentDiameter
+	}
+
+	data := map[string]interface{}{
+		"material": material,
+		"density":  density,
+		"diameter": diameter,
+	}
+	if name := strings.TrimSpace(filament.Name); name != "" {
+		data["name"] = name
+	}
+	if vendorID != 0 {
+		data["vendor_id"] = vendorID
+	}
+	if colorHex != "" {
+		data["color_hex"] = strings.ToUpper(colorHex)
+	}
+	if filament.Weight > 0 {
+		data["weight"] = filament.Weight
+	}
+	if filament.SpoolWeight > 0 {
+		data["spool_weight"] = filament.SpoolWeight
+	}
+	if filament.Price != nil {
+		data["price"] = *filament.Price
+	}
+	if filament.SettingsExtruderTemp > 0 {
+		data["settings_extruder_temp"] = filament.SettingsExtruderTemp
+	}
+	if filament.SettingsBedTemp > 0 {
+		data["settings_bed_temp"] = filament.SettingsBedTemp
+	}
+	if comment := strings.TrimSpace(filament.Comment); comment != "" {
+		data["comment"] = comment
+	}
+	created, err := b.spoolmanFor(filament.PrinterName).CreateFilament(data)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to create filament: %v", err)
+	}
+
+	bridgeLog.Info("Created filament in Spoolman", "filament_id", created.ID, "name", created.Name, "material", created.Material,
+		"spoolman_instance", b.spoolmanInstanceOf(filament.PrinterName))
+	return created, nil
+}
+
+// CreateSpool adds a received spool to Spoolman and, when a toolhead is given, maps it there
+// so receiving and loading a spool is one step. The toolhead is checked before the spool is
+// added; a mapping refused afterwards, e.g. by a toolhead rule, leaves the spool in Spoolman
+// and the error says so.
+func (b *FilamentBridge) CreateSpool(spool NewSpool) (*NewSpoolResult, error) {
+	if spool.FilamentID <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "filament_id is required")
+	}
+	if err := b.checkSpoolmanPrinter(spool.PrinterName); err != nil {
+		return nil, err
+	}
+	if spool.Barcode != "" && !isProductBarcode(spool.Barcode) {
+		return nil, newCodedError(ErrCodeInvalidRequest, "%q isn't an EAN or UPC barcode", spool.Barcode)
+	}
+	if spool.ToolheadID != nil {
+		if spool.PrinterName == "" {
+			return nil, newCodedError(ErrCodeInvalidRequest, "printer_name is required to map the spool to a toolhead")
+		}
+		_, config, _ := b.findPrinterConfig(spool.PrinterName)
+		if *spool.ToolheadID < 0 || *spool.ToolheadID >= config.SlotCount() {
+			return nil, newCodedError(ErrCodeInvalidRequest, "%s has no toolhead %d", spool.PrinterName, *spool.ToolheadID)
+		}
+	}
+
+	client := b.spoolmanFor(spool.PrinterName)
+	filaments, err := client.GetAllFilaments()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get filaments: %v", err)
+	}
+	var filament *SpoolmanFilament
+	for i := range filaments {
+		if filaments[i].ID == spool.FilamentID {
+			filament = &filaments[i]
+			break
+		}
+	}
+	if filament == nil {
+		return nil, newCodedError(ErrCodeNotFound, "filament %d not found", spool.FilamentID)
+	}
+
+	weight := filament.Weight
+	if spool.InitialWeight != nil {
+		weight = *spool.InitialWeight
+	}
+	if weight <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "initial_weight is required; filament %d has no weight set", spool.FilamentID)
+	}
+	// Spoolman falls back to the filament's and vendor's spool weights itself when none is sent
+	if spool.SpoolWeight != nil && *spool.SpoolWeight < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_weight can't be negative")
+	}
+	if spool.Price != nil && *spool.Price < 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "price can't be negative")
+	}
+
+	data := map[string]interface{}{
+		"filament_id":    spool.FilamentID,
+		"initial_weight": weight,
+	}
+	if spool.SpoolWeight != nil {
+		data["spool_weight"] = *spool.SpoolWeight
+	}
+	if lotNr := strings.TrimSpace(spool.LotNr); lotNr != "" {
+		data["lot_nr"] = lotNr
+	}
+	if spool.Price != nil {
+		data["price"] = *spool.Price
+	}
+	if location := strings.TrimSpace(spool.Location); location != "" && spool.ToolheadID == nil {
+		data["location"] = location
+	}
+	if comment := strings.TrimSpace(spool.Comment); comment != "" {
+		data["comment"] = comment
+	}
+	created, err := client.CreateSpool(data)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to create spool: %v", err)
+	}
+	*created = client.normalizeSpoolData(*created)
+	bridgeLog.Info("Created spool in Spoolman", "spool_id", created.ID, "filament_id", spool.FilamentID, "grams", weight,
+		"lot_nr", spool.LotNr, "spoolman_instance", b.spoolmanInstanceOf(spool.PrinterName))
+
+	// Barcode mappings are for the main instance's filaments
+	if spool.Barcode != "" && b.spoolmanInstanceOf(spool.PrinterName) == "" {
+		if err := b.rememberBarcode(spool.Barcode, spool.FilamentID); err != nil {
+			bridgeLog.Warn("Failed to remember spool barcode", "barcode", spool.Barcode, "filament_id", spool.FilamentID, "error", err)
+		}
+	}
+
+	result := &NewSpoolResult{Spool: created}
+	if spool.ToolheadID == nil {
+		return result, nil
+	}
+
+	assignment := ToolheadAssignment{ToolheadID: *spool.ToolheadID, SpoolID: created.ID}
+	warnings, err := b.checkToolheadRules(spool.PrinterName, []ToolheadAssignment{assignment})
+	if err == nil {
+		err = b.SetToolheadMapping(spool.PrinterName, assignment.ToolheadID, created.ID)
+	}
+	if err != nil {
+		return nil, newCodedError(errorCode(err, ErrCodeInternal), "spool %d was created but not mapped: %v", created.ID, err)
+	}
+	result.PrinterName = spool.PrinterName
+	result.ToolheadID = spool.ToolheadID
+	result.Warning = strings.Join(warnings, "; ")
+	return result, nil
+}
+
+// vendorsHandler returns all vendors as JSON
+func (ws *WebServer) vendorsHandler(c *gin.Context) {
+	vendors, err := ws.bridge.spoolmanFor(c.Query("printer_name")).GetAllVendors()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
+		return
+	}
+	c.JSON(http.StatusOK, vendors)
+}
+
+// createVendorHandler adds a vendor to Spoolman
+func (ws *WebServer) createVendorHandler(c *gin.Context) {
+	var req NewVendor
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	vendor, err := ws.bridge.CreateVendor(req)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusCreated, VendorCreatedResponse{Message: fmt.Sprintf("Created vendor %s", vendor.Name), Vendor: vendor})
+}
+
+// createFilamentHandler adds a filament type to Spoolman
+func (ws *WebServer) createFilamentHandler(c *gin.Context) {
+	var req NewFilament
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	filament, err := ws.bridge.CreateFilament(req)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusCreated, FilamentCreatedResponse{Message: fmt.Sprintf("Created filament %d", filament.ID), Filament: filament})
+}
+
+// createSpoolHandler adds a received spool to Spoolman, mapping it to a toolhead if asked
+func (ws *WebServer) createSpoolHandler(c *gin.Context) {
+	var req NewSpool
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	result, err := ws.bridge.CreateSpool(req)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	message := fmt.Sprintf("Created spool %d", result.Spool.ID)
+	if result.ToolheadID != nil {
+		message = fmt.Sprintf("Created spool %d and mapped it to %s toolhead %d", result.Spool.ID, result.PrinterName, *result.ToolheadID)
+		ws.BroadcastStatus()
+	}
+	c.JSON(http.StatusCreated, SpoolCreatedResponse{Message: message, Result: result})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SpoolEventEmptied is logged when a spool is archived because usage left it empty
+const SpoolEventEmptied = "emptied"
+
+// handleSpoolEmptied checks a spool after a usage update takes filament from it. When the
+// update leaves it at or below the auto-pause minimum weight, which counts as empty, it is
+// reported empty and, if configured, archived in Spoolman and unmapped from the toolhead.
+// Spools that were already empty aren't reported again.
+func (b *FilamentBridge) handleSpoolEmptied(printerName string, toolheadID, spoolID int, usedWeight float64) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return
+	}
+
+	spool, err := b.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil {
+		bridgeLog.Warn("Failed to get spool for empty check", "spool_id", spoolID, "error", err)
+		return
+	}
+	// Spoolman can't know what's left on spools without a weight
+	weightKnown := spool.InitialWeight > 0 || (spool.Filament != nil && spool.Filament.Weight > 0)
+	threshold := snapshot.AutoPauseMinWeight
+	if spool.Archived || !weightKnown || spool.RemainingWeight > threshold || spool.RemainingWeight+usedWeight <= threshold {
+		return
+	}
+
+	name := fmt.Sprintf("Spool %d", spoolID)
+	if spool.Filament != nil && spool.Filament.Name != "" {
+		name = fmt.Sprintf("Spool %d (%s)", spoolID, spool.Filament.Name)
+	}
+	message := fmt.Sprintf("%s has %.1fg left after printing on %s toolhead %d.", name, spool.RemainingWeight, printerName, toolheadID)
+
+	archived := false
+	if snapshot.EmptySpoolAutoArchive {
+		if err := b.archiveEmptySpool(printerName, toolheadID, spoolID, spool.RemainingWeight); err != nil {
+			bridgeLog.Error("Failed to archive empty spool", "spool_id", spoolID, "error", err)
+			message += " Archiving it in Spoolman failed, archive it by hand."
+		} else {
+			archived = true
+			message += " It was archived in Spoolman and unmapped from the toolhead."
+		}
+	} else {
+		message += " Archive it in Spoolman once it's taken off."
+	}
+
+	actionURL := dashboardURL(snapshot)
+	if actionURL != "" {
+		message += " Map a replacement on the dashboard: " + actionURL
+	}
+
+	bridgeLog.Info("Spool is empty", "spool_id", spoolID, "printer", printerName, "toolhead_id", toolheadID,
+		"remaining_weight", spool.RemainingWeight, "archived", archived)
+	b.emitLifecycleEvent(LifecycleEventSpoolEmpty, map[string]interface{}{
+		"printer_name":     printerName,
+		"toolhead_id":      toolheadID,
+		"spool_id":         spoolID,
+		"remaining_weight": spool.RemainingWeight,
+		"archived":         archived,
+	})
+	b.notifier.Notify(Notification{
+		Event:   NotificationEventSpoolEmpty,
+		Title:   fmt.Sprintf("%s is empty", name),
+		Message: message,
+		URL:     actionURL,
+	})
+}
+
+// archiveEmptySpool archives a spool in Spoolman, unmaps it from the toolhead if it's still
+// mapped there, and logs it in the spool's events
+func (b *FilamentBridge) archiveEmptySpool(printerName string, toolheadID, spoolID int, remaining float64) error {
+	if err := b.spoolmanFor(printerName).UpdateSpool(spoolID, map[string]interface{}{"archived": true}); err != nil {
+		return err
+	}
+
+	// An archived spool can't stay on any toolhead, including the others of a shared spool
+	instance := b.spoolmanInstanceOf(printerName)
+	if shared, err := b.isSpoolShared(instance, spoolID); err == nil && shared {
+		if err := b.clearSpoolFromAllToolheads(instance, spoolID); err != nil {
+			return err
+		}
+	} else {
+		mappings, err := b.GetToolheadMappings(printerName)
+		if err != nil {
+			return err
+		}
+		if mappings[toolheadID].SpoolID == spoolID {
+			if err := b.UnmapToolhead(printerName, toolheadID); err != nil {
+				return err
+			}
+		}
+	}
+
+	event := SpoolEvent{SpoolmanInstance: instance, SpoolID: spoolID, EventType: SpoolEventEmptied, Weight: remaining, CreatedAt: time.Now()}
+	if err := b.recordSpoolEvents([]SpoolEvent{event}); err != nil {
+		bridgeLog.Error("Failed to record emptied spool", "spool_id", spoolID, "error", err)
+	}
+	return nil
+}
+
+// dashboardURL links to the dashboard, so a replacement can be mapped from a notification.
+// Notifications go through third-party services, so the link needs a login rather than
+// carrying the NFC scan token. Empty without an external URL.
+func dashboardURL(snapshot *Config) string {
+	externalURL := strings.TrimSpace(snapshot.ExternalURL)
+	if externalURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(externalURL, "/") + "/"
+}
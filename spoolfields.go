@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpoolField is a Spoolman extra field with its value on one spool
+type SpoolField struct {
+	SpoolmanField
+	Value    interface{} `json:"value"`
+	Editable bool        `json:"editable"` // Listed in editable_spool_fields
+}
+
+// decodeSpoolExtra decodes Spoolman's extra field values, which are stored as JSON strings.
+// Values that aren't valid JSON are returned as-is.
+func decodeSpoolExtra(extra map[string]interface{}) map[string]interface{} {
+	if len(extra) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]interface{}, len(extra))
+	for key, raw := range extra {
+		encoded, ok := raw.(string)
+		if !ok {
+			fields[key] = raw
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+			fields[key] = encoded
+			continue
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// parseEditableSpoolFields parses the comma-separated list of editable extra field keys
+func parseEditableSpoolFields(value string) []string {
+	keys := []string{}
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// isEditableSpoolField reports whether an extra field may be edited through FilaBridge
+func (b *FilamentBridge) isEditableSpoolField(key string) bool {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return false
+	}
+	for _, editable := range snapshot.EditableSpoolFields {
+		if editable == key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSpoolCustomFields returns every extra field defined in the printer's Spoolman instance,
+// the main one when printerName is empty, with its value on a spool
+func (b *FilamentBridge) GetSpoolCustomFields(printerName string, spoolID int) ([]SpoolField, error) {
+	spoolman := b.spoolmanFor(printerName)
+	spool, err := spoolman.GetSpool(spoolID)
+	if err != nil {
+		return nil, newCodedError(ErrCodeNotFound, "%v", err)
+	}
+	definitions, err := spoolman.GetSpoolFields()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool fields: %v", err)
+	}
+
+	values := decodeSpoolExtra(spool.Extra)
+	fields := make([]SpoolField, 0, len(definitions))
+	for _, definition := range definitions {
+		fields = append(fields, SpoolField{
+			SpoolmanField: definition,
+			Value:         values[definition.Key],
+			Editable:      b.isEditableSpoolField(definition.Key),
+		})
+	}
+	return fields, nil
+}
+
+// UpdateSpoolCustomFields sets editable extra fields on a spool in the printer's Spoolman
+// instance. A nil value clears the field. Other extra fields on the spool are left unchanged.
+func (b *FilamentBridge) UpdateSpoolCustomFields(printerName string, spoolID int, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return newCodedError(ErrCodeInvalidRequest, "no fields to update")
+	}
+
+	definitions, err := b.spoolmanFor(printerName).GetSpoolFields()
+	if err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to get spool fields: %v", err)
+	}
+	byKey := make(map[string]SpoolmanField, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+
+	changes := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		definition, exists := byKey[key]
+		if !exists {
+			return newCodedError(ErrCodeInvalidRequest, "spool field %s is not defined in Spoolman", key)
+		}
+		if !b.isEditableSpoolField(key) {
+			return newCodedError(ErrCodeInvalidRequest, "spool field %s is not editable", key)
+		}
+		if value == nil {
+			changes[key] = nil
+			continue
+		}
+
+		encoded, err := encodeSpoolFieldValue(definition, value)
+		if err != nil {
+			return newCodedError(ErrCodeInvalidRequest, "invalid value for %s: %v", key, err)
+		}
+		changes[key] = encoded
+	}
+
+	return b.updateSpoolExtra(printerName, spoolID, changes)
+}
+
+// updateSpoolExtra applies already-encoded extra field values to a spool in the printer's
+// Spoolman instance; nil removes a field. Spoolman replaces the whole extra object on update,
+// so the spool's current values are merged in.
+func (b *FilamentBridge) updateSpoolExtra(printerName string, spoolID int, changes map[string]interface{}) error {
+	spoolman := b.spoolmanFor(printerName)
+	spool, err := spoolman.GetSpool(spoolID)
+	if err != nil {
+		return newCodedError(ErrCodeNotFound, "%v", err)
+	}
+
+	extra := make(map[string]interface{}, len(spool.Extra)+len(changes))
+	for key, value := range spool.Extra {
+		extra[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(extra, key)
+		} else {
+			extra[key] = value
+		}
+	}
+
+	if err := spoolman.UpdateSpool(spoolID, map[string]interface{}{"extra": extra}); err != nil {
+		return newCodedError(ErrCodeSpoolmanError, "failed to update spool %d: %v", spoolID, err)
+	}
+	return nil
+}
+
+// encodeSpoolFieldValue checks a value against its field type and encodes it the way
+// Spoolman stores extra fields
+func encodeSpoolFieldValue(field SpoolmanField, value interface{}) (string, error) {
+	switch field.FieldType {
+	case "text", "datetime":
+		if _, ok := value.(string); !ok {
+			return "", fmt.Errorf("expected a string")
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return "", fmt.Errorf("expected an integer")
+		}
+	case "float":
+		if _, ok := value.(float64); !ok {
+			return "", fmt.Errorf("expected a number")
+		}
+	case "integer_range", "float_range":
+		bounds, ok := value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return "", fmt.Errorf("expected a [min, max] pair")
+		}
+		for _, bound := range bounds {
+			if bound == nil {
+				continue
+			}
+			number, ok := bound.(float64)
+			if !ok || (field.FieldType == "integer_range" && number != math.Trunc(number)) {
+				return "", fmt.Errorf("expected a [min, max] pair of numbers")
+			}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "", fmt.Errorf("expected true or false")
+		}
+	case "choice":
+		selected := []interface{}{value}
+		if field.MultiChoice {
+			list, ok := value.([]interface{})
+			if !ok {
+				return "", fmt.Errorf("expected a list of choices")
+			}
+			selected = list
+		}
+		for _, choice := range selected {
+			text, ok := choice.(string)
+			if !ok || !containsString(field.Choices, text) {
+				return "", fmt.Errorf("expected one of %s", strings.Join(field.Choices, ", "))
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// containsString reports whether a list contains a value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getSpoolFieldsHandler returns the extra fields of a spool
+func (ws *WebServer) getSpoolFieldsHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	fields, err := ws.bridge.GetSpoolCustomFields(c.Query("printer_name"), spoolID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolFieldsResponse{SpoolID: spoolID, Fields: fields})
+}
+
+// updateSpoolFieldsHandler sets editable extra fields of a spool from a key/value object
+func (ws *WebServer) updateSpoolFieldsHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var values map[string]interface{}
+	if err := c.ShouldBindJSON(&values); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.UpdateSpoolCustomFields(c.Query("printer_name"), spoolID, values); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spool fields updated successfully"})
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -18,6 +20,9 @@ type SpoolmanClient struct {
 	httpClient *http.Client
 	username   string
 	password   string
+	cache      spoolmanCache
+	contact    *spoolmanContact
+	tlsConfig  *tls.Config // Set when Spoolman's HTTPS certificate isn't verified
 }
 
 // GetBaseURL returns the Spoolman base URL
@@ -34,12 +39,15 @@ type SpoolmanSpool struct {
 	InitialWeight   float64                `json:"initial_weight"`
 	SpoolWeight     float64                `json:"spool_weight"`
 	UsedWeight      float64                `json:"used_weight"`
+	Price           float64                `json:"price"` // Price of the spool; 0 when not set, in which case the filament's applies
 	RemainingLength float64                `json:"remaining_length"`
 	UsedLength      float64                `json:"used_length"`
 	FirstUsed       string                 `json:"first_used"`
 	LastUsed        string                 `json:"last_used"`
 	Archived        bool                   `json:"archived"`
 	LocationID      *int                   `json:"location_id"` // Reference to Spoolman Location entity
+	LotNr           string                 `json:"lot_nr"`      // Manufacturer lot number
+	Comment         string                 `json:"comment"`
 	Extra           map[string]interface{} `json:"extra"`
 
 	// Computed fields for easier access
@@ -47,6 +55,19 @@ type SpoolmanSpool struct {
 	Brand    string `json:"brand"`    // Computed from filament.vendor.name
 	Material string `json:"material"` // Computed from filament.material
 	Location string `json:"location"` // Spool location (e.g., "Printer1 - Toolhead 0") - kept for backward compatibility
+
+	// Spoolman extra fields with their JSON-encoded values decoded
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
+
+	// Owner from the configured owner extra field, and the member it names, if any
+	Owner         string `json:"owner,omitempty"`
+	OwnerMemberID int    `json:"owner_member_id,omitempty"`
+
+	// Per-material defaults from FilaBridge that apply to this spool, if configured
+	MaterialDefaults *MaterialDefaults `json:"material_defaults,omitempty"`
+
+	// Grams reserved on this spool for queued jobs
+	ReservedWeight float64 `json:"reserved_weight,omitempty"`
 }
 
 // SpoolmanFilament represents a filament type from Spoolman
@@ -60,10 +81,12 @@ type SpoolmanFilament struct {
 	Diameter             float64                `json:"diameter"`
 	Weight               float64                `json:"weight"`
 	SpoolWeight          float64                `json:"spool_weight"`
+	Price                float64                `json:"price"` // Price of a full spool of Weight grams
 	SettingsExtruderTemp int                    `json:"settings_extruder_temp"`
 	SettingsBedTemp      int                    `json:"settings_bed_temp"`
 	ColorHex             string                 `json:"color_hex"`
 	ExternalID           string                 `json:"external_id"`
+	Comment              string                 `json:"comment"`
 	Extra                map[string]interface{} `json:"extra"`
 	Archived             bool                   `json:"archived"`
 }
@@ -86,19 +109,24 @@ type SpoolmanError struct {
 }
 
 // NewSpoolmanClient creates a new Spoolman client
-func NewSpoolmanClient(baseURL string, timeout int, username, password string) *SpoolmanClient {
+func NewSpoolmanClient(baseURL string, timeout int, username, password string, insecureSkipVerify bool) *SpoolmanClient {
+	tlsConfig := insecureTLSConfig(insecureSkipVerify)
+	contact := &spoolmanContact{next: &http.Transport{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     30 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}}
 	return &SpoolmanClient{
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				MaxIdleConnsPerHost: 2,
-				IdleConnTimeout:     30 * time.Second,
-			},
+			Timeout:   time.Duration(timeout) * time.Second,
+			Transport: contact,
 		},
-		username: username,
-		password: password,
+		username:  username,
+		password:  password,
+		contact:   contact,
+		tlsConfig: tlsConfig,
 	}
 }
 
@@ -145,6 +173,8 @@ func (c *SpoolmanClient) normalizeSpoolData(spool SpoolmanSpool) SpoolmanSpool {
 		spool.Name = fmt.Sprintf("Spool %d", spool.ID)
 	}
 
+	spool.CustomFields = decodeSpoolExtra(spool.Extra)
+
 	return spool
 }
 
@@ -169,8 +199,21 @@ func (spool *SpoolmanSpool) getSpoolDisplayName() string {
 	return fmt.Sprintf("%s - %s - %s", material, brand, name)
 }
 
-// GetAllSpools gets all filament spools from Spoolman
+// GetAllSpools gets all filament spools from Spoolman, reusing a recent response
 func (c *SpoolmanClient) GetAllSpools() ([]SpoolmanSpool, error) {
+	if spools, cached := c.cache.get(time.Now()); cached {
+		return spools, nil
+	}
+	spools, err := c.fetchAllSpools()
+	if err != nil {
+		return nil, err
+	}
+	c.cache.set(spools, time.Now())
+	return spools, nil
+}
+
+// fetchAllSpools gets all filament spools from Spoolman
+func (c *SpoolmanClient) fetchAllSpools() ([]SpoolmanSpool, error) {
 	req, err := http.NewRequest("GET", c.baseURL+"/api/v1/spool", nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
@@ -263,8 +306,100 @@ func (c *SpoolmanClient) GetAllFilaments() ([]SpoolmanFilament, error) {
 	return filaments, nil
 }
 
+// GetAllVendors gets all vendors from Spoolman, sorted by name
+func (c *SpoolmanClient) GetAllVendors() ([]SpoolmanVendor, error) {
+	req, err := http.NewRequest("GET", c.baseURL+"/api/v1/vendor", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting vendors from Spoolman: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleAPIError(resp)
+	}
+
+	var vendors []SpoolmanVendor
+	if err := json.NewDecoder(resp.Body).Decode(&vendors); err != nil {
+		return nil, fmt.Errorf("error decoding vendors from Spoolman: %w", err)
+	}
+
+	// Filter out archived vendors
+	filteredVendors := make([]SpoolmanVendor, 0, len(vendors))
+	for _, vendor := range vendors {
+		if !vendor.Archived {
+			filteredVendors = append(filteredVendors, vendor)
+		}
+	}
+	vendors = filteredVendors
+
+	sort.Slice(vendors, func(i, j int) bool {
+		return strings.ToLower(vendors[i].Name) < strings.ToLower(vendors[j].Name)
+	})
+
+	return vendors, nil
+}
+
+// SpoolmanField is an extra field defined in Spoolman's settings
+type SpoolmanField struct {
+	Key          string   `json:"key"`
+	Name         string   `json:"name"`
+	FieldType    string   `json:"field_type"` // text, integer, integer_range, float, float_range, datetime, boolean or choice
+	Unit         string   `json:"unit,omitempty"`
+	DefaultValue string   `json:"default_value,omitempty"`
+	Choices      []string `json:"choices,omitempty"`
+	MultiChoice  bool     `json:"multi_choice,omitempty"`
+	Order        int      `json:"order"`
+}
+
+// GetSpoolFields gets the extra fields defined for spools in Spoolman
+func (c *SpoolmanClient) GetSpoolFields() ([]SpoolmanField, error) {
+	req, err := http.NewRequest("GET", c.baseURL+"/api/v1/field/spool", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting spool fields from Spoolman: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleAPIError(resp)
+	}
+
+	var fields []SpoolmanField
+	if err := json.NewDecoder(resp.Body).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("error decoding spool fields from Spoolman: %w", err)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Order < fields[j].Order
+	})
+
+	return fields, nil
+}
+
+// CreateSpoolField defines a new extra field for spools in Spoolman
+func (c *SpoolmanClient) CreateSpoolField(key, name, fieldType string) error {
+	var fields []SpoolmanField
+	return c.createResource("field/spool/"+key, map[string]interface{}{
+		"name":       name,
+		"field_type": fieldType,
+	}, &fields)
+}
+
 // UpdateSpool updates spool information (used for filament usage tracking)
 func (c *SpoolmanClient) UpdateSpool(spoolID int, data map[string]interface{}) error {
+	defer c.InvalidateCache()
+
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("error marshaling spool update data: %w", err)
@@ -290,58 +425,224 @@ func (c *SpoolmanClient) UpdateSpool(spoolID int, data map[string]interface{}) e
 	return nil
 }
 
-// UpdateSpoolUsage updates spool used weight based on usage (core bridge functionality)
+// UpdateSpoolUsage records filament used from a spool (core bridge functionality). Spoolman's
+// use endpoint adds to the used weight itself and sets the usage timestamps, so usage recorded
+// at the same time by other clients isn't overwritten.
 func (c *SpoolmanClient) UpdateSpoolUsage(spoolID int, filamentUsed float64) error {
-	// Get current spool data
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/spool/%d", c.baseURL, spoolID), nil)
+	defer c.InvalidateCache()
+
+	jsonData, err := json.Marshal(map[string]interface{}{"use_weight": filamentUsed})
+	if err != nil {
+		return fmt.Errorf("error marshaling spool usage data: %w", err)
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/spool/%d/use", c.baseURL, spoolID), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 	c.addAuthHeader(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("error getting spool %d from Spoolman: %w", spoolID, err)
+		return fmt.Errorf("error updating spool %d usage in Spoolman: %w", spoolID, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusNotFound {
 		return fmt.Errorf("spool %d not found in Spoolman: %w", spoolID, c.handleAPIError(resp))
 	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update spool %d: %w", spoolID, c.handleAPIError(resp))
+	}
 
 	var spool SpoolmanSpool
 	if err := json.NewDecoder(resp.Body).Decode(&spool); err != nil {
-		return fmt.Errorf("error decoding spool %d from Spoolman: %w", spoolID, err)
+		// The usage was recorded; only the updated spool couldn't be read
+		spoolmanLog.Warn("Error decoding spool after usage update", "spool_id", spoolID, "error", err)
 	}
 
-	// Calculate new used weight
-	newUsedWeight := spool.UsedWeight + filamentUsed
-	currentTime := time.Now().UTC().Format(time.RFC3339)
+	spoolmanLog.Info("Updated spool usage", "spool_id", spoolID, "used_weight", spool.UsedWeight, "added", filamentUsed)
 
-	// Update used_weight and timestamps
-	updateData := map[string]interface{}{
-		"used_weight": newUsedWeight,
-		"last_used":   currentTime,
+	return nil
+}
+
+// GetSpool retrieves a single spool by ID
+func (c *SpoolmanClient) GetSpool(spoolID int) (*SpoolmanSpool, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/spool/%d", c.baseURL, spoolID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting spool %d from Spoolman: %w", spoolID, err)
 	}
+	defer resp.Body.Close()
 
-	// Set first_used if it's not already set
-	if spool.FirstUsed == "" {
-		updateData["first_used"] = currentTime
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spool %d not found in Spoolman: %w", spoolID, c.handleAPIError(resp))
 	}
 
-	if err := c.UpdateSpool(spoolID, updateData); err != nil {
+	var spool SpoolmanSpool
+	if err := json.NewDecoder(resp.Body).Decode(&spool); err != nil {
+		return nil, fmt.Errorf("error decoding spool %d from Spoolman: %w", spoolID, err)
+	}
+
+	return &spool, nil
+}
+
+// AdjustSpoolUsedWeight changes a spool's used weight by delta grams without touching
+// usage timestamps. Used to correct previously recorded usage; never goes below zero.
+func (c *SpoolmanClient) AdjustSpoolUsedWeight(spoolID int, delta float64) error {
+	spool, err := c.GetSpool(spoolID)
+	if err != nil {
+		return err
+	}
+
+	newUsedWeight := spool.UsedWeight + delta
+	if newUsedWeight < 0 {
+		newUsedWeight = 0
+	}
+
+	if err := c.UpdateSpool(spoolID, map[string]interface{}{"used_weight": newUsedWeight}); err != nil {
 		return fmt.Errorf("failed to update spool %d: %w", spoolID, err)
 	}
 
-	fmt.Printf("Updated spool %d: used_weight %.2fg -> %.2fg (added %.2fg)\n",
-		spoolID, spool.UsedWeight, newUsedWeight, filamentUsed)
+	spoolmanLog.Info("Adjusted spool usage", "spool_id", spoolID,
+		"old_used_weight", spool.UsedWeight, "used_weight", newUsedWeight, "delta", delta)
 
 	return nil
 }
 
+// AddSpoolRemainingWeight adds grams of filament to a spool, e.g. leftovers respooled onto it.
+// Used weight is reduced first; once it reaches zero the initial weight is raised by the rest.
+func (c *SpoolmanClient) AddSpoolRemainingWeight(spoolID int, grams float64) error {
+	spool, err := c.GetSpool(spoolID)
+	if err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{"used_weight": spool.UsedWeight - grams}
+	if extra := grams - spool.UsedWeight; extra > 0 {
+		initialWeight := spool.InitialWeight
+		if initialWeight == 0 && spool.Filament != nil {
+			initialWeight = spool.Filament.Weight // Spoolman falls back to the filament's net weight
+		}
+		update["used_weight"] = 0
+		update["initial_weight"] = initialWeight + extra
+	}
+
+	if err := c.UpdateSpool(spoolID, update); err != nil {
+		return fmt.Errorf("failed to update spool %d: %w", spoolID, err)
+	}
+
+	spoolmanLog.Info("Added filament to spool", "spool_id", spoolID, "grams", grams,
+		"old_used_weight", spool.UsedWeight, "old_initial_weight", spool.InitialWeight)
+
+	return nil
+}
+
+// CreateFilament creates a filament type in Spoolman
+func (c *SpoolmanClient) CreateFilament(data map[string]interface{}) (*SpoolmanFilament, error) {
+	var filament SpoolmanFilament
+	if err := c.createResource("filament", data, &filament); err != nil {
+		return nil, err
+	}
+	return &filament, nil
+}
+
+// CreateVendor creates a vendor in Spoolman
+func (c *SpoolmanClient) CreateVendor(data map[string]interface{}) (*SpoolmanVendor, error) {
+	var vendor SpoolmanVendor
+	if err := c.createResource("vendor", data, &vendor); err != nil {
+		return nil, err
+	}
+	return &vendor, nil
+}
+
+// CreateSpool creates a spool in Spoolman
+func (c *SpoolmanClient) CreateSpool(data map[string]interface{}) (*SpoolmanSpool, error) {
+	var spool SpoolmanSpool
+	if err := c.createResource("spool", data, &spool); err != nil {
+		return nil, err
+	}
+	return &spool, nil
+}
+
+// DeleteSpool permanently deletes a spool from Spoolman
+func (c *SpoolmanClient) DeleteSpool(spoolID int) error {
+	return c.deleteResource("spool", spoolID)
+}
+
+// DeleteFilament permanently deletes a filament type from Spoolman
+func (c *SpoolmanClient) DeleteFilament(filamentID int) error {
+	return c.deleteResource("filament", filamentID)
+}
+
+// createResource posts a new Spoolman entity and decodes the created entity into out
+func (c *SpoolmanClient) createResource(kind string, data map[string]interface{}, out interface{}) error {
+	defer c.InvalidateCache()
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s data: %w", kind, err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/v1/%s", c.baseURL, kind), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("error creating POST request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error creating %s in Spoolman: %w", kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return c.handleAPIError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding created %s from Spoolman: %w", kind, err)
+	}
+	return nil
+}
+
+// deleteResource deletes a Spoolman entity by ID
+func (c *SpoolmanClient) deleteResource(kind string, id int) error {
+	defer c.InvalidateCache()
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/%s/%d", c.baseURL, kind, id), nil)
+	if err != nil {
+		return fmt.Errorf("error creating DELETE request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error deleting %s %d from Spoolman: %w", kind, id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return c.handleAPIError(resp)
+	}
+	return nil
+}
+
 // TestConnection tests the connection to Spoolman
 func (c *SpoolmanClient) TestConnection() error {
-	req, err := http.NewRequest("GET", c.baseURL+"/api/v1/info", nil)
+	return c.ping(context.Background())
+}
+
+// ping requests Spoolman's info endpoint, giving up when ctx ends
+func (c *SpoolmanClient) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/v1/info", nil)
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
@@ -426,7 +727,7 @@ func (c *SpoolmanClient) GetLocations() ([]SpoolmanLocation, error) {
 	if len(snippet) > 300 {
 		snippet = snippet[:300] + "..."
 	}
-	log.Printf("Spoolman /location unexpected JSON. Snippet: %s", snippet)
+	spoolmanLog.Warn("Unexpected JSON from Spoolman locations", "snippet", snippet)
 	return nil, fmt.Errorf("error decoding locations from Spoolman: unexpected JSON shape")
 }
 
@@ -491,6 +792,8 @@ func (c *SpoolmanClient) LocationExistsInSpoolman(name string) (bool, error) {
 
 // RenameLocation renames a location in Spoolman using the PATCH API
 func (c *SpoolmanClient) RenameLocation(oldName, newName string) error {
+	defer c.InvalidateCache()
+
 	updateData := map[string]interface{}{
 		"name": newName,
 	}
@@ -517,7 +820,7 @@ func (c *SpoolmanClient) RenameLocation(oldName, newName string) error {
 		return c.handleAPIError(resp)
 	}
 
-	log.Printf("Successfully renamed Spoolman location from '%s' to '%s'", oldName, newName)
+	spoolmanLog.Info("Renamed Spoolman location", "from", oldName, "to", newName)
 	return nil
 }
 
@@ -529,6 +832,8 @@ func (c *SpoolmanClient) UpdateSpoolLocation(spoolID int, locationName string) e
 
 // UpdateLocation updates a location name in Spoolman
 func (c *SpoolmanClient) UpdateLocation(locationID int, newName string) error {
+	defer c.InvalidateCache()
+
 	updateData := map[string]interface{}{
 		"name": newName,
 	}
@@ -555,12 +860,14 @@ func (c *SpoolmanClient) UpdateLocation(locationID int, newName string) error {
 		return c.handleAPIError(resp)
 	}
 
-	log.Printf("Successfully updated Spoolman location %d to '%s'", locationID, newName)
+	spoolmanLog.Info("Updated Spoolman location", "location_id", locationID, "location", newName)
 	return nil
 }
 
 // ArchiveLocation archives a location in Spoolman
 func (c *SpoolmanClient) ArchiveLocation(locationID int) error {
+	defer c.InvalidateCache()
+
 	updateData := map[string]interface{}{
 		"archived": true,
 	}
@@ -587,7 +894,7 @@ func (c *SpoolmanClient) ArchiveLocation(locationID int) error {
 		return c.handleAPIError(resp)
 	}
 
-	log.Printf("Successfully archived Spoolman location %d", locationID)
+	spoolmanLog.Info("Archived Spoolman location", "location_id", locationID)
 	return nil
 }
 
@@ -619,6 +926,8 @@ func (c *SpoolmanClient) UpdateLocationByName(oldName, newName string) error {
 
 // updateSpoolLocationText updates a spool's location using the text field
 func (c *SpoolmanClient) updateSpoolLocationText(spoolID int, locationName string) error {
+	defer c.InvalidateCache()
+
 	updateData := map[string]interface{}{
 		"location": locationName,
 	}
@@ -645,32 +954,32 @@ func (c *SpoolmanClient) updateSpoolLocationText(spoolID int, locationName strin
 		return c.handleAPIError(resp)
 	}
 
-	log.Printf("Successfully updated spool %d to location '%s' (text-based)", spoolID, locationName)
+	spoolmanLog.Info("Updated spool location", "spool_id", spoolID, "location", locationName)
 	return nil
 }
 
 // UpdateSpoolmanLocationReferences renames the location in Spoolman using the location rename API
 func (c *SpoolmanClient) UpdateSpoolmanLocationReferences(oldName, newName string) error {
-	log.Printf("UpdateSpoolmanLocationReferences: Renaming location from '%s' to '%s' in Spoolman", oldName, newName)
+	spoolmanLog.Debug("Renaming location references", "from", oldName, "to", newName)
 
 	// Check if the old location exists in Spoolman
 	exists, err := c.LocationExistsInSpoolman(oldName)
 	if err != nil {
-		log.Printf("UpdateSpoolmanLocationReferences: Failed to check if location exists: %v", err)
+		spoolmanLog.Warn("Failed to check if location exists", "location", oldName, "error", err)
 		return fmt.Errorf("failed to check if location exists in Spoolman: %w", err)
 	}
 
 	if !exists {
-		log.Printf("UpdateSpoolmanLocationReferences: Location '%s' does not exist in Spoolman, skipping rename", oldName)
+		spoolmanLog.Debug("Location does not exist in Spoolman, skipping rename", "location", oldName)
 		return nil
 	}
 
 	// Use the location rename API to rename the location directly
 	if err := c.RenameLocation(oldName, newName); err != nil {
-		log.Printf("UpdateSpoolmanLocationReferences: Failed to rename location in Spoolman: %v", err)
+		spoolmanLog.Warn("Failed to rename location in Spoolman", "from", oldName, "to", newName, "error", err)
 		return fmt.Errorf("failed to rename location in Spoolman: %w", err)
 	}
 
-	log.Printf("UpdateSpoolmanLocationReferences: Successfully renamed location from '%s' to '%s' in Spoolman", oldName, newName)
+	spoolmanLog.Debug("Renamed location references", "from", oldName, "to", newName)
 	return nil
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spoolmanCache keeps the spool list for SpoolmanCacheTTL, since the dashboard, status
+// broadcasts and tag listings each ask for every spool. Writes through the client invalidate
+// it; changes made in Spoolman itself show up once it expires.
+type spoolmanCache struct {
+	mutex    sync.Mutex
+	spools   []SpoolmanSpool
+	cachedAt time.Time
+}
+
+// get returns a copy of the cached spools, or false when there are none or they're stale
+func (c *spoolmanCache) get(now time.Time) ([]SpoolmanSpool, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.spools == nil || now.Sub(c.cachedAt) >= SpoolmanCacheTTL {
+		return nil, false
+	}
+	return append([]SpoolmanSpool(nil), c.spools...), true
+}
+
+// set caches a freshly fetched spool list
+func (c *spoolmanCache) set(spools []SpoolmanSpool, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.spools = append([]SpoolmanSpool(nil), spools...)
+	c.cachedAt = now
+}
+
+// InvalidateCache drops cached Spoolman responses so the next request fetches them again
+func (c *SpoolmanClient) InvalidateCache() {
+	c.cache.mutex.Lock()
+	defer c.cache.mutex.Unlock()
+	c.cache.spools = nil
+}
+
+// spoolmanRefresh bypasses the Spoolman cache for requests with ?refresh=true
+func (ws *WebServer) spoolmanRefresh() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("refresh") == "true" {
+			ws.bridge.spoolman.InvalidateCache()
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SpoolmanEventSubscriber follows Spoolman's websocket of entity changes, so spools added or
+// edited in Spoolman's own UI reach the dashboard without waiting for the next printer poll
+type SpoolmanEventSubscriber struct {
+	bridge   *FilamentBridge
+	onChange func() // Called once per burst of changes, e.g. to broadcast status; may be nil
+}
+
+// spoolmanEvent is a change message from Spoolman's websocket
+type spoolmanEvent struct {
+	Type     string `json:"type"`     // added, updated or deleted
+	Resource string `json:"resource"` // spool, filament, vendor, ...
+}
+
+// NewSpoolmanEventSubscriber creates a subscriber calling onChange after spool changes
+func NewSpoolmanEventSubscriber(bridge *FilamentBridge, onChange func()) *SpoolmanEventSubscriber {
+	return &SpoolmanEventSubscriber{bridge: bridge, onChange: onChange}
+}
+
+// eventsURL returns the websocket URL of Spoolman's change feed
+func (c *SpoolmanClient) eventsURL() string {
+	url := strings.TrimSuffix(c.baseURL, "/") + SpoolmanEventsPath
+	if strings.HasPrefix(url, "https://") {
+		return "wss://" + strings.TrimPrefix(url, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(url, "http://")
+}
+
+// eventsHeader returns the headers the websocket handshake needs, i.e. authentication
+func (c *SpoolmanClient) eventsHeader() http.Header {
+	req := &http.Request{Header: http.Header{}}
+	c.addAuthHeader(req)
+	return req.Header
+}
+
+// Run keeps the subscription open until ctx is cancelled, reconnecting after failures and
+// whenever the Spoolman URL changes
+func (s *SpoolmanEventSubscriber) Run(ctx context.Context) {
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	unsupportedLogged := false
+
+	for {
+		snapshot := s.bridge.GetConfigSnapshot()
+		if snapshot != nil && snapshot.SpoolmanEventsEnabled {
+			client := s.bridge.spoolman
+			dialer.TLSClientConfig = client.tlsConfig
+			conn, resp, err := dialer.DialContext(ctx, client.eventsURL(), client.eventsHeader())
+			switch {
+			case err == nil:
+				spoolmanLog.Info("Subscribed to Spoolman change events", "url", client.GetBaseURL())
+				unsupportedLogged = false
+				s.readEvents(ctx, client, conn)
+				conn.Close()
+			case ctx.Err() != nil:
+				return
+			case resp != nil && resp.StatusCode == http.StatusNotFound:
+				// Spoolman versions without the websocket still work, just with polling
+				if !unsupportedLogged {
+					spoolmanLog.Info("Spoolman change events not supported, relying on polling", "url", client.GetBaseURL())
+					unsupportedLogged = true
+				}
+			default:
+				spoolmanLog.Warn("Failed to subscribe to Spoolman change events", "url", client.GetBaseURL(), "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(SpoolmanEventRetryInterval):
+		}
+	}
+}
+
+// readEvents reads changes until the connection drops, ctx is cancelled, or the Spoolman
+// client is replaced or the subscription disabled by a configuration change
+func (s *SpoolmanEventSubscriber) readEvents(ctx context.Context, client *SpoolmanClient, conn *websocket.Conn) {
+	// Close the connection when the subscription should end so the blocking read returns
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(SpoolmanEventRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				snapshot := s.bridge.GetConfigSnapshot()
+				if s.bridge.spoolman != client || snapshot == nil || !snapshot.SpoolmanEventsEnabled {
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var scheduled atomic.Bool
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() == nil && !strings.Contains(err.Error(), "use of closed network connection") {
+				spoolmanLog.Info("Spoolman change event stream closed", "url", client.GetBaseURL(), "error", err)
+			}
+			return
+		}
+
+		var event spoolmanEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			spoolmanLog.Debug("Ignoring unreadable Spoolman event", "payload", truncateEventPayload(message), "error", err)
+			continue
+		}
+		switch event.Resource {
+		case "spool", "filament", "vendor", "location":
+		default:
+			continue
+		}
+		spoolmanLog.Debug("Spoolman change event", "type", event.Type, "resource", event.Resource)
+
+		// A print updating several spools, or our own writes echoing back, arrive as a burst
+		client.InvalidateCache()
+		if s.onChange != nil && scheduled.CompareAndSwap(false, true) {
+			time.AfterFunc(SpoolmanEventDebounce, func() {
+				scheduled.Store(false)
+				s.onChange()
+			})
+		}
+	}
+}
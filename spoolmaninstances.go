@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpoolmanInstance is an additional Spoolman server, e.g. one per location, that printers can
+// be assigned to instead of the main one configured by spoolman_url
+type SpoolmanInstance struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Accept the instance's HTTPS certificate without verifying it, e.g. a self-signed one
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// parseSpoolmanInstances parses and validates the JSON instance list from the configuration table
+func parseSpoolmanInstances(value string) ([]SpoolmanInstance, error) {
+	if strings.TrimSpace(value) == "" {
+		return []SpoolmanInstance{}, nil
+	}
+
+	var instances []SpoolmanInstance
+	if err := json.Unmarshal([]byte(value), &instances); err != nil {
+		return nil, newCodedError(ErrCodeInvalidRequest, "invalid Spoolman instances: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, instance := range instances {
+		if strings.TrimSpace(instance.Name) == "" {
+			return nil, newCodedError(ErrCodeInvalidRequest, "Spoolman instance name is required")
+		}
+		if names[instance.Name] {
+			return nil, newCodedError(ErrCodeInvalidRequest, "duplicate Spoolman instance name: %s", instance.Name)
+		}
+		names[instance.Name] = true
+
+		if !strings.HasPrefix(instance.URL, "http://") && !strings.HasPrefix(instance.URL, "https://") {
+			return nil, newCodedError(ErrCodeInvalidRequest, "Spoolman instance %s requires an http or https URL", instance.Name)
+		}
+	}
+
+	return instances, nil
+}
+
+// newSpoolmanInstanceClients creates a client for each configured instance, keyed by name.
+// They share the main instance's timeout.
+func newSpoolmanInstanceClients(config *Config) map[string]*SpoolmanClient {
+	clients := make(map[string]*SpoolmanClient, len(config.SpoolmanInstances))
+	for _, instance := range config.SpoolmanInstances {
+		clients[instance.Name] = NewSpoolmanClient(instance.URL, config.SpoolmanTimeout, instance.Username, instance.Password, instance.InsecureSkipVerify)
+	}
+	return clients
+}
+
+// printerSpoolmanInstance returns the instance a printer is assigned to, or "" for the main
+// one, including when the assigned instance is no longer configured
+func printerSpoolmanInstance(config *Config, printerName string) string {
+	if config == nil {
+		return ""
+	}
+	for _, printer := range config.Printers {
+		if resolvePrinterName(printer) != printerName || printer.SpoolmanInstance == "" {
+			continue
+		}
+		for _, instance := range config.SpoolmanInstances {
+			if instance.Name == printer.SpoolmanInstance {
+				return instance.Name
+			}
+		}
+	}
+	return ""
+}
+
+// instanceSpool identifies a spool across Spoolman instances, since spool IDs are only
+// unique within one
+type instanceSpool struct {
+	instance string
+	spoolID  int
+}
+
+// spoolmanInstanceOf returns the Spoolman instance a printer's spools are in, "" for the main one
+func (b *FilamentBridge) spoolmanInstanceOf(printerName string) string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return printerSpoolmanInstance(b.config, printerName)
+}
+
+// spoolmanFor returns the client for the Spoolman instance a printer's spools are in. Spool
+// IDs used with a printer refer to this instance; everything else uses the main one.
+func (b *FilamentBridge) spoolmanFor(printerName string) *SpoolmanClient {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if client, exists := b.spoolmanInstances[printerSpoolmanInstance(b.config, printerName)]; exists {
+		return client
+	}
+	return b.spoolman
+}
+
+// spoolmanInstance returns the client of a Spoolman instance by name, the main one for "" or
+// an instance that is no longer configured
+func (b *FilamentBridge) spoolmanInstance(name string) *SpoolmanClient {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if client, exists := b.spoolmanInstances[name]; exists {
+		return client
+	}
+	return b.spoolman
+}
+
+// spoolmanInstanceNames returns "" for the main Spoolman instance followed by the names of
+// the configured additional ones
+func (b *FilamentBridge) spoolmanInstanceNames() []string {
+	names := []string{""}
+	if snapshot := b.GetConfigSnapshot(); snapshot != nil {
+		for _, instance := range snapshot.SpoolmanInstances {
+			names = append(names, instance.Name)
+		}
+	}
+	return names
+}
+
+// validateSpoolmanInstance checks that a printer's instance, if it has one, is configured
+func (b *FilamentBridge) validateSpoolmanInstance(name string) error {
+	if name == "" {
+		return nil
+	}
+	snapshot := b.GetConfigSnapshot()
+	if snapshot != nil {
+		for _, instance := range snapshot.SpoolmanInstances {
+			if instance.Name == name {
+				return nil
+			}
+		}
+	}
+	return newCodedError(ErrCodeInvalidRequest, "unknown Spoolman instance: %s", name)
+}
+
+// unmapAllToolheads clears every spool mapping of a printer
+func (b *FilamentBridge) unmapAllToolheads(printerName string) {
+	mappings, err := b.GetToolheadMappings(printerName)
+	if err != nil {
+		bridgeLog.Warn("Failed to get toolhead mappings to clear", "printer", printerName, "error", err)
+		return
+	}
+	for toolheadID := range mappings {
+		if err := b.UnmapToolhead(printerName, toolheadID); err != nil {
+			bridgeLog.Warn("Failed to unmap toolhead", "printer", printerName, "toolhead_id", toolheadID, "error", err)
+		}
+	}
+}
+
+// getSpoolmanInstancesHandler returns the configured Spoolman instances
+func (ws *WebServer) getSpoolmanInstancesHandler(c *gin.Context) {
+	snapshot := ws.bridge.GetConfigSnapshot()
+	instances := []SpoolmanInstance{}
+	if snapshot != nil {
+		instances = snapshot.SpoolmanInstances
+	}
+	c.JSON(http.StatusOK, SpoolmanInstancesResponse{Instances: instances})
+}
+
+// updateSpoolmanInstancesHandler replaces the Spoolman instance list. Printers assigned to an
+// instance that is removed go back to the main one, with their mappings cleared.
+func (ws *WebServer) updateSpoolmanInstancesHandler(c *gin.Context) {
+	// Serialize printer operations, since printers of removed instances are changed too
+	ws.operationMutex.Lock()
+	defer ws.operationMutex.Unlock()
+
+	var req struct {
+		Instances []SpoolmanInstance `json:"instances"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	value, err := json.Marshal(req.Instances)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	// Validate before saving so a bad entry doesn't drop every instance
+	instances, err := parseSpoolmanInstances(string(value))
+	if err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+		return
+	}
+
+	// Spool IDs belong to an instance, so printers of a removed one lose their mappings
+	kept := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		kept[instance.Name] = true
+	}
+	printerConfigs, err := ws.bridge.GetAllPrinterConfigs()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	for printerID, printerConfig := range printerConfigs {
+		if printerConfig.SpoolmanInstance == "" || kept[printerConfig.SpoolmanInstance] {
+			continue
+		}
+		ws.bridge.unmapAllToolheads(resolvePrinterName(printerConfig))
+		printerConfig.SpoolmanInstance = ""
+		if err := ws.bridge.SavePrinterConfig(printerID, printerConfig); err != nil {
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+			return
+		}
+	}
+
+	if err := ws.bridge.SetConfigValue(ConfigKeySpoolmanInstances, string(value)); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	if err := ws.bridge.ReloadConfig(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Spoolman instances updated successfully"})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpoolmanProxyPath is where Spoolman is proxied under FilaBridge
+const SpoolmanProxyPath = "/spoolman"
+
+// spoolmanProxyHandler serves Spoolman's web UI and API reads through FilaBridge, for clients
+// that can reach FilaBridge but not Spoolman itself. Only GET and HEAD are routed here, so
+// nothing can be changed in Spoolman through the proxy. Callers need a member or admin role,
+// since requests reach Spoolman with FilaBridge's credentials.
+//
+// Spoolman's UI loads its assets from its own base path, so for its pages to work through
+// the proxy Spoolman has to run with SPOOLMAN_BASE_PATH set to FilaBridge's base path plus
+// /spoolman, and spoolman_url has to include that path.
+func (ws *WebServer) spoolmanProxyHandler(c *gin.Context) {
+	snapshot := ws.bridge.GetConfigSnapshot()
+	if snapshot == nil || !snapshot.SpoolmanProxyEnabled {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "The Spoolman proxy is disabled")
+		return
+	}
+
+	// Requests go out with FilaBridge's Spoolman credentials, so anonymous reads aren't enough
+	switch c.GetString(contextKeyRole) {
+	case RoleAdmin, RoleMember:
+	case "":
+		respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "The Spoolman proxy requires a member or admin login")
+		return
+	default:
+		respondError(c, http.StatusForbidden, ErrCodeForbidden, "The Spoolman proxy requires a member or admin login")
+		return
+	}
+
+	// Only paths below Spoolman's base path are proxied
+	requestPath := c.Param("path")
+	for _, segment := range strings.Split(requestPath, "/") {
+		if segment == ".." {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid path")
+			return
+		}
+	}
+	requestPath = path.Clean("/" + requestPath)
+	if strings.HasSuffix(c.Param("path"), "/") && requestPath != "/" {
+		requestPath += "/" // Clean drops it, but Spoolman's UI routes may need it
+	}
+
+	client := ws.bridge.spoolman
+	target, err := url.Parse(client.GetBaseURL())
+	if err != nil || target.Host == "" {
+		respondError(c, http.StatusBadGateway, ErrCodeSpoolmanError, "Spoolman URL is not configured")
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.URL.Path = strings.TrimSuffix(target.Path, "/") + requestPath
+			r.Out.URL.RawPath = ""
+			r.Out.Host = target.Host
+
+			// FilaBridge's own credentials stay here; Spoolman gets its configured ones
+			r.Out.Header.Del("Authorization")
+			r.Out.Header.Del("Cookie")
+			r.Out.Header.Del("X-API-Key")
+			client.addAuthHeader(r.Out)
+		},
+		Transport: client.contact, // Spoolman's TLS settings, and proxied answers count as contact
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			webLog.Warn("Spoolman proxy request failed", "path", r.URL.Path, "error", err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// spoolmanLinkBase returns the base URL for links to Spoolman pages: the proxy when it's
+// enabled, so they open on clients that can't reach Spoolman, otherwise Spoolman itself
+func (ws *WebServer) spoolmanLinkBase(c *gin.Context) string {
+	if snapshot := ws.bridge.GetConfigSnapshot(); snapshot != nil && snapshot.SpoolmanProxyEnabled {
+		return ws.publicBaseURL(c) + SpoolmanProxyPath
+	}
+	return strings.TrimSuffix(ws.bridge.spoolman.GetBaseURL(), "/")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsHandler streams the dashboard's websocket messages as server-sent events, for proxies
+// and scripts that handle SSE better than websockets. The current status is sent first, then
+// each status_update and nfc_session message as it's broadcast, named by its type.
+func (ws *WebServer) eventsHandler(c *gin.Context) {
+	initial, err := ws.statusMessage()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+
+	// Event streams receive broadcasts like any websocket client
+	client := &WebSocketClient{hub: ws.wsHub, send: make(chan []byte, EventStreamBuffer)}
+	ws.wsHub.register <- client
+	defer func() { ws.wsHub.unregister <- client }()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.SSEvent("status_update", string(initial))
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(EventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+	ctx := c.Request.Context()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case message, ok := <-client.send:
+			if !ok {
+				// Dropped by the hub for falling behind
+				return false
+			}
+			c.SSEvent(eventStreamType(message), string(message))
+			return true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			return true
+		}
+	})
+}
+
+// eventStreamType returns the type of a broadcast message, used as its event name
+func eventStreamType(message []byte) string {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil || envelope.Type == "" {
+		return "message"
+	}
+	return envelope.Type
+}
@@ -18,7 +18,7 @@ async function loadAvailableSpools(dropdown) {
     const printerName = printerNameElement.textContent;
     
     try {
-        const response = await fetch(`/api/available_spools?printer_name=${encodeURIComponent(printerName)}&toolhead_id=${toolheadId}`);
+        const response = await fetch(apiUrl(`/api/v1/available_spools?printer_name=${encodeURIComponent(printerName)}&toolhead_id=${toolheadId}`));
         const data = await response.json();
         
         if (data.error) {
@@ -58,7 +58,7 @@ async function loadAvailableSpools(dropdown) {
             
             const optionText = document.createElement('div');
             optionText.className = 'option-text';
-            optionText.textContent = `[${spool.id}] ${spool.material || 'Unknown Material'} - ${spool.brand || 'Unknown Brand'} - ${spool.name || 'Unnamed Spool'}${spool.remaining_weight != null ? ` (${Math.round(spool.remaining_weight)}g remaining)` : ''}`;
+            optionText.textContent = `[${spool.id}] ${spool.material || 'Unknown Material'} - ${spool.brand || 'Unknown Brand'} - ${spool.name || 'Unnamed Spool'}${spool.remaining_weight != null ? ` (${Math.round(spool.remaining_weight)}g remaining${spool.reserved_weight ? `, ${Math.round(spool.reserved_weight)}g reserved` : ''})` : ''}`;
             
             option.appendChild(colorSwatch);
             option.appendChild(optionText);
@@ -303,7 +303,7 @@ async function autoMapSpool(dropdown, selectedValue, selectedText, selectedColor
     `;
     
     try {
-        const response = await fetch('/api/map_toolhead', {
+        const response = await fetch(apiUrl('/api/v1/map_toolhead'), {
             method: 'POST',
             headers: {'Content-Type': 'application/json'},
             body: JSON.stringify({
@@ -317,7 +317,7 @@ async function autoMapSpool(dropdown, selectedValue, selectedText, selectedColor
         
         if (data.error) {
             // Handle conflict errors specifically
-            if (data.error.includes('is already assigned to')) {
+            if (data.code === 'spool_already_assigned') {
                 alert(`Spool assignment conflict: ${data.error}`);
             } else {
                 alert(`Error mapping spool: ${data.error}`);
@@ -342,6 +342,11 @@ async function autoMapSpool(dropdown, selectedValue, selectedText, selectedColor
         // Update edit button visibility and data
         updateEditButton(toolheadRow, selectedValue, selectedColor);
         
+        // Mapped, but the spool belongs to another member
+        if (data.warning) {
+            alert(`Warning: ${data.warning}`);
+        }
+        
         // Reset to normal state after 2 seconds
         setTimeout(() => {
             button.innerHTML = `
@@ -409,6 +414,13 @@ async function refreshAllDropdowns() {
 
 // Update edit button visibility and data based on selected spool
 function updateEditButton(toolheadRow, selectedValue, selectedColor = '') {
+    const fieldsButton = toolheadRow.querySelector('.spool-fields-btn');
+    if (fieldsButton) {
+        const mapped = selectedValue && selectedValue !== '' && selectedValue !== '0';
+        fieldsButton.classList.toggle('hidden', !mapped);
+        fieldsButton.setAttribute('data-spool-id', mapped ? selectedValue : '');
+    }
+
     const editButton = toolheadRow.querySelector('.edit-spool-btn');
     if (!editButton) return;
     
@@ -451,3 +463,419 @@ function openSpoolmanEdit(spoolId) {
     const editURL = `${spoolmanBaseURL}/spool/edit/${spoolId}`;
     window.open(editURL, '_blank');
 }
+
+// Open the custom fields editor for a spool
+async function openSpoolFieldsModal(spoolId) {
+    if (!spoolId) {
+        console.warn('No spool ID provided for fields');
+        return;
+    }
+
+    try {
+        const response = await fetch(apiUrl(`/api/v1/spools/${spoolId}/fields`));
+        const data = await response.json();
+        if (data.error) {
+            throw new Error(data.error);
+        }
+
+        document.getElementById('spoolFieldsTitle').textContent = `Spool ${spoolId} Fields`;
+        document.getElementById('spoolFieldsSpoolId').value = spoolId;
+
+        const list = document.getElementById('spoolFieldsList');
+        list.innerHTML = '';
+        if (data.fields.length === 0) {
+            list.innerHTML = '<p>No extra fields are defined for spools in Spoolman.</p>';
+        }
+        data.fields.forEach(field => list.appendChild(createSpoolFieldInput(field)));
+
+        document.getElementById('spoolFieldsModal').style.display = 'block';
+    } catch (error) {
+        console.error('Error loading spool fields:', error);
+        alert('Error loading spool fields: ' + error.message);
+    }
+}
+
+// Build the form row for one extra field; fields that aren't editable are shown read-only
+function createSpoolFieldInput(field) {
+    const group = document.createElement('div');
+    group.className = 'form-group';
+
+    const label = document.createElement('label');
+    label.textContent = field.name + (field.unit ? ` (${field.unit})` : '');
+    group.appendChild(label);
+
+    let input;
+    if (field.field_type === 'choice' && !field.multi_choice) {
+        input = document.createElement('select');
+        ['', ...(field.choices || [])].forEach(choice => {
+            const option = document.createElement('option');
+            option.value = choice;
+            option.textContent = choice || '—';
+            input.appendChild(option);
+        });
+        input.value = field.value ?? '';
+    } else if (field.field_type === 'boolean') {
+        input = document.createElement('select');
+        [['', '—'], ['true', 'Yes'], ['false', 'No']].forEach(([value, text]) => {
+            const option = document.createElement('option');
+            option.value = value;
+            option.textContent = text;
+            input.appendChild(option);
+        });
+        input.value = field.value === null || field.value === undefined ? '' : String(field.value);
+    } else {
+        input = document.createElement('input');
+        input.type = field.field_type === 'integer' || field.field_type === 'float' ? 'number' : 'text';
+        if (field.field_type === 'float') {
+            input.step = 'any';
+        }
+        const value = field.value ?? '';
+        input.value = typeof value === 'object' ? JSON.stringify(value) : value;
+    }
+
+    input.dataset.key = field.key;
+    input.dataset.fieldType = field.field_type;
+    input.dataset.multiChoice = field.multi_choice ? 'true' : 'false';
+    input.dataset.original = input.value;
+    input.disabled = !field.editable;
+    group.appendChild(input);
+
+    if (!field.editable) {
+        const hint = document.createElement('small');
+        hint.textContent = 'Read-only; add this key to editable_spool_fields to edit it here';
+        group.appendChild(hint);
+    }
+    return group;
+}
+
+// Convert a form value to the JSON value of its field type (null clears the field)
+function parseSpoolFieldValue(input) {
+    const value = input.value.trim();
+    if (value === '') {
+        return null;
+    }
+    switch (input.dataset.fieldType) {
+        case 'integer':
+        case 'float':
+            return Number(value);
+        case 'boolean':
+            return value === 'true';
+        case 'integer_range':
+        case 'float_range':
+            return JSON.parse(value);
+        case 'choice':
+            return input.dataset.multiChoice === 'true' ? JSON.parse(value) : value;
+        default:
+            return value;
+    }
+}
+
+// Save the changed editable fields of the spool being edited
+async function saveSpoolFields(event) {
+    event.preventDefault();
+    const spoolId = document.getElementById('spoolFieldsSpoolId').value;
+
+    const values = {};
+    try {
+        document.querySelectorAll('#spoolFieldsList [data-key]').forEach(input => {
+            if (!input.disabled && input.value !== input.dataset.original) {
+                values[input.dataset.key] = parseSpoolFieldValue(input);
+            }
+        });
+    } catch (error) {
+        alert('Invalid field value: ' + error.message);
+        return;
+    }
+
+    if (Object.keys(values).length === 0) {
+        closeSpoolFieldsModal();
+        return;
+    }
+
+    try {
+        const response = await fetch(apiUrl(`/api/v1/spools/${spoolId}/fields`), {
+            method: 'PUT',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify(values)
+        });
+        const data = await response.json();
+        if (data.error) {
+            throw new Error(data.error);
+        }
+        closeSpoolFieldsModal();
+    } catch (error) {
+        console.error('Error saving spool fields:', error);
+        alert('Error saving spool fields: ' + error.message);
+    }
+}
+
+function closeSpoolFieldsModal() {
+    document.getElementById('spoolFieldsModal').style.display = 'none';
+}
+
+// Open the form for a received spool, to add it to Spoolman and map it to a toolhead
+async function openNewSpoolModal(printerName, toolheadId) {
+    const query = `?printer_name=${encodeURIComponent(printerName)}`;
+    try {
+        const [filamentsResponse, vendorsResponse] = await Promise.all([
+            fetch(apiUrl(`/api/v1/filaments${query}`)),
+            fetch(apiUrl(`/api/v1/vendors${query}`))
+        ]);
+        const filaments = await filamentsResponse.json();
+        const vendors = await vendorsResponse.json();
+        if (filaments.error || vendors.error) {
+            throw new Error(filaments.error || vendors.error);
+        }
+
+        const select = document.getElementById('newSpoolFilament');
+        select.innerHTML = '';
+        filaments.forEach(filament => {
+            const option = document.createElement('option');
+            option.value = filament.id;
+            option.textContent = `[${filament.id}] ${filament.material || 'Unknown Material'} - ${filament.vendor ? filament.vendor.name : 'Unknown Brand'} - ${filament.name || 'Unnamed Filament'}`;
+            select.appendChild(option);
+        });
+        const newOption = document.createElement('option');
+        newOption.value = 'new';
+        newOption.textContent = '➕ New filament...';
+        select.appendChild(newOption);
+
+        const vendorList = document.getElementById('newFilamentVendors');
+        vendorList.innerHTML = '';
+        vendors.forEach(vendor => {
+            const option = document.createElement('option');
+            option.value = vendor.name;
+            vendorList.appendChild(option);
+        });
+
+        document.getElementById('newSpoolForm').reset();
+        document.getElementById('newSpoolPrinterName').value = printerName;
+        document.getElementById('newSpoolToolheadId').value = toolheadId;
+        document.getElementById('newSpoolMapLabel').textContent = `Map to ${printerName} toolhead ${toolheadId}`;
+        toggleNewFilamentFields();
+        lookupNewSpoolBarcode();
+
+        document.getElementById('newSpoolModal').style.display = 'block';
+    } catch (error) {
+        console.error('Error loading filaments:', error);
+        alert('Error loading filaments: ' + error.message);
+    }
+}
+
+// Show the new filament fields when "New filament" is picked
+function toggleNewFilamentFields() {
+    const isNew = document.getElementById('newSpoolFilament').value === 'new';
+    document.getElementById('newFilamentFields').style.display = isNew ? 'block' : 'none';
+    document.getElementById('newFilamentMaterial').required = isNew;
+}
+
+// Fill in the filament of the barcode entered for a new spool
+async function lookupNewSpoolBarcode() {
+    const code = document.getElementById('newSpoolBarcode').value.trim();
+    const status = document.getElementById('newSpoolBarcodeStatus');
+    if (!code) {
+        status.textContent = 'Fills in the filament of a known barcode, and is remembered for the next spool';
+        return;
+    }
+
+    try {
+        const response = await fetch(apiUrl(`/api/v1/barcodes/${encodeURIComponent(code)}`));
+        const lookup = await response.json();
+        if (lookup.error) {
+            throw new Error(lookup.error);
+        }
+        if (!lookup.found) {
+            status.textContent = 'Unknown barcode; pick or add its filament and it\'s remembered';
+            return;
+        }
+
+        const select = document.getElementById('newSpoolFilament');
+        if (lookup.filament_id && select.querySelector(`option[value="${lookup.filament_id}"]`)) {
+            select.value = lookup.filament_id;
+        } else {
+            select.value = 'new';
+            document.getElementById('newFilamentVendor').value = lookup.vendor || '';
+            document.getElementById('newFilamentName').value = lookup.name || '';
+            document.getElementById('newFilamentMaterial').value = lookup.material || '';
+            if (lookup.color_hex) {
+                document.getElementById('newFilamentColor').value = `#${lookup.color_hex}`;
+            }
+            document.getElementById('newFilamentWeight').value = lookup.weight || '';
+            document.getElementById('newFilamentSpoolWeight').value = lookup.spool_weight || '';
+        }
+        toggleNewFilamentFields();
+        status.textContent = lookup.source === 'online' ? 'Found in the online filament database' : 'Found in FilaBridge\'s barcodes';
+    } catch (error) {
+        console.error('Error looking up barcode:', error);
+        status.textContent = 'Barcode lookup failed: ' + error.message;
+    }
+}
+
+// Convert an optional number input to a number, or undefined when it's empty
+function optionalNumber(id) {
+    const value = document.getElementById(id).value.trim();
+    return value === '' ? undefined : Number(value);
+}
+
+// Create the new filament if one is being added, then the spool, mapped if asked
+async function createNewSpool(event) {
+    event.preventDefault();
+    const printerName = document.getElementById('newSpoolPrinterName').value;
+
+    try {
+        let filamentId = document.getElementById('newSpoolFilament').value;
+        if (filamentId === 'new') {
+            const response = await fetch(apiUrl('/api/v1/filaments'), {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({
+                    printer_name: printerName,
+                    vendor_name: document.getElementById('newFilamentVendor').value.trim(),
+                    name: document.getElementById('newFilamentName').value.trim(),
+                    material: document.getElementById('newFilamentMaterial').value.trim(),
+                    color_hex: document.getElementById('newFilamentColor').value,
+                    weight: optionalNumber('newFilamentWeight'),
+                    spool_weight: optionalNumber('newFilamentSpoolWeight')
+                })
+            });
+            const data = await response.json();
+            if (data.error) {
+                throw new Error(data.error);
+            }
+            filamentId = data.filament.id;
+        }
+
+        const spool = {
+            printer_name: printerName,
+            filament_id: parseInt(filamentId),
+            initial_weight: optionalNumber('newSpoolInitialWeight'),
+            price: optionalNumber('newSpoolPrice'),
+            lot_nr: document.getElementById('newSpoolLotNr').value.trim(),
+            barcode: document.getElementById('newSpoolBarcode').value.trim()
+        };
+        if (document.getElementById('newSpoolMap').checked) {
+            spool.toolhead_id = parseInt(document.getElementById('newSpoolToolheadId').value);
+        }
+        const response = await fetch(apiUrl('/api/v1/spools'), {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify(spool)
+        });
+        const data = await response.json();
+        if (data.error) {
+            throw new Error(data.error);
+        }
+
+        closeNewSpoolModal();
+        if (data.result.warning) {
+            alert(`Warning: ${data.result.warning}`);
+        }
+        // The spool lists and mappings are rendered with the page
+        location.reload();
+    } catch (error) {
+        console.error('Error creating spool:', error);
+        alert('Error creating spool: ' + error.message);
+    }
+}
+
+function closeNewSpoolModal() {
+    document.getElementById('newSpoolModal').style.display = 'none';
+}
+
+// Suggest spools for each toolhead of a file on the printer
+async function suggestSpools(printerId) {
+    const printerElement = document.querySelector(`.printer[data-printer-id="${printerId}"]`);
+    if (!printerElement) return;
+
+    const fileInput = printerElement.querySelector('.spool-suggest-file');
+    const file = fileInput ? fileInput.value.trim() : '';
+    if (!file) {
+        alert('Enter the path of a file on the printer, e.g. usb/JOB.bgcode');
+        return;
+    }
+
+    try {
+        const response = await fetch(apiUrl(`/api/v1/suggest_spools?printer=${encodeURIComponent(printerId)}&file=${encodeURIComponent(file)}&limit=3`));
+        const data = await response.json();
+        if (data.error) {
+            throw new Error(data.error);
+        }
+
+        printerElement.querySelectorAll('.spool-suggestions').forEach(container => {
+            container.innerHTML = '';
+            container.classList.add('hidden');
+        });
+
+        data.toolheads.forEach(toolhead => {
+            const container = printerElement.querySelector(`.spool-suggestions[data-toolhead-id="${toolhead.toolhead_id}"]`);
+            if (!container) return;
+            renderSpoolSuggestions(container, toolhead);
+            container.classList.remove('hidden');
+        });
+    } catch (error) {
+        console.error('Error suggesting spools:', error);
+        alert('Error suggesting spools: ' + error.message);
+    }
+}
+
+// Render the ranked spools for one toolhead, each with a button to map it
+function renderSpoolSuggestions(container, toolhead) {
+    const needs = [toolhead.material, toolhead.color, toolhead.required_weight ? `${toolhead.required_weight.toFixed(1)}g` : '']
+        .filter(Boolean).join(', ');
+    const header = document.createElement('div');
+    header.className = 'spool-suggestions-header';
+    header.textContent = needs ? `Job needs ${needs}` : 'Job needs unknown filament';
+    container.appendChild(header);
+
+    if (toolhead.suggestions.length === 0) {
+        const empty = document.createElement('div');
+        empty.textContent = 'No available spools';
+        container.appendChild(empty);
+        return;
+    }
+
+    toolhead.suggestions.forEach(suggestion => {
+        const spool = suggestion.spool;
+        const color = spool.filament?.color_hex || '';
+        const text = `[${spool.id}] ${spool.material || 'Unknown Material'} - ${spool.brand || 'Unknown Brand'} - ${spool.name || 'Unnamed Spool'} (${Math.round(spool.remaining_weight)}g remaining${spool.reserved_weight ? `, ${Math.round(spool.reserved_weight)}g reserved` : ''})`;
+
+        const row = document.createElement('div');
+        row.className = 'spool-suggestion';
+
+        const swatch = document.createElement('div');
+        swatch.className = 'color-swatch';
+        swatch.style.backgroundColor = '#' + (color || 'ccc');
+
+        const label = document.createElement('span');
+        const warnings = [];
+        if (!suggestion.material_match) warnings.push('different material');
+        if (!suggestion.enough_filament) warnings.push('not enough filament');
+        label.textContent = text + (warnings.length ? ` ⚠️ ${warnings.join(', ')}` : '');
+
+        row.appendChild(swatch);
+        row.appendChild(label);
+
+        if (suggestion.currently_mapped) {
+            const mapped = document.createElement('span');
+            mapped.textContent = '✅ loaded';
+            row.appendChild(mapped);
+        } else {
+            const button = document.createElement('button');
+            button.className = 'btn';
+            button.textContent = 'Use';
+            button.addEventListener('click', async () => {
+                const toolheadRow = document.querySelector(`.toolhead-mapping-row[data-printer-id="${container.dataset.printerId}"][data-toolhead-id="${container.dataset.toolheadId}"]`);
+                const dropdown = toolheadRow ? toolheadRow.querySelector('.custom-dropdown') : null;
+                if (!dropdown) return;
+                const hiddenInput = dropdown.querySelector('input[type="hidden"]');
+                if (hiddenInput) {
+                    hiddenInput.value = spool.id;
+                }
+                await autoMapSpool(dropdown, spool.id.toString(), text, color);
+                container.classList.add('hidden');
+            });
+            row.appendChild(button);
+        }
+        container.appendChild(row);
+    });
+}
@@ -0,0 +1,67 @@
+// FilaBridge Dashboard - Log Viewer
+
+const maxLogEntries = 1000; // Entries kept in the viewer
+let logSocket = null;
+
+// Load recent log entries for the selected filters, then follow new ones
+function startLogStream() {
+    stopLogStream();
+
+    const container = document.getElementById('log-entries');
+    if (!container) return;
+    container.innerHTML = '';
+
+    const params = new URLSearchParams({
+        level: document.getElementById('logLevelFilter').value,
+        limit: 200,
+    });
+    const module = document.getElementById('logModuleFilter').value;
+    if (module) {
+        params.set('module', module);
+    }
+
+    logSocket = new WebSocket(wsUrl(`/api/v1/logs/stream?${params}`));
+    logSocket.onmessage = function(event) {
+        try {
+            appendLogEntry(container, JSON.parse(event.data));
+        } catch (error) {
+            console.error('Error parsing log entry:', error);
+        }
+    };
+    logSocket.onerror = function() {
+        container.innerHTML = '<p>Error connecting to the log stream.</p>';
+    };
+}
+
+function stopLogStream() {
+    if (logSocket) {
+        logSocket.onerror = null;
+        logSocket.close();
+        logSocket = null;
+    }
+}
+
+function appendLogEntry(container, entry) {
+    // Keep following new entries only if the view is scrolled to the bottom
+    const atBottom = container.scrollHeight - container.scrollTop - container.clientHeight < 20;
+
+    const line = document.createElement('div');
+    line.className = `log-entry ${entry.level}`;
+
+    const time = document.createElement('span');
+    time.className = 'log-time';
+    time.textContent = new Date(entry.time).toLocaleTimeString();
+
+    const attrs = Object.entries(entry.attrs || {})
+        .map(([key, value]) => `${key}=${typeof value === 'object' ? JSON.stringify(value) : value}`)
+        .join(' ');
+    line.append(time, ` ${entry.level.padEnd(5)} [${entry.module || '-'}] ${entry.message}${attrs ? ' ' + attrs : ''}`);
+
+    container.appendChild(line);
+    while (container.childElementCount > maxLogEntries) {
+        container.removeChild(container.firstChild);
+    }
+    if (atBottom) {
+        container.scrollTop = container.scrollHeight;
+    }
+}
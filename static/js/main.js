@@ -91,11 +91,17 @@ function switchSettingsTab(tabName, clickedElement) {
         loadAdvancedSettings();
         loadAutoAssignSettings();
     }
+
+    if (tabName === 'logs') {
+        startLogStream();
+    } else {
+        stopLogStream();
+    }
 }
 
 // Configuration Management
 function loadConfiguration() {
-    fetch('/api/config')
+    fetch(apiUrl('/api/v1/config'))
         .then(response => response.json())
         .then(config => {
             const form = document.getElementById('config-form');
@@ -116,16 +122,35 @@ function loadConfiguration() {
                         <input type="password" id="spoolman_password" value="${config.spoolman_password || ''}" placeholder="Leave empty if not using basic auth">
                         <small>Password for Spoolman basic authentication (optional)</small>
                     </div>
+                    <div class="form-group">
+                        <label style="display: flex; align-items: center; gap: 10px; cursor: pointer;">
+                            <input type="checkbox" id="spoolman_insecure_skip_verify" style="width: auto; cursor: pointer;" ${config.spoolman_insecure_skip_verify === 'true' ? 'checked' : ''}>
+                            <span>Accept a self-signed HTTPS certificate from Spoolman</span>
+                        </label>
+                    </div>
                     <div class="form-group">
                         <label><strong>Poll Interval (seconds):</strong></label>
                         <input type="number" id="poll_interval" value="${config.poll_interval || '30'}" min="10" max="300">
                         <small>How often to check printer status</small>
                     </div>
+                    <div class="form-group">
+                        <label><strong>External URL (optional):</strong></label>
+                        <input type="text" id="external_url" value="${config.external_url || ''}" placeholder="https://filabridge.example.com">
+                        <small>Address phones use to reach FilaBridge; used in NFC tag URLs and QR codes instead of the address you're browsing from</small>
+                    </div>
                     <div style="margin-top: 20px; text-align: center;">
                         <button class="btn" onclick="saveConfiguration()">💾 Save Configuration</button>
                     </div>
                 </div>
             `;
+            lockOverriddenSettings({
+                spoolman_url: 'spoolman_url',
+                spoolman_username: 'spoolman_username',
+                spoolman_password: 'spoolman_password',
+                spoolman_insecure_skip_verify: 'spoolman_insecure_skip_verify',
+                poll_interval: 'poll_interval',
+                external_url: 'external_url'
+            });
         })
         .catch(error => {
             console.error('Error loading configuration:', error);
@@ -138,10 +163,12 @@ function saveConfiguration() {
         spoolman_url: document.getElementById('spoolman_url').value,
         spoolman_username: document.getElementById('spoolman_username').value,
         spoolman_password: document.getElementById('spoolman_password').value,
-        poll_interval: document.getElementById('poll_interval').value
+        spoolman_insecure_skip_verify: document.getElementById('spoolman_insecure_skip_verify').checked ? 'true' : 'false',
+        poll_interval: document.getElementById('poll_interval').value,
+        external_url: document.getElementById('external_url').value.trim()
     };
     
-    fetch('/api/config', {
+    fetch(apiUrl('/api/v1/config'), {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify(config)
@@ -160,14 +187,42 @@ function saveConfiguration() {
     });
 }
 
+// Disable inputs for settings set by environment variables, which the server won't change.
+// fields maps config keys to input IDs.
+function lockOverriddenSettings(fields) {
+    fetch(apiUrl('/api/v1/config/overrides'))
+        .then(response => response.json())
+        .then(data => {
+            Object.entries(data.overrides || {}).forEach(([key, variable]) => {
+                const input = fields[key] && document.getElementById(fields[key]);
+                if (input) {
+                    input.disabled = true;
+                    input.title = `Set by the ${variable} environment variable`;
+                }
+            });
+        })
+        .catch(error => {
+            console.error('Error loading configuration overrides:', error);
+        });
+}
+
 // Advanced Settings Functions
 function loadAdvancedSettings() {
-    fetch('/api/config')
+    fetch(apiUrl('/api/v1/config'))
         .then(response => response.json())
         .then(config => {
             document.getElementById('prusalinkTimeout').value = config.prusalink_timeout || '10';
             document.getElementById('prusalinkFileDownloadTimeout').value = config.prusalink_file_download_timeout || '60';
             document.getElementById('spoolmanTimeout').value = config.spoolman_timeout || '30';
+            document.getElementById('monitorStartDelay').value = config.monitor_start_delay || '0';
+            document.getElementById('monitorJitter').value = config.monitor_jitter || '0';
+            lockOverriddenSettings({
+                prusalink_timeout: 'prusalinkTimeout',
+                prusalink_file_download_timeout: 'prusalinkFileDownloadTimeout',
+                spoolman_timeout: 'spoolmanTimeout',
+                monitor_start_delay: 'monitorStartDelay',
+                monitor_jitter: 'monitorJitter'
+            });
         })
         .catch(error => {
             console.error('Error loading advanced settings:', error);
@@ -178,7 +233,9 @@ function saveAdvancedSettings() {
     const config = {
         prusalink_timeout: document.getElementById('prusalinkTimeout').value,
         prusalink_file_download_timeout: document.getElementById('prusalinkFileDownloadTimeout').value,
-        spoolman_timeout: document.getElementById('spoolmanTimeout').value
+        spoolman_timeout: document.getElementById('spoolmanTimeout').value,
+        monitor_start_delay: document.getElementById('monitorStartDelay').value,
+        monitor_jitter: document.getElementById('monitorJitter').value
     };
     
     // Validate inputs
@@ -194,8 +251,16 @@ function saveAdvancedSettings() {
         alert('Spoolman API timeout must be between 5 and 300 seconds');
         return;
     }
+    if (config.monitor_start_delay < 0 || config.monitor_start_delay > 600) {
+        alert('Monitor start delay must be between 0 and 600 seconds');
+        return;
+    }
+    if (config.monitor_jitter < 0 || config.monitor_jitter > 300) {
+        alert('Poll jitter must be between 0 and 300 seconds');
+        return;
+    }
     
-    fetch('/api/config', {
+    fetch(apiUrl('/api/v1/config'), {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify(config)
@@ -219,6 +284,8 @@ function resetAdvancedSettings() {
         document.getElementById('prusalinkTimeout').value = '10';
         document.getElementById('prusalinkFileDownloadTimeout').value = '60';
         document.getElementById('spoolmanTimeout').value = '30';
+        document.getElementById('monitorStartDelay').value = '0';
+        document.getElementById('monitorJitter').value = '0';
     }
 }
 
@@ -228,7 +295,7 @@ let autoAssignCheckboxHandler = null;
 
 function loadAutoAssignSettings() {
     // First, load the settings
-    fetch('/api/config/auto-assign-previous-spool')
+    fetch(apiUrl('/api/v1/config/auto-assign-previous-spool'))
         .then(response => response.json())
         .then(data => {
             if (data.error) {
@@ -248,7 +315,7 @@ function loadAutoAssignSettings() {
             }
             
             // Load locations and populate dropdown
-            return fetch('/api/locations')
+            return fetch(apiUrl('/api/v1/locations'))
                 .then(response => response.json())
                 .then(locationsData => {
                     if (locationsData.error) {
@@ -333,7 +400,7 @@ function saveAutoAssignSettings() {
         location: location
     };
     
-    fetch('/api/config/auto-assign-previous-spool', {
+    fetch(apiUrl('/api/v1/config/auto-assign-previous-spool'), {
         method: 'PUT',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify(settings)
@@ -351,13 +418,228 @@ function saveAutoAssignSettings() {
     });
 }
 
+function downloadBackup() {
+    window.location.href = apiUrl('/api/v1/backup');
+}
+
+function restoreBackup() {
+    const input = document.getElementById('restoreBackupFile');
+    if (!input.files.length) {
+        alert('Choose a backup file to restore');
+        return;
+    }
+    if (!confirm('Restore this backup? It replaces all settings, printers, mappings and history.')) {
+        return;
+    }
+
+    const formData = new FormData();
+    formData.append('file', input.files[0]);
+    fetch(apiUrl('/api/v1/restore'), {
+        method: 'POST',
+        body: formData
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.error) {
+            alert('Error restoring backup: ' + data.error);
+        } else {
+            alert('Backup restored. The previous database was saved to ' + data.previous_database);
+            window.location.reload();
+        }
+    })
+    .catch(error => {
+        alert('Error restoring backup: ' + error.message);
+    });
+}
+
+// End the UI login session
+async function logout() {
+    try {
+        await fetch(apiUrl('/api/v1/auth/logout'), { method: 'POST' });
+    } finally {
+        window.location.href = apiUrl('/login');
+    }
+}
+
+// Show spools whose toolhead mapping and Spoolman location disagree
+async function loadMappingMismatches() {
+    const container = document.getElementById('mapping-mismatches-container');
+    if (!container) return;
+
+    try {
+        const response = await fetch(apiUrl('/api/v1/reconcile'));
+        if (!response.ok) return;
+        const data = await response.json();
+
+        container.innerHTML = '';
+        if (data.mismatches.length === 0) {
+            container.style.display = 'none';
+            return;
+        }
+        container.style.display = 'block';
+
+        data.mismatches.forEach(mismatch => {
+            const element = document.createElement('div');
+            element.className = 'mapping-mismatch';
+            element.style.cssText = 'background: #fff3cd; border: 1px solid #ffeeba; color: #856404; padding: 20px; margin: 20px 0; border-radius: 8px;';
+            element.innerHTML = `
+                <h4 style="margin-top: 0;">🔀 Mapping Disagrees With Spoolman</h4>
+                <p class="mismatch-message"></p>
+                ${mismatch.kind !== 'spool_missing' ? `<button class="btn" data-use="filabridge">Update Spoolman</button>` : ''}
+                <button class="btn" data-use="spoolman">Update Mapping</button>
+            `;
+            element.querySelector('.mismatch-message').textContent = mismatch.message;
+            element.querySelectorAll('button[data-use]').forEach(button => {
+                button.addEventListener('click', () =>
+                    resolveMappingMismatch(mismatch.spool_id, button.dataset.use, mismatch.spoolman_instance || ''));
+            });
+            container.appendChild(element);
+        });
+    } catch (error) {
+        console.error('Error loading mapping mismatches:', error);
+    }
+}
+
+// Resolve a mismatch by making one side match the other
+async function resolveMappingMismatch(spoolId, use, spoolmanInstance) {
+    try {
+        const response = await fetch(apiUrl('/api/v1/reconcile/resolve'), {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ spool_id: spoolId, use: use, spoolman_instance: spoolmanInstance })
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            alert('Failed to resolve mismatch: ' + (data.error || 'Unknown error'));
+            return;
+        }
+        loadMappingMismatches();
+    } catch (error) {
+        console.error('Error resolving mapping mismatch:', error);
+        alert('Failed to resolve mismatch: ' + error.message);
+    }
+}
+
+// Show filament usage statistics for the selected period
+async function loadUsageStats() {
+    const container = document.getElementById('usage-stats-container');
+    if (!container) return;
+    const days = document.getElementById('usage-stats-days').value;
+
+    try {
+        const response = await fetch(apiUrl('/api/v1/stats?days=' + days));
+        const data = await response.json();
+        if (!response.ok) {
+            container.innerHTML = '<p class="help-text"></p>';
+            container.querySelector('p').textContent = 'Failed to load usage statistics: ' + (data.error || 'Unknown error');
+            return;
+        }
+
+        container.innerHTML = '';
+        if (data.entries === 0) {
+            container.innerHTML = '<p class="help-text">No filament usage recorded in this period.</p>';
+            return;
+        }
+
+        const summary = document.createElement('div');
+        summary.className = 'usage-stats-summary';
+        const failures = data.rated_prints > 0 ? `${data.failed_prints} of ${data.rated_prints} rated` : 'none rated';
+        const summaryStats = [
+            [`${data.total_used.toFixed(0)}g`, 'filament used'],
+            [`${data.total_waste.toFixed(0)}g`, 'purged'],
+            [data.prints, 'prints'],
+            [failures, 'failed prints'],
+            [data.processing_errors, 'processing errors']
+        ];
+        if (data.total_cost > 0) {
+            summaryStats.splice(1, 0, [data.total_cost.toFixed(2), 'filament cost']);
+        }
+        summaryStats.forEach(([value, label]) => {
+            const stat = document.createElement('div');
+            stat.className = 'usage-stat';
+            stat.innerHTML = '<div class="usage-stat-value"></div><div class="usage-stat-label"></div>';
+            stat.querySelector('.usage-stat-value').textContent = value;
+            stat.querySelector('.usage-stat-label').textContent = label;
+            summary.appendChild(stat);
+        });
+        container.appendChild(summary);
+
+        const groups = document.createElement('div');
+        groups.className = 'usage-stats-groups';
+        groups.appendChild(usageStatsGroup('By printer', data.by_printer.map(total => ({ name: total.key, total }))));
+        groups.appendChild(usageStatsGroup('By material', data.by_material.map(total => ({ name: total.key, total }))));
+        groups.appendChild(usageStatsGroup('Most used filaments', data.top_filaments.map(total => ({ name: total.name, color: total.color_hex, total }))));
+        groups.appendChild(usageStatsGroup('By spool', data.by_spool.slice(0, 10).map(total => ({ name: `#${total.spool_id} ${total.name}`, color: total.color_hex, total }))));
+        groups.appendChild(usageStatsGroup('By week', data.by_week.slice(-8).reverse().map(total => ({ name: total.key, total }))));
+        groups.appendChild(usageStatsGroup('By month', data.by_month.slice(-12).reverse().map(total => ({ name: total.key, total }))));
+        container.appendChild(groups);
+    } catch (error) {
+        console.error('Error loading usage statistics:', error);
+    }
+}
+
+// Download the print history of the selected period
+function exportHistory(format) {
+    const days = parseInt(document.getElementById('usage-stats-days').value, 10);
+    let url = '/api/v1/history/export?format=' + format;
+    if (days > 0) {
+        url += '&from=' + encodeURIComponent(new Date(Date.now() - days * 24 * 60 * 60 * 1000).toISOString());
+    }
+    window.location.href = apiUrl(url);
+}
+
+// Build one group of usage totals, with a bar scaled to the group's largest total
+function usageStatsGroup(title, rows) {
+    const group = document.createElement('div');
+    group.className = 'usage-stats-group';
+    group.innerHTML = '<h4></h4>';
+    group.querySelector('h4').textContent = title;
+
+    const largest = Math.max(...rows.map(row => row.total.filament_used), 1);
+    rows.forEach(row => {
+        const element = document.createElement('div');
+        element.className = 'usage-stats-row';
+        element.innerHTML = `
+            ${row.color ? '<div class="color-swatch"></div>' : ''}
+            <span class="usage-stats-name"></span>
+            <span class="usage-stats-amount"></span>
+        `;
+        if (row.color) {
+            element.querySelector('.color-swatch').style.backgroundColor = '#' + row.color.replace('#', '');
+        }
+        element.querySelector('.usage-stats-name').textContent = row.name;
+        let amount = `${row.total.filament_used.toFixed(0)}g`;
+        if (row.total.cost > 0) {
+            amount += ` · ${row.total.cost.toFixed(2)}`;
+        }
+        if (row.total.failed_prints > 0) {
+            amount += ` · ${row.total.failed_prints} failed`;
+        }
+        element.querySelector('.usage-stats-amount').textContent = amount;
+        group.appendChild(element);
+
+        const bar = document.createElement('div');
+        bar.className = 'usage-stats-bar';
+        bar.style.width = `${(row.total.filament_used / largest) * 100}%`;
+        group.appendChild(bar);
+    });
+    return group;
+}
+
 // Utility Functions
 function apiUrl(path) {
     // Ensure path starts with / if not already
     if (!path.startsWith('/')) {
         path = '/' + path;
     }
-    return `${window.location.origin}${path}`;
+    // Prefix the base path FilaBridge is served under behind a reverse proxy, if any
+    const basePath = document.body?.dataset.basePath || '';
+    return `${window.location.origin}${basePath}${path}`;
+}
+
+// WebSocket URL for a path, using wss: when the page is served over HTTPS
+function wsUrl(path) {
+    return apiUrl(path).replace(/^http/, 'ws');
 }
 
 // Initialize color swatches based on data-color attributes
@@ -399,7 +681,11 @@ document.addEventListener('DOMContentLoaded', function() {
     connectWebSocket();
     loadNfcData();
     loadPrinters();
+    loadMappingMismatches();
+    loadUsageStats();
     initCustomDropdowns();
     initColorSwatches();
     initEditButtonColors();
+    updatePollAges();
+    setInterval(updatePollAges, 1000);
 });
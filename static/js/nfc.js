@@ -51,7 +51,7 @@ async function loadNfcData() {
 async function loadSpoolTags() {
     try {
         console.log('Loading spool tags...');
-        const response = await fetch('/api/nfc/urls');
+        const response = await fetch(apiUrl('/api/v1/nfc/urls'));
         const data = await response.json();
         console.log('NFC URLs data:', data);
         
@@ -108,7 +108,7 @@ async function loadSpoolTags() {
 async function loadFilamentTags() {
     try {
         console.log('Loading filament tags...');
-        const response = await fetch('/api/nfc/urls');
+        const response = await fetch(apiUrl('/api/v1/nfc/urls'));
         const data = await response.json();
         console.log('NFC URLs data:', data);
         
@@ -165,7 +165,7 @@ async function loadFilamentTags() {
 async function loadLocationTags() {
     try {
         console.log('Loading location tags...');
-        const response = await fetch('/api/nfc/urls');
+        const response = await fetch(apiUrl('/api/v1/nfc/urls'));
         const data = await response.json();
         console.log('NFC URLs data:', data);
         
@@ -214,7 +214,7 @@ async function loadLocationTags() {
             let icon = '📦'; // Storage icon for storage locations
             let iconHtml = icon;
             if (url.location_type === 'printer') {
-                iconHtml = '<img src="/static/images/3d-printer-icon.png" alt="3D Printer" style="width: 20px; height: 20px;">';
+                iconHtml = `<img src="${apiUrl('/static/images/3d-printer-icon.png')}" alt="3D Printer" style="width: 20px; height: 20px;">`;
             }
             
             item.innerHTML = `
@@ -356,6 +356,7 @@ function displaySpoolQR(spoolData) {
     document.getElementById('spool-selected-details').innerHTML = ``;
     document.getElementById('spool-qr-large').src = `data:image/png;base64,${spoolData.qr_code_base64}`;
     document.getElementById('spool-url-text').textContent = spoolData.url;
+    showNfcWriteButton('spool-write-btn', `spool=${spoolData.spool_id}`);
 }
 
 // Display QR code for selected filament
@@ -395,6 +396,83 @@ function displayLocationQR(locationData) {
     `;
     document.getElementById('location-qr-large').src = `data:image/png;base64,${locationData.qr_code_base64}`;
     document.getElementById('location-url-text').textContent = locationData.url;
+    showNfcWriteButton('location-write-btn', `location=${encodeURIComponent(locationData.name)}`);
+}
+
+// Show the progress of NFC scan sessions as phones scan tags
+function handleNfcSessionEvent(data) {
+    const banner = document.getElementById('nfc-session-banner');
+    if (!banner) return;
+
+    const session = data.session;
+    const location = session.is_printer_location
+        ? `${session.printer_name} - Toolhead ${session.toolhead_id}`
+        : session.location_name;
+    let text;
+    switch (data.event) {
+        case 'completed':
+            text = `📱 Spool ${session.spool_id} assigned to ${location}`;
+            break;
+        case 'cancelled':
+            text = '📱 NFC scan session cancelled';
+            break;
+        case 'failed':
+            text = `📱 Failed to assign spool ${session.spool_id} to ${location}`;
+            break;
+        default:
+            if (session.has_spool) {
+                text = `📱 Spool ${session.spool_id} scanned, waiting for a location tag`;
+            } else if (session.has_location) {
+                text = `📱 ${location} scanned, waiting for a spool tag`;
+            } else {
+                text = '📱 NFC scan session started';
+            }
+    }
+
+    banner.textContent = text;
+    banner.style.display = '';
+    clearTimeout(banner.hideTimer);
+    // Waiting sessions stay visible until they expire
+    const visibleFor = data.event === 'updated' ? new Date(session.expires_at) - Date.now() : 10000;
+    banner.hideTimer = setTimeout(() => { banner.style.display = 'none'; }, Math.max(visibleFor, 0));
+}
+
+// Show the write button where Web NFC is available (Chrome on Android, over HTTPS)
+function showNfcWriteButton(buttonId, query) {
+    const button = document.getElementById(buttonId);
+    if (!button) return;
+    button.dataset.ndefQuery = query;
+    button.style.display = 'NDEFReader' in window ? '' : 'none';
+}
+
+// Write the selected tag's NDEF message to an NFC tag held against the phone
+async function writeNfcTag(buttonElement) {
+    const icon = buttonElement.querySelector('.nfc-copy-icon');
+    const originalIcon = icon.textContent;
+    try {
+        const response = await fetch(apiUrl(`/api/v1/nfc/ndef?${buttonElement.dataset.ndefQuery}`));
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to get NDEF data');
+        }
+
+        icon.textContent = '📡';
+        buttonElement.title = 'Hold a tag against your phone...';
+        await new NDEFReader().write(data.web_nfc);
+
+        icon.textContent = '✓';
+        buttonElement.style.background = 'rgba(76, 175, 80, 0.3)';
+        setTimeout(() => {
+            icon.textContent = originalIcon;
+            buttonElement.style.background = '';
+        }, 2000);
+    } catch (error) {
+        console.error('Failed to write NFC tag:', error);
+        icon.textContent = originalIcon;
+        alert(`Failed to write NFC tag: ${error.message}`);
+    } finally {
+        buttonElement.title = 'Write to NFC tag';
+    }
 }
 
 // Initialize search functionality for spools
@@ -469,7 +547,7 @@ async function addLocation() {
     const name = (nameEl.value || '').trim();
     if (!name) { alert('Please enter a location name'); return; }
     try {
-        const url = apiUrl('/api/locations');
+        const url = apiUrl('/api/v1/locations');
         console.log('POST', url, { name });
         const res = await fetch(url, {
             method: 'POST',
@@ -487,7 +565,7 @@ async function renameLocation(currentName) {
     const newName = prompt('Rename location', currentName || '');
     if (!newName || newName.trim() === '' || newName === currentName) return;
     try {
-        const url = apiUrl(`/api/locations/${encodeURIComponent(currentName)}`);
+        const url = apiUrl(`/api/v1/locations/${encodeURIComponent(currentName)}`);
         console.log('PUT', url, { name: newName.trim() });
         const res = await fetch(url, {
             method: 'PUT',
@@ -514,7 +592,7 @@ async function renameLocation(currentName) {
 async function deleteLocation(name) {
     try {
         console.log('deleteLocation called with name:', name);
-        const url = apiUrl(`/api/locations/${encodeURIComponent(name)}`);
+        const url = apiUrl(`/api/v1/locations/${encodeURIComponent(name)}`);
         console.log('DELETE', url);
         const res = await fetch(url, {
             method: 'DELETE',
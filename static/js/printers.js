@@ -10,7 +10,7 @@ function escapeHtmlAttribute(value) {
 
 // Printer Management Functions
 function loadPrinters() {
-    fetch('/api/printers')
+    fetch(apiUrl('/api/v1/printers'))
         .then(response => response.json())
         .then(data => {
             const printerList = document.getElementById('printer-list');
@@ -26,7 +26,7 @@ function loadPrinters() {
                     // Build toolhead names section
                     let toolheadNamesHTML = '';
                     const toolheadNames = printer.toolhead_names || {};
-                    for (let toolheadID = 0; toolheadID < (printer.toolheads || 1); toolheadID++) {
+                    for (let toolheadID = 0; toolheadID < (printer.slots || printer.toolheads || 1); toolheadID++) {
                         const currentName = toolheadNames[toolheadID] || `Toolhead ${toolheadID}`;
                         const escapedName = escapeHtmlAttribute(currentName);
                         toolheadNamesHTML += `
@@ -46,9 +46,10 @@ function loadPrinters() {
                     printerCard.innerHTML = `
                         <h3>${printer.name || 'Unknown Printer'}</h3>
                         <div class="printer-info">
-                            <div><strong>Model:</strong> ${printer.model || 'Unknown'} (${printer.toolheads || 1} toolhead${printer.toolheads > 1 ? 's' : ''})</div>
+                            <div><strong>Model:</strong> ${printer.model || 'Unknown'} (${printer.toolheads || 1} toolhead${printer.toolheads > 1 ? 's' : ''}${printer.slots ? `, ${printer.slots} MMU slots` : ''})</div>
                             <div><strong>Address:</strong> ${printer.ip_address || 'Not configured'}</div>
                             <div><strong>API Key:</strong> ${printer.api_key ? '••••••••' : 'Not configured'}</div>
+                            ${printer.group ? `<div><strong>Group:</strong> ${escapeHtmlAttribute(printer.group)}</div>` : ''}
                         </div>
                         <div class="printer-actions">
                             <button class="btn btn-small" onclick="editPrinter('${printerId}')">✏️ Edit</button>
@@ -76,9 +77,30 @@ function loadPrinters() {
         });
 }
 
+// Fill a printer form's instance select with the configured Spoolman instances
+function loadSpoolmanInstanceOptions(select, current) {
+    return fetch(apiUrl('/api/v1/spoolman/instances'))
+        .then(response => response.json())
+        .then(data => {
+            select.innerHTML = '<option value="">Main Spoolman</option>';
+            (data.instances || []).forEach(instance => {
+                const option = document.createElement('option');
+                option.value = instance.name;
+                option.textContent = instance.name;
+                select.appendChild(option);
+            });
+            select.value = current || '';
+        })
+        .catch(error => {
+            console.error('Error loading Spoolman instances:', error);
+        });
+}
+
 function showAddPrinterForm() {
     document.getElementById('addPrinterModal').style.display = 'block';
     document.getElementById('addPrinterForm').reset();
+    document.getElementById('discoverResults').innerHTML = '';
+    loadSpoolmanInstanceOptions(document.getElementById('printerSpoolmanInstance'), '');
     
     // Reset button state AFTER form reset with a fresh query
     // Use setTimeout to ensure DOM is updated
@@ -124,8 +146,64 @@ window.onclick = function(event) {
     }
 }
 
+// Search the network for PrusaLink printers and list them for the add printer form
+async function discoverPrinters() {
+    const subnet = document.getElementById('discoverSubnet').value.trim();
+    const results = document.getElementById('discoverResults');
+    results.textContent = 'Searching...';
+
+    try {
+        const query = subnet ? `?subnet=${encodeURIComponent(subnet)}` : '';
+        const response = await fetch(apiUrl(`/api/v1/discover_printers${query}`));
+        const data = await response.json();
+        if (data.error) {
+            throw new Error(data.error);
+        }
+
+        results.innerHTML = '';
+        if (data.printers.length === 0) {
+            results.textContent = 'No printers found';
+            return;
+        }
+        data.printers.forEach(printer => {
+            const row = document.createElement('div');
+            row.className = 'discover-result';
+            const label = document.createElement('span');
+            label.textContent = `${printer.hostname || printer.address} (${printer.address}, ${printer.model})` +
+                (printer.configured ? ' - already added' : '');
+            row.appendChild(label);
+            if (!printer.configured) {
+                const button = document.createElement('button');
+                button.type = 'button';
+                button.className = 'btn btn-small';
+                button.textContent = 'Use';
+                button.onclick = () => useDiscoveredPrinter(printer);
+                row.appendChild(button);
+            }
+            results.appendChild(row);
+        });
+    } catch (error) {
+        console.error('Error discovering printers:', error);
+        results.textContent = 'Error discovering printers: ' + error.message;
+    }
+}
+
+// Fill the add printer form from a discovered printer
+function useDiscoveredPrinter(printer) {
+    document.getElementById('printerIP').value = printer.address;
+    const nameInput = document.getElementById('printerName');
+    if (!nameInput.value && printer.hostname) {
+        nameInput.value = printer.hostname;
+    }
+    const modelSelect = document.getElementById('printerModel');
+    if ([...modelSelect.options].some(option => option.value === printer.model)) {
+        modelSelect.value = printer.model;
+    }
+    document.getElementById('printerAPIKey').focus();
+}
+
 function addPrinter(printerConfig) {
-    return fetch('/api/printers', {
+    return fetch(apiUrl('/api/v1/printers'), {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify(printerConfig)
@@ -154,6 +232,10 @@ document.getElementById('addPrinterForm').addEventListener('submit', function(e)
     const ipAddress = formData.get('ip_address');
     const apiKey = formData.get('api_key');
     const toolheads = parseInt(formData.get('toolheads'));
+    const slots = parseInt(formData.get('slots')) || 0;
+    const gcodeFlavor = formData.get('gcode_flavor') || '';
+    const spoolmanInstance = formData.get('spoolman_instance') || '';
+    const insecureSkipVerify = formData.get('insecure_skip_verify') === 'on';
     
     // Show loading state
     const submitButton = this.querySelector('button[type="submit"]');
@@ -162,7 +244,7 @@ document.getElementById('addPrinterForm').addEventListener('submit', function(e)
     submitButton.textContent = 'Detecting model...';
     
     // First detect printer model, then add printer
-    detectModelAndAddPrinter(name, ipAddress, apiKey, toolheads, submitButton, originalText);
+    detectModelAndAddPrinter(name, ipAddress, apiKey, toolheads, slots, gcodeFlavor, spoolmanInstance, insecureSkipVerify, submitButton, originalText);
 });
 
 // Handle edit form submission
@@ -176,6 +258,15 @@ document.getElementById('editPrinterForm').addEventListener('submit', function(e
     const ipAddress = formData.get('ip_address');
     const apiKey = formData.get('api_key');
     const toolheads = parseInt(formData.get('toolheads'));
+    const slots = parseInt(formData.get('slots')) || 0;
+    const gcodeFlavor = formData.get('gcode_flavor') || '';
+    const spoolmanInstance = formData.get('spoolman_instance') || '';
+    const insecureSkipVerify = formData.get('insecure_skip_verify') === 'on';
+    const group = (formData.get('group') || '').trim();
+    const pollInterval = parseInt(formData.get('poll_interval')) || 0;
+    const activePollInterval = parseInt(formData.get('active_poll_interval')) || 0;
+    const prusaLinkTimeout = parseInt(formData.get('prusalink_timeout')) || 0;
+    const downloadTimeout = parseInt(formData.get('prusalink_file_download_timeout')) || 0;
     
     // Validate printerId is present
     if (!printerId) {
@@ -200,15 +291,42 @@ document.getElementById('editPrinterForm').addEventListener('submit', function(e
         model: model,
         ip_address: ipAddress,
         api_key: apiKey,
-        toolheads: toolheads
+        toolheads: toolheads,
+        slots: slots,
+        gcode_flavor: gcodeFlavor,
+        spoolman_instance: spoolmanInstance,
+        insecure_skip_verify: insecureSkipVerify,
+        group: group,
+        poll_interval: pollInterval,
+        active_poll_interval: activePollInterval,
+        prusalink_timeout: prusaLinkTimeout,
+        prusalink_file_download_timeout: downloadTimeout
     };
     
+    // A changed key is verified against the printer and swapped in first, so a wrong key
+    // is rejected instead of breaking monitoring
+    const originalKey = document.getElementById('editPrinterAPIKey').dataset.originalKey || '';
+    const originalAddress = document.getElementById('editPrinterIP').dataset.originalAddress || '';
+    const rotateKey = apiKey !== originalKey && ipAddress && ipAddress === originalAddress
+        ? fetch(apiUrl(`/api/v1/printers/${printerId}/rotate-key`), {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({ api_key: apiKey })
+        })
+            .then(response => response.json())
+            .then(data => {
+                if (data.error) {
+                    throw new Error(data.error);
+                }
+            })
+        : Promise.resolve();
+    
     // Update the printer
-    fetch(`/api/printers/${printerId}`, {
+    rotateKey.then(() => fetch(apiUrl(`/api/v1/printers/${printerId}`), {
         method: 'PUT',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify(printerConfig)
-    })
+    }))
     .then(response => response.json())
     .then(data => {
         if (data.error) {
@@ -229,14 +347,15 @@ document.getElementById('editPrinterForm').addEventListener('submit', function(e
     });
 });
 
-function detectModelAndAddPrinter(name, ipAddress, apiKey, toolheads, submitButton, originalText) {
+function detectModelAndAddPrinter(name, ipAddress, apiKey, toolheads, slots, gcodeFlavor, spoolmanInstance, insecureSkipVerify, submitButton, originalText) {
     // Detect printer model only
-    fetch('/api/detect_printer', {
+    fetch(apiUrl('/api/v1/detect_printer'), {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify({
             ip_address: ipAddress,
-            api_key: apiKey
+            api_key: apiKey,
+            insecure_skip_verify: insecureSkipVerify
         })
     })
     .then(response => response.json())
@@ -257,7 +376,12 @@ function detectModelAndAddPrinter(name, ipAddress, apiKey, toolheads, submitButt
             model: data.model || "Unknown",
             ip_address: ipAddress,
             api_key: apiKey,
-            toolheads: toolheads
+            toolheads: toolheads,
+            // A detected MMU3 gets its five slots unless some were chosen
+            slots: slots || (data.mmu ? 5 : 0),
+            gcode_flavor: gcodeFlavor,
+            spoolman_instance: spoolmanInstance,
+            insecure_skip_verify: insecureSkipVerify
         };
         
         // Add the printer
@@ -278,7 +402,7 @@ function detectModelAndAddPrinter(name, ipAddress, apiKey, toolheads, submitButt
 
 function editPrinter(printerId) {
     // Get the current printer data
-    fetch('/api/printers')
+    fetch(apiUrl('/api/v1/printers'))
         .then(response => response.json())
         .then(data => {
             const printer = data.printers[printerId];
@@ -293,7 +417,18 @@ function editPrinter(printerId) {
             document.getElementById('editPrinterModel').value = printer.model || '';
             document.getElementById('editPrinterIP').value = printer.ip_address || '';
             document.getElementById('editPrinterAPIKey').value = printer.api_key || '';
+            document.getElementById('editPrinterAPIKey').dataset.originalKey = printer.api_key || '';
+            document.getElementById('editPrinterIP').dataset.originalAddress = printer.ip_address || '';
+            document.getElementById('editPrinterInsecureSkipVerify').checked = printer.insecure_skip_verify || false;
             document.getElementById('editPrinterToolheads').value = printer.toolheads || 1;
+            document.getElementById('editPrinterSlots').value = printer.slots || 0;
+            document.getElementById('editPrinterGcodeFlavor').value = printer.gcode_flavor || '';
+            loadSpoolmanInstanceOptions(document.getElementById('editPrinterSpoolmanInstance'), printer.spoolman_instance);
+            document.getElementById('editPrinterGroup').value = printer.group || '';
+            document.getElementById('editPrinterPollInterval').value = printer.poll_interval || '';
+            document.getElementById('editPrinterActivePollInterval').value = printer.active_poll_interval || '';
+            document.getElementById('editPrinterTimeout').value = printer.prusalink_timeout || '';
+            document.getElementById('editPrinterDownloadTimeout').value = printer.prusalink_file_download_timeout || '';
             
             // Show the edit modal
             document.getElementById('editPrinterModal').style.display = 'block';
@@ -306,7 +441,7 @@ function editPrinter(printerId) {
 
 function deletePrinter(printerId) {
     if (confirm('Are you sure you want to delete this printer?')) {
-        fetch(`/api/printers/${printerId}`, {
+        fetch(apiUrl(`/api/v1/printers/${printerId}`), {
             method: 'DELETE'
         })
         .then(response => response.json())
@@ -366,7 +501,7 @@ function saveToolheadNames(printerId) {
     
     // Save each toolhead name
     const savePromises = updates.map(update => {
-        return fetch(`/api/printers/${printerId}/toolheads/${update.toolheadId}`, {
+        return fetch(apiUrl(`/api/v1/printers/${printerId}/toolheads/${update.toolheadId}`), {
             method: 'PUT',
             headers: {'Content-Type': 'application/json'},
             body: JSON.stringify({ name: update.name })
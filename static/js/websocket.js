@@ -7,11 +7,10 @@ let maxReconnectAttempts = 10;
 let reconnectDelay = 1000; // Start with 1 second
 
 function connectWebSocket() {
-    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws/status`;
+    const statusUrl = wsUrl('/ws/status');
     
     try {
-        ws = new WebSocket(wsUrl);
+        ws = new WebSocket(statusUrl);
         
         ws.onopen = function(event) {
             console.log('WebSocket connected');
@@ -25,6 +24,8 @@ function connectWebSocket() {
                 const data = JSON.parse(event.data);
                 if (data.type === 'status_update') {
                     updateDashboard(data);
+                } else if (data.type === 'nfc_session') {
+                    handleNfcSessionEvent(data);
                 }
             } catch (error) {
                 console.error('Error parsing WebSocket message:', error);
@@ -123,6 +124,26 @@ function updateDashboard(data) {
     if (data.print_errors) {
         updatePrintErrors(data.print_errors);
     }
+    
+    // Update filament runouts waiting for a decision
+    if (data.filament_runouts) {
+        updateFilamentRunouts(data.filament_runouts);
+    }
+    
+    // Update usage of unmapped toolheads waiting for a spool
+    if (data.unattributed_usage) {
+        updateUnattributedUsage(data.unattributed_usage);
+    }
+    
+    // Update runout alerts
+    if (data.runout_alerts) {
+        updateRunoutAlerts(data.runout_alerts);
+    }
+    
+    // Update remaining weight estimates of spools in use
+    if (data.active_spools) {
+        updateActiveSpools(data.active_spools);
+    }
 }
 
 function updatePrinterStatuses(printers) {
@@ -139,14 +160,92 @@ function updatePrinterStatuses(printers) {
             statusBadge.className = `status ${printerData.state}`;
             statusBadge.textContent = printerData.state;
         }
+
+        // Update the current job and temperatures
+        const monitor = printerElement.querySelector('.printer-monitor');
+        if (monitor) {
+            monitor.innerHTML = renderPrinterMonitor(printerData);
+        }
+
+        // Update poll timing
+        const pollAge = printerElement.querySelector('.poll-age');
+        if (pollAge) {
+            pollAge.dataset.lastPoll = printerData.last_successful_poll_at || '';
+            pollAge.dataset.nextPoll = printerData.next_poll_at || '';
+        }
+    });
+    updatePollAges();
+}
+
+// Render a printer's current job and temperatures, matching the dashboard template
+function renderPrinterMonitor(printerData) {
+    let html = '';
+    const job = printerData.job;
+    if (job) {
+        const progress = Math.round(job.progress);
+        html += `
+            <p><strong>Job:</strong> ${escapeHtmlAttribute(job.display_name || job.file)}</p>
+            <div class="job-progress"><div class="job-progress-bar" style="width: ${progress}%;"></div></div>
+            <p class="job-times">${progress}% · ${formatJobDuration(job.time_printing_seconds)} elapsed · ${formatJobDuration(job.time_remaining_seconds)} left</p>
+        `;
+    }
+    const temperatures = printerData.temperatures;
+    if (temperatures) {
+        html += `<p class="printer-temperatures">🌡️ Nozzle ${Math.round(temperatures.nozzle)}/${Math.round(temperatures.nozzle_target)}°C · Bed ${Math.round(temperatures.bed)}/${Math.round(temperatures.bed_target)}°C</p>`;
+    }
+    return html;
+}
+
+// Format a job duration the way the server does, e.g. "1h 05m" or "42m"
+function formatJobDuration(seconds) {
+    const minutes = Math.floor(seconds / 60);
+    if (minutes < 60) {
+        return `${minutes}m`;
+    }
+    return `${Math.floor(minutes / 60)}h ${String(minutes % 60).padStart(2, '0')}m`;
+}
+
+// Show how old each printer's data is, e.g. "Data as of 12s ago · next poll in 18s"
+function updatePollAges() {
+    const now = Date.now();
+    document.querySelectorAll('.poll-age').forEach(element => {
+        const lastPoll = element.dataset.lastPoll;
+        if (!lastPoll) {
+            element.textContent = '';
+            return;
+        }
+
+        let text = `Data as of ${formatPollDuration(now - new Date(lastPoll).getTime())} ago`;
+        const nextPoll = element.dataset.nextPoll;
+        if (nextPoll) {
+            const untilNext = new Date(nextPoll).getTime() - now;
+            text += untilNext > 0 ? ` · next poll in ${formatPollDuration(untilNext)}` : ' · polling now';
+        }
+        element.textContent = text;
     });
 }
 
+function formatPollDuration(milliseconds) {
+    const seconds = Math.max(0, Math.round(milliseconds / 1000));
+    if (seconds < 60) {
+        return `${seconds}s`;
+    }
+    const minutes = Math.floor(seconds / 60);
+    if (minutes < 60) {
+        return `${minutes}m ${seconds % 60}s`;
+    }
+    return `${Math.floor(minutes / 60)}h ${minutes % 60}m`;
+}
+
 function updateSpoolData(spools) {
     // Update spool dropdowns with new weight data
     document.querySelectorAll('.custom-dropdown').forEach(dropdown => {
         const optionsContainer = dropdown.querySelector('.dropdown-options-container');
         if (!optionsContainer) return;
+
+        // Spools from the main Spoolman don't belong on printers using another instance
+        const toolheadRow = dropdown.closest('.toolhead-mapping-row');
+        if (toolheadRow && toolheadRow.dataset.spoolmanInstance) return;
         
         // Clear existing options except "Empty"
         const selectOption = optionsContainer.querySelector('.dropdown-option[data-value=""]');
@@ -276,11 +375,12 @@ function updateToolheadMappings(mappings) {
                     const selectedText = spoolOption.querySelector('.option-text').textContent;
                     const selectedColor = spoolOption.dataset.color;
                     
-                    // Update button display
+                    // Update button display; shared spools may be on other toolheads too
+                    const sharedLabel = mapping.shared ? ' (shared)' : '';
                     dropdownButton.innerHTML = `
                         <div style="display: flex; align-items: center; gap: 10px;">
                             <div class="color-swatch" style="background-color: #${selectedColor || 'ccc'};"></div>
-                            <span>${selectedText}</span>
+                            <span>${selectedText}${sharedLabel}</span>
                         </div>
                         <span class="dropdown-arrow">▼</span>
                     `;
@@ -350,10 +450,11 @@ function updatePrintErrors(printErrors) {
         errorElement.innerHTML = `
             <h4 style="margin-top: 0;">⚠️ Print Processing Failed</h4>
             <p><strong>Printer:</strong> ${error.printer_name}</p>
-            <p><strong>File:</strong> ${error.filename}</p>
+            <p><strong>File:</strong> ${error.display_name || error.filename}</p>
             <p><strong>Time:</strong> ${timestamp}</p>
             <p><strong>Error:</strong> ${error.error}</p>
-            <p><strong>Action Required:</strong> Please update Spoolman manually with the correct filament usage for this print.</p>
+            <p><strong>Action Required:</strong> ${error.retryable ? 'Retry processing once the problem is fixed, or update' : 'Please update'} Spoolman manually with the correct filament usage for this print.</p>
+            ${error.retryable ? `<button class="btn" onclick="retryError('${error.id}', this)" style="margin-top: 10px;">Retry</button>` : ''}
             <button class="btn" onclick="acknowledgeError('${error.id}')" style="background: #dc3545; margin-top: 10px;">Acknowledge</button>
         `;
         
@@ -361,10 +462,268 @@ function updatePrintErrors(printErrors) {
     });
 }
 
+function updateFilamentRunouts(runouts) {
+    const container = document.getElementById('filament-runouts-container');
+    if (!container) return;
+    
+    container.innerHTML = '';
+    
+    if (runouts.length === 0) {
+        container.style.display = 'none';
+        return;
+    }
+    
+    container.style.display = 'block';
+    
+    runouts.forEach(runout => {
+        const runoutElement = document.createElement('div');
+        runoutElement.className = 'filament-runout';
+        runoutElement.style.cssText = 'background: #f8d7da; border: 1px solid #f5c6cb; color: #721c24; padding: 20px; margin: 20px 0; border-radius: 8px;';
+        
+        const remaining = runout.estimated_remaining !== undefined
+            ? `, estimated to still have ${runout.estimated_remaining.toFixed(1)}g`
+            : '';
+        const printerAttr = (runout.printer_name || '').replace(/'/g, "\\'").replace(/"/g, '&quot;');
+        
+        runoutElement.innerHTML = `
+            <h4 style="margin-top: 0;">🧵 Filament Runout</h4>
+            <p><strong>Printer:</strong> ${runout.printer_name} (toolhead ${runout.toolhead_id})</p>
+            <p><strong>Job:</strong> ${runout.job_name}</p>
+            ${runout.reason ? `<p><strong>Printer reports:</strong> ${runout.reason}</p>` : ''}
+            <p><strong>Spool:</strong> #${runout.spool_id}${remaining}</p>
+            <p>If the spool is empty, mark it empty to archive it in Spoolman and unmap it.</p>
+            <button class="btn" onclick="resolveFilamentRunout(${runout.id}, 'confirm', this)" style="background: #dc3545; margin-top: 10px;">Mark Empty</button>
+            <button class="btn" onclick="resolveFilamentRunout(${runout.id}, 'dismiss', this)" style="margin-top: 10px;">Dismiss</button>
+            <div style="margin-top: 10px;">
+                <strong>Printer:</strong>
+                <button class="btn" onclick="controlPrinter('${printerAttr}', 'pause', this)">Pause</button>
+                <button class="btn" onclick="controlPrinter('${printerAttr}', 'resume', this)">Resume</button>
+                <button class="btn" onclick="controlPrinter('${printerAttr}', 'cooldown', this)">Cool Down</button>
+                <button class="btn" onclick="controlPrinter('${printerAttr}', 'stop', this)" style="background: #dc3545;">Stop</button>
+            </div>
+        `;
+        
+        container.appendChild(runoutElement);
+    });
+}
+
+// Confirm or dismiss a filament runout; the status update that follows refreshes the list
+async function resolveFilamentRunout(runoutId, action, button) {
+    button.disabled = true;
+    try {
+        const response = await fetch(apiUrl(`/api/v1/filament-runouts/${encodeURIComponent(runoutId)}/${action}`), {
+            method: 'POST'
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            alert('Failed to update runout: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        console.error('Error resolving filament runout:', error);
+        alert('Failed to update runout: ' + error.message);
+    } finally {
+        button.disabled = false;
+    }
+}
+
+// Pause, resume or stop a printer's job, or turn off its heaters, through FilaBridge
+async function controlPrinter(printerName, action, button) {
+    if (action === 'stop' && !confirm(`Stop the print on ${printerName}? It can't be resumed.`)) {
+        return;
+    }
+    button.disabled = true;
+    try {
+        const response = await fetch(apiUrl(`/api/v1/printers/${encodeURIComponent(printerName)}/actions/${action}`), {
+            method: 'POST'
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            alert('Printer action failed: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        console.error('Error controlling printer:', error);
+        alert('Printer action failed: ' + error.message);
+    } finally {
+        button.disabled = false;
+    }
+}
+
+function updateUnattributedUsage(usages) {
+    const container = document.getElementById('unattributed-usage-container');
+    if (!container) return;
+    
+    container.innerHTML = '';
+    
+    if (usages.length === 0) {
+        container.style.display = 'none';
+        return;
+    }
+    
+    container.style.display = 'block';
+    
+    usages.forEach(usage => {
+        const usageElement = document.createElement('div');
+        usageElement.className = 'unattributed-usage';
+        usageElement.style.cssText = 'background: #fff3cd; border: 1px solid #ffeeba; color: #856404; padding: 20px; margin: 20px 0; border-radius: 8px;';
+        
+        usageElement.innerHTML = `
+            <h4 style="margin-top: 0;">❔ Usage Not Charged</h4>
+            <p><strong>Printer:</strong> ${usage.printer_name} (toolhead ${usage.toolhead_id})</p>
+            <p><strong>Job:</strong> ${usage.job_display_name || usage.job_name}</p>
+            <p><strong>Used:</strong> ${usage.filament_used.toFixed(1)}g on ${new Date(usage.created_at).toLocaleString()}</p>
+            <p>No spool was mapped to the toolhead. Enter the spool that was loaded to charge it.</p>
+            <input type="number" min="1" placeholder="Spool ID" id="unattributed-spool-${usage.id}" style="width: 120px; margin-top: 10px;">
+            <button class="btn" onclick="assignUnattributedUsage(${usage.id}, this)" style="margin-top: 10px;">Assign</button>
+            <button class="btn" onclick="discardUnattributedUsage(${usage.id}, this)" style="background: #dc3545; margin-top: 10px;">Discard</button>
+        `;
+        
+        container.appendChild(usageElement);
+    });
+}
+
+// Charge unattributed usage to the spool entered; the status update that follows refreshes the list
+async function assignUnattributedUsage(usageId, button) {
+    const spoolId = parseInt(document.getElementById(`unattributed-spool-${usageId}`).value, 10);
+    if (!spoolId) {
+        alert('Enter the ID of the spool that was loaded');
+        return;
+    }
+    
+    button.disabled = true;
+    try {
+        const response = await fetch(apiUrl(`/api/v1/unattributed-usage/${encodeURIComponent(usageId)}/assign`), {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ spool_id: spoolId })
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            alert('Failed to assign usage: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        console.error('Error assigning unattributed usage:', error);
+        alert('Failed to assign usage: ' + error.message);
+    } finally {
+        button.disabled = false;
+    }
+}
+
+async function discardUnattributedUsage(usageId, button) {
+    if (!confirm('Discard this usage without charging any spool?')) return;
+    
+    button.disabled = true;
+    try {
+        const response = await fetch(apiUrl(`/api/v1/unattributed-usage/${encodeURIComponent(usageId)}`), {
+            method: 'DELETE'
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            alert('Failed to discard usage: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        console.error('Error discarding unattributed usage:', error);
+        alert('Failed to discard usage: ' + error.message);
+    } finally {
+        button.disabled = false;
+    }
+}
+
+function updateRunoutAlerts(alerts) {
+    const container = document.getElementById('runout-alerts-container');
+    if (!container) return;
+    
+    container.innerHTML = '';
+    
+    if (alerts.length === 0) {
+        container.style.display = 'none';
+        return;
+    }
+    
+    container.style.display = 'block';
+    
+    alerts.forEach(alert => {
+        const alertElement = document.createElement('div');
+        alertElement.className = 'runout-alert';
+        alertElement.style.cssText = 'background: #fff3cd; border: 1px solid #ffeeba; color: #856404; padding: 20px; margin: 20px 0; border-radius: 8px;';
+        
+        let eta = 'unknown';
+        if (alert.time_to_empty === 0) {
+            eta = 'now';
+        } else if (alert.estimated_empty_at) {
+            const minutes = Math.round(alert.time_to_empty / 60);
+            eta = `in about ${minutes} min (${new Date(alert.estimated_empty_at).toLocaleTimeString()})`;
+        }
+        
+        alertElement.innerHTML = `
+            <h4 style="margin-top: 0;">⏳ Spool Will Run Out Mid-Print</h4>
+            <p><strong>Printer:</strong> ${alert.printer_name} (toolhead ${alert.toolhead_id})</p>
+            <p><strong>Job:</strong> ${alert.job_name}</p>
+            <p><strong>Spool:</strong> #${alert.spool_id} has ${alert.remaining.toFixed(1)}g left, job needs ${alert.required.toFixed(1)}g</p>
+            <p><strong>Runs out at:</strong> ~${alert.runout_progress.toFixed(0)}% progress, ${eta}</p>
+        `;
+        
+        container.appendChild(alertElement);
+    });
+}
+
+// Show on each toolhead row in use how much its spool will have left after the current job
+function updateActiveSpools(activeSpools) {
+    const estimates = new Map();
+    activeSpools.forEach(active => {
+        estimates.set(`${active.printer_id}-${active.toolhead_id}`, active);
+    });
+    
+    document.querySelectorAll('.toolhead-mapping-row').forEach(toolheadRow => {
+        const key = `${toolheadRow.getAttribute('data-printer-id')}-${toolheadRow.getAttribute('data-toolhead-id')}`;
+        const active = estimates.get(key);
+        let estimateElement = toolheadRow.querySelector('.active-spool-estimate');
+        
+        if (!active) {
+            if (estimateElement) estimateElement.remove();
+            return;
+        }
+        
+        if (!estimateElement) {
+            estimateElement = document.createElement('div');
+            estimateElement.className = 'active-spool-estimate';
+            estimateElement.style.cssText = 'min-width: 140px; font-size: 0.9em; text-align: right;';
+            toolheadRow.querySelector('.custom-dropdown').after(estimateElement);
+        }
+        
+        const afterJob = Math.round(active.remaining_after_job);
+        estimateElement.style.color = afterJob < 0 ? '#dc3545' : '';
+        estimateElement.textContent = afterJob < 0
+            ? `~${-afterJob} g short for this job`
+            : `~${afterJob} g left after this job`;
+        estimateElement.title = `${active.job_name}: ~${Math.round(active.remaining)} g left now, ${active.used.toFixed(1)} of ${active.required.toFixed(1)} g used at ${active.progress.toFixed(0)}%`;
+    });
+}
+
+// Process the job of a print error again; the status update that follows refreshes the list
+async function retryError(errorId, button) {
+    button.disabled = true;
+    button.textContent = 'Retrying...';
+    try {
+        const response = await fetch(apiUrl(`/api/v1/print-errors/${encodeURIComponent(errorId)}/retry`), {
+            method: 'POST'
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            alert('Retry failed: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        console.error('Error retrying print error:', error);
+        alert('Retry failed: ' + error.message);
+    } finally {
+        button.disabled = false;
+        button.textContent = 'Retry';
+    }
+}
+
 // Acknowledge print error
 async function acknowledgeError(errorId) {
     try {
-        const response = await fetch(`/api/print-errors/${encodeURIComponent(errorId)}/acknowledge`, {
+        const response = await fetch(apiUrl(`/api/v1/print-errors/${encodeURIComponent(errorId)}/acknowledge`), {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
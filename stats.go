@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageTotal is the filament used by one group of print history entries
+type UsageTotal struct {
+	Key          string  `json:"key"` // Printer, material, week ("2026-W07") or month ("2026-02")
+	FilamentUsed float64 `json:"filament_used"`
+	Waste        float64 `json:"waste"`
+	Cost         float64 `json:"cost"` // Of the entries that have one
+	Entries      int     `json:"entries"`
+	Prints       int     `json:"prints"`        // Monitored prints; manually logged usage isn't one
+	FailedPrints int     `json:"failed_prints"` // Prints rated FailedPrintRating or lower
+}
+
+// SpoolUsageTotal is the filament used from one spool
+type SpoolUsageTotal struct {
+	UsageTotal
+	SpoolID  int       `json:"spool_id"`
+	Name     string    `json:"name"`
+	Material string    `json:"material"`
+	ColorHex string    `json:"color_hex,omitempty"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// FilamentUsageTotal is the filament used from all spools of one Spoolman filament
+type FilamentUsageTotal struct {
+	UsageTotal
+	FilamentID int    `json:"filament_id"`
+	Name       string `json:"name"`
+	Material   string `json:"material"`
+	ColorHex   string `json:"color_hex,omitempty"`
+	Spools     int    `json:"spools"`
+}
+
+// UsageStats aggregates print history over a period
+type UsageStats struct {
+	Days             int                  `json:"days"` // 0 covers all history
+	Since            *time.Time           `json:"since,omitempty"`
+	TotalUsed        float64              `json:"total_used"`
+	TotalWaste       float64              `json:"total_waste"`
+	TotalCost        float64              `json:"total_cost"` // In Spoolman's currency, of the entries priced when recorded
+	Entries          int                  `json:"entries"`
+	Prints           int                  `json:"prints"`
+	RatedPrints      int                  `json:"rated_prints"`
+	FailedPrints     int                  `json:"failed_prints"`
+	RevertedEntries  int                  `json:"reverted_entries"`  // Left out of the totals
+	ProcessingErrors int                  `json:"processing_errors"` // Prints whose usage couldn't be recorded, not yet acknowledged
+	ByPrinter        []UsageTotal         `json:"by_printer"`
+	ByMaterial       []UsageTotal         `json:"by_material"`
+	BySpool          []SpoolUsageTotal    `json:"by_spool"`
+	ByWeek           []UsageTotal         `json:"by_week"`
+	ByMonth          []UsageTotal         `json:"by_month"`
+	TopFilaments     []FilamentUsageTotal `json:"top_filaments"`
+}
+
+// historyPrint identifies the print a history entry belongs to; a multi-toolhead print
+// records one entry per toolhead
+type historyPrint struct {
+	printerName string
+	jobName     string
+	finished    time.Time
+}
+
+// usageTally adds entries up into groups, counting each print once per group
+type usageTally struct {
+	totals  map[string]*UsageTotal
+	counted map[string]map[historyPrint]bool
+}
+
+func newUsageTally() *usageTally {
+	return &usageTally{totals: make(map[string]*UsageTotal), counted: make(map[string]map[historyPrint]bool)}
+}
+
+// add counts an entry in a group. print is nil for manually logged usage.
+func (t *usageTally) add(key string, entry PrintHistory, print *historyPrint, failed bool) {
+	total, exists := t.totals[key]
+	if !exists {
+		total = &UsageTotal{Key: key}
+		t.totals[key] = total
+		t.counted[key] = make(map[historyPrint]bool)
+	}
+	total.FilamentUsed += entry.FilamentUsed
+	total.Waste += entry.Waste
+	if entry.Cost != nil {
+		total.Cost += *entry.Cost
+	}
+	total.Entries++
+	if print != nil && !t.counted[key][*print] {
+		t.counted[key][*print] = true
+		total.Prints++
+		if failed {
+			total.FailedPrints++
+		}
+	}
+}
+
+// byUsage lists the groups, most filament used first
+func (t *usageTally) byUsage() []UsageTotal {
+	list := t.list()
+	sort.SliceStable(list, func(i, j int) bool { return list[i].FilamentUsed > list[j].FilamentUsed })
+	return list
+}
+
+// list returns the groups sorted by key, which is chronological for weeks and months
+func (t *usageTally) list() []UsageTotal {
+	list := make([]UsageTotal, 0, len(t.totals))
+	for _, total := range t.totals {
+		list = append(list, *total)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// ComputeUsageStats aggregates the print history of the last days, or all of it when days is
+// 0, by printer, material, spool, filament, week and month. Reverted entries are left out.
+func (b *FilamentBridge) ComputeUsageStats(days int, now time.Time) (*UsageStats, error) {
+	return b.computeUsageStats(days, now, nil)
+}
+
+// computeUsageStats aggregates the print history of the given printers by name, or of all
+// history when printers is nil
+func (b *FilamentBridge) computeUsageStats(days int, now time.Time, printers map[string]bool) (*UsageStats, error) {
+	history, err := b.GetPrintHistory(PrintHistoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &UsageStats{Days: days}
+	for _, printError := range b.GetPrintErrors() {
+		if printers == nil || printers[printError.PrinterName] {
+			stats.ProcessingErrors++
+		}
+	}
+	var since time.Time
+	if days > 0 {
+		since = now.AddDate(0, 0, -days)
+		stats.Since = &since
+	}
+
+	var entries []PrintHistory
+	failedPrints := make(map[historyPrint]bool)
+	ratedPrints := make(map[historyPrint]bool)
+	for _, entry := range history {
+		if entry.PrintFinished.Before(since) || (printers != nil && !printers[entry.PrinterName]) {
+			continue
+		}
+		if entry.Reverted {
+			stats.RevertedEntries++
+			continue
+		}
+		entries = append(entries, entry)
+		if print := entryPrint(entry); print != nil {
+			if _, seen := failedPrints[*print]; !seen {
+				failedPrints[*print] = false
+			}
+			if entry.Rating > 0 {
+				ratedPrints[*print] = true
+				if entry.Rating <= FailedPrintRating {
+					failedPrints[*print] = true
+				}
+			}
+		}
+	}
+	stats.Prints = len(failedPrints)
+	stats.RatedPrints = len(ratedPrints)
+	for _, failed := range failedPrints {
+		if failed {
+			stats.FailedPrints++
+		}
+	}
+
+	spools := b.historySpools(entries)
+
+	byPrinter, byMaterial, bySpool := newUsageTally(), newUsageTally(), newUsageTally()
+	byWeek, byMonth, byFilament := newUsageTally(), newUsageTally(), newUsageTally()
+	spoolTotals := make(map[int]*SpoolUsageTotal)
+	filamentTotals := make(map[string]*FilamentUsageTotal)
+	filamentSpools := make(map[string]map[int]bool)
+	for _, entry := range entries {
+		stats.TotalUsed += entry.FilamentUsed
+		stats.TotalWaste += entry.Waste
+		if entry.Cost != nil {
+			stats.TotalCost += *entry.Cost
+		}
+		stats.Entries++
+
+		print := entryPrint(entry)
+		failed := print != nil && failedPrints[*print]
+		spool, known := spools.spoolOf(entry)
+
+		printer := entry.PrinterName
+		if printer == "" {
+			printer = "No printer" // Manually logged usage
+		}
+		byPrinter.add(printer, entry, print, failed)
+
+		material := "Unknown"
+		if known && spoolMaterial(spool) != "" {
+			material = spoolMaterial(spool)
+		}
+		byMaterial.add(material, entry, print, failed)
+
+		finished := entry.PrintFinished.Local()
+		year, week := finished.ISOWeek()
+		byWeek.add(fmt.Sprintf("%d-W%02d", year, week), entry, print, failed)
+		byMonth.add(finished.Format("2006-01"), entry, print, failed)
+
+		spoolKey := strconv.Itoa(entry.SpoolID)
+		bySpool.add(spoolKey, entry, print, failed)
+		spoolTotal, exists := spoolTotals[entry.SpoolID]
+		if !exists {
+			spoolTotal = &SpoolUsageTotal{SpoolID: entry.SpoolID, Name: fmt.Sprintf("Spool #%d", entry.SpoolID), Material: material}
+			if known {
+				spoolTotal.Name = reportSpoolName(spool)
+				if spool.Filament != nil {
+					spoolTotal.ColorHex = spool.Filament.ColorHex
+				}
+			}
+			spoolTotals[entry.SpoolID] = spoolTotal
+		}
+		if entry.PrintFinished.After(spoolTotal.LastUsed) {
+			spoolTotal.LastUsed = entry.PrintFinished
+		}
+
+		// Spools Spoolman no longer knows can't be grouped by filament
+		if !known || spool.Filament == nil {
+			continue
+		}
+		filamentKey := strconv.Itoa(spool.Filament.ID)
+		byFilament.add(filamentKey, entry, print, failed)
+		if _, exists := filamentTotals[filamentKey]; !exists {
+			filamentTotals[filamentKey] = &FilamentUsageTotal{
+				FilamentID: spool.Filament.ID,
+				Name:       filamentDisplayName(spool.Filament),
+				Material:   material,
+				ColorHex:   spool.Filament.ColorHex,
+			}
+			filamentSpools[filamentKey] = make(map[int]bool)
+		}
+		filamentSpools[filamentKey][entry.SpoolID] = true
+	}
+
+	stats.ByPrinter = byPrinter.byUsage()
+	stats.ByMaterial = byMaterial.byUsage()
+	stats.ByWeek = byWeek.list()
+	stats.ByMonth = byMonth.list()
+
+	stats.BySpool = []SpoolUsageTotal{}
+	for _, total := range bySpool.byUsage() {
+		spoolID, _ := strconv.Atoi(total.Key)
+		spoolTotal := spoolTotals[spoolID]
+		spoolTotal.UsageTotal = total
+		stats.BySpool = append(stats.BySpool, *spoolTotal)
+	}
+
+	stats.TopFilaments = []FilamentUsageTotal{}
+	for _, total := range byFilament.byUsage() {
+		if len(stats.TopFilaments) == StatsTopFilamentLimit {
+			break
+		}
+		filamentTotal := filamentTotals[total.Key]
+		filamentTotal.UsageTotal = total
+		filamentTotal.Spools = len(filamentSpools[total.Key])
+		stats.TopFilaments = append(stats.TopFilaments, *filamentTotal)
+	}
+
+	return stats, nil
+}
+
+// entryPrint returns the print a history entry belongs to, or nil for manually logged usage
+func entryPrint(entry PrintHistory) *historyPrint {
+	if entry.Source == HistorySourceManual {
+		return nil
+	}
+	return &historyPrint{printerName: entry.PrinterName, jobName: entry.JobName, finished: entry.PrintFinished}
+}
+
+// historySpoolSet holds the spools history entries used, from the Spoolman instance of each
+// entry's printer
+type historySpoolSet struct {
+	snapshot *Config
+	spools   map[instanceSpool]SpoolmanSpool
+}
+
+// spoolOf returns the spool a history entry used, if it was found
+func (s historySpoolSet) spoolOf(entry PrintHistory) (SpoolmanSpool, bool) {
+	spool, known := s.spools[instanceSpool{printerSpoolmanInstance(s.snapshot, entry.PrinterName), entry.SpoolID}]
+	return spool, known
+}
+
+// historySpools looks up the spools history entries used. Used-up spools are usually archived
+// and left out of the spool list, so those are fetched one by one.
+func (b *FilamentBridge) historySpools(entries []PrintHistory) historySpoolSet {
+	set := historySpoolSet{snapshot: b.GetConfigSnapshot(), spools: make(map[instanceSpool]SpoolmanSpool)}
+
+	used := make(map[string]map[int]bool)
+	for _, entry := range entries {
+		if entry.SpoolID == 0 {
+			continue
+		}
+		instance := printerSpoolmanInstance(set.snapshot, entry.PrinterName)
+		if used[instance] == nil {
+			used[instance] = make(map[int]bool)
+		}
+		used[instance][entry.SpoolID] = true
+	}
+
+	for instance, spoolIDs := range used {
+		spoolman := b.spoolmanInstance(instance)
+		active, err := spoolman.GetAllSpools()
+		if err != nil {
+			// Totals don't need Spoolman; materials and names just show as unknown
+			webLog.Warn("Failed to get spools for statistics", "spoolman_instance", instance, "error", err)
+			continue
+		}
+		for _, spool := range active {
+			set.spools[instanceSpool{instance, spool.ID}] = spool
+		}
+
+		for spoolID := range spoolIDs {
+			if _, known := set.spools[instanceSpool{instance, spoolID}]; known {
+				continue
+			}
+			spool, err := spoolman.GetSpool(spoolID)
+			if err != nil {
+				webLog.Debug("Spool from print history not found in Spoolman", "spoolman_instance", instance, "spool_id", spoolID, "error", err)
+				continue
+			}
+			set.spools[instanceSpool{instance, spoolID}] = *spool
+		}
+	}
+	return set
+}
+
+// spoolMaterial returns a spool's material, whichever of the spool and its filament has it
+func spoolMaterial(spool SpoolmanSpool) string {
+	if spool.Material != "" {
+		return spool.Material
+	}
+	if spool.Filament != nil {
+		return spool.Filament.Material
+	}
+	return ""
+}
+
+// filamentDisplayName names a filament by vendor, name and material
+func filamentDisplayName(filament *SpoolmanFilament) string {
+	parts := []string{}
+	if filament.Vendor != nil && filament.Vendor.Name != "" {
+		parts = append(parts, filament.Vendor.Name)
+	}
+	if filament.Name != "" {
+		parts = append(parts, filament.Name)
+	}
+	if filament.Material != "" {
+		parts = append(parts, filament.Material)
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Filament #%d", filament.ID)
+	}
+	return strings.Join(parts, " ")
+}
+
+// statsHandler returns usage statistics over ?days=, or all history when it's 0 or omitted
+func (ws *WebServer) statsHandler(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "0"))
+	if err != nil || days < 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "days must be 0 or a positive number")
+		return
+	}
+
+	stats, err := ws.bridge.ComputeUsageStats(days, time.Now())
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
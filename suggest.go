@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultSpoolSuggestionLimit is how many spools are suggested per toolhead
+const DefaultSpoolSuggestionLimit = 5
+
+// SpoolSuggestion is a spool ranked for one of a job's toolheads
+type SpoolSuggestion struct {
+	Spool           SpoolmanSpool `json:"spool"`
+	MaterialMatch   bool          `json:"material_match"`
+	EnoughFilament  bool          `json:"enough_filament"`
+	ColorDistance   *float64      `json:"color_distance,omitempty"` // 0 (same) to √3; omitted when either color is unknown
+	CurrentlyMapped bool          `json:"currently_mapped"`
+}
+
+// ToolheadSuggestions is what a job needs on a toolhead and the spools that fit it best
+type ToolheadSuggestions struct {
+	ToolheadID     int               `json:"toolhead_id"`
+	RequiredWeight float64           `json:"required_weight"` // Grams, 0 when the file doesn't say
+	Material       string            `json:"material,omitempty"`
+	Color          string            `json:"color,omitempty"`
+	Suggestions    []SpoolSuggestion `json:"suggestions"`
+}
+
+// findPrinterConfig looks a printer up by ID, then by name
+func (b *FilamentBridge) findPrinterConfig(printer string) (string, PrinterConfig, bool) {
+	snapshot := b.GetConfigSnapshot()
+	if config, exists := snapshot.Printers[printer]; exists {
+		return printer, config, true
+	}
+	for printerID, config := range snapshot.Printers {
+		if strings.EqualFold(resolvePrinterName(config), printer) {
+			return printerID, config, true
+		}
+	}
+	return "", PrinterConfig{}, false
+}
+
+// SuggestSpools reads a file on the printer and ranks the spools that could be loaded for
+// each toolhead it prints with: matching material first, then enough filament left, then the
+// closest color. Among equals, the emptier spool comes first so partial spools get used up.
+// Spools mapped to other toolheads aren't suggested.
+func (b *FilamentBridge) SuggestSpools(printer, filename string, limit int) ([]ToolheadSuggestions, error) {
+	printerID, config, exists := b.findPrinterConfig(printer)
+	if !exists {
+		return nil, newCodedError(ErrCodePrinterNotFound, "printer %s not found", printer)
+	}
+	if config.IPAddress == "" {
+		return nil, newCodedError(ErrCodeInvalidRequest, "printer %s has no PrusaLink address to read files from", printerID)
+	}
+	printerName := resolvePrinterName(config)
+
+	snapshot := b.GetConfigSnapshot()
+	client := config.prusaLinkClient(snapshot)
+	gcodeContent, err := client.GetGcodeFile(strings.TrimPrefix(filename, "/"))
+	if err != nil {
+		return nil, newCodedError(ErrCodePrinterError, "failed to download %s: %v", filename, err)
+	}
+	required := b.jobFilamentUsage(config, filename, gcodeContent)
+	sliced := ParseGcodeFilaments(gcodeContent)
+
+	spools, err := b.spoolmanFor(printerName).GetAllSpools()
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spools: %v", err)
+	}
+	mappedTo, mappedHere, err := b.spoolPlacements(printerName)
+	if err != nil {
+		return nil, err
+	}
+	b.annotateSpoolReservations(printerName, spools, filename)
+
+	toolheadIDs := jobToolheads(config, required)
+	if len(required) == 0 && config.SlotCount() > 1 && len(sliced.Types) > 0 {
+		// No usage data, but the sliced materials still show which toolheads are used
+		toolheadIDs = toolheadIDs[:0]
+		for toolheadID := range sliced.Types {
+			if toolheadID < config.SlotCount() {
+				toolheadIDs = append(toolheadIDs, toolheadID)
+			}
+		}
+		sort.Ints(toolheadIDs)
+	}
+
+	results := make([]ToolheadSuggestions, 0, len(toolheadIDs))
+	for _, toolheadID := range toolheadIDs {
+		result := ToolheadSuggestions{
+			ToolheadID:     toolheadID,
+			RequiredWeight: required[toolheadID],
+			Material:       sliced.Types[toolheadID],
+			Color:          sliced.Colors[toolheadID],
+		}
+		rankSpools(&result, spools, mappedTo, mappedHere[toolheadID], limit)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// spoolPlacements returns the printer each mapped spool is on and the spool on each of a
+// printer's toolheads, so a spool is only suggested for the toolhead it's already on
+func (b *FilamentBridge) spoolPlacements(printerName string) (map[int]string, map[int]int, error) {
+	allMappings, err := b.GetAllToolheadMappings()
+	if err != nil {
+		return nil, nil, err
+	}
+	instance := b.spoolmanInstanceOf(printerName)
+	mappedTo := make(map[int]string)
+	mappedHere := make(map[int]int)
+	for mappedPrinter, printerMappings := range allMappings {
+		// The same spool ID in another Spoolman instance is another spool
+		if b.spoolmanInstanceOf(mappedPrinter) != instance {
+			continue
+		}
+		for toolheadID, mapping := range printerMappings {
+			if mapping.SpoolID == 0 {
+				continue
+			}
+			mappedTo[mapping.SpoolID] = mapping.PrinterName
+			if mapping.PrinterName == printerName {
+				mappedHere[toolheadID] = mapping.SpoolID
+			}
+		}
+	}
+	return mappedTo, mappedHere, nil
+}
+
+// rankSpools fills a toolhead's suggestions from spools that aren't archived or mapped
+// elsewhere, best first, keeping at most limit
+func rankSpools(result *ToolheadSuggestions, spools []SpoolmanSpool, mappedTo map[int]string, currentSpoolID, limit int) {
+	result.Suggestions = []SpoolSuggestion{}
+	for _, spool := range spools {
+		currentlyMapped := currentSpoolID == spool.ID
+		if spool.Archived || (mappedTo[spool.ID] != "" && !currentlyMapped) {
+			continue
+		}
+
+		suggestion := SpoolSuggestion{
+			Spool:           spool,
+			MaterialMatch:   result.Material == "" || normalizeMaterial(result.Material) == normalizeMaterial(spool.Material),
+			EnoughFilament:  spool.RemainingWeight-spool.ReservedWeight >= result.RequiredWeight,
+			CurrentlyMapped: currentlyMapped,
+		}
+		if spool.Filament != nil {
+			if distance, ok := colorDistance(result.Color, spool.Filament.ColorHex); ok {
+				suggestion.ColorDistance = &distance
+			}
+		}
+		result.Suggestions = append(result.Suggestions, suggestion)
+	}
+
+	sort.SliceStable(result.Suggestions, func(i, j int) bool {
+		a, b := result.Suggestions[i], result.Suggestions[j]
+		if a.MaterialMatch != b.MaterialMatch {
+			return a.MaterialMatch
+		}
+		if a.EnoughFilament != b.EnoughFilament {
+			return a.EnoughFilament
+		}
+		if da, db := suggestionColorDistance(a), suggestionColorDistance(b); da != db {
+			return da < db
+		}
+		if a.CurrentlyMapped != b.CurrentlyMapped {
+			return a.CurrentlyMapped
+		}
+		return a.Spool.RemainingWeight < b.Spool.RemainingWeight
+	})
+	if len(result.Suggestions) > limit {
+		result.Suggestions = result.Suggestions[:limit]
+	}
+}
+
+// suggestionColorDistance ranks unknown colors after every known one
+func suggestionColorDistance(suggestion SpoolSuggestion) float64 {
+	if suggestion.ColorDistance == nil {
+		return 2 // More than the √3 maximum
+	}
+	return *suggestion.ColorDistance
+}
+
+// suggestSpoolsHandler ranks spools for each toolhead of an upcoming job
+func (ws *WebServer) suggestSpoolsHandler(c *gin.Context) {
+	printer := c.Query("printer")
+	filename := strings.TrimSpace(c.Query("file"))
+	if printer == "" || filename == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "printer and file parameters are required")
+		return
+	}
+
+	limit := DefaultSpoolSuggestionLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+
+	toolheads, err := ws.bridge.SuggestSpools(printer, filename, limit)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolSuggestionsResponse{File: filename, Toolheads: toolheads})
+}
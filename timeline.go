@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spool event types of assignments, moves and scans
+const (
+	SpoolEventAssigned   = "assigned"   // Mapped to a printer toolhead
+	SpoolEventUnassigned = "unassigned" // Taken off a printer toolhead
+	SpoolEventMoved      = "moved"      // Moved to a storage location through FilaBridge
+	SpoolEventNFCScan    = "nfc_scan"   // Its NFC tag or label was scanned
+)
+
+// Spool timeline entry types besides the spool event types
+const (
+	SpoolTimelineRegistered = "registered" // Added to Spoolman
+	SpoolTimelinePrint      = "print"      // Usage charged from the print history
+	SpoolTimelineCorrection = "correction" // A print history entry was reverted, adjusted or reassigned
+	SpoolTimelineWeighing   = "weighing"   // The spool was weighed
+)
+
+// SpoolTimelineEntry is one thing that happened to a spool
+type SpoolTimelineEntry struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`    // A spool event type or one of the timeline types
+	Summary     string    `json:"summary"` // One line describing what happened
+	Location    string    `json:"location,omitempty"`
+	PrinterName string    `json:"printer_name,omitempty"`
+	ToolheadID  *int      `json:"toolhead_id,omitempty"`
+	Weight      float64   `json:"weight,omitempty"` // Grams used, moved or weighed; for corrections the change charged to the spool
+	JobName     string    `json:"job_name,omitempty"`
+	Member      string    `json:"member,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+	HistoryID   int       `json:"history_id,omitempty"` // Print history entry of prints and corrections
+}
+
+// logSpoolEvents records entries in the spool event log. Failures are only logged, so the
+// log never fails the change it records.
+func (b *FilamentBridge) logSpoolEvents(events ...SpoolEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if err := b.recordSpoolEvents(events); err != nil {
+		bridgeLog.Warn("Failed to record spool events", "spool_id", events[0].SpoolID, "event_type", events[0].EventType, "error", err)
+	}
+}
+
+// toolheadLocationName returns the "Printer - Toolhead" location name of a printer toolhead
+func (b *FilamentBridge) toolheadLocationName(printerName string, toolheadID int) string {
+	if printerID, _, exists := b.findPrinterConfig(printerName); exists {
+		if name, err := b.GetToolheadName(printerID, toolheadID); err == nil {
+			return fmt.Sprintf("%s - %s", printerName, name)
+		}
+	}
+	return fmt.Sprintf("%s - Toolhead %d", printerName, toolheadID)
+}
+
+// mappingEvents returns the events of toolheads of a printer changing spools: the new spools
+// were assigned and the previous ones, unless moved to another of the toolheads, unassigned.
+// assignments and previous map toolheads to their new and previous spools, 0 for none.
+func (b *FilamentBridge) mappingEvents(printerName string, assignments, previous map[int]int, member string) []SpoolEvent {
+	now := time.Now()
+	instance := b.spoolmanInstanceOf(printerName)
+	remapped := make(map[int]bool)
+	for _, spoolID := range assignments {
+		remapped[spoolID] = true
+	}
+
+	var events []SpoolEvent
+	for toolheadID, spoolID := range assignments {
+		previousSpoolID := previous[toolheadID]
+		if spoolID == previousSpoolID {
+			continue
+		}
+		location := b.toolheadLocationName(printerName, toolheadID)
+		if spoolID > 0 {
+			events = append(events, SpoolEvent{SpoolmanInstance: instance, SpoolID: spoolID, EventType: SpoolEventAssigned,
+				RelatedSpoolID: previousSpoolID, Location: location, Member: member, CreatedAt: now})
+		}
+		if previousSpoolID > 0 && !remapped[previousSpoolID] {
+			events = append(events, SpoolEvent{SpoolmanInstance: instance, SpoolID: previousSpoolID, EventType: SpoolEventUnassigned,
+				RelatedSpoolID: spoolID, Location: location, Member: member, CreatedAt: now})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].SpoolID < events[j].SpoolID })
+	return events
+}
+
+// GetSpoolTimeline returns everything FilaBridge knows happened to a spool, newest first:
+// when it was added to Spoolman, its assignments and moves, the prints it was used for,
+// corrections to them, weighings, NFC scans, transfers, refills, runouts and dryings. The
+// spool is in the printer's Spoolman instance, the main one when printerName is empty, and
+// only what happened to that instance's spool is included.
+func (b *FilamentBridge) GetSpoolTimeline(printerName string, spoolID int) ([]SpoolTimelineEntry, error) {
+	timeline := []SpoolTimelineEntry{}
+	snapshot := b.GetConfigSnapshot()
+	instance := printerSpoolmanInstance(snapshot, printerName)
+	inInstance := func(printerName string) bool {
+		return printerSpoolmanInstance(snapshot, printerName) == instance
+	}
+
+	// The rest of the timeline is FilaBridge's own, so it's still returned without Spoolman
+	if spool, err := b.spoolmanInstance(instance).GetSpool(spoolID); err != nil {
+		spoolmanLog.Warn("Failed to get spool for timeline", "spool_id", spoolID, "error", err)
+	} else if registered, err := time.Parse(time.RFC3339, spool.Registered); err == nil {
+		timeline = append(timeline, SpoolTimelineEntry{Time: registered, Type: SpoolTimelineRegistered, Summary: "Added to Spoolman"})
+	}
+
+	events, err := b.GetSpoolEvents(instance, spoolID)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		timeline = append(timeline, SpoolTimelineEntry{
+			Time:     event.CreatedAt,
+			Type:     event.EventType,
+			Summary:  spoolEventSummary(event),
+			Location: event.Location,
+			Weight:   event.Weight,
+			Member:   event.Member,
+			Notes:    event.Notes,
+		})
+	}
+
+	history, err := b.GetPrintHistory(PrintHistoryFilter{SpoolID: spoolID})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range history {
+		if !inInstance(entry.PrinterName) {
+			continue
+		}
+		toolheadID := entry.ToolheadID
+		summary := fmt.Sprintf("Printed %s on %s, using %.1fg", displayFilename(entry.JobName, entry.JobDisplayName), entry.PrinterName, entry.FilamentUsed)
+		if entry.Source == HistorySourceManual {
+			summary = fmt.Sprintf("Logged %.1fg of manual usage on %s", entry.FilamentUsed, entry.PrinterName)
+		}
+		if entry.Reverted {
+			summary += " (reverted)"
+		}
+		timeline = append(timeline, SpoolTimelineEntry{
+			Time:        entry.PrintFinished,
+			Type:        SpoolTimelinePrint,
+			Summary:     summary,
+			Location:    b.toolheadLocationName(entry.PrinterName, entry.ToolheadID),
+			PrinterName: entry.PrinterName,
+			ToolheadID:  &toolheadID,
+			Weight:      entry.FilamentUsed,
+			JobName:     entry.JobName,
+			Member:      entry.Member,
+			Notes:       entry.Notes,
+			HistoryID:   entry.ID,
+		})
+	}
+
+	corrections, err := b.spoolHistoryCorrections(spoolID)
+	if err != nil {
+		return nil, err
+	}
+	for _, correction := range corrections {
+		if !inInstance(correction.printerName) {
+			continue
+		}
+		timeline = append(timeline, SpoolTimelineEntry{
+			Time:      correction.CreatedAt,
+			Type:      SpoolTimelineCorrection,
+			Summary:   historyCorrectionSummary(correction.HistoryCorrection, spoolID),
+			Weight:    historyCorrectionWeight(correction.HistoryCorrection, spoolID),
+			Notes:     correction.Reason,
+			HistoryID: correction.HistoryID,
+		})
+	}
+
+	readings, err := b.GetScaleReadings(spoolID, "", -1) // SQLite takes a negative limit as none
+	if err != nil {
+		return nil, err
+	}
+	for _, reading := range readings {
+		if !inInstance(reading.PrinterName) {
+			continue
+		}
+		summary := fmt.Sprintf("Weighed %.1fg, %s", reading.MeasuredWeight, reading.Status)
+		if reading.Scale != "" {
+			summary = fmt.Sprintf("Weighed %.1fg on %s, %s", reading.MeasuredWeight, reading.Scale, reading.Status)
+		}
+		timeline = append(timeline, SpoolTimelineEntry{
+			Time:        reading.CreatedAt,
+			Type:        SpoolTimelineWeighing,
+			Summary:     summary,
+			PrinterName: reading.PrinterName,
+			Weight:      reading.MeasuredWeight,
+		})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.After(timeline[j].Time) })
+	return timeline, nil
+}
+
+// spoolHistoryCorrection is a correction with the printer of the entry it corrected, which
+// tells the Spoolman instance of its spools
+type spoolHistoryCorrection struct {
+	HistoryCorrection
+	printerName string
+}
+
+// spoolHistoryCorrections returns the corrections to print history entries that charged a spool
+// or were moved onto it, oldest first
+func (b *FilamentBridge) spoolHistoryCorrections(spoolID int) ([]spoolHistoryCorrection, error) {
+	rows, err := b.db.Query(
+		`SELECT c.id, c.history_id, c.action, COALESCE(c.old_spool_id, 0), COALESCE(c.new_spool_id, 0),
+			COALESCE(c.old_filament_used, 0), COALESCE(c.new_filament_used, 0), COALESCE(c.reason, ''), c.created_at,
+			COALESCE(h.printer_name, '')
+		FROM history_corrections c LEFT JOIN print_history h ON h.id = c.history_id
+		WHERE c.old_spool_id = ? OR c.new_spool_id = ? ORDER BY c.id`,
+		spoolID, spoolID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history corrections: %w", err)
+	}
+	defer rows.Close()
+
+	corrections := []spoolHistoryCorrection{}
+	for rows.Next() {
+		var correction spoolHistoryCorrection
+		if err := rows.Scan(&correction.ID, &correction.HistoryID, &correction.Action, &correction.OldSpoolID, &correction.NewSpoolID,
+			&correction.OldFilamentUsed, &correction.NewFilamentUsed, &correction.Reason, &correction.CreatedAt, &correction.printerName); err != nil {
+			return nil, fmt.Errorf("failed to scan history correction: %w", err)
+		}
+		corrections = append(corrections, correction)
+	}
+	return corrections, rows.Err()
+}
+
+// spoolEventSummary describes a spool event in one line
+func spoolEventSummary(event SpoolEvent) string {
+	switch event.EventType {
+	case SpoolEventAssigned:
+		return fmt.Sprintf("Loaded on %s", event.Location)
+	case SpoolEventUnassigned:
+		return fmt.Sprintf("Taken off %s", event.Location)
+	case SpoolEventMoved:
+		return fmt.Sprintf("Moved to %s", event.Location)
+	case SpoolEventNFCScan:
+		return "NFC tag scanned"
+	case SpoolEventTransferOut:
+		return fmt.Sprintf("Moved %.1fg onto spool %d", event.Weight, event.RelatedSpoolID)
+	case SpoolEventTransferIn:
+		return fmt.Sprintf("Received %.1fg from spool %d", event.Weight, event.RelatedSpoolID)
+	case SpoolEventRefill:
+		return fmt.Sprintf("Refilled, replaced by or replacing spool %d", event.RelatedSpoolID)
+	case SpoolEventRunout:
+		return "Ran out of filament"
+	case SpoolEventEmptied:
+		return "Emptied"
+	case SpoolEventDried:
+		return "Dried"
+	}
+	return event.EventType
+}
+
+// historyCorrectionSummary describes a correction to a print history entry, from the side of
+// the spool the timeline is for
+func historyCorrectionSummary(correction HistoryCorrection, spoolID int) string {
+	switch correction.Action {
+	case HistoryCorrectionRevert:
+		return fmt.Sprintf("Reverted %.1fg of print history entry %d", correction.OldFilamentUsed, correction.HistoryID)
+	case HistoryCorrectionAdjust:
+		return fmt.Sprintf("Adjusted print history entry %d from %.1fg to %.1fg", correction.HistoryID, correction.OldFilamentUsed, correction.NewFilamentUsed)
+	case HistoryCorrectionReassign:
+		if correction.OldSpoolID == spoolID {
+			return fmt.Sprintf("Print history entry %d (%.1fg) reassigned to spool %d", correction.HistoryID, correction.OldFilamentUsed, correction.NewSpoolID)
+		}
+		return fmt.Sprintf("Print history entry %d (%.1fg) reassigned from spool %d", correction.HistoryID, correction.NewFilamentUsed, correction.OldSpoolID)
+	}
+	return fmt.Sprintf("Corrected print history entry %d", correction.HistoryID)
+}
+
+// historyCorrectionWeight returns how many grams a correction charged to the spool the
+// timeline is for, negative when it took usage off
+func historyCorrectionWeight(correction HistoryCorrection, spoolID int) float64 {
+	switch {
+	case correction.Action == HistoryCorrectionRevert:
+		return -correction.OldFilamentUsed
+	case correction.Action == HistoryCorrectionReassign && correction.OldSpoolID == spoolID:
+		return -correction.OldFilamentUsed
+	case correction.Action == HistoryCorrectionReassign:
+		return correction.NewFilamentUsed
+	}
+	return correction.NewFilamentUsed - correction.OldFilamentUsed
+}
+
+// getSpoolTimelineHandler returns everything that happened to a spool, newest first
+func (ws *WebServer) getSpoolTimelineHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	timeline, err := ws.bridge.GetSpoolTimeline(c.Query("printer_name"), spoolID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolTimelineResponse{SpoolID: spoolID, Timeline: timeline})
+}
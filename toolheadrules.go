@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Toolhead rule check modes
+const (
+	ToolheadRuleCheckOff    = "off"
+	ToolheadRuleCheckWarn   = "warn"
+	ToolheadRuleCheckReject = "reject" // Reject mappings and pause prints that break a rule
+)
+
+// Nozzle types of a toolhead rule
+const (
+	NozzleTypeBrass    = "brass"    // Worn down by abrasive filaments
+	NozzleTypeHardened = "hardened" // Hardened steel, ruby or similar, fine for abrasive filaments
+)
+
+// abrasiveMaterialRegex matches carbon and glass fiber filled materials such as "PA-CF",
+// "PETG CF" or "PA6-GF30"
+var abrasiveMaterialRegex = regexp.MustCompile(`(?i)(^|[^A-Z])(CF|GF)\d*([^A-Z]|$)`)
+
+// ToolheadRule limits what may be loaded on and printed with a toolhead. Empty settings
+// don't limit anything.
+type ToolheadRule struct {
+	ToolheadID       int      `json:"toolhead_id"`
+	AllowedMaterials []string `json:"allowed_materials,omitempty"` // Any material when empty
+	NozzleType       string   `json:"nozzle_type,omitempty"`       // brass or hardened; abrasive filaments need hardened
+	NozzleDiameter   float64  `json:"nozzle_diameter,omitempty"`   // mm; jobs sliced for another size are reported
+}
+
+// empty reports whether a rule doesn't limit anything
+func (r ToolheadRule) empty() bool {
+	return len(r.AllowedMaterials) == 0 && r.NozzleType == "" && r.NozzleDiameter == 0
+}
+
+// isAbrasiveMaterial reports whether a material is fiber filled and wears a brass nozzle
+func isAbrasiveMaterial(material string) bool {
+	return abrasiveMaterialRegex.MatchString(material)
+}
+
+// spoolRuleProblems lists how loading a spool on a toolhead breaks the toolhead's rule
+func spoolRuleProblems(toolheadID int, rule ToolheadRule, spool SpoolmanSpool) []string {
+	material := spoolMaterial(spool)
+	if material == "" {
+		return nil
+	}
+
+	var problems []string
+	if len(rule.AllowedMaterials) > 0 {
+		allowed := false
+		for _, candidate := range rule.AllowedMaterials {
+			if normalizeMaterial(candidate) == normalizeMaterial(material) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			problems = append(problems, fmt.Sprintf("toolhead %d only allows %s but spool %d is %s",
+				toolheadID, strings.Join(rule.AllowedMaterials, ", "), spool.ID, material))
+		}
+	}
+	if rule.NozzleType == NozzleTypeBrass && isAbrasiveMaterial(material) {
+		problems = append(problems, fmt.Sprintf("toolhead %d has a brass nozzle but spool %d is abrasive %s, which needs a hardened one",
+			toolheadID, spool.ID, material))
+	}
+	return problems
+}
+
+// nozzleRuleProblems reports a job sliced for another nozzle size than a toolhead's rule has
+func nozzleRuleProblems(toolheadID int, rule ToolheadRule, sliced GcodeFilaments) []string {
+	slicedDiameter, exists := sliced.NozzleDiameters[toolheadID]
+	if rule.NozzleDiameter == 0 || !exists || math.Abs(slicedDiameter-rule.NozzleDiameter) < 0.001 {
+		return nil
+	}
+	return []string{fmt.Sprintf("toolhead %d has a %gmm nozzle but the job was sliced for %gmm", toolheadID, rule.NozzleDiameter, slicedDiameter)}
+}
+
+// GetToolheadRules returns a printer's toolhead rules by toolhead
+func (b *FilamentBridge) GetToolheadRules(printerID string) (map[int]ToolheadRule, error) {
+	rows, err := b.db.Query(
+		"SELECT toolhead_id, COALESCE(allowed_materials, ''), COALESCE(nozzle_type, ''), COALESCE(nozzle_diameter, 0) FROM toolhead_rules WHERE printer_id = ?",
+		printerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get toolhead rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make(map[int]ToolheadRule)
+	for rows.Next() {
+		var rule ToolheadRule
+		var materials string
+		if err := rows.Scan(&rule.ToolheadID, &materials, &rule.NozzleType, &rule.NozzleDiameter); err != nil {
+			return nil, fmt.Errorf("failed to scan toolhead rule: %w", err)
+		}
+		if materials != "" {
+			rule.AllowedMaterials = strings.Split(materials, ",")
+		}
+		rules[rule.ToolheadID] = rule
+	}
+	return rules, rows.Err()
+}
+
+// SetToolheadRules replaces a printer's toolhead rules. Rules that don't limit anything are
+// dropped.
+func (b *FilamentBridge) SetToolheadRules(printerID string, rules []ToolheadRule) error {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil {
+		return newCodedError(ErrCodePrinterNotFound, "printer %s not found", printerID)
+	}
+	config, exists := snapshot.Printers[printerID]
+	if !exists {
+		return newCodedError(ErrCodePrinterNotFound, "printer %s not found", printerID)
+	}
+
+	var problems []string
+	seen := make(map[int]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.ToolheadID < 0 || rule.ToolheadID >= config.SlotCount() {
+			problems = append(problems, fmt.Sprintf("%s has no toolhead %d", config.Name, rule.ToolheadID))
+		} else if seen[rule.ToolheadID] {
+			problems = append(problems, fmt.Sprintf("toolhead %d is listed more than once", rule.ToolheadID))
+		}
+		seen[rule.ToolheadID] = true
+
+		rule.NozzleType = strings.ToLower(strings.TrimSpace(rule.NozzleType))
+		if rule.NozzleType != "" && rule.NozzleType != NozzleTypeBrass && rule.NozzleType != NozzleTypeHardened {
+			problems = append(problems, fmt.Sprintf("unknown nozzle type %q for toolhead %d (use brass, hardened or leave empty)", rule.NozzleType, rule.ToolheadID))
+		}
+		if rule.NozzleDiameter < 0 || rule.NozzleDiameter > 2 {
+			problems = append(problems, fmt.Sprintf("nozzle diameter for toolhead %d must be between 0 and 2mm", rule.ToolheadID))
+		}
+
+		materials := make([]string, 0, len(rule.AllowedMaterials))
+		for _, material := range rule.AllowedMaterials {
+			material = strings.TrimSpace(material)
+			if strings.Contains(material, ",") {
+				problems = append(problems, fmt.Sprintf("material %q for toolhead %d can't contain a comma", material, rule.ToolheadID))
+			} else if material != "" {
+				materials = append(materials, material)
+			}
+		}
+		rule.AllowedMaterials = materials
+	}
+	if len(problems) > 0 {
+		return newCodedError(ErrCodeInvalidRequest, "%s", strings.Join(problems, "; "))
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin toolhead rule update: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM toolhead_rules WHERE printer_id = ?", printerID); err != nil {
+		return fmt.Errorf("failed to clear toolhead rules: %w", err)
+	}
+	for _, rule := range rules {
+		if rule.empty() {
+			continue
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO toolhead_rules (printer_id, toolhead_id, allowed_materials, nozzle_type, nozzle_diameter) VALUES (?, ?, ?, ?, ?)",
+			printerID, rule.ToolheadID, strings.Join(rule.AllowedMaterials, ","), rule.NozzleType, rule.NozzleDiameter,
+		); err != nil {
+			return fmt.Errorf("failed to save toolhead %d rule: %w", rule.ToolheadID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit toolhead rules: %w", err)
+	}
+	return nil
+}
+
+// activeToolheadRules returns the rules of a printer to check, or nil when the toolhead rule
+// check is off or the printer has none
+func (b *FilamentBridge) activeToolheadRules(printerName string) map[int]ToolheadRule {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil || snapshot.ToolheadRuleCheck == ToolheadRuleCheckOff {
+		return nil
+	}
+	printerID, _, exists := b.findPrinterConfig(printerName)
+	if !exists {
+		return nil
+	}
+	rules, err := b.GetToolheadRules(printerID)
+	if err != nil {
+		bridgeLog.Warn("Failed to get toolhead rules", "printer", printerName, "error", err)
+		return nil
+	}
+	return rules
+}
+
+// checkToolheadRules checks spools about to be mapped to a printer's toolheads against their
+// rules. With the check set to reject a broken rule is an error; with warn the problems are
+// returned to pass on as warnings.
+func (b *FilamentBridge) checkToolheadRules(printer string, assignments []ToolheadAssignment) ([]string, error) {
+	rules := b.activeToolheadRules(printer)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var problems []string
+	for _, assignment := range assignments {
+		rule, exists := rules[assignment.ToolheadID]
+		if !exists || assignment.SpoolID == 0 || (len(rule.AllowedMaterials) == 0 && rule.NozzleType == "") {
+			continue
+		}
+		// A spool Spoolman doesn't know is reported by the mapping itself
+		spool, err := b.spoolmanFor(printer).GetSpool(assignment.SpoolID)
+		if err != nil {
+			continue
+		}
+		problems = append(problems, spoolRuleProblems(assignment.ToolheadID, rule, *spool)...)
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	if b.GetConfigSnapshot().ToolheadRuleCheck == ToolheadRuleCheckReject {
+		return nil, newCodedError(ErrCodeIncompatibleSpool, "%s", strings.Join(problems, "; "))
+	}
+	return problems, nil
+}
+
+// getToolheadRulesHandler returns a printer's toolhead rules
+func (ws *WebServer) getToolheadRulesHandler(c *gin.Context) {
+	printerID := c.Param("id")
+	if _, exists := ws.bridge.GetConfigSnapshot().Printers[printerID]; !exists {
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
+		return
+	}
+
+	rules, err := ws.bridge.GetToolheadRules(printerID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	list := make([]ToolheadRule, 0, len(rules))
+	for _, rule := range rules {
+		list = append(list, rule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ToolheadID < list[j].ToolheadID })
+	c.JSON(http.StatusOK, ToolheadRulesResponse{Rules: list})
+}
+
+// updateToolheadRulesHandler replaces a printer's toolhead rules
+func (ws *WebServer) updateToolheadRulesHandler(c *gin.Context) {
+	var req struct {
+		Rules []ToolheadRule `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if err := ws.bridge.SetToolheadRules(c.Param("id"), req.Rules); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, MessageResponse{Message: "Toolhead rules updated successfully"})
+}
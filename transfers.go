@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spool event types
+const (
+	SpoolEventTransferOut = "transfer_out" // Filament moved off this spool onto another
+	SpoolEventTransferIn  = "transfer_in"  // Filament moved onto this spool from another
+)
+
+// SpoolEvent is an entry in the spool event log
+type SpoolEvent struct {
+	ID               int       `json:"id"`
+	SpoolmanInstance string    `json:"spoolman_instance,omitempty"` // Empty for the main Spoolman instance
+	SpoolID          int       `json:"spool_id"`
+	EventType        string    `json:"event_type"`
+	RelatedSpoolID   int       `json:"related_spool_id,omitempty"` // The other spool of a transfer, refill or toolhead change
+	Weight           float64   `json:"weight"`                     // Grams involved
+	Location         string    `json:"location,omitempty"`         // Toolhead or storage location of an assignment or move
+	Member           string    `json:"member,omitempty"`           // Member who made the change, if any
+	Notes            string    `json:"notes"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// SpoolTransfer moves remaining filament from one spool record onto another
+type SpoolTransfer struct {
+	ToSpoolID     int      `json:"to_spool_id"`
+	Weight        *float64 `json:"weight"`         // Grams to move; all remaining filament when omitted
+	ArchiveSource bool     `json:"archive_source"` // Archive the source spool once it's empty
+	Notes         string   `json:"notes"`
+	PrinterName   string   `json:"printer_name"` // Picks the Spoolman instance of both spools; the main one when empty
+}
+
+// SpoolTransferResult describes a completed transfer
+type SpoolTransferResult struct {
+	FromSpoolID int     `json:"from_spool_id"`
+	ToSpoolID   int     `json:"to_spool_id"`
+	Weight      float64 `json:"weight"`
+	Archived    bool    `json:"archived"`          // The source spool was archived
+	Warning     string  `json:"warning,omitempty"` // e.g. the spools hold different filaments
+}
+
+// TransferSpool moves filament from one spool onto another in their Spoolman instance and
+// records the transfer in the event log of both spools
+func (b *FilamentBridge) TransferSpool(fromSpoolID int, transfer SpoolTransfer, member string) (*SpoolTransferResult, error) {
+	toSpoolID := transfer.ToSpoolID
+	if toSpoolID <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "to_spool_id must be positive")
+	}
+	if toSpoolID == fromSpoolID {
+		return nil, newCodedError(ErrCodeInvalidRequest, "can't transfer a spool onto itself")
+	}
+
+	spoolman := b.spoolmanFor(transfer.PrinterName)
+	from, err := spoolman.GetSpool(fromSpoolID)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", fromSpoolID, err)
+	}
+	to, err := spoolman.GetSpool(toSpoolID)
+	if err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", toSpoolID, err)
+	}
+	if to.Archived {
+		return nil, newCodedError(ErrCodeConflict, "spool %d is archived", toSpoolID)
+	}
+
+	weight := from.RemainingWeight
+	if transfer.Weight != nil {
+		weight = *transfer.Weight
+	}
+	if weight <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool %d has no filament to transfer", fromSpoolID)
+	}
+	// Allow for rounding in the remaining weight Spoolman reports
+	if weight > from.RemainingWeight+0.01 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool %d only has %.1fg remaining", fromSpoolID, from.RemainingWeight)
+	}
+
+	result := &SpoolTransferResult{FromSpoolID: fromSpoolID, ToSpoolID: toSpoolID, Weight: weight}
+	if from.Filament != nil && to.Filament != nil && from.Filament.ID != to.Filament.ID {
+		result.Warning = fmt.Sprintf("Spool %d holds %s but spool %d holds %s", fromSpoolID, from.Filament.Name, toSpoolID, to.Filament.Name)
+	}
+
+	// Take the filament off the source first, then add it to the destination
+	if err := spoolman.AdjustSpoolUsedWeight(fromSpoolID, weight); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to remove filament from spool %d: %v", fromSpoolID, err)
+	}
+	if err := spoolman.AddSpoolRemainingWeight(toSpoolID, weight); err != nil {
+		// Put the filament back so Spoolman isn't left half-transferred
+		if rollbackErr := spoolman.AdjustSpoolUsedWeight(fromSpoolID, -weight); rollbackErr != nil {
+			bridgeLog.Error("Failed to restore spool after failed transfer", "spool_id", fromSpoolID, "error", rollbackErr)
+		}
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to add filament to spool %d: %v", toSpoolID, err)
+	}
+
+	if transfer.ArchiveSource && math.Abs(from.RemainingWeight-weight) < 0.01 {
+		if err := spoolman.UpdateSpool(fromSpoolID, map[string]interface{}{"archived": true}); err != nil {
+			bridgeLog.Warn("Failed to archive emptied spool", "spool_id", fromSpoolID, "error", err)
+		} else {
+			result.Archived = true
+		}
+	}
+
+	notes := strings.TrimSpace(transfer.Notes)
+	instance := b.spoolmanInstanceOf(transfer.PrinterName)
+	now := time.Now()
+	events := []SpoolEvent{
+		{SpoolmanInstance: instance, SpoolID: fromSpoolID, EventType: SpoolEventTransferOut, RelatedSpoolID: toSpoolID, Weight: weight, Member: member, Notes: notes, CreatedAt: now},
+		{SpoolmanInstance: instance, SpoolID: toSpoolID, EventType: SpoolEventTransferIn, RelatedSpoolID: fromSpoolID, Weight: weight, Member: member, Notes: notes, CreatedAt: now},
+	}
+	if err := b.recordSpoolEvents(events); err != nil {
+		// Spoolman has already been updated, so report success and keep the failure in the log
+		bridgeLog.Error("Failed to record spool transfer", "spool_id", fromSpoolID, "to_spool_id", toSpoolID, "error", err)
+	}
+
+	bridgeLog.Info("Transferred filament between spools", "spool_id", fromSpoolID, "to_spool_id", toSpoolID,
+		"grams", weight, "archived", result.Archived)
+	return result, nil
+}
+
+// recordSpoolEvents writes entries to the spool event log in one transaction
+func (b *FilamentBridge) recordSpoolEvents(events []SpoolEvent) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin spool events: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		if _, err := tx.Exec(
+			`INSERT INTO spool_events (spoolman_instance, spool_id, event_type, related_spool_id, weight, location, member, notes, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			event.SpoolmanInstance, event.SpoolID, event.EventType, event.RelatedSpoolID, event.Weight, event.Location, event.Member, event.Notes, event.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to record spool event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit spool events: %w", err)
+	}
+	return nil
+}
+
+// GetSpoolEvents returns the event log of a spool of a Spoolman instance, oldest first
+func (b *FilamentBridge) GetSpoolEvents(instance string, spoolID int) ([]SpoolEvent, error) {
+	rows, err := b.db.Query(
+		`SELECT id, COALESCE(spoolman_instance, ''), spool_id, event_type, COALESCE(related_spool_id, 0), COALESCE(weight, 0),
+			COALESCE(location, ''), COALESCE(member, ''), COALESCE(notes, ''), created_at
+		FROM spool_events WHERE COALESCE(spoolman_instance, '') = ? AND spool_id = ? ORDER BY id`,
+		instance, spoolID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spool events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []SpoolEvent{}
+	for rows.Next() {
+		var event SpoolEvent
+		if err := rows.Scan(&event.ID, &event.SpoolmanInstance, &event.SpoolID, &event.EventType, &event.RelatedSpoolID, &event.Weight,
+			&event.Location, &event.Member, &event.Notes, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan spool event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// transferSpoolHandler moves remaining filament from one spool onto another
+func (ws *WebServer) transferSpoolHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	var req SpoolTransfer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	var member string
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+
+	result, err := ws.bridge.TransferSpool(spoolID, req, member)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SpoolTransferResponse{Message: "Filament transferred successfully", Transfer: result})
+}
+
+// getSpoolEventsHandler returns the event log of a spool
+func (ws *WebServer) getSpoolEventsHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	events, err := ws.bridge.GetSpoolEvents(ws.bridge.spoolmanInstanceOf(c.Query("printer_name")), spoolID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolEventsResponse{Events: events})
+}
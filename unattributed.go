@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnattributedUsage is filament a finished print used on a toolhead with no spool mapped. It's
+// kept until someone assigns it to the spool that was loaded, which charges the spool as if
+// it had been mapped, or discards it.
+type UnattributedUsage struct {
+	ID             int       `json:"id"`
+	PrinterName    string    `json:"printer_name"`
+	ToolheadID     int       `json:"toolhead_id"`
+	FilamentUsed   float64   `json:"filament_used"`             // Grams, converted with the default filament when only a length is known
+	FilamentLength float64   `json:"filament_length,omitempty"` // mm, when the print reported a length, converted again with the assigned spool's filament
+	Waste          float64   `json:"waste"`
+	JobName        string    `json:"job_name"`
+	JobDisplayName string    `json:"job_display_name"`
+	Source         string    `json:"source"`
+	CreatedAt      time.Time `json:"created_at"` // When the print finished
+}
+
+const unattributedUsageColumns = "id, printer_name, toolhead_id, filament_used, COALESCE(filament_length, 0), COALESCE(waste, 0), " +
+	"COALESCE(job_name, ''), COALESCE(job_display_name, ''), COALESCE(source, ''), created_at"
+
+// scanUnattributedUsage reads unattributed usage from a query row
+func scanUnattributedUsage(scanner interface{ Scan(...interface{}) error }) (UnattributedUsage, error) {
+	var usage UnattributedUsage
+	err := scanner.Scan(&usage.ID, &usage.PrinterName, &usage.ToolheadID, &usage.FilamentUsed, &usage.FilamentLength, &usage.Waste,
+		&usage.JobName, &usage.JobDisplayName, &usage.Source, &usage.CreatedAt)
+	return usage, err
+}
+
+// recordUnattributedUsage keeps usage of an unmapped toolhead for assigning to a spool later
+func (b *FilamentBridge) recordUnattributedUsage(usage UnattributedUsage) error {
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = time.Now()
+	}
+	_, err := b.db.Exec(
+		`INSERT INTO unattributed_usage (printer_name, toolhead_id, filament_used, filament_length, waste, job_name, job_display_name, source, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		usage.PrinterName, usage.ToolheadID, usage.FilamentUsed, usage.FilamentLength, usage.Waste, usage.JobName, usage.JobDisplayName,
+		usage.Source, usage.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record unattributed usage: %w", err)
+	}
+	return nil
+}
+
+// GetUnattributedUsage returns usage waiting to be assigned to a spool, oldest first
+func (b *FilamentBridge) GetUnattributedUsage() ([]UnattributedUsage, error) {
+	rows, err := b.db.Query("SELECT " + unattributedUsageColumns + " FROM unattributed_usage ORDER BY created_at, toolhead_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unattributed usage: %w", err)
+	}
+	defer rows.Close()
+
+	usages := []UnattributedUsage{}
+	for rows.Next() {
+		usage, err := scanUnattributedUsage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan unattributed usage: %w", err)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, rows.Err()
+}
+
+// getUnattributedUsage returns unattributed usage by ID
+func (b *FilamentBridge) getUnattributedUsage(id int) (*UnattributedUsage, error) {
+	usage, err := scanUnattributedUsage(b.db.QueryRow("SELECT "+unattributedUsageColumns+" FROM unattributed_usage WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, newCodedError(ErrCodeNotFound, "unattributed usage %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unattributed usage: %w", err)
+	}
+	return &usage, nil
+}
+
+// AssignUnattributedUsage charges unattributed usage to the spool that was loaded, in the
+// Spoolman instance of its printer. It's recorded in the print history as of when the print
+// finished, like usage that was applied at the time. member is who made the assignment.
+func (b *FilamentBridge) AssignUnattributedUsage(id, spoolID int, member string) (*PendingUpdate, error) {
+	if spoolID <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_id is required")
+	}
+	usage, err := b.getUnattributedUsage(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.spoolmanFor(usage.PrinterName).GetSpool(spoolID); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to get spool %d: %v", spoolID, err)
+	}
+
+	update := PendingUpdate{
+		PrinterName:    usage.PrinterName,
+		ToolheadID:     usage.ToolheadID,
+		SpoolID:        spoolID,
+		FilamentUsed:   usage.FilamentUsed,
+		Waste:          usage.Waste,
+		JobName:        usage.JobName,
+		JobDisplayName: usage.JobDisplayName,
+		Source:         usage.Source,
+		Member:         member,
+		CreatedAt:      usage.CreatedAt,
+	}
+	// A length is converted with the assigned spool's filament, as it would have been if mapped
+	if usage.FilamentLength > 0 {
+		update.FilamentUsed = b.spoolLengthToWeight(usage.PrinterName, spoolID, usage.FilamentLength)
+	}
+	if factor := b.usageCorrectionFactor(usage.PrinterName, spoolID); factor != 1 {
+		update.FilamentUsed *= factor
+		update.Waste *= factor
+	}
+
+	if err := b.applyPendingUpdate(update); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to update spool %d: %v", spoolID, err)
+	}
+	if _, err := b.db.Exec("DELETE FROM unattributed_usage WHERE id = ?", id); err != nil {
+		// Spoolman has the usage now; leaving the row would let it be assigned twice
+		bridgeLog.Error("Failed to remove assigned unattributed usage", "id", id, "error", err)
+	}
+
+	bridgeLog.Info("Assigned unattributed usage to spool", "printer", usage.PrinterName, "toolhead_id", usage.ToolheadID,
+		"job", usage.JobName, "spool_id", spoolID, "grams", update.FilamentUsed)
+	return &update, nil
+}
+
+// DeleteUnattributedUsage discards unattributed usage without charging any spool
+func (b *FilamentBridge) DeleteUnattributedUsage(id int) error {
+	result, err := b.db.Exec("DELETE FROM unattributed_usage WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete unattributed usage: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodeNotFound, "unattributed usage %d not found", id)
+	}
+	return nil
+}
+
+// getUnattributedUsageHandler lists usage of unmapped toolheads waiting for a spool
+func (ws *WebServer) getUnattributedUsageHandler(c *gin.Context) {
+	usages, err := ws.bridge.GetUnattributedUsage()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, UnattributedUsageResponse{Usage: usages})
+}
+
+// assignUnattributedUsageHandler charges unattributed usage to a spool
+func (ws *WebServer) assignUnattributedUsageHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid unattributed usage ID")
+		return
+	}
+	var req struct {
+		SpoolID int `json:"spool_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	member := ""
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+
+	// Serialize so the same usage can't be charged twice
+	ws.operationMutex.Lock()
+	update, err := ws.bridge.AssignUnattributedUsage(id, req.SpoolID, member)
+	ws.operationMutex.Unlock()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: fmt.Sprintf("Charged %.1fg to spool %d", update.FilamentUsed, update.SpoolID)})
+}
+
+// deleteUnattributedUsageHandler discards unattributed usage
+func (ws *WebServer) deleteUnattributedUsageHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid unattributed usage ID")
+		return
+	}
+	if err := ws.bridge.DeleteUnattributedUsage(id); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+	c.JSON(http.StatusOK, MessageResponse{Message: "Unattributed usage discarded"})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Print history entry sources
+const (
+	HistorySourcePrint     = "print"
+	HistorySourceManual    = "manual"
+	HistorySourceEstimated = "estimated" // Usage estimated from job telemetry because the G-code was unavailable
+	HistorySourceFixture   = "fixture"   // Synthetic load-testing data, see fixtures.go
+)
+
+// Manual usage kinds
+var manualUsageKinds = map[string]bool{
+	"purge":        true,
+	"failed_start": true,
+	"calibration":  true,
+	"offline":      true,
+	"other":        true,
+}
+
+// ManualUsage is ad-hoc filament consumption recorded outside of a monitored print
+type ManualUsage struct {
+	SpoolID        int     `json:"spool_id"`
+	PrinterName    string  `json:"printer_name"`
+	ToolheadID     *int    `json:"toolhead_id"`
+	FilamentUsed   float64 `json:"filament_used"`
+	FilamentLength float64 `json:"filament_length"` // mm, converted with the spool's filament when filament_used isn't given
+	Kind           string  `json:"kind"`
+	Notes          string  `json:"notes"`
+}
+
+// toolheadMappingOwner returns the spool mapped to a toolhead and the member who mapped it
+func (b *FilamentBridge) toolheadMappingOwner(printerName string, toolheadID int) (int, string, error) {
+	var spoolID int
+	var mappedBy string
+	err := b.db.QueryRow(
+		"SELECT spool_id, COALESCE(mapped_by, '') FROM toolhead_mappings WHERE printer_name = ? AND toolhead_id = ?",
+		printerName, toolheadID,
+	).Scan(&spoolID, &mappedBy)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get toolhead mapping: %w", err)
+	}
+	return spoolID, mappedBy, nil
+}
+
+// spoolMappingOwner returns the member who mapped a spool to a toolhead, if it is mapped
+func (b *FilamentBridge) spoolMappingOwner(spoolID int) (string, error) {
+	var mappedBy string
+	err := b.db.QueryRow(
+		"SELECT COALESCE(mapped_by, '') FROM toolhead_mappings WHERE spool_id = ?",
+		spoolID,
+	).Scan(&mappedBy)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get spool mapping: %w", err)
+	}
+	return mappedBy, nil
+}
+
+// RecordManualUsage pushes ad-hoc usage to Spoolman and logs it in print history.
+// When member is non-empty the usage must target a spool or toolhead that member mapped.
+func (b *FilamentBridge) RecordManualUsage(usage ManualUsage, member string) (*PrintHistory, error) {
+	if usage.FilamentUsed <= 0 && usage.FilamentLength <= 0 {
+		return nil, newCodedError(ErrCodeInvalidRequest, "filament_used or filament_length must be positive")
+	}
+	if usage.Kind == "" {
+		usage.Kind = "other"
+	}
+	if !manualUsageKinds[usage.Kind] {
+		return nil, newCodedError(ErrCodeInvalidRequest, "unknown usage kind: %s", usage.Kind)
+	}
+
+	toolheadID := 0
+	spoolID := usage.SpoolID
+	mappedBy := ""
+	switch {
+	case usage.PrinterName != "" && usage.ToolheadID != nil:
+		toolheadID = *usage.ToolheadID
+		mappedSpoolID, owner, err := b.toolheadMappingOwner(usage.PrinterName, toolheadID)
+		if err != nil {
+			return nil, err
+		}
+		if spoolID == 0 {
+			spoolID = mappedSpoolID
+		}
+		if spoolID == 0 {
+			return nil, newCodedError(ErrCodeNotFound, "no spool mapped to %s toolhead %d", usage.PrinterName, toolheadID)
+		}
+		if spoolID == mappedSpoolID {
+			mappedBy = owner
+		}
+	case spoolID > 0:
+		usage.PrinterName = "" // A printer without a toolhead doesn't identify anything
+		owner, err := b.spoolMappingOwner(spoolID)
+		if err != nil {
+			return nil, err
+		}
+		mappedBy = owner
+	default:
+		return nil, newCodedError(ErrCodeInvalidRequest, "spool_id or printer_name and toolhead_id are required")
+	}
+
+	if member != "" && mappedBy != member {
+		return nil, newCodedError(ErrCodeForbidden, "members can only log usage against spools they mapped")
+	}
+
+	if usage.FilamentUsed <= 0 {
+		usage.FilamentUsed = b.spoolLengthToWeight(usage.PrinterName, spoolID, usage.FilamentLength)
+	}
+
+	if err := b.spoolmanFor(usage.PrinterName).UpdateSpoolUsage(spoolID, usage.FilamentUsed); err != nil {
+		return nil, newCodedError(ErrCodeSpoolmanError, "failed to update spool %d usage: %v", spoolID, err)
+	}
+
+	jobName := "Manual: " + strings.ReplaceAll(usage.Kind, "_", " ")
+	now := time.Now()
+	cost := b.usageCost(usage.PrinterName, spoolID, usage.FilamentUsed)
+
+	b.mutex.Lock()
+	result, err := b.db.Exec(
+		`INSERT INTO print_history (printer_name, toolhead_id, spool_id, filament_used, print_started, print_finished, job_name, job_display_name, notes, member, source, cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		usage.PrinterName, toolheadID, spoolID, usage.FilamentUsed, now, now, jobName, jobName, strings.TrimSpace(usage.Notes), mappedBy, HistorySourceManual, cost,
+	)
+	b.mutex.Unlock()
+	if err != nil {
+		// Spoolman is already updated; the history row is what's missing
+		return nil, fmt.Errorf("failed to log manual usage: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history ID: %w", err)
+	}
+
+	bridgeLog.Info("Recorded manual usage", "spool_id", spoolID, "filament_used", usage.FilamentUsed, "kind", usage.Kind)
+	b.emitSpoolDeducted(usage.PrinterName, toolheadID, spoolID, usage.FilamentUsed, jobName, jobName, HistorySourceManual)
+	return b.GetPrintHistoryEntry(int(id))
+}
+
+// recordUsageHandler records ad-hoc filament consumption against a spool or toolhead
+func (ws *WebServer) recordUsageHandler(c *gin.Context) {
+	var usage ManualUsage
+	if err := c.ShouldBindJSON(&usage); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	member := ""
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+
+	entry, err := ws.bridge.RecordManualUsage(usage, member)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, HistoryEntryResponse{Message: "Usage recorded successfully", Entry: entry})
+}
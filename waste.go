@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// "; total filament used for wipe tower [g] = 4.21", included in the per-toolhead weights
+	wipeTowerWeightRegex = regexp.MustCompile(`(?:^|\W)total filament used for wipe tower \[g\]\s*=\s*([0-9.]+)`)
+	// "; wiping_volumes_matrix = 0,140,140,0", mm³ purged when switching from one toolhead to another
+	wipingVolumesRegex = regexp.MustCompile(`(?:^|\W)wiping_volumes_matrix\s*=\s*([0-9.,\s]+)`)
+)
+
+// ParseGcodeWaste finds how much of a job's filament goes to the wipe tower and purges, and
+// splits it between the toolheads in usage. PrusaSlicer counts this filament in each
+// toolhead's usage already, so the result is a breakdown of usage rather than extra to charge.
+// Files without a wipe tower give nil.
+func ParseGcodeWaste(content []byte, usage map[int]float64) map[int]float64 {
+	match := wipeTowerWeightRegex.FindSubmatch(content)
+	if match == nil {
+		return nil
+	}
+	total, err := strconv.ParseFloat(string(match[1]), 64)
+	if err != nil || total <= 0 {
+		return nil
+	}
+
+	var matrix []float64
+	if match := wipingVolumesRegex.FindSubmatch(content); match != nil {
+		for _, value := range strings.Split(string(match[1]), ",") {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				matrix = nil
+				break
+			}
+			matrix = append(matrix, parsed)
+		}
+	}
+	return splitPurgeWaste(total, usage, matrix)
+}
+
+// splitPurgeWaste divides wipe tower filament between the toolheads a job used. Purging uses
+// the filament being switched to, so each toolhead's share follows the wiping volumes for
+// switching to it from the job's other toolheads. Without a usable matrix, waste follows usage.
+func splitPurgeWaste(total float64, usage map[int]float64, matrix []float64) map[int]float64 {
+	shares := make(map[int]float64)
+	size := int(math.Sqrt(float64(len(matrix))))
+	if size > 0 && size*size == len(matrix) {
+		for to := range usage {
+			for from := range usage {
+				if from != to && from < size && to < size {
+					shares[to] += matrix[from*size+to]
+				}
+			}
+		}
+	}
+
+	var sum float64
+	for _, share := range shares {
+		sum += share
+	}
+	if sum == 0 {
+		shares = usage
+		for _, used := range usage {
+			sum += used
+		}
+	}
+	if sum == 0 {
+		return nil
+	}
+
+	waste := make(map[int]float64)
+	for toolheadID, share := range shares {
+		if share > 0 {
+			// A toolhead can't have wasted more than it used
+			waste[toolheadID] = math.Min(total*share/sum, usage[toolheadID])
+		}
+	}
+	return waste
+}
+
+// addSpoolWaste adds grams to the purge waste total kept in the extra field of a spool in the
+// printer's Spoolman instance, defining the field in Spoolman on first use
+func (b *FilamentBridge) addSpoolWaste(printerName string, spoolID int, field string, grams float64) error {
+	if err := b.ensureSpoolField(printerName, field, "Purge waste (g)", "float"); err != nil {
+		return err
+	}
+	spool, err := b.spoolmanFor(printerName).GetSpool(spoolID)
+	if err != nil {
+		return newCodedError(ErrCodeNotFound, "%v", err)
+	}
+
+	total := grams
+	if encoded, ok := spool.Extra[field].(string); ok {
+		if current, err := strconv.ParseFloat(strings.Trim(encoded, `"`), 64); err == nil {
+			total += current
+		}
+	}
+	return b.updateSpoolExtra(printerName, spoolID, map[string]interface{}{
+		field: strconv.FormatFloat(math.Max(math.Round(total*100)/100, 0), 'f', -1, 64),
+	})
+}
+
+// correctSpoolWaste updates the spools' purge waste totals after a history entry is
+// corrected: its recorded waste comes off its spool and newWaste goes to spoolID. Failures
+// are only logged, as the usage itself has already been corrected.
+func (b *FilamentBridge) correctSpoolWaste(entry PrintHistory, spoolID int, newWaste float64) {
+	snapshot := b.GetConfigSnapshot()
+	if snapshot == nil || snapshot.WasteSpoolField == "" {
+		return
+	}
+
+	changes := map[int]float64{entry.SpoolID: -entry.Waste}
+	changes[spoolID] += newWaste
+	for changedSpoolID, grams := range changes {
+		if math.Abs(grams) < 0.005 {
+			continue
+		}
+		if err := b.addSpoolWaste(entry.PrinterName, changedSpoolID, snapshot.WasteSpoolField, grams); err != nil {
+			bridgeLog.Warn("Error correcting spool purge waste", "spool_id", changedSpoolID, "field", snapshot.WasteSpoolField, "error", err)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"html/template"
 	"io/fs"
-	"log"
 	"net/http"
 	neturl "net/url"
 	"sort"
@@ -33,6 +32,11 @@ type WebServer struct {
 	router         *gin.Engine
 	operationMutex sync.Mutex // Protects add/update/delete printer operations
 	wsHub          *WebSocketHub
+	basePath       string // Prefix all routes are served under, e.g. "/filabridge"; empty for the root
+	server         *http.Server
+	monitor        *PrinterMonitor // Printer monitor running in this process, nil in web-only mode
+
+	deprecatedCalls sync.Map // Deprecated API routes called since startup, each logged once
 }
 
 // WebSocketHub manages WebSocket connections and broadcasts
@@ -59,6 +63,54 @@ type WebSocketMessage struct {
 	Spools           []SpoolmanSpool                    `json:"spools"`
 	ToolheadMappings map[string]map[int]ToolheadMapping `json:"toolhead_mappings"`
 	PrintErrors      []PrintError                       `json:"print_errors,omitempty"`
+	RunoutAlerts     []RunoutPrediction                 `json:"runout_alerts"`
+	ActiveSpools     []ActiveSpool                      `json:"active_spools"`
+	FilamentRunouts  []FilamentRunout                   `json:"filament_runouts"`
+	Unattributed     []UnattributedUsage                `json:"unattributed_usage"`
+}
+
+// sensitiveQueryParams carry credentials in a URL (webhook secrets, scan tokens and signed
+// links) and are redacted in the access log
+var sensitiveQueryParams = []string{"secret", "token", "sig"}
+
+// accessLogFormatter formats an access log line like gin's default logger, without colors and
+// with sensitive query parameters redacted
+func accessLogFormatter(params gin.LogFormatterParams) string {
+	if params.Latency > time.Minute {
+		params.Latency = params.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		params.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		params.StatusCode,
+		params.Latency,
+		params.ClientIP,
+		params.Method,
+		redactQueryParams(params.Path),
+		params.ErrorMessage,
+	)
+}
+
+// redactQueryParams replaces the values of sensitive query parameters in a request path
+func redactQueryParams(path string) string {
+	base, query, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	values, err := neturl.ParseQuery(query)
+	if err != nil {
+		return base + "?REDACTED" // Can't tell which parameters are sensitive
+	}
+	redacted := false
+	for _, name := range sensitiveQueryParams {
+		if values.Has(name) {
+			values.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return base + "?" + values.Encode()
 }
 
 // NewWebServer creates a new web server with Gin
@@ -67,7 +119,7 @@ func NewWebServer(bridge *FilamentBridge) *WebServer {
 	router := gin.New()
 
 	// Add middleware
-	router.Use(gin.Logger())
+	router.Use(gin.LoggerWithFormatter(accessLogFormatter))
 	router.Use(gin.Recovery())
 
 	// Add custom recovery middleware for API routes to ensure JSON responses
@@ -76,7 +128,7 @@ func NewWebServer(bridge *FilamentBridge) *WebServer {
 			if err := recover(); err != nil {
 				// Check if this is an API route
 				if strings.HasPrefix(c.Request.URL.Path, "/api/") {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+					respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 					c.Abort()
 				} else {
 					// For non-API routes, use default recovery behavior
@@ -96,9 +148,11 @@ func NewWebServer(bridge *FilamentBridge) *WebServer {
 	}
 
 	ws := &WebServer{
-		bridge: bridge,
-		router: router,
-		wsHub:  wsHub,
+		bridge:   bridge,
+		router:   router,
+		wsHub:    wsHub,
+		basePath: bridge.GetConfigSnapshot().BasePath,
+		server:   &http.Server{},
 	}
 
 	// Start WebSocket hub
@@ -117,11 +171,26 @@ func generateToolheadIDs(count int) []int {
 	return ids
 }
 
+// respondError writes a structured JSON error response with a machine-readable code
+func respondError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{"error": message, "code": code})
+}
+
+// respondErrorFrom writes err as a structured JSON error response. If err carries
+// its own code, that code (and its matching HTTP status) takes precedence over the
+// provided fallbacks.
+func respondErrorFrom(c *gin.Context, status int, fallbackCode string, err error) {
+	code := errorCode(err, fallbackCode)
+	respondError(c, httpStatusForCode(code, status), code, err.Error())
+}
+
 // setupRoutes configures all the routes
 func (ws *WebServer) setupRoutes() {
 	// Load HTML templates with custom functions from embedded filesystem
 	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
 		"generateToolheadIDs": generateToolheadIDs,
+		"basePath":            func() string { return ws.basePath },
+		"formatDuration":      formatDuration,
 	}).ParseFS(templatesFS, "templates/*"))
 	ws.router.SetHTMLTemplate(tmpl)
 
@@ -129,49 +198,197 @@ func (ws *WebServer) setupRoutes() {
 	// Use fs.Sub to strip the "static/" prefix from embedded paths
 	staticSubFS, err := fs.Sub(staticFS, "static")
 	if err != nil {
-		log.Fatalf("Failed to create static filesystem: %v", err)
+		fatal("Failed to create static filesystem", "error", err)
 	}
 	ws.router.StaticFS("/static", http.FS(staticSubFS))
 
+	// Any page or API request can ask for fresh Spoolman data
+	ws.router.Use(ws.spoolmanRefresh())
+
 	// Main dashboard
-	ws.router.GET("/", ws.dashboardHandler)
+	ws.router.GET("/", ws.requireLogin(), ws.dashboardHandler)
+	ws.router.GET("/login", ws.loginPageHandler)
+
+	// API routes, under /api/v1 and at their deprecated unversioned paths
+	ws.registerAPIRoutes(ws.router.Group(APIV1Prefix, ws.accessControl()))
+	ws.registerAPIRoutes(ws.router.Group(LegacyAPIPrefix, ws.deprecatedAPI(), ws.accessControl()))
 
-	// API routes
-	api := ws.router.Group("/api")
+	// Read-only Spoolman proxy
+	spoolmanProxy := ws.router.Group(SpoolmanProxyPath)
+	spoolmanProxy.Use(ws.accessControl())
 	{
-		api.GET("/status", ws.statusHandler)
-		api.GET("/spools", ws.spoolsHandler)
-		api.GET("/filaments", ws.filamentsHandler)
-		api.POST("/map_toolhead", ws.mapToolheadHandler)
-		api.GET("/available_spools", ws.availableSpoolsHandler)
-		api.GET("/spoolman/test", ws.testSpoolmanConnectionHandler)
-		api.GET("/spoolman/debug", ws.debugSpoolmanHandler)
-		api.POST("/test/print_complete", ws.testPrintCompleteHandler)
-		api.GET("/config", ws.getConfigHandler)
-		api.POST("/config", ws.updateConfigHandler)
-		api.GET("/config/auto-assign-previous-spool", ws.getAutoAssignPreviousSpoolHandler)
-		api.PUT("/config/auto-assign-previous-spool", ws.updateAutoAssignPreviousSpoolHandler)
-		api.GET("/printers", ws.getPrintersHandler)
-		api.POST("/printers", ws.addPrinterHandler)
-		api.PUT("/printers/:id", ws.updatePrinterHandler)
-		api.DELETE("/printers/:id", ws.deletePrinterHandler)
-		api.GET("/printers/:id/toolheads", ws.getToolheadNamesHandler)
-		api.PUT("/printers/:id/toolheads/:toolhead_id", ws.updateToolheadNameHandler)
-		api.POST("/detect_printer", ws.detectPrinterHandler)
-		api.GET("/print-errors", ws.getPrintErrorsHandler)
-		api.POST("/print-errors/:id/acknowledge", ws.acknowledgePrintErrorHandler)
-		api.GET("/nfc/assign", ws.nfcAssignHandler)
-		api.GET("/nfc/urls", ws.nfcUrlsHandler)
-		api.GET("/nfc/session/status", ws.nfcSessionStatusHandler)
-		api.GET("/locations", ws.getLocationsHandler)
-		api.GET("/locations/:name/status", ws.getLocationStatusHandler)
-		api.POST("/locations", ws.createLocationHandler)
-		api.PUT("/locations/:name", ws.updateLocationHandler)
-		api.DELETE("/locations/:name", ws.deleteLocationHandler)
-	}
-
-	// WebSocket endpoint
-	ws.router.GET("/ws/status", ws.websocketHandler)
+		spoolmanProxy.GET("/*path", ws.spoolmanProxyHandler)
+		spoolmanProxy.HEAD("/*path", ws.spoolmanProxyHandler)
+	}
+
+	// WebSocket endpoint; it pushes the same data as the status API, so it's behind the same access rules
+	ws.router.GET("/ws/status", ws.accessControl(), ws.websocketHandler)
+
+	// Prometheus metrics, which need a token like the rest of the API once login is on
+	ws.router.GET("/metrics", ws.accessControl(), ws.metricsHandler)
+
+	// Container liveness and readiness probes
+	ws.router.GET(HealthzPath, ws.healthzHandler)
+	ws.router.GET(ReadyzPath, ws.readyzHandler)
+}
+
+// registerAPIRoutes registers the API routes on a group
+func (ws *WebServer) registerAPIRoutes(api *gin.RouterGroup) {
+	api.GET("/status", ws.statusHandler)
+	api.GET("/events", ws.eventsHandler)
+	api.GET("/report", ws.reportHandler)
+	api.GET("/stats", ws.statsHandler)
+	api.GET("/logs", ws.getLogsHandler)
+	api.GET("/logs/stream", ws.logStreamHandler)
+	api.GET("/spools", ws.spoolsHandler)
+	api.GET("/spools/color-families", ws.spoolColorFamiliesHandler)
+	api.POST("/spools", ws.createSpoolHandler)
+	api.GET("/filaments", ws.filamentsHandler)
+	api.POST("/filaments", ws.createFilamentHandler)
+	api.GET("/vendors", ws.vendorsHandler)
+	api.POST("/vendors", ws.createVendorHandler)
+	api.GET("/barcodes", ws.getBarcodesHandler)
+	api.GET("/barcodes/:code", ws.lookupBarcodeHandler)
+	api.PUT("/barcodes/:code", ws.setBarcodeHandler)
+	api.DELETE("/barcodes/:code", ws.deleteBarcodeHandler)
+	api.GET("/materials/defaults", ws.getMaterialDefaultsHandler)
+	api.PUT("/materials/defaults/:material", ws.updateMaterialDefaultsHandler)
+	api.DELETE("/materials/defaults/:material", ws.deleteMaterialDefaultsHandler)
+	api.POST("/map_toolhead", ws.mapToolheadHandler)
+	api.POST("/map_toolheads", ws.mapToolheadsHandler)
+	api.GET("/available_spools", ws.availableSpoolsHandler)
+	api.GET("/suggest_spools", ws.suggestSpoolsHandler)
+	api.POST("/analyze", ws.analyzeGcodeHandler)
+	api.GET("/spoolman/test", ws.testSpoolmanConnectionHandler)
+	api.GET("/spoolman/debug", ws.debugSpoolmanHandler)
+	api.POST("/test/print_complete", ws.testPrintCompleteHandler)
+	api.GET("/config", ws.getConfigHandler)
+	api.POST("/config", ws.updateConfigHandler)
+	api.GET("/config/export", ws.exportConfigHandler)
+	api.GET("/config/overrides", ws.getConfigOverridesHandler)
+	api.POST("/config/import", ws.importConfigHandler)
+	api.GET("/config/auto-assign-previous-spool", ws.getAutoAssignPreviousSpoolHandler)
+	api.PUT("/config/auto-assign-previous-spool", ws.updateAutoAssignPreviousSpoolHandler)
+	api.GET("/printers", ws.getPrintersHandler)
+	api.POST("/printers", ws.addPrinterHandler)
+	api.PUT("/printers/:id", ws.updatePrinterHandler)
+	api.DELETE("/printers/:id", ws.deletePrinterHandler)
+	api.GET("/printers/:id/toolheads", ws.getToolheadNamesHandler)
+	api.PUT("/printers/:id/toolheads/:toolhead_id", ws.updateToolheadNameHandler)
+	api.GET("/printers/:id/toolhead-rules", ws.getToolheadRulesHandler)
+	api.PUT("/printers/:id/toolhead-rules", ws.updateToolheadRulesHandler)
+	api.POST("/printers/:id/rotate-key", ws.rotatePrinterKeyHandler)
+	api.POST("/printers/:id/purge", ws.purgePrinterDataHandler)
+	api.POST("/printers/:id/actions/:action", ws.controlPrinterHandler)
+	api.GET("/printers/:id/events", ws.getPrinterEventsHandler)
+	api.POST("/printers/:id/webhook-secret", ws.rotateWebhookSecretHandler)
+	api.DELETE("/printers/:id/webhook-secret", ws.deleteWebhookSecretHandler)
+	api.POST("/webhooks/print-event", ws.printEventWebhookHandler)
+	api.GET("/printer-groups", ws.getPrinterGroupsHandler)
+	api.GET("/printer-groups/:name", ws.getPrinterGroupHandler)
+	api.GET("/printer-groups/:name/stats", ws.getPrinterGroupStatsHandler)
+	api.PUT("/printer-groups/:name/mappings", ws.setPrinterGroupMappingsHandler)
+	api.DELETE("/printer-groups/:name/mappings", ws.clearPrinterGroupMappingsHandler)
+	api.GET("/printers/:id/maintenance-windows", ws.getMaintenanceWindowsHandler)
+	api.PUT("/printers/:id/maintenance-windows", ws.updateMaintenanceWindowsHandler)
+	api.POST("/detect_printer", ws.detectPrinterHandler)
+	api.GET("/discover_printers", ws.discoverPrintersHandler)
+	api.POST("/usage", ws.recordUsageHandler)
+	api.GET("/history", ws.getPrintHistoryHandler)
+	api.GET("/history/export", ws.exportHistoryHandler)
+	api.PUT("/history/:id", ws.updatePrintHistoryHandler)
+	api.PATCH("/history/:id", ws.adjustPrintHistoryHandler)
+	api.POST("/history/:id/revert", ws.revertPrintHistoryHandler)
+	api.POST("/history/:id/reassign", ws.reassignPrintHistoryHandler)
+	api.GET("/history/:id/corrections", ws.getHistoryCorrectionsHandler)
+	api.GET("/spools/:id/history", ws.getSpoolHistoryHandler)
+	api.GET("/spools/:id/fields", ws.getSpoolFieldsHandler)
+	api.PUT("/spools/:id/fields", ws.updateSpoolFieldsHandler)
+	api.PUT("/spools/:id/owner", ws.setSpoolOwnerHandler)
+	api.PUT("/spools/:id/low_stock_threshold", ws.setSpoolLowStockThresholdHandler)
+	api.POST("/spools/:id/measured_weight", ws.recordMeasuredWeightHandler)
+	api.GET("/scale-readings", ws.getScaleReadingsHandler)
+	api.POST("/scale-readings/:id/apply", ws.applyScaleReadingHandler)
+	api.POST("/scale-readings/:id/dismiss", ws.dismissScaleReadingHandler)
+	api.GET("/spool-exposure", ws.getSpoolExposuresHandler)
+	api.GET("/spools/:id/exposure", ws.getSpoolExposureHandler)
+	api.GET("/spools/:id/timeline", ws.getSpoolTimelineHandler)
+	api.PUT("/spools/:id/shared", ws.setSpoolSharedHandler)
+	api.GET("/shared-spools", ws.getSharedSpoolsHandler)
+	api.POST("/spools/:id/dried", ws.markSpoolDriedHandler)
+	api.POST("/spools/:id/transfer", ws.transferSpoolHandler)
+	api.POST("/spools/:id/refill", ws.refillSpoolHandler)
+	api.GET("/spools/:id/events", ws.getSpoolEventsHandler)
+	api.POST("/spools/:id/purge", ws.purgeSpoolDataHandler)
+	api.GET("/reservations", ws.getSpoolReservationsHandler)
+	api.POST("/reservations", ws.createSpoolReservationHandler)
+	api.DELETE("/reservations/:id", ws.deleteSpoolReservationHandler)
+	api.GET("/members", ws.getMembersHandler)
+	api.POST("/members", ws.createMemberHandler)
+	api.DELETE("/members/:id", ws.deleteMemberHandler)
+	api.POST("/admin-token", ws.rotateAdminTokenHandler)
+	api.GET("/tokens", ws.getAPITokensHandler)
+	api.POST("/tokens", ws.createAPITokenHandler)
+	api.DELETE("/tokens/:id", ws.deleteAPITokenHandler)
+	api.POST("/nfc/token/rotate", ws.rotateNFCScanTokenHandler)
+	api.GET("/auth/status", ws.authStatusHandler)
+	api.POST("/auth/login", ws.loginHandler)
+	api.POST("/auth/logout", ws.logoutHandler)
+	api.PUT("/auth/credentials", ws.updateLoginCredentialsHandler)
+	api.GET("/notifications/channels", ws.getNotificationChannelsHandler)
+	api.PUT("/notifications/channels", ws.updateNotificationChannelsHandler)
+	api.GET("/notifications/held", ws.getHeldNotificationsHandler)
+	api.GET("/webhooks/outgoing", ws.getOutgoingWebhooksHandler)
+	api.PUT("/webhooks/outgoing", ws.updateOutgoingWebhooksHandler)
+	api.GET("/spoolman/instances", ws.getSpoolmanInstancesHandler)
+	api.PUT("/spoolman/instances", ws.updateSpoolmanInstancesHandler)
+	api.GET("/scales", ws.getScalesHandler)
+	api.PUT("/scales", ws.updateScalesHandler)
+	api.GET("/environment-sensors", ws.getEnvironmentSensorsHandler)
+	api.PUT("/environment-sensors", ws.updateEnvironmentSensorsHandler)
+	api.GET("/reminders/return", ws.returnSpoolHandler)
+	api.GET("/print-errors", ws.getPrintErrorsHandler)
+	api.POST("/print-errors/:id/acknowledge", ws.acknowledgePrintErrorHandler)
+	api.POST("/print-errors/:id/retry", ws.retryPrintErrorHandler)
+	api.POST("/print-errors/:id/resolve", ws.resolvePrintErrorHandler)
+	api.GET("/runout-predictions", ws.getRunoutPredictionsHandler)
+	api.GET("/active-spools", ws.getActiveSpoolsHandler)
+	api.GET("/filament-runouts", ws.getFilamentRunoutsHandler)
+	api.POST("/filament-runouts/:id/confirm", ws.confirmFilamentRunoutHandler)
+	api.POST("/filament-runouts/:id/dismiss", ws.dismissFilamentRunoutHandler)
+	api.GET("/unattributed-usage", ws.getUnattributedUsageHandler)
+	api.POST("/unattributed-usage/:id/assign", ws.assignUnattributedUsageHandler)
+	api.DELETE("/unattributed-usage/:id", ws.deleteUnattributedUsageHandler)
+	api.GET("/reconcile", ws.getMappingMismatchesHandler)
+	api.POST("/reconcile/resolve", ws.resolveMappingMismatchHandler)
+	api.GET("/nfc/assign", ws.nfcAssignHandler)
+	api.POST("/nfc/barcode", ws.nfcBarcodeSpoolHandler)
+	api.GET("/nfc/toolhead", ws.nfcToolheadHandler)
+	api.GET("/nfc/urls", ws.nfcUrlsHandler)
+	api.GET("/nfc/labels.pdf", ws.nfcLabelsHandler)
+	api.GET("/nfc/ndef", ws.nfcNDEFHandler)
+	api.GET("/nfc/session/status", ws.nfcSessionStatusHandler)
+	api.DELETE("/nfc/session", ws.cancelNFCSessionHandler)
+	api.GET("/locations", ws.getLocationsHandler)
+	api.GET("/locations/:name/status", ws.getLocationStatusHandler)
+	api.POST("/locations", ws.createLocationHandler)
+	api.PUT("/locations/:name", ws.updateLocationHandler)
+	api.DELETE("/locations/:name", ws.deleteLocationHandler)
+	api.GET("/locations/:name/environment", ws.getLocationEnvironmentHandler)
+	api.POST("/locations/:name/environment", ws.recordEnvironmentReadingHandler)
+	api.PUT("/locations/:name/environment", ws.setLocationDryboxHandler)
+	api.GET("/fixtures", ws.getFixturesHandler)
+	api.POST("/fixtures", ws.createFixturesHandler)
+	api.DELETE("/fixtures", ws.deleteFixturesHandler)
+	api.GET("/backup", ws.backupHandler)
+	api.POST("/restore", ws.restoreHandler)
+	api.GET("/scheduler/jobs", ws.getScheduledJobsHandler)
+	api.PUT("/scheduler/jobs/:name", ws.updateScheduledJobHandler)
+	api.POST("/scheduler/jobs/:name/run", ws.runScheduledJobHandler)
+	api.GET("/pending_updates", ws.getPendingUpdatesHandler)
+	api.DELETE("/pending_updates/:id", ws.deletePendingUpdateHandler)
+	api.GET(strings.TrimPrefix(OpenAPIPath, "/api"), ws.openAPIHandler)
+	api.GET(strings.TrimPrefix(OpenAPIDocsPath, "/api"), ws.openAPIDocsHandler)
 }
 
 // WebSocket hub methods
@@ -184,7 +401,7 @@ func (h *WebSocketHub) run() {
 			h.mutex.Lock()
 			h.clients[client] = true
 			h.mutex.Unlock()
-			log.Printf("WebSocket client connected. Total clients: %d", len(h.clients))
+			webLog.Debug("WebSocket client connected", "clients", len(h.clients))
 
 		case client := <-h.unregister:
 			h.mutex.Lock()
@@ -193,7 +410,7 @@ func (h *WebSocketHub) run() {
 				close(client.send)
 			}
 			h.mutex.Unlock()
-			log.Printf("WebSocket client disconnected. Total clients: %d", len(h.clients))
+			webLog.Debug("WebSocket client disconnected", "clients", len(h.clients))
 
 		case message := <-h.broadcast:
 			h.mutex.RLock()
@@ -212,23 +429,51 @@ func (h *WebSocketHub) run() {
 
 // BroadcastStatus sends status updates to all connected clients
 func (ws *WebServer) BroadcastStatus() {
+	jsonData, err := ws.statusMessage()
+	if err != nil {
+		webLog.Error("Error building status broadcast", "error", err)
+		return
+	}
+
+	// Broadcast to all clients
+	select {
+	case ws.wsHub.broadcast <- jsonData:
+		webLog.Debug("Broadcasted status update", "clients", len(ws.wsHub.clients))
+	default:
+		webLog.Debug("No clients connected to receive broadcast")
+	}
+}
+
+// statusMessage builds the status_update message sent to dashboard and event stream clients
+func (ws *WebServer) statusMessage() ([]byte, error) {
 	// Get current status
 	status, err := ws.bridge.GetStatus()
 	if err != nil {
-		log.Printf("Error getting status for broadcast: %v", err)
-		return
+		return nil, fmt.Errorf("error getting status: %w", err)
 	}
 
 	// Get current spools
 	spools, err := ws.bridge.spoolman.GetAllSpools()
 	if err != nil {
-		log.Printf("Error getting spools for broadcast: %v", err)
+		webLog.Error("Error getting spools for broadcast", "error", err)
 		spools = []SpoolmanSpool{}
 	}
 
 	// Get print errors
 	printErrors := ws.bridge.GetPrintErrors()
 
+	filamentRunouts, err := ws.bridge.GetPendingFilamentRunouts()
+	if err != nil {
+		webLog.Error("Error getting filament runouts for broadcast", "error", err)
+		filamentRunouts = []FilamentRunout{}
+	}
+
+	unattributed, err := ws.bridge.GetUnattributedUsage()
+	if err != nil {
+		webLog.Error("Error getting unattributed usage for broadcast", "error", err)
+		unattributed = []UnattributedUsage{}
+	}
+
 	// Create message
 	message := WebSocketMessage{
 		Type:             "status_update",
@@ -237,35 +482,50 @@ func (ws *WebServer) BroadcastStatus() {
 		Spools:           spools,
 		ToolheadMappings: status.ToolheadMappings,
 		PrintErrors:      printErrors,
+		RunoutAlerts:     ws.bridge.runout.Predictions(),
+		ActiveSpools:     ws.bridge.runout.ActiveSpools(),
+		FilamentRunouts:  filamentRunouts,
+		Unattributed:     unattributed,
 	}
 
 	// Marshal to JSON
 	jsonData, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		return
+		return nil, fmt.Errorf("error marshaling WebSocket message: %w", err)
 	}
+	return jsonData, nil
+}
 
-	// Broadcast to all clients
-	select {
-	case ws.wsHub.broadcast <- jsonData:
-		log.Printf("Broadcasted status update to %d clients", len(ws.wsHub.clients))
-	default:
-		log.Printf("No clients connected to receive broadcast")
+// websocketOriginAllowed accepts websocket connections from FilaBridge's own pages: those
+// served from the requested host or the configured external URL. Clients that aren't
+// browsers send no Origin and are left to the access rules.
+func (ws *WebServer) websocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := neturl.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	if strings.EqualFold(parsed.Host, r.Host) {
+		return true
 	}
+	if snapshot := ws.bridge.GetConfigSnapshot(); snapshot != nil && strings.TrimSpace(snapshot.ExternalURL) != "" {
+		if external, err := neturl.Parse(strings.TrimSpace(snapshot.ExternalURL)); err == nil && strings.EqualFold(parsed.Host, external.Host) {
+			return true
+		}
+	}
+	return false
 }
 
 // websocketHandler handles WebSocket connections
 func (ws *WebServer) websocketHandler(c *gin.Context) {
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow connections from any origin
-		},
-	}
+	upgrader := websocket.Upgrader{CheckOrigin: ws.websocketOriginAllowed}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		webLog.Warn("WebSocket upgrade error", "error", err)
 		return
 	}
 
@@ -302,7 +562,7 @@ func (c *WebSocketClient) readPump() {
 		_, _, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				webLog.Warn("WebSocket error", "error", err)
 			}
 			break
 		}
@@ -372,6 +632,25 @@ func (ws *WebServer) dashboardHandler(c *gin.Context) {
 		spools = []SpoolmanSpool{}
 	}
 
+	// Printers using another Spoolman instance pick from its spools
+	printerSpools := make(map[string][]SpoolmanSpool)
+	instanceSpools := make(map[string][]SpoolmanSpool)
+	for printerID, printerConfig := range ws.bridge.config.Printers {
+		instance := ws.bridge.spoolmanInstanceOf(resolvePrinterName(printerConfig))
+		if instance == "" {
+			printerSpools[printerID] = spools
+			continue
+		}
+		if _, fetched := instanceSpools[instance]; !fetched {
+			instanceSpools[instance], err = ws.bridge.spoolmanFor(resolvePrinterName(printerConfig)).GetAllSpools()
+			if err != nil {
+				webLog.Warn("Failed to get spools from Spoolman instance", "instance", instance, "error", err)
+				instanceSpools[instance] = []SpoolmanSpool{}
+			}
+		}
+		printerSpools[printerID] = instanceSpools[instance]
+	}
+
 	// Check if this is a first run
 	isFirstRun, err := ws.bridge.IsFirstRun()
 	if err != nil {
@@ -384,9 +663,12 @@ func (ws *WebServer) dashboardHandler(c *gin.Context) {
 	printErrors := ws.bridge.GetPrintErrors()
 	hasPrintErrors := len(printErrors) > 0
 
+	_, loginEnabled := ws.bridge.accessControlEnabled()
+
 	c.HTML(http.StatusOK, "index.html", gin.H{
 		"Status":            status,
 		"Spools":            spools,
+		"PrinterSpools":     printerSpools,
 		"HasErrors":         hasErrors,
 		"HasPrintErrors":    hasPrintErrors,
 		"PrintErrors":       printErrors,
@@ -394,7 +676,8 @@ func (ws *WebServer) dashboardHandler(c *gin.Context) {
 		"Printers":          ws.bridge.config.Printers,
 		"SpoolmanConnected": spoolmanConnected,
 		"SpoolmanError":     spoolmanError,
-		"SpoolmanBaseURL":   ws.bridge.config.SpoolmanURL,
+		"SpoolmanBaseURL":   ws.spoolmanLinkBase(c),
+		"LoginEnabled":      loginEnabled,
 	})
 }
 
@@ -412,7 +695,7 @@ func hasConnectionErrors(status *PrinterStatus) bool {
 func (ws *WebServer) statusHandler(c *gin.Context) {
 	status, err := ws.bridge.GetStatus()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 	c.JSON(http.StatusOK, status)
@@ -420,19 +703,78 @@ func (ws *WebServer) statusHandler(c *gin.Context) {
 
 // spoolsHandler returns all spools as JSON
 func (ws *WebServer) spoolsHandler(c *gin.Context) {
-	spools, err := ws.bridge.spoolman.GetAllSpools()
+	spools, err := ws.bridge.spoolmanFor(c.Query("printer_name")).GetAllSpools()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
+		return
+	}
+
+	// Attach the defaults configured for each spool's material
+	if defaults, err := ws.bridge.GetAllMaterialDefaults(); err != nil {
+		webLog.Warn("Failed to get material defaults", "error", err)
+	} else if len(defaults) > 0 {
+		for i := range spools {
+			if md, exists := defaults[materialKey(spools[i].Material)]; exists {
+				spools[i].MaterialDefaults = &md
+			}
+		}
+	}
+
+	// Filter by owner if requested ("none" for unowned spools, "me" for the caller's)
+	owner, ok := ownerFilter(c)
+	if !ok {
 		return
 	}
+	ws.bridge.annotateSpoolOwners(spools)
+	ws.bridge.annotateSpoolReservations(c.Query("printer_name"), spools)
+	if owner != "" {
+		spools = filterSpoolsByOwner(spools, owner)
+	}
+
 	c.JSON(http.StatusOK, spools)
 }
 
+// spoolColorFamiliesHandler groups spools into coarse color families with counts and remaining weight.
+// Optional query parameters: material (e.g. "PLA") and family (e.g. "red") to narrow the result.
+func (ws *WebServer) spoolColorFamiliesHandler(c *gin.Context) {
+	spools, err := ws.bridge.spoolman.GetAllSpools()
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
+		return
+	}
+
+	// Filter by material if requested
+	if material := c.Query("material"); material != "" {
+		filtered := make([]SpoolmanSpool, 0, len(spools))
+		for _, spool := range spools {
+			if strings.EqualFold(spool.Material, material) {
+				filtered = append(filtered, spool)
+			}
+		}
+		spools = filtered
+	}
+
+	families := groupSpoolsByColorFamily(spools)
+
+	// Filter by family if requested
+	if family := strings.ToLower(c.Query("family")); family != "" {
+		filtered := make([]ColorFamily, 0, 1)
+		for _, f := range families {
+			if f.Family == family {
+				filtered = append(filtered, f)
+			}
+		}
+		families = filtered
+	}
+
+	c.JSON(http.StatusOK, ColorFamiliesResponse{Families: families})
+}
+
 // filamentsHandler returns all filament types as JSON
 func (ws *WebServer) filamentsHandler(c *gin.Context) {
-	filaments, err := ws.bridge.spoolman.GetAllFilaments()
+	filaments, err := ws.bridge.spoolmanFor(c.Query("printer_name")).GetAllFilaments()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 	c.JSON(http.StatusOK, filaments)
@@ -443,8 +785,11 @@ func validatePrinterConfig(config PrinterConfig) error {
 	if config.Name == "" {
 		return fmt.Errorf("printer name is required")
 	}
-	if config.IPAddress == "" {
-		return fmt.Errorf("address is required")
+	if config.IPAddress == "" && !config.usesPrusaConnect() {
+		return fmt.Errorf("address is required (or configure a Prusa Connect printer UUID and token)")
+	}
+	if (config.ConnectPrinterUUID == "") != (config.ConnectToken == "") {
+		return fmt.Errorf("prusa Connect requires both a printer UUID and a token")
 	}
 	if config.Toolheads < 1 {
 		return fmt.Errorf("toolheads must be at least 1")
@@ -452,14 +797,37 @@ func validatePrinterConfig(config PrinterConfig) error {
 	if config.Toolheads > 10 {
 		return fmt.Errorf("toolheads cannot exceed 10")
 	}
+	if config.Slots != 0 && (config.Slots < config.Toolheads || config.Slots > 20) {
+		return fmt.Errorf("slots must be between the number of toolheads and 20, or 0 for one per toolhead")
+	}
+	if config.PollInterval < 0 || config.ActivePollInterval < 0 || config.PrusaLinkTimeout < 0 || config.DownloadTimeout < 0 {
+		return fmt.Errorf("poll intervals and timeouts cannot be negative (use 0 for the global setting)")
+	}
+	if !validGcodeFlavor(config.GcodeFlavor) {
+		return fmt.Errorf("unknown G-code flavor %q (use bgcode, prusaslicer, cura, orcaslicer, klipper or leave empty to detect)", config.GcodeFlavor)
+	}
 	return nil
 }
 
-// validateAddress validates hostname or IP address format
+// validateAddress validates a printer address: a hostname or IP address with an optional
+// port, or a full http:// or https:// base URL
 func validateAddress(address string) error {
 	if address == "" {
 		return fmt.Errorf("address cannot be empty")
 	}
+	if strings.Contains(address, "://") {
+		if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+			return fmt.Errorf("invalid address format: only http:// and https:// URLs are supported")
+		}
+		parsed, err := neturl.Parse(address)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("invalid address format: %s is not a valid URL", address)
+		}
+		if parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("invalid address format: the URL can't have credentials, a query or a fragment")
+		}
+		address = parsed.Host
+	}
 	// Basic validation - check for reasonable length (hostnames can be longer than IPs)
 	// Minimum: 1 character (e.g., "a"), Maximum: 253 characters (RFC 1035)
 	if len(address) < 1 || len(address) > 253 {
@@ -487,17 +855,17 @@ func (ws *WebServer) mapToolheadHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
 		return
 	}
 
 	if req.PrinterName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Missing required parameters")
 		return
 	}
 
 	if req.ToolheadID < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Toolhead ID must be non-negative"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Toolhead ID must be non-negative")
 		return
 	}
 
@@ -505,22 +873,39 @@ func (ws *WebServer) mapToolheadHandler(c *gin.Context) {
 	if req.SpoolID == 0 {
 		// Unmap the toolhead
 		if err := ws.bridge.UnmapToolhead(req.PrinterName, req.ToolheadID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Toolhead unmapped successfully"})
+		c.JSON(http.StatusOK, MessageResponse{Message: "Toolhead unmapped successfully"})
 	} else {
+		// In reject mode a spool the toolhead's rules don't allow is refused before mapping
+		warnings, err := ws.bridge.checkToolheadRules(req.PrinterName, []ToolheadAssignment{{ToolheadID: req.ToolheadID, SpoolID: req.SpoolID}})
+		if err != nil {
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+			return
+		}
+
 		// Map the spool to the toolhead
 		if err := ws.bridge.SetToolheadMapping(req.PrinterName, req.ToolheadID, req.SpoolID); err != nil {
-			// Check if this is a spool conflict error
-			if strings.Contains(err.Error(), "is already assigned to") {
-				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			}
+			// Spool conflicts carry ErrCodeSpoolAlreadyAssigned and map to 409
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Toolhead mapped successfully"})
+		// Tag the mapping so prints from it are attributed to the member
+		member := callerMember(c)
+		if member != nil {
+			if err := ws.bridge.setToolheadMappedBy(req.PrinterName, req.ToolheadID, member.Name); err != nil {
+				webLog.Warn("Failed to record mapping member", "printer", req.PrinterName, "toolhead_id", req.ToolheadID, "error", err)
+			}
+		}
+
+		response := MappingResponse{Message: "Toolhead mapped successfully"}
+		if warning := ws.bridge.ownerMismatchWarning(req.PrinterName, req.SpoolID, member); warning != "" {
+			webLog.Warn("Member mapped another member's spool", "member", member.Name, "printer", req.PrinterName, "toolhead_id", req.ToolheadID, "spool_id", req.SpoolID, "warning", warning)
+			warnings = append(warnings, warning)
+		}
+		response.Warning = strings.Join(warnings, "; ")
+		c.JSON(http.StatusOK, response)
 	}
 }
 
@@ -530,33 +915,38 @@ func (ws *WebServer) availableSpoolsHandler(c *gin.Context) {
 	toolheadIDStr := c.Query("toolhead_id")
 
 	if printerName == "" || toolheadIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "printer_name and toolhead_id parameters are required"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "printer_name and toolhead_id parameters are required")
 		return
 	}
 
 	toolheadID, err := strconv.Atoi(toolheadIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid toolhead_id"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid toolhead_id")
 		return
 	}
 
-	// Get all spools from Spoolman
-	allSpools, err := ws.bridge.spoolman.GetAllSpools()
+	// Get all spools from the printer's Spoolman
+	allSpools, err := ws.bridge.spoolmanFor(printerName).GetAllSpools()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
 	// Get all current toolhead mappings
 	allMappings, err := ws.bridge.GetAllToolheadMappings()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
-	// Create a set of assigned spool IDs (excluding the current toolhead)
+	// Create a set of assigned spool IDs (excluding the current toolhead), counting only
+	// printers that use the same Spoolman instance
+	instance := ws.bridge.spoolmanInstanceOf(printerName)
 	assignedSpoolIDs := make(map[int]bool)
-	for _, printerMappings := range allMappings {
+	for mappedPrinter, printerMappings := range allMappings {
+		if ws.bridge.spoolmanInstanceOf(mappedPrinter) != instance {
+			continue
+		}
 		for tid, mapping := range printerMappings {
 			// Skip the current toolhead (allow re-assignment to the same toolhead)
 			if mapping.PrinterName == printerName && tid == toolheadID {
@@ -575,16 +965,43 @@ func (ws *WebServer) availableSpoolsHandler(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"spools": availableSpools})
+	owner, ok := ownerFilter(c)
+	if !ok {
+		return
+	}
+	ws.bridge.annotateSpoolOwners(availableSpools)
+	ws.bridge.annotateSpoolReservations(printerName, availableSpools)
+	if owner != "" {
+		availableSpools = filterSpoolsByOwner(availableSpools, owner)
+	}
+
+	c.JSON(http.StatusOK, SpoolsResponse{Spools: availableSpools})
 }
 
+// credentialConfigKeys are FilaBridge's own secrets, which are never returned, exported or
+// imported
+var credentialConfigKeys = []string{ConfigKeyAdminTokenHash, ConfigKeyActionLinkSecret, ConfigKeyAuthPasswordHash, ConfigKeyNFCTokenSecret}
+
+// hiddenConfigKeys are secrets that are never returned by the config API. Besides
+// FilaBridge's own credentials these are integration settings holding secrets, which are
+// still part of configuration exports.
+var hiddenConfigKeys = append(append([]string{}, credentialConfigKeys...),
+	ConfigKeyNotificationChannels, // Bot tokens and webhook URLs; see GET /api/notifications/channels
+	ConfigKeyOutgoingWebhooks,     // Signing secrets; see GET /api/webhooks/outgoing
+	ConfigKeySpoolmanInstances,    // Basic auth passwords; see GET /api/spoolman/instances
+	ConfigKeyMQTTPassword,
+)
+
 // getConfigHandler returns current configuration
 func (ws *WebServer) getConfigHandler(c *gin.Context) {
 	config, err := ws.bridge.GetAllConfig()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
+	for _, key := range hiddenConfigKeys {
+		delete(config, key)
+	}
 	c.JSON(http.StatusOK, config)
 }
 
@@ -592,14 +1009,32 @@ func (ws *WebServer) getConfigHandler(c *gin.Context) {
 func (ws *WebServer) updateConfigHandler(c *gin.Context) {
 	var config map[string]string
 	if err := c.ShouldBindJSON(&config); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
 		return
 	}
 
+	// Generated NFC and QR URLs are built on the external URL, so it must be absolute
+	if externalURL := strings.TrimSpace(config[ConfigKeyExternalURL]); externalURL != "" {
+		parsed, err := neturl.Parse(externalURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "external_url must be an http:// or https:// URL")
+			return
+		}
+	}
+
+	// Check every key before saving any, so a rejected key doesn't leave a partial update
+	for key, value := range config {
+		if override, ok := configEnvOverride(key); ok && value != override {
+			respondError(c, http.StatusConflict, ErrCodeConflict,
+				fmt.Sprintf("%s is set by the %s environment variable and can't be changed here", key, configEnvName(key)))
+			return
+		}
+	}
+
 	// Update each config value
 	for key, value := range config {
 		if err := ws.bridge.SetConfigValue(key, value); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 			return
 		}
 	}
@@ -607,35 +1042,94 @@ func (ws *WebServer) updateConfigHandler(c *gin.Context) {
 	// Reload configuration
 	newConfig, err := LoadConfig(ws.bridge)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	if err := ws.bridge.UpdateConfig(newConfig); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Configuration updated successfully"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Configuration updated successfully"})
+}
+
+// getNotificationChannelsHandler returns the configured notification channels
+func (ws *WebServer) getNotificationChannelsHandler(c *gin.Context) {
+	snapshot := ws.bridge.GetConfigSnapshot()
+	channels := []NotificationChannel{}
+	if snapshot != nil {
+		channels = snapshot.NotificationChannels
+	}
+	c.JSON(http.StatusOK, NotificationChannelsResponse{Channels: channels})
+}
+
+// updateNotificationChannelsHandler replaces the notification channel list
+func (ws *WebServer) updateNotificationChannelsHandler(c *gin.Context) {
+	var req struct {
+		Channels []NotificationChannel `json:"channels"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	value, err := json.Marshal(req.Channels)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	// Validate before saving so a bad channel doesn't disable all notifications
+	if _, err := parseNotificationChannels(string(value)); err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+		return
+	}
+	if !execHooksEnabled() {
+		for _, channel := range req.Channels {
+			if channel.Type == NotificationChannelExec {
+				respondError(c, http.StatusForbidden, ErrCodeForbidden,
+					fmt.Sprintf("Exec hooks are disabled; set %s=true in FilaBridge's environment to allow them", ExecHooksEnv))
+				return
+			}
+		}
+	}
+
+	if err := ws.bridge.SetConfigValue(ConfigKeyNotificationChannels, string(value)); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	if err := ws.bridge.ReloadConfig(); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Notification channels updated successfully"})
+}
+
+// getHeldNotificationsHandler returns notifications held for delivery after quiet hours
+func (ws *WebServer) getHeldNotificationsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, HeldNotificationsResponse{Held: ws.bridge.notifier.Held()})
 }
 
 // getAutoAssignPreviousSpoolHandler returns current auto-assign previous spool settings
 func (ws *WebServer) getAutoAssignPreviousSpoolHandler(c *gin.Context) {
 	enabled, err := ws.bridge.GetAutoAssignPreviousSpoolEnabled()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	location, err := ws.bridge.GetAutoAssignPreviousSpoolLocation()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"enabled":  enabled,
-		"location": location,
+	c.JSON(http.StatusOK, AutoAssignPreviousSpoolResponse{
+		Enabled:  enabled,
+		Location: location,
 	})
 }
 
@@ -647,42 +1141,53 @@ func (ws *WebServer) updateAutoAssignPreviousSpoolHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or missing 'enabled' field"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON or missing 'enabled' field")
 		return
 	}
 
 	// Update enabled setting
 	if err := ws.bridge.SetAutoAssignPreviousSpoolEnabled(req.Enabled); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	// Update location setting
 	if err := ws.bridge.SetAutoAssignPreviousSpoolLocation(req.Location); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Auto-assign previous spool settings updated successfully"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Auto-assign previous spool settings updated successfully"})
 }
 
 // getPrintersHandler returns all configured printers
 func (ws *WebServer) getPrintersHandler(c *gin.Context) {
 	printerConfigs, err := ws.bridge.GetAllPrinterConfigs()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	// Enhance printer configs with toolhead names
-	result := make(map[string]interface{})
+	result := make(map[string]PrinterResponse)
 	for printerID, printerConfig := range printerConfigs {
-		printerData := map[string]interface{}{
-			"name":       printerConfig.Name,
-			"model":      printerConfig.Model,
-			"ip_address": printerConfig.IPAddress,
-			"api_key":    printerConfig.APIKey,
-			"toolheads":  printerConfig.Toolheads,
+		printerData := PrinterResponse{
+			Name:               printerConfig.Name,
+			Model:              printerConfig.Model,
+			IPAddress:          printerConfig.IPAddress,
+			APIKey:             printerConfig.APIKey,
+			Toolheads:          printerConfig.Toolheads,
+			Slots:              printerConfig.Slots,
+			ConnectPrinterUUID: printerConfig.ConnectPrinterUUID,
+			ConnectToken:       printerConfig.ConnectToken,
+			GcodeFlavor:        printerConfig.GcodeFlavor,
+			PollInterval:       printerConfig.PollInterval,
+			ActivePollInterval: printerConfig.ActivePollInterval,
+			PrusaLinkTimeout:   printerConfig.PrusaLinkTimeout,
+			DownloadTimeout:    printerConfig.DownloadTimeout,
+			SpoolmanInstance:   printerConfig.SpoolmanInstance,
+			InsecureSkipVerify: printerConfig.InsecureSkipVerify,
+			Group:              printerConfig.Group,
 		}
 
 		// Get toolhead names for this printer
@@ -690,20 +1195,20 @@ func (ws *WebServer) getPrintersHandler(c *gin.Context) {
 		if err == nil {
 			// Build toolhead names map with defaults
 			toolheadNamesMap := make(map[int]string)
-			for toolheadID := 0; toolheadID < printerConfig.Toolheads; toolheadID++ {
+			for toolheadID := 0; toolheadID < printerConfig.SlotCount(); toolheadID++ {
 				if name, exists := toolheadNames[toolheadID]; exists {
 					toolheadNamesMap[toolheadID] = name
 				} else {
 					toolheadNamesMap[toolheadID] = fmt.Sprintf("Toolhead %d", toolheadID)
 				}
 			}
-			printerData["toolhead_names"] = toolheadNamesMap
+			printerData.ToolheadNames = toolheadNamesMap
 		}
 
 		result[printerID] = printerData
 	}
 
-	c.JSON(http.StatusOK, gin.H{"printers": result})
+	c.JSON(http.StatusOK, PrintersResponse{Printers: result})
 }
 
 // addPrinterHandler adds a new printer configuration
@@ -714,38 +1219,44 @@ func (ws *WebServer) addPrinterHandler(c *gin.Context) {
 
 	var printerConfig PrinterConfig
 	if err := c.ShouldBindJSON(&printerConfig); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
 	// Validate printer configuration
 	if err := validatePrinterConfig(printerConfig); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
-
-	// Validate address
-	if err := validateAddress(printerConfig.IPAddress); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := ws.bridge.validateSpoolmanInstance(printerConfig.SpoolmanInstance); err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
+	// Validate address (optional for Prusa Connect printers)
+	if printerConfig.IPAddress != "" {
+		if err := validateAddress(printerConfig.IPAddress); err != nil {
+			respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+			return
+		}
+	}
+
 	// Generate a unique printer ID using nanosecond timestamp + random component
 	printerID := fmt.Sprintf("printer_%d_%d", time.Now().UnixNano(), time.Now().Nanosecond()%1000)
 
 	// Save the printer configuration
 	if err := ws.bridge.SavePrinterConfig(printerID, printerConfig); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	// Reload configuration to include the new printer
 	if err := ws.reloadBridgeConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload configuration"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reload configuration")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Printer added successfully", "printer_id": printerID})
+	c.JSON(http.StatusOK, PrinterAddedResponse{Message: "Printer added successfully", PrinterID: printerID})
 }
 
 // updatePrinterHandler updates an existing printer configuration
@@ -758,62 +1269,73 @@ func (ws *WebServer) updatePrinterHandler(c *gin.Context) {
 
 	var printerConfig PrinterConfig
 	if err := c.ShouldBindJSON(&printerConfig); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
 	// Validate printer configuration
 	if err := validatePrinterConfig(printerConfig); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
-
-	// Validate address
-	if err := validateAddress(printerConfig.IPAddress); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := ws.bridge.validateSpoolmanInstance(printerConfig.SpoolmanInstance); err != nil {
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
+	// Validate address (optional for Prusa Connect printers)
+	if printerConfig.IPAddress != "" {
+		if err := validateAddress(printerConfig.IPAddress); err != nil {
+			respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
+			return
+		}
+	}
+
 	// Auto-detect model if address or API key changed, or if model is currently "Unknown"
-	if printerConfig.Model == "" || printerConfig.Model == ModelUnknown {
-		log.Printf("🔍 [Auto-Detection] Detecting model for printer %s (IP: %s)", printerID, printerConfig.IPAddress)
+	if (printerConfig.Model == "" || printerConfig.Model == ModelUnknown) && printerConfig.IPAddress != "" {
+		webLog.Info("Detecting printer model", "printer_id", printerID, "address", printerConfig.IPAddress)
 
 		// Create PrusaLink client for detection
-		client := NewPrusaLinkClient(printerConfig.IPAddress, printerConfig.APIKey, 10, 60) // Use default timeouts for detection
+		client := NewPrusaLinkClient(printerConfig.IPAddress, printerConfig.APIKey, 10, 60, printerConfig.InsecureSkipVerify) // Use default timeouts for detection
 
 		// Try to get printer info
 		printerInfo, err := client.GetPrinterInfo()
 		if err != nil {
-			log.Printf("⚠️ [Auto-Detection] Failed to detect model for %s: %v (keeping current model: %s)",
-				printerConfig.IPAddress, err, printerConfig.Model)
+			webLog.Warn("Failed to detect printer model, keeping current model",
+				"printer_id", printerID, "address", printerConfig.IPAddress, "model", printerConfig.Model, "error", err)
 		} else {
 			// Use shared model detection function
 			detectedModel := detectPrinterModel(printerInfo.Hostname)
 
 			if detectedModel != ModelUnknown {
-				log.Printf("✅ [Auto-Detection] Detected model for %s: '%s' -> %s",
-					printerConfig.IPAddress, printerInfo.Hostname, detectedModel)
+				webLog.Info("Detected printer model",
+					"printer_id", printerID, "address", printerConfig.IPAddress, "hostname", printerInfo.Hostname, "model", detectedModel)
 				printerConfig.Model = detectedModel
 			} else {
-				log.Printf("❌ [Auto-Detection] No pattern matched for hostname '%s' from %s",
-					printerInfo.Hostname, printerConfig.IPAddress)
+				webLog.Warn("No model pattern matched printer hostname",
+					"printer_id", printerID, "address", printerConfig.IPAddress, "hostname", printerInfo.Hostname)
 			}
 		}
 	}
 
+	// Spool IDs belong to a Spoolman instance, so mappings can't follow the printer to another one
+	if previous, exists := ws.bridge.GetConfigSnapshot().Printers[printerID]; exists && previous.SpoolmanInstance != printerConfig.SpoolmanInstance {
+		ws.bridge.unmapAllToolheads(resolvePrinterName(previous))
+	}
+
 	// Save the updated printer configuration
 	if err := ws.bridge.SavePrinterConfig(printerID, printerConfig); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	// Reload configuration to include the updated printer
 	if err := ws.reloadBridgeConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload configuration"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reload configuration")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Printer updated successfully"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Printer updated successfully"})
 }
 
 // deletePrinterHandler deletes a printer configuration
@@ -826,17 +1348,17 @@ func (ws *WebServer) deletePrinterHandler(c *gin.Context) {
 
 	// Delete the printer configuration
 	if err := ws.bridge.DeletePrinterConfig(printerID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	// Reload configuration to remove the deleted printer
 	if err := ws.reloadBridgeConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload configuration"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reload configuration")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Printer deleted successfully"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Printer deleted successfully"})
 }
 
 // getToolheadNamesHandler returns all toolhead names for a printer
@@ -846,26 +1368,26 @@ func (ws *WebServer) getToolheadNamesHandler(c *gin.Context) {
 	// Verify printer exists
 	printerConfigs, err := ws.bridge.GetAllPrinterConfigs()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	printerConfig, exists := printerConfigs[printerID]
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
 		return
 	}
 
 	// Get all toolhead names
 	toolheadNames, err := ws.bridge.GetAllToolheadNames(printerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	// Build response with all toolheads (including defaults for unnamed ones)
 	result := make(map[int]string)
-	for toolheadID := 0; toolheadID < printerConfig.Toolheads; toolheadID++ {
+	for toolheadID := 0; toolheadID < printerConfig.SlotCount(); toolheadID++ {
 		if name, exists := toolheadNames[toolheadID]; exists {
 			result[toolheadID] = name
 		} else {
@@ -873,7 +1395,7 @@ func (ws *WebServer) getToolheadNamesHandler(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"toolhead_names": result})
+	c.JSON(http.StatusOK, ToolheadNamesResponse{ToolheadNames: result})
 }
 
 // updateToolheadNameHandler updates a toolhead's display name
@@ -884,26 +1406,26 @@ func (ws *WebServer) updateToolheadNameHandler(c *gin.Context) {
 	// Parse toolhead ID
 	toolheadID, err := strconv.Atoi(toolheadIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid toolhead ID"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid toolhead ID")
 		return
 	}
 
 	// Verify printer exists
 	printerConfigs, err := ws.bridge.GetAllPrinterConfigs()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
 	printerConfig, exists := printerConfigs[printerID]
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
 		return
 	}
 
 	// Validate toolhead ID is within range
-	if toolheadID < 0 || toolheadID >= printerConfig.Toolheads {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Toolhead ID must be between 0 and %d", printerConfig.Toolheads-1)})
+	if toolheadID < 0 || toolheadID >= printerConfig.SlotCount() {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Toolhead ID must be between 0 and %d", printerConfig.SlotCount()-1))
 		return
 	}
 
@@ -912,17 +1434,17 @@ func (ws *WebServer) updateToolheadNameHandler(c *gin.Context) {
 		Name string `json:"name" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or missing 'name' field"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON or missing 'name' field")
 		return
 	}
 
 	// Update toolhead name
 	if err := ws.bridge.SetToolheadName(printerID, toolheadID, req.Name); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Toolhead name updated successfully"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Toolhead name updated successfully"})
 }
 
 // detectPrinterModel detects printer model from hostname
@@ -931,98 +1453,100 @@ func detectPrinterModel(hostname string) string {
 	hostnameLower := strings.ToLower(hostname)
 	hostnameLower = strings.TrimSpace(hostnameLower) // Clean up any whitespace
 
-	log.Printf("🔍 [Detection] Checking hostname '%s' against patterns:", hostnameLower)
+	webLog.Debug("Checking hostname against model patterns", "hostname", hostnameLower)
 
 	if strings.Contains(hostnameLower, ModelCorePattern) {
 		model = ModelCoreOne
-		log.Printf("✅ [Detection] Matched pattern '%s' -> %s", ModelCorePattern, model)
+		webLog.Debug("Matched model pattern", "pattern", ModelCorePattern, "model", model)
 	} else if strings.Contains(hostnameLower, ModelXLPattern) {
 		model = ModelXL
-		log.Printf("✅ [Detection] Matched pattern '%s' -> %s", ModelXLPattern, model)
+		webLog.Debug("Matched model pattern", "pattern", ModelXLPattern, "model", model)
 	} else if strings.Contains(hostnameLower, ModelMK4Pattern) {
 		model = ModelMK4
-		log.Printf("✅ [Detection] Matched pattern '%s' -> %s", ModelMK4Pattern, model)
+		webLog.Debug("Matched model pattern", "pattern", ModelMK4Pattern, "model", model)
 	} else if strings.Contains(hostnameLower, ModelMK3Pattern) {
 		model = ModelMK35
-		log.Printf("✅ [Detection] Matched pattern '%s' -> %s", ModelMK3Pattern, model)
+		webLog.Debug("Matched model pattern", "pattern", ModelMK3Pattern, "model", model)
 	} else if strings.Contains(hostnameLower, ModelMiniPattern) {
 		model = ModelMiniPlus
-		log.Printf("✅ [Detection] Matched pattern '%s' -> %s", ModelMiniPattern, model)
+		webLog.Debug("Matched model pattern", "pattern", ModelMiniPattern, "model", model)
 	} else {
-		log.Printf("❌ [Detection] No pattern matched for hostname '%s'. Available patterns: %s, %s, %s, %s, %s",
-			hostnameLower, ModelCorePattern, ModelXLPattern, ModelMK4Pattern, ModelMK3Pattern, ModelMiniPattern)
+		webLog.Debug("No model pattern matched hostname", "hostname", hostnameLower,
+			"patterns", []string{ModelCorePattern, ModelXLPattern, ModelMK4Pattern, ModelMK3Pattern, ModelMiniPattern})
 	}
 
-	log.Printf("🎯 [Detection] Final result: hostname='%s' -> model='%s'", hostname, model)
+	webLog.Debug("Model detection result", "hostname", hostname, "model", model)
 	return model
 }
 
 // detectPrinterHandler detects printer model from PrusaLink API
 func (ws *WebServer) detectPrinterHandler(c *gin.Context) {
 	var req struct {
-		IPAddress string `json:"ip_address" binding:"required"`
-		APIKey    string `json:"api_key" binding:"required"`
+		IPAddress          string `json:"ip_address" binding:"required"`
+		APIKey             string `json:"api_key" binding:"required"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
 		return
 	}
 
 	// Validate address
 	if err := validateAddress(req.IPAddress); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
-	log.Printf("🔍 [Detection] Starting printer model detection for IP: %s", req.IPAddress)
+	webLog.Info("Starting printer model detection", "address", req.IPAddress)
 
 	// Create PrusaLink client
-	client := NewPrusaLinkClient(req.IPAddress, req.APIKey, 10, 60) // Use default timeouts for detection
+	client := NewPrusaLinkClient(req.IPAddress, req.APIKey, 10, 60, req.InsecureSkipVerify) // Use default timeouts for detection
 
 	// Try to get printer info, but don't fail if it times out
 	printerInfo, err := client.GetPrinterInfo()
 	if err != nil {
-		log.Printf("❌ [Detection] Failed to get printer info from %s: %v", req.IPAddress, err)
+		webLog.Warn("Failed to get printer info for model detection", "address", req.IPAddress, "error", err)
 		// If API call fails, return default values instead of error
 		// This allows users to add printers even if they're offline
-		c.JSON(http.StatusOK, gin.H{
-			"model":    ModelUnknown,
-			"hostname": "Unknown",
-			"detected": false,
-			"warning":  "Could not connect to printer. You can still add it manually.",
+		c.JSON(http.StatusOK, DetectedPrinterResponse{
+			Model:    ModelUnknown,
+			Hostname: "Unknown",
+			Detected: false,
+			Warning:  "Could not connect to printer. You can still add it manually.",
 		})
 		return
 	}
 
-	log.Printf("📥 [Detection] Received printer info: hostname='%s'", printerInfo.Hostname)
+	webLog.Debug("Received printer info", "address", req.IPAddress, "hostname", printerInfo.Hostname)
 
 	// Use shared model detection function
 	model := detectPrinterModel(printerInfo.Hostname)
 
 	// Return detected information (toolheads will be provided by user)
-	c.JSON(http.StatusOK, gin.H{
-		"model":    model,
-		"hostname": printerInfo.Hostname,
-		"detected": true,
+	c.JSON(http.StatusOK, DetectedPrinterResponse{
+		Model:    model,
+		Hostname: printerInfo.Hostname,
+		MMU:      printerInfo.MMU,
+		Detected: true,
 	})
 }
 
 // testSpoolmanConnectionHandler tests the connection to Spoolman
 func (ws *WebServer) testSpoolmanConnectionHandler(c *gin.Context) {
 	if err := ws.bridge.spoolman.TestConnection(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "connected": false})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "code": ErrCodeSpoolmanError, "connected": false})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Connection successful", "connected": true})
+	c.JSON(http.StatusOK, SpoolmanConnectionResponse{Message: "Connection successful", Connected: true})
 }
 
 // debugSpoolmanHandler provides detailed debug information about Spoolman data
 func (ws *WebServer) debugSpoolmanHandler(c *gin.Context) {
 	spools, err := ws.bridge.spoolman.GetAllSpools()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
@@ -1054,13 +1578,14 @@ func (ws *WebServer) debugSpoolmanHandler(c *gin.Context) {
 // testPrintCompleteHandler simulates a print completion for testing
 func (ws *WebServer) testPrintCompleteHandler(c *gin.Context) {
 	var request struct {
-		PrinterName   string          `json:"printer_name" binding:"required"`
-		JobName       string          `json:"job_name"`
-		FilamentUsage map[int]float64 `json:"filament_usage"`
+		PrinterName     string          `json:"printer_name" binding:"required"`
+		JobName         string          `json:"job_name"`
+		FilamentUsage   map[int]float64 `json:"filament_usage"`
+		FilamentLengths map[int]float64 `json:"filament_lengths"` // mm, for toolheads without a weight in filament_usage
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
@@ -1069,7 +1594,7 @@ func (ws *WebServer) testPrintCompleteHandler(c *gin.Context) {
 	}
 
 	// If no filament usage provided, use default test values
-	if len(request.FilamentUsage) == 0 {
+	if len(request.FilamentUsage) == 0 && len(request.FilamentLengths) == 0 {
 		request.FilamentUsage = map[int]float64{
 			0: 10.0, // 10g for toolhead 0
 		}
@@ -1094,7 +1619,7 @@ func (ws *WebServer) testPrintCompleteHandler(c *gin.Context) {
 	}
 
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
 		return
 	}
 
@@ -1102,24 +1627,202 @@ func (ws *WebServer) testPrintCompleteHandler(c *gin.Context) {
 	printerName := resolvePrinterName(config)
 
 	// Process filament usage using helper function
-	if err := ws.bridge.processFilamentUsage(printerName, request.FilamentUsage, request.JobName); err != nil {
-		log.Printf("Error processing filament usage: %v", err)
+	if err := ws.bridge.processFilamentUsage(printerName, request.FilamentUsage, request.FilamentLengths, nil, request.JobName, "", HistorySourcePrint); err != nil {
+		webLog.Error("Error processing filament usage", "printer", printerName, "job", request.JobName, "error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":        "Print completion simulated successfully",
-		"printer":        request.PrinterName,
-		"job":            request.JobName,
-		"filament_usage": request.FilamentUsage,
+	c.JSON(http.StatusOK, SimulatedPrintResponse{
+		Message:       "Print completion simulated successfully",
+		Printer:       request.PrinterName,
+		Job:           request.JobName,
+		FilamentUsage: request.FilamentUsage,
 	})
 }
 
+// historyLimit parses the optional "limit" query parameter
+func historyLimit(c *gin.Context) int {
+	limit := DefaultPrintHistoryLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed >= 0 {
+			limit = parsed
+		}
+	}
+	return limit
+}
+
+// getPrintHistoryHandler returns print history, optionally filtered by printer_name
+func (ws *WebServer) getPrintHistoryHandler(c *gin.Context) {
+	history, err := ws.bridge.GetPrintHistory(PrintHistoryFilter{
+		PrinterName: c.Query("printer_name"),
+		Limit:       historyLimit(c),
+	})
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, PrintHistoryResponse{History: history})
+}
+
+// getSpoolHistoryHandler returns the print history of a single spool, including notes and ratings
+func (ws *WebServer) getSpoolHistoryHandler(c *gin.Context) {
+	spoolID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || spoolID <= 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid spool ID")
+		return
+	}
+
+	history, err := ws.bridge.GetPrintHistory(PrintHistoryFilter{
+		SpoolID: spoolID,
+		Limit:   historyLimit(c),
+	})
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	c.JSON(http.StatusOK, SpoolHistoryResponse{SpoolID: spoolID, History: history})
+}
+
+// updatePrintHistoryHandler sets notes and a success rating on a print history entry
+func (ws *WebServer) updatePrintHistoryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid history ID")
+		return
+	}
+
+	var req struct {
+		Notes  string `json:"notes"`
+		Rating int    `json:"rating"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	// Members may only annotate prints attributed to them
+	if member := callerMember(c); member != nil {
+		existing, err := ws.bridge.GetPrintHistoryEntry(id)
+		if err != nil {
+			respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+			return
+		}
+		if existing.Member != member.Name {
+			respondError(c, http.StatusForbidden, ErrCodeForbidden, "Members can only update their own prints")
+			return
+		}
+	}
+
+	entry, err := ws.bridge.UpdatePrintHistoryNotes(id, req.Notes, req.Rating)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, HistoryEntryResponse{Message: "History entry updated successfully", Entry: entry})
+}
+
+// adjustPrintHistoryHandler corrects the spool or filament amount of a recorded print
+func (ws *WebServer) adjustPrintHistoryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid history ID")
+		return
+	}
+
+	var adjustment HistoryAdjustment
+	if err := c.ShouldBindJSON(&adjustment); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if adjustment.SpoolID == nil && adjustment.FilamentUsed == nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "spool_id or filament_used is required")
+		return
+	}
+
+	entry, err := ws.bridge.AdjustPrintHistory(id, adjustment)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, HistoryEntryResponse{Message: "History entry adjusted successfully", Entry: entry})
+}
+
+// revertPrintHistoryHandler takes a recorded print's usage back out of Spoolman
+func (ws *WebServer) revertPrintHistoryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid history ID")
+		return
+	}
+
+	// The reason is optional, so an empty body is fine
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+			return
+		}
+	}
+
+	entry, err := ws.bridge.RevertPrintHistory(id, req.Reason)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, HistoryEntryResponse{Message: "History entry reverted successfully", Entry: entry})
+}
+
+// reassignPrintHistoryHandler moves a recorded print's usage to another spool
+func (ws *WebServer) reassignPrintHistoryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid history ID")
+		return
+	}
+
+	var req struct {
+		SpoolID int    `json:"spool_id" binding:"required"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "spool_id is required")
+		return
+	}
+
+	entry, err := ws.bridge.ReassignPrintHistory(id, req.SpoolID, req.Reason)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, HistoryEntryResponse{Message: "History entry reassigned successfully", Entry: entry})
+}
+
+// getHistoryCorrectionsHandler returns the correction audit trail of a history entry
+func (ws *WebServer) getHistoryCorrectionsHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid history ID")
+		return
+	}
+
+	corrections, err := ws.bridge.GetHistoryCorrections(id)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, HistoryCorrectionsResponse{HistoryID: id, Corrections: corrections})
+}
+
 // getPrintErrorsHandler returns all unacknowledged print errors
 func (ws *WebServer) getPrintErrorsHandler(c *gin.Context) {
 	errors := ws.bridge.GetPrintErrors()
-	c.JSON(http.StatusOK, gin.H{
-		"errors": errors,
-	})
+	c.JSON(http.StatusOK, PrintErrorsResponse{Errors: errors})
 }
 
 // acknowledgePrintErrorHandler acknowledges a print error
@@ -1127,23 +1830,23 @@ func (ws *WebServer) acknowledgePrintErrorHandler(c *gin.Context) {
 	// Ensure we always return JSON
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Panic in acknowledgePrintErrorHandler: %v", r)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			webLog.Error("Panic in acknowledgePrintErrorHandler", "panic", r)
+			respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
 		}
 	}()
 
 	errorID := c.Param("id")
 	if errorID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Error ID is required"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Error ID is required")
 		return
 	}
 
 	if err := ws.bridge.AcknowledgePrintError(errorID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusNotFound, ErrCodeNotFound, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Error acknowledged"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Error acknowledged"})
 }
 
 // reloadBridgeConfig reloads the bridge configuration after changes
@@ -1155,51 +1858,80 @@ func (ws *WebServer) reloadBridgeConfig() error {
 	return nil
 }
 
-// Start starts the web server
-func (ws *WebServer) Start(port string) error {
-	return ws.router.Run(":" + port)
-}
-
 // nfcAssignHandler handles NFC tag scans
 func (ws *WebServer) nfcAssignHandler(c *gin.Context) {
 	spoolIDStr := c.Query("spool")
+	if spoolIDStr == "" {
+		// Codes from other tools, e.g. Spoolman label QR codes or OpenSpool tag payloads
+		spoolIDStr = c.Query("code")
+	}
 	locationStr := c.Query("location")
-	clientIP := getClientIP(c.ClientIP())
-
-	// Generate session ID based on client IP
-	sessionID := generateSessionID(clientIP)
+	// Scans from the same device share a session through its cookie
+	sessionID := ws.nfcSessionID(c)
+	if sessionID == "" {
+		newID, err := newNFCSessionID()
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "nfc_error.html", gin.H{
+				"Error": "Failed to create session: " + err.Error(),
+			})
+			return
+		}
+		sessionID = newID
+	}
+	c.SetCookie(NFCSessionCookie, sessionID, int(NFCSessionCookieMaxAge/time.Second), ws.path("/"), "", c.Request.TLS != nil, true)
 
 	var spoolID int
 	var printerName string
 	var toolheadID int
 	var err error
+	var locationName string
+	var isPrinterLocation bool
 
-	// Parse parameters
-	if spoolIDStr != "" {
-		spoolID, err = strconv.Atoi(spoolIDStr)
+	// Parse parameters; the location goes first, since its printer picks the Spoolman instance of the spool
+	if locationStr != "" {
+		printerName, toolheadID, locationName, isPrinterLocation, err = ws.bridge.parseLocationParam(locationStr)
 		if err != nil {
 			c.HTML(http.StatusBadRequest, "nfc_error.html", gin.H{
-				"Error": "Invalid spool ID",
+				"Error": err.Error(),
 			})
 			return
 		}
 	}
 
-	var locationName string
-	var isPrinterLocation bool
+	// A spool scanned on its own belongs to the printer whose toolhead the session already holds
+	scanPrinter := printerName
+	if locationStr == "" {
+		if existing, err := ws.bridge.getSession(sessionID); err == nil && existing.IsPrinterLocation {
+			scanPrinter = existing.PrinterName
+		}
+	}
 
-	if locationStr != "" {
-		printerName, toolheadID, locationName, isPrinterLocation, err = ws.bridge.parseLocationParam(locationStr)
+	// A manufacturer barcode is a spool still to be added to Spoolman
+	if c.Query("spool") == "" && isProductBarcode(spoolIDStr) {
+		ws.nfcBarcodePage(c, spoolIDStr, scanPrinter)
+		return
+	}
+
+	spoolInstance := ws.bridge.spoolmanInstanceOf(scanPrinter)
+	if spoolIDStr != "" {
+		spoolID, err = ws.bridge.resolveSpoolCode(scanPrinter, spoolIDStr)
 		if err != nil {
-			c.HTML(http.StatusBadRequest, "nfc_error.html", gin.H{
+			c.HTML(httpStatusForCode(errorCode(err, ErrCodeInvalidRequest), http.StatusBadGateway), "nfc_error.html", gin.H{
 				"Error": err.Error(),
 			})
 			return
 		}
+
+		scan := SpoolEvent{SpoolmanInstance: spoolInstance, SpoolID: spoolID,
+			EventType: SpoolEventNFCScan, CreatedAt: time.Now()}
+		if caller := callerMember(c); caller != nil {
+			scan.Member = caller.Name
+		}
+		ws.bridge.logSpoolEvents(scan)
 	}
 
 	// Create or update session
-	session, err := ws.bridge.createOrUpdateSession(sessionID, spoolID, printerName, toolheadID, locationName, isPrinterLocation)
+	session, err := ws.bridge.createOrUpdateSession(sessionID, spoolID, spoolInstance, printerName, toolheadID, locationName, isPrinterLocation)
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "nfc_error.html", gin.H{
 			"Error": "Failed to create session: " + err.Error(),
@@ -1209,9 +1941,32 @@ func (ws *WebServer) nfcAssignHandler(c *gin.Context) {
 
 	// Check if session is complete
 	if session.isSessionComplete() {
+		// Toolhead rules are checked as for mappings made in the web interface
+		if session.IsPrinterLocation {
+			// Spool IDs only mean something in their own instance; the session stays open for a rescan
+			if instance := ws.bridge.spoolmanInstanceOf(session.PrinterName); instance != session.SpoolmanInstance {
+				c.HTML(http.StatusConflict, "nfc_error.html", gin.H{
+					"Error": fmt.Sprintf("Spool %d was scanned in another Spoolman instance than printer %s uses. Scan the spool tag again to look it up there.", session.SpoolID, session.PrinterName),
+				})
+				return
+			}
+			warnings, err := ws.bridge.checkToolheadRules(session.PrinterName, []ToolheadAssignment{{ToolheadID: session.ToolheadID, SpoolID: session.SpoolID}})
+			if err != nil {
+				ws.broadcastNFCSession(NFCSessionFailed, session)
+				c.HTML(http.StatusConflict, "nfc_error.html", gin.H{
+					"Error": "Assignment refused: " + err.Error(),
+				})
+				return
+			}
+			for _, warning := range warnings {
+				webLog.Warn("NFC assignment breaks toolhead rule", "printer", session.PrinterName, "toolhead_id", session.ToolheadID, "spool_id", session.SpoolID, "warning", warning)
+			}
+		}
+
 		// Complete the assignment
 		err = ws.bridge.AssignSpoolToLocation(session.SpoolID, session.PrinterName, session.ToolheadID, session.LocationName, session.IsPrinterLocation)
 		if err != nil {
+			ws.broadcastNFCSession(NFCSessionFailed, session)
 			c.HTML(http.StatusInternalServerError, "nfc_error.html", gin.H{
 				"Error": "Assignment failed: " + err.Error(),
 			})
@@ -1223,6 +1978,7 @@ func (ws *WebServer) nfcAssignHandler(c *gin.Context) {
 
 		// Clean up session
 		ws.bridge.deleteSession(sessionID)
+		ws.broadcastNFCSession(NFCSessionCompleted, session)
 
 		// Show success page
 		c.HTML(http.StatusOK, "nfc_success.html", gin.H{
@@ -1248,12 +2004,14 @@ func (ws *WebServer) nfcAssignHandler(c *gin.Context) {
 	} else {
 		message = "Session started. Scan a spool or location tag."
 	}
+	ws.broadcastNFCSession(NFCSessionUpdated, session)
 
 	c.HTML(http.StatusOK, "nfc_progress.html", gin.H{
 		"Message":     message,
 		"SessionID":   sessionID,
 		"HasSpool":    session.HasSpool,
 		"HasLocation": session.HasLocation,
+		"ExpiresAt":   session.ExpiresAt.Format(time.RFC3339),
 	})
 }
 
@@ -1264,13 +2022,13 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 	// Get all spools
 	spools, err := ws.bridge.spoolman.GetAllSpools()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
 	// Generate spool URLs
 	for _, spool := range spools {
-		url := fmt.Sprintf("http://%s/api/nfc/assign?spool=%d", c.Request.Host, spool.ID)
+		url := ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?spool=%d", ws.publicBaseURL(c), spool.ID))
 
 		// Safely get color hex
 		colorHex := ""
@@ -1285,7 +2043,7 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 		// Generate QR code
 		qrCode, err := qrcode.Encode(url, qrcode.Medium, 256)
 		if err != nil {
-			log.Printf("Error generating QR code for spool %d: %v", spool.ID, err)
+			webLog.Error("Error generating QR code", "spool_id", spool.ID, "error", err)
 			// Continue without QR code if generation fails
 			urls = append(urls, gin.H{
 				"type":             "spool",
@@ -1316,15 +2074,16 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 	}
 
 	// Get all filaments
-	filaments, err := ws.bridge.spoolman.GetAllFilaments()
+	filaments, err := ws.bridge.spoolmanFor(c.Query("printer_name")).GetAllFilaments()
 	if err != nil {
-		log.Printf("Warning: Failed to get filaments for NFC URLs: %v", err)
+		webLog.Warn("Failed to get filaments for NFC URLs", "error", err)
 		filaments = []SpoolmanFilament{}
 	}
 
 	// Generate filament URLs
+	spoolmanLinkBase := ws.spoolmanLinkBase(c)
 	for _, filament := range filaments {
-		url := fmt.Sprintf("%s/filament/show/%d", ws.bridge.config.SpoolmanURL, filament.ID)
+		url := fmt.Sprintf("%s/filament/show/%d", spoolmanLinkBase, filament.ID)
 
 		// Safely get color hex
 		colorHex := ""
@@ -1345,7 +2104,7 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 		// Generate QR code
 		qrCode, err := qrcode.Encode(url, qrcode.Medium, 256)
 		if err != nil {
-			log.Printf("Error generating QR code for filament %d: %v", filament.ID, err)
+			webLog.Error("Error generating QR code", "filament_id", filament.ID, "error", err)
 			// Continue without QR code if generation fails
 			urls = append(urls, gin.H{
 				"type":           "filament",
@@ -1384,24 +2143,25 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 	// Get Spoolman locations
 	spoolmanLocations, err := ws.bridge.spoolman.GetLocations()
 	if err != nil {
-		log.Printf("Warning: Failed to get Spoolman locations: %v", err)
+		webLog.Warn("Failed to get Spoolman locations", "error", err)
 		spoolmanLocations = []SpoolmanLocation{}
 	}
 
 	// Get printer configurations to build a map of printer toolhead location names
 	printerConfigs, err := ws.bridge.GetAllPrinterConfigs()
 	if err != nil {
-		log.Printf("Warning: Failed to get printer configurations: %v", err)
+		webLog.Warn("Failed to get printer configurations", "error", err)
 		printerConfigs = make(map[string]PrinterConfig)
 	}
 
 	printerLocationNames := make(map[string]bool)
+	var toolheadURLs []gin.H
 	for printerID, printerConfig := range printerConfigs {
 		toolheadNames, err := ws.bridge.GetAllToolheadNames(printerID)
 		if err != nil {
 			toolheadNames = make(map[int]string)
 		}
-		for toolheadID := 0; toolheadID < printerConfig.Toolheads; toolheadID++ {
+		for toolheadID := 0; toolheadID < printerConfig.SlotCount(); toolheadID++ {
 			var displayName string
 			if name, exists := toolheadNames[toolheadID]; exists {
 				displayName = name
@@ -1410,13 +2170,33 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 			}
 			locationName := fmt.Sprintf("%s - %s", printerConfig.Name, displayName)
 			printerLocationNames[locationName] = true
+
+			// Toolhead tags open the quick-map page of spools suggested for the toolhead
+			quickMapURL := ws.toolheadQuickMapURL(c, locationName)
+			qrCodeBase64 := ""
+			if qrCode, err := qrcode.Encode(quickMapURL, qrcode.Medium, 256); err != nil {
+				webLog.Error("Error generating QR code", "location", locationName, "error", err)
+			} else {
+				qrCodeBase64 = base64.StdEncoding.EncodeToString(qrCode)
+			}
+			toolheadURLs = append(toolheadURLs, gin.H{
+				"type":           "location",
+				"location_type":  "printer",
+				"location_name":  locationName,
+				"display_name":   locationName,
+				"url":            quickMapURL,
+				"qr_code_base64": qrCodeBase64,
+				"is_local_only":  false,
+				"description":    "Opens the spools suggested for this toolhead; tap one to map it",
+			})
 		}
 	}
+	urls = append(urls, toolheadURLs...)
 
-	// Generate location URLs for Spoolman locations only (no virtual printer toolhead locations)
+	// Generate location URLs for Spoolman locations
 	for _, location := range spoolmanLocations {
-		// Skip archived locations
-		if location.Archived {
+		// Skip archived locations, and any named like a toolhead, which already has its tag
+		if location.Archived || printerLocationNames[location.Name] {
 			continue
 		}
 
@@ -1426,12 +2206,12 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 		}
 
 		locationParam := location.Name
-		nfcUrl := fmt.Sprintf("http://%s/api/nfc/assign?location=%s", c.Request.Host, neturl.QueryEscape(locationParam))
+		nfcUrl := ws.nfcScanURL(fmt.Sprintf("%s/api/nfc/assign?location=%s", ws.publicBaseURL(c), neturl.QueryEscape(locationParam)))
 
 		// Generate QR code
 		qrCode, err := qrcode.Encode(nfcUrl, qrcode.Medium, 256)
 		if err != nil {
-			log.Printf("Error generating QR code for Spoolman location %s: %v", locationParam, err)
+			webLog.Error("Error generating QR code", "location", locationParam, "error", err)
 			// Continue without QR code if generation fails
 			urls = append(urls, gin.H{
 				"type":           "location",
@@ -1523,39 +2303,108 @@ func (ws *WebServer) nfcUrlsHandler(c *gin.Context) {
 	})
 
 	// Get Spoolman URL for the response
-	spoolmanURL := ws.bridge.spoolman.GetBaseURL()
+	spoolmanURL := ws.spoolmanLinkBase(c)
 
-	c.JSON(http.StatusOK, gin.H{
-		"urls":         urls,
-		"spoolman_url": spoolmanURL,
+	c.JSON(http.StatusOK, NFCURLsResponse{
+		URLs:        urls,
+		SpoolmanURL: spoolmanURL,
 	})
 }
 
 // nfcSessionStatusHandler returns the current session status
 func (ws *WebServer) nfcSessionStatusHandler(c *gin.Context) {
-	clientIP := getClientIP(c.ClientIP())
-	sessionID := generateSessionID(clientIP)
+	sessionID := ws.nfcSessionID(c)
+	if sessionID == "" {
+		c.JSON(http.StatusOK, NFCSessionStatusResponse{Active: false})
+		return
+	}
 
 	session, err := ws.bridge.getSession(sessionID)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
-			"active": false,
-		})
+		c.JSON(http.StatusOK, NFCSessionStatusResponse{Active: false})
+		return
+	}
+
+	c.JSON(http.StatusOK, NFCSessionStatusResponse{
+		Active: true,
+		NFCSessionDetails: &NFCSessionDetails{
+			SessionID:         session.SessionID,
+			HasSpool:          session.HasSpool,
+			HasLocation:       session.HasLocation,
+			SpoolID:           session.SpoolID,
+			PrinterName:       session.PrinterName,
+			ToolheadID:        session.ToolheadID,
+			LocationName:      session.LocationName,
+			IsPrinterLocation: session.IsPrinterLocation,
+			ExpiresAt:         session.ExpiresAt,
+		},
+	})
+}
+
+// nfcSessionID returns the caller's NFC session ID from the session query parameter, for
+// clients that don't keep cookies, or the session cookie. Empty when the caller has neither.
+func (ws *WebServer) nfcSessionID(c *gin.Context) string {
+	if sessionID := strings.TrimSpace(c.Query("session")); sessionID != "" {
+		return sessionID
+	}
+	if cookie, err := c.Cookie(NFCSessionCookie); err == nil {
+		return strings.TrimSpace(cookie)
+	}
+	return ""
+}
+
+// cancelNFCSessionHandler ends the caller's NFC session before it expires
+func (ws *WebServer) cancelNFCSessionHandler(c *gin.Context) {
+	sessionID := ws.nfcSessionID(c)
+	if sessionID == "" {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "No active NFC session")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"active":              true,
-		"session_id":          session.SessionID,
-		"has_spool":           session.HasSpool,
-		"has_location":        session.HasLocation,
-		"spool_id":            session.SpoolID,
-		"printer_name":        session.PrinterName,
-		"toolhead_id":         session.ToolheadID,
-		"location_name":       session.LocationName,
-		"is_printer_location": session.IsPrinterLocation,
-		"expires_at":          session.ExpiresAt,
-	})
+	session, err := ws.bridge.getSession(sessionID)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "No active NFC session")
+		return
+	}
+	if err := ws.bridge.deleteSession(sessionID); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	ws.broadcastNFCSession(NFCSessionCancelled, session)
+	c.JSON(http.StatusOK, MessageResponse{Message: "NFC session cancelled"})
+}
+
+// broadcastNFCSession tells connected dashboards that an NFC session changed. The session
+// ID is left out since it lets the holder act on the session.
+func (ws *WebServer) broadcastNFCSession(event string, session *NFCSession) {
+	message := gin.H{
+		"type":      "nfc_session",
+		"timestamp": time.Now(),
+		"event":     event,
+		"session": gin.H{
+			"has_spool":           session.HasSpool,
+			"has_location":        session.HasLocation,
+			"spool_id":            session.SpoolID,
+			"printer_name":        session.PrinterName,
+			"toolhead_id":         session.ToolheadID,
+			"location_name":       session.LocationName,
+			"is_printer_location": session.IsPrinterLocation,
+			"expires_at":          session.ExpiresAt,
+		},
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		webLog.Error("Error marshaling NFC session event", "error", err)
+		return
+	}
+
+	select {
+	case ws.wsHub.broadcast <- jsonData:
+	default:
+		webLog.Debug("No clients connected to receive NFC session event")
+	}
 }
 
 // Location Management Handlers
@@ -1565,12 +2414,12 @@ func (ws *WebServer) getLocationsHandler(c *gin.Context) {
 	// Get Spoolman locations
 	spoolmanLocations, err := ws.bridge.spoolman.GetLocations()
 	if err != nil {
-		log.Printf("Warning: Failed to get Spoolman locations: %v", err)
+		webLog.Warn("Failed to get Spoolman locations", "error", err)
 		spoolmanLocations = []SpoolmanLocation{}
 	}
 
 	// Only return Spoolman locations (no virtual printer toolhead locations)
-	var allLocations []gin.H
+	var allLocations []LocationSummary
 	for _, loc := range spoolmanLocations {
 		// Skip archived locations
 		if loc.Archived {
@@ -1582,19 +2431,21 @@ func (ws *WebServer) getLocationsHandler(c *gin.Context) {
 			continue
 		}
 
-		allLocations = append(allLocations, gin.H{
-			"name":       loc.Name,
-			"type":       "storage",
-			"is_virtual": false,
+		allLocations = append(allLocations, LocationSummary{
+			Name:      loc.Name,
+			Type:      "storage",
+			IsVirtual: false,
 		})
 	}
 
+	ws.bridge.annotateLocationEnvironments(allLocations)
+
 	// Get Spoolman URL for the message
-	spoolmanURL := ws.bridge.spoolman.GetBaseURL()
+	spoolmanURL := ws.spoolmanLinkBase(c)
 
-	c.JSON(http.StatusOK, gin.H{
-		"locations":    allLocations,
-		"spoolman_url": spoolmanURL,
+	c.JSON(http.StatusOK, LocationsResponse{
+		Locations:   allLocations,
+		SpoolmanURL: spoolmanURL,
 	})
 }
 
@@ -1602,28 +2453,23 @@ func (ws *WebServer) getLocationsHandler(c *gin.Context) {
 func (ws *WebServer) getLocationStatusHandler(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Location name is required"})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Location name is required")
 		return
 	}
 
 	// Check if location exists in Spoolman
 	location, err := ws.bridge.spoolman.FindLocationByName(name)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
 	if location == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		respondError(c, http.StatusNotFound, ErrCodeLocationNotFound, "Location not found")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"name":     location.Name,
-		"id":       location.ID,
-		"comment":  location.Comment,
-		"archived": location.Archived,
-	})
+	c.JSON(http.StatusOK, newLocationResponse(location))
 }
 
 // createLocationHandler creates a new location in Spoolman
@@ -1636,33 +2482,28 @@ func (ws *WebServer) createLocationHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("createLocationHandler: bad request: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		webLog.Warn("Create location: bad request", "error", err)
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
-	log.Printf("createLocationHandler: creating location name='%s' in Spoolman", req.Name)
+	webLog.Info("Creating location in Spoolman", "location", req.Name)
 	location, err := ws.bridge.spoolman.GetOrCreateLocation(req.Name)
 	if err != nil {
-		log.Printf("createLocationHandler: failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		webLog.Error("Failed to create location", "location", req.Name, "error", err)
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"name":     location.Name,
-		"id":       location.ID,
-		"comment":  location.Comment,
-		"archived": location.Archived,
-	})
+	c.JSON(http.StatusCreated, newLocationResponse(location))
 }
 
 // updateLocationHandler updates a location in Spoolman
 func (ws *WebServer) updateLocationHandler(c *gin.Context) {
 	oldName := c.Param("name")
 	if oldName == "" {
-		log.Printf("updateLocationHandler: missing location name")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Location name is required"})
+		webLog.Warn("Update location: missing location name")
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Location name is required")
 		return
 	}
 
@@ -1671,66 +2512,63 @@ func (ws *WebServer) updateLocationHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("updateLocationHandler: bad request for name='%s': %v", oldName, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		webLog.Warn("Update location: bad request", "location", oldName, "error", err)
+		respondErrorFrom(c, http.StatusBadRequest, ErrCodeInvalidRequest, err)
 		return
 	}
 
-	log.Printf("updateLocationHandler: renaming '%s' to '%s' in Spoolman", oldName, req.Name)
+	webLog.Info("Renaming location in Spoolman", "from", oldName, "to", req.Name)
 	if err := ws.bridge.spoolman.UpdateLocationByName(oldName, req.Name); err != nil {
-		log.Printf("updateLocationHandler: failed for name='%s': %v", oldName, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		webLog.Error("Failed to rename location", "location", oldName, "error", err)
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
 	// Get updated location
 	location, err := ws.bridge.spoolman.FindLocationByName(req.Name)
 	if err != nil {
-		log.Printf("Warning: Could not get updated location '%s': %v", req.Name, err)
-		c.JSON(http.StatusOK, gin.H{"message": "Location updated successfully"})
+		webLog.Warn("Could not get updated location", "location", req.Name, "error", err)
+		c.JSON(http.StatusOK, LocationUpdatedResponse{Message: "Location updated successfully"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Location updated successfully",
-		"location": gin.H{
-			"name":     location.Name,
-			"id":       location.ID,
-			"comment":  location.Comment,
-			"archived": location.Archived,
-		},
-	})
+	response := LocationUpdatedResponse{Message: "Location updated successfully"}
+	if location != nil {
+		updated := newLocationResponse(location)
+		response.Location = &updated
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // deleteLocationHandler archives a location in Spoolman (locations are archived, not deleted)
 func (ws *WebServer) deleteLocationHandler(c *gin.Context) {
 	name := c.Param("name")
 	if name == "" {
-		log.Printf("deleteLocationHandler: missing location name")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Location name is required"})
+		webLog.Warn("Delete location: missing location name")
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Location name is required")
 		return
 	}
 
 	// Find location by name
 	location, err := ws.bridge.spoolman.FindLocationByName(name)
 	if err != nil {
-		log.Printf("deleteLocationHandler: error finding location '%s': %v", name, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		webLog.Error("Error finding location", "location", name, "error", err)
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeSpoolmanError, err)
 		return
 	}
 
 	if location == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		respondError(c, http.StatusNotFound, ErrCodeLocationNotFound, "Location not found")
 		return
 	}
 
 	// Archive the location (Spoolman doesn't support deletion, only archiving)
-	log.Printf("deleteLocationHandler: archiving location '%s' (ID: %d)", name, location.ID)
+	webLog.Info("Archiving location", "location", name, "location_id", location.ID)
 	if err := ws.bridge.spoolman.ArchiveLocation(location.ID); err != nil {
-		log.Printf("deleteLocationHandler: failed to archive location '%s': %v", name, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive location"})
+		webLog.Error("Failed to archive location", "location", name, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrCodeSpoolmanError, "Failed to archive location")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Location archived successfully"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "Location archived successfully"})
 }
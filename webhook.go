@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookSecretHeader carries a printer's webhook secret; ?secret= works too for senders that
+// can't set headers
+const WebhookSecretHeader = "X-Webhook-Secret"
+
+// Print events accepted by the webhook
+const (
+	PrintEventStarted   = "started"
+	PrintEventFinished  = "finished"
+	PrintEventCancelled = "cancelled"
+)
+
+// printEventAliases maps the event names of common senders (OctoPrint events, Moonraker job
+// states) to the webhook's own, after lowercasing and removing separators
+var printEventAliases = map[string]string{
+	"started":        PrintEventStarted,
+	"start":          PrintEventStarted,
+	"printstarted":   PrintEventStarted,
+	"printing":       PrintEventStarted,
+	"finished":       PrintEventFinished,
+	"done":           PrintEventFinished,
+	"complete":       PrintEventFinished,
+	"completed":      PrintEventFinished,
+	"printdone":      PrintEventFinished,
+	"printfinished":  PrintEventFinished,
+	"cancelled":      PrintEventCancelled,
+	"canceled":       PrintEventCancelled,
+	"printcancelled": PrintEventCancelled,
+	"printcanceled":  PrintEventCancelled,
+	"failed":         PrintEventCancelled,
+	"printfailed":    PrintEventCancelled,
+	"error":          PrintEventCancelled,
+}
+
+// PrintEvent is a print start or finish pushed to the webhook by a printer or an integration
+// in front of it
+type PrintEvent struct {
+	PrinterID    string               `json:"printer_id"`
+	Event        string               `json:"event"`
+	Filename     string               `json:"filename"` // Path of the job's G-code on the printer
+	DisplayName  string               `json:"display_name"`
+	JobID        int                  `json:"job_id"`
+	TimePrinting int                  `json:"time_printing"` // Seconds
+	Filament     []PrintEventFilament `json:"filament"`      // Used if the G-code can't be downloaded
+}
+
+// PrintEventFilament is a toolhead's filament usage as reported by the sender
+type PrintEventFilament struct {
+	ToolheadID int     `json:"toolhead_id"`
+	Length     float64 `json:"length"` // mm
+	Weight     float64 `json:"weight"` // grams
+}
+
+// normalizePrintEvent returns the webhook event an event name stands for, or "" if unknown
+func normalizePrintEvent(name string) string {
+	name = strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	return printEventAliases[name]
+}
+
+// mergeTelemetry adds the usage reported with an event to a job's telemetry
+func (e PrintEvent) mergeTelemetry(telemetry jobTelemetry) jobTelemetry {
+	if e.TimePrinting > 0 {
+		telemetry.TimePrinting = e.TimePrinting
+	}
+	for _, filament := range e.Filament {
+		if filament.Length <= 0 && filament.Weight <= 0 {
+			continue
+		}
+		merged := make(map[int]reportedFilament, len(telemetry.Filament)+1)
+		for toolheadID, reported := range telemetry.Filament {
+			merged[toolheadID] = reported
+		}
+		merged[filament.ToolheadID] = reportedFilament{Length: filament.Length, Weight: filament.Weight}
+		telemetry.Filament = merged
+	}
+	return telemetry
+}
+
+// RotateWebhookSecret creates a new webhook secret for a printer, replacing the previous one,
+// and returns it. Only its hash is stored, so it's only shown once.
+func (b *FilamentBridge) RotateWebhookSecret(printerID string) (string, error) {
+	secret, err := generateAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, err := b.db.Exec(
+		"INSERT OR REPLACE INTO printer_webhook_secrets (printer_id, secret_hash, created_at) VALUES (?, ?, ?)",
+		printerID, hashAccessToken(secret), time.Now(),
+	); err != nil {
+		return "", fmt.Errorf("failed to store webhook secret: %w", err)
+	}
+	return secret, nil
+}
+
+// DeleteWebhookSecret removes a printer's webhook secret, turning its webhook off
+func (b *FilamentBridge) DeleteWebhookSecret(printerID string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	result, err := b.db.Exec("DELETE FROM printer_webhook_secrets WHERE printer_id = ?", printerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook secret: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return newCodedError(ErrCodeNotFound, "printer %s has no webhook secret", printerID)
+	}
+	return nil
+}
+
+// verifyWebhookSecret checks a secret against the printer's. Printers without one don't
+// accept webhooks.
+func (b *FilamentBridge) verifyWebhookSecret(printerID, secret string) error {
+	if secret == "" {
+		return newCodedError(ErrCodeUnauthorized, "a webhook secret is required")
+	}
+
+	var secretHash string
+	err := b.db.QueryRow("SELECT secret_hash FROM printer_webhook_secrets WHERE printer_id = ?", printerID).Scan(&secretHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get webhook secret: %w", err)
+	}
+	if err == sql.ErrNoRows || !hmac.Equal([]byte(hashAccessToken(secret)), []byte(secretHash)) {
+		return newCodedError(ErrCodeUnauthorized, "invalid printer or webhook secret")
+	}
+	return nil
+}
+
+// recordPushedPrintStart tracks the job of a started event the way a poll seeing the printer
+// print would, and reports whether it's a new job
+func (b *FilamentBridge) recordPushedPrintStart(event PrintEvent) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	printerID := event.PrinterID
+	b.wasPrinting[printerID] = true
+	newJob := b.currentJobFile[printerID] == "" || (event.JobID != 0 && event.JobID != b.currentJobID[printerID])
+	if newJob {
+		b.currentJobFile[printerID] = event.Filename
+		b.currentJobID[printerID] = event.JobID
+		b.currentJobName[printerID] = displayFilename(event.Filename, event.DisplayName)
+		b.jobTelemetry[printerID] = event.mergeTelemetry(jobTelemetry{})
+		monitorLog.Info("Stored job filename from webhook", "printer_id", printerID, "job", event.Filename, "job_id", event.JobID)
+	} else {
+		b.jobTelemetry[printerID] = event.mergeTelemetry(b.jobTelemetry[printerID])
+	}
+	return newJob
+}
+
+// clearPushedPrint stops tracking a cancelled print without recording its usage
+func (b *FilamentBridge) clearPushedPrint(event PrintEvent) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	printerID := event.PrinterID
+	if b.processingPrints[printerID] {
+		return newCodedError(ErrCodeConflict, "printer %s is already processing a print", printerID)
+	}
+	if b.currentJobFile[printerID] != "" {
+		monitorLog.Info("Print cancelled via webhook, not recording usage", "printer_id", printerID, "job", b.currentJobFile[printerID])
+	}
+	b.wasPrinting[printerID] = false
+	b.currentJobFile[printerID] = ""
+	b.currentJobID[printerID] = 0
+	b.currentJobName[printerID] = ""
+	delete(b.jobTelemetry, printerID)
+	return nil
+}
+
+// PushEvent applies a print event received by webhook. A finished print is processed in the
+// background on the monitor's context, so shutdown waits for it like for a polled one.
+func (m *PrinterMonitor) PushEvent(event PrintEvent) error {
+	snapshot := m.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		return newCodedError(ErrCodeInternal, "configuration not loaded")
+	}
+	config, exists := snapshot.Printers[event.PrinterID]
+	if !exists || isFixturePrinter(event.PrinterID) {
+		return newCodedError(ErrCodePrinterNotFound, "printer not found: %s", event.PrinterID)
+	}
+
+	switch event.Event {
+	case PrintEventStarted:
+		if event.Filename == "" {
+			return newCodedError(ErrCodeInvalidRequest, "filename is required for started events")
+		}
+		if m.bridge.recordPushedPrintStart(event) {
+			go m.bridge.checkSpoolsOnPrintStart(config, event.JobID, event.Filename, displayFilename(event.Filename, event.DisplayName))
+		}
+		return nil
+	case PrintEventCancelled:
+		return m.bridge.clearPushedPrint(event)
+	case PrintEventFinished:
+		return m.finishPushedPrint(config, event)
+	}
+	return newCodedError(ErrCodeInvalidRequest, "unknown event %q, expected started, finished or cancelled", event.Event)
+}
+
+// finishPushedPrint claims the printer's tracked print the way a poll seeing it finish would,
+// so a poll and a webhook reporting the same finish only record it once
+func (m *PrinterMonitor) finishPushedPrint(config PrinterConfig, event PrintEvent) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.ctx == nil || m.ctx.Err() != nil {
+		return newCodedError(ErrCodeInternal, "print monitoring isn't running")
+	}
+
+	printerID := event.PrinterID
+	b := m.bridge
+	b.mutex.Lock()
+	if b.processingPrints[printerID] {
+		b.mutex.Unlock()
+		return newCodedError(ErrCodeConflict, "printer %s is already processing a print", printerID)
+	}
+	if !b.wasPrinting[printerID] || b.currentJobFile[printerID] == "" {
+		b.mutex.Unlock()
+		return newCodedError(ErrCodeConflict, "no print is being tracked on printer %s; it may already have been recorded", printerID)
+	}
+	if event.JobID != 0 && b.currentJobID[printerID] != 0 && event.JobID != b.currentJobID[printerID] {
+		b.mutex.Unlock()
+		return newCodedError(ErrCodeConflict, "job %d isn't the print being tracked on printer %s", event.JobID, printerID)
+	}
+	print := unfinishedPrint{
+		PrinterID:   printerID,
+		Filename:    b.currentJobFile[printerID],
+		DisplayName: b.currentJobName[printerID],
+		JobID:       b.currentJobID[printerID],
+		Telemetry:   event.mergeTelemetry(b.jobTelemetry[printerID]),
+		FinishedAt:  time.Now(),
+	}
+	b.wasPrinting[printerID] = false
+	b.processingPrints[printerID] = true
+	b.mutex.Unlock()
+
+	monitorLog.Info("Print finished via webhook", "printer_id", printerID, "job", print.Filename, "job_id", print.JobID)
+	ctx := m.ctx
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		b.queueTrackedPrint(ctx, config, print, false)
+	}()
+	return nil
+}
+
+// printEventWebhookHandler receives print events pushed by printers or integrations. It's
+// authenticated by the printer's webhook secret rather than API credentials.
+func (ws *WebServer) printEventWebhookHandler(c *gin.Context) {
+	var event PrintEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	event.PrinterID = strings.TrimSpace(event.PrinterID)
+	if event.PrinterID == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "printer_id is required")
+		return
+	}
+
+	secret := strings.TrimSpace(c.GetHeader(WebhookSecretHeader))
+	if secret == "" {
+		secret = strings.TrimSpace(c.Query("secret"))
+	}
+	if err := ws.bridge.verifyWebhookSecret(event.PrinterID, secret); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+
+	name := event.Event
+	if event.Event = normalizePrintEvent(name); event.Event == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Unknown event %q, expected started, finished or cancelled", name))
+		return
+	}
+	if ws.monitor == nil {
+		respondError(c, http.StatusServiceUnavailable, ErrCodeInternal, "Print monitoring isn't running in this process")
+		return
+	}
+	if err := ws.monitor.PushEvent(event); err != nil {
+		respondErrorFrom(c, http.StatusServiceUnavailable, ErrCodeInternal, err)
+		return
+	}
+	ws.BroadcastStatus()
+
+	switch event.Event {
+	case PrintEventFinished:
+		c.JSON(http.StatusAccepted, MessageResponse{Message: "Print is being processed"})
+	case PrintEventCancelled:
+		c.JSON(http.StatusOK, MessageResponse{Message: "Print tracking cleared"})
+	default:
+		c.JSON(http.StatusOK, MessageResponse{Message: "Print start recorded"})
+	}
+}
+
+// rotateWebhookSecretHandler creates a printer's webhook secret, replacing any previous one
+func (ws *WebServer) rotateWebhookSecretHandler(c *gin.Context) {
+	printerID := c.Param("id")
+	snapshot := ws.bridge.GetConfigSnapshot()
+	if snapshot == nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "Configuration not loaded")
+		return
+	}
+	if _, exists := snapshot.Printers[printerID]; !exists {
+		respondError(c, http.StatusNotFound, ErrCodePrinterNotFound, "Printer not found")
+		return
+	}
+
+	secret, err := ws.bridge.RotateWebhookSecret(printerID)
+	if err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.recordWebhookSecretEvent(c, printerID, PrinterEventWebhookSecretRotated, "Webhook secret created")
+
+	webLog.Info("Rotated printer webhook secret", "printer_id", printerID)
+	c.JSON(http.StatusOK, WebhookSecretResponse{
+		Message: "Webhook secret created; it won't be shown again",
+		Secret:  secret,
+		Header:  WebhookSecretHeader,
+	})
+}
+
+// deleteWebhookSecretHandler turns a printer's webhook off
+func (ws *WebServer) deleteWebhookSecretHandler(c *gin.Context) {
+	printerID := c.Param("id")
+	if err := ws.bridge.DeleteWebhookSecret(printerID); err != nil {
+		respondErrorFrom(c, http.StatusInternalServerError, ErrCodeInternal, err)
+		return
+	}
+	ws.recordWebhookSecretEvent(c, printerID, PrinterEventWebhookSecretRemoved, "Webhook secret removed")
+	c.JSON(http.StatusOK, MessageResponse{Message: "Webhook secret removed"})
+}
+
+// recordWebhookSecretEvent adds a webhook secret change to the printer's audit log
+func (ws *WebServer) recordWebhookSecretEvent(c *gin.Context, printerID, eventType, notes string) {
+	var member string
+	if caller := callerMember(c); caller != nil {
+		member = caller.Name
+	}
+	event := PrinterEvent{
+		PrinterID: printerID,
+		EventType: eventType,
+		Member:    member,
+		Notes:     notes,
+		CreatedAt: time.Now(),
+	}
+	if err := ws.bridge.recordPrinterEvent(event); err != nil {
+		webLog.Error("Failed to record webhook secret change", "printer_id", printerID, "error", err)
+	}
+}